@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/internal/storage"
+	"github.com/sampila/uniai-client/pkg/rag"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	askFile        string // Document to ask about
+	askPages       string // Page range to ask about
+	askModel       string // Model building the digest and answering
+	askDigest      string // File storing the page digest between runs
+	askDigestWidth int    // Render width of the pages sent for the digest
+	askDigestWords int    // Maximum words of a page digest
+	askTargetPages int    // Maximum pages sent in full with a question
+	askJson        bool   // Flag to print the answers with their pages as JSON
+)
+
+var askCmd = &cobra.Command{
+	Use:   "ask QUESTION...",
+	Short: "Answer questions from the indexed documents or about a document",
+	Long: `Ask answers questions from the documents indexed by 'uniai index' in --store,
+or about a whole document given with --file.
+
+Without --file, the --top-k passages of the index most similar to a question
+are retrieved and sent to the model as context of a chat, and the answer
+cites the passages it used, listed after it.
+
+With --file, the document is answered about without sending every page at
+full resolution. A compact digest of every page is built first from low resolution renders,
+or from the text layer when there is one. Every question is then answered
+in two requests: the model picks from the digest the few pages it needs,
+at most --target-pages, and answers with the digest plus those pages at
+full resolution.
+
+With --digest, the digest is stored and reused by later runs until the
+document changes, so that only the first question about a document pays for
+it. --pages, --digest and the digest flags only apply with --file.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if askFile == "" {
+			return askIndex(cmd, args)
+		}
+
+		if askDigestWidth <= 0 {
+			return errors.New("--digest-width must be positive")
+		}
+		pageNumbers, err := cli.ParsePageRange(askPages)
+		if err != nil {
+			return fmt.Errorf("invalid page range: %w", err)
+		}
+
+		client, err := newClient(cmd, askFile)
+		if err != nil {
+			return fmt.Errorf("failed to initialize UniAI client: %w", err)
+		}
+
+		ctx := cmd.Context()
+		path := askFile
+		if storage.IsRemote(askFile) {
+			local, err := storage.Download(ctx, askFile)
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(filepath.Dir(local))
+			path = local
+		}
+
+		qa := &uniai.DocumentQA{
+			Client:      client,
+			Model:       askModel,
+			DigestWords: askDigestWords,
+			TargetPages: askTargetPages,
+		}
+		digest, err := documentDigest(ctx, qa, path, pageNumbers)
+		if err != nil {
+			return err
+		}
+
+		load := func(ctx context.Context, pages []int) ([]uniai.PageInput, error) {
+			loaded, err := loadInputPages(ctx, path, pages, true)
+			if err != nil {
+				return nil, err
+			}
+			inputs := make([]uniai.PageInput, len(loaded))
+			for i, page := range loaded {
+				inputs[i] = page.pageInput()
+			}
+			return inputs, nil
+		}
+
+		var answers []*uniai.DocumentAnswer
+		for i, question := range args {
+			var stream func(string)
+			if !askJson {
+				if i > 0 {
+					fmt.Println()
+				}
+				if len(args) > 1 {
+					fmt.Printf("Q: %s\n", question)
+				}
+				stream = func(text string) { fmt.Print(text) }
+			}
+
+			answer, err := qa.Answer(ctx, question, digest.Select(pageNumbers), load, stream)
+			if err != nil {
+				return fmt.Errorf("failed to answer %q: %w", question, err)
+			}
+			answers = append(answers, answer)
+			if !askJson {
+				fmt.Println()
+				println("Pages read in full:", fmt.Sprint(answer.Pages))
+			}
+		}
+
+		if askJson {
+			data, err := json.MarshalIndent(answers, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		}
+
+		return nil
+	},
+}
+
+// indexAnswer is the answer to a question from the index, as printed with
+// --json.
+type indexAnswer struct {
+	Text    string        `json:"text"`
+	Sources []indexSource `json:"sources"`
+}
+
+// indexSource is a passage cited by an indexAnswer.
+type indexSource struct {
+	Number int     `json:"number"`
+	Source string  `json:"source"`
+	Page   int     `json:"page"`
+	Score  float64 `json:"score"`
+}
+
+// askIndex answers questions from the documents indexed in --store.
+func askIndex(cmd *cobra.Command, questions []string) error {
+	store, err := rag.OpenStore(ragStoreDir)
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	if len(store.Chunks) == 0 {
+		return fmt.Errorf("no documents indexed in %s, index some with 'uniai index' or ask about a document with --file", ragStoreDir)
+	}
+
+	client, err := newClient(cmd, "")
+	if err != nil {
+		return fmt.Errorf("failed to initialize UniAI client: %w", err)
+	}
+
+	var answers []indexAnswer
+	for i, question := range questions {
+		if !askJson {
+			if i > 0 {
+				fmt.Println()
+			}
+			if len(questions) > 1 {
+				fmt.Printf("Q: %s\n", question)
+			}
+		}
+
+		answer, err := answerFromIndex(cmd.Context(), client, store, askModel, question, askJson)
+		if err != nil {
+			return fmt.Errorf("failed to answer %q: %w", question, err)
+		}
+		if !askJson {
+			printSources(answer)
+			continue
+		}
+
+		ia := indexAnswer{Text: answer.Text, Sources: []indexSource{}}
+		for _, c := range answer.Citations {
+			ia.Sources = append(ia.Sources, indexSource{Number: c.Number, Source: c.Source, Page: c.Page, Score: c.Score})
+		}
+		answers = append(answers, ia)
+	}
+
+	if askJson {
+		data, err := json.MarshalIndent(answers, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	}
+
+	return nil
+}
+
+// documentDigest returns the digest of the selected pages of the document at
+// path, reusing the one stored at --digest if it covers them and storing the
+// one it builds otherwise.
+func documentDigest(ctx context.Context, qa *uniai.DocumentQA, path string, pageNumbers []int) (*cli.DocumentDigest, error) {
+	sum, err := cli.HashFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash document: %w", err)
+	}
+
+	if askDigest != "" {
+		digest, err := cli.LoadDocumentDigest(askDigest)
+		if err != nil {
+			return nil, err
+		}
+		if digest.Covers(sum, pageNumbers) {
+			logger.Info("Reusing page digest", "path", askDigest, "pages", len(digest.Pages))
+			return digest, nil
+		}
+	}
+
+	settings := cli.DefaultThumbnailSettings
+	settings.Width = askDigestWidth
+	pages, err := loadInputPagesAt(ctx, path, pageNumbers, true, settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load document: %w", err)
+	}
+	inputs := make([]uniai.PageInput, len(pages))
+	for i, page := range pages {
+		inputs[i] = page.pageInput()
+	}
+
+	qa.Progress = func(d uniai.PageDigest) {
+		println("Digested page", d.Page)
+	}
+	digests, err := qa.Digest(ctx, inputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to digest document: %w", err)
+	}
+
+	model := qa.Model
+	if model == "" {
+		model = uniai.ModelDefault
+	}
+	digest := &cli.DocumentDigest{
+		SchemaVersion: cli.SchemaVersion,
+		Source:        askFile,
+		SHA256:        sum,
+		Model:         model,
+		Pages:         digests,
+	}
+	if askDigest != "" {
+		if err := digest.WriteJSON(askDigest); err != nil {
+			return nil, fmt.Errorf("failed to write digest: %w", err)
+		}
+	}
+
+	return digest, nil
+}
+
+func init() {
+	askCmd.Flags().StringVarP(&askFile, "file", "f", "", "Path or URL of the document, instead of answering from the index")
+	askCmd.Flags().StringVarP(&askPages, "pages", "r", "", "Page range to ask about (all pages by default)")
+	askCmd.Flags().StringVar(&askModel, "model", uniai.ModelDefault, "Model answering, and building the digest with --file")
+	askCmd.Flags().StringVar(&askDigest, "digest", "", "File storing the page digest, reused while the document is unchanged")
+	askCmd.Flags().IntVar(&askDigestWidth, "digest-width", cli.DefaultThumbnailSettings.Width*2, "Render width in pixels of the pages sent for the digest")
+	askCmd.Flags().IntVar(&askDigestWords, "digest-words", uniai.DefaultDigestWords, "Maximum words of a page digest")
+	askCmd.Flags().IntVar(&askTargetPages, "target-pages", uniai.DefaultTargetPages, "Maximum pages sent in full with a question")
+	askCmd.Flags().BoolVar(&askJson, "json", false, "Print the answers with the passages cited, or the pages read in full with --file, as JSON")
+	askCmd.Flags().StringVar(&ragStoreDir, "store", defaultRagStoreDir, "Directory of the vector store the questions are answered from without --file")
+	askCmd.Flags().IntVar(&topK, "top-k", rag.DefaultOptions.TopK, "Number of passages retrieved per question without --file")
+
+	uniaiCmd.AddCommand(askCmd)
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	auditEnabled bool   // Flag to indicate if requests and responses should be recorded in the audit log
+	auditFile    string // Audit log file, defaults to audit.jsonl in the user config directory
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the audit log of prompts and responses",
+	Long: `With --audit, every request of the uniai commands is appended to the audit log
+with its prompt, response, user, command, document and time. Records are
+chained by their SHA-256 hashes, so modified or removed records are detected
+by 'uniai audit verify'.
+
+If ` + cli.AuditKeyEnv + ` holds a base64 encoded 32-byte key, prompts and
+responses are encrypted with AES-256-GCM; their hashes stay readable.`,
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that no record of the audit log was modified or removed",
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := auditLogPath()
+		if err != nil {
+			println("Failed to resolve audit log:", err.Error())
+			return
+		}
+
+		n, err := cli.VerifyAudit(path)
+		if err != nil {
+			println("Audit log verification failed:", err.Error())
+			return
+		}
+		fmt.Printf("%d record(s) verified\n", n)
+	},
+}
+
+var auditShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the records of the audit log, decrypted with " + cli.AuditKeyEnv,
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := auditLogPath()
+		if err != nil {
+			println("Failed to resolve audit log:", err.Error())
+			return
+		}
+		key, err := auditKey()
+		if err != nil {
+			println("Failed to read audit key:", err.Error())
+			return
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		err = cli.ReadAudit(path, func(line int, rec cli.AuditRecord) error {
+			if rec.Encrypted && key != nil {
+				if err := rec.Decrypt(key); err != nil {
+					return fmt.Errorf("line %d: %w", line, err)
+				}
+			}
+			return enc.Encode(rec)
+		})
+		if err != nil {
+			println("Failed to read audit log:", err.Error())
+		}
+	},
+}
+
+// recordAudit adds the requests of client about document to the audit log
+// when --audit is set.
+func recordAudit(cmd *cobra.Command, client *uniai.Client, document string) error {
+	if !auditEnabled {
+		return nil
+	}
+
+	path, err := auditLogPath()
+	if err != nil {
+		return err
+	}
+	key, err := auditKey()
+	if err != nil {
+		return err
+	}
+	auditLog, err := cli.NewAuditLog(path, key)
+	if err != nil {
+		return err
+	}
+
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	command := strings.TrimSpace(cmd.CommandPath())
+	client.OnExchange(func(ex uniai.Exchange) {
+		rec := cli.AuditRecord{
+			Time:     ex.Started.UTC(),
+			User:     name,
+			Command:  command,
+			Document: document,
+			Kind:     ex.Kind,
+			Model:    ex.Model,
+			System:   ex.System,
+			Prompt:   ex.Prompt,
+			Response: ex.Response,
+			Duration: ex.Duration,
+		}
+		for _, img := range ex.Images {
+			rec.Images = append(rec.Images, cli.HashBytes(img))
+		}
+		if ex.Err != nil {
+			rec.Error = redactor.String(ex.Err.Error())
+		}
+		if err := auditLog.Add(rec); err != nil {
+			println("Failed to record audit:", err.Error())
+		}
+	})
+
+	return nil
+}
+
+func auditLogPath() (string, error) {
+	if auditFile != "" {
+		return auditFile, nil
+	}
+
+	return cli.DefaultAuditPath()
+}
+
+// auditKey returns the key of cli.AuditKeyEnv, or nil if it is not set.
+func auditKey() ([]byte, error) {
+	s := os.Getenv(cli.AuditKeyEnv)
+	if s == "" {
+		return nil, nil
+	}
+
+	return cli.ParseAuditKey(s)
+}
+
+func init() {
+	uniaiCmd.PersistentFlags().BoolVar(&auditEnabled, "audit", false, "Record every prompt and response in the append-only audit log")
+	uniaiCmd.PersistentFlags().StringVar(&auditFile, "audit-log", "", "Audit log file (defaults to "+cli.AuditFile+" in the user config directory)")
+
+	auditCmd.AddCommand(auditVerifyCmd, auditShowCmd)
+	uniaiCmd.AddCommand(auditCmd)
+}
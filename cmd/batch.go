@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/internal/storage"
+)
+
+var (
+	batchDir    string // Directory of the documents of a batch run
+	batchResume bool   // Flag to skip the documents completed by the previous batch run
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch -d <dir>",
+	Short: "Process every document of a directory",
+	Long: `Batch processes every document found under a directory, recursively, like
+'uniai' does a single one: PDFs, HTML pages, emails and PNG or JPEG images.
+It accepts the flags of 'uniai', except --file; every document gets its own
+directory in --output.
+
+The outcome of every document, with the pages rendered, answered, skipped and
+failed, is written to ` + cli.BatchStateFile + ` in --output as soon as the document
+completes, and a summary of all documents is printed at the end. With
+--resume, the documents the previous run completed without failures, and
+unchanged since, are skipped; the pages answered by the documents left are
+reused from the response cache, so a crashed run loses no answered page.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if batchDir == "" || outputDir == "" || (prompt == "" && templateName == "") {
+			return cmd.Help()
+		}
+		cmd.SilenceUsage = true
+
+		if storage.IsRemote(outputDir) {
+			return errors.New("batch keeps its state in the output directory, it cannot be used with an object storage --output")
+		}
+		if summaryFile != "" {
+			return errors.New("--summary-file cannot be used with batch, which writes the outcome of every document to " + cli.BatchStateFile)
+		}
+
+		// The page images written to --output would be read as documents
+		// by the next run.
+		if sameDir(batchDir, outputDir) {
+			return errors.New("--output cannot be --dir, the output files would be read as documents")
+		}
+		files, err := walkDocuments(batchDir, outputDir)
+		if err != nil {
+			return fmt.Errorf("failed to list documents: %w", err)
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("no documents found in %s", batchDir)
+		}
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+
+		statePath := filepath.Join(outputDir, cli.BatchStateFile)
+		state := cli.NewBatchState(statePath, batchDir)
+		if batchResume {
+			if state, err = cli.LoadBatchState(statePath); err != nil {
+				return fmt.Errorf("failed to load batch state: %w", err)
+			}
+			state.Input = batchDir
+		}
+
+		// Every document writes its run summary here, read back into the
+		// state.
+		tmp, err := os.MkdirTemp("", "uniai-batch-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		defer func() { summaryFile = "" }()
+
+		// The prompt is replaced by its rendered template during a run.
+		basePrompt := prompt
+		ctx := cmd.Context()
+		for i, file := range files {
+			if ctx.Err() != nil {
+				break
+			}
+
+			hash, err := cli.HashFile(file)
+			if err != nil {
+				state.Set(cli.NewBatchFile(file, "", nil, fmt.Errorf("failed to hash file: %w", err), 0))
+				if err := state.Write(); err != nil {
+					return fmt.Errorf("failed to write batch state: %w", err)
+				}
+				continue
+			}
+			if batchResume && state.Done(file, hash) {
+				logger.Info("skipping completed document", "file", file)
+				continue
+			}
+
+			logger.Info("processing document", "file", file, "document", i+1, "documents", len(files))
+			filePath, prompt = file, basePrompt
+			summaryFile = filepath.Join(tmp, fmt.Sprintf("summary_%d.json", i+1))
+			start := time.Now()
+			runErr := uniaiCmd.RunE(cmd, nil)
+			if runErr != nil {
+				logger.Error("document failed", "file", file, "err", runErr)
+			}
+
+			summary, err := cli.LoadRunSummary(summaryFile)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				logger.Warn("failed to read run summary", "file", file, "err", err)
+			}
+			state.Set(cli.NewBatchFile(file, hash, summary, runErr, time.Since(start)))
+			if err := state.Write(); err != nil {
+				return fmt.Errorf("failed to write batch state: %w", err)
+			}
+		}
+		logger.Info("batch state written", "path", statePath)
+
+		return printBatchSummary(state, files)
+	},
+}
+
+// walkDocuments returns the documents 'uniai' reads found under dir,
+// recursively: PDFs and the files [cli.ConvertFile] converts. The directory
+// skip, if any, is left out.
+func walkDocuments(dir, skip string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if skip != "" && sameDir(path, skip) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(path), ".pdf") || cli.IsConvertible(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+
+	return files, err
+}
+
+// sameDir reports whether the paths a and b are the same directory.
+func sameDir(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+
+	return errA == nil && errB == nil && absA == absB
+}
+
+// printBatchSummary prints the outcome of every document of files in state,
+// and returns an error if some did not complete.
+func printBatchSummary(state *cli.BatchState, files []string) error {
+	outcomes := make(map[string]*cli.BatchFile, len(state.Files))
+	for _, f := range state.Files {
+		outcomes[f.Path] = f
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "FILE\tSTATUS\tPAGES\tANSWERED\tSKIPPED\tFAILED\tDURATION\n")
+	incomplete := 0
+	for _, file := range files {
+		f, ok := outcomes[file]
+		if !ok {
+			// Not reached before the run was interrupted.
+			fmt.Fprintf(w, "%s\t%s\t\t\t\t\t\n", file, cli.StatusUnprocessed)
+			incomplete++
+			continue
+		}
+		if f.Status != cli.StatusOK {
+			incomplete++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n", file, f.Status, f.Pages, len(f.Answered), len(f.Skipped), len(f.Failed), f.Duration.Round(time.Millisecond))
+	}
+	w.Flush()
+
+	if incomplete > 0 {
+		return fmt.Errorf("%d of %d document(s) did not complete, run again with --resume to retry them", incomplete, len(files))
+	}
+
+	return nil
+}
+
+func init() {
+	batchCmd.Flags().StringVarP(&batchDir, "dir", "d", "", "Directory of the documents to process, searched recursively")
+	batchCmd.Flags().BoolVar(&batchResume, "resume", false, "Skip the documents the previous batch run in --output completed without failures, unless they changed since")
+
+	uniaiCmd.AddCommand(batchCmd)
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestWalkDocuments checks that the documents of a batch are found in the
+// subdirectories of --dir, except in --output.
+func TestWalkDocuments(t *testing.T) {
+	t.Chdir(t.TempDir())
+	for _, path := range []string{"docs/a.pdf", "docs/notes.txt", "docs/sub/b.PNG", "docs/out/a/page_1.jpg", "docs/out/batch.json"} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		output string
+		want   []string
+	}{
+		{output: "docs/out", want: []string{"docs/a.pdf", "docs/sub/b.PNG"}},
+		{output: "./docs/out/", want: []string{"docs/a.pdf", "docs/sub/b.PNG"}},
+		{output: "elsewhere", want: []string{"docs/a.pdf", "docs/out/a/page_1.jpg", "docs/sub/b.PNG"}},
+		{output: "", want: []string{"docs/a.pdf", "docs/out/a/page_1.jpg", "docs/sub/b.PNG"}},
+	}
+
+	for _, tt := range tests {
+		got, err := walkDocuments("docs", tt.output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range got {
+			got[i] = filepath.ToSlash(got[i])
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("walkDocuments(docs, %q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+
+	if !sameDir("docs", "./docs/") {
+		t.Error("sameDir(docs, ./docs/) = false")
+	}
+}
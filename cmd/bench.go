@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// defaultBenchPrompt is the prompt of the synthetic requests of bench.
+const defaultBenchPrompt = "Write a short paragraph explaining what a PDF document is."
+
+var (
+	benchFile        string   // Sample document whose pages are sent
+	benchPages       string   // Page range of the sample document
+	benchPrompt      string   // Prompt of the requests
+	benchModels      []string // Models benchmarked
+	benchRequests    int      // Requests per model
+	benchConcurrency int      // Requests in flight at a time
+	benchMaxTokens   int      // Completion tokens per request
+	benchJson        bool     // Flag to print the results as JSON
+
+	benchProfile  cli.LoadProfile // Ramp profile of a sustained benchmark
+	benchInterval time.Duration   // Interval of the results over time
+	benchCSV      string          // File the results are exported to as CSV
+	benchOutput   string          // File the results are exported to as JSON
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Benchmark the latency and throughput of models",
+	Long: `Bench sends --requests generate requests to every model, --concurrency at a
+time, and reports per model the p50, p95 and maximum latency of the
+successful requests, the median time to the first token, the completion
+tokens and requests per second, and the error rate with the errors, for the
+capacity planning of self-hosted backends.
+
+The requests are synthetic text prompts, or the pages of a sample document
+with --file, sent in turn. --max-tokens bounds the answers so that the runs
+of different models compare; the models are benchmarked one after the other.
+
+With --ramp-up, --steady or --ramp-down, the benchmark is a sustained load
+test instead: for each model, the requests in flight grow from 1 to
+--concurrency during --ramp-up, stay there during --steady and go back down
+during --ramp-down, whatever --requests. The results are also reported every
+--interval with the load of the interval, along with the peak throughput and
+its concurrency, the saturation point of the deployment: beyond it, more
+requests in flight only add latency.
+
+--csv and --output export the results, over time for a load test, to CSV and
+JSON files.
+
+  uniai bench --models uniai01:7b,uniai01:32b -n 100 -c 8
+  uniai bench -f sample.pdf --pages 1-5 -m "Extract the text" -n 50
+  uniai bench -c 32 --ramp-up 5m --steady 10m --ramp-down 2m --csv load.csv`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if benchRequests < 1 || benchConcurrency < 1 {
+			return fmt.Errorf("--requests and --concurrency must be at least 1")
+		}
+		if benchProfile.RampUp < 0 || benchProfile.Steady < 0 || benchProfile.RampDown < 0 {
+			return fmt.Errorf("--ramp-up, --steady and --ramp-down must not be negative")
+		}
+		benchProfile.Peak = benchConcurrency
+
+		ctx := cmd.Context()
+		prompt := benchPrompt
+		var pages []inputPage
+		if benchFile != "" {
+			pageNumbers, err := cli.ParsePageRange(benchPages)
+			if err != nil {
+				return fmt.Errorf("invalid page range: %w", err)
+			}
+			if pages, err = loadInputPages(ctx, benchFile, pageNumbers, false); err != nil {
+				return fmt.Errorf("failed to load %s: %w", benchFile, err)
+			}
+			if len(pages) == 0 {
+				return fmt.Errorf("no pages to send in %s", benchFile)
+			}
+		} else if prompt == "" {
+			prompt = defaultBenchPrompt
+		}
+
+		client, err := newClient(cmd, benchFile)
+		if err != nil {
+			return fmt.Errorf("failed to initialize UniAI client: %w", err)
+		}
+		// Failed requests count as errors rather than adding to the latency
+		// of their retries.
+		client.UseRetry(uniai.RetryPolicy{})
+
+		options := cli.MergeOptions(uniai.DefaultOptions, map[string]any{"num_predict": benchMaxTokens})
+		request := func(model string, i int) *uniai.GenerateRequest {
+			req := &uniai.GenerateRequest{Model: model, Prompt: prompt, Options: options}
+			if len(pages) > 0 {
+				page := pages[i%len(pages)]
+				if page.image != nil {
+					req.Images = []uniai.ImageData{page.image}
+				} else {
+					req.Prompt = cli.TextPrompt(prompt, page.text)
+				}
+			}
+			return req
+		}
+
+		results := make([]cli.BenchResult, 0, len(benchModels))
+		for _, model := range benchModels {
+			bench := cli.NewBench(model, benchConcurrency)
+			var res cli.BenchResult
+			if benchProfile.Duration() > 0 {
+				logger.Info("load testing", "model", model, "peak", benchProfile.Peak, "duration", benchProfile.Duration())
+				runLoad(ctx, client, bench, benchProfile, func(i int) *uniai.GenerateRequest { return request(model, i) })
+				res = bench.Result()
+				profile := benchProfile
+				res.Profile, res.Windows = &profile, bench.Windows(benchInterval)
+				res.Peak = cli.PeakWindow(res.Windows)
+			} else {
+				logger.Info("benchmarking", "model", model, "requests", benchRequests, "concurrency", benchConcurrency)
+				runRequests(ctx, client, bench, benchRequests, benchConcurrency, func(i int) *uniai.GenerateRequest { return request(model, i) })
+				res = bench.Result()
+			}
+			results = append(results, res)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		if benchCSV != "" {
+			if err := writeBenchFile(benchCSV, func(f *os.File) error { return cli.WriteBenchCSV(f, results) }); err != nil {
+				return err
+			}
+			logger.Info("results exported", "path", benchCSV)
+		}
+		if benchOutput != "" {
+			if err := writeBenchFile(benchOutput, func(f *os.File) error { return writeBenchJSON(f, results) }); err != nil {
+				return err
+			}
+			logger.Info("results exported", "path", benchOutput)
+		}
+
+		if benchJson {
+			return writeBenchJSON(os.Stdout, results)
+		}
+
+		return cli.WriteBenchResults(os.Stdout, results)
+	},
+}
+
+// runRequests sends n requests, concurrency at a time, and records them in
+// bench.
+func runRequests(ctx context.Context, client *uniai.Client, bench *cli.Bench, n, concurrency int, request func(i int) *uniai.GenerateRequest) {
+	requests := make(chan int)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range requests {
+				sample := benchRequest(ctx, client, request(i))
+				sample.Concurrency = concurrency
+				bench.Add(sample)
+			}
+		}()
+	}
+	for i := range n {
+		requests <- i
+	}
+	close(requests)
+	wg.Wait()
+}
+
+// runLoad sends requests following profile until it is over, and records
+// them in bench. Each of the Peak workers sends requests one after the other
+// while the profile allows as many requests in flight as its rank.
+func runLoad(ctx context.Context, client *uniai.Client, bench *cli.Bench, profile cli.LoadProfile, request func(i int) *uniai.GenerateRequest) {
+	start := time.Now()
+	var sent atomic.Int64
+	var wg sync.WaitGroup
+	for worker := range profile.Peak {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				elapsed := time.Since(start)
+				if elapsed >= profile.Duration() {
+					return
+				}
+				concurrency := profile.Concurrency(elapsed)
+				if worker >= concurrency {
+					select {
+					case <-ctx.Done():
+					case <-time.After(100 * time.Millisecond):
+					}
+					continue
+				}
+
+				sample := benchRequest(ctx, client, request(int(sent.Add(1)-1)))
+				sample.Concurrency = concurrency
+				bench.Add(sample)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// writeBenchJSON writes the results to w as indented JSON.
+func writeBenchJSON(w io.Writer, results []cli.BenchResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(results)
+}
+
+// writeBenchFile creates the file at path and writes it with write.
+func writeBenchFile(path string, write func(f *os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// benchRequest sends req and measures it.
+func benchRequest(ctx context.Context, client *uniai.Client, req *uniai.GenerateRequest) cli.BenchSample {
+	var sample cli.BenchSample
+	start := time.Now()
+	sample.Err = client.Generate(ctx, req, func(resp uniai.GenerateResponse) error {
+		if sample.FirstToken == 0 {
+			sample.FirstToken = time.Since(start)
+		}
+		if resp.Done {
+			sample.Tokens = resp.EvalCount
+		}
+		return nil
+	})
+	sample.Latency = time.Since(start)
+
+	return sample
+}
+
+func init() {
+	benchCmd.Flags().StringVarP(&benchFile, "file", "f", "", "Sample document (PDF, image or text) whose pages are sent in turn instead of a synthetic prompt")
+	benchCmd.Flags().StringVar(&benchPages, "pages", "", "Page range of the sample document (e.g. 1-5), all pages if empty")
+	benchCmd.Flags().StringVarP(&benchPrompt, "prompt", "m", "", "Prompt of the requests, a synthetic one if empty")
+	benchCmd.Flags().StringSliceVar(&benchModels, "models", []string{uniai.ModelDefault}, "Comma-separated models to benchmark")
+	benchCmd.Flags().IntVarP(&benchRequests, "requests", "n", 20, "Requests sent to every model")
+	benchCmd.Flags().IntVarP(&benchConcurrency, "concurrency", "c", 4, "Requests in flight at a time")
+	benchCmd.Flags().IntVar(&benchMaxTokens, "max-tokens", 256, "Completion tokens per request (num_predict)")
+	benchCmd.Flags().BoolVar(&benchJson, "json", false, "Print the results as JSON")
+	benchCmd.Flags().DurationVar(&benchProfile.RampUp, "ramp-up", 0, "Load test: time to grow the requests in flight from 1 to --concurrency")
+	benchCmd.Flags().DurationVar(&benchProfile.Steady, "steady", 0, "Load test: time to hold --concurrency requests in flight")
+	benchCmd.Flags().DurationVar(&benchProfile.RampDown, "ramp-down", 0, "Load test: time to bring the requests in flight back to 1")
+	benchCmd.Flags().DurationVar(&benchInterval, "interval", 10*time.Second, "Load test: interval of the results over time")
+	benchCmd.Flags().StringVar(&benchCSV, "csv", "", "Export the results to this CSV file")
+	benchCmd.Flags().StringVar(&benchOutput, "output", "", "Export the results to this JSON file")
+
+	uniaiCmd.AddCommand(benchCmd)
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/sampila/uniai-client/pkg/uniai/cassette"
+)
+
+var (
+	cassetteFile string // Cassette recording or replaying the API requests
+	cassetteMode string // Cassette mode: record or replay
+)
+
+var (
+	apiClientOnce sync.Once
+	apiClient     *http.Client
+	apiClientErr  error
+)
+
+// apiHTTPClient returns the HTTP client of the API clients: nil for the
+// default client, or one recording or replaying --cassette and tracing the
+// requests with --debug-http. All clients of a command share the cassette
+// and the trace.
+func apiHTTPClient() (*http.Client, error) {
+	if cassetteFile == "" && !debugHTTP {
+		return nil, nil
+	}
+
+	apiClientOnce.Do(func() {
+		var transport http.RoundTripper = http.DefaultTransport
+		if cassetteFile != "" {
+			if transport, apiClientErr = cassette.Open(cassetteFile, cassetteMode, nil); apiClientErr != nil {
+				return
+			}
+		}
+		if debugHTTP {
+			if transport, apiClientErr = traceHTTP(transport); apiClientErr != nil {
+				return
+			}
+		}
+		apiClient = &http.Client{Transport: transport}
+	})
+
+	return apiClient, apiClientErr
+}
+
+func init() {
+	uniaiCmd.PersistentFlags().StringVar(&cassetteFile, "cassette", "", "Record the API requests to this file, or replay them from it, for deterministic tests and offline runs (see --cassette-mode)")
+	uniaiCmd.PersistentFlags().StringVar(&cassetteMode, "cassette-mode", cassette.ModeReplay, "With --cassette: 'record' (send requests to the API and record the sanitized exchanges) or 'replay' (answer requests from the cassette without network)")
+}
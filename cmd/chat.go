@@ -0,0 +1,553 @@
+package cmd
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	sessionName  string // Name of the persisted chat session
+	sessionDir   string // Directory of the chat sessions
+	chatMessage  string // Single message to send instead of starting an interactive chat
+	systemPrompt string // System prompt of a new session
+	exportFormat string // Format of exported sessions: markdown or json
+	exportOutput string // File the session is exported to
+
+	transcriptFile   string // File every finalized turn is appended to
+	transcriptFormat string // Format of the transcript: markdown or jsonl
+
+	forkAt      int // Number of messages of the session kept by a fork, all of them if negative
+	chatDiffCtx int // Number of unchanged lines shown around the changes of two sessions
+)
+
+var (
+	chatFile      string // Document the conversation is about
+	chatPages     string // Page range of the document
+	chatTextFirst bool   // Flag to send the pages with a text layer as text
+	chatHistory   string // File the conversation is saved to and resumed from
+)
+
+var chatCmd = &cobra.Command{
+	Use:   "chat",
+	Short: "Chat with the UniAI model",
+	Long: `Chat starts an interactive conversation with the UniAI model. With --session the
+history is saved after every reply and resumed the next time the same session
+is opened; --history does the same with a history file anywhere. With
+--transcript every finalized turn is also appended to a Markdown or JSONL
+transcript.
+
+With --file, the conversation is about a document (PDF, image or text): its
+pages are given as context once, in the system message, as text for the pages
+with a text layer and as images for the others, and every question can
+follow up on the previous answers:
+
+  uniai chat -f contract.pdf --history contract-chat.json
+
+Commands:
+  /history                      list the user messages, numbered
+  /retry [model] [key=value]... regenerate the last reply, optionally with
+                                another model or options, e.g. temperature=0.8
+  /edit <n> <message>           replace the n-th user message and regenerate
+                                the conversation from it
+  /exit                         quit, as does Ctrl-D`,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd, "")
+		if err != nil {
+			println("Failed to initialize UniAI client:", err.Error())
+			return
+		}
+
+		if sessionName != "" && chatHistory != "" {
+			println("--session and --history cannot be used together")
+			return
+		}
+
+		// save persists the session after every reply, if it is saved.
+		var save func(*cli.Session) error
+		sess := &cli.Session{Name: sessionName, Model: uniai.ModelDefault}
+		var loaded *cli.Session
+		switch {
+		case sessionName != "":
+			var store *cli.SessionStore
+			if store, err = openSessionStore(); err != nil {
+				println("Failed to open session store:", err.Error())
+				return
+			}
+			save = store.Save
+			loaded, err = store.Load(sessionName)
+		case chatHistory != "":
+			save = func(sess *cli.Session) error { return cli.SaveSessionFile(chatHistory, sess) }
+			loaded, err = cli.LoadSessionFile(chatHistory)
+		}
+		switch {
+		case save == nil:
+		case err == nil:
+			sess = loaded
+			println("Resuming conversation with", len(sess.Messages), "message(s)")
+		case errors.Is(err, os.ErrNotExist):
+			println("Starting a new conversation")
+		default:
+			println("Failed to load session:", err.Error())
+			return
+		}
+
+		if len(sess.Messages) == 0 {
+			system := uniai.Message{Role: "system", Content: systemPrompt}
+			if chatFile != "" {
+				if system, err = documentContext(cmd.Context(), chatFile, chatPages, systemPrompt); err != nil {
+					println("Failed to load document:", err.Error())
+					return
+				}
+				sess.Document = chatFile
+			}
+			if system.Content != "" {
+				sess.Messages = append(sess.Messages, system)
+			}
+		} else if chatFile != "" && chatFile != sess.Document {
+			println("The conversation is already about", cmp.Or(sess.Document, "no document")+", ignoring --file")
+		}
+
+		var transcript *cli.Transcript
+		if transcriptFile != "" {
+			transcript, err = cli.OpenTranscript(transcriptFile, transcriptFormat)
+			if err != nil {
+				println("Failed to open transcript:", err.Error())
+				return
+			}
+			defer transcript.Close()
+		}
+
+		ctx := cmd.Context()
+		// reply sends the history, which ends with a user message, with model
+		// and options, and appends the reply.
+		reply := func(model string, options map[string]any) bool {
+			var (
+				reply   strings.Builder
+				metrics uniai.Metrics
+			)
+			var console io.Writer = os.Stdout
+			md := cli.NewMarkdownWriter(os.Stdout)
+			if renderMarkdown {
+				console = md
+			}
+			started := time.Now()
+			err := client.Chat(ctx, &uniai.ChatRequest{
+				Model:    model,
+				Messages: sess.Messages,
+				Options:  options,
+			}, func(resp uniai.ChatResponse) error {
+				reply.WriteString(resp.Message.Content)
+				fmt.Fprint(console, resp.Message.Content)
+				if resp.Done {
+					metrics = resp.Metrics
+				}
+				return nil
+			})
+			if renderMarkdown {
+				md.Flush()
+			} else {
+				fmt.Println()
+			}
+			if err != nil {
+				println("Failed to chat:", err.Error())
+				return false
+			}
+			sess.Model = model
+			sess.Messages = append(sess.Messages, uniai.Message{Role: "assistant", Content: reply.String()})
+
+			if transcript != nil {
+				err := transcript.Add(cli.Turn{
+					Time:             started.UTC(),
+					Model:            model,
+					User:             sess.Messages[len(sess.Messages)-2].Content,
+					Assistant:        reply.String(),
+					PromptTokens:     metrics.PromptEvalCount,
+					CompletionTokens: metrics.EvalCount,
+					Duration:         time.Since(started),
+				})
+				if err != nil {
+					println("Failed to write transcript:", err.Error())
+				}
+			}
+
+			if save != nil {
+				if err := save(sess); err != nil {
+					println("Failed to save session:", err.Error())
+				}
+			}
+			return true
+		}
+
+		send := func(text string) bool {
+			sess.Messages = append(sess.Messages, uniai.Message{Role: "user", Content: text})
+			if !reply(sess.Model, uniai.DefaultOptions) {
+				// Drop the unanswered message so the history stays consistent.
+				sess.Messages = sess.Messages[:len(sess.Messages)-1]
+				return false
+			}
+			return true
+		}
+
+		if chatMessage != "" {
+			send(chatMessage)
+			return
+		}
+
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for {
+			fmt.Print(">>> ")
+			if !scanner.Scan() {
+				fmt.Println()
+				return
+			}
+			line := strings.TrimSpace(scanner.Text())
+			command, rest, _ := strings.Cut(line, " ")
+			switch command {
+			case "":
+				continue
+			case "/exit", "/quit":
+				return
+			case "/history":
+				for i, text := range sess.UserMessages() {
+					fmt.Printf("%d: %s\n", i+1, excerpt(text, 70))
+				}
+				continue
+			case "/retry":
+				// The history is restored if the new reply fails.
+				previous := sess.Messages
+				model, options, err := retryArgs(sess.Model, strings.Fields(rest))
+				if err == nil {
+					err = sess.DropReply()
+				}
+				if err != nil {
+					println(err.Error())
+					continue
+				}
+				if !reply(model, options) {
+					sess.Messages = previous
+				}
+				continue
+			case "/edit":
+				turn, text, _ := strings.Cut(strings.TrimSpace(rest), " ")
+				n, err := strconv.Atoi(turn)
+				if err != nil || strings.TrimSpace(text) == "" {
+					println("Usage: /edit <turn> <message>, see /history for the turns")
+					continue
+				}
+				previous := sess.Messages
+				if err := sess.EditTurn(n, strings.TrimSpace(text)); err != nil {
+					println(err.Error())
+					continue
+				}
+				if !reply(sess.Model, uniai.DefaultOptions) {
+					sess.Messages = previous
+				}
+				continue
+			}
+			send(line)
+		}
+	},
+}
+
+// documentContext returns the system message of a conversation about the
+// document at file: system, if any, followed by the text of the pages with a
+// text layer, with the other pages as images.
+func documentContext(ctx context.Context, file, pageRange, system string) (uniai.Message, error) {
+	pageNumbers, err := cli.ParsePageRange(pageRange)
+	if err != nil {
+		return uniai.Message{}, fmt.Errorf("invalid page range: %w", err)
+	}
+	pages, err := loadInputPages(ctx, file, pageNumbers, chatTextFirst)
+	if err != nil {
+		return uniai.Message{}, err
+	}
+	if len(pages) == 0 {
+		return uniai.Message{}, fmt.Errorf("no pages in %s", file)
+	}
+
+	var sb strings.Builder
+	if system != "" {
+		sb.WriteString(system + "\n\n")
+	}
+	fmt.Fprintf(&sb, "Answer the questions of the user about the document %s, whose pages follow.", filepath.Base(file))
+	msg := uniai.Message{Role: "system"}
+	for _, page := range pages {
+		if page.image != nil {
+			fmt.Fprintf(&sb, "\n\nPage %d is attached as image %d.", page.num, len(msg.Images)+1)
+			msg.Images = append(msg.Images, page.image)
+			continue
+		}
+		fmt.Fprintf(&sb, "\n\nPage %d:\n%s", page.num, strings.TrimSpace(page.text))
+	}
+	msg.Content = sb.String()
+	logger.Info("document loaded", "file", file, "pages", len(pages), "images", len(msg.Images))
+
+	return msg, nil
+}
+
+// excerpt returns the first line of text, cut to n characters.
+func excerpt(text string, n int) string {
+	line, _, more := strings.Cut(strings.TrimSpace(text), "\n")
+	if runes := []rune(line); len(runes) > n {
+		line, more = string(runes[:n]), true
+	}
+	if more {
+		line += "…"
+	}
+
+	return line
+}
+
+// retryArgs parses the arguments of /retry: an optional model, then model
+// options as key=value.
+func retryArgs(model string, args []string) (string, map[string]any, error) {
+	if len(args) > 0 && !strings.Contains(args[0], "=") {
+		model, args = args[0], args[1:]
+	}
+	if len(args) == 0 {
+		return model, uniai.DefaultOptions, nil
+	}
+
+	options, err := cli.ParseOptions(args)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return model, cli.MergeOptions(uniai.DefaultOptions, options), nil
+}
+
+var chatListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the saved chat sessions",
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := openSessionStore()
+		if err != nil {
+			println("Failed to open session store:", err.Error())
+			return
+		}
+
+		sessions, err := store.List()
+		if err != nil {
+			println("Failed to list sessions:", err.Error())
+			return
+		}
+		for _, sess := range sessions {
+			fmt.Printf("%s\t%d message(s)\tupdated %s\n", sess.Name, len(sess.Messages), sess.UpdatedAt.Local().Format("2006-01-02 15:04"))
+		}
+	},
+}
+
+var chatExportCmd = &cobra.Command{
+	Use:   "export <session>",
+	Short: "Export a chat session as Markdown or JSON",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := openSessionStore()
+		if err != nil {
+			println("Failed to open session store:", err.Error())
+			return
+		}
+
+		sess, err := store.Load(args[0])
+		if err != nil {
+			println("Failed to load session:", err.Error())
+			return
+		}
+
+		var data []byte
+		switch exportFormat {
+		case "markdown", "md":
+			data = []byte(sess.Markdown())
+		case "json":
+			data, err = json.MarshalIndent(sess, "", "  ")
+			if err != nil {
+				println("Failed to encode session:", err.Error())
+				return
+			}
+			data = append(data, '\n')
+		default:
+			println("Invalid export format:", exportFormat)
+			return
+		}
+
+		if exportOutput == "" {
+			os.Stdout.Write(data)
+			return
+		}
+		if err := os.WriteFile(exportOutput, data, 0644); err != nil {
+			println("Failed to write export:", err.Error())
+			return
+		}
+		println("Session exported to", exportOutput)
+	},
+}
+
+var chatForkCmd = &cobra.Command{
+	Use:   "fork <session> <branch>",
+	Short: "Fork a chat session into a new branch",
+	Long: `Fork copies the history of a session, up to --at messages, into a new session
+named branch, e.g. to try two extraction strategies from the same context.
+Continue the branch with 'uniai chat --session <branch>'.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := openSessionStore()
+		if err != nil {
+			println("Failed to open session store:", err.Error())
+			return
+		}
+
+		sess, err := store.Load(args[0])
+		if err != nil {
+			println("Failed to load session:", err.Error())
+			return
+		}
+		if _, err := store.Load(args[1]); err == nil {
+			println("Session", args[1], "already exists")
+			return
+		}
+
+		at := forkAt
+		if at < 0 {
+			at = len(sess.Messages)
+		}
+		branch, err := sess.Fork(args[1], at)
+		if err != nil {
+			println("Failed to fork session:", err.Error())
+			return
+		}
+		if err := store.Save(branch); err != nil {
+			println("Failed to save session:", err.Error())
+			return
+		}
+		println("Forked session", sess.Name, "after message", at, "into", branch.Name)
+	},
+}
+
+var chatBranchesCmd = &cobra.Command{
+	Use:   "branches <session>",
+	Short: "List the branches forked from a chat session",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := openSessionStore()
+		if err != nil {
+			println("Failed to open session store:", err.Error())
+			return
+		}
+
+		branches, err := store.Branches(args[0])
+		if err != nil {
+			println("Failed to list branches:", err.Error())
+			return
+		}
+		for _, sess := range branches {
+			fmt.Printf("%s\tforked after message %d\t%d message(s)\tupdated %s\n", sess.Name, sess.ForkedAt, len(sess.Messages), sess.UpdatedAt.Local().Format("2006-01-02 15:04"))
+		}
+	},
+}
+
+var chatDiffCmd = &cobra.Command{
+	Use:   "diff <session1> <session2>",
+	Short: "Compare two chat sessions, such as two branches",
+	Long: `Diff prints the number of messages two sessions share, then a unified diff of
+their transcripts from the first message that differs.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := openSessionStore()
+		if err != nil {
+			println("Failed to open session store:", err.Error())
+			return
+		}
+
+		a, err := store.Load(args[0])
+		if err != nil {
+			println("Failed to load session:", err.Error())
+			return
+		}
+		b, err := store.Load(args[1])
+		if err != nil {
+			println("Failed to load session:", err.Error())
+			return
+		}
+
+		common := cli.CommonHistory(a, b)
+		fmt.Printf("Common history: %d message(s)\n", common)
+		if common == len(a.Messages) && common == len(b.Messages) {
+			fmt.Println("The sessions are identical")
+			return
+		}
+		fmt.Print(cli.UnifiedDiff(a.Name, b.Name, cli.MessagesMarkdown(a.Messages[common:]), cli.MessagesMarkdown(b.Messages[common:]), chatDiffCtx))
+	},
+}
+
+var chatDeleteCmd = &cobra.Command{
+	Use:   "delete <session>...",
+	Short: "Delete chat sessions",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := openSessionStore()
+		if err != nil {
+			println("Failed to open session store:", err.Error())
+			return
+		}
+
+		for _, name := range args {
+			if err := store.Delete(name); err != nil {
+				println("Failed to delete session:", err.Error())
+				continue
+			}
+			println("Deleted session", name)
+		}
+	},
+}
+
+// openSessionStore opens --session-dir or the default session directory.
+func openSessionStore() (*cli.SessionStore, error) {
+	dir := sessionDir
+	if dir == "" {
+		var err error
+		dir, err = cli.DefaultSessionDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cli.NewSessionStore(dir)
+}
+
+func init() {
+	chatCmd.PersistentFlags().StringVar(&sessionDir, "session-dir", "", "Directory of saved chat sessions (defaults to the user config directory)")
+	chatCmd.Flags().StringVarP(&sessionName, "session", "s", "", "Name of the session to resume or create; its history is saved after every reply")
+	chatCmd.Flags().StringVarP(&chatMessage, "message", "m", "", "Send a single message and exit instead of starting an interactive chat")
+	chatCmd.Flags().StringVar(&systemPrompt, "system", "", "System prompt of a new session")
+	chatCmd.Flags().StringVarP(&chatFile, "file", "f", "", "Document (PDF, image or text) the conversation is about, given as context")
+	chatCmd.Flags().StringVar(&chatPages, "pages", "", "Page range of the document (e.g. 1-5), all pages if empty")
+	chatCmd.Flags().BoolVar(&chatTextFirst, "text-first", true, "Give the pages of the document with a text layer as text instead of images")
+	chatCmd.Flags().StringVar(&chatHistory, "history", "", "History file the conversation is saved to after every reply, and resumed from if it exists")
+	chatCmd.Flags().StringVar(&transcriptFile, "transcript", "", "Append every finalized turn, with its token counts, to this transcript file")
+	chatCmd.Flags().StringVar(&transcriptFormat, "transcript-format", "", "Transcript format: 'markdown' or 'jsonl' (defaults to jsonl for .jsonl and .json files, markdown otherwise)")
+
+	chatExportCmd.Flags().StringVar(&exportFormat, "format", "markdown", "Export format: 'markdown' or 'json'")
+	chatExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "File to write the export to (defaults to standard output)")
+
+	chatForkCmd.Flags().IntVar(&forkAt, "at", -1, "Number of messages of the session the branch starts from (all of them by default)")
+	chatDiffCmd.Flags().IntVar(&chatDiffCtx, "context", 3, "Number of unchanged lines shown around changes")
+
+	chatCmd.AddCommand(chatListCmd, chatExportCmd, chatForkCmd, chatBranchesCmd, chatDiffCmd, chatDeleteCmd)
+	uniaiCmd.AddCommand(chatCmd)
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	classifyFile      string   // Document to classify
+	classifyPages     string   // Page range to classify
+	classifyLabels    []string // Labels as name or name=description
+	classifyLabelFile string   // YAML file of labels, examples and threshold
+	classifyThreshold float64  // Confidence below which the classifier abstains
+	classifyDocument  bool     // Flag to classify the pages together instead of one by one
+	classifyJson      bool     // Flag to print the results as JSON lines
+)
+
+var classifyCmd = &cobra.Command{
+	Use:   "classify",
+	Short: "Classify document pages into a set of labels",
+	Long: `Classify assigns one of the given labels to every page of a document, or to the
+whole document with --document, with a confidence and a reason. Results with a
+confidence below --threshold are reported as abstained. Labels are given with
+--label or in a YAML file with --labels, which may also hold few-shot examples:
+
+  threshold: 0.6
+  labels:
+    - name: invoice
+      description: a bill requesting payment
+    - name: receipt
+  examples:
+    - text: "Thank you for your purchase ..."
+      label: receipt`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if classifyFile == "" {
+			cmd.Help()
+			return
+		}
+
+		client, err := newClient(cmd, classifyFile)
+		if err != nil {
+			println("Failed to initialize UniAI client:", err.Error())
+			return
+		}
+
+		classifier := &uniai.Classifier{
+			Client:    client,
+			Labels:    cli.ParseLabels(classifyLabels),
+			Threshold: classifyThreshold,
+		}
+		if classifyLabelFile != "" {
+			set, err := cli.LoadLabelSet(classifyLabelFile)
+			if err != nil {
+				println("Failed to load labels:", err.Error())
+				return
+			}
+			classifier.Labels = append(classifier.Labels, set.Labels...)
+			classifier.Examples = set.Examples
+			if set.Threshold != 0 && !cmd.Flags().Changed("threshold") {
+				classifier.Threshold = set.Threshold
+			}
+		}
+		if len(classifier.Labels) == 0 {
+			println("No labels given, use --label or --labels")
+			return
+		}
+
+		pageNumbers, err := cli.ParsePageRange(classifyPages)
+		if err != nil {
+			println("Invalid page range:", err.Error())
+			return
+		}
+
+		ctx := cmd.Context()
+		pages, err := loadInputPages(ctx, classifyFile, pageNumbers, true)
+		if err != nil {
+			println("Failed to load document:", err.Error())
+			return
+		}
+
+		if classifyDocument {
+			var in uniai.ClassifyInput
+			var texts []string
+			for _, page := range pages {
+				if page.image != nil {
+					in.Images = append(in.Images, page.image)
+				} else {
+					texts = append(texts, page.text)
+				}
+			}
+			in.Text = strings.Join(texts, "\n\n")
+
+			result, err := classifier.Classify(ctx, in)
+			if err != nil {
+				println("Failed to classify document:", err.Error())
+				return
+			}
+			printClassification("Document", 0, result)
+			return
+		}
+
+		for _, page := range pages {
+			in := uniai.ClassifyInput{Text: page.text}
+			if page.image != nil {
+				in.Images = []uniai.ImageData{page.image}
+			}
+
+			result, err := classifier.Classify(ctx, in)
+			if err != nil {
+				println("Failed to classify page", page.num, ":", err.Error())
+				continue
+			}
+			printClassification(fmt.Sprintf("Page %d", page.num), page.num, result)
+		}
+	},
+}
+
+// printClassification prints a result as text or, with --json, as a JSON line.
+func printClassification(subject string, pageNum int, result *uniai.Classification) {
+	if classifyJson {
+		data, _ := json.Marshal(struct {
+			Page int `json:"page,omitempty"`
+			*uniai.Classification
+		}{pageNum, result})
+		fmt.Println(string(data))
+		return
+	}
+
+	if result.Abstained {
+		fmt.Printf("%s: abstained (best guess %s, confidence %.2f)\n", subject, result.Label, result.Confidence)
+		return
+	}
+	fmt.Printf("%s: %s (confidence %.2f)", subject, result.Label, result.Confidence)
+	if result.Reason != "" {
+		fmt.Printf(" - %s", result.Reason)
+	}
+	fmt.Println()
+}
+
+func init() {
+	classifyCmd.Flags().StringVarP(&classifyFile, "file", "f", "", "Path or URL of the document to classify")
+	classifyCmd.Flags().StringVarP(&classifyPages, "pages", "r", "", "Page range to classify (all pages by default)")
+	classifyCmd.Flags().StringArrayVarP(&classifyLabels, "label", "l", nil, "Label as 'name' or 'name=description' (repeatable)")
+	classifyCmd.Flags().StringVar(&classifyLabelFile, "labels", "", "YAML file of labels, few-shot examples and threshold")
+	classifyCmd.Flags().Float64Var(&classifyThreshold, "threshold", uniai.DefaultAbstainThreshold, "Confidence (0-1) below which the classifier abstains")
+	classifyCmd.Flags().BoolVar(&classifyDocument, "document", false, "Classify the selected pages together as one document")
+	classifyCmd.Flags().BoolVar(&classifyJson, "json", false, "Print the results as JSON lines")
+
+	uniaiCmd.AddCommand(classifyCmd)
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sampila/uniai-client/internal/cli"
+)
+
+var copyResponse bool // Flag to indicate if the combined response should be copied to the clipboard
+
+// copyToClipboard places the responses of the run, read back from the
+// checkpoint in page order, on the clipboard when --copy is set.
+func copyToClipboard(proc *pageProcessor) error {
+	if !copyResponse {
+		return nil
+	}
+
+	entries, err := proc.checkpoint.Entries(proc.run.StartedAt)
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if len(entries) == 0 {
+		logger.Warn("no response to copy to the clipboard")
+		return nil
+	}
+	responses := make([]string, len(entries))
+	for i, entry := range entries {
+		responses[i] = strings.TrimSpace(entry.Response)
+	}
+
+	if err := cli.CopyToClipboard(strings.Join(responses, "\n\n") + "\n"); err != nil {
+		return err
+	}
+	logger.Info("response copied to the clipboard", "requests", len(entries))
+
+	return nil
+}
+
+func init() {
+	uniaiCmd.Flags().BoolVar(&copyResponse, "copy", false, "Copy the combined response of the run, in page order, to the system clipboard once it finishes")
+}
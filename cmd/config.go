@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+)
+
+// apiConfig holds the UniAI API credentials and endpoint. Execute loads the
+// environment and .env layers before the flags are parsed, setupConfig the
+// configuration file and flags after.
+var apiConfig = cli.NewConfig()
+
+var (
+	configFile         string // Configuration, defaults to config.yaml in the user config directory
+	apiBaseURL         string // Base URL of the UniAI API
+	apiAuth            string // Basic auth of the UniAI API
+	apiSigningKey      string // HMAC key signing the requests
+	apiSignatureHeader string // Header of the request signature
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show the UniAI API credentials and endpoint in use, and where they come from",
+	Long: `Show the UniAI API credentials and endpoint in use, and where they come from.
+Each setting is taken from the first of:
+
+  1. the flags --base-url, --auth, --signing-key and --signature-header,
+  2. the environment variables UNIAI_BASE_URL, UNIAI_AUTH, UNIAI_SIGNING_KEY
+     and UNIAI_SIGNATURE_HEADER, or the legacy API_BASEURL, API_AUTH,
+     API_SIGNING_KEY and API_SIGNATURE_HEADER,
+  3. the configuration file of --config, config.yaml in the user config
+     directory by default, with the keys base_url, auth, signing_key and
+     signature_header,
+  4. the .env file of the working directory.
+
+A provider configured in providers.yaml overrides these settings.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, s := range cli.Settings {
+			value, source := apiConfig.Lookup(s.Key)
+			switch {
+			case source == "":
+				value, source = "-", "unset"
+			case s.Secret:
+				value = mask(value)
+			}
+			fmt.Printf("%-17s %-7s %s\n", s.Key, source, value)
+		}
+	},
+}
+
+// setupConfig completes the configuration with the configuration file and
+// the flags.
+func setupConfig(cmd *cobra.Command) error {
+	path := configFile
+	if path == "" {
+		var err error
+		if path, err = cli.DefaultConfigPath(); err != nil {
+			return err
+		}
+	}
+	if err := apiConfig.LoadFile(path); err != nil {
+		return err
+	}
+
+	apiConfig.Set(cli.SourceFlag, cli.SettingBaseURL, apiBaseURL)
+	apiConfig.Set(cli.SourceFlag, cli.SettingAuth, apiAuth)
+	apiConfig.Set(cli.SourceFlag, cli.SettingSigningKey, apiSigningKey)
+	apiConfig.Set(cli.SourceFlag, cli.SettingSignatureHeader, apiSignatureHeader)
+
+	return nil
+}
+
+// mask hides all but the first characters of a secret.
+func mask(secret string) string {
+	if len(secret) <= 4 {
+		return strings.Repeat("*", len(secret))
+	}
+
+	return secret[:4] + strings.Repeat("*", len(secret)-4)
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Configuration file of the UniAI API credentials and endpoint (defaults to config.yaml in the user config directory)")
+	rootCmd.PersistentFlags().StringVar(&apiBaseURL, "base-url", "", "Base URL of the UniAI API (defaults to $UNIAI_BASE_URL, $API_BASEURL, the config file, then .env)")
+	rootCmd.PersistentFlags().StringVar(&apiAuth, "auth", "", "Basic auth of the UniAI API as user:password (defaults to $UNIAI_AUTH, $API_AUTH, the config file, then .env)")
+	rootCmd.PersistentFlags().StringVar(&apiSigningKey, "signing-key", "", "HMAC key signing every request, for API gateways requiring it (defaults to $UNIAI_SIGNING_KEY, $API_SIGNING_KEY, the config file, then .env)")
+	rootCmd.PersistentFlags().StringVar(&apiSignatureHeader, "signature-header", "", "Header of the request signature (defaults to $UNIAI_SIGNATURE_HEADER, $API_SIGNATURE_HEADER, the config file, then .env)")
+
+	noPDF(configCmd)
+	uniaiCmd.AddCommand(configCmd)
+}
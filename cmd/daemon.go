@@ -0,0 +1,420 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	daemonAddr      string        // Address the REST API listens on
+	daemonRoot      string        // Directory local documents are read from
+	daemonWorkers   int           // Number of jobs processed at a time
+	daemonJobDir    string        // Directory the jobs are persisted in
+	daemonRetention time.Duration // Age after which finished jobs are removed
+	daemonChatRate  int           // Turns per minute allowed to every chat session
+	daemonSlots     int           // Requests sent to the API at a time, chat replies first
+)
+
+// pruneInterval is how often finished jobs past the retention are removed.
+const pruneInterval = 10 * time.Minute
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run a document processing service with a REST job API",
+	Long: `Daemon accepts document jobs over a REST API, persists them and processes them
+with a pool of --workers. Jobs left unfinished by a previous run are processed
+again on start, and finished jobs are removed after --retention.
+
+  POST   /jobs              submit a job: {"file": "invoices/acme.pdf",
+                            "prompt": "Extract the total", "pages": "1-3",
+                            "text_first": true, "model": "...", "system": "..."}
+                            (or base64 "data" with its "name" to upload it)
+  GET    /jobs              list the jobs, most recent first
+  GET    /jobs/{id}         status and page results of a job
+  GET    /jobs/{id}/events  progress events of a job as NDJSON, until it is
+                            finished (see 'uniai serve')
+  DELETE /jobs/{id}         remove a finished job
+  POST   /sessions/{name}   send a message to a chat session, created if
+                            needed: {"content": "...", "model": "...",
+                            "system": "...", "options": {...}}; the reply is
+                            streamed as NDJSON {"content": "..."} chunks,
+                            then {"done": true}
+  POST   /sessions/{name}/fork
+                            fork a chat session into a new branch:
+                            {"branch": "...", "at": 4} keeps its first 4
+                            messages, all of them without "at"
+  POST   /sessions/{name}/retry
+                            regenerate the last reply, with the "model" and
+                            "options" of the body if any, streamed the same
+  POST   /sessions/{name}/edit/{n}
+                            replace the n-th user message with "content",
+                            drop the messages after it and stream the reply
+  GET    /sessions          names of the open chat sessions
+  GET    /sessions/{name}   history of a chat session
+  DELETE /sessions/{name}   delete a chat session
+  POST   /schedules         add a schedule submitting a job per file of a
+                            directory below --root at the times of a cron
+                            expression: {"name": "nightly", "cron": "0 2 * * *",
+                            "dir": "inbox", "pattern": "*.pdf", "task": "ocr",
+                            "new_only": true, "job": {"prompt": "..."}}
+  GET    /schedules         list the schedules and their next run
+  GET    /schedules/{name}  a schedule and the history of its runs
+  POST   /schedules/{name}/run
+                            run a schedule now
+  DELETE /schedules/{name}  remove a schedule, keeping its jobs
+  GET    /healthz, /readyz  liveness, and readiness (the AI backend answers)
+  GET    /metrics           Prometheus metrics: jobs by status, finished
+                            jobs and stage latencies
+
+Files are paths below --root or https://, s3:// and gs:// URLs. Chat sessions
+run concurrently, each limited to --chat-rate messages per minute, and are
+saved in --session-dir like those of 'uniai chat'. At most --slots requests
+are sent to the API at a time; waiting chat replies are sent before the pages
+of jobs.
+
+Schedules are kept in the job directory and run in local time. A run is
+skipped while jobs of the previous run of its schedule are unfinished.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		root, err := filepath.Abs(daemonRoot)
+		if err != nil {
+			println("Invalid root directory:", err.Error())
+			return
+		}
+
+		dir := daemonJobDir
+		if dir == "" {
+			dir, err = cli.DefaultJobDir()
+			if err != nil {
+				println("Failed to locate job directory:", err.Error())
+				return
+			}
+		}
+		store, err := cli.NewJobStore(dir)
+		if err != nil {
+			println("Failed to open job store:", err.Error())
+			return
+		}
+
+		jobs, err := cli.NewJobPool(func(ctx context.Context, spec cli.JobSpec, emit func(cli.Event) error) error {
+			return runJob(uniai.WithPriority(ctx, uniai.PriorityBatch), cmd, root, spec, emit)
+		}, daemonWorkers, store)
+		if err != nil {
+			println("Failed to load jobs:", err.Error())
+			return
+		}
+
+		scheduler, err := cli.NewScheduler(filepath.Join(dir, cli.SchedulesFile), jobs, scheduleJobs(root))
+		if err != nil {
+			println("Failed to load schedules:", err.Error())
+			return
+		}
+		go scheduler.Run(context.Background())
+
+		if daemonRetention > 0 {
+			go func() {
+				for ; ; time.Sleep(pruneInterval) {
+					n, err := jobs.Prune(time.Now().Add(-daemonRetention))
+					if err != nil {
+						println("Failed to remove expired jobs:", err.Error())
+					}
+					if n > 0 {
+						println("Removed", n, "expired job(s)")
+					}
+				}
+			}()
+		}
+
+		if daemonSlots > 0 {
+			requestQueue = uniai.NewRequestQueue(daemonSlots)
+		}
+		client, err := newClient(cmd, "")
+		if err != nil {
+			println("Failed to initialize UniAI client:", err.Error())
+			return
+		}
+		sessionStore, err := openSessionStore()
+		if err != nil {
+			println("Failed to open session store:", err.Error())
+			return
+		}
+
+		mux := jobsHandler(jobs)
+		handleSessions(mux, cli.NewSessionManager(client, sessionStore, daemonChatRate))
+		handleSchedules(mux, scheduler)
+		handleHealth(mux, cmd, jobs)
+
+		println("Listening on", daemonAddr)
+		if err := http.ListenAndServe(daemonAddr, mux); err != nil {
+			println("Failed to serve:", err.Error())
+		}
+	},
+}
+
+// jobsHandler serves the REST API of jobs.
+func jobsHandler(jobs *cli.Jobs) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /jobs", func(w http.ResponseWriter, r *http.Request) {
+		var spec cli.JobSpec
+		if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid job: "+err.Error())
+			return
+		}
+		if spec.Prompt == "" {
+			writeError(w, http.StatusBadRequest, "the job has no prompt")
+			return
+		}
+		if spec.File == "" && len(spec.Data) == 0 {
+			writeError(w, http.StatusBadRequest, "the job has no file")
+			return
+		}
+
+		job := jobs.Submit(spec)
+		w.Header().Set("Location", "/jobs/"+job.State().ID)
+		writeJSON(w, http.StatusAccepted, job.State())
+	})
+
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		states := jobs.List()
+		for i := range states {
+			states[i].Results = nil
+		}
+		writeJSON(w, http.StatusOK, states)
+	})
+
+	mux.HandleFunc("GET /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		job, ok := jobs.Get(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusNotFound, cli.ErrJobNotFound.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, job.State())
+	})
+
+	mux.HandleFunc("GET /jobs/{id}/events", func(w http.ResponseWriter, r *http.Request) {
+		job, ok := jobs.Get(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusNotFound, cli.ErrJobNotFound.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		flusher, _ := w.(http.Flusher)
+		job.Follow(r.Context(), func(event cli.Event) error {
+			if err := enc.Encode(event); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return nil
+		})
+	})
+
+	mux.HandleFunc("DELETE /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		err := jobs.Delete(r.PathValue("id"))
+		switch {
+		case errors.Is(err, cli.ErrJobNotFound):
+			writeError(w, http.StatusNotFound, err.Error())
+		case err != nil:
+			writeError(w, http.StatusConflict, err.Error())
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	return mux
+}
+
+// handleSessions serves the REST API of chat sessions.
+func handleSessions(mux *http.ServeMux, sessions *cli.SessionManager) {
+	mux.HandleFunc("POST /sessions/{name}", func(w http.ResponseWriter, r *http.Request) {
+		var turn cli.SessionTurn
+		if err := json.NewDecoder(r.Body).Decode(&turn); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid message: "+err.Error())
+			return
+		}
+		if turn.Content == "" && len(turn.Images) == 0 {
+			writeError(w, http.StatusBadRequest, "the message is empty")
+			return
+		}
+
+		streamReply(w, func(fn func(string)) error {
+			_, err := sessions.Send(interactive(r), r.PathValue("name"), turn, fn)
+			return err
+		})
+	})
+
+	mux.HandleFunc("POST /sessions/{name}/retry", func(w http.ResponseWriter, r *http.Request) {
+		var turn cli.SessionTurn
+		if err := json.NewDecoder(r.Body).Decode(&turn); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, "invalid retry: "+err.Error())
+			return
+		}
+
+		streamReply(w, func(fn func(string)) error {
+			_, err := sessions.Regenerate(interactive(r), r.PathValue("name"), turn, fn)
+			return err
+		})
+	})
+
+	mux.HandleFunc("POST /sessions/{name}/edit/{turn}", func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(r.PathValue("turn"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid turn: "+r.PathValue("turn"))
+			return
+		}
+		var turn cli.SessionTurn
+		if err := json.NewDecoder(r.Body).Decode(&turn); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid message: "+err.Error())
+			return
+		}
+		if turn.Content == "" {
+			writeError(w, http.StatusBadRequest, "the message is empty")
+			return
+		}
+
+		streamReply(w, func(fn func(string)) error {
+			_, err := sessions.Edit(interactive(r), r.PathValue("name"), n, turn, fn)
+			return err
+		})
+	})
+
+	mux.HandleFunc("POST /sessions/{name}/fork", func(w http.ResponseWriter, r *http.Request) {
+		var fork struct {
+			Branch string `json:"branch"`
+			At     *int   `json:"at"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&fork); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid fork: "+err.Error())
+			return
+		}
+
+		name := r.PathValue("name")
+		at := -1
+		if fork.At != nil {
+			at = *fork.At
+		} else if sess, err := sessions.Session(r.Context(), name); err == nil {
+			at = len(sess.Messages)
+		}
+		sess, err := sessions.Fork(r.Context(), name, fork.Branch, at)
+		switch {
+		case errors.Is(err, cli.ErrSessionNotFound):
+			writeError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, cli.ErrSessionExists):
+			writeError(w, http.StatusConflict, err.Error())
+		case err != nil:
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			w.Header().Set("Location", "/sessions/"+sess.Name)
+			writeJSON(w, http.StatusCreated, sess)
+		}
+	})
+
+	mux.HandleFunc("GET /sessions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, sessions.Sessions())
+	})
+
+	mux.HandleFunc("GET /sessions/{name}", func(w http.ResponseWriter, r *http.Request) {
+		sess, err := sessions.Session(r.Context(), r.PathValue("name"))
+		switch {
+		case errors.Is(err, cli.ErrSessionNotFound):
+			writeError(w, http.StatusNotFound, err.Error())
+		case err != nil:
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeJSON(w, http.StatusOK, sess)
+		}
+	})
+
+	mux.HandleFunc("DELETE /sessions/{name}", func(w http.ResponseWriter, r *http.Request) {
+		err := sessions.Delete(r.PathValue("name"))
+		switch {
+		case errors.Is(err, cli.ErrSessionNotFound):
+			writeError(w, http.StatusNotFound, err.Error())
+		case err != nil:
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+// streamReply streams the chunks of the reply sent by send as NDJSON
+// {"content": "..."} lines, then {"done": true}. The headers are sent with
+// interactive returns the context of r, in which the requests to the API go
+// before those of jobs.
+func interactive(r *http.Request) context.Context {
+	return uniai.WithPriority(r.Context(), uniai.PriorityInteractive)
+}
+
+// the first chunk, so that errors before it get their status.
+func streamReply(w http.ResponseWriter, send func(fn func(chunk string)) error) {
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	streaming := false
+	err := send(func(chunk string) {
+		if !streaming {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			streaming = true
+		}
+		enc.Encode(map[string]string{"content": chunk})
+		if flusher != nil {
+			flusher.Flush()
+		}
+	})
+
+	var limited *cli.SessionRateError
+	switch {
+	case err == nil && streaming:
+		enc.Encode(map[string]bool{"done": true})
+	case err == nil:
+		writeJSON(w, http.StatusOK, map[string]bool{"done": true})
+	case streaming:
+		enc.Encode(map[string]string{"error": err.Error()})
+	case errors.As(err, &limited):
+		w.Header().Set("Retry-After", strconv.Itoa(int(limited.RetryAfter.Seconds())+1))
+		writeError(w, http.StatusTooManyRequests, err.Error())
+	case errors.Is(err, cli.ErrSessionNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, cli.ErrInvalidTurn):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusBadGateway, err.Error())
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func init() {
+	daemonCmd.Flags().StringVar(&daemonAddr, "addr", "127.0.0.1:8090", "Address the REST API listens on")
+	daemonCmd.Flags().StringVar(&daemonRoot, "root", ".", "Directory the files of jobs are read from")
+	daemonCmd.Flags().IntVar(&daemonWorkers, "workers", 2, "Number of jobs processed at a time")
+	daemonCmd.Flags().StringVar(&daemonJobDir, "job-dir", "", "Directory the jobs are persisted in (defaults to the user config directory)")
+	daemonCmd.Flags().DurationVar(&daemonRetention, "retention", 7*24*time.Hour, "Age after which finished jobs are removed (0 keeps them)")
+	daemonCmd.Flags().IntVar(&daemonSlots, "slots", 4, "Requests sent to the API at a time across jobs and chat sessions, chat replies first (0 for no limit)")
+	daemonCmd.Flags().IntVar(&daemonChatRate, "chat-rate", 20, "Messages per minute allowed to every chat session (0 for no limit)")
+	daemonCmd.Flags().StringVar(&sessionDir, "session-dir", "", "Directory of saved chat sessions (defaults to the user config directory)")
+
+	uniaiCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+)
+
+var (
+	writeDataset bool // Flag to indicate if the requests and responses should be written as a fine-tuning dataset
+
+	datasetOutput      string // Directory of the curated dataset
+	datasetReview      string // Review file holding the decision about every example
+	datasetInteractive bool   // Flag to indicate if pending examples should be reviewed in the terminal
+)
+
+var datasetCmd = &cobra.Command{
+	Use:   "dataset",
+	Short: "Curate the examples written by --dataset into training data",
+}
+
+var datasetBuildCmd = &cobra.Command{
+	Use:   "build <run output>...",
+	Short: "Build a curated dataset from the examples of past runs",
+	Long: `Build walks the given run outputs for the ` + cli.DatasetFile + ` files written by
+'uniai --dataset' and writes the accepted examples to ` + cli.CuratedFile + ` in the
+output directory, with their sources and image checksums in ` + cli.CuratedManifestFile + `.
+
+Examples are accepted, rejected or edited in a review file: new examples are
+added to it as pending, and its decisions and corrected responses are applied
+by every build. With --interactive, pending examples are reviewed in the
+terminal instead, and the decisions are saved to the review file if any.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		candidates, err := cli.FindCandidates(args)
+		if err != nil {
+			return fmt.Errorf("failed to read datasets: %w", err)
+		}
+		if len(candidates) == 0 {
+			return fmt.Errorf("no %s found in %s", cli.DatasetFile, strings.Join(args, ", "))
+		}
+
+		review := &cli.Review{}
+		if datasetReview != "" {
+			if review, err = cli.LoadReview(datasetReview); err != nil {
+				return err
+			}
+		}
+		if added := review.Merge(candidates); added > 0 {
+			logger.Info("new examples to review", "examples", added)
+		}
+		if datasetInteractive {
+			err = reviewExamples(candidates, review)
+		}
+		if datasetReview != "" {
+			if werr := review.Write(datasetReview); werr != nil {
+				return fmt.Errorf("failed to write review: %w", werr)
+			}
+			logger.Info("review written", "path", datasetReview)
+		}
+		if err != nil {
+			return err
+		}
+
+		manifest, err := cli.BuildCurated(candidates, review, datasetOutput)
+		if err != nil {
+			return fmt.Errorf("failed to build dataset: %w", err)
+		}
+		logger.Info("dataset written", "dir", datasetOutput, "accepted", manifest.Accepted, "rejected", manifest.Rejected, "pending", manifest.Pending)
+
+		return nil
+	},
+}
+
+// reviewExamples asks in the terminal for a decision about every pending
+// candidate, until the user quits.
+func reviewExamples(candidates []cli.Candidate, review *cli.Review) error {
+	input := bufio.NewScanner(os.Stdin)
+	for i, c := range candidates {
+		entry := review.Entry(c.ID)
+		if entry.Decision != cli.DecisionPending {
+			continue
+		}
+
+		fmt.Printf("\n--- Example %d of %d (%s)\n", i+1, len(candidates), c.Source)
+		fmt.Printf("Prompt:\n%s\n", strings.TrimSpace(entry.Prompt))
+		if len(entry.Images) > 0 {
+			fmt.Printf("Images: %s\n", strings.Join(entry.Images, ", "))
+		}
+		fmt.Printf("Response:\n%s\n", strings.TrimSpace(entry.Response))
+
+		for decided := false; !decided; {
+			fmt.Print("[a]ccept, [r]eject, [e]dit and accept, [s]kip, [q]uit: ")
+			if !input.Scan() {
+				return input.Err()
+			}
+			switch strings.ToLower(strings.TrimSpace(input.Text())) {
+			case "a":
+				entry.Decision, decided = cli.DecisionAccept, true
+			case "r":
+				entry.Decision, decided = cli.DecisionReject, true
+			case "e":
+				edited, err := editText(entry.Response)
+				if err != nil {
+					logger.Error("failed to edit response", "err", err)
+					continue
+				}
+				entry.Response, entry.Decision, decided = edited, cli.DecisionAccept, true
+			case "s":
+				decided = true
+			case "q":
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// editText opens text in $EDITOR, vi by default, and returns it once saved.
+func editText(text string) (string, error) {
+	f, err := os.CreateTemp("", "uniai-response-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// addExample writes a successful request about pages to the dataset of
+// --dataset, with references to the images of the pages if they were sent.
+func (p *pageProcessor) addExample(pages []int, system, prompt string, withImages bool, response string) {
+	if p.dataset == nil {
+		return
+	}
+
+	var images []string
+	if withImages {
+		for _, page := range pages {
+			images = append(images, p.manifest.Paths(cli.ArtifactPageImage, page)...)
+			images = append(images, p.manifest.Paths(cli.ArtifactEmbeddedImage, page)...)
+		}
+	}
+	if err := p.dataset.Add(cli.NewDatasetExample(system, prompt, images, response)); err != nil {
+		logger.Warn("failed to write dataset example", "pages", pages, "err", err)
+	}
+}
+
+func init() {
+	datasetBuildCmd.Flags().StringVarP(&datasetOutput, "output", "o", "dataset", "Directory the curated dataset is written to")
+	datasetBuildCmd.Flags().StringVar(&datasetReview, "review", "", "Review file (YAML) with the decision of every example: 'accept', 'reject' or 'pending', and its editable response")
+	datasetBuildCmd.Flags().BoolVarP(&datasetInteractive, "interactive", "i", false, "Review pending examples in the terminal")
+	datasetCmd.AddCommand(datasetBuildCmd)
+	noPDF(datasetCmd)
+	uniaiCmd.AddCommand(datasetCmd)
+
+	uniaiCmd.Flags().BoolVar(&writeDataset, "dataset", false, "Write every answered request (system prompt, prompt, page image references and response) to "+cli.DatasetFile+" in the chat layout of fine-tuning datasets")
+}
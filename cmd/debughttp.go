@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	debugHTTP      bool   // Flag to trace the API requests to a file
+	debugHTTPFile  string // Trace file of --debug-http
+	debugHTTPLimit int    // Bytes of the bodies written to the trace
+)
+
+// traceHTTP returns next tracing the requests to the trace file of the run,
+// masked by the redactor of the logs.
+func traceHTTP(next http.RoundTripper) (http.RoundTripper, error) {
+	path := debugHTTPFile
+	if path == "" {
+		path = "uniai-http-" + time.Now().Format("20060102-150405") + ".trace"
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("tracing the API requests", "path", path)
+
+	tracer := uniai.NewTracer(f, redactor, next)
+	tracer.SetBodyLimit(debugHTTPLimit)
+
+	return tracer, nil
+}
+
+func init() {
+	uniaiCmd.PersistentFlags().BoolVar(&debugHTTP, "debug-http", false, "Trace the API requests and responses (headers, timings and truncated bodies, with credentials masked and images elided) to a file, see --debug-http-file")
+	uniaiCmd.PersistentFlags().StringVar(&debugHTTPFile, "debug-http-file", "", "Trace file of --debug-http, uniai-http-<time>.trace in the working directory by default")
+	uniaiCmd.PersistentFlags().IntVar(&debugHTTPLimit, "debug-http-body", uniai.DefaultTraceBodyLimit, "Bytes of every body written to the trace of --debug-http")
+}
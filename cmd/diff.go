@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/eval"
+)
+
+var (
+	diffContext int  // Number of unchanged lines shown around changes
+	diffSummary bool // Flag to only print the status of every output
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <run1> <run2>",
+	Short: "Compare the outputs of two runs page by page",
+	Long: `Diff compares the responses of two runs of the same document, for instance with
+different models or prompts, and prints the settings that differ, a unified
+diff of every changed page and a summary.
+
+A run is an output directory holding a manifest.json, or the manifest file
+itself with the flat layout. Responses are only kept with --write-response
+(and --markdown for document.md).`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		a, err := loadRunOutputs(args[0])
+		if err != nil {
+			println("Failed to load run", args[0], ":", err.Error())
+			return
+		}
+		b, err := loadRunOutputs(args[1])
+		if err != nil {
+			println("Failed to load run", args[1], ":", err.Error())
+			return
+		}
+
+		fmt.Printf("A: %s\nB: %s\n", args[0], args[1])
+		if a.manifest.SourceSHA256 != b.manifest.SourceSHA256 {
+			fmt.Printf("Warning: the runs processed different documents (%s, %s)\n", a.manifest.Source, b.manifest.Source)
+		}
+		for _, d := range settingsDiff(a.info, b.info) {
+			fmt.Println(d)
+		}
+		fmt.Println()
+
+		var identical, changed, onlyA, onlyB int
+		for _, key := range mergeKeys(a.keys, b.keys) {
+			textA, inA := a.outputs[key]
+			textB, inB := b.outputs[key]
+			switch {
+			case !inB:
+				onlyA++
+				fmt.Printf("%s: only in A\n", key)
+			case !inA:
+				onlyB++
+				fmt.Printf("%s: only in B\n", key)
+			case textA == textB:
+				identical++
+				fmt.Printf("%s: identical\n", key)
+			default:
+				changed++
+				fmt.Printf("%s: changed (similarity %.2f)\n", key, eval.Similarity(textA, textB))
+				if !diffSummary {
+					fmt.Println(cli.UnifiedDiff("A/"+key, "B/"+key, textA, textB, diffContext))
+				}
+			}
+		}
+
+		fmt.Printf("\n%d identical, %d changed, %d only in A, %d only in B\n", identical, changed, onlyA, onlyB)
+	},
+}
+
+// runOutputs are the text outputs of a run, keyed by the pages they were
+// produced from.
+type runOutputs struct {
+	manifest *cli.Manifest
+	info     *cli.RunInfo // nil for runs without run metadata
+	outputs  map[string]string
+	keys     []string // in manifest order
+}
+
+// loadRunOutputs reads the manifest of the run at path, an output directory
+// or a manifest file, and the responses and Markdown it lists.
+func loadRunOutputs(path string) (*runOutputs, error) {
+	manifestPath := path
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		manifestPath = filepath.Join(path, cli.ManifestFile)
+	}
+	manifest, err := cli.LoadManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &runOutputs{manifest: manifest, outputs: make(map[string]string)}
+	runInfoPath := strings.TrimSuffix(manifestPath, cli.ManifestFile) + cli.RunInfoFile
+	run.info, err = cli.LoadRunInfo(runInfoPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	for _, artifact := range manifest.Artifacts {
+		var key string
+		switch artifact.Kind {
+		case cli.ArtifactResponse:
+			key = pagesLabel(artifact.Pages)
+		case cli.ArtifactMarkdown:
+			key = "document.md"
+		case cli.ArtifactJSONDocument:
+			key = "document.json"
+		default:
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(manifest.Dir(), filepath.FromSlash(artifact.Path)))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := run.outputs[key]; !ok {
+			run.keys = append(run.keys, key)
+		}
+		run.outputs[key] = stripMetrics(string(data))
+	}
+	if len(run.keys) == 0 {
+		return nil, errors.New("the run has no responses, run with --write-response to keep them")
+	}
+
+	return run, nil
+}
+
+// metricsPrefixes start the lines of the metrics summary that response files
+// end with, which differ on every run.
+var metricsPrefixes = []string{
+	"total duration:", "load duration:", "prompt eval count:", "prompt eval duration:",
+	"prompt eval rate:", "eval count:", "eval duration:", "eval rate:",
+}
+
+// stripMetrics returns a response file without its metrics summary.
+func stripMetrics(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for len(lines) > 0 && slices.ContainsFunc(metricsPrefixes, func(p string) bool {
+		return strings.HasPrefix(lines[len(lines)-1], p)
+	}) {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// pagesLabel names the output of a request by its source pages.
+func pagesLabel(pages []int) string {
+	if len(pages) == 1 {
+		return "page " + strconv.Itoa(pages[0])
+	}
+
+	labels := make([]string, len(pages))
+	for i, p := range pages {
+		labels[i] = strconv.Itoa(p)
+	}
+
+	return "pages " + strings.Join(labels, ",")
+}
+
+// mergeKeys returns the keys of a followed by those only in b.
+func mergeKeys(a, b []string) []string {
+	keys := slices.Clone(a)
+	for _, k := range b {
+		if !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+// settingsDiff lists the settings that differ between two runs.
+func settingsDiff(a, b *cli.RunInfo) []string {
+	if a == nil || b == nil {
+		return []string{"Run metadata missing, settings not compared"}
+	}
+
+	options := func(r *cli.RunInfo) string {
+		data, _ := json.Marshal(r.Options)
+		return string(data)
+	}
+	fields := []struct{ name, a, b string }{
+		{"model", a.Model, b.Model},
+		{"prompt", a.Prompt, b.Prompt},
+		{"system", a.System, b.System},
+		{"format", string(a.Format), string(b.Format)},
+		{"options", options(a), options(b)},
+		{"server version", a.ServerVersion, b.ServerVersion},
+		{"client version", a.ClientVersion, b.ClientVersion},
+	}
+
+	var diffs []string
+	for _, f := range fields {
+		if f.a != f.b {
+			diffs = append(diffs, fmt.Sprintf("%s: %q -> %q", f.name, f.a, f.b))
+		}
+	}
+
+	return diffs
+}
+
+func init() {
+	diffCmd.Flags().IntVarP(&diffContext, "context", "U", 3, "Number of unchanged lines shown around changes")
+	diffCmd.Flags().BoolVar(&diffSummary, "summary", false, "Only print whether every output is identical or changed")
+
+	uniaiCmd.AddCommand(diffCmd)
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	ensembleFile    string   // Document to extract from
+	ensemblePages   string   // Page range to send
+	ensemblePrompt  string   // Extraction prompt
+	ensembleSchema  string   // JSON schema file of the answer
+	ensembleModels  []string // Models queried
+	ensembleSamples int      // Answers per model
+	ensembleOutput  string   // File the result is written to
+
+	ensembleLogprobs      bool    // Flag to ask for token log probabilities
+	ensembleMinConfidence float64 // Confidence below which fields are reported for review
+)
+
+var ensembleCmd = &cobra.Command{
+	Use:   "ensemble",
+	Short: "Extract structured data with several models and merge their answers",
+	Long: `Ensemble sends the same extraction request to several models, or several times to
+a model with --samples, and merges the JSON answers field by field: every field
+takes the value most answers agree on. The result holds the consensus value,
+the agreement of every field, the individual answers and a confidence per
+field combining the agreement, the token probabilities (with --logprobs, if
+the model returns them) and the schema; fields the answers disagree on or with
+a confidence below --min-confidence are reported so they can be reviewed.
+
+  uniai ensemble -f invoice.pdf -m "Extract the invoice number and total" \
+    --schema invoice.schema.json --models uniai01:7b,uniai01:32b --samples 2
+
+The pages of the document are sent in a single request.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if ensembleFile == "" || ensemblePrompt == "" {
+			cmd.Help()
+			return
+		}
+
+		var schema map[string]any
+		if ensembleSchema != "" {
+			data, err := os.ReadFile(ensembleSchema)
+			if err != nil {
+				println("Failed to read schema:", err.Error())
+				return
+			}
+			if err := json.Unmarshal(data, &schema); err != nil {
+				println("Invalid schema:", err.Error())
+				return
+			}
+		}
+
+		client, err := newClient(cmd, ensembleFile)
+		if err != nil {
+			println("Failed to initialize UniAI client:", err.Error())
+			return
+		}
+
+		pageNumbers, err := cli.ParsePageRange(ensemblePages)
+		if err != nil {
+			println("Invalid page range:", err.Error())
+			return
+		}
+
+		ctx := cmd.Context()
+		pages, err := loadInputPages(ctx, ensembleFile, pageNumbers, true)
+		if err != nil {
+			println("Failed to load document:", err.Error())
+			return
+		}
+
+		req := &uniai.GenerateRequest{Prompt: ensemblePrompt, Options: uniai.DefaultOptions}
+		var texts []string
+		for _, page := range pages {
+			if page.image != nil {
+				req.Images = append(req.Images, page.image)
+			} else {
+				texts = append(texts, fmt.Sprintf("Page %d:\n%s", page.num, strings.TrimSpace(page.text)))
+			}
+		}
+		if len(texts) > 0 {
+			req.Prompt = cli.TextPrompt(req.Prompt, strings.Join(texts, "\n\n"))
+		}
+
+		ensemble := &uniai.Ensemble{
+			Client:   client,
+			Models:   ensembleModels,
+			Samples:  ensembleSamples,
+			Logprobs: ensembleLogprobs,
+		}
+		result, err := ensemble.Extract(ctx, req, schema)
+		if err != nil {
+			println("Failed to extract:", err.Error())
+			return
+		}
+
+		for _, a := range result.Answers {
+			if a.Error != "" {
+				println("Answer of", a.Source, "failed:", a.Error)
+			}
+		}
+		disagreements := result.Disagreements()
+		for _, f := range disagreements {
+			values := make([]string, len(f.Values))
+			for i, v := range f.Values {
+				data, _ := json.Marshal(v.Value)
+				values[i] = fmt.Sprintf("%s (%s)", data, strings.Join(v.Sources, ", "))
+			}
+			println("Disagreement on", f.Path+":", strings.Join(values, " vs "))
+		}
+		println(fmt.Sprintf("Agreement %.2f, %d of %d field(s) disputed", result.Agreement, len(disagreements), len(result.Fields)))
+		for _, f := range result.Confidence {
+			if f.Confidence >= ensembleMinConfidence {
+				continue
+			}
+			value, _ := json.Marshal(f.Value)
+			line := fmt.Sprintf("Low confidence %.2f on %s = %s", f.Confidence, f.Path, value)
+			if len(f.Violations) > 0 {
+				line += " (" + strings.Join(f.Violations, "; ") + ")"
+			}
+			println(line)
+		}
+
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			println("Failed to encode result:", err.Error())
+			return
+		}
+		if ensembleOutput == "" {
+			fmt.Println(string(data))
+			return
+		}
+		if err := os.WriteFile(ensembleOutput, append(data, '\n'), 0644); err != nil {
+			println("Failed to write result:", err.Error())
+			return
+		}
+		println("Result written to", ensembleOutput)
+	},
+}
+
+func init() {
+	ensembleCmd.Flags().StringVarP(&ensembleFile, "file", "f", "", "Path or URL of the document")
+	ensembleCmd.Flags().StringVarP(&ensemblePages, "pages", "r", "", "Page range to send (all pages by default)")
+	ensembleCmd.Flags().StringVarP(&ensemblePrompt, "prompt", "m", "", "Extraction prompt")
+	ensembleCmd.Flags().StringVar(&ensembleSchema, "schema", "", "JSON schema file the answers must match")
+	ensembleCmd.Flags().StringSliceVar(&ensembleModels, "models", []string{uniai.ModelDefault}, "Comma-separated models to query")
+	ensembleCmd.Flags().IntVar(&ensembleSamples, "samples", 1, "Number of answers per model, sampled with different seeds")
+	ensembleCmd.Flags().BoolVar(&ensembleLogprobs, "logprobs", false, "Ask for token log probabilities and use them in the field confidence")
+	ensembleCmd.Flags().Float64Var(&ensembleMinConfidence, "min-confidence", 0.8, "Report the fields with a lower confidence for review")
+	ensembleCmd.Flags().StringVarP(&ensembleOutput, "output", "o", "", "Write the result to this file instead of stdout")
+
+	uniaiCmd.AddCommand(ensembleCmd)
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	entitiesFile  string   // Document to extract entities from
+	entitiesPages string   // Page range to process
+	entityTypes   []string // Entity types to extract
+	entitiesJson  bool     // Flag to print the entities as JSON
+)
+
+var entitiesCmd = &cobra.Command{
+	Use:   "entities",
+	Short: "Extract named entities from a document",
+	Long: `Entities lists the people, organizations, dates and amounts (or the types given
+with --type) found on the pages of a document, with their normalized form and
+the pages they appear on. Entities found on several pages are listed once.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if entitiesFile == "" {
+			cmd.Help()
+			return
+		}
+
+		client, err := newClient(cmd, entitiesFile)
+		if err != nil {
+			println("Failed to initialize UniAI client:", err.Error())
+			return
+		}
+
+		pageNumbers, err := cli.ParsePageRange(entitiesPages)
+		if err != nil {
+			println("Invalid page range:", err.Error())
+			return
+		}
+
+		ctx := cmd.Context()
+		pages, err := loadInputPages(ctx, entitiesFile, pageNumbers, true)
+		if err != nil {
+			println("Failed to load document:", err.Error())
+			return
+		}
+
+		extractor := &uniai.EntityExtractor{Client: client, Types: entityTypes}
+		var all []uniai.Entity
+		for _, page := range pages {
+			entities, err := extractor.ExtractPage(ctx, page.pageInput())
+			if err != nil {
+				println("Failed to extract entities of page", page.num, ":", err.Error())
+				continue
+			}
+			println("Found", len(entities), "entities on page", page.num)
+			all = append(all, entities...)
+		}
+		all = uniai.MergeEntities(all)
+
+		if entitiesJson {
+			if all == nil {
+				all = []uniai.Entity{}
+			}
+			data, err := json.MarshalIndent(all, "", "  ")
+			if err != nil {
+				println("Failed to encode entities:", err.Error())
+				return
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TYPE\tTEXT\tNORMALIZED\tPAGES")
+		for _, e := range all {
+			pages := make([]string, len(e.Pages))
+			for i, p := range e.Pages {
+				pages[i] = strconv.Itoa(p)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Type, e.Text, e.Normalized, strings.Join(pages, ","))
+		}
+		tw.Flush()
+	},
+}
+
+func init() {
+	entitiesCmd.Flags().StringVarP(&entitiesFile, "file", "f", "", "Path or URL of the document")
+	entitiesCmd.Flags().StringVarP(&entitiesPages, "pages", "r", "", "Page range to process (all pages by default)")
+	entitiesCmd.Flags().StringArrayVar(&entityTypes, "type", nil, "Entity type to extract (repeatable; default person, organization, date and amount)")
+	entitiesCmd.Flags().BoolVar(&entitiesJson, "json", false, "Print the entities as JSON")
+
+	uniaiCmd.AddCommand(entitiesCmd)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// envPrefix prefixes the environment variables setting the flags.
+const envPrefix = "UNIAI_"
+
+// envAliases are the environment variables of flags named after what they
+// set rather than after the flag, in addition to the one of the flag name.
+var envAliases = map[string]string{
+	"output":  "UNIAI_OUTPUT_DIR",
+	"workers": "UNIAI_CONCURRENCY",
+}
+
+// settingFlags are the flags of the API settings, whose UNIAI_* environment
+// variables are read by apiConfig to rank them above the config file.
+var settingFlags = map[string]bool{
+	"base-url":         true,
+	"auth":             true,
+	"signing-key":      true,
+	"signature-header": true,
+}
+
+// bindEnv sets every flag of cmd not given on the command line from its
+// environment variable, UNIAI_ followed by the flag name upper-cased with
+// dashes as underscores (e.g. UNIAI_MAX_TOKENS for --max-tokens), or else
+// its alias. A flag set from the environment counts as given, so it takes
+// precedence over the defaults of task presets.
+func bindEnv(cmd *cobra.Command) error {
+	var errs []error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed || settingFlags[f.Name] {
+			return
+		}
+
+		for _, name := range envNames(f.Name) {
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				continue
+			}
+			if err := cmd.Flags().Set(f.Name, value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s: %w", name, err))
+			}
+			return
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// envNames returns the environment variables of the flag name, by
+// precedence.
+func envNames(flag string) []string {
+	names := []string{envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))}
+	if alias, ok := envAliases[flag]; ok {
+		names = append(names, alias)
+	}
+
+	return names
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/unidoc/unipdf/v4/model"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/internal/storage"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	estimateFile      string // Document to estimate
+	estimatePages     string // Page range to estimate
+	estimatePrompt    string // Prompt sent with every page
+	estimateModel     string // Model the requests would be sent to
+	estimateCrop      string // Region of the pages that would be rendered
+	estimateTextFirst bool   // Flag to count pages with a text layer as text
+	estimateJson      bool   // Flag to print the estimate as JSON
+)
+
+var estimateCmd = &cobra.Command{
+	Use:   "estimate",
+	Short: "Estimate the prompt tokens and cost of processing a document",
+	Long: `Estimate predicts the prompt tokens of the page requests of a document, and
+their cost from the prices of the usage ledger, without sending any request.
+
+Pages are not rendered: the size of their images is computed from the page
+size and the render width, then turned into vision tokens the way the family
+of the model does, e.g. fixed for llava, 512 px tiles for the UniAI models
+and 28 px patches for Qwen-VL. The answers are not included, as their length
+is not known in advance.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if estimateFile == "" {
+			return cmd.Help()
+		}
+		cmd.SilenceUsage = true
+
+		pageNumbers, err := cli.ParsePageRange(estimatePages)
+		if err != nil {
+			return fmt.Errorf("invalid page range: %w", err)
+		}
+		settings := cli.DefaultRenderSettings
+		if estimateCrop != "" {
+			box, err := cli.ParseCropBox(estimateCrop)
+			if err != nil {
+				return fmt.Errorf("invalid crop: %w", err)
+			}
+			settings.Crop = &box
+		}
+
+		estimate, err := estimateDocument(cmd.Context(), estimateFile, pageNumbers, settings)
+		if err != nil {
+			return err
+		}
+		path, err := usageLedgerPath()
+		if err != nil {
+			return err
+		}
+		prices, err := loadPrices(path)
+		if err != nil {
+			return err
+		}
+		estimate.Price(prices)
+
+		if estimateJson {
+			data, err := json.MarshalIndent(estimate, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "PAGE\tIMAGE SIZE\tIMAGE TOKENS\tPROMPT TOKENS\tTOKENS\t")
+		for _, p := range estimate.Pages {
+			size := "text"
+			if p.Width > 0 {
+				size = fmt.Sprintf("%dx%d", p.Width, p.Height)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%d\t\n", p.Page, size, p.ImageTokens, p.PromptTokens, p.Tokens())
+		}
+		fmt.Fprintf(w, "total\t\t%d\t%d\t%d\t\n", estimate.ImageTokens, estimate.PromptTokens, estimate.Tokens)
+		w.Flush()
+		fmt.Printf("\nEstimated prompt cost with %s: %.4f (answers not included)\n", estimate.Model, estimate.Cost)
+
+		return nil
+	},
+}
+
+// estimateDocument estimates the page requests of the selected pages of a
+// document, all of them if pageNumbers is empty.
+func estimateDocument(ctx context.Context, file string, pageNumbers []int, settings cli.RenderSettings) (*cli.Estimate, error) {
+	path := file
+	if storage.IsRemote(file) {
+		local, err := storage.Download(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(filepath.Dir(local))
+		path = local
+	}
+
+	estimate := cli.NewEstimate(estimateModel)
+	if cli.IsConvertible(path) {
+		doc, err := cli.ConvertFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(pageNumbers) == 0 {
+			for i := range doc.Pages {
+				pageNumbers = append(pageNumbers, i+1)
+			}
+		}
+		for _, pageNum := range pageNumbers {
+			if pageNum < 1 || pageNum > len(doc.Pages) {
+				return nil, fmt.Errorf("page %d out of range", pageNum)
+			}
+			page := doc.Pages[pageNum-1]
+			if page.Image == nil {
+				estimate.AddText(pageNum, page.Name, page.Text, estimatePrompt)
+				continue
+			}
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(page.Image))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode image %s: %w", page.Name, err)
+			}
+			estimate.AddImage(pageNum, cfg.Width, cfg.Height, estimatePrompt)
+		}
+		return estimate, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader, err := model.NewPdfReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF file: %w", err)
+	}
+	if len(pageNumbers) == 0 {
+		numPages, err := reader.GetNumPages()
+		if err != nil {
+			return nil, err
+		}
+		for i := 1; i <= numPages; i++ {
+			pageNumbers = append(pageNumbers, i)
+		}
+	}
+
+	for _, pageNum := range pageNumbers {
+		page, err := reader.GetPage(pageNum)
+		if err != nil {
+			return nil, err
+		}
+		if estimateTextFirst {
+			text, err := cli.ExtractPageText(page)
+			if err == nil && cli.HasUsableText(text, cli.DefaultMinTextChars) {
+				estimate.AddText(pageNum, "", text, estimatePrompt)
+				continue
+			}
+		}
+		width, height, err := cli.RenderedSize(page, settings)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		estimate.AddImage(pageNum, width, height, estimatePrompt)
+	}
+
+	return estimate, nil
+}
+
+func init() {
+	estimateCmd.Flags().StringVarP(&estimateFile, "file", "f", "", "Path or URL of the document")
+	estimateCmd.Flags().StringVarP(&estimatePages, "pages", "r", "", "Page range to estimate (all pages by default)")
+	estimateCmd.Flags().StringVarP(&estimatePrompt, "prompt", "m", "", "Prompt sent with every page")
+	estimateCmd.Flags().StringVar(&estimateModel, "model", uniai.ModelDefault, "Model the requests would be sent to, whose family sets how images are counted")
+	estimateCmd.Flags().StringVar(&estimateCrop, "crop", "", "Region of each page that would be rendered, as 'x,y,w,h' in points or percent (see uniai --crop)")
+	estimateCmd.Flags().BoolVar(&estimateTextFirst, "text-first", false, "Count pages with an extractable text layer as text, as uniai --text-first sends them")
+	estimateCmd.Flags().BoolVar(&estimateJson, "json", false, "Print the estimate as JSON")
+
+	uniaiCmd.AddCommand(estimateCmd)
+}
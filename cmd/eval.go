@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/eval"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	evalDataset string // Dataset file of documents and expected outputs
+	evalReport  string // File the JSON report is written to
+	evalPrompt  string // Prompt overriding the prompt of the dataset
+	evalTask    string // Task preset overriding the task of the dataset
+	evalModel   string // Model overriding the model of the dataset
+)
+
+var evalCmd = &cobra.Command{
+	Use:   "eval [dataset]",
+	Short: "Score prompts and models against a dataset of expected outputs",
+	Long: `Eval processes every document of a dataset, compares the answers with the
+expected outputs and prints a report with, per case and on average, the exact
+match, the F1 (field-level for JSON outputs, word-level for text) and the
+fuzzy similarity of the answers.
+
+The dataset is a YAML file:
+
+  prompt: Extract the invoice number and total as JSON
+  model: uniai01:7b
+  cases:
+    - file: invoices/acme.pdf
+      pages: 1-1
+      expected: {number: INV-001, total: 120.5}
+    - file: letters/notice.html
+      task: summarize
+      expected_file: letters/notice.txt
+
+Files are relative to the dataset. Expected mappings and lists are compared
+with the JSON answer of the model, strings with its text; --prompt, --task and
+--model override the dataset to compare variants.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			evalDataset = args[0]
+		}
+		if evalDataset == "" {
+			cmd.Help()
+			return
+		}
+
+		ds, err := eval.LoadDataset(evalDataset)
+		if err != nil {
+			println("Failed to load dataset:", err.Error())
+			return
+		}
+
+		client, err := newClient(cmd, evalDataset)
+		if err != nil {
+			println("Failed to initialize UniAI client:", err.Error())
+			return
+		}
+
+		report := &eval.Report{
+			Dataset:   ds.Name,
+			Model:     uniai.ModelDefault,
+			CreatedAt: time.Now().UTC(),
+		}
+		switch {
+		case evalModel != "":
+			report.Model = evalModel
+		case ds.Model != "":
+			report.Model = ds.Model
+		}
+
+		ctx := cmd.Context()
+		for _, c := range ds.Cases {
+			if evalPrompt != "" || evalTask != "" {
+				c.Prompt, c.Task = evalPrompt, evalTask
+			}
+
+			println("Evaluating", c.Name)
+			start := time.Now()
+			output, err := runEvalCase(ctx, client, report.Model, ds.TextFirst, c)
+			res := eval.Result{
+				Case:     c.Name,
+				File:     c.File,
+				Output:   output,
+				Duration: time.Since(start),
+			}
+			if err != nil {
+				println("Failed to evaluate", c.Name, ":", err.Error())
+				res.Error = err.Error()
+			} else if c.Structured() {
+				res.Score = eval.ScoreJSON(c.Expected, output)
+			} else {
+				res.Score = eval.ScoreText(fmt.Sprint(c.Expected), output)
+			}
+			report.Add(res)
+		}
+
+		fmt.Printf("\nDataset %s, model %s\n\n", report.Dataset, report.Model)
+		report.WriteText(os.Stdout)
+
+		if evalReport != "" {
+			if err := report.WriteJSON(evalReport); err != nil {
+				println("Failed to write report:", err.Error())
+				return
+			}
+			println("Report written to", evalReport)
+		}
+	},
+}
+
+// runEvalCase sends the pages of a case with its prompt or task and returns
+// the answers, joined into a JSON list when a structured output spans several
+// pages.
+func runEvalCase(ctx context.Context, client *uniai.Client, modelName string, textFirst bool, c eval.Case) (string, error) {
+	system, err := loadSystemPrompt(cli.DefaultSystemPreset)
+	if err != nil {
+		return "", err
+	}
+	req := uniai.GenerateRequest{
+		Model:   modelName,
+		Prompt:  c.Prompt,
+		System:  system,
+		Options: uniai.DefaultOptions,
+	}
+	if c.Task != "" {
+		presets, err := loadPresets()
+		if err != nil {
+			return "", err
+		}
+		preset, ok := presets[c.Task]
+		if !ok {
+			return "", fmt.Errorf("unknown task %q", c.Task)
+		}
+
+		if req.Prompt, err = renderPreset(preset, c.Prompt); err != nil {
+			return "", err
+		}
+		if req.Format, err = preset.Format(); err != nil {
+			return "", err
+		}
+		if preset.System != "" {
+			req.System = preset.System
+		}
+		req.Options = cli.MergeOptions(uniai.DefaultOptions, preset.Options)
+	}
+
+	pageNumbers, err := cli.ParsePageRange(c.Pages)
+	if err != nil {
+		return "", err
+	}
+	pages, err := loadInputPages(ctx, c.File, pageNumbers, textFirst)
+	if err != nil {
+		return "", err
+	}
+
+	answers := make([]string, 0, len(pages))
+	for _, page := range pages {
+		pageReq := req
+		if page.image != nil {
+			pageReq.Images = []uniai.ImageData{page.image}
+		} else {
+			pageReq.Prompt = cli.TextPrompt(req.Prompt, page.text)
+		}
+
+		answer, err := client.GenerateText(ctx, &pageReq)
+		if err != nil {
+			return strings.Join(answers, "\n\n"), err
+		}
+		answers = append(answers, strings.TrimSpace(answer))
+	}
+
+	if c.Structured() && len(answers) > 1 {
+		return eval.JoinJSON(answers), nil
+	}
+
+	return strings.Join(answers, "\n\n"), nil
+}
+
+func init() {
+	evalCmd.Flags().StringVarP(&evalDataset, "dataset", "d", "", "Dataset file (YAML or JSON) of documents and expected outputs")
+	evalCmd.Flags().StringVar(&evalReport, "report", "", "Write the report with the answers as JSON to this file")
+	evalCmd.Flags().StringVarP(&evalPrompt, "prompt", "m", "", "Prompt used for every case instead of the dataset prompts")
+	evalCmd.Flags().StringVar(&evalTask, "task", "", "Task preset used for every case instead of the dataset prompts")
+	evalCmd.Flags().StringVar(&evalModel, "model", "", "Model used instead of the model of the dataset")
+
+	uniaiCmd.AddCommand(evalCmd)
+}
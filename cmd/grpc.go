@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniaipb"
+)
+
+// grpcServer implements the gRPC API of the serve mode over background jobs.
+type grpcServer struct {
+	uniaipb.UnimplementedProcessingServer
+	jobs *cli.Jobs
+}
+
+func newGRPCServer(jobs *cli.Jobs) *grpc.Server {
+	s := grpc.NewServer()
+	uniaipb.RegisterProcessingServer(s, &grpcServer{jobs: jobs})
+
+	return s
+}
+
+func (s *grpcServer) SubmitDocument(ctx context.Context, req *uniaipb.SubmitDocumentRequest) (*uniaipb.Job, error) {
+	if req.Prompt == "" {
+		return nil, status.Error(codes.InvalidArgument, "the job has no prompt")
+	}
+	if req.File == "" && len(req.Data) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "the job has no file")
+	}
+
+	job := s.jobs.Submit(cli.JobSpec{
+		File:      req.File,
+		Name:      req.Name,
+		Data:      req.Data,
+		Prompt:    req.Prompt,
+		Pages:     req.Pages,
+		TextFirst: req.TextFirst,
+		Model:     req.Model,
+		System:    req.System,
+	})
+
+	return jobMessage(job.State()), nil
+}
+
+func (s *grpcServer) StreamResults(req *uniaipb.StreamResultsRequest, stream grpc.ServerStreamingServer[uniaipb.Event]) error {
+	job, ok := s.jobs.Get(req.JobId)
+	if !ok {
+		return status.Errorf(codes.NotFound, "job %s not found", req.JobId)
+	}
+
+	return job.Follow(stream.Context(), func(event cli.Event) error {
+		return stream.Send(&uniaipb.Event{
+			Type:  event.Type,
+			JobId: event.Job,
+			Page:  int32(event.Page),
+			Pages: int32(event.Pages),
+			Text:  event.Text,
+			Error: event.Error,
+		})
+	})
+}
+
+func (s *grpcServer) GetJob(ctx context.Context, req *uniaipb.GetJobRequest) (*uniaipb.Job, error) {
+	job, ok := s.jobs.Get(req.Id)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "job %s not found", req.Id)
+	}
+
+	return jobMessage(job.State()), nil
+}
+
+func jobMessage(state cli.JobState) *uniaipb.Job {
+	job := &uniaipb.Job{
+		Id:          state.ID,
+		Status:      state.Status,
+		Error:       state.Error,
+		Pages:       int32(state.Pages),
+		SubmittedAt: timestamppb.New(state.SubmittedAt),
+	}
+	if !state.FinishedAt.IsZero() {
+		job.FinishedAt = timestamppb.New(state.FinishedAt)
+	}
+	for _, r := range state.Results {
+		job.Results = append(job.Results, &uniaipb.PageResult{Page: int32(r.Page), Text: r.Text})
+	}
+
+	return job
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+)
+
+// readyTimeout bounds the request checking the UniAI API.
+const readyTimeout = 5 * time.Second
+
+// jobMetrics collects the metrics of the jobs run by this process.
+var jobMetrics = cli.NewMetrics()
+
+// handleHealth adds the health and metrics endpoints to mux. jobs may be nil
+// if jobs are not queued.
+func handleHealth(mux *http.ServeMux, cmd *cobra.Command, jobs *cli.Jobs) {
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := checkBackend(r.Context(), cmd); err != nil {
+			http.Error(w, "AI backend unavailable: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		up := 1
+		if checkBackend(r.Context(), cmd) != nil {
+			up = 0
+		}
+		fmt.Fprintln(w, "# HELP uniai_backend_up Whether the AI backend answers.")
+		fmt.Fprintln(w, "# TYPE uniai_backend_up gauge")
+		fmt.Fprintf(w, "uniai_backend_up %d\n", up)
+
+		if jobs != nil {
+			counts := jobs.Counts()
+			fmt.Fprintln(w, "# HELP uniai_jobs Jobs kept by the server, by status; queued jobs are the queue depth.")
+			fmt.Fprintln(w, "# TYPE uniai_jobs gauge")
+			for _, status := range []string{cli.JobQueued, cli.JobRunning, cli.JobDone, cli.JobFailed} {
+				fmt.Fprintf(w, "uniai_jobs{status=%q} %d\n", status, counts[status])
+			}
+		}
+
+		jobMetrics.WriteTo(w)
+	})
+}
+
+// checkBackend asks the provider of the command for its models.
+func checkBackend(ctx context.Context, cmd *cobra.Command) error {
+	client, err := newProviderClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+	_, err = client.ListModels(ctx)
+
+	return err
+}
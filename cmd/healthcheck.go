@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// Statuses of healthcheck and their exit codes, as those of Nagios plugins.
+const (
+	healthOK       = "OK"
+	healthWarning  = "WARNING"
+	healthCritical = "CRITICAL"
+)
+
+var healthExitCodes = map[string]int{healthOK: 0, healthWarning: 1, healthCritical: 2}
+
+var (
+	healthTimeout time.Duration // Time allowed for all the checks
+	healthModels  []string      // Models that must be served
+	healthJSON    bool          // Print the report as JSON instead of a status line
+)
+
+// healthReport is the result of healthcheck.
+type healthReport struct {
+	Status        string        `json:"status"`
+	Message       string        `json:"message"`
+	ServerVersion string        `json:"server_version,omitempty"`
+	Latency       time.Duration `json:"latency"`
+	Missing       []string      `json:"missing_models,omitempty"`
+}
+
+var healthcheckCmd = &cobra.Command{
+	Use:   "healthcheck",
+	Short: "Check that the API answers, accepts the credentials and serves the models",
+	Long: `Healthcheck checks, within --timeout, that the API of the provider of the
+command is reachable, that it accepts the credentials and that it serves every
+--model, and exits with the status of a Nagios plugin, for Kubernetes probes
+and monitors:
+
+  0  OK        all the checks passed
+  1  WARNING   the API answers but a model is not served, or the models
+               could not be listed
+  2  CRITICAL  the API is unreachable, did not answer in time or rejected
+               the credentials
+
+The result is printed as a status line with the latency as performance data,
+or as JSON with --json.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		client, err := newProviderClient(cmd)
+		if err != nil {
+			return err
+		}
+		// The first failure is the status, and the latency is that of a
+		// single request.
+		client.UseRetry(uniai.RetryPolicy{})
+		ctx, cancel := context.WithTimeout(cmd.Context(), healthTimeout)
+		defer cancel()
+
+		start := time.Now()
+		report := checkHealth(ctx, client, healthModels)
+		report.Latency = time.Since(start)
+
+		if healthJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				return err
+			}
+		} else {
+			fmt.Printf("UNIAI %s - %s | latency=%.3fs\n", report.Status, report.Message, report.Latency.Seconds())
+		}
+
+		if report.Status == healthOK {
+			return nil
+		}
+		return &exitError{code: healthExitCodes[report.Status], err: fmt.Errorf("healthcheck %s: %s", strings.ToLower(report.Status), report.Message)}
+	},
+}
+
+// checkHealth checks the API of client and that it serves models.
+func checkHealth(ctx context.Context, client *uniai.Client, models []string) healthReport {
+	critical := func(err error) healthReport {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return healthReport{Status: healthCritical, Message: fmt.Sprintf("no answer within %s", healthTimeout)}
+		}
+		return healthReport{Status: healthCritical, Message: err.Error()}
+	}
+
+	if err := client.Heartbeat(ctx); err != nil && (cli.Unreachable(err) || ctx.Err() != nil) {
+		return critical(errUnreachable(err))
+	}
+
+	var report healthReport
+	version, err := client.Version(ctx)
+	switch {
+	case err == nil:
+		report.ServerVersion = version
+	case rejected(err):
+		return critical(errRejected(err))
+	case cli.Unreachable(err) || ctx.Err() != nil:
+		return critical(errUnreachable(err))
+	}
+
+	available, err := client.ListModels(ctx)
+	switch {
+	case err == nil:
+	case rejected(err):
+		return critical(errRejected(err))
+	case cli.Unreachable(err) || ctx.Err() != nil:
+		return critical(errUnreachable(err))
+	default:
+		report.Status, report.Message = healthWarning, "failed to list the models: "+err.Error()
+		return report
+	}
+
+	var names []string
+	for _, m := range available {
+		names = append(names, m.Name)
+	}
+	for _, model := range models {
+		if resolved := client.ResolveModel(model); !hasModel(names, resolved) {
+			report.Missing = append(report.Missing, resolved)
+		}
+	}
+	if len(report.Missing) > 0 {
+		report.Status = healthWarning
+		report.Message = fmt.Sprintf("model(s) not served: %s; available: %s", strings.Join(report.Missing, ", "), strings.Join(names, ", "))
+		return report
+	}
+
+	report.Status = healthOK
+	report.Message = fmt.Sprintf("API reachable, credentials accepted, model(s) %s served", strings.Join(models, ", "))
+	if report.ServerVersion != "" {
+		report.Message += ", server " + report.ServerVersion
+	}
+
+	return report
+}
+
+func init() {
+	healthcheckCmd.Flags().DurationVar(&healthTimeout, "timeout", readyTimeout, "Time allowed for all the checks")
+	healthcheckCmd.Flags().StringSliceVar(&healthModels, "model", []string{uniai.ModelDefault}, "Comma-separated models that must be served (aliases are resolved)")
+	healthcheckCmd.Flags().BoolVar(&healthJSON, "json", false, "Print the result as JSON instead of a status line")
+
+	uniaiCmd.AddCommand(healthcheckCmd)
+}
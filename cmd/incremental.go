@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sampila/uniai-client/internal/cli"
+)
+
+var incremental bool // Flag to reprocess only the pages that changed since the last revision of the document
+
+// pageIndex is the page index of the document of an incremental run.
+type pageIndex struct {
+	path     string
+	index    *cli.PageIndex
+	hashes   map[int]string // page hashes of this revision
+	settings string         // settings hash of this run
+}
+
+// openPageIndex hashes the pages of the PDF at path and loads the page index
+// of the previous revision of the document, next to its outputs.
+func openPageIndex(proc *pageProcessor, out cli.OutputDir, path string) (*pageIndex, error) {
+	hashes, err := cli.PageHashes(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash pages: %w", err)
+	}
+	x := &pageIndex{
+		path:     cli.OutputDir{Dir: out.Doc, Name: out.Name, Flat: out.Flat}.Path(cli.PageIndexFile),
+		hashes:   hashes,
+		settings: cli.SettingsHash(proc.model, proc.system, proc.basePrompt(), proc.format, proc.options, proc.settings),
+	}
+	if x.index, err = cli.LoadPageIndex(x.path); err != nil {
+		return nil, err
+	}
+
+	return x, nil
+}
+
+// reuse adds the responses of the previous revision to the selected pages
+// that did not change to the run, and returns the pages left to process.
+// With --force, every page is processed.
+func (x *pageIndex) reuse(proc *pageProcessor, selected []int) []int {
+	if force {
+		return selected
+	}
+
+	var changed, reused []int
+	for _, pageNum := range selected {
+		response, ok := x.index.Lookup(x.hashes[pageNum], x.settings)
+		if !ok {
+			changed = append(changed, pageNum)
+			continue
+		}
+		if err := proc.reuse(pageNum, response); err != nil {
+			logger.Warn("failed to reuse the response of the previous revision", "page", pageNum, "err", err)
+			changed = append(changed, pageNum)
+			continue
+		}
+		reused = append(reused, pageNum)
+	}
+	logger.Info("incremental run", "index", x.path, "changed", len(changed), "unchanged", len(reused))
+
+	return changed
+}
+
+// write updates the page index with the responses of this run.
+func (x *pageIndex) write(proc *pageProcessor, source, fileHash string) error {
+	responses, err := proc.checkpoint.Responses()
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if err := x.index.Update(source, fileHash, x.settings, x.hashes, responses).Write(x.path); err != nil {
+		return err
+	}
+	logger.Info("page index written", "path", x.path)
+
+	return nil
+}
+
+// reuse records response, from the previous revision of the document, as the
+// response to the page, as if it had been sent.
+func (p *pageProcessor) reuse(pageNum int, response string) error {
+	name := fmt.Sprintf("page_%d", pageNum)
+	sink, err := p.openSink(name)
+	if err != nil {
+		return err
+	}
+	defer sink.Close()
+
+	if sink.path != "" {
+		logger.Info("writing response to file", "request", name, "path", sink.path)
+	}
+	fmt.Fprintln(sink, response)
+	sink.Flush()
+	if sink.path != "" {
+		if err := sink.Close(); err != nil {
+			return fmt.Errorf("failed to write response file: %w", err)
+		}
+		p.record(cli.ArtifactResponse, sink.path, []int{pageNum}, "")
+	}
+
+	p.run.Add(cli.RequestInfo{Name: name, Pages: []int{pageNum}, Model: p.model, StartedAt: time.Now().UTC(), Cached: true})
+	p.checkpointed(name, []int{pageNum}, response)
+
+	return nil
+}
+
+func init() {
+	uniaiCmd.Flags().BoolVar(&incremental, "incremental", false, "Process only the pages of a PDF that changed since its last run with the same settings, reusing the responses of the other pages; a document with changed content keeps its output name as a new revision ("+cli.PageIndexFile+" next to the outputs)")
+}
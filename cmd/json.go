@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	jsonOut    bool   // Flag to ask for JSON answers, validated and merged into a JSON document
+	schemaFile string // JSON schema file the answers must match, implies --json
+)
+
+// jsonFormat is the response format of --json without a schema.
+var jsonFormat = json.RawMessage(`"json"`)
+
+// loadSchema reads the JSON schema file at path and returns the schema and
+// its compact encoding, sent as the response format.
+func loadSchema(path string) (map[string]any, json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	format, err := json.Marshal(schema)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	return schema, format, nil
+}
+
+// request sends a request with send. With --json, an answer that is not JSON
+// or does not match the schema is asked again once, with its violations.
+func (p *pageProcessor) request(ctx context.Context, name string, pages []int, requestPrompt, text string, images []uniai.ImageData) (string, error) {
+	response, err := p.send(ctx, name, pages, requestPrompt, text, images)
+	var verr *uniai.ValidationError
+	if !errors.As(err, &verr) {
+		return response, err
+	}
+
+	logger.Warn("invalid JSON answer, asking again", "request", name, "violations", verr.Violations)
+	requestPrompt += "\n\nYour previous answer was invalid: " + strings.Join(verr.Violations, "; ") + ". Correct it."
+
+	return p.send(ctx, name, pages, requestPrompt, text, images)
+}
+
+// parseJSON returns the answer to a request of a --json run as indented
+// JSON, or a *uniai.ValidationError if it is invalid.
+func (p *pageProcessor) parseJSON(answer string) (string, error) {
+	value, err := uniai.ParseJSON(answer, p.schema)
+	if err != nil {
+		return "", err
+	}
+	var indented strings.Builder
+	enc := json.NewEncoder(&indented)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(value); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(indented.String(), "\n"), nil
+}
+
+// writeJSONDocument merges the JSON answers of the run into a single
+// document when --json is set.
+func writeJSONDocument(proc *pageProcessor, out cli.OutputDir, source string) error {
+	if !jsonOut {
+		return nil
+	}
+	entries, err := proc.checkpoint.Entries(proc.run.StartedAt)
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	data, err := cli.MergeJSON(source, proc.model, entries)
+	if err != nil {
+		return fmt.Errorf("failed to merge JSON document: %w", err)
+	}
+	path := out.Path(cli.JSONDocumentFile)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write JSON document: %w", err)
+	}
+	logger.Info("JSON document written", "path", path)
+	proc.record(cli.ArtifactJSONDocument, path, nil, "")
+
+	return nil
+}
+
+func init() {
+	uniaiCmd.Flags().BoolVar(&jsonOut, "json", false, "Ask for JSON answers, matching the schema of --schema or of the task preset if any: invalid answers are repaired or asked again once, and written as page_N.json in the response directory, as with --write-response, with a merged "+cli.JSONDocumentFile)
+	uniaiCmd.Flags().StringVar(&schemaFile, "schema", "", "JSON schema file the answers must match, sent as the response format (implies --json)")
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+	"github.com/sampila/uniai-client/pkg/uniai/uniaitest"
+)
+
+// TestRequestCachesValidJSON checks that an invalid answer of a --json run,
+// new or from a previous run, is not left in the response cache, and that
+// the answer to the request asking again is.
+func TestRequestCachesValidJSON(t *testing.T) {
+	const valid = `{"total": 3}`
+
+	tests := []struct {
+		name    string
+		cached  string   // invalid answer cached by a previous run, if any
+		replies []string // answers of the API, in order
+	}{
+		{name: "new answer", replies: []string{"The total is 3.", valid}},
+		{name: "cached answer", cached: "The total is 3.", replies: []string{valid}},
+	}
+
+	prev := jsonOut
+	jsonOut = true
+	t.Cleanup(func() { jsonOut = prev })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := uniaitest.NewServer()
+			t.Cleanup(srv.Close)
+			for _, reply := range tt.replies {
+				srv.Reply(uniaitest.Text(reply))
+			}
+			client, err := srv.NewClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			responses, err := cli.NewResponseCache(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &pageProcessor{client: client, model: uniai.ModelDefault, format: jsonFormat, responses: responses}
+
+			const prompt = "Extract the total."
+			if tt.cached != "" {
+				req := uniai.GenerateRequest{Model: p.model, Prompt: prompt, Format: p.format}
+				if err := responses.Put(responses.Key(&req), tt.cached); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			result, err := p.request(context.Background(), "page_1", []int{1}, prompt, "", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "{\n  \"total\": 3\n}"; result != want {
+				t.Errorf("result %q, want %q", result, want)
+			}
+			if got := len(srv.Requests()); got != len(tt.replies) {
+				t.Errorf("%d requests sent, want %d", got, len(tt.replies))
+			}
+
+			var cached []string
+			err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					cached = append(cached, path)
+				}
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cached) != 1 {
+				t.Fatalf("%d cached responses, want only the valid answer", len(cached))
+			}
+			requests := srv.Requests()
+			var sent uniai.GenerateRequest
+			if err := requests[len(requests)-1].Decode(&sent); err != nil {
+				t.Fatal(err)
+			}
+			retry := uniai.GenerateRequest{Model: p.model, Prompt: sent.Prompt, Format: p.format}
+			if got, ok := responses.Get(responses.Key(&retry)); !ok || got != valid {
+				t.Errorf("cached response of the retry %q, want %q", got, valid)
+			}
+		})
+	}
+}
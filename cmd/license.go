@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+// defaultLicenseKeyEnv is the environment variable holding the metered
+// UniDoc API key, unless another one is chosen.
+const defaultLicenseKeyEnv = "UNIDOC_LICENSE_API_KEY_DEV"
+
+// noPDFAnnotation marks the commands that never read or write PDFs, and so
+// run without a UniDoc license.
+const noPDFAnnotation = "uniai/no-pdf"
+
+var (
+	licenseKeyEnv   string // Environment variable holding the metered UniDoc API key
+	licenseFile     string // Offline UniDoc license file, used instead of a metered key
+	licenseCustomer string // Customer name of the offline license file
+)
+
+// setupLicense sets the UniDoc license for commands handling PDFs: the
+// offline license file of --license-file or UNIDOC_LICENSE_FILE if any,
+// otherwise the metered key in the environment variable of
+// --license-key-env, UNIDOC_LICENSE_KEY_ENV or UNIDOC_LICENSE_API_KEY_DEV.
+func setupLicense(cmd *cobra.Command) error {
+	if !needsLicense(cmd) {
+		return nil
+	}
+
+	if file := cmp.Or(licenseFile, os.Getenv("UNIDOC_LICENSE_FILE")); file != "" {
+		customer := cmp.Or(licenseCustomer, os.Getenv("UNIDOC_LICENSE_CUSTOMER"))
+		if customer == "" {
+			return fmt.Errorf("the UniDoc license file %s needs the customer name it was issued to: set --license-customer or UNIDOC_LICENSE_CUSTOMER", file)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read the UniDoc license file: %w", err)
+		}
+		// The key is the body of the file, between its BEGIN and END lines.
+		for _, line := range strings.Split(string(data), "\n") {
+			if !strings.HasPrefix(strings.TrimSpace(line), "-----") {
+				redactor.Add(line)
+			}
+		}
+		if err := license.SetLicenseKey(string(data), customer); err != nil {
+			return fmt.Errorf("invalid UniDoc license file %s: %w", file, err)
+		}
+		return nil
+	}
+
+	env := cmp.Or(licenseKeyEnv, os.Getenv("UNIDOC_LICENSE_KEY_ENV"), defaultLicenseKeyEnv)
+	key := os.Getenv(env)
+	if key == "" {
+		return fmt.Errorf("no UniDoc license to process PDFs: set %s to a metered API key, or --license-file to an offline license file", env)
+	}
+	redactor.Add(key)
+	if err := license.SetMeteredKey(key); err != nil {
+		return fmt.Errorf("failed to set the UniDoc metered key of %s: %w", env, err)
+	}
+
+	return nil
+}
+
+// needsLicense reports whether cmd may handle PDFs: every command but those
+// marked by noPDF, their subcommands, and the help and completion commands.
+func needsLicense(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Annotations[noPDFAnnotation] != "" {
+			return false
+		}
+		switch c.Name() {
+		case "help", "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			return false
+		}
+	}
+
+	return true
+}
+
+// noPDF marks commands, and their subcommands, as never handling PDFs.
+func noPDF(cmds ...*cobra.Command) {
+	for _, c := range cmds {
+		if c.Annotations == nil {
+			c.Annotations = make(map[string]string)
+		}
+		c.Annotations[noPDFAnnotation] = "true"
+	}
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&licenseKeyEnv, "license-key-env", "", "Environment variable holding the metered UniDoc API key (defaults to $UNIDOC_LICENSE_KEY_ENV or "+defaultLicenseKeyEnv+")")
+	rootCmd.PersistentFlags().StringVar(&licenseFile, "license-file", "", "Offline UniDoc license file used instead of a metered key (defaults to $UNIDOC_LICENSE_FILE)")
+	rootCmd.PersistentFlags().StringVar(&licenseCustomer, "license-customer", "", "Customer name the offline license file was issued to (defaults to $UNIDOC_LICENSE_CUSTOMER)")
+
+	noPDF(auditCmd, chatCmd, diffCmd, migrateCmd, queuePushCmd, queueResultsCmd, queryCmd, tasksCmd, templatesCmd, usageCmd)
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// Log formats of --log-format.
+const (
+	logFormatText = "text"
+	logFormatJson = "json"
+)
+
+var (
+	logFormat string // Log format: text or json
+	logLevel  string // Minimum level of logged messages: debug, info, warn or error
+)
+
+// secretEnv are the environment variables holding credentials read by the
+// commands besides those of the configuration, masked in the logs.
+var secretEnv = []string{
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"GOOGLE_OAUTH_ACCESS_TOKEN",
+	cli.AuditKeyEnv,
+}
+
+// redactor masks the API credentials, license keys and image payloads in the
+// logs and the debug output of the commands. Clients register their
+// credentials with it as they are created.
+var redactor = uniai.NewRedactor()
+
+// logger reports the progress and the failures of the commands on stderr.
+var logger = newLogger(os.Stderr, logFormatText, slog.LevelInfo)
+
+// newLogger returns a logger writing to w in format, with secrets masked by
+// the redactor. Text logs leave out the time, as they are read in a terminal.
+func newLogger(w io.Writer, format string, level slog.Level) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactor.ReplaceAttr}
+	if format == logFormatJson {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+
+	opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return redactor.ReplaceAttr(groups, a)
+	}
+
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// setupLogger replaces the logger by one with the format and level of the
+// flags.
+func setupLogger(cmd *cobra.Command, args []string) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return fmt.Errorf("invalid log level: %w", err)
+	}
+
+	switch logFormat {
+	case logFormatText, logFormatJson:
+	default:
+		return fmt.Errorf("invalid log format: %s", logFormat)
+	}
+
+	for _, name := range secretEnv {
+		redactor.Add(os.Getenv(name))
+	}
+	logger = newLogger(os.Stderr, logFormat, level)
+
+	return nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logFormatText, "Format of the log on stderr: 'text' or 'json'")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Minimum level of logged messages: 'debug', 'info', 'warn' or 'error'")
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate <output-dir>...",
+	Short: "Upgrade the outputs of past runs to the current schema",
+	Long: `Migrate upgrades the JSON files written by past runs (manifest.json, run.json,
+comparison.json and crosscheck.json) found below the given output directories
+to schema version ` + strconv.Itoa(cli.SchemaVersion) + `, so archived results stay readable by current tools.
+Every file records its version as "schema_version"; files without one are
+version 1. The checksums of upgraded files are updated in their manifest.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		total := 0
+		for _, dir := range args {
+			migrated, err := cli.MigrateOutputs(dir)
+			for _, path := range migrated {
+				println("Upgraded", path)
+			}
+			total += len(migrated)
+			if err != nil {
+				println("Failed to migrate", dir, ":", err.Error())
+				return
+			}
+		}
+		println("Upgraded", total, "file(s) to schema version", cli.SchemaVersion)
+	},
+}
+
+func init() {
+	uniaiCmd.AddCommand(migrateCmd)
+}
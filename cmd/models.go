@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var modelsFile string // Model configuration, defaults to models.yaml in the user config directory
+
+// loadModels reads the model configuration of --model-config.
+func loadModels() (*cli.Models, error) {
+	path := modelsFile
+	if path == "" {
+		var err error
+		if path, err = cli.DefaultModelsPath(); err != nil {
+			return nil, err
+		}
+	}
+
+	return cli.LoadModels(path)
+}
+
+// useModels makes client resolve the model aliases and merge the options of
+// the model profiles into its requests.
+func useModels(client *uniai.Client) error {
+	models, err := loadModels()
+	if err != nil {
+		return err
+	}
+	if len(models.Profiles) > 0 {
+		client.UseOptionsResolver(models.Profiles.Options)
+	}
+	if len(models.Aliases) > 0 {
+		client.UseModelAliases(models.Aliases)
+	}
+
+	return nil
+}
+
+// selectModel picks the model of a run with --model auto about pages of doc,
+// or of the PDF file if doc is nil, from the tiers of the model
+// configuration.
+func selectModel(doc *cli.Document, pages int, structured bool) (*cli.ModelSelection, error) {
+	models, err := loadModels()
+	if err != nil {
+		return nil, err
+	}
+
+	in := cli.SelectionInput{
+		Pages:      pages,
+		Language:   cli.DetectLanguage(prompt),
+		Vision:     doc == nil && !textFirst,
+		Structured: structured,
+	}
+	if activePreset != nil {
+		in.Task = taskName
+	}
+	if doc != nil {
+		in.Language = cmp.Or(cli.DetectLanguage(doc.Text()), in.Language)
+		in.Vision = slices.ContainsFunc(doc.Pages, func(p cli.DocumentPage) bool {
+			return p.Image != nil
+		})
+	}
+	selection := models.Auto.Select(in)
+
+	return &selection, nil
+}
+
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "List the models served by the API",
+	Long: `Models lists the models served by the API of the provider, with their size and
+modification time. 'uniai models show' prints the details of a model and
+'uniai models pull' downloads a model to the server.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		client, err := newProviderClient(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to initialize UniAI client: %w", err)
+		}
+		available, err := client.ListModels(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to list models: %w", err)
+		}
+		slices.SortFunc(available, func(a, b uniai.ModelInfo) int { return strings.Compare(a.Name, b.Name) })
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "NAME\tSIZE\tMODIFIED\n")
+		for _, m := range available {
+			modified := ""
+			if !m.ModifiedAt.IsZero() {
+				modified = m.ModifiedAt.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", m.Name, formatSize(m.Size), modified)
+		}
+
+		return w.Flush()
+	},
+}
+
+var modelsShowCmd = &cobra.Command{
+	Use:   "show <model>",
+	Short: "Print the details of a model",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		client, err := newProviderClient(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to initialize UniAI client: %w", err)
+		}
+		resp, err := client.ShowModel(cmd.Context(), &uniai.ShowRequest{Model: args[0]})
+		if err != nil {
+			return fmt.Errorf("failed to show model %s: %w", args[0], err)
+		}
+
+		d := resp.Details
+		fmt.Printf("%-14s %s\n", "model", client.ResolveModel(args[0]))
+		for _, field := range [][2]string{
+			{"family", d.Family},
+			{"parameters", d.ParameterSize},
+			{"quantization", d.QuantizationLevel},
+			{"format", d.Format},
+			{"capabilities", strings.Join(resp.Capabilities, ", ")},
+		} {
+			if field[1] != "" {
+				fmt.Printf("%-14s %s\n", field[0], field[1])
+			}
+		}
+		if resp.Parameters != "" {
+			fmt.Printf("\nParameters:\n%s\n", strings.TrimRight(resp.Parameters, "\n"))
+		}
+		if resp.System != "" {
+			fmt.Printf("\nSystem:\n%s\n", strings.TrimRight(resp.System, "\n"))
+		}
+
+		return nil
+	},
+}
+
+var modelsPullCmd = &cobra.Command{
+	Use:   "pull <model>",
+	Short: "Download a model to the server",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		client, err := newProviderClient(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to initialize UniAI client: %w", err)
+		}
+
+		// Progress is logged when the status changes and every 10% of a
+		// layer.
+		var status string
+		var step int64
+		err = client.PullModel(cmd.Context(), &uniai.PullRequest{Model: args[0]}, func(p uniai.ProgressResponse) error {
+			if p.Status != status {
+				status, step = p.Status, -1
+				if p.Total == 0 {
+					logger.Info(p.Status, "model", args[0])
+				}
+			}
+			if p.Total > 0 {
+				if s := p.Completed * 10 / p.Total; s != step {
+					step = s
+					logger.Info(p.Status, "model", args[0], "completed", formatSize(p.Completed), "total", formatSize(p.Total), "percent", s*10)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to pull model %s: %w", args[0], err)
+		}
+
+		return nil
+	},
+}
+
+// formatSize formats a number of bytes with a decimal unit.
+func formatSize(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+func init() {
+	uniaiCmd.PersistentFlags().StringVar(&modelsFile, "model-config", "", "Model configuration file of the default options of each model and of the model aliases (defaults to "+cli.ModelsFile+" in the user config directory)")
+
+	modelsCmd.AddCommand(modelsShowCmd)
+	modelsCmd.AddCommand(modelsPullCmd)
+	uniaiCmd.AddCommand(modelsCmd)
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sampila/uniai-client/internal/cli"
+)
+
+// Output formats of --output-format.
+const (
+	outputFormatMarkdown = "md"
+	outputFormatJSONL    = "jsonl"
+	outputFormatText     = "txt"
+	outputFormatPDF      = "pdf"
+)
+
+var (
+	outputFormats []string // Output formats of the responses: md, jsonl, txt or pdf
+	stitchOut     bool     // Flag to indicate if the responses should be merged into document.md, as with --markdown
+	jsonlOut      bool     // Flag to indicate if the responses should be written to responses.jsonl
+)
+
+// applyOutputFormats turns on the outputs of --output-format.
+func applyOutputFormats() error {
+	for _, format := range outputFormats {
+		switch format {
+		case outputFormatMarkdown:
+			stitchOut = true
+		case outputFormatJSONL:
+			jsonlOut = true
+		case outputFormatText:
+			writeResponse = true
+		case outputFormatPDF:
+			searchable = true
+		default:
+			return fmt.Errorf("invalid output format: %s", format)
+		}
+	}
+
+	return nil
+}
+
+// writeResponsesJSONL writes the responses of the run with the metadata of
+// their requests to responses.jsonl when --output-format jsonl is set.
+func writeResponsesJSONL(proc *pageProcessor, out cli.OutputDir) error {
+	if !jsonlOut {
+		return nil
+	}
+	entries, err := proc.checkpoint.Entries(proc.run.StartedAt)
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	path := out.Path(cli.ResponsesFile)
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write responses: %w", err)
+	}
+	if err := cli.WriteResponsesJSONL(f, proc.run, proc.model, entries); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write responses: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write responses: %w", err)
+	}
+	logger.Info("responses written", "path", path)
+	proc.record(cli.ArtifactResponsesJSONL, path, nil, "")
+
+	return nil
+}
+
+func init() {
+	uniaiCmd.Flags().StringSliceVar(&outputFormats, "output-format", nil, "Comma-separated output formats of the responses: 'md' (merged into "+cli.MarkdownFile+"), 'jsonl' (one line per request with its pages, model and token counts in "+cli.ResponsesFile+"), 'txt' (a file per request, as --write-response) or 'pdf' (a searchable copy of the PDF, as --searchable; use with an OCR prompt)")
+}
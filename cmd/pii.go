@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/sampila/uniai-client/internal/cli"
+)
+
+var (
+	scrubPII   bool   // Flag to indicate if personal data should be masked in the text sent to the API
+	blurPII    bool   // Flag to indicate if personal data should be pixelated in the page images sent to the API
+	restorePII bool   // Flag to indicate if masked personal data should be restored in the responses
+	piiMapPath string // File the mapping of masked values is written to
+	piiReport  bool   // Flag to indicate if the responses should be scanned for personal data
+)
+
+// writePIIMap writes the mapping of the values masked with --scrub-pii to
+// --pii-map, or next to the outputs. It is kept out of the manifest, and so
+// never uploaded.
+func writePIIMap(proc *pageProcessor, out cli.OutputDir) error {
+	if proc.pii == nil {
+		return nil
+	}
+
+	path := piiMapPath
+	if path == "" {
+		path = out.Path(cli.PIIMapFile)
+	}
+	if err := proc.pii.Write(path); err != nil {
+		return err
+	}
+	logger.Info("PII map written", "path", path, "values", proc.pii.Len())
+
+	return nil
+}
+
+// writePIIReport writes the report of the personal data found in the
+// responses of the run with --pii-report.
+func writePIIReport(proc *pageProcessor, out cli.OutputDir) error {
+	if !piiReport {
+		return nil
+	}
+
+	responses, err := proc.checkpoint.Entries(proc.run.StartedAt)
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	report := cli.NewPIIReport(proc.run.Source, responses)
+
+	path := out.Path(cli.PIIReportFile)
+	if err := report.Write(path); err != nil {
+		return err
+	}
+	if len(report.Findings) > 0 {
+		logger.Warn("responses contain personal data", "path", path, "counts", report.Counts)
+	} else {
+		logger.Info("no personal data found in the responses", "path", path)
+	}
+	proc.record(cli.ArtifactPIIReport, path, nil, "")
+
+	return nil
+}
+
+func init() {
+	uniaiCmd.Flags().BoolVar(&scrubPII, "scrub-pii", false, "Mask email addresses, phone numbers, IBANs and national IDs in the prompt and extracted text before sending them, keeping the mapping locally")
+	uniaiCmd.Flags().BoolVar(&blurPII, "blur-pii", false, "Pixelate the personal data found in the text layer of rendered pages before sending them")
+	uniaiCmd.Flags().BoolVar(&restorePII, "restore-pii", false, "With --scrub-pii, restore the masked values in the responses written locally")
+	uniaiCmd.Flags().BoolVar(&piiReport, "pii-report", false, "Scan the responses for email addresses, phone numbers, IBANs and national IDs and write their counts and locations to "+cli.PIIReportFile)
+	uniaiCmd.Flags().StringVar(&piiMapPath, "pii-map", "", "File the mapping of masked values is written to (defaults to "+cli.PIIMapFile+" next to the outputs; required with an object storage --output)")
+}
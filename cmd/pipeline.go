@@ -0,0 +1,1588 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unidoc/unipdf/v4/model"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/internal/storage"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// renderWorkers is the number of pages rendered concurrently in parallel
+// mode, and so the number of PDF readers kept open.
+const renderWorkers = 3
+
+type renderedPage struct {
+	pageNum  int
+	filePath string
+	text     string // extracted text layer, set when the page is sent as text
+	images   []string
+	skipped  bool
+	reason   string
+	size     int64 // bytes held for the page while it waits for a request
+	err      error // why the page could not be prepared
+}
+
+// pageProcessor holds the per-run state shared by the render and generate
+// stages.
+type pageProcessor struct {
+	out      cli.OutputDir
+	fileHash string
+	settings cli.RenderSettings
+	crops    map[int]cli.CropBox // per-page crop boxes overriding settings.Crop
+	cache    *cli.RenderCache
+	client   *uniai.Client
+
+	// responses holds the responses of previous runs, reused unless
+	// --force is set.
+	responses *cli.ResponseCache
+
+	// model answers every request; with --models, comparison collects its
+	// answers and those of the other models.
+	model      string
+	comparison *cli.Comparison
+
+	// crossChecks compares every response with the local OCR of its pages
+	// when --cross-validate is set.
+	crossChecks *cli.CrossCheck
+
+	// router sends page requests to the provider and model of their route
+	// instead, unless --models is set. pages is the page count of the
+	// document it routes by.
+	router *router
+	pages  int
+
+	// maxRequest is the size limit of a serialized request, from
+	// --max-request-bytes; larger requests are downscaled or split.
+	maxRequest int
+
+	// budget throttles the render workers when the pages waiting for a
+	// request hold more than --max-inflight-bytes.
+	budget *cli.ByteBudget
+
+	// manifest records every artifact written during the run.
+	manifest *cli.Manifest
+
+	// run records the settings and timing of the requests of the run.
+	run *cli.RunInfo
+
+	// attachmentContext and attachmentImages are added to every request
+	// when --attachments is set.
+	attachmentContext string
+	attachmentImages  []uniai.ImageData
+
+	// system, options and format are sent with every request; a task
+	// preset may override them.
+	system  string
+	options map[string]any
+	format  json.RawMessage
+
+	// schema is the JSON schema the answers of a --json run must match, nil
+	// for any JSON value.
+	schema map[string]any
+
+	// filters clean up every response before the hooks run.
+	filters []uniai.ResponseFilter
+
+	// hooks preprocess page images and postprocess responses.
+	hooks *cli.Hooks
+
+	// pacer spaces out the requests once the API rate limited one.
+	pacer *cli.Pacer
+
+	// checkpoint persists every response as soon as it completes.
+	checkpoint *cli.Checkpoint
+
+	// dataset collects the answered requests with --dataset.
+	dataset *cli.Dataset
+
+	// pii masks personal data in requests with --scrub-pii.
+	pii *cli.PIIMap
+
+	// pageContext adds the previous answer, or a rolling summary of the
+	// answers so far, to every request with --page-context.
+	pageContext *cli.PageContext
+
+	// compressor shrinks the prompts exceeding the context window of the
+	// model, nil if it is not known.
+	compressor *uniai.Compressor
+
+	// fallback switches the requests to a smaller model once the model is
+	// overloaded, nil without --fallback-model.
+	fallback *cli.ModelFallback
+
+	// errors collects the pages that failed, reported at the end of the run.
+	errors *cli.PageErrors
+}
+
+// Stages of the pipeline in which a page can fail.
+const (
+	stageSelect   = "select"
+	stageRender   = "render"
+	stageRead     = "read"
+	stageGenerate = "generate"
+)
+
+// maxRateLimitedAttempts bounds the attempts of a request the API rate
+// limits.
+const maxRateLimitedAttempts = 6
+
+// errBudgetExhausted is returned for requests that were not sent because the
+// budget of the run is exhausted.
+var errBudgetExhausted = errors.New("the budget of the run is exhausted")
+
+// fail records that pages failed in stage and reports every page.
+func (p *pageProcessor) fail(stage string, pages []int, err error) {
+	for _, pe := range p.errors.Add(stage, pages, err) {
+		logger.Error("page failed", "doc", pe.Doc, "page", pe.Page, "stage", pe.Stage, "err", pe.Err)
+	}
+}
+
+// countPages returns the number of pages of the PDF at path.
+func countPages(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	pdfReader, err := model.NewPdfReader(f)
+	if err != nil {
+		return 0, err
+	}
+
+	return pdfReader.GetNumPages()
+}
+
+// loadAttachments reads the embedded files of the document and converts them
+// into prompt context and images.
+func (p *pageProcessor) loadAttachments() error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pdfReader, err := model.NewPdfReader(f)
+	if err != nil {
+		return err
+	}
+
+	attachments, err := cli.ReadAttachments(pdfReader, cli.DefaultMaxAttachmentText)
+	if err != nil {
+		return err
+	}
+
+	for _, att := range attachments {
+		logger.Info("found attachment", "name", att.Name)
+		if att.Image != nil {
+			p.attachmentImages = append(p.attachmentImages, att.Image)
+		}
+	}
+	p.attachmentContext = cli.AttachmentContext(attachments)
+
+	return nil
+}
+
+// readSections returns the outline sections of the document.
+func (p *pageProcessor) readSections() ([]cli.Section, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pdfReader, err := model.NewPdfReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return cli.ReadSections(pdfReader, sectionDepth)
+}
+
+// processSections renders and sends the selected pages section by section,
+// one request per section. The skipped pages are returned.
+func (p *pageProcessor) processSections(ctx context.Context, sections []cli.Section, selected []int) []renderedPage {
+	isSelected := make(map[int]bool, len(selected))
+	for _, pageNum := range selected {
+		isSelected[pageNum] = true
+	}
+
+	var skippedPages []renderedPage
+	for i, section := range sections {
+		var pageNumbers []int
+		for _, pageNum := range section.Pages() {
+			if isSelected[pageNum] {
+				pageNumbers = append(pageNumbers, pageNum)
+			}
+		}
+		if len(pageNumbers) == 0 {
+			continue
+		}
+
+		renderedPages := p.renderWindow(ctx, pageNumbers)
+
+		var pages []renderedPage
+		for _, page := range renderedPages {
+			switch {
+			case page.err != nil:
+				p.fail(stageRender, []int{page.pageNum}, page.err)
+			case page.skipped:
+				skippedPages = append(skippedPages, page)
+			default:
+				pages = append(pages, page)
+			}
+		}
+
+		p.generateSection(ctx, fmt.Sprintf("section_%d", i+1), section, pages)
+	}
+
+	return skippedPages
+}
+
+// process renders the selected pages and sends them to UniAI. Both stages
+// run concurrently: render workers feed prepared pages through a bounded
+// queue to the request stage, so requests start as soon as the first page is
+// ready, and --workers request workers send them. The queue is bounded both
+// in pages and, with --max-inflight-bytes, in the bytes of the queued images.
+// Pages are rendered in windows of windowSize pages with fresh PDF readers,
+// bounding the memory held by parsed objects. Responses go to the checkpoint
+// as they complete; it returns the skipped pages.
+func (p *pageProcessor) process(ctx context.Context, pageNumbers []int) []renderedPage {
+	size := windowSize
+	if size <= 0 {
+		size = len(pageNumbers)
+	}
+	progress := cli.NewProgress(len(pageNumbers))
+
+	// The queue holds rendered pages waiting for a request; its capacity
+	// applies backpressure on the render workers.
+	queue := make(chan renderedPage, max(renderWorkers, requestWorkers))
+	push := func(page renderedPage) {
+		page.size = pageSize(page)
+		p.budget.Acquire(page.size)
+		queue <- page
+	}
+	enqueue := func(_ int, page renderedPage) {
+		push(page)
+	}
+	if !asCompleted {
+		// Parallel workers finish out of order: buffer early pages so
+		// requests, and their console output, follow the page order. The
+		// budget is acquired in that order too, so a later page can never
+		// hold the bytes the next expected page is waiting for.
+		enqueue = cli.NewReorderer(push).Add
+	}
+
+	go func() {
+		defer close(queue)
+		for start := 0; start < len(pageNumbers); start += size {
+			end := min(start+size, len(pageNumbers))
+			p.renderPages(ctx, pageNumbers[start:end], func(i int, page renderedPage) {
+				enqueue(start+i, page)
+			})
+		}
+	}()
+
+	var (
+		mu           sync.Mutex // guards skippedPages
+		skippedPages []renderedPage
+		batch        []renderedPage // only used by a single worker
+		wg           sync.WaitGroup
+	)
+	for range requestWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for page := range queue {
+				switch {
+				case page.err != nil:
+					p.fail(stageRender, []int{page.pageNum}, page.err)
+					p.progressed(progress, 1)
+				case page.skipped:
+					mu.Lock()
+					skippedPages = append(skippedPages, page)
+					mu.Unlock()
+					p.progressed(progress, 1)
+				case pagesPerRequest > 1:
+					// Batched pages are only held as files: their bytes
+					// are released at once, so the render workers cannot
+					// wait for the pages the batch itself is waiting for.
+					if batch = append(batch, page); len(batch) == pagesPerRequest {
+						p.generateBatch(ctx, batch)
+						p.progressed(progress, len(batch))
+						batch = nil
+					}
+				default:
+					if stage, err := p.generate(ctx, page); err != nil {
+						p.fail(stage, []int{page.pageNum}, err)
+					}
+					p.progressed(progress, 1)
+				}
+				p.budget.Release(page.size)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(batch) > 0 {
+		p.generateBatch(ctx, batch)
+		p.progressed(progress, len(batch))
+	}
+	// Concurrent workers skip pages out of order.
+	slices.SortFunc(skippedPages, func(a, b renderedPage) int { return a.pageNum - b.pageNum })
+
+	return skippedPages
+}
+
+// progressed logs the progress of the run once n more pages completed.
+func (p *pageProcessor) progressed(progress *cli.Progress, n int) {
+	done, total, left := progress.Done(n)
+	if total > 1 {
+		logger.Info("progress", "pages", fmt.Sprintf("%d/%d", done, total), "left", left.Round(time.Second))
+	}
+}
+
+// processDocument sends the selected pages of a converted document, e.g. an
+// email body and its attachments; responses go to the checkpoint.
+func (p *pageProcessor) processDocument(ctx context.Context, doc *cli.Document, pageNumbers []int) {
+	for _, pageNum := range pageNumbers {
+		start := time.Now()
+		docPage := doc.Pages[pageNum-1]
+		page := renderedPage{
+			pageNum: pageNum,
+			text:    docPage.Text,
+		}
+		if docPage.Image != nil {
+			data, err := cli.PreprocessEncoded(docPage.Image, p.settings)
+			if err != nil {
+				p.fail(stageRender, []int{pageNum}, fmt.Errorf("failed to preprocess image of %s: %w", docPage.Name, err))
+				continue
+			}
+			output, err := cli.WritePageImage(pageNum, data, p.out)
+			if err != nil {
+				p.fail(stageRender, []int{pageNum}, fmt.Errorf("failed to save image of %s: %w", docPage.Name, err))
+				continue
+			}
+			page.filePath = output
+			p.record(cli.ArtifactPageImage, output, []int{pageNum}, "")
+		}
+		p.run.Rendered(pageNum, time.Since(start))
+
+		logger.Info("sending document page", "name", docPage.Name, "page", pageNum)
+		if stage, err := p.generate(ctx, page); err != nil {
+			p.fail(stage, []int{pageNum}, err)
+		}
+	}
+}
+
+// pageSize returns the number of bytes a prepared page contributes to a
+// request: its extracted text, rendered image and embedded images.
+func pageSize(page renderedPage) int64 {
+	size := int64(len(page.text))
+	for _, path := range append([]string{page.filePath}, page.images...) {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			size += info.Size()
+		}
+	}
+
+	return size
+}
+
+// renderWindow prepares the given pages and returns one entry per page, in
+// the same order as pageNumbers.
+func (p *pageProcessor) renderWindow(ctx context.Context, pageNumbers []int) []renderedPage {
+	renderedPages := make([]renderedPage, len(pageNumbers))
+	p.renderPages(ctx, pageNumbers, func(i int, page renderedPage) {
+		renderedPages[i] = page
+	})
+
+	return renderedPages
+}
+
+// renderPages prepares the given pages with fresh PDF readers, so parsed
+// objects of previous calls can be garbage collected, and passes every result
+// to emit with its index in pageNumbers. In parallel mode pages are rendered
+// concurrently and emit may be called from several goroutines. Once ctx is
+// canceled, the pages not started yet are emitted with its error.
+func (p *pageProcessor) renderPages(ctx context.Context, pageNumbers []int, emit func(i int, page renderedPage)) {
+	workers := 1
+	if isParallel {
+		workers = renderWorkers
+	}
+
+	// A small pool of readers is shared by the workers: each reader parses
+	// the document once and is then reused for many pages.
+	pool := cli.NewReaderPool(filePath, workers)
+	defer pool.Close()
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, workers) // Semaphore to limit concurrency
+	)
+	for i, pageNum := range pageNumbers {
+		wg.Add(1)
+		sem <- struct{}{} // Acquire a semaphore slot
+		if err := ctx.Err(); err != nil {
+			emit(i, renderedPage{pageNum: pageNum, err: err})
+			<-sem
+			wg.Done()
+			continue
+		}
+		go func(i, pageNum int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := pool.Do(func(reader *model.PdfReader) error {
+				emit(i, p.prepare(reader, pageNum))
+				return nil
+			})
+			if err != nil {
+				emit(i, renderedPage{pageNum: pageNum, err: fmt.Errorf("failed to open PDF file: %w", err)})
+			}
+		}(i, pageNum)
+	}
+	wg.Wait()
+}
+
+// filterRelevant sends a low-resolution thumbnail of every page with a
+// relevance question and returns the pages the model considers relevant,
+// together with the skipped ones.
+func (p *pageProcessor) filterRelevant(ctx context.Context, pageNumbers []int) ([]int, []renderedPage) {
+	query := relevanceQuery
+	if query == "" {
+		query = prompt
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		logger.Warn("failed to open PDF file for relevance pass, keeping all pages", "err", err)
+		return pageNumbers, nil
+	}
+	defer f.Close()
+
+	pdfReader, err := model.NewPdfReader(f)
+	if err != nil {
+		logger.Warn("failed to open PDF file for relevance pass, keeping all pages", "err", err)
+		return pageNumbers, nil
+	}
+
+	settings := cli.DefaultThumbnailSettings
+	settings.BlurPII = p.settings.BlurPII
+	if thumbWidth > 0 {
+		settings.Width = thumbWidth
+	}
+
+	var (
+		relevant []int
+		skipped  []renderedPage
+	)
+	for i, pageNum := range pageNumbers {
+		if ctx.Err() != nil {
+			// Keep the unchecked pages, they fail in the next stage.
+			relevant = append(relevant, pageNumbers[i:]...)
+			break
+		}
+
+		thumb, err := p.thumbnail(pdfReader, pageNum, settings)
+		if err != nil {
+			// Keep the page rather than silently dropping it.
+			logger.Warn("failed to create thumbnail, keeping page", "page", pageNum, "err", err)
+			relevant = append(relevant, pageNum)
+			continue
+		}
+
+		stream := false
+		answer, err := p.client.GenerateText(ctx, &uniai.GenerateRequest{
+			Model:   uniai.ModelDefault,
+			Prompt:  cli.RelevancePrompt(query),
+			Images:  []uniai.ImageData{thumb},
+			Stream:  &stream,
+			Options: uniai.DefaultOptions,
+		})
+		if err != nil {
+			logger.Warn("failed to check relevance, keeping page", "page", pageNum, "err", err)
+			relevant = append(relevant, pageNum)
+			continue
+		}
+
+		if cli.ParseRelevance(answer) {
+			logger.Info("page is relevant", "page", pageNum)
+			relevant = append(relevant, pageNum)
+		} else {
+			logger.Info("page is not relevant", "page", pageNum)
+			skipped = append(skipped, renderedPage{
+				pageNum: pageNum,
+				skipped: true,
+				reason:  "not relevant",
+			})
+		}
+	}
+
+	return relevant, skipped
+}
+
+// thumbnail renders a page at low resolution and returns the encoded image.
+func (p *pageProcessor) thumbnail(reader *model.PdfReader, pageNum int, settings cli.RenderSettings) ([]byte, error) {
+	page, err := reader.GetPage(pageNum)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := cli.RenderPdfPageImage(page, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return cli.EncodeJpeg(img, settings.Quality)
+}
+
+// prepare extracts or renders a single page. The err of the returned page is
+// set when it could not be prepared.
+func (p *pageProcessor) prepare(reader *model.PdfReader, pageNum int) renderedPage {
+	start := time.Now()
+	defer func() { p.run.Rendered(pageNum, time.Since(start)) }()
+
+	page, err := reader.GetPage(pageNum)
+	if err != nil {
+		return renderedPage{pageNum: pageNum, err: fmt.Errorf("failed to get page: %w", err)}
+	}
+
+	rp := p.preparePage(page, pageNum)
+	if extractImages && rp.err == nil && !rp.skipped {
+		rp.images = p.saveEmbeddedImages(page, pageNum)
+	}
+
+	if rp.filePath != "" {
+		p.record(cli.ArtifactPageImage, rp.filePath, []int{pageNum}, "")
+	}
+	for _, path := range rp.images {
+		p.record(cli.ArtifactEmbeddedImage, path, []int{pageNum}, "")
+	}
+
+	return rp
+}
+
+// saveEmbeddedImages writes the embedded images of a page to the output
+// directory and returns their paths.
+func (p *pageProcessor) saveEmbeddedImages(page *model.PdfPage, pageNum int) []string {
+	images, err := cli.ExtractPageImages(page, minImageSize)
+	if err != nil {
+		logger.Warn("failed to extract embedded images", "page", pageNum, "err", err)
+		return nil
+	}
+
+	var paths []string
+	for i, img := range images {
+		data, err := cli.EncodeJpeg(img, p.settings.Quality)
+		if err != nil {
+			logger.Warn("failed to encode embedded image", "page", pageNum, "err", err)
+			continue
+		}
+
+		path := cli.EmbeddedImagePath(p.out, pageNum, i)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			logger.Warn("failed to save embedded image", "page", pageNum, "err", err)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	if len(paths) > 0 {
+		logger.Info("extracted embedded images", "page", pageNum, "count", len(paths))
+	}
+
+	return paths
+}
+
+// pageSettings returns the render settings of a page.
+func (p *pageProcessor) pageSettings(pageNum int) cli.RenderSettings {
+	settings := p.settings
+	if crop, ok := p.crops[pageNum]; ok {
+		settings.Crop = &crop
+	}
+
+	return settings
+}
+
+// preparePage extracts the text layer or renders the page.
+func (p *pageProcessor) preparePage(page *model.PdfPage, pageNum int) renderedPage {
+	settings := p.pageSettings(pageNum)
+
+	if textFirst {
+		// In text mode any text layer is used; scanned pages have none.
+		minChars := minTextChars
+		if pageMode == cli.PageModeText {
+			minChars = 1
+		}
+		text, err := cli.ExtractPageText(page)
+		if err != nil {
+			logger.Warn("failed to extract text layer", "page", pageNum, "err", err)
+		} else if cli.HasUsableText(text, minChars) {
+			logger.Info("extracted text layer", "page", pageNum)
+			return renderedPage{
+				pageNum: pageNum,
+				text:    text,
+			}
+		}
+	}
+
+	var cacheKey string
+	if p.cache != nil {
+		cacheKey = p.cache.Key(p.fileHash, pageNum, settings)
+		if _, ok := p.cache.Get(cacheKey); ok {
+			data, err := p.cache.Load(cacheKey)
+			var output string
+			if err == nil {
+				output, err = cli.WritePageImage(pageNum, data, p.out)
+			}
+			if err != nil {
+				logger.Warn("failed to restore cached page", "page", pageNum, "err", err)
+			} else {
+				if skipBlank {
+					img, err := cli.LoadPageImage(output)
+					if err == nil && cli.IsBlankImage(img, blankThreshold) {
+						logger.Info("skipping blank page", "page", pageNum)
+						return renderedPage{
+							pageNum: pageNum,
+							skipped: true,
+							reason:  "blank page",
+						}
+					}
+				}
+				logger.Info("using cached render", "page", pageNum, "path", output)
+				return renderedPage{
+					pageNum:  pageNum,
+					filePath: output,
+				}
+			}
+		}
+	}
+
+	// Render the page to an image
+	img, err := cli.RenderPdfPageImage(page, settings)
+	if err != nil {
+		return renderedPage{pageNum: pageNum, err: fmt.Errorf("failed to render page: %w", err)}
+	}
+
+	if skipBlank && cli.IsBlankImage(img, blankThreshold) {
+		logger.Info("skipping blank page", "page", pageNum)
+		return renderedPage{
+			pageNum: pageNum,
+			skipped: true,
+			reason:  "blank page",
+		}
+	}
+
+	output, err := cli.SavePageImage(pageNum, img, p.out, settings)
+	if err != nil {
+		return renderedPage{pageNum: pageNum, err: fmt.Errorf("failed to save page: %w", err)}
+	}
+	if p.cache != nil {
+		if err := p.cache.Put(cacheKey, output); err != nil {
+			logger.Warn("failed to cache page", "page", pageNum, "err", err)
+		}
+	}
+	logger.Info("rendered page", "page", pageNum, "path", output)
+
+	return renderedPage{
+		pageNum:  pageNum,
+		filePath: output,
+	}
+}
+
+// generate sends a prepared page to UniAI, streams the response and adds it
+// to the checkpoint. If the page failed, it returns the stage it failed in
+// and the error.
+func (p *pageProcessor) generate(ctx context.Context, page renderedPage) (string, error) {
+	in, err := p.pageInputs(ctx, page)
+	if err != nil {
+		return stageRead, err
+	}
+
+	pagePrompt := p.basePrompt() + in.hint
+	if in.text != "" {
+		pagePrompt = cli.TextPrompt(pagePrompt, in.text)
+	}
+
+	name := fmt.Sprintf("page_%d", page.pageNum)
+	response, err := p.request(ctx, name, []int{page.pageNum}, pagePrompt, in.text, in.images)
+	if err != nil {
+		return stageGenerate, err
+	}
+	p.checkpointed(name, []int{page.pageNum}, response)
+
+	return "", nil
+}
+
+// generateSection sends all pages of a section, named name, in a single
+// request, recording the pages that failed. A request exceeding
+// --max-request-bytes even with downscaled images is split in two halves.
+func (p *pageProcessor) generateSection(ctx context.Context, name string, section cli.Section, pages []renderedPage) {
+	var (
+		texts    []string
+		hints    []string
+		images   []uniai.ImageData
+		pageNums []int
+		read     []renderedPage
+	)
+	for _, page := range pages {
+		in, err := p.pageInputs(ctx, page)
+		if err != nil {
+			p.fail(stageRead, []int{page.pageNum}, err)
+			continue
+		}
+		pageNums = append(pageNums, page.pageNum)
+		read = append(read, page)
+		if in.text != "" {
+			texts = append(texts, fmt.Sprintf("Page %d:\n%s", page.pageNum, strings.TrimSpace(in.text)))
+		}
+		if in.hint != "" {
+			hints = append(hints, fmt.Sprintf("Page %d:%s", page.pageNum, in.hint))
+		}
+		images = append(images, in.images...)
+	}
+	if len(texts) == 0 && len(images) == 0 {
+		return
+	}
+
+	sectionPrompt := cli.SectionPrompt(p.basePrompt(), section)
+	for _, hint := range hints {
+		sectionPrompt += "\n\n" + hint
+	}
+	if len(texts) > 0 {
+		sectionPrompt = cli.TextPrompt(sectionPrompt, strings.Join(texts, "\n\n"))
+	}
+
+	logger.Info("sending section", "request", name, "title", section.Title, "pages", pageNums)
+	response, err := p.request(ctx, name, pageNums, sectionPrompt, strings.Join(texts, "\n\n"), images)
+	if err == nil {
+		p.checkpointed(name, pageNums, response)
+		return
+	}
+	if errors.Is(err, cli.ErrRequestTooLarge) && len(read) > 1 {
+		logger.Warn("splitting request", "request", name, "reason", err)
+		half := len(read) / 2
+		p.generateSection(ctx, name+"_1", section, read[:half])
+		p.generateSection(ctx, name+"_2", section, read[half:])
+		return
+	}
+	p.fail(stageGenerate, pageNums, err)
+}
+
+// generateBatch sends consecutive pages in a single request with
+// --pages-per-request, recording the pages that failed. As with sections, a
+// request exceeding --max-request-bytes even with downscaled images is split
+// in two halves.
+func (p *pageProcessor) generateBatch(ctx context.Context, pages []renderedPage) {
+	var (
+		texts      []string
+		hints      []string
+		images     []uniai.ImageData
+		imagePages []int
+		pageNums   []int
+		read       []renderedPage
+	)
+	for _, page := range pages {
+		in, err := p.pageInputs(ctx, page)
+		if err != nil {
+			p.fail(stageRead, []int{page.pageNum}, err)
+			continue
+		}
+		pageNums = append(pageNums, page.pageNum)
+		read = append(read, page)
+		if in.text != "" {
+			texts = append(texts, fmt.Sprintf("Page %d:\n%s", page.pageNum, strings.TrimSpace(in.text)))
+		}
+		if in.hint != "" {
+			hints = append(hints, fmt.Sprintf("Page %d:%s", page.pageNum, in.hint))
+		}
+		images = append(images, in.images...)
+		for range in.images {
+			imagePages = append(imagePages, page.pageNum)
+		}
+	}
+	if len(texts) == 0 && len(images) == 0 {
+		return
+	}
+
+	batchPrompt := cli.BatchPrompt(p.basePrompt(), pageNums, imagePages)
+	for _, hint := range hints {
+		batchPrompt += "\n\n" + hint
+	}
+	if len(texts) > 0 {
+		batchPrompt = cli.TextPrompt(batchPrompt, strings.Join(texts, "\n\n"))
+	}
+
+	name := "pages_" + cli.FormatPageRange(pageNums)
+	logger.Info("sending pages in one request", "request", name, "pages", pageNums)
+	response, err := p.request(ctx, name, pageNums, batchPrompt, strings.Join(texts, "\n\n"), images)
+	if err == nil {
+		p.checkpointed(name, pageNums, response)
+		return
+	}
+	if errors.Is(err, cli.ErrRequestTooLarge) && len(read) > 1 {
+		logger.Warn("splitting request", "request", name, "reason", err)
+		half := len(read) / 2
+		p.generateBatch(ctx, read[:half])
+		p.generateBatch(ctx, read[half:])
+		return
+	}
+	p.fail(stageGenerate, pageNums, err)
+}
+
+// checkpointed adds the response to request name, about pages, to the
+// checkpoint.
+func (p *pageProcessor) checkpointed(name string, pages []int, response string) {
+	if err := p.checkpoint.Add(name, pages, response); err != nil {
+		logger.Warn("failed to write checkpoint", "request", name, "err", err)
+	}
+}
+
+// basePrompt returns the user prompt with the instructions implied by the
+// output flags.
+func (p *pageProcessor) basePrompt() string {
+	base := prompt
+	if markdownOut {
+		base += "\n\n" + cli.MarkdownPrompt
+	}
+	if ocrPositions {
+		base += "\n\n" + cli.OcrPositionsPrompt
+	}
+	if jsonOut {
+		// The schema was encoded when the run started.
+		instructions, _ := uniai.JSONInstructions(p.schema)
+		base += "\n\n" + instructions
+	}
+
+	return base
+}
+
+// pageInput is what a prepared page contributes to a request.
+type pageInput struct {
+	text   string // extracted text layer, if the page is sent as text
+	hint   string // prompt addition describing the images
+	images []uniai.ImageData
+}
+
+// pageInputs returns the extracted text and the images of a prepared page.
+func (p *pageProcessor) pageInputs(ctx context.Context, page renderedPage) (pageInput, error) {
+	in := pageInput{text: page.text}
+	if page.text != "" {
+		logger.Info("sending text layer", "page", page.pageNum)
+	} else {
+		logger.Info("sending rendered page", "page", page.pageNum, "path", page.filePath)
+		fb, err := os.ReadFile(page.filePath)
+		if err != nil {
+			return pageInput{}, fmt.Errorf("failed to read page image: %w", err)
+		}
+		if p.hooks.HasImagePreprocessors() {
+			fb, err = p.hooks.PreprocessImage(ctx, page.pageNum, fb)
+			if err != nil {
+				return pageInput{}, fmt.Errorf("failed to preprocess page: %w", err)
+			}
+		}
+
+		hint, images, err := cli.ApplyLayout(fb, layoutMode, p.settings.Quality)
+		if err != nil {
+			logger.Warn("failed to analyse layout", "page", page.pageNum, "err", err)
+			hint, images = "", [][]byte{fb}
+		}
+		if splitLongPages && len(images) == 1 {
+			tilesHint, tiles, err := cli.SplitTall(images[0], p.settings.Quality)
+			if err != nil {
+				logger.Warn("failed to split page", "page", page.pageNum, "err", err)
+			} else if len(tiles) > 1 {
+				logger.Info("splitting tall page", "page", page.pageNum, "tiles", len(tiles))
+				hint, images = hint+tilesHint, tiles
+			}
+		}
+		in.hint = hint
+		for _, img := range images {
+			in.images = append(in.images, img)
+		}
+	}
+
+	for _, path := range page.images {
+		fb, err := os.ReadFile(path)
+		if err != nil {
+			logger.Warn("failed to read embedded image", "page", page.pageNum, "path", path, "err", err)
+			continue
+		}
+		in.images = append(in.images, fb)
+	}
+
+	return in, nil
+}
+
+// responseSink is where the response to a request is written: the console,
+// the response file with --write-response, or both with --print-response.
+type responseSink struct {
+	io.Writer
+	console  io.Writer       // nil if the response is not printed
+	renderer consoleRenderer // renders the console output with --pretty or --render-markdown
+	file     *os.File        // nil unless --write-response is set, or once closed
+	path     string          // path of the response file, if any
+}
+
+// consoleRenderer formats responses printed to the terminal once they are
+// complete.
+type consoleRenderer interface {
+	io.Writer
+	Flush() error
+}
+
+// openSink returns the sink of the response to request name. It must be
+// closed once the response is complete.
+func (p *pageProcessor) openSink(name string) (*responseSink, error) {
+	if !writeResponse {
+		s := &responseSink{}
+		s.setConsole(os.Stderr)
+		s.Writer = s.console
+		return s, nil
+	}
+
+	dir := filepath.Join(p.out.Dir, "response")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create response directory: %w", err)
+	}
+	ext := ".txt"
+	if jsonOut {
+		ext = ".json"
+	}
+	path := cli.OutputDir{Dir: dir, Name: p.out.Name, Flat: p.out.Flat}.Path(name + ext)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create response file: %w", err)
+	}
+
+	s := &responseSink{Writer: f, file: f, path: path}
+	if printResponse {
+		s.setConsole(os.Stderr)
+		s.Writer = io.MultiWriter(s.Writer, s.console)
+	}
+
+	return s, nil
+}
+
+// setConsole prints the response to w: highlighted with --pretty if w is a
+// terminal, rendered as Markdown with --render-markdown, once complete with
+// several --workers, or as is.
+func (s *responseSink) setConsole(w *os.File) {
+	switch {
+	case prettyJSON && cli.IsTerminal(w):
+		s.renderer = cli.NewJSONWriter(w)
+	case renderMarkdown:
+		s.renderer = cli.NewMarkdownWriter(w)
+	case requestWorkers > 1:
+		s.renderer = &bufferedConsole{w: w}
+	default:
+		s.console = w
+		return
+	}
+	s.console = s.renderer
+}
+
+// consoleMu serializes the responses printed by a bufferedConsole, and the
+// metrics printed after the responses of the other sinks.
+var consoleMu sync.Mutex
+
+// bufferedConsole holds a response until it is complete, so that the
+// responses of concurrent requests are printed one after the other.
+type bufferedConsole struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (c *bufferedConsole) Write(p []byte) (int, error) {
+	return c.buf.Write(p)
+}
+
+// Flush prints the response held so far.
+func (c *bufferedConsole) Flush() error {
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+
+	_, err := c.buf.WriteTo(c.w)
+
+	return err
+}
+
+// Flush prints the rest of the rendered response once it is complete.
+func (s *responseSink) Flush() {
+	if s.renderer == nil {
+		return
+	}
+	if err := s.renderer.Flush(); errors.Is(err, cli.ErrInvalidJSON) {
+		logger.Warn("the response is not valid JSON, printed as is")
+	}
+}
+
+// Done prints the rest of the complete response, as Flush does, and the
+// metrics of its request after it. With several --workers, they are printed
+// together, between the responses of the other requests.
+func (s *responseSink) Done(m *uniai.Metrics) {
+	if c, ok := s.renderer.(*bufferedConsole); ok {
+		m.WriteSummary(c)
+		s.Flush()
+		return
+	}
+
+	s.Flush()
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+	m.WriteSummary(os.Stderr)
+}
+
+// Replace replaces the response written so far, e.g. the raw stream, by
+// response.
+func (s *responseSink) Replace(response string) error {
+	if s.console != nil {
+		fmt.Fprintln(s.console, response)
+		s.Flush()
+	}
+	if s.file == nil {
+		return nil
+	}
+
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to write response file: %w", err)
+	}
+	if err := s.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to write response file: %w", err)
+	}
+	if _, err := fmt.Fprintln(s.file, response); err != nil {
+		return fmt.Errorf("failed to write response file: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the response file, if any. Closing a sink again does nothing.
+func (s *responseSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+
+	return err
+}
+
+// send streams a Generate request and returns the complete response. name
+// identifies the request in messages and response files, pages are the
+// source pages of the request. text is the text layer of the pages, used with
+// the images by the local OCR.
+func (p *pageProcessor) send(ctx context.Context, name string, pages []int, requestPrompt, text string, images []uniai.ImageData) (string, error) {
+	if localOCR == cli.LocalOCROnly {
+		return p.ocrLocally(ctx, name, pages, text, images)
+	}
+	pageImages := images
+
+	requestPrompt = p.pageContext.Prompt(requestPrompt)
+	requestPrompt += p.attachmentContext
+	images = append(images, p.attachmentImages...)
+	if p.pii != nil {
+		requestPrompt = p.pii.Scrub(requestPrompt)
+	}
+
+	sink, err := p.openSink(name)
+	if err != nil {
+		return "", err
+	}
+	defer sink.Close()
+
+	client, model := p.client, p.model
+	if p.comparison == nil {
+		input := cli.InputText
+		if len(images) > 0 {
+			input = cli.InputImage
+		}
+		client, model, err = p.router.route(input, cli.DetectLanguage(requestPrompt), p.pages, client, model)
+		if err != nil {
+			return "", fmt.Errorf("failed to route request: %w", err)
+		}
+		if model != p.model || client != p.client {
+			logger.Info("routing request", "request", name, "model", model)
+		}
+	}
+	if model == p.model && p.fallback.Active() {
+		model = p.fallback.Model
+	}
+
+	requestGen := uniai.GenerateRequest{
+		Model:   model,
+		Prompt:  requestPrompt,
+		Images:  images,
+		System:  p.system,
+		Format:  p.format,
+		Options: p.options,
+		Filters: p.filters,
+	}
+
+	before, after, err := cli.FitRequest(&requestGen, p.maxRequest, p.settings.Quality)
+	if err != nil {
+		return "", err
+	}
+	if after < before {
+		logger.Warn("request too large, downscaled its images", "request", name, "size", before, "limit", p.maxRequest, "downscaled", after)
+	}
+	if err := p.compress(ctx, name, client, &requestGen); err != nil {
+		return "", err
+	}
+	requestPrompt = requestGen.Prompt
+
+	// The prompt tokens are estimated before sending, so the spend limit
+	// stops a request that would exceed it, the first one included.
+	estimate := uniai.EstimateRequestTokens(&requestGen)
+	logger.Debug("sending request", "request", name, "prompt", requestPrompt, "system", requestGen.System, "estimated_tokens", estimate)
+	if sink.path != "" {
+		logger.Info("writing response to file", "request", name, "path", sink.path)
+	}
+
+	info := cli.RequestInfo{
+		Name:       name,
+		Pages:      pages,
+		Model:      model,
+		PromptHash: cli.HashBytes([]byte(requestPrompt)),
+		StartedAt:  time.Now().UTC(),
+	}
+	var response strings.Builder
+	funcResp := func(resp uniai.GenerateResponse) error {
+		// Handle the response from UniAI.
+		// For example, you could print the response or save it to a file.
+		response.WriteString(resp.Response)
+		fmt.Fprint(sink, resp.Response)
+		if resp.Done {
+			fmt.Fprintln(sink)
+			sink.Done(&resp.Metrics)
+			info.PromptTokens, info.CompletionTokens = resp.PromptEvalCount, resp.EvalCount
+		}
+
+		return nil
+	}
+
+	key := p.responses.Key(&requestGen)
+	if cached, ok := p.responses.Get(key); ok && !force {
+		logger.Info("reusing the response of a previous run", "request", name)
+		response.WriteString(cached)
+		fmt.Fprintln(sink, cached)
+		sink.Flush()
+		info.Cached = true
+	} else if !runSpend.Allow(model, estimate) {
+		tokens, cost := runSpend.Spent()
+		p.run.Stop("budget", pages)
+		logger.Warn("not sending request", "request", name, "reason", errBudgetExhausted, "tokens", tokens, "cost", cost)
+		return "", errBudgetExhausted
+	} else {
+		var err error
+		info.Retries, err = p.generateRequest(ctx, name, client, &requestGen, funcResp, &response)
+		sink.Flush()
+		info.Duration = time.Since(info.StartedAt)
+		if p.fallback != nil && requestGen.Model == p.fallback.Model && requestGen.Model != p.model {
+			info.Model, info.Fallback = requestGen.Model, true
+		}
+		if err != nil && localOCR == cli.LocalOCRFallback && cli.Unreachable(err) {
+			logger.Warn("the API is unreachable, falling back to the local OCR", "request", name, "err", err)
+			return p.ocrLocally(ctx, name, pages, text, pageImages)
+		}
+		if err != nil {
+			info.Error = err.Error()
+			p.run.Add(info)
+			p.compare(ctx, name, pages, requestGen, cli.ModelOutput{Model: p.model, Duration: info.Duration, Error: info.Error})
+			return "", fmt.Errorf("failed to generate response: %w", err)
+		}
+		if err := p.responses.Put(p.responses.Key(&requestGen), response.String()); err != nil {
+			logger.Warn("failed to cache response", "request", name, "err", err)
+		}
+	}
+	p.run.Add(info)
+
+	result := client.FilterResponse(&requestGen, response.String())
+	if p.pii != nil && restorePII {
+		result = p.pii.Restore(result)
+	}
+	if p.hooks.HasResponsePostprocessors() {
+		var err error
+		result, err = p.hooks.PostprocessResponse(ctx, pages, requestPrompt, result)
+		if err != nil {
+			return "", fmt.Errorf("failed to postprocess response: %w", err)
+		}
+	}
+
+	if jsonOut {
+		result, err = p.parseJSON(result)
+		if err != nil {
+			// The invalid answer is not reused: the next run, or the
+			// request asking again with the same prompt, gets a new one.
+			if err := p.responses.Delete(p.responses.Key(&requestGen)); err != nil {
+				logger.Warn("failed to remove cached response", "request", name, "err", err)
+			}
+			return "", err
+		}
+	}
+
+	if result != response.String() {
+		logger.Info("postprocessed response", "request", name)
+		if err := sink.Replace(result); err != nil {
+			return "", err
+		}
+	}
+
+	if sink.path != "" {
+		if err := sink.Close(); err != nil {
+			return "", fmt.Errorf("failed to write response file: %w", err)
+		}
+		p.record(cli.ArtifactResponse, sink.path, pages, info.PromptHash)
+		if info.Fallback {
+			p.manifest.SetModel(sink.path, info.Model)
+		}
+	}
+
+	p.addExample(pages, requestGen.System, requestPrompt, len(pageImages) > 0, result)
+	p.crossCheck(ctx, name, pages, text, pageImages, result)
+	p.compare(ctx, name, pages, requestGen, cli.ModelOutput{
+		Model:            p.model,
+		Response:         result,
+		Duration:         info.Duration,
+		PromptTokens:     info.PromptTokens,
+		CompletionTokens: info.CompletionTokens,
+		Cached:           info.Cached,
+	})
+	p.updateContext(ctx, name, pages, result)
+
+	return result, nil
+}
+
+// compress shrinks the prompt of req if it exceeds the context window of the
+// model, keeping the base prompt, and logs what was left out.
+func (p *pageProcessor) compress(ctx context.Context, name string, client *uniai.Client, req *uniai.GenerateRequest) error {
+	if p.compressor == nil {
+		return nil
+	}
+	compressor := *p.compressor
+	compressor.Client = client
+
+	compression, err := compressor.Compress(ctx, req, p.basePrompt())
+	if err != nil || compression == nil {
+		return err
+	}
+	logger.Warn("prompt exceeds the context window, compressed it", "request", name, "mode", compressor.Mode,
+		"estimated_tokens", compression.Before, "compressed", compression.After,
+		"dropped_paragraphs", len(compression.Dropped), "dropped_chars", compression.DroppedChars())
+	for _, dropped := range compression.Dropped {
+		logger.Debug("left out of the prompt", "request", name, "text", dropped)
+	}
+
+	return nil
+}
+
+// updateContext makes the result of request name, about pages, the context
+// of the next request with --page-context, merged into the rolling summary
+// in summary mode. A summary that fails to update is kept as it was.
+func (p *pageProcessor) updateContext(ctx context.Context, name string, pages []int, result string) {
+	if !p.pageContext.Summarizes() {
+		p.pageContext.Update(pages, result)
+		return
+	}
+
+	summaryPrompt := p.pageContext.SummaryPrompt(pages, result)
+	if p.pii != nil {
+		summaryPrompt = p.pii.Scrub(summaryPrompt)
+	}
+	stream := false
+	summary, err := p.client.GenerateText(ctx, &uniai.GenerateRequest{
+		Model:   p.model,
+		Prompt:  summaryPrompt,
+		Stream:  &stream,
+		Options: uniai.DefaultOptions,
+	})
+	if err != nil {
+		logger.Warn("failed to update the page context summary", "request", name, "err", err)
+		return
+	}
+	p.pageContext.Update(pages, summary)
+}
+
+// generateRequest sends req with client once the pacer allows it. A request
+// the API rate limits is sent again, after slowing down the pacer for the
+// rest of the run, rather than failing its pages. Once the model is
+// overloaded, the request and the rest of the run are sent to the fallback
+// model, if any. response collects the streamed chunks and is reset before
+// every new attempt. It returns the number of retries.
+func (p *pageProcessor) generateRequest(ctx context.Context, name string, client *uniai.Client, req *uniai.GenerateRequest, fn uniai.GenerateResponseFunc, response *strings.Builder) (int, error) {
+	for attempt := 1; ; attempt++ {
+		if err := p.pacer.Wait(ctx); err != nil {
+			return attempt - 1, err
+		}
+
+		response.Reset()
+		err := client.Generate(ctx, req, fn)
+		if req.Model == p.model && p.fallback.Record(err) {
+			logger.Warn("the model is overloaded, falling back to a smaller model for the rest of the run", "request", name, "model", req.Model, "fallback", p.fallback.Model, "err", err)
+		}
+		if req.Model == p.model && p.fallback.Active() && cli.Overloaded(err) {
+			req.Model = p.fallback.Model
+			continue
+		}
+		var limited *uniai.RateLimitError
+		if !errors.As(err, &limited) || attempt == maxRateLimitedAttempts {
+			return attempt - 1, err
+		}
+
+		interval := p.pacer.Throttled(limited.RetryAfter)
+		logger.Warn("rate limited, slowing down requests", "request", name, "attempt", attempt, "retry_after", limited.RetryAfter, "interval", interval)
+	}
+}
+
+// ocrLocally extracts the text of the pages of a request with the local OCR
+// instead of sending them to the API: their text layer, if any, followed by
+// the text of their images.
+func (p *pageProcessor) ocrLocally(ctx context.Context, name string, pages []int, text string, images []uniai.ImageData) (string, error) {
+	logger.Info("extracting text with the local OCR", "request", name)
+
+	info := cli.RequestInfo{
+		Name:      name,
+		Pages:     pages,
+		StartedAt: time.Now().UTC(),
+		Engine:    cli.EngineTesseract,
+	}
+	result, err := localText(ctx, text, images)
+	info.Duration = time.Since(info.StartedAt)
+	if err != nil {
+		info.Error = err.Error()
+		p.run.Add(info)
+		return "", fmt.Errorf("failed to extract text: %w", err)
+	}
+	p.run.Add(info)
+
+	sink, err := p.openSink(name)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(sink, result)
+	if err := sink.Close(); err != nil {
+		return "", fmt.Errorf("failed to write response file: %w", err)
+	}
+	if sink.path != "" {
+		p.record(cli.ArtifactLocalOCR, sink.path, pages, "")
+	}
+
+	return result, nil
+}
+
+// localText returns the text layer, if any, followed by the local OCR of the
+// images.
+func localText(ctx context.Context, text string, images []uniai.ImageData) (string, error) {
+	var parts []string
+	if text = strings.TrimSpace(text); text != "" {
+		parts = append(parts, text)
+	}
+	for _, img := range images {
+		out, err := cli.LocalOCR(ctx, img, ocrLanguage)
+		if err != nil {
+			return "", err
+		}
+		if out != "" {
+			parts = append(parts, out)
+		}
+	}
+
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// crossCheck compares the response to a request with the local OCR of its
+// pages when --cross-validate is set.
+func (p *pageProcessor) crossCheck(ctx context.Context, name string, pages []int, text string, images []uniai.ImageData, response string) {
+	if p.crossChecks == nil || (strings.TrimSpace(text) == "" && len(images) == 0) {
+		return
+	}
+
+	local, err := localText(ctx, text, images)
+	if err != nil {
+		p.crossChecks.AddError(name, pages, err)
+		logger.Warn("failed to cross-validate", "request", name, "err", err)
+		return
+	}
+	entry := p.crossChecks.Add(name, pages, response, local)
+	if entry.Flagged {
+		logger.Warn("the response disagrees with the local OCR", "request", name, "similarity", fmt.Sprintf("%.2f", entry.Similarity))
+	}
+}
+
+// compare sends a request to the other models of --models and records their
+// answers next to the answer of the primary model.
+func (p *pageProcessor) compare(ctx context.Context, name string, pages []int, req uniai.GenerateRequest, primary cli.ModelOutput) {
+	if p.comparison == nil {
+		return
+	}
+
+	entry := cli.ComparisonEntry{Name: name, Pages: pages, Outputs: []cli.ModelOutput{primary}}
+	for _, model := range p.comparison.Models[1:] {
+		req.Model = model
+		out := cli.ModelOutput{Model: model}
+
+		key := p.responses.Key(&req)
+		if cached, ok := p.responses.Get(key); ok && !force {
+			out.Response = p.client.FilterResponse(&req, cached)
+			out.Cached = true
+			entry.Outputs = append(entry.Outputs, out)
+			continue
+		}
+
+		var response strings.Builder
+		start := time.Now()
+		err := p.client.Generate(ctx, &req, func(resp uniai.GenerateResponse) error {
+			response.WriteString(resp.Response)
+			if resp.Done {
+				out.PromptTokens, out.CompletionTokens = resp.PromptEvalCount, resp.EvalCount
+			}
+			return nil
+		})
+		out.Duration = time.Since(start)
+		if err != nil {
+			logger.Warn("failed to generate comparison response", "request", name, "model", model, "err", err)
+			out.Error = err.Error()
+		} else {
+			logger.Info("compared response", "request", name, "model", model)
+			if err := p.responses.Put(key, response.String()); err != nil {
+				logger.Warn("failed to cache response", "request", name, "err", err)
+			}
+			out.Response = p.client.FilterResponse(&req, response.String())
+		}
+		entry.Outputs = append(entry.Outputs, out)
+	}
+
+	p.comparison.Add(entry)
+}
+
+// record adds an artifact to the manifest, reporting failures without
+// aborting the run.
+func (p *pageProcessor) record(kind, path string, pages []int, promptHash string) {
+	if err := p.manifest.Add(kind, path, pages, promptHash); err != nil {
+		logger.Warn("failed to record artifact in manifest", "path", path, "err", err)
+	}
+}
+
+// inputPage is a page of a document loaded in memory, either as text or as
+// an image.
+type inputPage struct {
+	num   int
+	text  string
+	image []byte
+}
+
+// pageInput returns the page as an input of the pkg/uniai helpers.
+func (p inputPage) pageInput() uniai.PageInput {
+	in := uniai.PageInput{Number: p.num, Text: p.text}
+	if p.image != nil {
+		in.Images = []uniai.ImageData{p.image}
+	}
+
+	return in
+}
+
+// loadInputPages returns the selected pages of a document, all of them if
+// pageNumbers is empty. PDF pages are rendered unless textFirst is set and
+// they have a usable text layer.
+func loadInputPages(ctx context.Context, file string, pageNumbers []int, textFirst bool) ([]inputPage, error) {
+	return loadInputPagesAt(ctx, file, pageNumbers, textFirst, cli.DefaultRenderSettings)
+}
+
+// loadInputPagesAt is loadInputPages rendering PDF pages with settings.
+func loadInputPagesAt(ctx context.Context, file string, pageNumbers []int, textFirst bool, settings cli.RenderSettings) ([]inputPage, error) {
+	path := file
+	if storage.IsRemote(file) {
+		local, err := storage.Download(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(filepath.Dir(local))
+		path = local
+	}
+
+	if cli.IsConvertible(path) {
+		doc, err := cli.ConvertFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(pageNumbers) == 0 {
+			for i := range doc.Pages {
+				pageNumbers = append(pageNumbers, i+1)
+			}
+		}
+
+		var pages []inputPage
+		for _, pageNum := range pageNumbers {
+			if pageNum < 1 || pageNum > len(doc.Pages) {
+				return nil, fmt.Errorf("page %d out of range", pageNum)
+			}
+			page := doc.Pages[pageNum-1]
+			pages = append(pages, inputPage{num: pageNum, text: page.Text, image: page.Image})
+		}
+		return pages, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader, err := model.NewPdfReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF file: %w", err)
+	}
+	if len(pageNumbers) == 0 {
+		numPages, err := reader.GetNumPages()
+		if err != nil {
+			return nil, err
+		}
+		for i := 1; i <= numPages; i++ {
+			pageNumbers = append(pageNumbers, i)
+		}
+	}
+
+	var pages []inputPage
+	for _, pageNum := range pageNumbers {
+		page, err := reader.GetPage(pageNum)
+		if err != nil {
+			return nil, err
+		}
+
+		if textFirst {
+			text, err := cli.ExtractPageText(page)
+			if err == nil && cli.HasUsableText(text, cli.DefaultMinTextChars) {
+				pages = append(pages, inputPage{num: pageNum, text: text})
+				continue
+			}
+		}
+
+		img, err := cli.RenderPdfPageImage(page, settings)
+		if err != nil {
+			return nil, err
+		}
+		data, err := cli.EncodeJpeg(img, settings.Quality)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, inputPage{num: pageNum, image: data})
+	}
+
+	return pages, nil
+}
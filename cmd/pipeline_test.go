@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// TestResponseSinkDone checks that, with several workers, every response is
+// printed followed by the metrics of its own request.
+func TestResponseSinkDone(t *testing.T) {
+	var out bytes.Buffer
+	const requests = 8
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sink := &responseSink{renderer: &bufferedConsole{w: &out}}
+			sink.console = sink.renderer
+			sink.Writer = sink.console
+			fmt.Fprintf(sink, "answer %d\n", i)
+			sink.Done(&uniai.Metrics{EvalCount: i + 1})
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2*requests {
+		t.Fatalf("%d lines printed, want %d:\n%s", len(lines), 2*requests, out.String())
+	}
+	for i := 0; i < len(lines); i += 2 {
+		var n int
+		if _, err := fmt.Sscanf(lines[i], "answer %d", &n); err != nil {
+			t.Fatalf("line %d is %q, want a response", i+1, lines[i])
+		}
+		if want := fmt.Sprintf("eval count:           %d token(s)", n+1); lines[i+1] != want {
+			t.Errorf("response %d followed by %q, want %q", n, lines[i+1], want)
+		}
+	}
+}
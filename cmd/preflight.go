@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+	"github.com/sampila/uniai-client/pkg/uniai/cassette"
+)
+
+var skipPreflight bool // Flag to indicate if the API should not be checked before the run
+
+// preflight checks that the API of client is reachable, accepts the
+// credentials and serves models, before any page is rendered, and returns
+// the server version. Failures are reported with what to check.
+func preflight(ctx context.Context, client *uniai.Client, models []string) (string, error) {
+	if err := client.Heartbeat(ctx); err != nil && cli.Unreachable(err) {
+		return "", errUnreachable(err)
+	}
+
+	version, err := client.Version(ctx)
+	switch {
+	case err == nil:
+	case cli.Unreachable(err):
+		return "", errUnreachable(err)
+	case rejected(err):
+		return "", errRejected(err)
+	default:
+		logger.Warn("failed to get server version", "err", err)
+	}
+
+	available, err := client.ListModels(ctx)
+	if err != nil {
+		if rejected(err) {
+			return "", errRejected(err)
+		}
+		logger.Warn("failed to list models, not checking them", "err", err)
+		return version, nil
+	}
+
+	var names []string
+	for _, m := range available {
+		names = append(names, m.Name)
+	}
+	for _, model := range models {
+		model = client.ResolveModel(model)
+		if !hasModel(names, model) {
+			return "", fmt.Errorf("model %s is not served by the API; available: %s", model, strings.Join(names, ", "))
+		}
+	}
+
+	return version, nil
+}
+
+func errUnreachable(err error) error {
+	return fmt.Errorf("cannot reach the API, check the base URL (see uniai config) or the base_url of the provider, and the network: %w", err)
+}
+
+func errRejected(err error) error {
+	return fmt.Errorf("the API rejected the credentials, check the auth (see uniai config) or the auth of the provider: %w", err)
+}
+
+// rejected reports whether the API answered err because of the credentials.
+func rejected(err error) bool {
+	var status uniai.StatusError
+
+	return errors.As(err, &status) && (status.StatusCode == http.StatusUnauthorized || status.StatusCode == http.StatusForbidden)
+}
+
+// hasModel reports whether model is one of names, where a name without a tag
+// is the :latest tag.
+func hasModel(names []string, model string) bool {
+	if !strings.Contains(model, ":") {
+		model += ":latest"
+	}
+
+	return slices.ContainsFunc(names, func(name string) bool {
+		if !strings.Contains(name, ":") {
+			name += ":latest"
+		}
+		return name == model
+	})
+}
+
+// preflightEnabled reports whether the run talks to the API, and so should
+// check it first: not with --skip-preflight, --offline or a replayed cassette.
+func preflightEnabled() bool {
+	replay := cassetteFile != "" && cassetteMode == cassette.ModeReplay
+
+	return !skipPreflight && !offline && !replay
+}
+
+func init() {
+	uniaiCmd.Flags().BoolVar(&skipPreflight, "skip-preflight", false, "Do not check the API endpoint, credentials and models before rendering the pages")
+}
@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	providersFile string // Provider configuration, defaults to providers.yaml in the user config directory
+	providerName  string // Provider used instead of the one configured for the command
+	offline       bool   // Flag to indicate if canned responses should be served instead of calling a provider
+	maxRetries    int    // Retries of the requests failing with a transient error
+
+	requestTimeout time.Duration // Time limit of every API request, streamed responses included
+	apiHeaders     []string      // Headers, "Key: Value", set on every API request
+
+	// requestQueue, if set, is shared by the clients to bound the requests
+	// in flight, by priority.
+	requestQueue *uniai.RequestQueue
+)
+
+// newProviderClient returns a client of the provider configured for the
+// command, or of the UniAI API at the base URL and with the auth of the
+// configuration if none is, signing requests with its signing key if set. With --offline, the client
+// serves canned responses instead. The client applies the model aliases and
+// profiles of --model-config, shares the request queue, if any, and retries
+// the requests failing with a transient error up to --retries times. API
+// clients bound their requests with --request-timeout and set the headers of
+// --header.
+func newProviderClient(cmd *cobra.Command) (*uniai.Client, error) {
+	var client *uniai.Client
+	if offline {
+		p, err := cli.NewOfflineProvider()
+		if err != nil {
+			return nil, err
+		}
+		client = uniai.NewProviderClient(p)
+	} else {
+		providers, err := loadProviders()
+		if err != nil {
+			return nil, err
+		}
+		if client, err = providerClient(providers, providerTask(cmd), providerName); err != nil {
+			return nil, err
+		}
+	}
+
+	client.UseQueue(requestQueue)
+	client.UseRetry(retryPolicy())
+
+	return client, useModels(client)
+}
+
+// retryPolicy returns the retry policy of --retries.
+func retryPolicy() uniai.RetryPolicy {
+	policy := uniai.DefaultRetryPolicy
+	policy.Attempts = maxRetries + 1
+
+	return policy
+}
+
+// clientOptions returns the options of the UniAI clients of --request-timeout
+// and --header.
+func clientOptions() ([]uniai.ClientOption, error) {
+	var opts []uniai.ClientOption
+	if requestTimeout > 0 {
+		opts = append(opts, uniai.WithTimeout(requestTimeout))
+	}
+	for _, header := range apiHeaders {
+		key, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid header %q: use 'Key: Value'", header)
+		}
+		opts = append(opts, uniai.WithHeader(strings.TrimSpace(key), strings.TrimSpace(value)))
+	}
+
+	return opts, nil
+}
+
+// loadProviders reads the provider configuration of --providers.
+func loadProviders() (*cli.Providers, error) {
+	path := providersFile
+	if path == "" {
+		var err error
+		if path, err = cli.DefaultProvidersPath(); err != nil {
+			return nil, err
+		}
+	}
+
+	return cli.LoadProviders(path)
+}
+
+// providerClient returns a client of the named provider, or of the provider
+// of task if name is empty.
+func providerClient(providers *cli.Providers, task, name string) (*uniai.Client, error) {
+	name, config, ok, err := providers.For(task, name)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := apiHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	opts, err := clientOptions()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		client, err := uniai.NewClient(apiConfig.Get(cli.SettingBaseURL), httpClient, apiConfig.Get(cli.SettingAuth), opts...)
+		if err != nil {
+			return nil, err
+		}
+		return signClient(client, apiConfig.Get(cli.SettingSigningKey), apiConfig.Get(cli.SettingSignatureHeader))
+	}
+
+	switch config.Type {
+	case cli.ProviderUniAI:
+		baseURL, auth, signingKey, signatureHeader := config.BaseURL, config.Auth, config.SigningKey, config.SignatureHeader
+		if baseURL == "" {
+			baseURL = apiConfig.Get(cli.SettingBaseURL)
+		}
+		if signingKey == "" {
+			signingKey, signatureHeader = apiConfig.Get(cli.SettingSigningKey), apiConfig.Get(cli.SettingSignatureHeader)
+		}
+		if len(config.Keys) == 0 {
+			if auth == "" {
+				auth = apiConfig.Get(cli.SettingAuth)
+			}
+			client, err := uniai.NewClient(baseURL, httpClient, auth, opts...)
+			if err != nil {
+				return nil, err
+			}
+			return signClient(client, signingKey, signatureHeader)
+		}
+
+		keys := make([]uniai.APIKey, len(config.Keys))
+		for i, key := range config.Keys {
+			keys[i] = uniai.APIKey{Auth: key.Auth, RPM: key.RPM}
+		}
+		pool, err := uniai.NewKeyPool(keys, 0)
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", name, err)
+		}
+		client, err := uniai.NewClient(baseURL, httpClient, keys[0].Auth, opts...)
+		if err != nil {
+			return nil, err
+		}
+		client.UseKeys(pool)
+		return signClient(client, signingKey, signatureHeader)
+	}
+
+	return nil, fmt.Errorf("provider %s has an unsupported type %q", name, config.Type)
+}
+
+// signClient makes client sign its requests with HMAC-SHA256 in header if
+// signingKey is set, for API gateways requiring signed requests. Its
+// credentials are masked in the logs either way.
+func signClient(client *uniai.Client, signingKey, header string) (*uniai.Client, error) {
+	client.UseRedactor(redactor)
+	if signingKey == "" {
+		return client, nil
+	}
+
+	signer, err := uniai.NewSigner([]byte(signingKey), header)
+	if err != nil {
+		return nil, err
+	}
+	client.UseSigner(signer)
+
+	return client, nil
+}
+
+// router picks the provider and model of page requests from the routes of
+// the provider configuration. It is safe for concurrent use.
+type router struct {
+	cmd       *cobra.Command
+	document  string
+	providers *cli.Providers
+
+	mu      sync.Mutex
+	clients map[string]*uniai.Client // by provider name
+}
+
+// newRouter returns the router of the page requests of the command about
+// document, or nil if no routes are configured, --provider is set or the run
+// is offline.
+func newRouter(cmd *cobra.Command, document string) (*router, error) {
+	if offline {
+		return nil, nil
+	}
+	providers, err := loadProviders()
+	if err != nil {
+		return nil, err
+	}
+	if len(providers.Routes) == 0 || providerName != "" {
+		return nil, nil
+	}
+
+	return &router{
+		cmd:       cmd,
+		document:  document,
+		providers: providers,
+		clients:   make(map[string]*uniai.Client),
+	}, nil
+}
+
+// route returns the client and model of a page request: those of the first
+// matching route, or client and model if none matches.
+func (r *router) route(input, language string, pages int, client *uniai.Client, model string) (*uniai.Client, string, error) {
+	if r == nil {
+		return client, model, nil
+	}
+
+	route, ok := r.providers.Route(cli.RouteInput{
+		Task:     providerTask(r.cmd),
+		Input:    input,
+		Pages:    pages,
+		Language: language,
+	})
+	if !ok {
+		return client, model, nil
+	}
+	if route.Model != "" {
+		model = route.Model
+	}
+	if route.Provider == "" {
+		return client, model, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.clients[route.Provider]
+	if !ok {
+		var err error
+		if c, err = providerClient(r.providers, "", route.Provider); err != nil {
+			return nil, "", err
+		}
+		c.UseQueue(requestQueue)
+		c.UseRetry(retryPolicy())
+		if err := useModels(c); err != nil {
+			return nil, "", err
+		}
+		if err := recordUsage(r.cmd, c, r.document); err != nil {
+			return nil, "", err
+		}
+		if err := recordAudit(r.cmd, c, r.document); err != nil {
+			return nil, "", err
+		}
+		r.clients[route.Provider] = c
+	}
+
+	return c, model, nil
+}
+
+// providerTask returns the name providers are configured for: the path of the
+// command below uniai, e.g. "classify" or "pipeline run", or "uniai" for the
+// document processing command itself.
+func providerTask(cmd *cobra.Command) string {
+	path := strings.TrimSpace(cmd.CommandPath())
+	if task, ok := strings.CutPrefix(path, "uniai "); ok {
+		return task
+	}
+
+	return path
+}
+
+func init() {
+	uniaiCmd.PersistentFlags().StringVar(&providersFile, "providers", "", "Provider configuration file selecting the AI backend of each command (defaults to "+cli.ProvidersFile+" in the user config directory)")
+	uniaiCmd.PersistentFlags().StringVar(&providerName, "provider", "", "Name of the configured provider to use instead of the one of the command")
+	uniaiCmd.PersistentFlags().IntVar(&maxRetries, "retries", uniai.DefaultRetryPolicy.Attempts-1, "Retries, with exponential backoff, of the requests failing with a 5xx response or a network error")
+	uniaiCmd.PersistentFlags().DurationVar(&requestTimeout, "request-timeout", 0, "Time limit of every API request, streamed responses included; 0 for none")
+	uniaiCmd.PersistentFlags().StringArrayVar(&apiHeaders, "header", nil, "Header, 'Key: Value', set on every API request, e.g. for an API gateway (repeatable)")
+	uniaiCmd.PersistentFlags().BoolVar(&offline, "offline", false, "Answer every request with canned responses of the bundled fixtures, without network or credentials, to try out the CLI")
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/internal/queue"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	queueURL     string      // URL of the job queue
+	queueSpec    cli.JobSpec // Job pushed for every file
+	queueRoot    string      // Directory the files of jobs are read from
+	queueWorkers int         // Number of jobs processed at a time
+	queueWorker  string      // Name of this worker
+	queueJson    bool        // Flag to print the results as JSON
+)
+
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Process documents from a shared job queue",
+	Long: `Queue distributes document jobs between uniai instances through a Redis server,
+so a large backlog can be processed by as many workers as needed:
+
+  uniai queue push -m "Extract the total" s3://bucket/invoices/*.pdf
+  uniai queue work --workers 4      # on every processing host
+  uniai queue results
+
+Every job is taken by a single worker. A worker that is stopped finishes the
+jobs it took; jobs of a worker that crashed are taken again once a worker of
+the same --name starts. The queue is set with --queue or UNIAI_QUEUE, e.g.
+'redis://:password@host:6379/0?name=invoices'.`,
+}
+
+var queuePushCmd = &cobra.Command{
+	Use:   "push <file>...",
+	Short: "Queue a job for every file",
+	Long: `Push queues a job for every file. Files are remote URLs (https://, s3://,
+gs://) or paths relative to the --root of the workers.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if queueSpec.Prompt == "" {
+			println("A prompt is required")
+			return
+		}
+
+		ctx := cmd.Context()
+		q, err := queue.Open(ctx, queueURL)
+		if err != nil {
+			println("Failed to open queue:", err.Error())
+			return
+		}
+		defer q.Close()
+
+		for _, file := range args {
+			spec := queueSpec
+			spec.File = file
+			msg, err := q.Push(ctx, spec)
+			if err != nil {
+				println("Failed to queue", file, ":", err.Error())
+				continue
+			}
+			fmt.Println(msg.ID, file)
+		}
+	},
+}
+
+var queueWorkCmd = &cobra.Command{
+	Use:   "work",
+	Short: "Process jobs from the queue until interrupted",
+	Run: func(cmd *cobra.Command, args []string) {
+		root, err := filepath.Abs(queueRoot)
+		if err != nil {
+			println("Invalid root directory:", err.Error())
+			return
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		var wg sync.WaitGroup
+		for i := range max(queueWorkers, 1) {
+			worker := fmt.Sprintf("%s-%d", queueWorker, i+1)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := work(ctx, cmd, root, worker); err != nil {
+					println("Worker", worker, "stopped:", err.Error())
+				}
+			}()
+		}
+		wg.Wait()
+	},
+}
+
+// work processes jobs as worker until ctx is canceled. A job that was taken
+// is finished even if ctx is canceled meanwhile.
+func work(ctx context.Context, cmd *cobra.Command, root, worker string) error {
+	q, err := queue.Open(ctx, queueURL)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	n, err := q.Requeue(ctx, worker)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		println("Requeued", n, "unfinished job(s) of", worker)
+	}
+
+	for {
+		msg, err := q.Pop(ctx, worker)
+		if err != nil {
+			return err
+		}
+		if msg == nil {
+			return nil
+		}
+
+		// Taken jobs are finished and completed even if ctx is canceled.
+		jobCtx := context.WithoutCancel(ctx)
+		println("Processing job", msg.ID, msg.Spec.File)
+		state := cli.RunJob(jobCtx, msg.ID, msg.Spec, func(ctx context.Context, spec cli.JobSpec, emit func(cli.Event) error) error {
+			return runJob(ctx, cmd, root, spec, emit)
+		})
+		state.SubmittedAt = msg.SubmittedAt
+		if state.Error != "" {
+			println("Job", msg.ID, "failed:", state.Error)
+		}
+
+		if err := q.Complete(jobCtx, worker, msg, state); err != nil {
+			return err
+		}
+	}
+}
+
+var queueResultsCmd = &cobra.Command{
+	Use:   "results",
+	Short: "List the results of processed jobs",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		q, err := queue.Open(ctx, queueURL)
+		if err != nil {
+			println("Failed to open queue:", err.Error())
+			return
+		}
+		defer q.Close()
+
+		results, err := q.Results(ctx)
+		if err != nil {
+			println("Failed to read results:", err.Error())
+			return
+		}
+
+		if queueJson {
+			data, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				println("Failed to encode results:", err.Error())
+				return
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "JOB\tSTATUS\tPAGES\tWORKER\tFINISHED\tERROR")
+		for _, r := range results {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", r.ID, r.Status, len(r.Results), r.Worker, r.FinishedAt.Local().Format("2006-01-02 15:04:05"), r.Error)
+		}
+		tw.Flush()
+	},
+}
+
+func init() {
+	defaultQueue := os.Getenv("UNIAI_QUEUE")
+	if defaultQueue == "" {
+		defaultQueue = "redis://127.0.0.1:6379"
+	}
+	hostname, _ := os.Hostname()
+
+	queueCmd.PersistentFlags().StringVar(&queueURL, "queue", defaultQueue, "URL of the job queue (redis:// or rediss://, with an optional ?name= of the queue)")
+
+	queuePushCmd.Flags().StringVarP(&queueSpec.Prompt, "prompt", "m", "", "Prompt for the model")
+	queuePushCmd.Flags().StringVarP(&queueSpec.Pages, "pages", "r", "", "Page range to process (all pages by default)")
+	queuePushCmd.Flags().BoolVar(&queueSpec.TextFirst, "text-first", false, "Send pages with an extractable text layer as text instead of rendering them")
+	queuePushCmd.Flags().StringVar(&queueSpec.Model, "model", uniai.ModelDefault, "Model to use")
+	queuePushCmd.Flags().StringVar(&queueSpec.System, "system", "", "System prompt")
+
+	queueWorkCmd.Flags().StringVar(&queueRoot, "root", ".", "Directory local files of jobs are read from")
+	queueWorkCmd.Flags().IntVar(&queueWorkers, "workers", 1, "Number of jobs processed at a time")
+	queueWorkCmd.Flags().StringVar(&queueWorker, "name", hostname, "Name of this worker, used to take back its unfinished jobs after a crash")
+
+	queueResultsCmd.Flags().BoolVar(&queueJson, "json", false, "Print the results as JSON")
+
+	queueCmd.AddCommand(queuePushCmd, queueWorkCmd, queueResultsCmd)
+	uniaiCmd.AddCommand(queueCmd)
+}
@@ -0,0 +1,349 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/unidoc/unipdf/v4/model"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/internal/storage"
+	"github.com/sampila/uniai-client/pkg/rag"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	ragStoreDir  string   // Directory of the RAG vector store
+	indexFiles   []string // Files to index
+	chunkSize    int      // Maximum chunk length in characters
+	chunkOverlap int      // Characters shared by consecutive chunks
+	embedModel   string   // Model used for embeddings
+	indexOcr     bool     // Flag to indicate if pages without text layer should be transcribed by the model
+	topK         int      // Number of chunks retrieved per question
+	listSources  bool     // Flag to indicate if the indexed sources should be listed instead of answering
+	embedCache   bool     // Flag to indicate if embeddings of unchanged chunks should be reused
+)
+
+// defaultRagStoreDir is the store used when --store is not set.
+const defaultRagStoreDir = "./rag"
+
+// ocrTranscribePrompt asks the model for the text of a page image when it has
+// no text layer.
+const ocrTranscribePrompt = "Transcribe all text on this page in reading order. Answer with the text only."
+
+var indexCmd = &cobra.Command{
+	Use:   "index [file or directory...]",
+	Short: "Index documents for retrieval-augmented questions",
+	Long: `Index splits documents into chunks, embeds them with the UniAI model and stores
+them in a local vector store, so they can be queried with "uniai ask" or
+"uniai query".
+Directories are searched recursively for the documents 'uniai batch' reads.
+Indexing a document again replaces its previous chunks; only chunks whose text
+changed are embedded again, the others are read from the embedding cache of
+the store.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		files, err := indexableFiles(append(indexFiles, args...))
+		if err != nil {
+			println("Failed to list documents:", err.Error())
+			return
+		}
+		if len(files) == 0 {
+			cmd.Help()
+			return
+		}
+
+		client, err := newClient(cmd, "")
+		if err != nil {
+			println("Failed to initialize UniAI client:", err.Error())
+			return
+		}
+
+		store, err := rag.OpenStore(ragStoreDir)
+		if err != nil {
+			println("Failed to open store:", err.Error())
+			return
+		}
+
+		r := rag.New(client, store, rag.Options{
+			EmbedModel:   embedModel,
+			ChunkSize:    chunkSize,
+			ChunkOverlap: chunkOverlap,
+		})
+
+		var cache *rag.EmbeddingCache
+		if embedCache {
+			cache, err = rag.OpenEmbeddingCache(ragStoreDir)
+			if err != nil {
+				println("Failed to open embedding cache:", err.Error())
+				return
+			}
+			r.UseCache(cache)
+		}
+
+		ctx := cmd.Context()
+		for _, file := range files {
+			pages, err := documentPages(ctx, client, file)
+			if err != nil {
+				println("Failed to read", file, ":", err.Error())
+				continue
+			}
+
+			var hits int
+			if cache != nil {
+				hits, _ = cache.Stats()
+			}
+			n, err := r.Index(ctx, file, pages)
+			if err != nil {
+				println("Failed to index", file, ":", err.Error())
+				continue
+			}
+			if cache != nil {
+				total, _ := cache.Stats()
+				println("Indexed", n, "chunk(s) of", file, "-", total-hits, "reused from the embedding cache")
+			} else {
+				println("Indexed", n, "chunk(s) of", file)
+			}
+
+			// Save after every document, so an interrupted run keeps its
+			// progress.
+			if err := store.Save(); err != nil {
+				println("Failed to save store:", err.Error())
+				return
+			}
+			if cache != nil {
+				if err := cache.Save(); err != nil {
+					println("Failed to save embedding cache:", err.Error())
+				}
+			}
+		}
+
+		// Drop the embeddings of chunks replaced during this run.
+		if cache != nil && cache.Prune(store) > 0 {
+			if err := cache.Save(); err != nil {
+				println("Failed to save embedding cache:", err.Error())
+			}
+		}
+	},
+}
+
+var queryCmd = &cobra.Command{
+	Use:   "query [question]",
+	Short: "Answer a question from the indexed documents",
+	Long: `Query retrieves the chunks of the indexed documents most similar to the question
+and asks the UniAI model to answer from them, citing the passages it used.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := rag.OpenStore(ragStoreDir)
+		if err != nil {
+			println("Failed to open store:", err.Error())
+			return
+		}
+
+		if listSources {
+			sources := store.Sources()
+			names := make([]string, 0, len(sources))
+			for name := range sources {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("%s\t%d chunk(s)\n", name, sources[name])
+			}
+			return
+		}
+
+		question := strings.TrimSpace(strings.Join(args, " "))
+		if question == "" {
+			cmd.Help()
+			return
+		}
+
+		client, err := newClient(cmd, "")
+		if err != nil {
+			println("Failed to initialize UniAI client:", err.Error())
+			return
+		}
+
+		answer, err := answerFromIndex(cmd.Context(), client, store, "", question, false)
+		if err != nil {
+			println("Failed to answer question:", err.Error())
+			return
+		}
+		printSources(answer)
+	},
+}
+
+// answerFromIndex answers question from the --top-k passages of store most
+// similar to it, with model or the default one if empty. The answer is
+// printed as it streams unless quiet.
+func answerFromIndex(ctx context.Context, client *uniai.Client, store *rag.Store, model, question string, quiet bool) (*rag.Answer, error) {
+	// Questions are embedded with the model the store was built with.
+	r := rag.New(client, store, rag.Options{EmbedModel: store.Model, Model: model, TopK: topK})
+
+	md := cli.NewMarkdownWriter(os.Stdout)
+	answer, err := r.Query(ctx, question, func(text string) {
+		switch {
+		case quiet:
+		case renderMarkdown:
+			md.Write([]byte(text))
+		default:
+			fmt.Print(text)
+		}
+	})
+	switch {
+	case quiet:
+	case renderMarkdown:
+		md.Flush()
+	default:
+		fmt.Println()
+	}
+
+	return answer, err
+}
+
+// printSources prints the passages cited by answer.
+func printSources(answer *rag.Answer) {
+	fmt.Println("\nSources:")
+	for _, c := range answer.Citations {
+		fmt.Printf("[%d] %s, page %d (score %.3f)\n", c.Number, c.Source, c.Page, c.Score)
+	}
+}
+
+// indexableFiles returns the documents to index: the files given, and the
+// documents found under the directories given.
+func indexableFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		if storage.IsRemote(path) {
+			files = append(files, path)
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		documents, err := walkDocuments(path, "")
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, documents...)
+	}
+
+	return files, nil
+}
+
+// documentPages returns the text of every page of a document. PDF pages
+// without a usable text layer are transcribed by the model when --ocr is set
+// and skipped otherwise.
+func documentPages(ctx context.Context, client *uniai.Client, file string) ([]rag.Page, error) {
+	path := file
+	if storage.IsRemote(file) {
+		local, err := storage.Download(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(filepath.Dir(local))
+		path = local
+	}
+
+	if cli.IsConvertible(path) {
+		doc, err := cli.ConvertFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var pages []rag.Page
+		for i, page := range doc.Pages {
+			if page.Text != "" {
+				pages = append(pages, rag.Page{Number: i + 1, Text: page.Text})
+			}
+		}
+		return pages, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader, err := model.NewPdfReader(f)
+	if err != nil {
+		return nil, err
+	}
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+
+	var pages []rag.Page
+	for pageNum := 1; pageNum <= numPages; pageNum++ {
+		page, err := reader.GetPage(pageNum)
+		if err != nil {
+			return nil, err
+		}
+
+		text, err := cli.ExtractPageText(page)
+		if err != nil {
+			println("Failed to extract text of page", pageNum, ":", err.Error())
+		}
+		if !cli.HasUsableText(text, cli.DefaultMinTextChars) && indexOcr {
+			text, err = transcribePage(ctx, client, page)
+			if err != nil {
+				println("Failed to transcribe page", pageNum, ":", err.Error())
+				continue
+			}
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		pages = append(pages, rag.Page{Number: pageNum, Text: text})
+	}
+
+	return pages, nil
+}
+
+// transcribePage renders a page and asks the model for its text.
+func transcribePage(ctx context.Context, client *uniai.Client, page *model.PdfPage) (string, error) {
+	img, err := cli.RenderPdfPageImage(page, cli.DefaultRenderSettings)
+	if err != nil {
+		return "", err
+	}
+	data, err := cli.EncodeJpeg(img, cli.DefaultRenderSettings.Quality)
+	if err != nil {
+		return "", err
+	}
+
+	return client.GenerateText(ctx, &uniai.GenerateRequest{
+		Model:   uniai.ModelDefault,
+		Prompt:  ocrTranscribePrompt,
+		Images:  []uniai.ImageData{data},
+		Options: uniai.DefaultOptions,
+	})
+}
+
+func init() {
+	indexCmd.Flags().StringArrayVarP(&indexFiles, "file", "f", nil, "Path or URL of a document to index (repeatable; files may also be given as arguments)")
+	indexCmd.Flags().StringVar(&ragStoreDir, "store", defaultRagStoreDir, "Directory of the vector store")
+	indexCmd.Flags().IntVar(&chunkSize, "chunk-size", rag.DefaultOptions.ChunkSize, "Maximum chunk length in characters")
+	indexCmd.Flags().IntVar(&chunkOverlap, "chunk-overlap", rag.DefaultOptions.ChunkOverlap, "Characters shared by consecutive chunks")
+	indexCmd.Flags().StringVar(&embedModel, "embed-model", rag.DefaultOptions.EmbedModel, "Model used for embeddings")
+	indexCmd.Flags().BoolVar(&embedCache, "embed-cache", true, "Reuse the embeddings of unchanged chunks from the embedding cache of the store")
+	indexCmd.Flags().BoolVar(&indexOcr, "ocr", false, "Transcribe PDF pages without a text layer with the model instead of skipping them")
+
+	queryCmd.Flags().StringVar(&ragStoreDir, "store", defaultRagStoreDir, "Directory of the vector store")
+	queryCmd.Flags().IntVar(&topK, "top-k", rag.DefaultOptions.TopK, "Number of passages retrieved for the question")
+	queryCmd.Flags().BoolVar(&listSources, "list", false, "List the indexed documents instead of answering")
+
+	uniaiCmd.AddCommand(indexCmd, queryCmd)
+}
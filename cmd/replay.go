@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// replayDir is the directory, next to the replayed run, of the replays
+// without --output.
+const replayDir = "replays"
+
+// unreplayedFlags are the flags of a run that are not applied to its replay:
+// the document, prompt and model come from the run metadata, and the output
+// goes to a new directory.
+var unreplayedFlags = []string{"file", "output", "prompt", "template", "var", "model", "models", "system-preset"}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <run.json>",
+	Short: "Run a previous run again with the same inputs, against its model or another",
+	Long: `Replay processes the document of a previous run again with its exact inputs:
+the prompt, system prompt, response schema and model options of its run
+metadata, and the flags it was run with, such as its page range and rendering
+settings. The model is the model of the run, or --model to compare another.
+
+The argument is the run.json of the run, or its output directory. The outputs
+are written to a new run directory below 'replays' next to the run, or to
+--output, so that they can be compared with the run:
+
+  uniai replay output/invoice/run.json --model uniai01:32b
+  uniai diff output/invoice output/invoice/replays/invoice/20260101-120000
+
+Flags set on the command line take precedence over those of the run. The
+requests are sent again rather than answered from the response cache, unless
+--force=false. The document is read from the source of the run, so a relative
+path must be replayed from the same working directory.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		path := args[0]
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			path = filepath.Join(path, cli.RunInfoFile)
+		}
+		run, err := cli.LoadRunInfo(path)
+		if err != nil {
+			return fmt.Errorf("failed to load run metadata: %w", err)
+		}
+		if run.Source == "" {
+			return fmt.Errorf("run metadata %s has no source document", path)
+		}
+
+		if err := applyRunFlags(cmd, run.Args); err != nil {
+			return fmt.Errorf("failed to apply the flags of the run: %w", err)
+		}
+
+		preset := &cli.Preset{
+			System:  run.System,
+			Options: run.Options,
+		}
+		if len(run.Format) > 0 && !bytes.Equal(run.Format, jsonFormat) {
+			if err := json.Unmarshal(run.Format, &preset.Schema); err != nil {
+				return fmt.Errorf("invalid response schema in run metadata: %w", err)
+			}
+		}
+		preset.Prompt = runPrompt(run.Prompt, preset.Schema)
+
+		filePath, prompt, templateName, activePreset = run.Source, preset.Prompt, "", preset
+		if !cmd.Flags().Changed("model") {
+			uniaiModel = run.Model
+		}
+		if !cmd.Flags().Changed("output") {
+			outputDir = filepath.Join(filepath.Dir(path), replayDir)
+			if !cmd.Flags().Changed("output-layout") {
+				outputLayout = cli.OutputPerRun
+			}
+		}
+		if !cmd.Flags().Changed("force") {
+			force = true
+		}
+		logger.Info("replaying run", "run", path, "source", run.Source, "model", uniaiModel, "output", outputDir)
+
+		return uniaiCmd.RunE(cmd, nil)
+	},
+}
+
+// runPrompt returns the prompt of a run without the instructions its flags
+// append to it, which the replay appends again. schema is the schema of the
+// run.
+func runPrompt(base string, schema map[string]any) string {
+	if jsonOut || schemaFile != "" {
+		instructions, _ := uniai.JSONInstructions(schema)
+		base = strings.TrimSuffix(base, "\n\n"+instructions)
+	}
+	if ocrPositions {
+		base = strings.TrimSuffix(base, "\n\n"+cli.OcrPositionsPrompt)
+	}
+	if markdownOut {
+		base = strings.TrimSuffix(base, "\n\n"+cli.MarkdownPrompt)
+	}
+
+	return base
+}
+
+// applyRunFlags sets the processing flags of cmd to their values in the
+// command line args of a run, except for the flags set on the command line
+// and the unreplayed flags. Flags of other commands are ignored.
+func applyRunFlags(cmd *cobra.Command, args []string) error {
+	type setting struct{ name, value string }
+	var settings []setting
+
+	recorded := pflag.NewFlagSet("run", pflag.ContinueOnError)
+	recorded.ParseErrorsWhitelist.UnknownFlags = true
+	recorded.SetOutput(io.Discard)
+	var changed []string
+	cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed {
+			changed = append(changed, f.Name)
+		}
+		name := f.Name
+		r := recorded.VarPF(&recordedValue{typ: f.Value.Type(), set: func(value string) {
+			settings = append(settings, setting{name, value})
+		}}, f.Name, f.Shorthand, "")
+		r.NoOptDefVal = f.NoOptDefVal
+	})
+	if err := recorded.Parse(args); err != nil {
+		return err
+	}
+
+	for _, s := range settings {
+		if slices.Contains(changed, s.name) || slices.Contains(unreplayedFlags, s.name) {
+			continue
+		}
+		if err := cmd.Flags().Set(s.name, s.value); err != nil {
+			return fmt.Errorf("flag %s: %w", s.name, err)
+		}
+	}
+
+	return nil
+}
+
+// recordedValue is a flag value passing the values it is set to to set.
+type recordedValue struct {
+	typ string
+	set func(value string)
+}
+
+func (v *recordedValue) String() string { return "" }
+func (v *recordedValue) Type() string   { return v.typ }
+
+func (v *recordedValue) Set(value string) error {
+	v.set(value)
+	return nil
+}
+
+func init() {
+	uniaiCmd.AddCommand(replayCmd)
+}
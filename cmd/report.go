@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/sampila/uniai-client/internal/cli"
+)
+
+var reportFormat string // Format of the report of the run for reviewers, if any
+
+// writeReport writes the report of --report to out, for the run that failed
+// with err if not nil, and returns its path, or "" without --report.
+func writeReport(proc *pageProcessor, out cli.OutputDir, skipped []renderedPage, err error) (string, error) {
+	if reportFormat == "" {
+		return "", nil
+	}
+
+	summary := cli.NewRunSummary(proc.run, proc.manifest, out.Dir, cli.ManifestFile, cli.RunInfoFile, skipReasons(skipped), err)
+	responses, err := proc.checkpoint.Entries(proc.run.StartedAt)
+	if err != nil {
+		return "", fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	report, err := cli.NewReport(summary, proc.run, responses, proc.manifest, out.Dir)
+	if err != nil {
+		return "", err
+	}
+
+	path := out.Path(cli.ReportFile(reportFormat))
+	if err := report.Write(path, reportFormat); err != nil {
+		return "", err
+	}
+	logger.Info("report written", "path", path)
+
+	return path, nil
+}
+
+func init() {
+	uniaiCmd.Flags().StringVar(&reportFormat, "report", "", "Write a report of the run for reviewers: 'html' (a single file with page thumbnails, responses, run metadata and failures) or 'pdf' (a summary page, then every page image with its responses)")
+}
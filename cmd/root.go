@@ -1,8 +1,9 @@
 package cmd
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"errors"
+	"io/fs"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
@@ -13,15 +14,74 @@ var rootCmd = &cobra.Command{
 	Short: "UniAI is a CLI client for interacting with UniAI models.",
 	Long: `UniAI is a command-line interface (CLI) client designed to interact with UniAI models, 
 providing functionalities such as pdf to text generation, document QA, and make structured data.`,
+	// Errors are reported through the logger by Execute.
+	SilenceErrors:     true,
+	PersistentPreRunE: setup,
 }
 
-func Execute() {
-	err := godotenv.Load() // by default loads ".env"
+// setup sets the flags from the environment, then configures the logger, the
+// API settings and, for commands handling PDFs, the UniDoc license before any
+// command runs.
+func setup(cmd *cobra.Command, args []string) error {
+	if err := bindEnv(cmd); err != nil {
+		return err
+	}
+	if err := setupLogger(cmd, args); err != nil {
+		return err
+	}
+	if err := setupConfig(cmd); err != nil {
+		return err
+	}
+
+	return setupLicense(cmd)
+}
+
+// Execute runs the command line. The returned error has already been
+// reported, so callers only need to exit with its status, see ExitCode.
+func Execute() error {
+	// The environment is read before the .env file is loaded into it, which
+	// ranks below the configuration file. The .env file is optional, e.g. for
+	// --offline runs without credentials.
+	apiConfig.LoadEnv()
+	err := apiConfig.LoadDotenv(".env")
+	if err == nil {
+		err = godotenv.Load() // by default loads ".env"
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	}
+	if err == nil {
+		// Commands hand cmd.Context() down to their work, so canceling it
+		// stops rendering and requests in progress.
+		err = rootCmd.ExecuteContext(context.Background())
+	}
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		logger.Error(err.Error())
 	}
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+	return err
+}
+
+// exitError is an error of a command that exits with a status other than 1.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// ExitCode returns the exit status of a command that failed with err.
+func ExitCode(err error) int {
+	var exit *exitError
+	if errors.As(err, &exit) {
+		return exit.code
 	}
+
+	return 1
 }
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+)
+
+var (
+	taskName    string // Name of the task preset to run
+	presetsFile string // YAML file of user task presets
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run --task <name>",
+	Short: "Process a document with a task preset",
+	Long: `Run processes a document like 'uniai' with a task preset: a named bundle of
+prompt, response schema, model options and flag defaults. Built-in tasks are
+summarize, ocr, classify and extract-dates; more can be added, or built-ins
+overridden, in the user presets file (see --presets) without code changes; see
+'uniai tasks' for the list.
+Flags set on the command line take precedence over the defaults of the task,
+--prompt is passed to the task prompt as the 'prompt' variable and --var sets
+its other variables.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if taskName == "" {
+			return cmd.Help()
+		}
+
+		presets, err := loadPresets()
+		if err != nil {
+			return fmt.Errorf("failed to load task presets: %w", err)
+		}
+		preset, ok := presets[taskName]
+		if !ok {
+			return fmt.Errorf("unknown task: %s", taskName)
+		}
+		if templateName != "" {
+			return errors.New("--template cannot be used with --task")
+		}
+
+		for name, value := range preset.Flags {
+			f := cmd.Flags().Lookup(name)
+			if f == nil {
+				return fmt.Errorf("invalid task preset: unknown flag %s", name)
+			}
+			if f.Changed {
+				continue
+			}
+			if err := f.Value.Set(value); err != nil {
+				return fmt.Errorf("invalid task preset: flag %s: %w", name, err)
+			}
+		}
+
+		rendered, err := renderPreset(preset, prompt)
+		if err != nil {
+			return fmt.Errorf("failed to render task prompt: %w", err)
+		}
+		prompt = rendered
+		activePreset = preset
+
+		return uniaiCmd.RunE(cmd, args)
+	},
+}
+
+var tasksCmd = &cobra.Command{
+	Use:   "tasks",
+	Short: "List the task presets",
+	Run: func(cmd *cobra.Command, args []string) {
+		presets, err := loadPresets()
+		if err != nil {
+			println("Failed to load task presets:", err.Error())
+			return
+		}
+
+		for _, name := range cli.PresetNames(presets) {
+			fmt.Printf("%-20s %s (%s)\n", name, presets[name].Description, presets[name].Source)
+		}
+	},
+}
+
+// loadPresets loads the built-in task presets and those of --presets or the
+// default presets file.
+func loadPresets() (map[string]*cli.Preset, error) {
+	path := presetsFile
+	if path == "" {
+		var err error
+		path, err = cli.DefaultPresetFile()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cli.LoadPresets(path)
+}
+
+// renderPreset renders the prompt of a task with its variables, overridden
+// by the --var variables, and the prompt as the "prompt" variable.
+func renderPreset(preset *cli.Preset, prompt string) (string, error) {
+	registry, err := loadTemplates()
+	if err != nil {
+		return "", err
+	}
+
+	vars, err := cli.ParseVars(templateVars)
+	if err != nil {
+		return "", err
+	}
+	for key, value := range preset.Vars {
+		if _, ok := vars[key]; !ok {
+			vars[key] = value
+		}
+	}
+	if _, ok := vars["prompt"]; !ok {
+		vars["prompt"] = prompt
+	}
+
+	if preset.Template != "" {
+		return registry.Render(preset.Template, vars)
+	}
+
+	return registry.RenderText(preset.Prompt, vars)
+}
+
+func init() {
+	runCmd.Flags().StringVar(&taskName, "task", "", "Name of the task preset to run")
+	uniaiCmd.PersistentFlags().StringVar(&presetsFile, "presets", "", "YAML file of user task presets (defaults to presets.yaml in the user config directory)")
+
+	uniaiCmd.AddCommand(runCmd)
+	uniaiCmd.AddCommand(tasksCmd)
+}
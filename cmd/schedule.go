@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sampila/uniai-client/internal/cli"
+)
+
+// scheduleJobs returns the expander of the schedules of the daemon: a job per
+// file of the directory of a schedule below root matching its pattern, and
+// modified since its previous run with new_only.
+func scheduleJobs(root string) cli.ScheduleExpander {
+	return func(sched cli.Schedule, since time.Time) ([]cli.JobSpec, error) {
+		template := sched.Job
+		if sched.Task != "" {
+			presets, err := loadPresets()
+			if err != nil {
+				return nil, fmt.Errorf("failed to load task presets: %w", err)
+			}
+			preset, ok := presets[sched.Task]
+			if !ok {
+				return nil, fmt.Errorf("unknown task: %s", sched.Task)
+			}
+			if template.Prompt, err = renderPreset(preset, template.Prompt); err != nil {
+				return nil, fmt.Errorf("failed to render task prompt: %w", err)
+			}
+			template.System = cmp.Or(template.System, preset.System)
+			template.Options = cli.MergeOptions(preset.Options, template.Options)
+		}
+		if template.Prompt == "" {
+			return nil, errors.New("the schedule has no prompt")
+		}
+
+		dir := filepath.Join(root, filepath.FromSlash(sched.Dir))
+		if rel, err := filepath.Rel(root, dir); err != nil || strings.HasPrefix(rel, "..") {
+			return nil, fmt.Errorf("directory %s is outside the served directory", sched.Dir)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		var jobs []cli.JobSpec
+		for _, e := range entries {
+			if !e.Type().IsRegular() {
+				continue
+			}
+			if sched.Pattern != "" {
+				if ok, _ := filepath.Match(sched.Pattern, e.Name()); !ok {
+					continue
+				}
+			}
+			if sched.NewOnly && !since.IsZero() {
+				info, err := e.Info()
+				if err != nil {
+					return nil, err
+				}
+				if !info.ModTime().After(since) {
+					continue
+				}
+			}
+
+			job := template
+			job.File = path.Join(filepath.ToSlash(sched.Dir), e.Name())
+			job.Name, job.Data = "", nil
+			job.Schedule = sched.Name
+			jobs = append(jobs, job)
+		}
+
+		return jobs, nil
+	}
+}
+
+// scheduleState is a schedule with the time of its next run.
+type scheduleState struct {
+	cli.Schedule
+	NextRun time.Time `json:"next_run,omitzero"`
+}
+
+// handleSchedules serves the REST API of the schedules.
+func handleSchedules(mux *http.ServeMux, scheduler *cli.Scheduler) {
+	state := func(sched cli.Schedule) scheduleState {
+		next, _ := scheduler.Next(sched.Name, time.Now())
+		return scheduleState{Schedule: sched, NextRun: next}
+	}
+
+	mux.HandleFunc("POST /schedules", func(w http.ResponseWriter, r *http.Request) {
+		var sched cli.Schedule
+		if err := json.NewDecoder(r.Body).Decode(&sched); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid schedule: "+err.Error())
+			return
+		}
+		if sched.Job.Prompt == "" && sched.Task == "" {
+			writeError(w, http.StatusBadRequest, "the schedule has no prompt or task")
+			return
+		}
+
+		err := scheduler.Add(sched)
+		switch {
+		case errors.Is(err, cli.ErrScheduleExists):
+			writeError(w, http.StatusConflict, err.Error())
+		case err != nil:
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			sched, _ = scheduler.Get(sched.Name)
+			w.Header().Set("Location", "/schedules/"+sched.Name)
+			writeJSON(w, http.StatusCreated, state(sched))
+		}
+	})
+
+	mux.HandleFunc("GET /schedules", func(w http.ResponseWriter, r *http.Request) {
+		var states []scheduleState
+		for _, sched := range scheduler.List() {
+			states = append(states, state(sched))
+		}
+		writeJSON(w, http.StatusOK, states)
+	})
+
+	mux.HandleFunc("GET /schedules/{name}", func(w http.ResponseWriter, r *http.Request) {
+		sched, ok := scheduler.Get(r.PathValue("name"))
+		if !ok {
+			writeError(w, http.StatusNotFound, cli.ErrScheduleNotFound.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, state(sched))
+	})
+
+	mux.HandleFunc("POST /schedules/{name}/run", func(w http.ResponseWriter, r *http.Request) {
+		run, err := scheduler.RunNow(r.PathValue("name"))
+		switch {
+		case errors.Is(err, cli.ErrScheduleNotFound):
+			writeError(w, http.StatusNotFound, err.Error())
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		default:
+			writeJSON(w, http.StatusOK, run)
+		}
+	})
+
+	mux.HandleFunc("DELETE /schedules/{name}", func(w http.ResponseWriter, r *http.Request) {
+		err := scheduler.Delete(r.PathValue("name"))
+		switch {
+		case errors.Is(err, cli.ErrScheduleNotFound):
+			writeError(w, http.StatusNotFound, err.Error())
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
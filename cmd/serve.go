@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/websocket"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/internal/storage"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	serveAddr    string   // Address the server listens on
+	serveGRPC    string   // Address the gRPC server listens on, if any
+	serveRoot    string   // Directory local documents are read from
+	serveOrigins []string // Origins allowed to open WebSocket connections
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve document processing over WebSocket",
+	Long: `Serve starts an HTTP server whose /ws WebSocket endpoint processes documents and
+streams the progress to the client, for live browser UIs. Every message sent
+by the client is a job:
+
+  {"id": "job-1", "file": "invoices/acme.pdf", "prompt": "Extract the total",
+   "pages": "1-3", "text_first": true}
+
+The file is a path below --root or an https://, s3:// or gs:// URL; a browser
+can instead upload the document as base64 "data" with its "name". The server
+answers with events, in order:
+
+  {"type": "started", "job": "job-1", "pages": 3}
+  {"type": "page_rendered", "job": "job-1", "page": 1}
+  {"type": "token", "job": "job-1", "page": 1, "text": "The total"}
+  {"type": "page_completed", "job": "job-1", "page": 1, "text": "The total is 12 EUR"}
+  {"type": "done", "job": "job-1"}
+
+or an "error" event. Jobs of a connection are processed one after another.
+
+With --grpc-addr, the Processing service of pkg/uniaipb/uniai.proto is also
+served: SubmitDocument queues a job in the background, StreamResults streams
+its events and GetJob returns its status and page results.
+
+/healthz, /readyz and /metrics serve the health of the process, its
+readiness (the AI backend answers) and Prometheus metrics.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		root, err := filepath.Abs(serveRoot)
+		if err != nil {
+			println("Invalid root directory:", err.Error())
+			return
+		}
+
+		var jobs *cli.Jobs
+		mux := http.NewServeMux()
+		mux.Handle("/ws", websocket.Server{
+			Handshake: checkOrigin,
+			Handler: func(ws *websocket.Conn) {
+				serveConn(ws, cmd, root)
+			},
+		})
+
+		if serveGRPC != "" {
+			jobs = cli.NewJobs(func(ctx context.Context, spec cli.JobSpec, emit func(cli.Event) error) error {
+				return runJob(ctx, cmd, root, spec, emit)
+			})
+			lis, err := net.Listen("tcp", serveGRPC)
+			if err != nil {
+				println("Failed to listen:", err.Error())
+				return
+			}
+			go func() {
+				if err := newGRPCServer(jobs).Serve(lis); err != nil {
+					println("Failed to serve gRPC:", err.Error())
+				}
+			}()
+			println("Serving gRPC on", serveGRPC)
+		}
+
+		handleHealth(mux, cmd, jobs)
+
+		println("Listening on", serveAddr)
+		if err := http.ListenAndServe(serveAddr, mux); err != nil {
+			println("Failed to serve:", err.Error())
+		}
+	},
+}
+
+// checkOrigin accepts WebSocket connections from the origins of
+// --allow-origin, or from any origin if none is set.
+func checkOrigin(config *websocket.Config, r *http.Request) error {
+	if len(serveOrigins) == 0 {
+		return nil
+	}
+
+	origin := r.Header.Get("Origin")
+	if !slices.Contains(serveOrigins, origin) {
+		return fmt.Errorf("origin %q not allowed", origin)
+	}
+
+	return nil
+}
+
+// serveJob is a job sent by a WebSocket client, with the ID its events are
+// tagged with.
+type serveJob struct {
+	ID string `json:"id"`
+	cli.JobSpec
+}
+
+// serveConn processes the jobs of a connection until it is closed.
+func serveConn(ws *websocket.Conn, cmd *cobra.Command, root string) {
+	defer ws.Close()
+
+	for {
+		var job serveJob
+		if err := websocket.JSON.Receive(ws, &job); err != nil {
+			return
+		}
+
+		send := func(event cli.Event) error {
+			event.Job = job.ID
+			return websocket.JSON.Send(ws, event)
+		}
+		if err := runJob(ws.Request().Context(), cmd, root, job.JobSpec, send); err != nil {
+			if send(cli.Event{Type: cli.EventError, Error: err.Error()}) != nil {
+				return
+			}
+		}
+	}
+}
+
+// runJob processes the pages of a job one by one, sending an event at every
+// step.
+func runJob(ctx context.Context, cmd *cobra.Command, root string, job cli.JobSpec, send func(cli.Event) error) (err error) {
+	start := time.Now()
+	defer func() {
+		jobMetrics.Observe(cli.StageJob, time.Since(start))
+		if err != nil {
+			jobMetrics.JobFinished(cli.JobFailed)
+		} else {
+			jobMetrics.JobFinished(cli.JobDone)
+		}
+	}()
+
+	if job.Prompt == "" {
+		return errors.New("the job has no prompt")
+	}
+
+	file, cleanup, err := serveJobFile(root, job)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	pageNumbers, err := cli.ParsePageRange(job.Pages)
+	if err != nil {
+		return fmt.Errorf("invalid page range: %w", err)
+	}
+	loadStart := time.Now()
+	pages, err := loadInputPages(ctx, file, pageNumbers, job.TextFirst)
+	if err != nil {
+		return fmt.Errorf("failed to load document: %w", err)
+	}
+	jobMetrics.Observe(cli.StageLoad, time.Since(loadStart))
+	if err := send(cli.Event{Type: cli.EventStarted, Pages: len(pages)}); err != nil {
+		return err
+	}
+	for _, page := range pages {
+		if err := send(cli.Event{Type: cli.EventPageRendered, Page: page.num}); err != nil {
+			return err
+		}
+	}
+
+	client, err := newClient(cmd, file)
+	if err != nil {
+		return err
+	}
+	// A model requested by the job is not routed.
+	var routes *router
+	if job.Model == "" {
+		if routes, err = newRouter(cmd, file); err != nil {
+			return err
+		}
+	}
+
+	doc := cmp.Or(job.File, job.Name)
+	for _, page := range pages {
+		req := uniai.GenerateRequest{
+			Model:   job.Model,
+			Prompt:  job.Prompt,
+			System:  job.System,
+			Options: cli.MergeOptions(uniai.DefaultOptions, job.Options),
+		}
+		if req.Model == "" {
+			req.Model = uniai.ModelDefault
+		}
+		input := cli.InputText
+		if page.image != nil {
+			input = cli.InputImage
+			req.Images = []uniai.ImageData{page.image}
+		} else {
+			req.Prompt = cli.TextPrompt(req.Prompt, page.text)
+		}
+		client, model, err := routes.route(input, cli.DetectLanguage(req.Prompt), len(pages), client, req.Model)
+		if err != nil {
+			return &cli.PageError{Doc: doc, Page: page.num, Stage: stageGenerate, Err: err}
+		}
+		req.Model = model
+
+		var response strings.Builder
+		generateStart := time.Now()
+		err = client.Generate(ctx, &req, func(resp uniai.GenerateResponse) error {
+			response.WriteString(resp.Response)
+			if resp.Response == "" {
+				return nil
+			}
+			return send(cli.Event{Type: cli.EventToken, Page: page.num, Text: resp.Response})
+		})
+		if err != nil {
+			return &cli.PageError{Doc: doc, Page: page.num, Stage: stageGenerate, Err: err}
+		}
+		jobMetrics.Observe(cli.StageGenerate, time.Since(generateStart))
+
+		text := client.FilterResponse(&req, response.String())
+		if err := send(cli.Event{Type: cli.EventPageCompleted, Page: page.num, Text: text}); err != nil {
+			return err
+		}
+	}
+
+	return send(cli.Event{Type: cli.EventDone})
+}
+
+// serveJobFile returns the document of a job: the uploaded data written to a
+// temporary file, a remote URL, or a file below root.
+func serveJobFile(root string, job cli.JobSpec) (string, func(), error) {
+	noop := func() {}
+
+	switch {
+	case len(job.Data) > 0:
+		dir, err := os.MkdirTemp("", "uniai-serve-*")
+		if err != nil {
+			return "", noop, err
+		}
+		name := filepath.Base(job.Name)
+		if name == "." || name == string(filepath.Separator) {
+			name = "document.pdf"
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, job.Data, 0600); err != nil {
+			os.RemoveAll(dir)
+			return "", noop, err
+		}
+		return path, func() { os.RemoveAll(dir) }, nil
+	case storage.IsRemote(job.File):
+		return job.File, noop, nil
+	case job.File == "":
+		return "", noop, errors.New("the job has no file")
+	}
+
+	path := filepath.Join(root, filepath.FromSlash(job.File))
+	if rel, err := filepath.Rel(root, path); err != nil || strings.HasPrefix(rel, "..") {
+		return "", noop, fmt.Errorf("file %s is outside the served directory", job.File)
+	}
+
+	return path, noop, nil
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8090", "Address the server listens on")
+	serveCmd.Flags().StringVar(&serveGRPC, "grpc-addr", "", "Address of the gRPC server, e.g. '127.0.0.1:8091' (disabled by default)")
+	serveCmd.Flags().StringVar(&serveRoot, "root", ".", "Directory the files of jobs are read from")
+	serveCmd.Flags().StringArrayVar(&serveOrigins, "allow-origin", nil, "Origin allowed to open WebSocket connections, e.g. 'https://app.example.com' (repeatable; any origin if unset)")
+
+	uniaiCmd.AddCommand(serveCmd)
+}
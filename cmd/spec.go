@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/eval"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	specInput  string // Input file overriding the input of the spec
+	specOutput string // Output directory overriding the output of the spec
+	specPages  string // Page range overriding the pages of the spec
+)
+
+var pipelineCmd = &cobra.Command{
+	Use:   "pipeline",
+	Short: "Run multi-step document jobs described in a spec file",
+}
+
+var pipelineRunCmd = &cobra.Command{
+	Use:   "run <spec.yaml>",
+	Short: "Run the steps of a pipeline spec",
+	Long: `Run executes the steps of a YAML pipeline spec in order. Each step consumes the
+result of the previous step, or of the step named by its input:
+
+  name: invoices
+  input: invoice.pdf
+  output: ./out
+  steps:
+    - name: pages
+      type: render        # load the pages as images (text_first: use text layers)
+    - name: text
+      type: extract       # one request per page, answers kept as text
+      prompt: Transcribe all text on this page.
+    - name: invoice
+      type: structure     # one request for all pages, answer parsed as JSON
+      prompt: Extract the invoice number, date and total.
+      schema: {type: object, properties: {number: {type: string}, total: {type: number}}}
+    - type: validate      # check the JSON against a schema
+      schema: {type: object, required: [number, total]}
+    - type: export        # write json, csv, text or markdown to the output directory
+      file: invoice.json
+
+Extract and structure steps accept model, system and options; the model of the
+spec applies to steps without one.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		spec, err := cli.LoadSpec(args[0])
+		if err != nil {
+			println("Failed to load pipeline spec:", err.Error())
+			return
+		}
+		if specInput != "" {
+			spec.Input = specInput
+		}
+		if specOutput != "" {
+			spec.Output = specOutput
+		}
+		if specPages != "" {
+			spec.Pages = specPages
+		}
+		if spec.Output == "" {
+			spec.Output = "./output"
+		}
+
+		client, err := newClient(cmd, spec.Input)
+		if err != nil {
+			println("Failed to initialize UniAI client:", err.Error())
+			return
+		}
+
+		if err := runSpec(cmd.Context(), client, spec); err != nil {
+			println("Pipeline", spec.Name, "failed:", err.Error())
+			return
+		}
+		println("Pipeline", spec.Name, "completed")
+	},
+}
+
+// stepResult is what a step hands to the steps consuming it: pages of text or
+// images, and the JSON value of structure steps.
+type stepResult struct {
+	pages []inputPage
+	value any
+}
+
+// texts returns the text of the pages.
+func (r *stepResult) texts() []string {
+	texts := make([]string, 0, len(r.pages))
+	for _, page := range r.pages {
+		if page.text != "" {
+			texts = append(texts, page.text)
+		}
+	}
+
+	return texts
+}
+
+// runSpec runs the steps of a spec in order, stopping at the first failure.
+func runSpec(ctx context.Context, client *uniai.Client, spec *cli.Spec) error {
+	results := make(map[string]*stepResult, len(spec.Steps))
+	for _, step := range spec.Steps {
+		println("Running step", step.Name, "("+step.Type+")")
+
+		in := results[step.Input]
+		var (
+			out *stepResult
+			err error
+		)
+		switch step.Type {
+		case cli.StepRender:
+			out, err = runRenderStep(ctx, spec, step)
+		case cli.StepExtract:
+			out, err = runExtractStep(ctx, client, spec, step, in)
+		case cli.StepStructure:
+			out, err = runStructureStep(ctx, client, spec, step, in)
+		case cli.StepValidate:
+			out, err = runValidateStep(step, in)
+		case cli.StepExport:
+			out, err = runExportStep(spec, step, in)
+		}
+		if err != nil {
+			return fmt.Errorf("step %s: %w", step.Name, err)
+		}
+		results[step.Name] = out
+	}
+
+	return nil
+}
+
+func runRenderStep(ctx context.Context, spec *cli.Spec, step cli.SpecStep) (*stepResult, error) {
+	if spec.Input == "" {
+		return nil, errors.New("the spec has no input, set one with --file")
+	}
+	pageNumbers, err := cli.ParsePageRange(spec.Pages)
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := loadInputPages(ctx, spec.Input, pageNumbers, step.TextFirst)
+	if err != nil {
+		return nil, err
+	}
+	println("Loaded", len(pages), "page(s) of", spec.Input)
+
+	return &stepResult{pages: pages}, nil
+}
+
+// stepRequest returns the request of an extract or structure step.
+func stepRequest(spec *cli.Spec, step cli.SpecStep) uniai.GenerateRequest {
+	req := uniai.GenerateRequest{
+		Model:   uniai.ModelDefault,
+		Prompt:  step.Prompt,
+		System:  step.System,
+		Options: cli.MergeOptions(uniai.DefaultOptions, step.Options),
+	}
+	switch {
+	case step.Model != "":
+		req.Model = step.Model
+	case spec.Model != "":
+		req.Model = spec.Model
+	}
+
+	return req
+}
+
+func runExtractStep(ctx context.Context, client *uniai.Client, spec *cli.Spec, step cli.SpecStep, in *stepResult) (*stepResult, error) {
+	req := stepRequest(spec, step)
+
+	pages := in.pages
+	if in.value != nil {
+		data, err := json.MarshalIndent(in.value, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		pages = []inputPage{{text: string(data)}}
+	}
+
+	out := &stepResult{}
+	for _, page := range pages {
+		pageReq := req
+		if page.image != nil {
+			pageReq.Images = []uniai.ImageData{page.image}
+		} else {
+			pageReq.Prompt = cli.TextPrompt(req.Prompt, page.text)
+		}
+
+		answer, err := client.GenerateText(ctx, &pageReq)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", page.num, err)
+		}
+		println("Extracted page", page.num)
+		out.pages = append(out.pages, inputPage{num: page.num, text: strings.TrimSpace(answer)})
+	}
+
+	return out, nil
+}
+
+func runStructureStep(ctx context.Context, client *uniai.Client, spec *cli.Spec, step cli.SpecStep, in *stepResult) (*stepResult, error) {
+	req := stepRequest(spec, step)
+	req.Format = json.RawMessage(`"json"`)
+	if len(step.Schema) > 0 {
+		data, err := json.Marshal(step.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema: %w", err)
+		}
+		req.Format = data
+	}
+
+	texts := in.texts()
+	if in.value != nil {
+		data, err := json.MarshalIndent(in.value, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		texts = []string{string(data)}
+	}
+	for _, page := range in.pages {
+		if page.image != nil {
+			req.Images = append(req.Images, page.image)
+		}
+	}
+	if len(texts) > 0 {
+		req.Prompt = cli.TextPrompt(req.Prompt, strings.Join(texts, "\n\n"))
+	}
+
+	answer, err := client.GenerateText(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+	value, err := eval.ParseJSON(answer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stepResult{pages: []inputPage{{text: strings.TrimSpace(answer)}}, value: value}, nil
+}
+
+func runValidateStep(step cli.SpecStep, in *stepResult) (*stepResult, error) {
+	value := in.value
+	if value == nil {
+		var err error
+		value, err = eval.ParseJSON(strings.Join(in.texts(), "\n"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	errs := uniai.ValidateSchema(value, step.Schema)
+	for _, e := range errs {
+		println("Invalid:", e)
+	}
+	if len(errs) > 0 && !step.ContinueOnError {
+		return nil, fmt.Errorf("%d schema violation(s)", len(errs))
+	}
+	if len(errs) == 0 {
+		println("Valid")
+	}
+
+	return &stepResult{pages: in.pages, value: value}, nil
+}
+
+func runExportStep(spec *cli.Spec, step cli.SpecStep, in *stepResult) (*stepResult, error) {
+	data, err := cli.ExportValue(step.Format, in.value, in.texts())
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(spec.Output, step.File)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	println("Exported", path)
+
+	return in, nil
+}
+
+func init() {
+	pipelineRunCmd.Flags().StringVarP(&specInput, "file", "f", "", "Input file, overriding the input of the spec")
+	pipelineRunCmd.Flags().StringVarP(&specOutput, "output", "o", "", "Output directory, overriding the output of the spec (default ./output)")
+	pipelineRunCmd.Flags().StringVarP(&specPages, "pages", "r", "", "Page range, overriding the pages of the spec")
+
+	pipelineCmd.AddCommand(pipelineRunCmd)
+	uniaiCmd.AddCommand(pipelineCmd)
+}
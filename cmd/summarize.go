@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	summarizeFile      string // Document to summarize
+	summarizePages     string // Page range to summarize
+	summarizePrompt    string // Instructions added to every request
+	summarizeModel     string // Model writing the summaries
+	summarizeFanIn     int    // Number of summaries merged per request
+	summarizeContext   int    // Context size of the model in tokens
+	summarizeMapReduce bool   // Flag to force the hierarchical summarization
+	summarizeJson      bool   // Flag to print the summary with its intermediate summaries as JSON
+)
+
+var summarizeCmd = &cobra.Command{
+	Use:   "summarize",
+	Short: "Summarize a document of any length",
+	Long: `Summarize writes a summary of a document. A document fitting in the context of
+the model is summarized in a single request; a longer one is summarized page
+by page, then the page summaries are merged --fan-in at a time into section
+summaries, and so on up to the document summary.
+
+Pages with a text layer are sent as text, which makes long documents cheaper
+to summarize.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if summarizeFile == "" {
+			cmd.Help()
+			return
+		}
+
+		client, err := newClient(cmd, summarizeFile)
+		if err != nil {
+			println("Failed to initialize UniAI client:", err.Error())
+			return
+		}
+
+		pageNumbers, err := cli.ParsePageRange(summarizePages)
+		if err != nil {
+			println("Invalid page range:", err.Error())
+			return
+		}
+
+		ctx := cmd.Context()
+		pages, err := loadInputPages(ctx, summarizeFile, pageNumbers, true)
+		if err != nil {
+			println("Failed to load document:", err.Error())
+			return
+		}
+		inputs := make([]uniai.PageInput, len(pages))
+		for i, page := range pages {
+			inputs[i] = page.pageInput()
+		}
+
+		summarizer := &uniai.Summarizer{
+			Client:        client,
+			Model:         summarizeModel,
+			Instructions:  summarizePrompt,
+			FanIn:         summarizeFanIn,
+			ContextTokens: summarizeContext,
+			MapReduce:     summarizeMapReduce,
+			Progress: func(part uniai.SummaryPart) {
+				println("Summarized", part.Level, "of page(s)", fmt.Sprint(part.Pages))
+			},
+		}
+		summary, err := summarizer.Summarize(ctx, inputs)
+		if err != nil {
+			println("Failed to summarize document:", err.Error())
+			return
+		}
+
+		if summarizeJson {
+			data, err := json.MarshalIndent(summary, "", "  ")
+			if err != nil {
+				println("Failed to encode summary:", err.Error())
+				return
+			}
+			fmt.Println(string(data))
+			return
+		}
+		fmt.Println(summary.Text)
+	},
+}
+
+func init() {
+	summarizeCmd.Flags().StringVarP(&summarizeFile, "file", "f", "", "Path or URL of the document")
+	summarizeCmd.Flags().StringVarP(&summarizePages, "pages", "r", "", "Page range to summarize (all pages by default)")
+	summarizeCmd.Flags().StringVarP(&summarizePrompt, "prompt", "m", "", "Instructions added to every request, such as the length or focus of the summary")
+	summarizeCmd.Flags().StringVar(&summarizeModel, "model", uniai.ModelDefault, "Model writing the summaries")
+	summarizeCmd.Flags().IntVar(&summarizeFanIn, "fan-in", uniai.DefaultFanIn, "Number of summaries merged per request")
+	summarizeCmd.Flags().IntVar(&summarizeContext, "context-tokens", uniai.DefaultContextTokens, "Context size of the model in tokens; longer documents are summarized hierarchically")
+	summarizeCmd.Flags().BoolVar(&summarizeMapReduce, "map-reduce", false, "Summarize hierarchically even if the document fits in the context")
+	summarizeCmd.Flags().BoolVar(&summarizeJson, "json", false, "Print the summary with the page and section summaries as JSON")
+
+	uniaiCmd.AddCommand(summarizeCmd)
+}
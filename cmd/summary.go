@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/sampila/uniai-client/internal/cli"
+)
+
+var (
+	printSummary bool   // Flag to indicate if the JSON summary of the run should be printed to stdout
+	summaryFile  string // File the JSON summary of the run is written to
+)
+
+// writeSummary writes the JSON summary of the run, which failed with err if
+// not nil, to --summary-file and, with --summary, to stdout.
+func writeSummary(proc *pageProcessor, out cli.OutputDir, localOutput string, skipped []renderedPage, err error) error {
+	if !printSummary && summaryFile == "" {
+		return nil
+	}
+
+	summary := cli.NewRunSummary(proc.run, proc.manifest, outputDir,
+		relativePath(localOutput, out.Path(cli.ManifestFile)),
+		relativePath(localOutput, out.Path(cli.RunInfoFile)),
+		skipReasons(skipped), err)
+
+	if summaryFile != "" {
+		f, err := os.Create(summaryFile)
+		if err != nil {
+			return err
+		}
+		if err := summary.Write(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		logger.Info("summary written", "path", summaryFile)
+	}
+	if printSummary {
+		return summary.Write(os.Stdout)
+	}
+
+	return nil
+}
+
+// writePageMetrics writes the metrics of every page of the run to out, and
+// returns their path.
+func writePageMetrics(proc *pageProcessor, out cli.OutputDir, skipped []renderedPage) (string, error) {
+	summary := cli.NewRunSummary(proc.run, proc.manifest, out.Dir, cli.ManifestFile, cli.RunInfoFile, skipReasons(skipped), nil)
+
+	path := out.Path(cli.PageMetricsFile)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if err := summary.WriteCSV(f); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	logger.Info("page metrics written", "path", path)
+
+	return path, nil
+}
+
+// skipReasons returns why the skipped pages were skipped, by page number.
+func skipReasons(skipped []renderedPage) map[int]string {
+	reasons := make(map[int]string, len(skipped))
+	for _, page := range skipped {
+		reasons[page.pageNum] = page.reason
+	}
+
+	return reasons
+}
+
+// relativePath returns path relative to dir, with slashes.
+func relativePath(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		path = rel
+	}
+
+	return filepath.ToSlash(path)
+}
+
+func init() {
+	uniaiCmd.Flags().BoolVar(&printSummary, "summary", false, "Print a JSON summary of the run (status, pages, durations, tokens, outputs and failures) to stdout once it finishes")
+	uniaiCmd.Flags().StringVar(&summaryFile, "summary-file", "", "Write the JSON summary of the run to this file")
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+)
+
+var (
+	systemPreset string // Name of the system prompt of page requests
+	systemDir    string // Directory of user system prompts
+)
+
+var systemPromptsCmd = &cobra.Command{
+	Use:   "system-prompts",
+	Short: "List the system prompt presets",
+	Long: `System-prompts lists the system prompts usable with --system-preset: the
+built-in ones and the *.md files of the user system prompt directory, which
+override built-ins of the same name. Sharing that directory shares the prompt
+standards of an organization; override "default" to change the system prompt
+of every page request.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		prompts, err := loadSystemPrompts()
+		if err != nil {
+			println("Failed to load system prompts:", err.Error())
+			return
+		}
+
+		for _, name := range prompts.Names() {
+			source, text, _ := prompts.Source(name)
+			fmt.Printf("%-20s %s (%s)\n", name, excerpt(text, 60), source)
+		}
+	},
+}
+
+var systemPromptsShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Print a system prompt preset",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		prompts, err := loadSystemPrompts()
+		if err != nil {
+			println("Failed to load system prompts:", err.Error())
+			return
+		}
+
+		source, text, ok := prompts.Source(args[0])
+		if !ok {
+			println("Unknown system prompt:", args[0])
+			return
+		}
+		fmt.Printf("# %s\n%s\n", source, text)
+	},
+}
+
+// loadSystemPrompts loads the built-in system prompts and those of
+// --system-dir or the default system prompt directory.
+func loadSystemPrompts() (*cli.SystemPrompts, error) {
+	dir := systemDir
+	if dir == "" {
+		var err error
+		dir, err = cli.DefaultSystemDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cli.LoadSystemPrompts(dir)
+}
+
+// loadSystemPrompt returns the named system prompt preset.
+func loadSystemPrompt(name string) (string, error) {
+	prompts, err := loadSystemPrompts()
+	if err != nil {
+		return "", fmt.Errorf("failed to load system prompts: %w", err)
+	}
+
+	return prompts.Get(name)
+}
+
+func init() {
+	uniaiCmd.Flags().StringVar(&systemPreset, "system-preset", cli.DefaultSystemPreset, "Name of the system prompt of page requests (see 'uniai system-prompts'), instead of the one of the task preset")
+	uniaiCmd.PersistentFlags().StringVar(&systemDir, "system-dir", "", "Directory of user system prompts, *.md files (defaults to the user config directory)")
+
+	systemPromptsCmd.AddCommand(systemPromptsShowCmd)
+	uniaiCmd.AddCommand(systemPromptsCmd)
+}
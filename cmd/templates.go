@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+)
+
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "List the prompt templates",
+	Long: `Templates lists the prompt templates usable with --template: the built-in ones
+and the *.tmpl files of the user template directory, which override built-ins
+of the same name. Templates use Go text/template syntax; variables set with
+--var key=value are available as {{.key}} and partials (templates named with a
+leading underscore) are included with {{template "_name" .}}.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		registry, err := loadTemplates()
+		if err != nil {
+			println("Failed to load templates:", err.Error())
+			return
+		}
+
+		for _, name := range registry.Names() {
+			source, _, _ := registry.Source(name)
+			fmt.Printf("%-20s %s (%s)\n", name, registry.Description(name), source)
+		}
+	},
+}
+
+var templatesShowCmd = &cobra.Command{
+	Use:   "show <template>",
+	Short: "Print the source of a prompt template",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		registry, err := loadTemplates()
+		if err != nil {
+			println("Failed to load templates:", err.Error())
+			return
+		}
+
+		source, text, ok := registry.Source(args[0])
+		if !ok {
+			println("Unknown template:", args[0])
+			return
+		}
+		fmt.Printf("# %s\n%s", source, text)
+	},
+}
+
+// loadTemplates loads the built-in templates and those of --template-dir or
+// the default template directory.
+func loadTemplates() (*cli.TemplateRegistry, error) {
+	dir := templateDir
+	if dir == "" {
+		var err error
+		dir, err = cli.DefaultTemplateDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cli.LoadTemplates(dir)
+}
+
+// renderTemplate renders the named template with the --var variables and the
+// prompt as the "prompt" variable.
+func renderTemplate(name, prompt string) (string, error) {
+	registry, err := loadTemplates()
+	if err != nil {
+		return "", err
+	}
+
+	vars, err := cli.ParseVars(templateVars)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := vars["prompt"]; !ok {
+		vars["prompt"] = prompt
+	}
+
+	return registry.Render(name, vars)
+}
+
+func init() {
+	templatesCmd.AddCommand(templatesShowCmd)
+	uniaiCmd.AddCommand(templatesCmd)
+}
@@ -1,19 +1,22 @@
 package cmd
 
 import (
-	"bytes"
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
-	"strings"
-	"sync"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/spf13/cobra"
-
-	"github.com/unidoc/unipdf/v4/model"
+	"github.com/spf13/pflag"
 
 	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/internal/storage"
 	"github.com/sampila/uniai-client/pkg/uniai"
 )
 
@@ -24,17 +27,125 @@ var (
 	pageRange     string // e.g., "1-3" for pages 1 to 3, "1,2,4" for specific pages
 	isParallel    bool   // Flag to indicate if processing should be parallelized
 	writeResponse bool   // Flag to indicate if the response should be written to a file
+	printResponse bool   // Flag to indicate if written responses should also be printed
+
+	skipBlank      bool    // Flag to indicate if near-blank pages should not be sent to the API
+	blankThreshold float64 // Ink coverage ratio below which a page is considered blank
+
+	textFirst    bool   // Flag to indicate if pages with a text layer should be sent as text instead of images
+	pageMode     string // How PDF pages are sent: image, auto (as --text-first) or text
+	minTextChars int    // Minimum number of letters/digits for a text layer to be considered usable
+
+	useCache bool   // Flag to indicate if rendered pages and responses should be cached between runs
+	cacheDir string // Directory of the render cache, defaults to the user cache directory
+	force    bool   // Flag to indicate if requests should be sent again even if a response is cached
+
+	windowSize int // Number of pages rendered and sent before memory is released
+
+	twoPass        bool   // Flag to indicate if a thumbnail relevance pass should select the pages to process
+	relevanceQuery string // Question used by the relevance pass, defaults to the prompt
+	thumbWidth     int    // Width of the thumbnails sent by the relevance pass
+
+	extractImages bool // Flag to indicate if embedded images should be sent as additional inputs
+	minImageSize  int  // Minimum width/height of embedded images to send
+
+	withAttachments bool // Flag to indicate if embedded files should be sent as extra context
+
+	annotate bool // Flag to indicate if an annotated copy of the PDF should be written
+
+	searchable   bool // Flag to indicate if a searchable copy of the PDF should be written from the responses
+	ocrPositions bool // Flag to indicate if the model should be asked for line positions
+
+	bySection    bool // Flag to indicate if the document should be processed per outline section
+	sectionDepth int  // Outline depth used to split the document into sections
+
+	crop      string   // Region of every page to render, "x,y,w,h" in points or percent
+	pageCrops []string // Per-page regions as "page=x,y,w,h", overriding crop
+
+	layoutMode string // Layout analysis mode: off, hints or regions
+
+	localOCR    string // Local OCR mode: off, fallback or only
+	ocrLanguage string // Tesseract language of the local OCR
+
+	maxTokens int     // Token limit of the requests of a run
+	maxCost   float64 // Cost limit of the requests of a run
+
+	crossValidate      bool    // Flag to indicate if responses should be compared with the local OCR
+	agreementThreshold float64 // Similarity below which a response disagrees with the local OCR
+
+	maxImageBytes  string // Byte budget of every page image, e.g. "1.5MB"
+	imageFormat    string // Output image format: jpeg, webp or auto
+	renderDPI      int    // Resolution pages are rendered at instead of the fixed width
+	grayscale      bool   // Flag to indicate if page images should be converted to grayscale
+	autoCrop       bool   // Flag to indicate if the whitespace margins of page images should be cropped
+	splitLongPages bool   // Flag to indicate if tall pages should be sent as several tiles
+
+	asCompleted bool // Flag to indicate if pages should be sent as soon as rendered instead of in page order
+
+	requestWorkers int     // Number of page requests sent at a time
+	requestRate    float64 // Requests started per second, unbounded if 0
+
+	pagesPerRequest int    // Number of consecutive pages sent in a single request
+	pageContextMode string // Context of the earlier pages added to every request: off, previous or summary
+
+	contextTokens int    // Context window of the model in tokens
+	compressMode  string // Compression of the prompts exceeding the context window: trim, summarize or off
+
+	fallbackModel string // Smaller model answering the rest of the run once the model is overloaded
+	fallbackAfter int    // Consecutive overloaded requests switching the run to the fallback model
+
+	maxInflightBytes string // Byte budget of the rendered pages waiting for a request, e.g. "200MB"
+	maxRequestBytes  string // Size limit of a serialized request, e.g. "32MB"
+
+	outputLayout string // Output directory layout: flat, per-doc or per-run
+	uploadImages bool   // Flag to indicate if images should be uploaded when --output is an object storage URL
+
+	markdownOut bool // Flag to indicate if pages should be reconstructed as Markdown into document.md
+
+	templateName string   // Name of the prompt template to use
+	templateVars []string // Template variables as key=value
+	templateDir  string   // Directory of user prompt templates
+
+	responseFilters []string // Response filters: fences, thinking, preamble, trim, default or s/regex/repl/
+
+	uniaiModel     string   // Model answering the requests
+	renderMarkdown bool     // Flag to indicate if responses printed to the terminal should be rendered as Markdown
+	prettyJSON     bool     // Flag to indicate if JSON responses printed to the terminal should be re-indented and highlighted
+	compareModels  []string // Models every request is sent to for comparison; the first one answers
+
+	preHooks    []string      // Commands preprocessing page images
+	postHooks   []string      // Commands postprocessing responses
+	hookTimeout time.Duration // Time limit of a single hook invocation
+
+	// activePreset is the task preset selected with 'uniai run --task'.
+	activePreset *cli.Preset
+
+	// runSpend tracks the usage of the run against --max-tokens and
+	// --max-cost.
+	runSpend *cli.SpendLimit
 )
 
+// defaultWindowSize is the number of pages processed per window by default.
+const defaultWindowSize = 20
+
 var uniaiCmd = &cobra.Command{
 	Use:   "uniai",
 	Short: "UniAI is a CLI client for interacting with UniAI models.",
 	Long: `UniAI is a command-line interface (CLI) client designed to interact with UniAI models,
 providing functionalities such as pdf to text generation, document QA, and make structured data.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		if filePath == "" || outputDir == "" || prompt == "" {
-			cmd.Help()
-			return
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if filePath == "" || outputDir == "" || (prompt == "" && templateName == "") {
+			return cmd.Help()
+		}
+		// From here on errors are failures of the run, not usage errors.
+		cmd.SilenceUsage = true
+
+		if templateName != "" {
+			rendered, err := renderTemplate(templateName, prompt)
+			if err != nil {
+				return fmt.Errorf("failed to render prompt template: %w", err)
+			}
+			prompt = rendered
 		}
 
 		var (
@@ -44,28 +155,42 @@ providing functionalities such as pdf to text generation, document QA, and make
 		if pageRange != "" {
 			pageNumbers, err = cli.ParsePageRange(pageRange)
 			if err != nil {
-				println("Invalid page range format:", err.Error())
-				return
+				return fmt.Errorf("invalid page range format: %w", err)
 			}
 		}
 
-		// Read the file and process it
-		fp, err := os.ReadFile(filePath)
-		if err != nil {
-			println("Failed to read file:", err.Error())
-			return
-		}
+		ctx := cmd.Context()
 
-		pdfReader, err := model.NewPdfReader(bytes.NewReader(fp))
-		if err != nil {
-			println("Failed to open PDF file:", err.Error())
-			return
+		// Remote documents are streamed to a temporary file, as PDF readers
+		// need random access.
+		source := filePath
+		if storage.IsRemote(filePath) {
+			logger.Info("downloading", "source", source)
+			local, err := storage.Download(ctx, source)
+			if err != nil {
+				return fmt.Errorf("failed to download file: %w", err)
+			}
+			defer os.RemoveAll(filepath.Dir(local))
+			filePath = local
 		}
 
-		numPages, err := pdfReader.GetNumPages()
-		if err != nil {
-			println("Failed to get number of pages:", err.Error())
-			return
+		// HTML pages and emails are converted into pages of text and images
+		// up front; everything else is read as a PDF.
+		var (
+			doc      *cli.Document
+			numPages int
+		)
+		if cli.IsConvertible(filePath) {
+			doc, err = cli.ConvertFile(filePath)
+			if err != nil {
+				return fmt.Errorf("failed to convert file: %w", err)
+			}
+			numPages = len(doc.Pages)
+		} else {
+			numPages, err = countPages(filePath)
+			if err != nil {
+				return fmt.Errorf("failed to open PDF file: %w", err)
+			}
 		}
 
 		if len(pageNumbers) == 0 {
@@ -75,173 +200,710 @@ providing functionalities such as pdf to text generation, document QA, and make
 			}
 		}
 
-		type renderedPage struct {
-			pageNum  int
-			filePath string
+		if !cli.ValidOutputLayout(outputLayout) {
+			return fmt.Errorf("invalid output layout: %s", outputLayout)
+		}
+		if reportFormat != "" && !cli.ValidReportFormat(reportFormat) {
+			return fmt.Errorf("invalid report format: %s", reportFormat)
 		}
-		renderedPages := make([]renderedPage, numPages)
 
-		var (
-			wg  sync.WaitGroup
-			sem = make(chan struct{}, 3) // Semaphore to limit concurrency
-		)
+		// The content hash keys the render cache and tells apart documents
+		// with the same file name in the output directory.
+		fileHash, err := cli.HashFile(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to hash file: %w", err)
+		}
+
+		// Results for object storage are staged in a temporary directory and
+		// uploaded at the end of the run.
+		localOutput := outputDir
+		if storage.IsRemote(outputDir) {
+			if !storage.CanUpload(outputDir) {
+				return fmt.Errorf("invalid output location: %s", outputDir)
+			}
+			localOutput, err = os.MkdirTemp("", "uniai-output-*")
+			if err != nil {
+				return fmt.Errorf("failed to create staging directory: %w", err)
+			}
+			defer os.RemoveAll(localOutput)
+		}
+
+		out, err := cli.NewOutputDir(localOutput, outputLayout, filePath, fileHash, incremental, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+
+		switch layoutMode {
+		case cli.LayoutOff, cli.LayoutHints, cli.LayoutRegions:
+		default:
+			return fmt.Errorf("invalid layout mode: %s", layoutMode)
+		}
+
+		if err := applyOutputFormats(); err != nil {
+			return err
+		}
+
+		switch pageMode {
+		case cli.PageModeImage:
+			if textFirst {
+				if cmd.Flags().Changed("mode") {
+					return errors.New("--text-first cannot be used with --mode image")
+				}
+				pageMode = cli.PageModeAuto
+			}
+		case cli.PageModeAuto, cli.PageModeText:
+			textFirst = true
+		default:
+			return fmt.Errorf("invalid page mode: %s", pageMode)
+		}
+
+		switch localOCR {
+		case cli.LocalOCROff, cli.LocalOCRFallback:
+		case cli.LocalOCROnly:
+			if twoPass || len(compareModels) > 0 || crossValidate {
+				return errors.New("--local-ocr only cannot be used with --two-pass, --models or --cross-validate, which send the pages to the API")
+			}
+		default:
+			return fmt.Errorf("invalid local OCR mode: %s", localOCR)
+		}
+
+		if pagesPerRequest < 1 {
+			return fmt.Errorf("invalid pages per request: %d", pagesPerRequest)
+		}
+		if pagesPerRequest > 1 && (bySection || asCompleted) {
+			return errors.New("--pages-per-request cannot be used with --by-section, which already sends a section per request, or --as-completed, which sends pages out of order")
+		}
+		if requestWorkers < 1 || requestRate < 0 {
+			return fmt.Errorf("invalid workers or request rate: %d, %g", requestWorkers, requestRate)
+		}
+		if requestWorkers > 1 && pagesPerRequest > 1 {
+			return errors.New("--workers cannot be used with --pages-per-request, whose requests take consecutive pages")
+		}
+		if incremental && (bySection || pagesPerRequest > 1 || len(compareModels) > 0) {
+			return errors.New("--incremental reuses the responses of single pages, it cannot be used with --by-section, --pages-per-request or --models")
+		}
+		if incremental && storage.IsRemote(outputDir) {
+			return errors.New("--incremental needs the page index of the previous run, it cannot be used with an object storage --output")
+		}
+
+		format, err := activePreset.Format()
+		if err != nil {
+			return fmt.Errorf("invalid task preset: %w", err)
+		}
+		var schema map[string]any
+		if activePreset != nil && len(activePreset.Schema) > 0 {
+			schema = activePreset.Schema
+		}
+		if schemaFile != "" {
+			if schema, format, err = loadSchema(schemaFile); err != nil {
+				return fmt.Errorf("failed to load schema: %w", err)
+			}
+			jsonOut = true
+		}
+		if jsonOut && (markdownOut || ocrPositions || localOCR != cli.LocalOCROff) {
+			return errors.New("--json cannot be used with --markdown, --ocr-positions or --local-ocr, which ask for other answers")
+		}
+		if jsonOut && format == nil {
+			format = jsonFormat
+		}
+		if jsonOut && !writeResponse {
+			// The answers are written as page_N.json, and still printed.
+			writeResponse, printResponse = true, true
+		}
+
+		modelName := uniaiModel
+		if len(compareModels) > 0 {
+			modelName = compareModels[0]
+		}
+		var selection *cli.ModelSelection
+		if modelName == cli.ModelAuto {
+			if selection, err = selectModel(doc, len(pageNumbers), format != nil); err != nil {
+				return fmt.Errorf("failed to select model: %w", err)
+			}
+			logger.Info("model selected", "model", selection.Model, "tier", selection.Tier, "reason", selection.Reason)
+			modelName = selection.Model
+		}
+
+		proc := &pageProcessor{
+			out:      out,
+			fileHash: fileHash,
+			settings: cli.DefaultRenderSettings,
+			manifest: cli.NewManifest(out.Dir, source, fileHash, modelName, prompt),
+			options:  uniai.DefaultOptions,
+			format:   format,
+			schema:   schema,
+			model:    modelName,
+			errors:   &cli.PageErrors{Doc: source},
+			pacer:    cli.NewPacer(),
+		}
+		proc.pacer.SetRate(requestRate)
+		proc.pageContext, err = cli.NewPageContext(pageContextMode)
+		if err != nil {
+			return err
+		}
+		if proc.pageContext != nil && asCompleted {
+			return errors.New("--page-context needs the pages in order, it cannot be used with --as-completed")
+		}
+		if proc.pageContext != nil && requestWorkers > 1 {
+			return errors.New("--page-context needs the answers to the earlier pages, it cannot be used with --workers")
+		}
+		if proc.pageContext != nil && incremental {
+			return errors.New("--page-context needs the answers to the earlier pages, it cannot be used with --incremental")
+		}
+		proc.checkpoint, err = cli.OpenCheckpoint(out.Path(cli.CheckpointFile))
+		if err != nil {
+			return fmt.Errorf("failed to open checkpoint: %w", err)
+		}
+		defer proc.checkpoint.Close()
+		if writeDataset {
+			proc.dataset, err = cli.CreateDataset(out.Path(cli.DatasetFile))
+			if err != nil {
+				return fmt.Errorf("failed to create dataset: %w", err)
+			}
+			defer proc.dataset.Close()
+		}
+		if len(compareModels) > 1 {
+			proc.comparison = cli.NewComparison(compareModels)
+		}
+		if crossValidate {
+			proc.crossChecks = cli.NewCrossCheck(agreementThreshold)
+		}
+		for _, spec := range responseFilters {
+			filters, err := uniai.ParseFilter(spec)
+			if err != nil {
+				return fmt.Errorf("invalid response filter: %w", err)
+			}
+			proc.filters = append(proc.filters, filters...)
+		}
+		if len(preHooks) > 0 || len(postHooks) > 0 {
+			proc.hooks = &cli.Hooks{}
+			for _, command := range preHooks {
+				proc.hooks.AddImagePreprocessor(cli.ExecImagePreprocessor(command, hookTimeout))
+			}
+			for _, command := range postHooks {
+				proc.hooks.AddResponsePostprocessor(cli.ExecResponsePostprocessor(command, hookTimeout))
+			}
+		}
+		proc.system, err = loadSystemPrompt(systemPreset)
+		if err != nil {
+			return err
+		}
+		if activePreset != nil {
+			if activePreset.System != "" && !cmd.Flags().Changed("system-preset") {
+				proc.system = activePreset.System
+			}
+			proc.options = cli.MergeOptions(uniai.DefaultOptions, activePreset.Options)
+		}
 
-		base := filepath.Base(filePath) // "report 2025.pdf"
-		dirName := strings.TrimSuffix(base, filepath.Ext(base))
+		switch compressMode {
+		case uniai.CompressTrim, uniai.CompressSummarize, uniai.CompressOff:
+		default:
+			return fmt.Errorf("invalid prompt compression: %s", compressMode)
+		}
+		if contextTokens < 0 {
+			return fmt.Errorf("invalid context window: %d", contextTokens)
+		}
+		if fallbackModel != "" {
+			if fallbackAfter < 1 {
+				return fmt.Errorf("invalid fallback threshold: %d", fallbackAfter)
+			}
+			proc.fallback = cli.NewModelFallback(fallbackModel, fallbackAfter)
+		}
+
+		proc.settings.MaxBytes, err = cli.ParseByteSize(maxImageBytes)
+		if err != nil {
+			return fmt.Errorf("invalid image size budget: %w", err)
+		}
+
+		inflight, err := cli.ParseByteSize(maxInflightBytes)
+		if err != nil {
+			return fmt.Errorf("invalid in-flight byte budget: %w", err)
+		}
+		proc.budget = cli.NewByteBudget(int64(inflight))
 
-		outDir := filepath.Join(outputDir, dirName)
-		if _, err := os.Stat(outDir); os.IsNotExist(err) {
-			err = os.MkdirAll(outDir, 0755)
+		proc.maxRequest, err = cli.ParseByteSize(maxRequestBytes)
+		if err != nil {
+			return fmt.Errorf("invalid request size limit: %w", err)
+		}
+
+		if !cli.ValidFormat(imageFormat) {
+			return fmt.Errorf("invalid image format: %s", imageFormat)
+		}
+		proc.settings.Format = imageFormat
+
+		if !cli.ValidDPI(renderDPI) {
+			return fmt.Errorf("invalid DPI: %d", renderDPI)
+		}
+		proc.settings.DPI = renderDPI
+		proc.settings.Grayscale = grayscale
+		proc.settings.TrimMargins = autoCrop
+
+		if crop != "" {
+			box, err := cli.ParseCropBox(crop)
 			if err != nil {
-				println("Failed to create output directory:", err.Error())
-				return
+				return fmt.Errorf("invalid crop: %w", err)
 			}
+			proc.settings.Crop = &box
+		}
+		proc.crops, err = cli.ParseCropMap(pageCrops)
+		if err != nil {
+			return fmt.Errorf("invalid page crop: %w", err)
 		}
 
-		for _, pageNum := range pageNumbers {
-			if pageNum < 1 || pageNum > numPages {
-				println("Page number out of range:", pageNum)
-				continue
+		proc.settings.BlurPII = blurPII
+		if scrubPII {
+			if storage.IsRemote(outputDir) && piiMapPath == "" {
+				return errors.New("--scrub-pii with an object storage --output requires --pii-map, the mapping is never uploaded")
 			}
+			proc.pii = cli.NewPIIMap()
+		}
 
-			if isParallel {
-				wg.Add(1)
-				sem <- struct{}{} // Acquire a semaphore slot
-				go func(pageNum int) {
-					defer wg.Done()
-					defer func() { <-sem }()
-
-					newReader, err := model.NewPdfReader(bytes.NewReader(fp))
-					page, err := newReader.GetPage(pageNum)
-					if err != nil {
-						println("Failed to get page:", err.Error())
-						return
-					}
-
-					// Render the page to an image
-					output, err := cli.RenderPdfPage(pageNum, page, outDir)
-					if err != nil {
-						println("Failed to render page:", err.Error())
-						return
-					}
-					renderedPages[pageNum-1] = renderedPage{
-						pageNum:  pageNum,
-						filePath: output,
-					}
-					println("Rendered page", pageNum, "to", output)
-				}(pageNum)
-			} else {
-				page, err := pdfReader.GetPage(pageNum)
+		if useCache {
+			dir := cacheDir
+			if dir == "" {
+				dir, err = cli.DefaultCacheDir()
 				if err != nil {
-					println("Failed to get page:", err.Error())
-					continue
+					return fmt.Errorf("failed to resolve cache directory: %w", err)
 				}
+			}
+			proc.cache, err = cli.NewRenderCache(dir)
+			if err != nil {
+				return fmt.Errorf("failed to open render cache: %w", err)
+			}
 
-				// Render the page to an image
-				output, err := cli.RenderPdfPage(pageNum, page, outputDir)
+			responseDir := filepath.Join(cacheDir, "responses")
+			if cacheDir == "" {
+				responseDir, err = cli.DefaultResponseCacheDir()
 				if err != nil {
-					println("Failed to render page:", err.Error())
-					continue
-				}
-				renderedPages[pageNum-1] = renderedPage{
-					pageNum:  pageNum,
-					filePath: output,
+					return fmt.Errorf("failed to resolve response cache directory: %w", err)
 				}
-				println("Rendered page", pageNum, "to", output)
+			}
+			proc.responses, err = cli.NewResponseCache(responseDir)
+			if err != nil {
+				return fmt.Errorf("failed to open response cache: %w", err)
 			}
 		}
-		wg.Wait()
 
 		// Init UniAI client
-		uniaiClient, err := uniai.NewClient(os.Getenv("API_BASEURL"), nil, os.Getenv("API_AUTH"))
+		runSpend = cli.NewSpendLimit(maxTokens, maxCost)
+		proc.client, err = newClient(cmd, filePath)
 		if err != nil {
-			println("Failed to initialize UniAI client:", err.Error())
-			return
+			return fmt.Errorf("failed to initialize UniAI client: %w", err)
+		}
+		// The model behind an alias and the options of its profile are
+		// recorded and cached as sent.
+		if model := proc.client.ResolveModel(proc.model); model != proc.model {
+			logger.Info("model alias resolved", "alias", proc.model, "model", model)
+			proc.model = model
+		}
+		proc.options = proc.client.ResolveOptions(proc.model, proc.options)
+		if window := cmp.Or(contextTokens, cli.ContextWindow(proc.options)); window > 0 {
+			proc.compressor = &uniai.Compressor{Mode: compressMode, ContextTokens: window, Options: proc.options}
+		}
+		proc.router, err = newRouter(cmd, filePath)
+		if err != nil {
+			return fmt.Errorf("failed to load routes: %w", err)
+		}
+		proc.pages = numPages
+
+		proc.run = cli.NewRunInfo(source, proc.model, proc.system, proc.basePrompt(), proc.format, proc.options)
+		proc.run.ModelSelection = selection
+		// The API is checked before any page is rendered, so a wrong
+		// endpoint, credentials or model fail the run at once.
+		switch {
+		case localOCR == cli.LocalOCROnly || offline:
+		case preflightEnabled():
+			models := compareModels
+			if len(models) == 0 {
+				models = []string{proc.model}
+			}
+			if fallbackModel != "" {
+				models = append(slices.Clip(models), fallbackModel)
+			}
+			proc.run.ServerVersion, err = preflight(ctx, proc.client, models)
+			if err != nil && localOCR == cli.LocalOCRFallback && cli.Unreachable(err) {
+				logger.Warn("the API is unreachable, pages will fall back to the local OCR", "err", err)
+			} else if err != nil {
+				return fmt.Errorf("preflight check failed: %w", err)
+			}
+		default:
+			if proc.run.ServerVersion, err = proc.client.Version(ctx); err != nil {
+				logger.Warn("failed to get server version", "err", err)
+			}
 		}
 
-		for _, page := range renderedPages {
-			println("Rendered page", page.pageNum, "saved to", page.filePath)
-			fb, err := os.ReadFile(page.filePath)
-			if err != nil {
-				println("Failed to read file for page", page.pageNum, ":", err.Error())
+		if withAttachments && doc == nil {
+			if err := proc.loadAttachments(); err != nil {
+				return fmt.Errorf("failed to read attachments: %w", err)
+			}
+		}
+
+		var selected []int
+		for _, pageNum := range pageNumbers {
+			if pageNum < 1 || pageNum > numPages {
+				proc.fail(stageSelect, []int{pageNum}, fmt.Errorf("page number out of range 1-%d", numPages))
 				continue
 			}
+			selected = append(selected, pageNum)
+		}
 
-			if writeResponse {
-				var (
-					respDir          string
-					responseFilePath string
-					rf               *os.File
-				)
-				// write response to a in directory response
-				respDir = filepath.Join(outDir, "response")
-				if _, err := os.Stat(respDir); os.IsNotExist(err) {
-					err = os.MkdirAll(respDir, 0755)
-					if err != nil {
-						println("Failed to create response directory:", err.Error())
-						continue
-					}
-				}
-				responseFilePath = filepath.Join(respDir, fmt.Sprintf("page_%d.txt", page.pageNum))
-				rf, err = os.Create(responseFilePath)
-				if err != nil {
-					println("Failed to create response file for page", page.pageNum, ":", err.Error())
-					continue
-				}
-				defer rf.Close()
+		if doc != nil {
+			if incremental {
+				logger.Warn("--incremental only applies to PDF documents, processing every page")
+			}
+			proc.processDocument(ctx, doc, selected)
+			return finish(ctx, proc, out, localOutput, nil, writeMarkdown(proc, out), writeJSONDocument(proc, out, source))
+		}
 
-				os.Stderr = rf // Redirect stderr to the response file
+		var index *pageIndex
+		if incremental {
+			if index, err = openPageIndex(proc, out, filePath); err != nil {
+				return fmt.Errorf("failed to open page index: %w", err)
 			}
+			selected = index.reuse(proc, selected)
+		}
 
-			requestGen := uniai.GenerateRequest{
-				Model:   uniai.ModelDefault,
-				Prompt:  prompt,
-				Images:  []uniai.ImageData{fb},
-				System:  "If user mentioned to process with 'high precision', it means prioritize to OCR the image file from request",
-				Options: uniai.DefaultOptions,
+		var skippedPages []renderedPage
+		if twoPass {
+			var irrelevant []renderedPage
+			selected, irrelevant = proc.filterRelevant(ctx, selected)
+			skippedPages = append(skippedPages, irrelevant...)
+		}
+
+		if bySection {
+			sections, err := proc.readSections()
+			if err != nil {
+				return fmt.Errorf("failed to read document outline: %w", err)
 			}
+			if len(sections) > 0 {
+				skippedPages = append(skippedPages, proc.processSections(ctx, sections, selected)...)
+				err := finish(ctx, proc, out, localOutput, skippedPages, writeJSONDocument(proc, out, source))
+				printSkipped(skippedPages)
+				return err
+			}
+			logger.Info("document has no outline, processing page by page")
+		}
 
-			println("User prompt:", requestGen.Prompt)
-			println("System prompt:", requestGen.System)
-			println("Response:")
-			if writeResponse {
-				println("Response written to file")
+		skippedPages = append(skippedPages, proc.process(ctx, selected)...)
+		var errs []error
+		if index != nil {
+			if err := index.write(proc, source, fileHash); err != nil {
+				errs = append(errs, fmt.Errorf("failed to write page index: %w", err))
 			}
+		}
 
-			ctx := context.Background()
-			funcResp := func(resp uniai.GenerateResponse) error {
-				// Handle the response from UniAI.
-				// For example, you could print the response or save it to a file.
-				fmt.Fprint(os.Stderr, resp.Response)
-				if resp.Done {
-					fmt.Fprintln(os.Stderr)
-					resp.Summary()
-				}
+		// The responses are read back from the checkpoint rather than
+		// held in memory during the run.
+		responses, err := proc.checkpoint.Responses()
+		if err != nil {
+			return finish(ctx, proc, out, localOutput, skippedPages, fmt.Errorf("failed to read checkpoint: %w", err))
+		}
+		errs = append(errs, writeMarkdown(proc, out), writeJSONDocument(proc, out, source))
 
-				return nil
+		if annotate && len(responses) > 0 {
+			annotated := filepath.Join(out.Dir, out.Name+"_annotated.pdf")
+			err := cli.AnnotatePdf(filePath, annotated, responses, "UniAI "+proc.model)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to write annotated PDF: %w", err))
+			} else {
+				logger.Info("annotated PDF written", "path", annotated)
+				proc.record(cli.ArtifactAnnotatedPdf, annotated, nil, "")
 			}
+		}
 
-			err = uniaiClient.Generate(ctx, &requestGen, funcResp)
-			if err != nil {
-				println("Failed to generate response for page", page.pageNum, ":", err.Error())
-				continue
+		if searchable && len(responses) > 0 {
+			texts := make(map[int][]cli.OcrLine, len(responses))
+			for pageNum, response := range responses {
+				texts[pageNum] = cli.ParseOcrLines(response)
+			}
+
+			searchablePath := filepath.Join(out.Dir, out.Name+"_searchable.pdf")
+			if err := cli.WriteSearchablePdf(filePath, searchablePath, texts); err != nil {
+				errs = append(errs, fmt.Errorf("failed to write searchable PDF: %w", err))
+			} else {
+				logger.Info("searchable PDF written", "path", searchablePath)
+				proc.record(cli.ArtifactSearchablePdf, searchablePath, nil, "")
 			}
-			fmt.Println()
 		}
+
+		err = finish(ctx, proc, out, localOutput, skippedPages, errs...)
+		printSkipped(skippedPages)
+		return err
 	},
 }
 
+// writeMarkdown stitches the Markdown responses of the checkpoint into a
+// single document when --markdown or --output-format md is set.
+func writeMarkdown(proc *pageProcessor, out cli.OutputDir) error {
+	if !markdownOut && !stitchOut {
+		return nil
+	}
+	responses, err := proc.checkpoint.Responses()
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+
+	path := out.Path(cli.MarkdownFile)
+	if err := os.WriteFile(path, []byte(cli.StitchMarkdown(responses)), 0644); err != nil {
+		return fmt.Errorf("failed to write Markdown document: %w", err)
+	}
+	logger.Info("Markdown document written", "path", path)
+	proc.record(cli.ArtifactMarkdown, path, nil, "")
+
+	return nil
+}
+
+// finish copies the responses to the clipboard with --copy, writes the
+// manifest, the metadata and page metrics of the run and the report of
+// --report and, when --output is an object storage URL, uploads the results
+// staged in localOutput, then writes the summary of --summary or
+// --summary-file. It returns the errors of the run: errs, the outputs that
+// could not be written or uploaded, and the failed pages.
+func finish(ctx context.Context, proc *pageProcessor, out cli.OutputDir, localOutput string, skipped []renderedPage, errs ...error) error {
+	if proc.comparison != nil {
+		errs = append(errs, writeComparison(proc, out))
+	}
+	if proc.crossChecks != nil {
+		errs = append(errs, writeCrossCheck(proc, out))
+	}
+
+	if n := len(proc.run.Unprocessed); n > 0 {
+		logger.Warn("pages were not processed because the budget of the run is exhausted; run again with a higher budget to resume, answered pages are reused from the response cache", "pages", proc.run.Unprocessed)
+	}
+
+	if err := proc.checkpoint.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to write checkpoint: %w", err))
+	} else {
+		proc.record(cli.ArtifactCheckpoint, proc.checkpoint.Path(), nil, "")
+		errs = append(errs, copyToClipboard(proc), writeResponsesJSONL(proc, out))
+	}
+	if err := writePIIMap(proc, out); err != nil {
+		errs = append(errs, fmt.Errorf("failed to write PII map: %w", err))
+	}
+	if err := writePIIReport(proc, out); err != nil {
+		errs = append(errs, fmt.Errorf("failed to write PII report: %w", err))
+	}
+	if proc.dataset != nil {
+		if err := proc.dataset.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write dataset: %w", err))
+		} else {
+			proc.record(cli.ArtifactDataset, proc.dataset.Path(), nil, "")
+		}
+	}
+
+	manifestPath := out.Path(cli.ManifestFile)
+	if err := proc.manifest.Write(manifestPath); err != nil {
+		errs = append(errs, fmt.Errorf("failed to write manifest: %w", err), proc.errors.Err())
+		return errors.Join(errs...)
+	}
+	logger.Info("manifest written", "path", manifestPath)
+
+	paths := []string{manifestPath}
+	runPath := out.Path(cli.RunInfoFile)
+	proc.run.Fail(proc.errors.Errors())
+	if err := proc.run.Write(runPath); err != nil {
+		errs = append(errs, fmt.Errorf("failed to write run metadata: %w", err))
+	} else {
+		logger.Info("run metadata written", "path", runPath)
+		paths = append(paths, runPath)
+	}
+	if metricsPath, err := writePageMetrics(proc, out, skipped); err != nil {
+		errs = append(errs, fmt.Errorf("failed to write page metrics: %w", err))
+	} else {
+		paths = append(paths, metricsPath)
+	}
+
+	reportPath, err := writeReport(proc, out, skipped, errors.Join(append(errs, proc.errors.Err())...))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to write report: %w", err))
+	} else if reportPath != "" {
+		paths = append(paths, reportPath)
+	}
+
+	if localOutput != outputDir {
+		errs = append(errs, upload(ctx, proc, out, localOutput, paths))
+	}
+
+	err = errors.Join(append(errs, proc.errors.Err())...)
+	if serr := writeSummary(proc, out, localOutput, skipped, err); serr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to write summary: %w", serr))
+	}
+
+	return err
+}
+
+// upload uploads paths, and the artifacts of the manifest, staged in
+// localOutput to --output.
+func upload(ctx context.Context, proc *pageProcessor, out cli.OutputDir, localOutput string, paths []string) error {
+	for _, artifact := range proc.manifest.Artifacts {
+		switch artifact.Kind {
+		case cli.ArtifactPageImage, cli.ArtifactEmbeddedImage:
+			if !uploadImages {
+				continue
+			}
+		}
+		paths = append(paths, filepath.Join(out.Dir, filepath.FromSlash(artifact.Path)))
+	}
+
+	var errs []error
+	for _, path := range paths {
+		rel, err := filepath.Rel(localOutput, path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to upload %s: %w", path, err))
+			continue
+		}
+
+		target := storage.Join(outputDir, rel)
+		if err := storage.Upload(ctx, target, path); err != nil {
+			errs = append(errs, fmt.Errorf("failed to upload %s: %w", rel, err))
+			continue
+		}
+		logger.Info("uploaded", "target", target)
+	}
+
+	return errors.Join(errs...)
+}
+
+// writeCrossCheck writes the agreement of the responses with the local OCR
+// and reports the requests where they disagree.
+func writeCrossCheck(proc *pageProcessor, out cli.OutputDir) error {
+	path := out.Path(cli.CrossCheckFile)
+	if err := proc.crossChecks.WriteJSON(path); err != nil {
+		return fmt.Errorf("failed to write cross-validation: %w", err)
+	}
+	proc.record(cli.ArtifactCrossCheck, path, nil, "")
+	logger.Info("cross-validation written", "path", path)
+	if proc.crossChecks.Flagged > 0 {
+		logger.Warn("responses disagree with the local OCR", "count", proc.crossChecks.Flagged)
+	}
+
+	return nil
+}
+
+// writeComparison writes the answers of the models of --models side by side
+// as Markdown, and as JSON.
+func writeComparison(proc *pageProcessor, out cli.OutputDir) error {
+	path := out.Path(cli.ComparisonFile)
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write model comparison: %w", err)
+	}
+	err = proc.comparison.WriteMarkdown(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write model comparison: %w", err)
+	}
+	proc.record(cli.ArtifactComparison, path, nil, "")
+
+	jsonPath := out.Path(cli.ComparisonJsonFile)
+	if err := proc.comparison.WriteJSON(jsonPath); err != nil {
+		return fmt.Errorf("failed to write model comparison: %w", err)
+	}
+	proc.record(cli.ArtifactComparison, jsonPath, nil, "")
+	logger.Info("model comparison written", "path", path)
+
+	return nil
+}
+
+// printSkipped reports the pages that were not sent to the API.
+func printSkipped(skippedPages []renderedPage) {
+	for _, page := range skippedPages {
+		logger.Info("skipped page", "page", page.pageNum, "reason", page.reason)
+	}
+}
+
 func init() {
-	uniaiCmd.Flags().StringVarP(&filePath, "file", "f", "", "Path to the input file (PDF or text)")
-	uniaiCmd.Flags().StringVarP(&outputDir, "output", "o", "./output", "Directory to save the output files")
+	uniaiCmd.Flags().StringVarP(&filePath, "file", "f", "", "Path or URL (https://, s3://bucket/key, gs://bucket/object) of the input file (PDF or text)")
+	uniaiCmd.Flags().StringVarP(&outputDir, "output", "o", "./output", "Directory or object storage URL (s3://bucket/prefix, gs://bucket/prefix) to save the output files")
 	uniaiCmd.Flags().StringVarP(&prompt, "prompt", "m", "", "Prompt for the model (required for some commands)")
 	uniaiCmd.Flags().StringVarP(&pageRange, "pages", "r", "", "Page range to process (e.g., '1-3' for pages 1 to 3, '1,2,4' for specific pages)")
 	uniaiCmd.Flags().BoolVarP(&isParallel, "parallel", "p", false, "Enable parallel processing of pages (if applicable)")
+	uniaiCmd.Flags().IntVar(&requestWorkers, "workers", 1, "Page requests sent at a time, while the next pages render; with more than one, responses are printed once complete")
+	uniaiCmd.Flags().Float64Var(&requestRate, "rps", 0, "Requests started per second at most, unbounded if 0")
 	uniaiCmd.Flags().BoolVarP(&writeResponse, "write-response", "w", false, "Write the response to a file (if applicable)")
+	uniaiCmd.Flags().BoolVar(&printResponse, "print-response", false, "With --write-response, also print the responses to the console")
+	uniaiCmd.Flags().BoolVar(&skipBlank, "skip-blank", false, "Skip near-blank pages instead of sending them to the API")
+	uniaiCmd.Flags().Float64Var(&blankThreshold, "blank-threshold", cli.DefaultBlankThreshold, "Ink coverage ratio (0-1) below which a page is considered blank")
+	uniaiCmd.Flags().BoolVar(&textFirst, "text-first", false, "Send pages with an extractable text layer as text instead of rendering them (same as --mode auto)")
+	uniaiCmd.Flags().StringVar(&pageMode, "mode", cli.PageModeImage, "How PDF pages are sent: 'image' (render every page), 'auto' (send the text layer of pages with at least --min-text-chars, render the others) or 'text' (send any text layer, render only pages without text)")
+	uniaiCmd.Flags().IntVar(&minTextChars, "min-text-chars", cli.DefaultMinTextChars, "Minimum letters/digits for a page text layer to be used with --mode auto or --text-first")
+	uniaiCmd.Flags().BoolVar(&useCache, "cache", true, "Reuse rendered pages, and responses to unchanged pages with the same prompt, model and options, from previous runs")
+	uniaiCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Directory of the render cache, with the response cache in its 'responses' subdirectory (defaults to the user cache directory)")
+	uniaiCmd.Flags().BoolVar(&force, "force", false, "Send every request again, even if a response from a previous run is cached")
+	uniaiCmd.Flags().BoolVar(&extractImages, "extract-images", false, "Send embedded images (photos, figures, signatures) as additional inputs")
+	uniaiCmd.Flags().IntVar(&minImageSize, "min-image-size", cli.DefaultMinImageSize, "Minimum width and height in pixels of embedded images to send")
+	uniaiCmd.Flags().BoolVar(&withAttachments, "attachments", false, "Send embedded file attachments (XML, CSV, spreadsheets, images, PDFs) as extra context")
+	uniaiCmd.Flags().BoolVar(&annotate, "annotate", false, "Write a copy of the PDF with the responses as page annotations")
+	uniaiCmd.Flags().BoolVar(&searchable, "searchable", false, "Write a searchable copy of the PDF with the responses as an invisible text layer (use with an OCR prompt)")
+	uniaiCmd.Flags().BoolVar(&ocrPositions, "ocr-positions", false, "Ask the model for line positions so the searchable text layer matches the page")
+	uniaiCmd.Flags().BoolVar(&bySection, "by-section", false, "Process the document per bookmark section instead of per page ("+cli.SectionPlaceholder+" in the prompt is replaced by the section title)")
+	uniaiCmd.Flags().IntVar(&sectionDepth, "section-depth", 1, "Bookmark depth used to split the document into sections with --by-section")
+	uniaiCmd.Flags().StringVar(&crop, "crop", "", "Only render and send a region of each page, as 'x,y,w,h' from the top-left corner in points or percent (e.g., '0,70%,100%,30%')")
+	uniaiCmd.Flags().StringArrayVar(&pageCrops, "crop-page", nil, "Region of a specific page as 'page=x,y,w,h', overriding --crop (repeatable)")
+	uniaiCmd.Flags().StringVar(&layoutMode, "layout", cli.LayoutOff, "Layout analysis of rendered pages: 'off', 'hints' (describe columns in the prompt) or 'regions' (send each column/block as a separate image)")
+	uniaiCmd.Flags().StringVar(&localOCR, "local-ocr", cli.LocalOCROff, "Extract plain text with the local tesseract tool: 'off', 'fallback' (when the API is unreachable) or 'only' (never upload the document)")
+	uniaiCmd.Flags().StringVar(&ocrLanguage, "ocr-lang", cli.DefaultOCRLanguage, "Tesseract language of the local OCR (e.g., 'eng' or 'deu+fra')")
+	uniaiCmd.Flags().IntVar(&maxTokens, "max-tokens", 0, "Stop sending requests once the prompt and completion tokens of the run would exceed this limit (0 for no limit)")
+	uniaiCmd.Flags().Float64Var(&maxCost, "max-cost", 0, "Stop sending requests once the cost of the run, from the prices of the usage ledger, would exceed this limit (0 for no limit)")
+	uniaiCmd.Flags().BoolVar(&crossValidate, "cross-validate", false, "Also extract every page with the local tesseract tool and flag the responses that disagree with it in "+cli.CrossCheckFile+" (use with an OCR prompt)")
+	uniaiCmd.Flags().Float64Var(&agreementThreshold, "agreement-threshold", cli.DefaultAgreementThreshold, "Word similarity (0-1) below which a response disagrees with the local OCR")
+	uniaiCmd.Flags().StringVar(&maxImageBytes, "max-image-bytes", "", "Byte budget per page image (e.g., '1.5MB'); quality, then size, is reduced to fit")
+	uniaiCmd.Flags().IntVar(&renderDPI, "dpi", 0, "Render pages at this resolution (e.g., 150) instead of at a fixed width of "+strconv.Itoa(cli.DefaultRenderSettings.Width)+" pixels")
+	uniaiCmd.Flags().BoolVar(&grayscale, "grayscale", false, "Convert page images to grayscale, which encode smaller")
+	uniaiCmd.Flags().BoolVar(&autoCrop, "auto-crop", false, "Crop the whitespace margins of page images")
+	uniaiCmd.Flags().BoolVar(&splitLongPages, "split-long-pages", false, "Send pages more than twice as tall as wide, such as receipts, as several overlapping tiles in the same request")
+	uniaiCmd.Flags().StringVar(&imageFormat, "image-format", cli.FormatJpeg, "Page image format: 'jpeg', 'webp' (requires cwebp) or 'auto' (the smaller of both at the same quality)")
+	uniaiCmd.Flags().IntVar(&pagesPerRequest, "pages-per-request", 1, "Send this many consecutive pages in a single request, for short pages such as receipts ("+cli.PagesPlaceholder+" in the prompt is replaced by their page numbers)")
+	uniaiCmd.Flags().IntVar(&contextTokens, "context-tokens", 0, "Context window of the model in tokens (the num_ctx option of the preset by default); longer prompts are compressed with --compress")
+	uniaiCmd.Flags().StringVar(&compressMode, "compress", uniai.CompressTrim, "Compression of prompts exceeding the context window: 'trim' (keep the beginning of the context after the prompt), 'summarize' (condense it with an extra request, then trim) or 'off'")
+	uniaiCmd.Flags().StringVar(&fallbackModel, "fallback-model", "", "Smaller model answering the rest of the run once the model is overloaded, marked in the run metadata and the manifest")
+	uniaiCmd.Flags().IntVar(&fallbackAfter, "fallback-after", cli.DefaultFallbackAfter, "Consecutive overloaded or rate limited requests switching the run to --fallback-model")
+	uniaiCmd.Flags().StringVar(&pageContextMode, "page-context", cli.PageContextOff, "Context of the earlier pages added to every request, to resolve the entities and table headers they define: 'off', 'previous' (the answer to the previous page) or 'summary' (a rolling summary of all answers, updated with an extra request per page)")
+	uniaiCmd.Flags().BoolVar(&asCompleted, "as-completed", false, "With --parallel, send and print pages as soon as they are rendered instead of in page order")
+	uniaiCmd.Flags().StringVar(&outputLayout, "output-layout", cli.OutputPerDoc, "Output directory layout: 'per-doc' (a subdirectory per document), 'per-run' (a timestamped directory per run below it) or 'flat' (file names prefixed with the document name)")
+	uniaiCmd.Flags().StringVarP(&templateName, "template", "t", "", "Name of a prompt template (see 'uniai templates'); --prompt is passed to it as the 'prompt' variable")
+	uniaiCmd.Flags().StringArrayVar(&templateVars, "var", nil, "Prompt template variable as key=value (repeatable)")
+	uniaiCmd.Flags().BoolVar(&prettyJSON, "pretty", false, "Re-indent, highlight and validate JSON responses printed to a terminal (output that is not a terminal is left as is)")
+	uniaiCmd.PersistentFlags().BoolVar(&renderMarkdown, "render-markdown", false, "Render the Markdown of responses printed to the terminal (headings, emphasis, tables, code blocks)")
+	uniaiCmd.PersistentFlags().StringVar(&templateDir, "template-dir", "", "Directory of user prompt templates (defaults to the user config directory)")
+	uniaiCmd.Flags().BoolVar(&markdownOut, "markdown", false, "Ask for a layout-preserving Markdown rendition of every page and stitch the answers into document.md")
+	uniaiCmd.Flags().BoolVar(&uploadImages, "upload-images", false, "With an s3:// or gs:// --output, also upload rendered and embedded images (responses and the manifest are always uploaded)")
+	uniaiCmd.Flags().StringVar(&maxInflightBytes, "max-inflight-bytes", "", "Byte budget of rendered pages waiting for a request (e.g., '200MB'); render workers pause while it is exhausted")
+	uniaiCmd.Flags().StringVar(&maxRequestBytes, "max-request-bytes", "32MB", "Size limit of a request (e.g., '32MB'); larger requests are sent with downscaled images or, for sections, split ('0' disables the limit)")
+	uniaiCmd.Flags().IntVar(&windowSize, "window", defaultWindowSize, "Number of pages rendered and sent per window, bounding memory use (0 processes all pages at once)")
+	uniaiCmd.Flags().BoolVar(&twoPass, "two-pass", false, "Send low-resolution thumbnails first and only fully process pages the model flags as relevant")
+	uniaiCmd.Flags().StringVar(&relevanceQuery, "relevance-query", "", "Question used to judge page relevance in --two-pass mode (defaults to the prompt)")
+	uniaiCmd.Flags().IntVar(&thumbWidth, "thumbnail-width", cli.DefaultThumbnailSettings.Width, "Width in pixels of the thumbnails sent in --two-pass mode")
+	uniaiCmd.Flags().StringArrayVar(&responseFilters, "filter", nil, "Clean up every response with 'fences', 'thinking', 'preamble', 'trim', 'default' (thinking, preamble and fences) or a substitution 's/regex/replacement/' (repeatable, applied in order)")
+	uniaiCmd.Flags().StringVar(&uniaiModel, "model", uniai.ModelDefault, "Model answering the requests (overridden by the first of --models), or 'auto' to select it by task, page count, language and whether pages are sent as images (see --model-config)")
+	uniaiCmd.Flags().StringSliceVar(&compareModels, "models", nil, "Send every request to each of these comma-separated models and write their answers side by side with latency and token counts to comparison.md; the first model's answers are the responses of the run")
+	uniaiCmd.Flags().StringArrayVar(&preHooks, "pre-hook", nil, "Shell command preprocessing every page image before it is sent; it reads a JSON request on stdin and may write {\"image\": base64} to stdout (repeatable)")
+	uniaiCmd.Flags().StringArrayVar(&postHooks, "post-hook", nil, "Shell command postprocessing every response; it reads a JSON request on stdin and may write {\"response\": text} to stdout (repeatable)")
+	uniaiCmd.Flags().DurationVar(&hookTimeout, "hook-timeout", cli.DefaultHookTimeout, "Time limit of a single hook invocation")
 
 	uniaiCmd.MarkFlagRequired("file")
-	uniaiCmd.MarkFlagRequired("prompt")
 	uniaiCmd.MarkFlagRequired("output")
 
+	// 'uniai run' accepts the same flags; it is set up here, once they are
+	// defined.
+	runCmd.Flags().AddFlagSet(uniaiCmd.Flags())
+	// So do 'uniai replay', where the document and output directory come
+	// from the replayed run, and 'uniai batch', where the documents come from
+	// a directory.
+	uniaiCmd.Flags().VisitAll(func(f *pflag.Flag) {
+		optional := *f
+		optional.Annotations = maps.Clone(f.Annotations)
+		delete(optional.Annotations, cobra.BashCompOneRequiredFlag)
+		replayCmd.Flags().AddFlag(&optional)
+		if f.Name != "file" {
+			batchCmd.Flags().AddFlag(&optional)
+		}
+	})
+
 	rootCmd.AddCommand(uniaiCmd)
 }
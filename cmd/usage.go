@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sampila/uniai-client/internal/cli"
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+var (
+	ledgerFile string // Usage ledger file, defaults to usage.jsonl in the user config directory
+	noUsage    bool   // Flag to indicate if usage should not be recorded
+
+	usageBy    string // Grouping of the usage report: day, model, document or run
+	usageSince string // First day of the usage report, as YYYY-MM-DD
+	usageJson  bool   // Flag to indicate if the usage report should be printed as JSON
+)
+
+// usageRun identifies the records of this process in the ledger.
+var usageRun = time.Now().UTC().Format("20060102T150405") + fmt.Sprintf("-%d", os.Getpid())
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report the token usage and cost of past runs",
+	Long: `Usage reports the tokens and cost recorded in the usage ledger, grouped by day,
+model, document or run. Every request made by the uniai commands is recorded
+unless --no-usage is set.
+
+Costs are computed when a request is recorded, from the prices per million
+tokens in prices.yaml next to the ledger:
+
+  uniai01:7b: {prompt: 0.10, completion: 0.40}
+  default: {prompt: 0.50, completion: 1.50}`,
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := usageLedgerPath()
+		if err != nil {
+			println("Failed to resolve usage ledger:", err.Error())
+			return
+		}
+		records, err := cli.ReadLedger(path)
+		if err != nil {
+			println("Failed to read usage ledger:", err.Error())
+			return
+		}
+
+		if usageSince != "" {
+			since, err := time.ParseInLocation(time.DateOnly, usageSince, time.Local)
+			if err != nil {
+				println("Invalid --since date:", err.Error())
+				return
+			}
+			kept := records[:0]
+			for _, rec := range records {
+				if !rec.Time.Before(since) {
+					kept = append(kept, rec)
+				}
+			}
+			records = kept
+		}
+
+		totals, err := cli.SummarizeUsage(records, usageBy)
+		if err != nil {
+			println("Failed to summarize usage:", err.Error())
+			return
+		}
+		var all cli.UsageTotal
+		all.Key = "total"
+		for _, rec := range records {
+			all.Add(rec)
+		}
+
+		if usageJson {
+			data, err := json.MarshalIndent(map[string]any{"groups": totals, "total": all}, "", "  ")
+			if err != nil {
+				println("Failed to encode usage:", err.Error())
+				return
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(w, "%s\tREQUESTS\tPROMPT TOKENS\tCOMPLETION TOKENS\tDURATION\tCOST\t\n", usageBy)
+		for _, t := range append(totals, all) {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%.4f\t\n", t.Key, t.Requests, t.PromptTokens, t.CompletionTokens, t.Duration.Round(time.Millisecond), t.Cost)
+		}
+		w.Flush()
+	},
+}
+
+// usageLedgerPath returns the path of --ledger or of the default ledger.
+func usageLedgerPath() (string, error) {
+	if ledgerFile != "" {
+		return ledgerFile, nil
+	}
+
+	dir, err := cli.DefaultUsageDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "usage.jsonl"), nil
+}
+
+// loadPrices reads the prices next to the usage ledger at path.
+func loadPrices(path string) (cli.Prices, error) {
+	return cli.LoadPrices(filepath.Join(filepath.Dir(path), "prices.yaml"))
+}
+
+// newClient returns a client of the API configured by the environment which
+// records the usage of its requests for document in the usage ledger, and
+// the requests themselves in the audit log if enabled.
+func newClient(cmd *cobra.Command, document string) (*uniai.Client, error) {
+	client, err := newProviderClient(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordUsage(cmd, client, document); err != nil {
+		return nil, err
+	}
+
+	return client, recordAudit(cmd, client, document)
+}
+
+// recordUsage adds the requests of client about document to the usage
+// ledger, unless --no-usage is set, and to the spend limit of the run.
+func recordUsage(cmd *cobra.Command, client *uniai.Client, document string) error {
+	if noUsage && runSpend == nil {
+		return nil
+	}
+
+	path, err := usageLedgerPath()
+	if err != nil {
+		return err
+	}
+	prices, err := loadPrices(path)
+	if err != nil {
+		return err
+	}
+	runSpend.UsePrices(prices)
+
+	var ledger *cli.Ledger
+	if !noUsage {
+		ledger = cli.NewLedger(path)
+	}
+	client.OnUsage(func(model string, m uniai.Metrics) {
+		cost := prices.For(model).Cost(m.PromptEvalCount, m.EvalCount)
+		runSpend.Add(m.PromptEvalCount+m.EvalCount, cost)
+		if ledger == nil {
+			return
+		}
+
+		err := ledger.Add(cli.UsageRecord{
+			Time:             time.Now().UTC(),
+			Run:              usageRun,
+			Command:          strings.TrimSpace(cmd.CommandPath()),
+			Document:         document,
+			Model:            model,
+			PromptTokens:     m.PromptEvalCount,
+			CompletionTokens: m.EvalCount,
+			Duration:         m.TotalDuration,
+			Cost:             cost,
+		})
+		if err != nil {
+			println("Failed to record usage:", err.Error())
+		}
+	})
+
+	return nil
+}
+
+func init() {
+	uniaiCmd.PersistentFlags().StringVar(&ledgerFile, "ledger", "", "Usage ledger file (defaults to usage.jsonl in the user config directory)")
+	uniaiCmd.PersistentFlags().BoolVar(&noUsage, "no-usage", false, "Do not record token usage in the usage ledger")
+
+	usageCmd.Flags().StringVar(&usageBy, "by", cli.UsageByDay, "Group usage by 'day', 'model', 'document' or 'run'")
+	usageCmd.Flags().StringVar(&usageSince, "since", "", "Only report usage from this day on (YYYY-MM-DD)")
+	usageCmd.Flags().BoolVar(&usageJson, "json", false, "Print the report as JSON")
+
+	uniaiCmd.AddCommand(usageCmd)
+}
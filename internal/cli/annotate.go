@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// annotationSize is the side length, in points, of the note icon placed in
+// the top-left corner of annotated pages.
+const annotationSize = 24
+
+// AnnotatePdf writes a copy of the PDF at src to dst, adding a text (sticky
+// note) annotation with the given note to every page present in notes. Notes
+// are keyed by 1-based page number; title is shown as the note author.
+func AnnotatePdf(src, dst string, notes map[int]string, title string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader, err := model.NewPdfReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to open PDF file: %w", err)
+	}
+
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		return err
+	}
+
+	writer := model.NewPdfWriter()
+	for i := 1; i <= numPages; i++ {
+		page, err := reader.GetPage(i)
+		if err != nil {
+			return fmt.Errorf("failed to get page %d: %w", i, err)
+		}
+
+		if note, ok := notes[i]; ok && note != "" {
+			annot, err := newNoteAnnotation(page, note, title)
+			if err != nil {
+				return fmt.Errorf("failed to annotate page %d: %w", i, err)
+			}
+			page.AddAnnotation(annot)
+		}
+
+		if err := writer.AddPage(page); err != nil {
+			return fmt.Errorf("failed to add page %d: %w", i, err)
+		}
+	}
+
+	return writePdf(&writer, dst)
+}
+
+// writePdf serializes the document in memory first so a failed write does not
+// leave a truncated file behind.
+func writePdf(writer *model.PdfWriter, dst string) error {
+	var buf bytes.Buffer
+	if err := writer.Write(&buf); err != nil {
+		return fmt.Errorf("failed to write PDF: %w", err)
+	}
+
+	return os.WriteFile(dst, buf.Bytes(), 0644)
+}
+
+func newNoteAnnotation(page *model.PdfPage, note, title string) (*model.PdfAnnotation, error) {
+	box, err := page.GetMediaBox()
+	if err != nil {
+		return nil, err
+	}
+
+	annot := model.NewPdfAnnotationText()
+	annot.Contents = core.MakeEncodedString(note, true)
+	annot.T = core.MakeEncodedString(title, true)
+	annot.Name = core.MakeName("Comment")
+	annot.Open = core.MakeBool(false)
+	annot.Rect = core.MakeArrayFromFloats([]float64{
+		box.Llx + annotationSize/2,
+		box.Ury - annotationSize*3/2,
+		box.Llx + annotationSize*3/2,
+		box.Ury - annotationSize/2,
+	})
+
+	return annot.PdfAnnotation, nil
+}
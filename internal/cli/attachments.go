@@ -0,0 +1,327 @@
+package cli
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// DefaultMaxAttachmentText is the maximum number of bytes of text taken from
+// a single attachment.
+const DefaultMaxAttachmentText = 32 * 1024
+
+// Attachment is an embedded file converted into something the model can
+// consume: either text or an image.
+type Attachment struct {
+	Name        string
+	Description string
+
+	// Text is set for attachments that were converted to text.
+	Text string
+
+	// Image is set for image attachments.
+	Image []byte
+
+	// Unsupported is set when no handler knows the file type.
+	Unsupported bool
+}
+
+// AttachmentHandler converts the content of an embedded file.
+type AttachmentHandler func(content []byte) (Attachment, error)
+
+// attachmentHandlers maps lower-case file extensions to their handlers.
+var attachmentHandlers = map[string]AttachmentHandler{
+	".txt":  textAttachment,
+	".md":   textAttachment,
+	".csv":  textAttachment,
+	".tsv":  textAttachment,
+	".json": textAttachment,
+	".xml":  textAttachment,
+	".html": htmlAttachment,
+	".htm":  htmlAttachment,
+	".png":  imageAttachment,
+	".jpg":  imageAttachment,
+	".jpeg": imageAttachment,
+	".pdf":  pdfAttachment,
+	".xlsx": xlsxAttachment,
+}
+
+func init() {
+	// Registered here, as converting an email converts its attachments in
+	// turn.
+	attachmentHandlers[".eml"] = emailAttachment
+	attachmentHandlers[".msg"] = msgAttachment
+}
+
+// RegisterAttachmentHandler adds or replaces the handler of a file extension
+// (including the leading dot).
+func RegisterAttachmentHandler(ext string, handler AttachmentHandler) {
+	attachmentHandlers[strings.ToLower(ext)] = handler
+}
+
+// ReadAttachments returns the embedded files of the document, converted with
+// ConvertAttachment.
+func ReadAttachments(reader *model.PdfReader, maxText int) ([]Attachment, error) {
+	files, err := reader.GetAttachedFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attached files: %w", err)
+	}
+
+	attachments := make([]Attachment, 0, len(files))
+	for _, f := range files {
+		att, err := ConvertAttachment(f.Name, f.Content, maxText)
+		if err != nil {
+			return nil, err
+		}
+		att.Description = f.Description
+
+		attachments = append(attachments, att)
+	}
+
+	return attachments, nil
+}
+
+// ConvertAttachment converts a file with the handler registered for its
+// extension. When the extension is unknown the content type is sniffed. Text
+// is truncated to maxText bytes.
+func ConvertAttachment(name string, content []byte, maxText int) (Attachment, error) {
+	handler, ok := attachmentHandlers[strings.ToLower(filepath.Ext(name))]
+	if !ok {
+		handler = sniffAttachmentHandler(content)
+	}
+
+	att := Attachment{Unsupported: true}
+	if handler != nil {
+		var err error
+		att, err = handler(content)
+		if err != nil {
+			return Attachment{}, fmt.Errorf("failed to read attachment %s: %w", name, err)
+		}
+	}
+	att.Name = name
+	if maxText > 0 && len(att.Text) > maxText {
+		att.Text = att.Text[:maxText] + "\n[truncated]"
+	}
+
+	return att, nil
+}
+
+// AttachmentContext formats the text attachments as additional prompt
+// context. Image attachments are only listed by name since they are sent as
+// images.
+func AttachmentContext(attachments []Attachment) string {
+	if len(attachments) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\nThe document has the following attached files:\n")
+	for _, att := range attachments {
+		fmt.Fprintf(&sb, "\n--- Attachment: %s", att.Name)
+		if att.Description != "" {
+			fmt.Fprintf(&sb, " (%s)", att.Description)
+		}
+		sb.WriteString(" ---\n")
+
+		switch {
+		case att.Text != "":
+			sb.WriteString(att.Text)
+			sb.WriteString("\n")
+		case att.Image != nil:
+			sb.WriteString("[sent as an additional image]\n")
+		default:
+			sb.WriteString("[unsupported file type, content not included]\n")
+		}
+	}
+
+	return sb.String()
+}
+
+func sniffAttachmentHandler(content []byte) AttachmentHandler {
+	contentType := http.DetectContentType(content)
+	switch {
+	case strings.HasPrefix(contentType, "text/html"):
+		return htmlAttachment
+	case strings.HasPrefix(contentType, "text/"):
+		return textAttachment
+	case contentType == "image/png", contentType == "image/jpeg":
+		return imageAttachment
+	case contentType == "application/pdf":
+		return pdfAttachment
+	}
+
+	return nil
+}
+
+func textAttachment(content []byte) (Attachment, error) {
+	return Attachment{Text: string(content)}, nil
+}
+
+func htmlAttachment(content []byte) (Attachment, error) {
+	return Attachment{Text: HtmlToText(content)}, nil
+}
+
+// emailAttachment converts an attached message into its text and lists its
+// own attachments by name.
+func emailAttachment(content []byte) (Attachment, error) {
+	doc, err := ConvertEmail(content)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	return Attachment{Text: doc.Text()}, nil
+}
+
+func msgAttachment(content []byte) (Attachment, error) {
+	doc, err := ConvertMsg(content)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	return Attachment{Text: doc.Text()}, nil
+}
+
+func imageAttachment(content []byte) (Attachment, error) {
+	return Attachment{Image: content}, nil
+}
+
+func pdfAttachment(content []byte) (Attachment, error) {
+	reader, err := model.NewPdfReader(bytes.NewReader(content))
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	var sb strings.Builder
+	for i := 1; i <= numPages; i++ {
+		page, err := reader.GetPage(i)
+		if err != nil {
+			return Attachment{}, err
+		}
+
+		text, err := ExtractPageText(page)
+		if err != nil {
+			return Attachment{}, err
+		}
+		sb.WriteString(text)
+		sb.WriteString("\n")
+	}
+
+	return Attachment{Text: sb.String()}, nil
+}
+
+// xlsxAttachment converts the sheets of an Office Open XML workbook into
+// tab-separated text. Only cell values are kept; formatting and formulas are
+// ignored.
+func xlsxAttachment(content []byte) (Attachment, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	var sharedStrings []string
+	var sheets []*zip.File
+	for _, f := range zr.File {
+		switch {
+		case f.Name == "xl/sharedStrings.xml":
+			sharedStrings, err = readSharedStrings(f)
+			if err != nil {
+				return Attachment{}, err
+			}
+		case strings.HasPrefix(f.Name, "xl/worksheets/") && strings.HasSuffix(f.Name, ".xml"):
+			sheets = append(sheets, f)
+		}
+	}
+
+	var sb strings.Builder
+	for _, sheet := range sheets {
+		fmt.Fprintf(&sb, "# %s\n", strings.TrimSuffix(filepath.Base(sheet.Name), ".xml"))
+		if err := writeSheet(&sb, sheet, sharedStrings); err != nil {
+			return Attachment{}, err
+		}
+	}
+
+	return Attachment{Text: sb.String()}, nil
+}
+
+func readSharedStrings(f *zip.File) ([]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var sst struct {
+		Items []struct {
+			Text string `xml:"t"`
+			Runs []struct {
+				Text string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&sst); err != nil {
+		return nil, err
+	}
+
+	out := make([]string, len(sst.Items))
+	for i, item := range sst.Items {
+		out[i] = item.Text
+		for _, r := range item.Runs {
+			out[i] += r.Text
+		}
+	}
+
+	return out, nil
+}
+
+func writeSheet(w io.Writer, f *zip.File, sharedStrings []string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var sheet struct {
+		Rows []struct {
+			Cells []struct {
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&sheet); err != nil {
+		return err
+	}
+
+	for _, row := range sheet.Rows {
+		values := make([]string, len(row.Cells))
+		for i, c := range row.Cells {
+			switch c.Type {
+			case "s":
+				var idx int
+				if _, err := fmt.Sscan(c.Value, &idx); err == nil && idx >= 0 && idx < len(sharedStrings) {
+					values[i] = sharedStrings[idx]
+				}
+			case "inlineStr":
+				values[i] = c.Inline
+			default:
+				values[i] = c.Value
+			}
+		}
+		fmt.Fprintln(w, strings.Join(values, "\t"))
+	}
+
+	return nil
+}
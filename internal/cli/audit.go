@@ -0,0 +1,296 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AuditFile is the name of the audit log in the user config directory.
+const AuditFile = "audit.jsonl"
+
+// AuditKeyEnv is the environment variable holding the base64 encoded
+// AES-256 key the audit log is encrypted with, if set.
+const AuditKeyEnv = "UNIAI_AUDIT_KEY"
+
+// AuditRecord is a request and its response in the audit log.
+type AuditRecord struct {
+	Time     time.Time     `json:"time"`
+	User     string        `json:"user"`
+	Command  string        `json:"command"`
+	Document string        `json:"document,omitempty"`
+	Kind     string        `json:"kind"`
+	Model    string        `json:"model"`
+	System   string        `json:"system,omitempty"`
+	Prompt   string        `json:"prompt"`
+	Images   []string      `json:"images,omitempty"` // SHA-256 of the images sent
+	Response string        `json:"response,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+
+	// PromptHash and ResponseHash are the SHA-256 of the plain prompt and
+	// response, so they can be matched against outputs even when encrypted.
+	PromptHash   string `json:"prompt_hash"`
+	ResponseHash string `json:"response_hash"`
+	// Encrypted is set when System, Prompt and Response are sealed with the
+	// audit key, as base64 of the nonce followed by the AES-GCM ciphertext.
+	Encrypted bool `json:"encrypted,omitempty"`
+
+	// Prev is the hash of the previous record and Hash the SHA-256 of this
+	// record without it, chaining the records so edits and removals are
+	// detected by VerifyAudit.
+	Prev string `json:"prev"`
+	Hash string `json:"hash"`
+}
+
+// DefaultAuditPath returns the audit log in the user config directory.
+func DefaultAuditPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "uniai", AuditFile), nil
+}
+
+// ParseAuditKey decodes a base64 encoded AES-256 key.
+func ParseAuditKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid audit key: %d bytes instead of 32", len(key))
+	}
+
+	return key, nil
+}
+
+// AuditLog is an append-only file of audit records, one JSON object per line,
+// chained by their hashes. It is safe for concurrent use.
+type AuditLog struct {
+	path string
+	aead cipher.AEAD // encrypts the records if set
+
+	mu sync.Mutex
+}
+
+// NewAuditLog returns the audit log stored at path, encrypting the content
+// of its records with key unless it is nil.
+func NewAuditLog(path string, key []byte) (*AuditLog, error) {
+	l := &AuditLog{path: path}
+	if key != nil {
+		var err error
+		if l.aead, err = newAuditCipher(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+// Add appends a record after hashing, and encrypting if the log has a key,
+// its content. A nil log ignores the call.
+func (l *AuditLog) Add(rec AuditRecord) error {
+	if l == nil {
+		return nil
+	}
+
+	rec.PromptHash = HashBytes([]byte(rec.Prompt))
+	rec.ResponseHash = HashBytes([]byte(rec.Response))
+	if l.aead != nil {
+		for _, field := range []*string{&rec.System, &rec.Prompt, &rec.Response} {
+			if *field != "" {
+				*field = l.seal(*field)
+			}
+		}
+		rec.Encrypted = true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	last, err := lastLine(f)
+	if err == nil && len(last) > 0 {
+		var prev AuditRecord
+		if err = json.Unmarshal(last, &prev); err != nil {
+			err = fmt.Errorf("failed to parse the last audit record: %w", err)
+		}
+		rec.Prev = prev.Hash
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	rec.Hash = ""
+	if rec.Hash, err = hashAuditRecord(rec); err != nil {
+		f.Close()
+		return err
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func (l *AuditLog) seal(plain string) string {
+	nonce := make([]byte, l.aead.NonceSize())
+	rand.Read(nonce)
+
+	return base64.StdEncoding.EncodeToString(l.aead.Seal(nonce, nonce, []byte(plain), nil))
+}
+
+// Decrypt opens the encrypted content of rec with key.
+func (rec *AuditRecord) Decrypt(key []byte) error {
+	if !rec.Encrypted {
+		return nil
+	}
+
+	aead, err := newAuditCipher(key)
+	if err != nil {
+		return err
+	}
+	for _, field := range []*string{&rec.System, &rec.Prompt, &rec.Response} {
+		if *field == "" {
+			continue
+		}
+		sealed, err := base64.StdEncoding.DecodeString(*field)
+		if err != nil || len(sealed) < aead.NonceSize() {
+			return errors.New("malformed encrypted audit field")
+		}
+		plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt audit record: %w", err)
+		}
+		*field = string(plain)
+	}
+	rec.Encrypted = false
+
+	return nil
+}
+
+// ReadAudit calls fn with every record of the audit log at path and its line
+// number, in order, until fn fails.
+func ReadAudit(path string, fn func(line int, rec AuditRecord) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			var rec AuditRecord
+			if err := json.Unmarshal(data, &rec); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			if err := fn(line, rec); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// VerifyAudit checks the hash chain of the audit log at path and returns its
+// number of records.
+func VerifyAudit(path string) (int, error) {
+	n, prev := 0, ""
+	err := ReadAudit(path, func(line int, rec AuditRecord) error {
+		hash := rec.Hash
+		rec.Hash = ""
+		want, err := hashAuditRecord(rec)
+		if err != nil {
+			return err
+		}
+		if hash != want {
+			return fmt.Errorf("line %d: the record was modified", line)
+		}
+		if rec.Prev != prev {
+			return fmt.Errorf("line %d: a record before it was modified or removed", line)
+		}
+		prev = hash
+		n++
+		return nil
+	})
+
+	return n, err
+}
+
+func hashAuditRecord(rec AuditRecord) (string, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+
+	return HashBytes(data), nil
+}
+
+func newAuditCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit key: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// lastLine returns the last non-empty line of f.
+func lastLine(f *os.File) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	const chunk = 64 * 1024
+	var tail []byte
+	for end := info.Size(); end > 0; {
+		start := max(end-chunk, 0)
+		buf := make([]byte, end-start)
+		if _, err := f.ReadAt(buf, start); err != nil {
+			return nil, err
+		}
+		tail = append(buf, tail...)
+		trimmed := bytes.TrimRight(tail, "\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:], nil
+		}
+		end = start
+	}
+
+	return bytes.TrimRight(tail, "\n"), nil
+}
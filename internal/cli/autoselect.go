@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"cmp"
+	"fmt"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// ModelAuto is the model name selecting the model of a run with
+// [AutoSelect].
+const ModelAuto = "auto"
+
+// Model tiers of the automatic model selection.
+const (
+	TierSmall   = "small"
+	TierDefault = "default"
+	TierLarge   = "large"
+	TierVision  = "vision"
+)
+
+// Default page thresholds of the automatic model selection.
+const (
+	DefaultSmallPages = 2
+	DefaultLargePages = 50
+)
+
+// AutoSelect picks the model of a run from its task preset, page count,
+// language and whether the pages are sent as images. The model of every tier
+// may be an alias; tiers without a model use the Default one, and
+// uniai.ModelDefault if none is set.
+type AutoSelect struct {
+	Small   string `yaml:"small"`   // short English text documents
+	Default string `yaml:"default"` // everything else
+	Large   string `yaml:"large"`   // long documents and other languages
+	Vision  string `yaml:"vision"`  // pages sent as images, if set
+
+	// SmallPages is the most pages of a document for the small model,
+	// DefaultSmallPages if 0.
+	SmallPages int `yaml:"small_pages"`
+	// LargePages is the fewest pages of a document for the large model,
+	// DefaultLargePages if 0.
+	LargePages int `yaml:"large_pages"`
+	// Tasks are the tiers of task presets, which take precedence over the
+	// other rules.
+	Tasks map[string]string `yaml:"tasks"`
+}
+
+// SelectionInput describes the run a model is selected for.
+type SelectionInput struct {
+	Task       string `json:"task,omitempty"` // name of the task preset
+	Pages      int    `json:"pages"`
+	Language   string `json:"language,omitempty"` // ISO 639-1 code, see DetectLanguage
+	Vision     bool   `json:"vision"`             // pages are sent as images
+	Structured bool   `json:"structured"`         // the answer follows a schema
+}
+
+// ModelSelection is the model picked by [AutoSelect.Select] and why, as
+// recorded in the run metadata.
+type ModelSelection struct {
+	Model  string         `json:"model"`
+	Tier   string         `json:"tier"`
+	Reason string         `json:"reason"`
+	Input  SelectionInput `json:"input"`
+}
+
+// Validate checks the tiers of the task presets.
+func (a *AutoSelect) Validate() error {
+	for task, tier := range a.Tasks {
+		switch tier {
+		case TierSmall, TierDefault, TierLarge, TierVision:
+		default:
+			return fmt.Errorf("task %s: unknown model tier %q", task, tier)
+		}
+	}
+	if a.SmallPages < 0 || a.LargePages < 0 {
+		return fmt.Errorf("invalid page thresholds: %d and %d", a.SmallPages, a.LargePages)
+	}
+
+	return nil
+}
+
+// Select returns the model of a run. A nil AutoSelect selects among the
+// default models only.
+func (a *AutoSelect) Select(in SelectionInput) ModelSelection {
+	if a == nil {
+		a = &AutoSelect{}
+	}
+	smallPages := cmp.Or(a.SmallPages, DefaultSmallPages)
+	largePages := cmp.Or(a.LargePages, DefaultLargePages)
+
+	tier, reason := TierDefault, "no rule matched"
+	switch {
+	case a.Tasks[in.Task] != "":
+		tier, reason = a.Tasks[in.Task], fmt.Sprintf("task %s", in.Task)
+	case in.Vision && a.Vision != "":
+		tier, reason = TierVision, "pages are sent as images"
+	case in.Pages >= largePages:
+		tier, reason = TierLarge, fmt.Sprintf("%d pages, at least %d", in.Pages, largePages)
+	case in.Language != "" && in.Language != "en":
+		tier, reason = TierLarge, fmt.Sprintf("language %s", in.Language)
+	case in.Structured:
+		reason = "the answer follows a schema"
+	case in.Vision:
+		reason = "pages are sent as images"
+	case in.Pages <= smallPages:
+		tier, reason = TierSmall, fmt.Sprintf("%d page(s) of text, at most %d", in.Pages, smallPages)
+	}
+
+	return ModelSelection{Model: a.model(tier), Tier: tier, Reason: reason, Input: in}
+}
+
+// model returns the model of tier.
+func (a *AutoSelect) model(tier string) string {
+	var model string
+	switch tier {
+	case TierSmall:
+		model = a.Small
+	case TierLarge:
+		model = a.Large
+	case TierVision:
+		model = a.Vision
+	}
+
+	return cmp.Or(model, a.Default, uniai.ModelDefault)
+}
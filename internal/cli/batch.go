@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PagesPlaceholder is replaced with the page numbers of the request, such as
+// "3-4", in prompts of requests batching several pages.
+const PagesPlaceholder = "{{pages}}"
+
+// BatchPrompt adapts prompt for a request of several pages, whose images
+// belong to imagePages in order: the [PagesPlaceholder] is replaced by the
+// page numbers, and the page of every image is listed after the prompt. When
+// the placeholder is absent, the model is also asked to answer page by page.
+func BatchPrompt(prompt string, pages, imagePages []int) string {
+	var sb strings.Builder
+	if strings.Contains(prompt, PagesPlaceholder) {
+		sb.WriteString(strings.ReplaceAll(prompt, PagesPlaceholder, FormatPageRange(pages)))
+	} else {
+		sb.WriteString(prompt)
+		fmt.Fprintf(&sb, "\n\nThe provided content is pages %s of the document. Answer for each page in turn, starting each answer with a line \"Page <number>\".", FormatPageRange(pages))
+	}
+
+	if len(imagePages) > 0 {
+		sb.WriteString("\n\nThe images are, in order:")
+		for i, page := range imagePages {
+			fmt.Fprintf(&sb, "\n- image %d: page %d", i+1, page)
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// BatchStateFile is the name of the state of a batch run, written in its
+// output directory.
+const BatchStateFile = "batch.json"
+
+// BatchFile is the outcome of a document of a batch run.
+type BatchFile struct {
+	Path   string `json:"path"`
+	Hash   string `json:"hash,omitempty"`
+	Status string `json:"status"` // status of its run, StatusFailed if it could not run
+	Pages  int    `json:"pages"`
+
+	Rendered []int `json:"rendered,omitempty"`
+	Answered []int `json:"answered,omitempty"` // answered now or from the response cache
+	Skipped  []int `json:"skipped,omitempty"`
+	Failed   []int `json:"failed,omitempty"` // failed or not processed
+
+	RunInfo  string        `json:"run_info,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Time     time.Time     `json:"time"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// NewBatchFile returns the outcome of the document at path, with content
+// hash, from the summary of its run, which failed with err if not nil. The
+// summary is nil if the run stopped before writing it.
+func NewBatchFile(path, hash string, summary *RunSummary, err error, d time.Duration) *BatchFile {
+	f := &BatchFile{Path: path, Hash: hash, Status: StatusFailed, Duration: d, Time: time.Now().UTC()}
+	if err != nil {
+		f.Error = err.Error()
+	}
+	if summary == nil {
+		return f
+	}
+
+	f.Status, f.Pages = summary.Status, len(summary.Pages)
+	f.RunInfo = summary.RunInfo
+	for _, p := range summary.Pages {
+		if p.RenderDuration > 0 {
+			f.Rendered = append(f.Rendered, p.Page)
+		}
+		switch p.Status {
+		case StatusOK, StatusCached:
+			f.Answered = append(f.Answered, p.Page)
+		case StatusSkipped:
+			f.Skipped = append(f.Skipped, p.Page)
+		default:
+			f.Failed = append(f.Failed, p.Page)
+		}
+	}
+
+	return f
+}
+
+// BatchState records the outcome of every document of a batch run as it
+// completes, so that a run interrupted or partly failed can be resumed with
+// the documents left.
+type BatchState struct {
+	Input string       `json:"input"`
+	Files []*BatchFile `json:"files"`
+
+	path string
+}
+
+// LoadBatchState reads the batch state at path, or returns an empty state
+// to be written there if it does not exist.
+func LoadBatchState(path string) (*BatchState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &BatchState{path: path}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := &BatchState{path: path}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse batch state %s: %w", path, err)
+	}
+
+	return state, nil
+}
+
+// NewBatchState returns an empty state, to be written at path, of a batch
+// run of the documents of input.
+func NewBatchState(path, input string) *BatchState {
+	return &BatchState{Input: input, path: path}
+}
+
+// Done reports whether the document at path, with content hash, was
+// completed without failures.
+func (s *BatchState) Done(path, hash string) bool {
+	i := s.index(path)
+
+	return i >= 0 && s.Files[i].Hash == hash && s.Files[i].Status == StatusOK
+}
+
+// Set records the outcome of a document, replacing its previous one.
+func (s *BatchState) Set(f *BatchFile) {
+	if i := s.index(f.Path); i >= 0 {
+		s.Files[i] = f
+		return
+	}
+	s.Files = append(s.Files, f)
+}
+
+func (s *BatchState) index(path string) int {
+	return slices.IndexFunc(s.Files, func(f *BatchFile) bool { return f.Path == path })
+}
+
+// Write writes the state to its path, replacing the previous one at once so
+// that a crash never leaves a partial state.
+func (s *BatchState) Write() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,353 @@
+package cli
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// BenchSample is the outcome of a request of a benchmark.
+type BenchSample struct {
+	Latency     time.Duration // until the response was complete
+	FirstToken  time.Duration // until its first chunk, 0 if none
+	Tokens      int           // completion tokens
+	Concurrency int           // requests in flight allowed when it was sent
+	Err         error
+
+	at time.Duration // since the start of the benchmark, once complete
+}
+
+// LoadProfile is the load of a sustained benchmark: the requests in flight
+// grow from 1 to Peak during RampUp, stay at Peak during Steady, and go back
+// to 1 during RampDown, so that the saturation point of a deployment shows
+// as the load where the throughput stops growing.
+type LoadProfile struct {
+	RampUp   time.Duration `json:"ramp_up"`
+	Steady   time.Duration `json:"steady"`
+	RampDown time.Duration `json:"ramp_down"`
+	Peak     int           `json:"peak"`
+}
+
+// Duration returns the duration of the profile.
+func (p LoadProfile) Duration() time.Duration {
+	return p.RampUp + p.Steady + p.RampDown
+}
+
+// Concurrency returns the requests in flight allowed at elapsed since the
+// start of the profile, 0 once it is over.
+func (p LoadProfile) Concurrency(elapsed time.Duration) int {
+	ramp := func(done, total time.Duration) int {
+		return int(float64(p.Peak-1) * float64(done) / float64(total))
+	}
+
+	switch {
+	case elapsed < 0 || elapsed >= p.Duration():
+		return 0
+	case elapsed < p.RampUp:
+		return 1 + ramp(elapsed, p.RampUp)
+	case elapsed < p.RampUp+p.Steady:
+		return p.Peak
+	default:
+		return p.Peak - ramp(elapsed-p.RampUp-p.Steady, p.RampDown)
+	}
+}
+
+// BenchWindow is the summary of the requests of a benchmark completed in an
+// interval of time.
+type BenchWindow struct {
+	Start             time.Duration `json:"start"` // since the start of the benchmark
+	End               time.Duration `json:"end"`
+	Concurrency       int           `json:"concurrency"` // the highest of the window
+	Requests          int           `json:"requests"`
+	Errors            int           `json:"errors"`
+	ErrorRate         float64       `json:"error_rate"`
+	P50               time.Duration `json:"p50"`
+	P95               time.Duration `json:"p95"`
+	Tokens            int           `json:"tokens"`
+	TokensPerSecond   float64       `json:"tokens_per_second"`
+	RequestsPerSecond float64       `json:"requests_per_second"`
+}
+
+// BenchResult is the summary of the requests of a benchmark to a model.
+type BenchResult struct {
+	Model       string  `json:"model"`
+	Requests    int     `json:"requests"`
+	Errors      int     `json:"errors"`
+	ErrorRate   float64 `json:"error_rate"`
+	Concurrency int     `json:"concurrency"`
+	// Latencies are the percentiles of the successful requests.
+	P50        time.Duration `json:"p50"`
+	P95        time.Duration `json:"p95"`
+	Max        time.Duration `json:"max"`
+	FirstToken time.Duration `json:"first_token_p50"`
+	// TokensPerSecond is the completion tokens of all the requests over the
+	// duration of the benchmark, and RequestsPerSecond the requests over it.
+	Tokens            int            `json:"tokens"`
+	TokensPerSecond   float64        `json:"tokens_per_second"`
+	RequestsPerSecond float64        `json:"requests_per_second"`
+	Duration          time.Duration  `json:"duration"`
+	ErrorCounts       map[string]int `json:"error_counts,omitempty"` // by message
+	// Windows are the results over time of a sustained benchmark, and Peak
+	// the window of the highest throughput: its concurrency is the
+	// saturation point of the model.
+	Profile *LoadProfile  `json:"profile,omitempty"`
+	Windows []BenchWindow `json:"windows,omitempty"`
+	Peak    *BenchWindow  `json:"peak,omitempty"`
+}
+
+// Bench collects the samples of a benchmark of a model. It is safe for
+// concurrent use.
+type Bench struct {
+	model       string
+	concurrency int
+	start       time.Time
+
+	mu      sync.Mutex
+	samples []BenchSample
+}
+
+// NewBench starts the benchmark of model with concurrency requests in
+// flight.
+func NewBench(model string, concurrency int) *Bench {
+	return &Bench{model: model, concurrency: concurrency, start: time.Now()}
+}
+
+// Add records the outcome of a request that just completed.
+func (b *Bench) Add(s BenchSample) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s.at = time.Since(b.start)
+	b.samples = append(b.samples, s)
+}
+
+// Result summarizes the samples recorded so far.
+func (b *Bench) Result() BenchResult {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	res := BenchResult{
+		Model:       b.model,
+		Requests:    len(b.samples),
+		Concurrency: b.concurrency,
+		Duration:    time.Since(b.start),
+	}
+	var latencies, firstTokens []time.Duration
+	for _, s := range b.samples {
+		res.Tokens += s.Tokens
+		if s.Err != nil {
+			res.Errors++
+			if res.ErrorCounts == nil {
+				res.ErrorCounts = make(map[string]int)
+			}
+			res.ErrorCounts[s.Err.Error()]++
+			continue
+		}
+		latencies = append(latencies, s.Latency)
+		if s.FirstToken > 0 {
+			firstTokens = append(firstTokens, s.FirstToken)
+		}
+	}
+	res.ErrorRate = rate(res.Errors, res.Requests)
+	res.TokensPerSecond, res.RequestsPerSecond = perSecond(res.Tokens, res.Duration), perSecond(res.Requests, res.Duration)
+	res.P50, res.P95 = Percentile(latencies, 50), Percentile(latencies, 95)
+	if len(latencies) > 0 {
+		res.Max = slices.Max(latencies)
+	}
+	res.FirstToken = Percentile(firstTokens, 50)
+
+	return res
+}
+
+// Windows summarizes the samples recorded so far by the interval in which
+// they completed.
+func (b *Bench) Windows(interval time.Duration) []BenchWindow {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if interval <= 0 || len(b.samples) == 0 {
+		return nil
+	}
+	end := time.Since(b.start)
+	windows := make([]BenchWindow, int(end/interval)+1)
+	latencies := make([][]time.Duration, len(windows))
+	for i := range windows {
+		windows[i].Start, windows[i].End = time.Duration(i)*interval, min(time.Duration(i+1)*interval, end)
+	}
+	for _, s := range b.samples {
+		i := min(int(s.at/interval), len(windows)-1)
+		w := &windows[i]
+		w.Requests++
+		w.Tokens += s.Tokens
+		w.Concurrency = max(w.Concurrency, s.Concurrency)
+		if s.Err != nil {
+			w.Errors++
+		} else {
+			latencies[i] = append(latencies[i], s.Latency)
+		}
+	}
+	for i := range windows {
+		w := &windows[i]
+		w.ErrorRate = rate(w.Errors, w.Requests)
+		w.P50, w.P95 = Percentile(latencies[i], 50), Percentile(latencies[i], 95)
+		w.TokensPerSecond, w.RequestsPerSecond = perSecond(w.Tokens, w.End-w.Start), perSecond(w.Requests, w.End-w.Start)
+	}
+
+	return windows
+}
+
+// PeakWindow returns the window of the highest throughput, in tokens per
+// second or, if no tokens were counted, in successful requests per second;
+// nil if there are none.
+func PeakWindow(windows []BenchWindow) *BenchWindow {
+	throughput := func(w BenchWindow) float64 {
+		if w.Tokens > 0 {
+			return w.TokensPerSecond
+		}
+		return w.RequestsPerSecond * (1 - w.ErrorRate)
+	}
+
+	var peak *BenchWindow
+	for i, w := range windows {
+		if peak == nil || throughput(w) > throughput(*peak) {
+			peak = &windows[i]
+		}
+	}
+
+	return peak
+}
+
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(n) / float64(total)
+}
+
+func perSecond(n int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+
+	return float64(n) / d.Seconds()
+}
+
+// Percentile returns the p-th percentile of durations, by the nearest-rank
+// method, or 0 if there are none.
+func Percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+// WriteBenchResults writes the results as a table, followed by the errors of
+// each model.
+func WriteBenchResults(w io.Writer, results []BenchResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODEL\tREQUESTS\tERRORS\tP50\tP95\tMAX\tFIRST TOKEN\tTOKENS/S\tREQ/S")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d (%.1f%%)\t%s\t%s\t%s\t%s\t%.1f\t%.2f\n",
+			r.Model, r.Requests, r.Errors, r.ErrorRate*100,
+			benchDuration(r.P50), benchDuration(r.P95), benchDuration(r.Max), benchDuration(r.FirstToken),
+			r.TokensPerSecond, r.RequestsPerSecond)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if len(r.Windows) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s over time:\n", r.Model)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "  TIME\tCONCURRENCY\tREQUESTS\tERRORS\tP50\tP95\tTOKENS/S\tREQ/S")
+		for _, win := range r.Windows {
+			fmt.Fprintf(tw, "  %s\t%d\t%d\t%.1f%%\t%s\t%s\t%.1f\t%.2f\n",
+				win.Start.Round(time.Second), win.Concurrency, win.Requests, win.ErrorRate*100,
+				benchDuration(win.P50), benchDuration(win.P95), win.TokensPerSecond, win.RequestsPerSecond)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if r.Peak != nil {
+			fmt.Fprintf(w, "Peak throughput at concurrency %d: %.1f tokens/s, %.2f requests/s, p95 %s\n",
+				r.Peak.Concurrency, r.Peak.TokensPerSecond, r.Peak.RequestsPerSecond, benchDuration(r.Peak.P95))
+		}
+	}
+
+	for _, r := range results {
+		if len(r.ErrorCounts) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\nErrors of %s:\n", r.Model)
+		messages := slices.Sorted(maps.Keys(r.ErrorCounts))
+		for _, msg := range messages {
+			fmt.Fprintf(w, "  %4d  %s\n", r.ErrorCounts[msg], msg)
+		}
+	}
+
+	return nil
+}
+
+// WriteBenchCSV writes the windows of the results to w as CSV, with a header
+// row, or a row per result without windows. Durations are in milliseconds,
+// times since the start of the benchmark in seconds.
+func WriteBenchCSV(w io.Writer, results []BenchResult) error {
+	ms := func(d time.Duration) string { return strconv.FormatInt(d.Milliseconds(), 10) }
+	seconds := func(d time.Duration) string { return strconv.FormatFloat(d.Seconds(), 'f', 1, 64) }
+	number := func(f float64) string { return strconv.FormatFloat(f, 'f', 3, 64) }
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"model", "start_s", "end_s", "concurrency", "requests", "errors", "error_rate", "p50_ms", "p95_ms", "tokens", "tokens_per_second", "requests_per_second"})
+	for _, r := range results {
+		windows := r.Windows
+		if len(windows) == 0 {
+			windows = []BenchWindow{{
+				End: r.Duration, Concurrency: r.Concurrency, Requests: r.Requests, Errors: r.Errors, ErrorRate: r.ErrorRate,
+				P50: r.P50, P95: r.P95, Tokens: r.Tokens, TokensPerSecond: r.TokensPerSecond, RequestsPerSecond: r.RequestsPerSecond,
+			}}
+		}
+		for _, win := range windows {
+			cw.Write([]string{
+				r.Model,
+				seconds(win.Start),
+				seconds(win.End),
+				strconv.Itoa(win.Concurrency),
+				strconv.Itoa(win.Requests),
+				strconv.Itoa(win.Errors),
+				number(win.ErrorRate),
+				ms(win.P50),
+				ms(win.P95),
+				strconv.Itoa(win.Tokens),
+				number(win.TokensPerSecond),
+				number(win.RequestsPerSecond),
+			})
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func benchDuration(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+
+	return d.Round(time.Millisecond).String()
+}
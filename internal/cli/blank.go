@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"image"
+)
+
+const (
+	// DefaultBlankThreshold is the ink coverage ratio below which a page is
+	// considered blank. Scanner noise on empty sheets usually stays well below
+	// half a percent.
+	DefaultBlankThreshold = 0.005
+
+	// inkLuminance is the 8-bit luminance below which a pixel counts as ink.
+	inkLuminance = 200
+
+	// blankSampleStep is the pixel stride used when sampling the image.
+	blankSampleStep = 2
+)
+
+// InkCoverage returns the ratio of "inked" (dark enough) pixels in img, in the
+// range [0, 1]. The image is sampled on a grid to keep it cheap for large
+// renders.
+func InkCoverage(img image.Image) float64 {
+	if img == nil {
+		return 0
+	}
+
+	bounds := img.Bounds()
+	var total, inked int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += blankSampleStep {
+		for x := bounds.Min.X; x < bounds.Max.X; x += blankSampleStep {
+			r, g, b, _ := img.At(x, y).RGBA()
+			// ITU-R BT.601 luma on 16-bit channels, scaled down to 8 bits.
+			lum := (299*r + 587*g + 114*b) / 1000 >> 8
+			if lum < inkLuminance {
+				inked++
+			}
+			total++
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	return float64(inked) / float64(total)
+}
+
+// IsBlankImage reports whether img is near-blank, i.e. its ink coverage is
+// below threshold.
+func IsBlankImage(img image.Image, threshold float64) bool {
+	return InkCoverage(img) < threshold
+}
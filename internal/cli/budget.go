@@ -0,0 +1,148 @@
+package cli
+
+import "sync"
+
+// ByteBudget limits the number of bytes held by in-flight items. Producers
+// acquire the size of an item before handing it on and consumers release it
+// once the item has been processed. A nil *ByteBudget is unlimited.
+type ByteBudget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+}
+
+// NewByteBudget returns a budget of limit bytes, or nil if limit is not
+// positive.
+func NewByteBudget(limit int64) *ByteBudget {
+	if limit <= 0 {
+		return nil
+	}
+
+	b := &ByteBudget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+
+	return b
+}
+
+// Acquire blocks until n bytes fit into the budget. An item larger than the
+// whole budget is admitted once nothing else is in flight, so it cannot block
+// forever.
+func (b *ByteBudget) Acquire(n int64) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.used > 0 && b.used+n > b.limit {
+		b.cond.Wait()
+	}
+	b.used += n
+}
+
+// Release returns n bytes to the budget.
+func (b *ByteBudget) Release(n int64) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	b.used -= n
+	b.cond.Broadcast()
+	b.mu.Unlock()
+}
+
+// InFlight returns the number of bytes currently acquired.
+func (b *ByteBudget) InFlight() int64 {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.used
+}
+
+// SpendLimit caps the tokens and cost of the requests of a run. It is safe
+// for concurrent use; a nil *SpendLimit is unlimited.
+type SpendLimit struct {
+	maxTokens int
+	maxCost   float64
+	prices    Prices
+
+	mu       sync.Mutex
+	requests int
+	tokens   int
+	cost     float64
+}
+
+// NewSpendLimit returns a limit of maxTokens tokens and maxCost in the
+// currency of the prices, either unlimited if not positive, or nil if both
+// are.
+func NewSpendLimit(maxTokens int, maxCost float64) *SpendLimit {
+	if maxTokens <= 0 && maxCost <= 0 {
+		return nil
+	}
+
+	return &SpendLimit{maxTokens: maxTokens, maxCost: maxCost}
+}
+
+// UsePrices sets the prices the cost of the requests not sent yet is
+// estimated with.
+func (s *SpendLimit) UsePrices(prices Prices) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prices = prices
+}
+
+// Add records the tokens and cost of a completed request.
+func (s *SpendLimit) Add(tokens int, cost float64) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	s.tokens += tokens
+	s.cost += cost
+}
+
+// Allow reports whether another request to model fits into the limit. The
+// request is assumed to use its estimated prompt tokens, or as much as the
+// mean request so far if more, as the estimate leaves out the answer.
+func (s *SpendLimit) Allow(model string, estimate int) bool {
+	if s == nil {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, nextCost := estimate, s.prices.For(model).Cost(estimate, 0)
+	if s.requests > 0 {
+		next = max(next, s.tokens/s.requests)
+		nextCost = max(nextCost, s.cost/float64(s.requests))
+	}
+	tokens, cost := s.tokens+next, s.cost+nextCost
+
+	return (s.maxTokens <= 0 || tokens <= s.maxTokens) && (s.maxCost <= 0 || cost <= s.maxCost)
+}
+
+// Spent returns the tokens and cost of the requests so far.
+func (s *SpendLimit) Spent() (int, float64) {
+	if s == nil {
+		return 0, 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tokens, s.cost
+}
@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// RenderCache is a content-addressed store of rendered page images. Entries
+// are keyed by the source file hash, the page number and the render settings,
+// so re-running with a different prompt does not re-render pages.
+type RenderCache struct {
+	dir string
+}
+
+// DefaultCacheDir returns the default location of the render cache inside the
+// user cache directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "uniai", "render"), nil
+}
+
+// NewRenderCache creates the cache directory if needed and returns the cache.
+func NewRenderCache(dir string) (*RenderCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	return &RenderCache{dir: dir}, nil
+}
+
+// HashBytes returns the hex-encoded SHA-256 of b.
+func HashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Key returns the cache key for a page of the file with the given hash.
+func (c *RenderCache) Key(fileHash string, pageNum int, settings RenderSettings) string {
+	return HashBytes([]byte(fmt.Sprintf("%s|%d|%s", fileHash, pageNum, settings)))
+}
+
+func (c *RenderCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// Get returns the cached image path for key, if present.
+func (c *RenderCache) Get(key string) (string, bool) {
+	p := c.path(key)
+	if _, err := os.Stat(p); err != nil {
+		return "", false
+	}
+
+	return p, true
+}
+
+// Put stores a copy of the image at src under key.
+func (c *RenderCache) Put(key, src string) error {
+	dst := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file of this writer first, so concurrent readers
+	// never observe a partially written entry, and concurrent writers of
+	// the same entry, such as two runs of a document sharing the cache,
+	// never interleave.
+	tmp, err := createTemp(dst)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(src, tmp); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// createTemp creates a temporary file of its own for the entry at dst, in
+// the same directory so that it can be renamed into place.
+func createTemp(dst string) (*os.File, error) {
+	f, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	// Entries are readable by others, like the files of os.Create.
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Load returns the cached image data for key.
+func (c *RenderCache) Load(key string) ([]byte, error) {
+	src, ok := c.Get(key)
+	if !ok {
+		return nil, fmt.Errorf("cache entry %s not found", key)
+	}
+
+	return os.ReadFile(src)
+}
+
+// copyFile copies the file at src to out, and closes out.
+func copyFile(src string, out *os.File) error {
+	in, err := os.Open(src)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	defer in.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// HashFile returns the hex-encoded SHA-256 of the file at path, streaming it
+// so large files are never fully loaded in memory.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestRenderCacheConcurrentPut checks that writers of the same entry, as
+// runs of a document sharing the cache are, leave a whole image of one of
+// them and no temporary file.
+func TestRenderCacheConcurrentPut(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewRenderCache(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := cache.Key("hash", 1, DefaultRenderSettings)
+
+	const writers = 8
+	images := make([][]byte, writers)
+	var wg sync.WaitGroup
+	for i := range writers {
+		images[i] = bytes.Repeat([]byte{byte('a' + i)}, 256<<10)
+		src := filepath.Join(dir, fmt.Sprintf("page_%d.jpg", i))
+		if err := os.WriteFile(src, images[i], 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				if err := cache.Put(key, src); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := cache.Load(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	whole := false
+	for _, img := range images {
+		whole = whole || bytes.Equal(data, img)
+	}
+	if !whole {
+		t.Errorf("entry of %d bytes is not the image of a single writer", len(data))
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(cache.path(key)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("%d files in the entry directory, want only the entry", len(entries))
+	}
+}
@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CheckpointFile is the name of the checkpoint written next to the outputs.
+const CheckpointFile = "checkpoint.jsonl"
+
+// CheckpointEntry is a completed request of a run.
+type CheckpointEntry struct {
+	Request  string    `json:"request"`
+	Pages    []int     `json:"pages"`
+	Response string    `json:"response"`
+	Time     time.Time `json:"time"`
+}
+
+// Checkpoint persists every response the moment its request completes, so a
+// crash late in a run loses no prior work. Entries are appended as JSON lines
+// and synced to disk; entries of earlier runs in the same output directory
+// are kept. It is safe for concurrent use.
+type Checkpoint struct {
+	path string
+
+	mu    sync.Mutex
+	f     *os.File
+	pages map[int]bool // single pages answered by this run
+}
+
+// OpenCheckpoint opens the checkpoint at path for appending, creating it if
+// needed.
+func OpenCheckpoint(path string) (*Checkpoint, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Checkpoint{path: path, f: f, pages: make(map[int]bool)}, nil
+}
+
+// Path returns the path of the checkpoint.
+func (c *Checkpoint) Path() string {
+	return c.path
+}
+
+// Add appends the response of request, about pages, and syncs it to disk.
+func (c *Checkpoint) Add(request string, pages []int, response string) error {
+	data, err := json.Marshal(CheckpointEntry{Request: request, Pages: pages, Response: response, Time: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.f == nil {
+		return errors.New("checkpoint is closed")
+	}
+	if _, err := c.f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := c.f.Sync(); err != nil {
+		return err
+	}
+	if len(pages) == 1 {
+		c.pages[pages[0]] = true
+	}
+
+	return nil
+}
+
+// Responses reads back the responses to the single-page requests of this
+// run, by page number.
+func (c *Checkpoint) Responses() (map[int]string, error) {
+	c.mu.Lock()
+	pages := make(map[int]bool, len(c.pages))
+	for page := range c.pages {
+		pages[page] = true
+	}
+	c.mu.Unlock()
+
+	responses := make(map[int]string, len(pages))
+	err := ReadCheckpoint(c.path, func(entry CheckpointEntry) {
+		if len(entry.Pages) == 1 && pages[entry.Pages[0]] {
+			responses[entry.Pages[0]] = entry.Response
+		}
+	})
+
+	return responses, err
+}
+
+// Entries reads back the entries written since the given time, such as the
+// start of the run, sorted by their first page. A request answered more than
+// once keeps its last entry.
+func (c *Checkpoint) Entries(since time.Time) ([]CheckpointEntry, error) {
+	latest := make(map[string]CheckpointEntry)
+	err := ReadCheckpoint(c.path, func(entry CheckpointEntry) {
+		if !entry.Time.Before(since) && len(entry.Pages) > 0 {
+			latest[entry.Request] = entry
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]CheckpointEntry, 0, len(latest))
+	for _, entry := range latest {
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b CheckpointEntry) int {
+		return cmp.Or(a.Pages[0]-b.Pages[0], strings.Compare(a.Request, b.Request))
+	})
+
+	return entries, nil
+}
+
+// Close closes the checkpoint file.
+func (c *Checkpoint) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.f == nil {
+		return nil
+	}
+	err := c.f.Close()
+	c.f = nil
+
+	return err
+}
+
+// ReadCheckpoint calls fn with every entry of the checkpoint at path, in the
+// order they were written. A truncated last line, left by a crash while it
+// was written, is ignored.
+func ReadCheckpoint(path string, fn func(CheckpointEntry)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	var pending error
+	for line := 1; scanner.Scan(); line++ {
+		if pending != nil {
+			return pending
+		}
+
+		var entry CheckpointEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			pending = fmt.Errorf("failed to parse checkpoint %s line %d: %w", path, line, err)
+			continue
+		}
+		fn(entry)
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardTool is a command writing its standard input to the clipboard.
+type clipboardTool struct {
+	args    []string
+	display string // environment variable the tool needs, if any
+}
+
+// clipboardTools are the commands tried, in order, to write the clipboard.
+var clipboardTools = []clipboardTool{
+	{args: []string{"pbcopy"}},
+	{args: []string{"wl-copy"}, display: "WAYLAND_DISPLAY"},
+	{args: []string{"xclip", "-selection", "clipboard"}, display: "DISPLAY"},
+	{args: []string{"xsel", "--clipboard", "--input"}, display: "DISPLAY"},
+	{args: []string{"clip.exe"}},
+}
+
+// CopyToClipboard places text on the system clipboard with the first
+// available of pbcopy (macOS), wl-copy (Wayland), xclip or xsel (X11) and
+// clip (Windows and WSL).
+func CopyToClipboard(text string) error {
+	tools := clipboardTools
+	if runtime.GOOS == "windows" {
+		tools = []clipboardTool{{args: []string{"clip"}}}
+	}
+
+	for _, tool := range tools {
+		if tool.display != "" && os.Getenv(tool.display) == "" {
+			continue
+		}
+		path, err := exec.LookPath(tool.args[0])
+		if err != nil {
+			continue
+		}
+
+		var stderr bytes.Buffer
+		cmd := exec.Command(path, tool.args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to copy to the clipboard with %s: %w: %s", tool.args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+
+	return errors.New("copying to the clipboard requires pbcopy, wl-copy, xclip, xsel or clip in PATH")
+}
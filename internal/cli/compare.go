@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Files of a model comparison.
+const (
+	ComparisonFile     = "comparison.md"
+	ComparisonJsonFile = "comparison.json"
+)
+
+// ModelOutput is the answer of one model to a request.
+type ModelOutput struct {
+	Model            string        `json:"model"`
+	Response         string        `json:"response"`
+	Duration         time.Duration `json:"duration"`
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	Cached           bool          `json:"cached,omitempty"`
+	Error            string        `json:"error,omitempty"`
+}
+
+// ComparisonEntry holds the answers of every model to a request.
+type ComparisonEntry struct {
+	Name    string        `json:"name"`
+	Pages   []int         `json:"pages,omitempty"`
+	Outputs []ModelOutput `json:"outputs"`
+}
+
+// Comparison collects the answers of several models to the same requests.
+// It is safe for concurrent use.
+type Comparison struct {
+	SchemaVersion int               `json:"schema_version"`
+	Models        []string          `json:"models"`
+	Entries       []ComparisonEntry `json:"entries"`
+
+	mu sync.Mutex
+}
+
+// NewComparison returns an empty comparison of models.
+func NewComparison(models []string) *Comparison {
+	return &Comparison{SchemaVersion: SchemaVersion, Models: models}
+}
+
+// Add records the answers to a request. A nil comparison ignores the call.
+func (c *Comparison) Add(entry ComparisonEntry) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Entries = append(c.Entries, entry)
+}
+
+// sortEntries orders the entries by first page, then name.
+func (c *Comparison) sortEntries() {
+	sort.SliceStable(c.Entries, func(i, j int) bool {
+		a, b := c.Entries[i], c.Entries[j]
+		if len(a.Pages) > 0 && len(b.Pages) > 0 && a.Pages[0] != b.Pages[0] {
+			return a.Pages[0] < b.Pages[0]
+		}
+		return a.Name < b.Name
+	})
+}
+
+// WriteJSON stores the comparison at path.
+func (c *Comparison) WriteJSON(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sortEntries()
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// WriteMarkdown writes a report with the totals of every model and, per
+// request, the metrics and answers of the models side by side.
+func (c *Comparison) WriteMarkdown(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sortEntries()
+
+	var sb strings.Builder
+	sb.WriteString("# Model comparison\n\n")
+	sb.WriteString("| Model | Requests | Errors | Mean latency | Prompt tokens | Completion tokens |\n")
+	sb.WriteString("| --- | ---: | ---: | ---: | ---: | ---: |\n")
+	for _, model := range c.Models {
+		var requests, errors, prompt, completion int
+		var total time.Duration
+		for _, e := range c.Entries {
+			for _, out := range e.Outputs {
+				if out.Model != model {
+					continue
+				}
+				requests++
+				if out.Error != "" {
+					errors++
+				}
+				total += out.Duration
+				prompt += out.PromptTokens
+				completion += out.CompletionTokens
+			}
+		}
+		mean := time.Duration(0)
+		if requests > 0 {
+			mean = total / time.Duration(requests)
+		}
+		fmt.Fprintf(&sb, "| %s | %d | %d | %s | %d | %d |\n", model, requests, errors, mean.Round(time.Millisecond), prompt, completion)
+	}
+
+	for _, e := range c.Entries {
+		fmt.Fprintf(&sb, "\n## %s\n\n", e.Name)
+
+		header, align, metrics, answers := "|", "|", "|", "|"
+		for _, out := range e.Outputs {
+			header += " " + out.Model + " |"
+			align += " --- |"
+
+			metric := fmt.Sprintf("%s, %d+%d tokens", out.Duration.Round(time.Millisecond), out.PromptTokens, out.CompletionTokens)
+			if out.Cached {
+				metric = "cached"
+			}
+			metrics += " " + metric + " |"
+
+			answer := out.Response
+			if out.Error != "" {
+				answer = "**Error:** " + out.Error
+			}
+			answers += " " + tableCell(answer) + " |"
+		}
+		fmt.Fprintf(&sb, "%s\n%s\n%s\n%s\n", header, align, metrics, answers)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// tableCell escapes text for a Markdown table cell.
+func tableCell(text string) string {
+	text = strings.TrimSpace(text)
+	text = strings.ReplaceAll(text, "|", `\|`)
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	return strings.ReplaceAll(text, "\n", "<br>")
+}
@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile is the name of the configuration in the user config directory.
+const ConfigFile = "config.yaml"
+
+// Settings of the UniAI API credentials and endpoint.
+const (
+	SettingBaseURL         = "base_url"
+	SettingAuth            = "auth"
+	SettingSigningKey      = "signing_key"
+	SettingSignatureHeader = "signature_header"
+)
+
+// Sources of a setting, from the highest precedence to the lowest.
+const (
+	SourceFlag   = "flag"
+	SourceEnv    = "env"
+	SourceConfig = "config"
+	SourceDotenv = ".env"
+)
+
+var sources = []string{SourceFlag, SourceEnv, SourceConfig, SourceDotenv}
+
+// Setting is a credential or endpoint setting and the environment variables
+// holding it, by precedence. The API_* variables predate the UNIAI_* ones.
+type Setting struct {
+	Key    string
+	Env    []string
+	Secret bool
+}
+
+// Settings are the settings resolved by Config.
+var Settings = []Setting{
+	{Key: SettingBaseURL, Env: []string{"UNIAI_BASE_URL", "API_BASEURL"}},
+	{Key: SettingAuth, Env: []string{"UNIAI_AUTH", "API_AUTH"}, Secret: true},
+	{Key: SettingSigningKey, Env: []string{"UNIAI_SIGNING_KEY", "API_SIGNING_KEY"}, Secret: true},
+	{Key: SettingSignatureHeader, Env: []string{"UNIAI_SIGNATURE_HEADER", "API_SIGNATURE_HEADER"}},
+}
+
+// Config resolves the credentials and endpoint of the UniAI API, in order of
+// precedence, from:
+//
+//  1. the command line flags,
+//  2. the UNIAI_* environment variables, then the legacy API_* ones,
+//  3. the configuration file, config.yaml in the user config directory,
+//     whose values may reference environment variables as ${NAME},
+//  4. the .env file of the working directory, which is optional.
+//
+// The layers may be loaded in any order.
+type Config struct {
+	layers map[string]map[string]string // values by key, by source
+}
+
+// NewConfig returns an empty configuration.
+func NewConfig() *Config {
+	return &Config{layers: make(map[string]map[string]string)}
+}
+
+// Set sets the value of key from source. Empty values are ignored.
+func (c *Config) Set(source, key, value string) {
+	if value == "" {
+		return
+	}
+	if c.layers[source] == nil {
+		c.layers[source] = make(map[string]string)
+	}
+	c.layers[source][key] = value
+}
+
+// LoadEnv reads the settings from the process environment. It must be called
+// before the .env file is loaded into the environment, so that the file does
+// not take precedence over the configuration file.
+func (c *Config) LoadEnv() {
+	for _, s := range Settings {
+		for _, name := range s.Env {
+			if value := os.Getenv(name); value != "" {
+				c.Set(SourceEnv, s.Key, value)
+				break
+			}
+		}
+	}
+}
+
+// LoadDotenv reads the settings from the .env file at path. A missing file is
+// not an error.
+func (c *Config) LoadDotenv(path string) error {
+	env, err := godotenv.Read(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load %s file: %w", path, err)
+	}
+
+	for _, s := range Settings {
+		for _, name := range s.Env {
+			if value := env[name]; value != "" {
+				c.Set(SourceDotenv, s.Key, value)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// LoadFile reads the settings from the configuration file at path. A missing
+// file is not an error.
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var values map[string]string
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	for key, value := range values {
+		if _, ok := setting(key); !ok {
+			return fmt.Errorf("config %s: unknown setting %q", path, key)
+		}
+		c.Set(SourceConfig, key, os.ExpandEnv(value))
+	}
+
+	return nil
+}
+
+// Get returns the value of key from the source of highest precedence.
+func (c *Config) Get(key string) string {
+	value, _ := c.Lookup(key)
+
+	return value
+}
+
+// Lookup returns the value of key and its source, or empty strings if no
+// source sets it.
+func (c *Config) Lookup(key string) (value, source string) {
+	for _, source := range sources {
+		if value, ok := c.layers[source][key]; ok {
+			return value, source
+		}
+	}
+
+	return "", ""
+}
+
+func setting(key string) (Setting, bool) {
+	for _, s := range Settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+
+	return Setting{}, false
+}
+
+// DefaultConfigPath returns the configuration in the user config directory.
+func DefaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "uniai", ConfigFile), nil
+}
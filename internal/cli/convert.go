@@ -0,0 +1,421 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// Document is an input file that is not a PDF, converted into pages the
+// pipeline can send: text, an image, or both.
+type Document struct {
+	Pages []DocumentPage
+}
+
+// DocumentPage is a single unit of a converted document, e.g. the body of an
+// email or one of its attachments.
+type DocumentPage struct {
+	Name  string
+	Text  string
+	Image []byte // encoded image, set for image pages
+}
+
+// Text returns the text of all pages, with image pages listed by name.
+func (d *Document) Text() string {
+	var sb strings.Builder
+	for i, page := range d.Pages {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		switch {
+		case page.Text != "":
+			sb.WriteString(page.Text)
+		case page.Image != nil:
+			fmt.Fprintf(&sb, "[image %s]", page.Name)
+		}
+	}
+
+	return sb.String()
+}
+
+// convertibleExts are the extensions of the non-PDF inputs ConvertFile
+// supports.
+var convertibleExts = map[string]bool{
+	".html": true,
+	".htm":  true,
+	".eml":  true,
+	".msg":  true,
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+}
+
+// IsConvertible reports whether the file at path is converted with
+// ConvertFile rather than read as a PDF.
+func IsConvertible(path string) bool {
+	return convertibleExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// ConvertFile converts an HTML page, an email (.eml or Outlook .msg) or a
+// PNG or JPEG image into a document.
+func ConvertFile(path string) (*Document, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		return &Document{Pages: []DocumentPage{{
+			Name: filepath.Base(path),
+			Text: HtmlToText(content),
+		}}}, nil
+	case ".eml":
+		return ConvertEmail(content)
+	case ".msg":
+		return ConvertMsg(content)
+	case ".png", ".jpg", ".jpeg":
+		return &Document{Pages: []DocumentPage{{
+			Name:  filepath.Base(path),
+			Image: content,
+		}}}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported input file type %q", filepath.Ext(path))
+}
+
+// htmlBlockTags end a line of text.
+var htmlBlockTags = map[string]bool{
+	"p": true, "div": true, "br": true, "tr": true, "table": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "section": true, "article": true,
+	"header": true, "footer": true, "blockquote": true, "pre": true, "hr": true,
+	"title": true,
+}
+
+// htmlSkippedTags have content that is not shown.
+var htmlSkippedTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+}
+
+// HtmlToText returns the visible text of an HTML document, keeping line
+// breaks of block elements, list bullets and table cells separated by tabs.
+func HtmlToText(content []byte) string {
+	var (
+		sb      strings.Builder
+		skip    int
+		pre     int
+		space   bool // whitespace is pending between two text runs
+		tokens  = html.NewTokenizer(bytes.NewReader(content))
+		newline = func() {
+			if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+				sb.WriteString("\n")
+			}
+		}
+	)
+	for {
+		tt := tokens.Next()
+		switch tt {
+		case html.ErrorToken:
+			return collapseBlankLines(sb.String())
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			name, hasAttr := tokens.TagName()
+			tag := string(name)
+			start := tt != html.EndTagToken
+
+			switch {
+			case htmlSkippedTags[tag]:
+				if tt == html.StartTagToken {
+					skip++
+				} else if tt == html.EndTagToken && skip > 0 {
+					skip--
+				}
+			case tag == "pre":
+				if tt == html.StartTagToken {
+					pre++
+				} else if tt == html.EndTagToken && pre > 0 {
+					pre--
+				}
+				newline()
+			case htmlBlockTags[tag]:
+				newline()
+				if tag == "li" && start {
+					sb.WriteString("- ")
+				}
+			case (tag == "td" || tag == "th") && !start:
+				sb.WriteString("\t")
+			case tag == "img" && hasAttr:
+				for {
+					key, val, more := tokens.TagAttr()
+					if string(key) == "alt" && len(val) > 0 {
+						fmt.Fprintf(&sb, "[image: %s]", val)
+					}
+					if !more {
+						break
+					}
+				}
+			}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			raw := string(tokens.Text())
+			if pre > 0 {
+				sb.WriteString(raw)
+				continue
+			}
+
+			// Runs separated by inline tags only get a space if the
+			// source had whitespace between them.
+			text := strings.Join(strings.Fields(raw), " ")
+			if text == "" {
+				space = space || raw != ""
+				continue
+			}
+			lead := strings.TrimLeft(raw, " \t\r\n") != raw
+			if s := sb.String(); (space || lead) && s != "" && !strings.HasSuffix(s, "\n") && !strings.HasSuffix(s, "\t") {
+				sb.WriteString(" ")
+			}
+			sb.WriteString(text)
+			space = strings.TrimRight(raw, " \t\r\n") != raw
+		}
+	}
+}
+
+// collapseBlankLines trims trailing spaces of every line and removes runs of
+// empty lines.
+func collapseBlankLines(s string) string {
+	var (
+		lines []string
+		blank bool
+	)
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			if blank || len(lines) == 0 {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// ConvertEmail converts an RFC 5322 message into a document: the first page
+// holds the headers and the body, preferring the plain text alternative, and
+// every attachment follows as its own page.
+func ConvertEmail(content []byte) (*Document, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email: %w", err)
+	}
+
+	var m emailParts
+	if err := m.walk(textproto.MIMEHeader(msg.Header), msg.Body); err != nil {
+		return nil, err
+	}
+
+	body := m.plain
+	if body == "" && m.html != "" {
+		body = HtmlToText([]byte(m.html))
+	}
+
+	header := emailHeader{
+		from:    decodeHeader(msg.Header.Get("From")),
+		to:      decodeHeader(msg.Header.Get("To")),
+		cc:      decodeHeader(msg.Header.Get("Cc")),
+		date:    msg.Header.Get("Date"),
+		subject: decodeHeader(msg.Header.Get("Subject")),
+	}
+
+	return emailDocument(header, body, m.attachments), nil
+}
+
+// emailHeader holds the headers of a message shown to the model.
+type emailHeader struct {
+	from, to, cc, date, subject string
+}
+
+// emailDocument builds the document of a parsed email.
+func emailDocument(header emailHeader, body string, attachments []emailAttachmentPart) *Document {
+	var sb strings.Builder
+	for _, h := range []struct{ name, value string }{
+		{"From", header.from},
+		{"To", header.to},
+		{"Cc", header.cc},
+		{"Date", header.date},
+		{"Subject", header.subject},
+	} {
+		if h.value != "" {
+			fmt.Fprintf(&sb, "%s: %s\n", h.name, h.value)
+		}
+	}
+	sb.WriteString("\n")
+	sb.WriteString(strings.TrimSpace(body))
+
+	doc := &Document{}
+	var listed []string
+	for _, part := range attachments {
+		att, err := ConvertAttachment(part.name, part.data, DefaultMaxAttachmentText)
+		switch {
+		case err != nil:
+			listed = append(listed, part.name+" (could not be read: "+err.Error()+")")
+		case att.Image != nil:
+			listed = append(listed, part.name+" (sent as an image)")
+			doc.Pages = append(doc.Pages, DocumentPage{Name: part.name, Image: att.Image})
+		case att.Text != "":
+			listed = append(listed, part.name)
+			doc.Pages = append(doc.Pages, DocumentPage{
+				Name: part.name,
+				Text: fmt.Sprintf("Attachment %s:\n\n%s", part.name, att.Text),
+			})
+		default:
+			listed = append(listed, part.name+" (unsupported file type, content not included)")
+		}
+	}
+	if len(listed) > 0 {
+		sb.WriteString("\n\nAttachments:\n")
+		for _, name := range listed {
+			sb.WriteString("- " + name + "\n")
+		}
+	}
+
+	doc.Pages = append([]DocumentPage{{Name: "message", Text: sb.String()}}, doc.Pages...)
+
+	return doc
+}
+
+type emailAttachmentPart struct {
+	name string
+	data []byte
+}
+
+// emailParts collects the bodies and attachments of a MIME tree.
+type emailParts struct {
+	plain       string
+	html        string
+	attachments []emailAttachmentPart
+}
+
+func (m *emailParts) walk(header textproto.MIMEHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read email part: %w", err)
+			}
+			if err := m.walk(part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransfer(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("failed to decode email part: %w", err)
+	}
+
+	disposition, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	name := decodeHeader(dispParams["filename"])
+	if name == "" {
+		name = decodeHeader(params["name"])
+	}
+
+	switch {
+	case mediaType == "message/rfc822":
+		if name == "" {
+			name = "message.eml"
+		}
+		m.attachments = append(m.attachments, emailAttachmentPart{name, data})
+	case disposition == "attachment" || name != "":
+		if name == "" {
+			name = "attachment"
+		}
+		m.attachments = append(m.attachments, emailAttachmentPart{name, data})
+	case mediaType == "text/plain" && m.plain == "":
+		m.plain = decodeCharset(data, params["charset"])
+	case mediaType == "text/html" && m.html == "":
+		m.html = decodeCharset(data, params["charset"])
+	}
+
+	return nil
+}
+
+// decodeTransfer undoes the Content-Transfer-Encoding of a part.
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+
+	return r
+}
+
+// decodeCharset converts text in the given charset to UTF-8. Unknown
+// charsets are kept as is.
+func decodeCharset(data []byte, charset string) string {
+	charset = strings.ToLower(charset)
+	if charset == "" || charset == "utf-8" || charset == "us-ascii" {
+		return string(data)
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return string(data)
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+
+	return string(decoded)
+}
+
+// headerDecoder decodes RFC 2047 encoded words in any charset known to
+// htmlindex.
+var headerDecoder = &mime.WordDecoder{
+	CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		enc, err := htmlindex.Get(charset)
+		if err != nil {
+			return nil, errors.New("unsupported charset " + charset)
+		}
+		return enc.NewDecoder().Reader(input), nil
+	},
+}
+
+func decodeHeader(value string) string {
+	decoded, err := headerDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+
+	return decoded
+}
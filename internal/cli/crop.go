@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"strconv"
+	"strings"
+)
+
+// CropValue is a crop coordinate, either in PDF points or in percent of the
+// page size.
+type CropValue struct {
+	Value   float64
+	Percent bool
+}
+
+func (v CropValue) String() string {
+	if v.Percent {
+		return strconv.FormatFloat(v.Value, 'f', -1, 64) + "%"
+	}
+
+	return strconv.FormatFloat(v.Value, 'f', -1, 64)
+}
+
+// resolve converts the value into pixels for a page dimension of pageSize
+// points rendered to pixels pixels.
+func (v CropValue) resolve(pageSize float64, pixels int) int {
+	if v.Percent {
+		return int(v.Value / 100 * float64(pixels))
+	}
+	if pageSize <= 0 {
+		return int(v.Value)
+	}
+
+	return int(v.Value / pageSize * float64(pixels))
+}
+
+// CropBox is a page region, with its origin at the top-left corner of the
+// page.
+type CropBox struct {
+	X, Y, W, H CropValue
+}
+
+func (c CropBox) String() string {
+	return fmt.Sprintf("%s,%s,%s,%s", c.X, c.Y, c.W, c.H)
+}
+
+// ParseCropBox parses "x,y,w,h" where every value is either in PDF points
+// (e.g. "72") or in percent of the page size (e.g. "10%").
+func ParseCropBox(s string) (CropBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return CropBox{}, fmt.Errorf("invalid crop box %q: expected x,y,w,h", s)
+	}
+
+	var vals [4]CropValue
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		percent := strings.HasSuffix(part, "%")
+
+		v, err := strconv.ParseFloat(strings.TrimSuffix(part, "%"), 64)
+		if err != nil || v < 0 {
+			return CropBox{}, fmt.Errorf("invalid crop value %q", part)
+		}
+		vals[i] = CropValue{Value: v, Percent: percent}
+	}
+
+	if vals[2].Value == 0 || vals[3].Value == 0 {
+		return CropBox{}, fmt.Errorf("invalid crop box %q: width and height must be positive", s)
+	}
+
+	return CropBox{X: vals[0], Y: vals[1], W: vals[2], H: vals[3]}, nil
+}
+
+// ParseCropMap parses per-page crop boxes given as "page=x,y,w,h".
+func ParseCropMap(entries []string) (map[int]CropBox, error) {
+	crops := make(map[int]CropBox, len(entries))
+	for _, entry := range entries {
+		pageStr, box, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid page crop %q: expected page=x,y,w,h", entry)
+		}
+
+		pageNum, err := strconv.Atoi(strings.TrimSpace(pageStr))
+		if err != nil || pageNum < 1 {
+			return nil, fmt.Errorf("invalid page number in crop %q", entry)
+		}
+
+		crop, err := ParseCropBox(box)
+		if err != nil {
+			return nil, err
+		}
+		crops[pageNum] = crop
+	}
+
+	return crops, nil
+}
+
+// Rect returns the crop region in pixels of an image rendered from a page of
+// pageWidth x pageHeight points, clamped to the image bounds.
+func (c CropBox) Rect(bounds image.Rectangle, pageWidth, pageHeight float64) image.Rectangle {
+	x := c.X.resolve(pageWidth, bounds.Dx())
+	y := c.Y.resolve(pageHeight, bounds.Dy())
+	w := c.W.resolve(pageWidth, bounds.Dx())
+	h := c.H.resolve(pageHeight, bounds.Dy())
+
+	r := image.Rect(x, y, x+w, y+h).Add(bounds.Min)
+	return r.Intersect(bounds)
+}
+
+// CropImage returns a copy of the r region of img.
+func CropImage(img image.Image, r image.Rectangle) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+
+	return dst
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// CrossCheckFile is the report of the OCR cross-validation of a run.
+const CrossCheckFile = "crosscheck.json"
+
+// DefaultAgreementThreshold is the similarity below which the model and the
+// local OCR are considered to disagree about a page.
+const DefaultAgreementThreshold = 0.8
+
+// CrossCheckEntry is the agreement of the model and the local OCR about the
+// text of a request.
+type CrossCheckEntry struct {
+	Name       string  `json:"name"`
+	Pages      []int   `json:"pages,omitempty"`
+	Similarity float64 `json:"similarity"`
+	Flagged    bool    `json:"flagged,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// CrossCheck collects the agreement of the responses of a run with the local
+// OCR of the same pages. It is safe for concurrent use.
+type CrossCheck struct {
+	SchemaVersion int               `json:"schema_version"`
+	Threshold     float64           `json:"threshold"`
+	Flagged       int               `json:"flagged"`
+	Entries       []CrossCheckEntry `json:"entries"`
+
+	mu sync.Mutex
+}
+
+// NewCrossCheck returns an empty cross-validation flagging the requests whose
+// similarity is below threshold.
+func NewCrossCheck(threshold float64) *CrossCheck {
+	return &CrossCheck{SchemaVersion: SchemaVersion, Threshold: threshold}
+}
+
+// Add compares the response of the model to a request with the text of the
+// local OCR and records their agreement.
+func (c *CrossCheck) Add(name string, pages []int, response, local string) CrossCheckEntry {
+	entry := CrossCheckEntry{Name: name, Pages: pages, Similarity: TextSimilarity(response, local)}
+	entry.Flagged = entry.Similarity < c.Threshold
+	c.add(entry)
+
+	return entry
+}
+
+// AddError records a request whose local OCR failed.
+func (c *CrossCheck) AddError(name string, pages []int, err error) {
+	c.add(CrossCheckEntry{Name: name, Pages: pages, Error: err.Error()})
+}
+
+func (c *CrossCheck) add(entry CrossCheckEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry.Flagged {
+		c.Flagged++
+	}
+	c.Entries = append(c.Entries, entry)
+}
+
+// WriteJSON stores the cross-validation at path, with the entries in page
+// order.
+func (c *CrossCheck) WriteJSON(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sort.SliceStable(c.Entries, func(i, j int) bool {
+		a, b := c.Entries[i], c.Entries[j]
+		if len(a.Pages) > 0 && len(b.Pages) > 0 && a.Pages[0] != b.Pages[0] {
+			return a.Pages[0] < b.Pages[0]
+		}
+		return a.Name < b.Name
+	})
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// TextSimilarity returns the agreement of two texts from 0 to 1: the share
+// of their words, ignoring case, punctuation and spacing, that align in
+// order.
+func TextSimilarity(a, b string) float64 {
+	wa, wb := words(a), words(b)
+	if len(wa)+len(wb) == 0 {
+		return 1
+	}
+
+	// Length of the longest common subsequence of words, row by row.
+	prev := make([]int, len(wb)+1)
+	cur := make([]int, len(wb)+1)
+	for i := range wa {
+		for j := range wb {
+			switch {
+			case wa[i] == wb[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] >= cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	return 2 * float64(prev[len(wb)]) / float64(len(wa)+len(wb))
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
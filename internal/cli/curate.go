@@ -0,0 +1,312 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Decisions about an example in a review.
+const (
+	DecisionPending = "pending"
+	DecisionAccept  = "accept"
+	DecisionReject  = "reject"
+)
+
+// Files of a curated dataset.
+const (
+	CuratedFile         = "curated.jsonl" // the accepted examples
+	CuratedManifestFile = "curated.json"  // where they come from
+)
+
+// Candidate is an example found in the outputs of a past run.
+type Candidate struct {
+	ID      string // content hash of the example
+	Source  string // dataset file and line, e.g. "out/doc/dataset.jsonl:3"
+	Dir     string // directory the image references are relative to
+	Example DatasetExample
+}
+
+// Response returns the response of the example.
+func (c Candidate) Response() string {
+	m, _ := c.Example.Message(RoleAssistant)
+	return m.Text()
+}
+
+// FindCandidates walks roots, directories of run outputs or dataset files,
+// and returns the examples of every DatasetFile found, in walk order.
+func FindCandidates(roots []string) ([]Candidate, error) {
+	var candidates []Candidate
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || (path != root && d.Name() != DatasetFile) {
+				return nil
+			}
+			return ReadDataset(path, func(line int, ex DatasetExample) error {
+				data, err := json.Marshal(ex)
+				if err != nil {
+					return err
+				}
+				candidates = append(candidates, Candidate{
+					ID:      HashBytes(data)[:16],
+					Source:  fmt.Sprintf("%s:%d", filepath.ToSlash(path), line),
+					Dir:     filepath.Dir(path),
+					Example: ex,
+				})
+				return nil
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return candidates, nil
+}
+
+// ReviewEntry is the decision about an example in a review file. Editing
+// Response replaces the response of the example in the curated dataset.
+type ReviewEntry struct {
+	ID       string   `yaml:"id"`
+	Source   string   `yaml:"source"`
+	Decision string   `yaml:"decision"`
+	Prompt   string   `yaml:"prompt"`
+	Images   []string `yaml:"images,omitempty"`
+	Response string   `yaml:"response"`
+}
+
+// Review holds the decisions about the examples of a dataset, written to a
+// YAML file that can be edited by hand and read back by a later build.
+type Review struct {
+	Examples []*ReviewEntry `yaml:"examples"`
+}
+
+// LoadReview reads the review file at path. A missing file is an empty
+// review.
+func LoadReview(path string) (*Review, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Review{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var r Review
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse review %s: %w", path, err)
+	}
+	for _, e := range r.Examples {
+		switch e.Decision {
+		case "":
+			e.Decision = DecisionPending
+		case DecisionPending, DecisionAccept, DecisionReject:
+		default:
+			return nil, fmt.Errorf("invalid decision of example %s in %s: %s", e.ID, path, e.Decision)
+		}
+	}
+
+	return &r, nil
+}
+
+// Write stores the review at path.
+func (r *Review) Write(path string) error {
+	data, err := yaml.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// Entry returns the entry of the example id, or nil.
+func (r *Review) Entry(id string) *ReviewEntry {
+	for _, e := range r.Examples {
+		if e.ID == id {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// Merge adds a pending entry for every candidate not reviewed yet, and
+// returns how many were added. Entries of examples no longer found are
+// kept.
+func (r *Review) Merge(candidates []Candidate) int {
+	added := 0
+	for _, c := range candidates {
+		if r.Entry(c.ID) != nil {
+			continue
+		}
+		user, _ := c.Example.Message(RoleUser)
+		r.Examples = append(r.Examples, &ReviewEntry{
+			ID:       c.ID,
+			Source:   c.Source,
+			Decision: DecisionPending,
+			Prompt:   user.Text(),
+			Images:   user.Images(),
+			Response: c.Response(),
+		})
+		added++
+	}
+
+	return added
+}
+
+// CuratedImage is an image referenced by a curated example.
+type CuratedImage struct {
+	Path   string `json:"path"` // relative to the curated dataset
+	SHA256 string `json:"sha256"`
+}
+
+// CuratedExample is an accepted example of a curated dataset.
+type CuratedExample struct {
+	ID     string         `json:"id"`
+	Source string         `json:"source"`
+	Edited bool           `json:"edited,omitempty"` // the response was corrected in review
+	Images []CuratedImage `json:"images,omitempty"`
+}
+
+// CuratedManifest describes a curated dataset: the accepted examples and
+// where they come from, and the decisions of the review.
+type CuratedManifest struct {
+	SchemaVersion int              `json:"schema_version"`
+	CreatedAt     time.Time        `json:"created_at"`
+	Dataset       string           `json:"dataset"`
+	Sources       []string         `json:"sources"`
+	Accepted      int              `json:"accepted"`
+	Rejected      int              `json:"rejected"`
+	Pending       int              `json:"pending"`
+	Examples      []CuratedExample `json:"examples"`
+}
+
+// BuildCurated writes the candidates accepted in review to CuratedFile in
+// dir, with their edited responses and their image references relative to
+// dir, and the manifest of the dataset to CuratedManifestFile.
+func BuildCurated(candidates []Candidate, review *Review, dir string) (*CuratedManifest, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	m := &CuratedManifest{
+		SchemaVersion: SchemaVersion,
+		CreatedAt:     time.Now().UTC(),
+		Dataset:       CuratedFile,
+		Sources:       []string{},
+		Examples:      []CuratedExample{},
+	}
+
+	var lines []byte
+	sources := make(map[string]bool)
+	for _, c := range candidates {
+		entry := review.Entry(c.ID)
+		switch {
+		case entry == nil || entry.Decision == DecisionPending:
+			m.Pending++
+			continue
+		case entry.Decision == DecisionReject:
+			m.Rejected++
+			continue
+		}
+
+		ex, curated, err := curate(c, entry, dir)
+		if err != nil {
+			return nil, fmt.Errorf("example %s of %s: %w", c.ID, c.Source, err)
+		}
+		data, err := json.Marshal(ex)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(append(lines, data...), '\n')
+		m.Examples = append(m.Examples, curated)
+		m.Accepted++
+
+		source, _, _ := strings.Cut(c.Source, ":")
+		if !sources[source] {
+			sources[source] = true
+			m.Sources = append(m.Sources, source)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, CuratedFile), lines, 0644); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, CuratedManifestFile), append(data, '\n'), 0644); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// curate returns the example of c with the response of entry and its local
+// image references made relative to dir, checksumming the images.
+func curate(c Candidate, entry *ReviewEntry, dir string) (DatasetExample, CuratedExample, error) {
+	curated := CuratedExample{ID: c.ID, Source: c.Source}
+	ex := DatasetExample{Messages: make([]DatasetMessage, len(c.Example.Messages))}
+	for i, msg := range c.Example.Messages {
+		switch content := msg.Content.(type) {
+		case string:
+			if msg.Role == RoleAssistant && entry.Response != content {
+				msg.Content, curated.Edited = entry.Response, true
+			}
+		case []DatasetContent:
+			parts := make([]DatasetContent, len(content))
+			for j, part := range content {
+				if part.ImageURL != nil && localImage(part.ImageURL.URL) {
+					image, err := curatedImage(filepath.Join(c.Dir, filepath.FromSlash(part.ImageURL.URL)), dir)
+					if err != nil {
+						return ex, curated, err
+					}
+					part.ImageURL = &DatasetImage{URL: image.Path}
+					curated.Images = append(curated.Images, image)
+				}
+				parts[j] = part
+			}
+			msg.Content = parts
+		}
+		ex.Messages[i] = msg
+	}
+
+	return ex, curated, nil
+}
+
+// localImage reports whether an image reference is a path rather than a URL.
+func localImage(ref string) bool {
+	return !strings.Contains(ref, "://") && !strings.HasPrefix(ref, "data:")
+}
+
+// curatedImage checksums the image at path and returns it relative to dir.
+func curatedImage(path, dir string) (CuratedImage, error) {
+	sum, err := HashFile(path)
+	if err != nil {
+		return CuratedImage{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return CuratedImage{}, err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return CuratedImage{}, err
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		rel = absPath
+	}
+
+	return CuratedImage{Path: filepath.ToSlash(rel), SHA256: sum}, nil
+}
@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// DatasetFile is the name of the fine-tuning dataset written next to the
+// outputs.
+const DatasetFile = "dataset.jsonl"
+
+// Roles of the messages of a dataset example.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// DatasetExample is a request of a run and its response in the chat layout
+// of fine-tuning datasets: a system message if any, the user message with
+// the prompt and references to the images, and the assistant message with
+// the response.
+type DatasetExample struct {
+	Messages []DatasetMessage `json:"messages"`
+}
+
+// DatasetMessage is a message of a dataset example. Content is a string, or
+// a list of DatasetContent parts for a user message with images.
+type DatasetMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+// UnmarshalJSON reads the content of a message as a string or a list of
+// parts.
+func (m *DatasetMessage) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	m.Role = raw.Role
+
+	var text string
+	if err := json.Unmarshal(raw.Content, &text); err == nil {
+		m.Content = text
+		return nil
+	}
+	var parts []DatasetContent
+	if err := json.Unmarshal(raw.Content, &parts); err != nil {
+		return fmt.Errorf("invalid content of %s message: %w", raw.Role, err)
+	}
+	m.Content = parts
+
+	return nil
+}
+
+// Text returns the text of the message, the text parts joined by blank lines
+// for a list of parts.
+func (m DatasetMessage) Text() string {
+	switch content := m.Content.(type) {
+	case string:
+		return content
+	case []DatasetContent:
+		var texts []string
+		for _, part := range content {
+			if part.Type == "text" {
+				texts = append(texts, part.Text)
+			}
+		}
+		return strings.Join(texts, "\n\n")
+	}
+
+	return ""
+}
+
+// Images returns the image references of the message.
+func (m DatasetMessage) Images() []string {
+	parts, _ := m.Content.([]DatasetContent)
+	var images []string
+	for _, part := range parts {
+		if part.ImageURL != nil {
+			images = append(images, part.ImageURL.URL)
+		}
+	}
+
+	return images
+}
+
+// DatasetContent is a part of the content of a message: text, or an image
+// referenced by URL or by path relative to the dataset.
+type DatasetContent struct {
+	Type     string        `json:"type"` // "text" or "image_url"
+	Text     string        `json:"text,omitempty"`
+	ImageURL *DatasetImage `json:"image_url,omitempty"`
+}
+
+// DatasetImage is the reference of an image of a dataset example.
+type DatasetImage struct {
+	URL string `json:"url"`
+}
+
+// NewDatasetExample returns the example of a request with system and prompt
+// about the images at imagePaths, answered with response.
+func NewDatasetExample(system, prompt string, imagePaths []string, response string) DatasetExample {
+	var ex DatasetExample
+	if system != "" {
+		ex.Messages = append(ex.Messages, DatasetMessage{Role: RoleSystem, Content: system})
+	}
+
+	var user any = prompt
+	if len(imagePaths) > 0 {
+		parts := []DatasetContent{{Type: "text", Text: prompt}}
+		for _, path := range imagePaths {
+			parts = append(parts, DatasetContent{Type: "image_url", ImageURL: &DatasetImage{URL: path}})
+		}
+		user = parts
+	}
+	ex.Messages = append(ex.Messages,
+		DatasetMessage{Role: RoleUser, Content: user},
+		DatasetMessage{Role: RoleAssistant, Content: response})
+
+	return ex
+}
+
+// Message returns the first message of the example with role, and false if
+// there is none.
+func (ex DatasetExample) Message(role string) (DatasetMessage, bool) {
+	for _, m := range ex.Messages {
+		if m.Role == role {
+			return m, true
+		}
+	}
+
+	return DatasetMessage{}, false
+}
+
+// ReadDataset calls fn with every example of the dataset at path and its
+// line, in order.
+func ReadDataset(path string, fn func(line int, ex DatasetExample) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var ex DatasetExample
+		if err := json.Unmarshal(scanner.Bytes(), &ex); err != nil {
+			return fmt.Errorf("failed to parse dataset %s line %d: %w", path, line, err)
+		}
+		if err := fn(line, ex); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Dataset writes the examples of a run to a JSON lines file as they
+// complete. It is safe for concurrent use.
+type Dataset struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// CreateDataset creates the dataset at path, replacing the examples of an
+// earlier run: responses reused from the cache are written again.
+func CreateDataset(path string) (*Dataset, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Dataset{path: path, f: f}, nil
+}
+
+// Path returns the path of the dataset.
+func (d *Dataset) Path() string {
+	return d.path
+}
+
+// Add appends ex to the dataset.
+func (d *Dataset) Add(ex DatasetExample) error {
+	data, err := json.Marshal(ex)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err = d.f.Write(append(data, '\n'))
+
+	return err
+}
+
+// Close closes the dataset file.
+func (d *Dataset) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.f == nil {
+		return nil
+	}
+	err := d.f.Close()
+	d.f = nil
+
+	return err
+}
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Line operations of a diff.
+const (
+	DiffEqual  = ' '
+	DiffDelete = '-'
+	DiffInsert = '+'
+)
+
+// DiffLine is a line of a diff: kept, deleted from the old text or inserted
+// in the new one.
+type DiffLine struct {
+	Op   byte
+	Text string
+}
+
+// maxDiffCells bounds the memory of the LCS table; larger inputs are diffed
+// as a whole replacement.
+const maxDiffCells = 4_000_000
+
+// DiffLines returns the line diff turning a into b, from their longest common
+// subsequence.
+func DiffLines(a, b []string) []DiffLine {
+	// Common prefix and suffix are kept as is, which keeps the table small
+	// for the usual mostly-unchanged responses.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var diff []DiffLine
+	for _, line := range a[:prefix] {
+		diff = append(diff, DiffLine{DiffEqual, line})
+	}
+	diff = append(diff, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		diff = append(diff, DiffLine{DiffEqual, line})
+	}
+
+	return diff
+}
+
+func diffMiddle(a, b []string) []DiffLine {
+	var diff []DiffLine
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		for _, line := range a {
+			diff = append(diff, DiffLine{DiffDelete, line})
+		}
+		for _, line := range b {
+			diff = append(diff, DiffLine{DiffInsert, line})
+		}
+		return diff
+	}
+
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, DiffLine{DiffEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, DiffLine{DiffDelete, a[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{DiffInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, DiffLine{DiffDelete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, DiffLine{DiffInsert, b[j]})
+	}
+
+	return diff
+}
+
+// UnifiedDiff returns the diff of two texts in unified format, with context
+// lines around the changes, or "" if they are equal.
+func UnifiedDiff(nameA, nameB, a, b string, context int) string {
+	if a == b {
+		return ""
+	}
+
+	diff := DiffLines(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+	for start := 0; start < len(diff); {
+		// Find the next change and the end of its hunk: changes less than
+		// 2*context lines apart share a hunk.
+		first := start
+		for first < len(diff) && diff[first].Op == DiffEqual {
+			first++
+		}
+		if first == len(diff) {
+			break
+		}
+		last := first
+		for k := first; k < len(diff); k++ {
+			if diff[k].Op != DiffEqual {
+				last = k
+			} else if k-last > 2*context {
+				break
+			}
+		}
+
+		from, to := max(first-context, start), min(last+context+1, len(diff))
+		lineA, lineB := 1, 1
+		for _, d := range diff[:from] {
+			if d.Op != DiffInsert {
+				lineA++
+			}
+			if d.Op != DiffDelete {
+				lineB++
+			}
+		}
+		countA, countB := 0, 0
+		for _, d := range diff[from:to] {
+			if d.Op != DiffInsert {
+				countA++
+			}
+			if d.Op != DiffDelete {
+				countB++
+			}
+		}
+
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", lineA, countA, lineB, countB)
+		for _, d := range diff[from:to] {
+			sb.WriteByte(d.Op)
+			sb.WriteString(d.Text)
+			sb.WriteByte('\n')
+		}
+		start = to
+	}
+
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// DocumentDigest is the stored digest of the pages of a document, reused by
+// every question about it until the document changes.
+type DocumentDigest struct {
+	SchemaVersion int                `json:"schema_version"`
+	Source        string             `json:"source"`
+	SHA256        string             `json:"sha256"`
+	Model         string             `json:"model"`
+	Pages         []uniai.PageDigest `json:"pages"`
+}
+
+// LoadDocumentDigest reads the digest stored at path. It returns nil and no
+// error if there is none.
+func LoadDocumentDigest(path string) (*DocumentDigest, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var digest DocumentDigest
+	if err := json.Unmarshal(data, &digest); err != nil {
+		return nil, fmt.Errorf("invalid digest %s: %w", path, err)
+	}
+
+	return &digest, nil
+}
+
+// Covers reports whether the digest was built from the document of hash
+// sum and has every page of pageNumbers. Any digest of the document covers
+// an empty pageNumbers.
+func (d *DocumentDigest) Covers(sum string, pageNumbers []int) bool {
+	if d == nil || d.SHA256 != sum || len(d.Pages) == 0 {
+		return false
+	}
+	if len(pageNumbers) == 0 {
+		return true
+	}
+
+	have := make(map[int]bool, len(d.Pages))
+	for _, p := range d.Pages {
+		have[p.Page] = true
+	}
+	for _, n := range pageNumbers {
+		if !have[n] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Select returns the digests of pageNumbers, all of them if pageNumbers is
+// empty.
+func (d *DocumentDigest) Select(pageNumbers []int) []uniai.PageDigest {
+	if len(pageNumbers) == 0 {
+		return d.Pages
+	}
+
+	want := make(map[int]bool, len(pageNumbers))
+	for _, n := range pageNumbers {
+		want[n] = true
+	}
+	var pages []uniai.PageDigest
+	for _, p := range d.Pages {
+		if want[p.Page] {
+			pages = append(pages, p)
+		}
+	}
+
+	return pages
+}
+
+// WriteJSON stores the digest at path.
+func (d *DocumentDigest) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// DefaultMinImageSize is the minimum width and height in pixels of embedded
+// images worth sending; smaller ones are usually icons, bullets or rules.
+const DefaultMinImageSize = 100
+
+// ExtractPageImages returns the raster images embedded in the page whose
+// native width and height are both at least minSize pixels.
+func ExtractPageImages(page *model.PdfPage, minSize int) ([]image.Image, error) {
+	if page == nil {
+		return nil, errors.New("page is nil")
+	}
+
+	ex, err := extractor.New(page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create extractor: %w", err)
+	}
+
+	pageImages, err := ex.ExtractPageImages(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract images: %w", err)
+	}
+
+	var images []image.Image
+	for _, mark := range pageImages.Images {
+		if mark.Image == nil || mark.Image.Width < int64(minSize) || mark.Image.Height < int64(minSize) {
+			continue
+		}
+
+		img, err := mark.Image.ToGoImage()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode embedded image: %w", err)
+		}
+		images = append(images, img)
+	}
+
+	return images, nil
+}
+
+// EmbeddedImagePath returns the path of the index-th embedded image of a page
+// in out.
+func EmbeddedImagePath(out OutputDir, pageNumber, index int) string {
+	return out.Path(fmt.Sprintf("page_%d_image_%d.jpg", pageNumber, index+1))
+}
@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register the WebP decoder
+)
+
+// Output image formats of [RenderSettings].
+const (
+	FormatJpeg = "jpeg"
+	FormatWebp = "webp"
+
+	// FormatAuto encodes both JPEG and WebP at the same quality and keeps
+	// the smaller one.
+	FormatAuto = "auto"
+)
+
+const (
+	// minBudgetQuality is the lowest quality used to fit a byte budget
+	// before the image is downscaled instead.
+	minBudgetQuality = 40
+
+	// budgetScaleStep is the factor applied to the image size when the
+	// lowest quality still exceeds the byte budget.
+	budgetScaleStep = 0.8
+
+	// minBudgetWidth is the width below which images are no longer
+	// downscaled; text becomes unreadable for the model.
+	minBudgetWidth = 400
+)
+
+// ErrBudgetExceeded is returned when an image cannot be encoded within the
+// byte budget, even at the lowest quality and scale.
+var ErrBudgetExceeded = errors.New("image exceeds the byte budget at the lowest quality and size")
+
+// encodeFunc encodes an image at the given quality.
+type encodeFunc func(img image.Image, quality int) ([]byte, error)
+
+// ValidFormat reports whether format is a supported output format.
+func ValidFormat(format string) bool {
+	switch format {
+	case "", FormatJpeg, FormatWebp, FormatAuto:
+		return true
+	}
+
+	return false
+}
+
+// EncodeImage encodes img in the format of the settings, within their byte
+// budget.
+func EncodeImage(img image.Image, settings RenderSettings) ([]byte, error) {
+	switch settings.Format {
+	case "", FormatJpeg:
+		return encodeBudget(img, settings.MaxBytes, settings.Quality, EncodeJpeg)
+	case FormatWebp:
+		return encodeBudget(img, settings.MaxBytes, settings.Quality, EncodeWebp)
+	case FormatAuto:
+		jpegData, err := encodeBudget(img, settings.MaxBytes, settings.Quality, EncodeJpeg)
+		if err != nil {
+			return nil, err
+		}
+
+		webpData, err := encodeBudget(img, settings.MaxBytes, settings.Quality, EncodeWebp)
+		if err != nil || len(webpData) >= len(jpegData) {
+			// WebP is an optimization, fall back to JPEG when it is not
+			// available or not smaller.
+			return jpegData, nil
+		}
+		return webpData, nil
+	default:
+		return nil, fmt.Errorf("unsupported image format %q", settings.Format)
+	}
+}
+
+// EncodeJpegBudget encodes img as JPEG no larger than maxBytes. The highest
+// quality up to maxQuality that fits is searched first; when even
+// minBudgetQuality is too large the image is progressively downscaled. With
+// maxBytes <= 0 the image is encoded at maxQuality.
+func EncodeJpegBudget(img image.Image, maxBytes, maxQuality int) ([]byte, error) {
+	return encodeBudget(img, maxBytes, maxQuality, EncodeJpeg)
+}
+
+func encodeBudget(img image.Image, maxBytes, maxQuality int, enc encodeFunc) ([]byte, error) {
+	if maxBytes <= 0 {
+		return enc(img, maxQuality)
+	}
+
+	for {
+		data, err := encodeWithinBudget(img, maxBytes, maxQuality, enc)
+		if err != nil || data != nil {
+			return data, err
+		}
+
+		width := int(float64(img.Bounds().Dx()) * budgetScaleStep)
+		if width < minBudgetWidth {
+			return nil, ErrBudgetExceeded
+		}
+		img = ScaleToWidth(img, width)
+	}
+}
+
+// encodeWithinBudget binary-searches the highest quality fitting maxBytes. It
+// returns nil data when no quality down to minBudgetQuality fits.
+func encodeWithinBudget(img image.Image, maxBytes, maxQuality int, enc encodeFunc) ([]byte, error) {
+	data, err := enc(img, maxQuality)
+	if err != nil || len(data) <= maxBytes {
+		return data, err
+	}
+
+	var best []byte
+	lo, hi := minBudgetQuality, maxQuality-1
+	for lo <= hi {
+		q := (lo + hi) / 2
+		data, err := enc(img, q)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(data) <= maxBytes {
+			best = data
+			lo = q + 1
+		} else {
+			hi = q - 1
+		}
+	}
+
+	return best, nil
+}
+
+// EncodeWebp encodes img as lossy WebP using the cwebp tool from libwebp,
+// which must be available in PATH.
+func EncodeWebp(img image.Image, quality int) ([]byte, error) {
+	cwebp, err := exec.LookPath("cwebp")
+	if err != nil {
+		return nil, errors.New("WebP encoding requires the cwebp tool (libwebp) in PATH")
+	}
+
+	dir, err := os.MkdirTemp("", "uniai-webp-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "in.png")
+	out := filepath.Join(dir, "out.webp")
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	if err := os.WriteFile(in, buf.Bytes(), 0600); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(cwebp, "-quiet", "-q", strconv.Itoa(quality), in, "-o", out)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("cwebp failed: %w: %s", err, bytes.TrimSpace(output))
+	}
+
+	return os.ReadFile(out)
+}
+
+// ImageExt returns the file extension matching the encoded image data.
+func ImageExt(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/webp":
+		return ".webp"
+	case "image/png":
+		return ".png"
+	default:
+		return ".jpg"
+	}
+}
+
+// ScaleToWidth resizes img to width pixels, keeping the aspect ratio.
+func ScaleToWidth(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	if b.Dx() == width || b.Dx() == 0 {
+		return img
+	}
+
+	height := max(1, b.Dy()*width/b.Dx())
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+
+	return dst
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// PageError is the failure of a page of a document in a stage of the
+// pipeline. In JSON, Err is its message.
+type PageError struct {
+	Doc   string // the document, as given to the run; empty if unknown
+	Page  int
+	Stage string // e.g. "render", "generate", "ocr"
+	Err   error
+}
+
+func (e *PageError) Error() string {
+	if e.Doc != "" {
+		return fmt.Sprintf("%s: page %d: %s: %v", e.Doc, e.Page, e.Stage, e.Err)
+	}
+
+	return fmt.Sprintf("page %d: %s: %v", e.Page, e.Stage, e.Err)
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
+
+// pageErrorJSON is the JSON form of a PageError.
+type pageErrorJSON struct {
+	Doc   string `json:"doc,omitempty"`
+	Page  int    `json:"page"`
+	Stage string `json:"stage"`
+	Error string `json:"error"`
+}
+
+func (e *PageError) MarshalJSON() ([]byte, error) {
+	v := pageErrorJSON{Doc: e.Doc, Page: e.Page, Stage: e.Stage}
+	if e.Err != nil {
+		v.Error = e.Err.Error()
+	}
+
+	return json.Marshal(v)
+}
+
+func (e *PageError) UnmarshalJSON(data []byte) error {
+	var v pageErrorJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*e = PageError{Doc: v.Doc, Page: v.Page, Stage: v.Stage, Err: errors.New(v.Error)}
+
+	return nil
+}
+
+// PageErrors collects the page failures of a run. It is safe for concurrent
+// use; a nil *PageErrors ignores failures.
+type PageErrors struct {
+	Doc string // the document of the pages, set on every PageError added
+
+	mu   sync.Mutex
+	errs []*PageError
+}
+
+// Add records the failure of every page of pages of the document in stage,
+// and returns the recorded errors.
+func (e *PageErrors) Add(stage string, pages []int, err error) []*PageError {
+	if e == nil || err == nil {
+		return nil
+	}
+
+	var added []*PageError
+	for _, page := range pages {
+		added = append(added, &PageError{Doc: e.Doc, Page: page, Stage: stage, Err: err})
+	}
+
+	e.mu.Lock()
+	e.errs = append(e.errs, added...)
+	e.mu.Unlock()
+
+	return added
+}
+
+// Errors returns the recorded failures ordered by page.
+func (e *PageErrors) Errors() []*PageError {
+	if e == nil {
+		return nil
+	}
+
+	e.mu.Lock()
+	errs := slices.Clone(e.errs)
+	e.mu.Unlock()
+
+	slices.SortStableFunc(errs, func(a, b *PageError) int {
+		return a.Page - b.Page
+	})
+
+	return errs
+}
+
+// Pages returns the failed pages in order, each once.
+func (e *PageErrors) Pages() []int {
+	var pages []int
+	for _, err := range e.Errors() {
+		if !slices.Contains(pages, err.Page) {
+			pages = append(pages, err.Page)
+		}
+	}
+
+	return pages
+}
+
+// Err returns nil if no page failed, and otherwise an error wrapping every
+// failure.
+func (e *PageErrors) Err() error {
+	errs := e.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+
+	joined := make([]error, len(errs))
+	for i, err := range errs {
+		joined[i] = err
+	}
+
+	return &RunError{Pages: e.Pages(), Err: errors.Join(joined...)}
+}
+
+// RunError is returned by runs in which some pages failed.
+type RunError struct {
+	Pages []int // the failed pages, in order
+	Err   error // the failures joined
+}
+
+func (e *RunError) Error() string {
+	return fmt.Sprintf("%d page(s) failed: %v", len(e.Pages), e.Pages)
+}
+
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// PageEstimate is the estimated prompt tokens of the request of a page.
+type PageEstimate struct {
+	Page         int    `json:"page"`
+	Name         string `json:"name,omitempty"` // of a converted document page
+	Width        int    `json:"width,omitempty"`
+	Height       int    `json:"height,omitempty"`
+	ImageTokens  int    `json:"image_tokens"`
+	PromptTokens int    `json:"prompt_tokens"` // of the prompt and the page text
+}
+
+// Tokens returns the estimated prompt tokens of the request.
+func (p PageEstimate) Tokens() int {
+	return p.ImageTokens + p.PromptTokens
+}
+
+// Estimate is the estimated prompt tokens and cost of processing the pages
+// of a document, computed locally before sending any request. The answers
+// are not included, as their length is not known in advance.
+type Estimate struct {
+	Model        string         `json:"model"`
+	Pages        []PageEstimate `json:"pages"`
+	ImageTokens  int            `json:"image_tokens"`
+	PromptTokens int            `json:"prompt_tokens"`
+	Tokens       int            `json:"tokens"`
+	Cost         float64        `json:"cost"`
+}
+
+// NewEstimate returns an empty estimate of requests to model.
+func NewEstimate(model string) *Estimate {
+	return &Estimate{Model: model, Pages: []PageEstimate{}}
+}
+
+// AddImage adds a page sent as an image of width×height pixels with prompt.
+func (e *Estimate) AddImage(page int, width, height int, prompt string) {
+	e.add(PageEstimate{
+		Page:         page,
+		Width:        width,
+		Height:       height,
+		ImageTokens:  uniai.ImageTokenSchemeFor(e.Model).Tokens(width, height),
+		PromptTokens: uniai.EstimateTokens(prompt),
+	})
+}
+
+// AddText adds a page sent as text with prompt.
+func (e *Estimate) AddText(page int, name, text, prompt string) {
+	e.add(PageEstimate{Page: page, Name: name, PromptTokens: uniai.EstimateTokens(prompt) + uniai.EstimateTokens(text)})
+}
+
+func (e *Estimate) add(p PageEstimate) {
+	e.Pages = append(e.Pages, p)
+	e.ImageTokens += p.ImageTokens
+	e.PromptTokens += p.PromptTokens
+	e.Tokens += p.Tokens()
+}
+
+// Price sets the cost of the estimate from the prompt price of its model.
+func (e *Estimate) Price(prices Prices) {
+	e.Cost = prices.For(e.Model).Cost(e.Tokens, 0)
+}
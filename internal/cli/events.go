@@ -0,0 +1,22 @@
+package cli
+
+// Progress events of document processing, streamed to clients of the serve
+// mode.
+const (
+	EventStarted       = "started"        // the document was loaded
+	EventPageRendered  = "page_rendered"  // a page is ready to be sent
+	EventToken         = "token"          // a chunk of a response was generated
+	EventPageCompleted = "page_completed" // the response of a page is complete
+	EventDone          = "done"           // all pages were processed
+	EventError         = "error"          // processing failed
+)
+
+// Event is a progress event of a job.
+type Event struct {
+	Type  string `json:"type"`
+	Job   string `json:"job,omitempty"`
+	Page  int    `json:"page,omitempty"`
+	Pages int    `json:"pages,omitempty"` // number of pages, in started events
+	Text  string `json:"text,omitempty"`  // token chunk or complete response
+	Error string `json:"error,omitempty"`
+}
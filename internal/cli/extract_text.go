@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// DefaultMinTextChars is the minimum number of letters and digits a page needs
+// in its text layer for the layer to be considered usable.
+const DefaultMinTextChars = 200
+
+// Page modes, choosing how the pages of a PDF are sent to the model.
+const (
+	// PageModeImage renders every page to an image.
+	PageModeImage = "image"
+	// PageModeAuto sends the text layer of the pages where it has at least
+	// the minimum number of letters and digits, and renders the others.
+	PageModeAuto = "auto"
+	// PageModeText sends the text layer of every page that has one, and only
+	// renders the pages without any text, such as scans.
+	PageModeText = "text"
+)
+
+// ExtractPageText returns the text layer of the page as plain text.
+func ExtractPageText(page *model.PdfPage) (string, error) {
+	if page == nil {
+		return "", errors.New("page is nil")
+	}
+
+	ex, err := extractor.New(page)
+	if err != nil {
+		return "", fmt.Errorf("failed to create extractor: %w", err)
+	}
+
+	text, err := ex.ExtractText()
+	if err != nil {
+		return "", fmt.Errorf("failed to extract text: %w", err)
+	}
+
+	return text, nil
+}
+
+// HasUsableText reports whether text contains at least minChars letters or
+// digits. Scanned pages often carry an empty or near-empty text layer (page
+// numbers, stamps), which should not be mistaken for real content.
+func HasUsableText(text string, minChars int) bool {
+	var n int
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			n++
+			if n >= minChars {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// TextPrompt combines the user prompt with the extracted page text for a
+// text-only request.
+func TextPrompt(prompt, text string) string {
+	var sb strings.Builder
+	sb.WriteString(prompt)
+	sb.WriteString("\n\nThe page content extracted from the PDF text layer is:\n\n")
+	sb.WriteString(strings.TrimSpace(text))
+
+	return sb.String()
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// DefaultFallbackAfter is the default number of consecutive overloaded
+// requests switching a run to its fallback model.
+const DefaultFallbackAfter = 3
+
+// Overloaded reports whether err tells that the API is overloaded: a rate
+// limit, or a 503 Service Unavailable.
+func Overloaded(err error) bool {
+	var limited *uniai.RateLimitError
+	if errors.As(err, &limited) {
+		return true
+	}
+	var status uniai.StatusError
+	if errors.As(err, &status) {
+		return status.StatusCode == http.StatusServiceUnavailable
+	}
+
+	return false
+}
+
+// ModelFallback switches the requests of a run from its primary model to a
+// smaller Model once the primary is overloaded: after After consecutive
+// requests to it failed with [Overloaded] errors. The switch lasts for the
+// rest of the run. It is safe for concurrent use; a nil *ModelFallback never
+// switches.
+type ModelFallback struct {
+	Model string
+	After int
+
+	mu       sync.Mutex
+	failures int
+	active   bool
+}
+
+// NewModelFallback returns the fallback to model after consecutive
+// overloaded requests, DefaultFallbackAfter if 0.
+func NewModelFallback(model string, after int) *ModelFallback {
+	if after == 0 {
+		after = DefaultFallbackAfter
+	}
+
+	return &ModelFallback{Model: model, After: after}
+}
+
+// Active reports whether the requests are switched to the fallback model.
+func (f *ModelFallback) Active() bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.active
+}
+
+// Record records the outcome of a request to the primary model and reports
+// whether it switched the requests to the fallback model. A successful
+// request resets the count of consecutive failures, while errors other than
+// overloads do not count.
+func (f *ModelFallback) Record(err error) bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case f.active:
+		return false
+	case err == nil:
+		f.failures = 0
+	case Overloaded(err):
+		f.failures++
+		f.active = f.failures >= f.After
+		return f.active
+	}
+
+	return false
+}
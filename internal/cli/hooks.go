@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Hook stages, passed to hook commands as the "stage" field and the
+// UNIAI_HOOK_STAGE environment variable.
+const (
+	// HookPreprocessImage runs on every page image before it is sent.
+	HookPreprocessImage = "preprocess-image"
+	// HookPostprocessResponse runs on every complete response.
+	HookPostprocessResponse = "postprocess-response"
+)
+
+// DefaultHookTimeout bounds a single hook invocation.
+const DefaultHookTimeout = time.Minute
+
+// ImagePreprocessor transforms a page image before it is sent to the model.
+type ImagePreprocessor func(ctx context.Context, pageNum int, image []byte) ([]byte, error)
+
+// ResponsePostprocessor transforms a response. pages are the source pages of
+// the request.
+type ResponsePostprocessor func(ctx context.Context, pages []int, prompt, response string) (string, error)
+
+// Hooks holds the processors invoked at the defined points of the pipeline,
+// run in the order they were added. A nil Hooks does nothing.
+type Hooks struct {
+	images    []ImagePreprocessor
+	responses []ResponsePostprocessor
+}
+
+// AddImagePreprocessor appends an image preprocessor.
+func (h *Hooks) AddImagePreprocessor(fn ImagePreprocessor) {
+	h.images = append(h.images, fn)
+}
+
+// AddResponsePostprocessor appends a response postprocessor.
+func (h *Hooks) AddResponsePostprocessor(fn ResponsePostprocessor) {
+	h.responses = append(h.responses, fn)
+}
+
+// HasImagePreprocessors reports whether page images are preprocessed.
+func (h *Hooks) HasImagePreprocessors() bool {
+	return h != nil && len(h.images) > 0
+}
+
+// HasResponsePostprocessors reports whether responses are postprocessed.
+func (h *Hooks) HasResponsePostprocessors() bool {
+	return h != nil && len(h.responses) > 0
+}
+
+// PreprocessImage runs the image preprocessors on a page image.
+func (h *Hooks) PreprocessImage(ctx context.Context, pageNum int, image []byte) ([]byte, error) {
+	if h == nil {
+		return image, nil
+	}
+
+	for _, fn := range h.images {
+		var err error
+		if image, err = fn(ctx, pageNum, image); err != nil {
+			return nil, err
+		}
+	}
+
+	return image, nil
+}
+
+// PostprocessResponse runs the response postprocessors on a response.
+func (h *Hooks) PostprocessResponse(ctx context.Context, pages []int, prompt, response string) (string, error) {
+	if h == nil {
+		return response, nil
+	}
+
+	for _, fn := range h.responses {
+		var err error
+		if response, err = fn(ctx, pages, prompt, response); err != nil {
+			return "", err
+		}
+	}
+
+	return response, nil
+}
+
+// HookRequest is the JSON object written to the standard input of a hook
+// command. Images are base64-encoded.
+type HookRequest struct {
+	Stage    string `json:"stage"`
+	Page     int    `json:"page,omitempty"`
+	Pages    []int  `json:"pages,omitempty"`
+	Image    []byte `json:"image,omitempty"`
+	Prompt   string `json:"prompt,omitempty"`
+	Response string `json:"response,omitempty"`
+}
+
+// HookResult is the JSON object a hook command writes to its standard
+// output. Fields left out, or an empty output, keep the input unchanged.
+type HookResult struct {
+	Image    []byte  `json:"image,omitempty"`
+	Response *string `json:"response,omitempty"`
+}
+
+// ExecImagePreprocessor returns a preprocessor running command through the
+// shell with a HookRequest on its standard input.
+func ExecImagePreprocessor(command string, timeout time.Duration) ImagePreprocessor {
+	return func(ctx context.Context, pageNum int, image []byte) ([]byte, error) {
+		res, err := runHook(ctx, command, timeout, HookRequest{
+			Stage: HookPreprocessImage,
+			Page:  pageNum,
+			Image: image,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if res.Image == nil {
+			return image, nil
+		}
+
+		return res.Image, nil
+	}
+}
+
+// ExecResponsePostprocessor returns a postprocessor running command through
+// the shell with a HookRequest on its standard input.
+func ExecResponsePostprocessor(command string, timeout time.Duration) ResponsePostprocessor {
+	return func(ctx context.Context, pages []int, prompt, response string) (string, error) {
+		res, err := runHook(ctx, command, timeout, HookRequest{
+			Stage:    HookPostprocessResponse,
+			Pages:    pages,
+			Prompt:   prompt,
+			Response: response,
+		})
+		if err != nil {
+			return "", err
+		}
+		if res.Response == nil {
+			return response, nil
+		}
+
+		return *res.Response, nil
+	}
+}
+
+func runHook(ctx context.Context, command string, timeout time.Duration, req HookRequest) (HookResult, error) {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return HookResult{}, err
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), "UNIAI_HOOK_STAGE="+req.Stage)
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return HookResult{}, fmt.Errorf("hook %q failed: %w: %s", command, err, msg)
+		}
+		return HookResult{}, fmt.Errorf("hook %q failed: %w", command, err)
+	}
+
+	var res HookResult
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return res, nil
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		return HookResult{}, fmt.Errorf("hook %q returned invalid JSON: %w", command, err)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// PageIndexFile is the name of the index of the page hashes and responses of
+// a document, next to its outputs, for incremental runs.
+const PageIndexFile = "pages.json"
+
+// unhashedKeys are the keys of the page objects left out of page hashes:
+// links to the page tree and to other pages, which would make a page change
+// with every other page.
+var unhashedKeys = []core.PdfObjectName{"Parent", "P", "Dest", "A", "StructParents", "Popup"}
+
+// inheritedKeys are the page attributes inherited from the page tree.
+var inheritedKeys = []core.PdfObjectName{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// PageHashes returns the content hashes of the pages of the PDF at path, by
+// page number. A page hash covers its content streams, resources (images,
+// fonts), annotations and geometry, so that it changes with what the page
+// shows, whatever page number it has. Pages are not rendered.
+func PageHashes(path string) (map[int]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader, err := model.NewPdfReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF file: %w", err)
+	}
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[int]string, numPages)
+	for pageNum := 1; pageNum <= numPages; pageNum++ {
+		page, err := reader.GetPage(pageNum)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read page %d: %w", pageNum, err)
+		}
+		hashes[pageNum] = hashPage(page.GetPageDict())
+	}
+
+	return hashes, nil
+}
+
+// hashPage returns the hash of a page dictionary, with its inherited
+// attributes.
+func hashPage(dict *core.PdfObjectDictionary) string {
+	h := &objectHasher{h: sha256.New(), seen: make(map[core.PdfObject]int)}
+	h.write(dict)
+	for _, key := range inheritedKeys {
+		if dict.Get(key) != nil {
+			continue
+		}
+		for parent := dict; parent != nil; {
+			parent, _ = core.GetDict(parent.Get("Parent"))
+			if parent != nil && parent.Get(key) != nil {
+				fmt.Fprintf(h.h, "/%s", key)
+				h.write(parent.Get(key))
+				break
+			}
+		}
+	}
+
+	return hex.EncodeToString(h.h.Sum(nil))
+}
+
+// objectHasher writes PDF objects to a hash, following references once.
+type objectHasher struct {
+	h    hash.Hash
+	seen map[core.PdfObject]int // indirect objects by order of appearance
+}
+
+func (h *objectHasher) write(obj core.PdfObject) {
+	switch o := obj.(type) {
+	case nil:
+		io.WriteString(h.h, "null")
+	case *core.PdfObjectReference:
+		h.write(o.Resolve())
+	case *core.PdfIndirectObject:
+		if h.visited(o) {
+			return
+		}
+		h.write(o.PdfObject)
+	case *core.PdfObjectStream:
+		if h.visited(o) {
+			return
+		}
+		h.write(o.PdfObjectDictionary)
+		fmt.Fprintf(h.h, "stream %d\n", len(o.Stream))
+		h.h.Write(o.Stream)
+	case *core.PdfObjectDictionary:
+		keys := o.Keys()
+		slices.Sort(keys)
+		io.WriteString(h.h, "<<")
+		for _, key := range keys {
+			if slices.Contains(unhashedKeys, key) {
+				continue
+			}
+			fmt.Fprintf(h.h, "/%s ", key)
+			h.write(o.Get(key))
+		}
+		io.WriteString(h.h, ">>")
+	case *core.PdfObjectArray:
+		io.WriteString(h.h, "[")
+		for _, elem := range o.Elements() {
+			h.write(elem)
+			io.WriteString(h.h, " ")
+		}
+		io.WriteString(h.h, "]")
+	default:
+		io.WriteString(h.h, o.String())
+	}
+}
+
+// visited reports whether obj was written already, writing a reference to it
+// if so.
+func (h *objectHasher) visited(obj core.PdfObject) bool {
+	if n, ok := h.seen[obj]; ok {
+		fmt.Fprintf(h.h, "@%d", n)
+		return true
+	}
+	h.seen[obj] = len(h.seen)
+
+	return false
+}
+
+// SettingsHash returns the hash of the settings of the requests of a run
+// that its responses depend on besides the pages: the model, prompts, schema
+// and options, and how the pages are rendered.
+func SettingsHash(model, system, prompt string, format json.RawMessage, options map[string]any, render RenderSettings) string {
+	data, _ := json.Marshal(struct {
+		Model   string          `json:"model"`
+		System  string          `json:"system"`
+		Prompt  string          `json:"prompt"`
+		Format  json.RawMessage `json:"format,omitempty"`
+		Options map[string]any  `json:"options"`
+		Render  RenderSettings  `json:"render"`
+	}{model, system, prompt, format, options, render})
+
+	return HashBytes(data)
+}
+
+// IndexedPage is a page of a document in a [PageIndex].
+type IndexedPage struct {
+	Page     int    `json:"page"`
+	Hash     string `json:"hash"`
+	Response string `json:"response"`
+}
+
+// PageIndex records the hash and response of every page of the last
+// revision of a document processed with given settings, so that the next
+// revision of the document only has the pages that changed processed again.
+// Pages are matched by hash, so that pages moved by an insertion are reused
+// too.
+type PageIndex struct {
+	Source       string        `json:"source"`
+	SourceSHA256 string        `json:"source_sha256"`
+	Settings     string        `json:"settings_sha256"` // see SettingsHash
+	UpdatedAt    time.Time     `json:"updated_at"`
+	Pages        []IndexedPage `json:"pages"`
+}
+
+// LoadPageIndex reads the page index at path, or returns an empty index if
+// there is none.
+func LoadPageIndex(path string) (*PageIndex, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &PageIndex{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var index PageIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse page index %s: %w", path, err)
+	}
+
+	return &index, nil
+}
+
+// Lookup returns the response of the page with hash, if it was processed
+// with settings.
+func (x *PageIndex) Lookup(hash, settings string) (string, bool) {
+	if x == nil || x.Settings != settings {
+		return "", false
+	}
+	for _, p := range x.Pages {
+		if p.Hash == hash {
+			return p.Response, true
+		}
+	}
+
+	return "", false
+}
+
+// Update returns the index of the revision of the document with source
+// hash sourceHash and page hashes, processed with settings: its pages
+// answered in responses, and the other pages found in x.
+func (x *PageIndex) Update(source, sourceHash, settings string, hashes map[int]string, responses map[int]string) *PageIndex {
+	updated := &PageIndex{Source: source, SourceSHA256: sourceHash, Settings: settings, UpdatedAt: time.Now().UTC()}
+	for pageNum, hash := range hashes {
+		response, ok := responses[pageNum]
+		if !ok {
+			response, ok = x.Lookup(hash, settings)
+		}
+		if ok {
+			updated.Pages = append(updated.Pages, IndexedPage{Page: pageNum, Hash: hash, Response: response})
+		}
+	}
+	slices.SortFunc(updated.Pages, func(a, b IndexedPage) int { return a.Page - b.Page })
+
+	return updated
+}
+
+// Write stores the index at path.
+func (x *PageIndex) Write(path string) error {
+	data, err := json.MarshalIndent(x, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
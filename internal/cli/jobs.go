@@ -0,0 +1,375 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Job states.
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// ErrJobNotFound is returned for unknown job IDs.
+var ErrJobNotFound = errors.New("job not found")
+
+// JobSpec is a document processing request of a client of the serve mode.
+type JobSpec struct {
+	// File is a path below the served directory or a remote URL, ignored if
+	// Data is set.
+	File string `json:"file,omitempty"`
+	// Name is the file name of the uploaded Data.
+	Name      string `json:"name,omitempty"`
+	Data      []byte `json:"data,omitempty"`
+	Prompt    string `json:"prompt"`
+	Pages     string `json:"pages,omitempty"`
+	TextFirst bool   `json:"text_first,omitempty"`
+	Model     string `json:"model,omitempty"`
+	System    string `json:"system,omitempty"`
+	// Options are the model options, merged into the default ones.
+	Options map[string]any `json:"options,omitempty"`
+	// Schedule is the name of the schedule that submitted the job, if any.
+	Schedule string `json:"schedule,omitempty"`
+}
+
+// JobRunner processes a job, emitting its events in order. It stops once
+// emitting an event fails.
+type JobRunner func(ctx context.Context, spec JobSpec, emit func(Event) error) error
+
+// PageResult is the response of a processed page.
+type PageResult struct {
+	Page int    `json:"page"`
+	Text string `json:"text"`
+}
+
+// JobState is a snapshot of a job.
+type JobState struct {
+	ID          string       `json:"id"`
+	Status      string       `json:"status"`
+	Error       string       `json:"error,omitempty"`
+	Pages       int          `json:"pages,omitempty"`
+	Results     []PageResult `json:"results,omitempty"`
+	SubmittedAt time.Time    `json:"submitted_at"`
+	FinishedAt  time.Time    `json:"finished_at,omitzero"`
+}
+
+// Finished reports whether the job is done or failed.
+func (s JobState) Finished() bool {
+	return s.Status == JobDone || s.Status == JobFailed
+}
+
+// Job is a submitted job and the events it produced so far.
+type Job struct {
+	mu      sync.Mutex
+	state   JobState
+	spec    JobSpec
+	events  []Event
+	changed chan struct{} // closed and replaced whenever an event is added
+	save    func(StoredJob)
+}
+
+// State returns a snapshot of the job, with its results in page order.
+func (j *Job) State() JobState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.snapshot()
+}
+
+func (j *Job) snapshot() JobState {
+	state := j.state
+	state.Results = append([]PageResult(nil), j.state.Results...)
+	sort.Slice(state.Results, func(a, b int) bool {
+		return state.Results[a].Page < state.Results[b].Page
+	})
+
+	return state
+}
+
+// Follow calls fn with every event of the job, from the first one, until the
+// job is finished, fn fails or ctx is canceled. Jobs loaded from a store
+// have no events of their earlier runs.
+func (j *Job) Follow(ctx context.Context, fn func(Event) error) error {
+	for next := 0; ; {
+		j.mu.Lock()
+		events := j.events[next:]
+		changed := j.changed
+		finished := j.state.Finished()
+		j.mu.Unlock()
+
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+		next += len(events)
+		if finished {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// run processes the job and records its events.
+func (j *Job) run(ctx context.Context, run JobRunner) {
+	j.mu.Lock()
+	j.state.Status = JobRunning
+	j.persist()
+	spec := j.spec
+	j.mu.Unlock()
+
+	emit := func(event Event) error {
+		j.add(event)
+		return nil
+	}
+	if err := run(ctx, spec, emit); err != nil {
+		j.add(Event{Type: EventError, Error: err.Error()})
+	}
+}
+
+// add records an event of the job and wakes up its followers.
+func (j *Job) add(event Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	event.Job = j.state.ID
+	switch event.Type {
+	case EventStarted:
+		j.state.Pages = event.Pages
+	case EventPageCompleted:
+		j.state.Results = append(j.state.Results, PageResult{Page: event.Page, Text: event.Text})
+	case EventDone:
+		j.state.Status = JobDone
+		j.state.FinishedAt = time.Now().UTC()
+	case EventError:
+		j.state.Status = JobFailed
+		j.state.Error = event.Error
+		j.state.FinishedAt = time.Now().UTC()
+	}
+	j.events = append(j.events, event)
+	if event.Type != EventToken && event.Type != EventPageRendered {
+		j.persist()
+	}
+
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// persist saves the job if it belongs to a store, with the lock held.
+// Uploaded data is dropped once the job is finished.
+func (j *Job) persist() {
+	if j.save == nil {
+		return
+	}
+
+	spec := j.spec
+	if j.state.Finished() {
+		spec.Data = nil
+	}
+	j.save(StoredJob{JobState: j.snapshot(), Spec: spec})
+}
+
+// Jobs runs submitted jobs in the background and keeps them in memory, and
+// in a [JobStore] if set.
+type Jobs struct {
+	run   JobRunner
+	store *JobStore
+	slots chan struct{} // bounds the number of running jobs, if set
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewJobs returns an empty set of jobs processed by run, all at once.
+func NewJobs(run JobRunner) *Jobs {
+	return &Jobs{run: run, jobs: make(map[string]*Job)}
+}
+
+// NewJobPool returns the jobs of store processed by run, at most workers at a
+// time. Jobs the store holds unfinished, e.g. after a crash, are run again.
+func NewJobPool(run JobRunner, workers int, store *JobStore) (*Jobs, error) {
+	s := NewJobs(run)
+	s.store = store
+	s.slots = make(chan struct{}, max(workers, 1))
+
+	stored, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	// Oldest first, so unfinished jobs are run again in submission order.
+	for i := len(stored) - 1; i >= 0; i-- {
+		job := s.newJob(stored[i].ID, stored[i].Spec)
+		job.state.SubmittedAt = stored[i].SubmittedAt
+		s.add(job)
+		if stored[i].Finished() {
+			job.state = stored[i].JobState
+			continue
+		}
+		s.start(job)
+	}
+
+	return s, nil
+}
+
+// Submit starts processing spec and returns its job.
+func (s *Jobs) Submit(spec JobSpec) *Job {
+	job := s.newJob(NewJobID(), spec)
+	s.add(job)
+
+	job.mu.Lock()
+	job.persist()
+	job.mu.Unlock()
+
+	s.start(job)
+
+	return job
+}
+
+// Get returns the job with the given ID.
+func (s *Jobs) Get(id string) (*Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+// List returns the state of all jobs, most recently submitted first.
+func (s *Jobs) List() []JobState {
+	s.mu.Lock()
+	states := make([]JobState, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		states = append(states, job.State())
+	}
+	s.mu.Unlock()
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].SubmittedAt.After(states[j].SubmittedAt)
+	})
+
+	return states
+}
+
+// Counts returns the number of jobs by status.
+func (s *Jobs) Counts() map[string]int {
+	counts := map[string]int{JobQueued: 0, JobRunning: 0, JobDone: 0, JobFailed: 0}
+	for _, state := range s.List() {
+		counts[state.Status]++
+	}
+
+	return counts
+}
+
+// Delete removes a finished job.
+func (s *Jobs) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return fmt.Errorf("job %s: %w", id, ErrJobNotFound)
+	}
+	if !job.State().Finished() {
+		return fmt.Errorf("job %s is not finished", id)
+	}
+
+	if s.store != nil {
+		if err := s.store.Delete(id); err != nil {
+			return err
+		}
+	}
+	delete(s.jobs, id)
+
+	return nil
+}
+
+// Prune removes the jobs finished before t and returns their number.
+func (s *Jobs) Prune(t time.Time) (int, error) {
+	var ids []string
+	for _, state := range s.List() {
+		if state.Finished() && state.FinishedAt.Before(t) {
+			ids = append(ids, state.ID)
+		}
+	}
+
+	for i, id := range ids {
+		if err := s.Delete(id); err != nil {
+			return i, err
+		}
+	}
+
+	return len(ids), nil
+}
+
+func (s *Jobs) newJob(id string, spec JobSpec) *Job {
+	job := newJob(id)
+	job.spec = spec
+	if s.store != nil {
+		job.save = func(stored StoredJob) {
+			if err := s.store.Save(stored); err != nil {
+				println("Failed to save job", stored.ID, ":", err.Error())
+			}
+		}
+	}
+
+	return job
+}
+
+func (s *Jobs) add(job *Job) {
+	s.mu.Lock()
+	s.jobs[job.state.ID] = job
+	s.mu.Unlock()
+}
+
+// start runs the job in the background, once a slot is free.
+func (s *Jobs) start(job *Job) {
+	go func() {
+		if s.slots != nil {
+			s.slots <- struct{}{}
+			defer func() { <-s.slots }()
+		}
+		job.run(context.Background(), s.run)
+	}()
+}
+
+// RunJob processes spec as the job id and returns its final state.
+func RunJob(ctx context.Context, id string, spec JobSpec, run JobRunner) JobState {
+	job := newJob(id)
+	job.spec = spec
+	job.run(ctx, run)
+
+	return job.State()
+}
+
+func newJob(id string) *Job {
+	return &Job{
+		state: JobState{
+			ID:          id,
+			Status:      JobQueued,
+			SubmittedAt: time.Now().UTC(),
+		},
+		changed: make(chan struct{}),
+	}
+}
+
+// NewJobID returns a random job ID.
+func NewJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// validJobID matches the IDs of [NewJobID], so IDs from requests cannot
+// address files outside the store.
+var validJobID = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// StoredJob is a job persisted by a [JobStore].
+type StoredJob struct {
+	JobState
+	Spec JobSpec `json:"spec"`
+}
+
+// JobStore persists jobs as JSON files in a directory, one per job.
+type JobStore struct {
+	dir string
+}
+
+// DefaultJobDir returns the directory of the jobs of the daemon in the user
+// config directory.
+func DefaultJobDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "uniai", "jobs"), nil
+}
+
+// NewJobStore creates the job directory if needed and returns the store.
+func NewJobStore(dir string) (*JobStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create job directory: %w", err)
+	}
+
+	return &JobStore{dir: dir}, nil
+}
+
+func (s *JobStore) path(id string) (string, error) {
+	if !validJobID.MatchString(id) {
+		return "", fmt.Errorf("invalid job ID %q", id)
+	}
+
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// Load returns the job with the given ID. The error wraps os.ErrNotExist if
+// it does not exist.
+func (s *JobStore) Load(id string) (*StoredJob, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var job StoredJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to parse job %s: %w", id, err)
+	}
+
+	return &job, nil
+}
+
+// Save writes a job, replacing its previous state atomically.
+func (s *JobStore) Save(job StoredJob) error {
+	path, err := s.path(job.ID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// List returns all jobs, most recently submitted first.
+func (s *JobStore) List() ([]*StoredJob, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*StoredJob
+	for _, e := range entries {
+		// Other files, such as the schedules, share the directory.
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() || !validJobID.MatchString(id) {
+			continue
+		}
+		job, err := s.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].SubmittedAt.After(jobs[j].SubmittedAt)
+	})
+
+	return jobs, nil
+}
+
+// Delete removes the job with the given ID.
+func (s *JobStore) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
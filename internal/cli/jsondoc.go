@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// JSONDocumentFile is the name of the merged JSON rendition of a document
+// processed with --json.
+const JSONDocumentFile = "document.json"
+
+// JSONPart is the JSON answer to a request of a document.
+type JSONPart struct {
+	Request string          `json:"request"`
+	Pages   []int           `json:"pages"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// JSONDocument is the merged JSON rendition of a document: the answers to
+// its requests, in page order.
+type JSONDocument struct {
+	Source string     `json:"source"`
+	Model  string     `json:"model"`
+	Parts  []JSONPart `json:"parts"`
+}
+
+// MergeJSON returns the JSON document of the checkpoint entries of a run,
+// as returned by [Checkpoint.Entries]. Every response must be a JSON value.
+func MergeJSON(source, model string, entries []CheckpointEntry) ([]byte, error) {
+	doc := JSONDocument{Source: source, Model: model, Parts: make([]JSONPart, 0, len(entries))}
+	for _, entry := range entries {
+		if !json.Valid([]byte(entry.Response)) {
+			return nil, fmt.Errorf("response to %s is not valid JSON", entry.Request)
+		}
+		doc.Parts = append(doc.Parts, JSONPart{Request: entry.Request, Pages: entry.Pages, Data: json.RawMessage(entry.Response)})
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
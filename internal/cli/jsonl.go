@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// ResponsesFile is the name of the JSON lines rendition of the responses of
+// a run, written with --output-format jsonl.
+const ResponsesFile = "responses.jsonl"
+
+// ResponseLine is a line of a [ResponsesFile]: the response to a request
+// with the metadata of the request.
+type ResponseLine struct {
+	Source           string        `json:"source"`
+	Request          string        `json:"request"`
+	Pages            []int         `json:"pages"`
+	Model            string        `json:"model"`
+	Response         string        `json:"response"`
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	Duration         time.Duration `json:"duration"`
+	Cached           bool          `json:"cached,omitempty"`
+}
+
+// WriteResponsesJSONL writes a line to w for every checkpoint entry of the
+// run, as returned by [Checkpoint.Entries], in page order. The metadata of
+// an entry is that of the last successful request of the run with its name
+// answered by model, the model of the run, or by its fallback; requests of
+// compared models are left out.
+func WriteResponsesJSONL(w io.Writer, run *RunInfo, model string, entries []CheckpointEntry) error {
+	requests := make(map[string]RequestInfo, len(run.Requests))
+	for _, req := range run.Requests {
+		if req.Error == "" && (req.Model == "" || req.Model == model || req.Fallback) {
+			requests[req.Name] = req
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	for _, entry := range entries {
+		line := ResponseLine{
+			Source:   run.Source,
+			Request:  entry.Request,
+			Pages:    entry.Pages,
+			Model:    model,
+			Response: entry.Response,
+		}
+		if req, ok := requests[entry.Request]; ok {
+			if req.Model != "" {
+				line.Model = req.Model
+			}
+			line.PromptTokens, line.CompletionTokens = req.PromptTokens, req.CompletionTokens
+			line.Duration, line.Cached = req.Duration, req.Cached
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
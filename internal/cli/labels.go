@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// LabelSet is the configuration of a classifier, as read from a YAML file:
+//
+//	threshold: 0.6
+//	labels:
+//	  - name: invoice
+//	    description: a bill requesting payment
+//	  - name: receipt
+//	examples:
+//	  - text: "Thank you for your purchase ..."
+//	    label: receipt
+type LabelSet struct {
+	Labels    []uniai.Label   `yaml:"labels"`
+	Examples  []uniai.Example `yaml:"examples"`
+	Threshold float64         `yaml:"threshold"`
+}
+
+// LoadLabelSet reads a label set file.
+func LoadLabelSet(path string) (*LabelSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var set LabelSet
+	if err := yaml.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse labels %s: %w", path, err)
+	}
+	for i, l := range set.Labels {
+		if l.Name == "" {
+			return nil, fmt.Errorf("label %d in %s has no name", i+1, path)
+		}
+	}
+
+	return &set, nil
+}
+
+// ParseLabels parses "name" or "name=description" labels.
+func ParseLabels(specs []string) []uniai.Label {
+	labels := make([]uniai.Label, 0, len(specs))
+	for _, spec := range specs {
+		name, desc, _ := strings.Cut(spec, "=")
+		labels = append(labels, uniai.Label{Name: strings.TrimSpace(name), Description: strings.TrimSpace(desc)})
+	}
+
+	return labels
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minLanguageWords is the number of stopwords needed to guess the language of
+// text written in the Latin script.
+const minLanguageWords = 3
+
+// languageScripts are the languages recognized by their script alone.
+var languageScripts = []struct {
+	code   string
+	tables []*unicode.RangeTable
+}{
+	{"ja", []*unicode.RangeTable{unicode.Hiragana, unicode.Katakana}},
+	{"ko", []*unicode.RangeTable{unicode.Hangul}},
+	{"zh", []*unicode.RangeTable{unicode.Han}},
+	{"ru", []*unicode.RangeTable{unicode.Cyrillic}},
+	{"ar", []*unicode.RangeTable{unicode.Arabic}},
+	{"he", []*unicode.RangeTable{unicode.Hebrew}},
+	{"el", []*unicode.RangeTable{unicode.Greek}},
+	{"th", []*unicode.RangeTable{unicode.Thai}},
+}
+
+// languageStopwords are frequent words of languages written in the Latin
+// script.
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "for", "with", "that", "this", "on", "are"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "den", "für", "von", "zu", "auf"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "pour", "dans", "que", "du", "sur"},
+	"es": {"el", "los", "las", "y", "es", "una", "para", "por", "con", "que", "del", "en"},
+	"it": {"il", "di", "che", "è", "per", "una", "con", "non", "della", "sono", "gli", "nel"},
+	"nl": {"de", "het", "een", "en", "van", "is", "niet", "met", "voor", "op", "zijn", "dat"},
+	"pt": {"o", "os", "e", "do", "da", "não", "uma", "para", "com", "que", "dos", "em"},
+	"id": {"yang", "dan", "di", "ini", "itu", "dengan", "untuk", "tidak", "dari", "ke", "pada", "adalah"},
+}
+
+// DetectLanguage guesses the ISO 639-1 code of the language of text from its
+// script or, for the Latin script, its most frequent words. It returns an
+// empty string if the text is too short to tell.
+func DetectLanguage(text string) string {
+	counts := make([]int, len(languageScripts))
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for i, s := range languageScripts {
+			if unicode.In(r, s.tables...) {
+				counts[i]++
+				break
+			}
+		}
+	}
+	// Japanese mixes kana with kanji, so any kana wins over Han.
+	for i, s := range languageScripts {
+		if counts[i] > 0 && (counts[i]*2 >= letters || s.code == "ja" && counts[i]*10 >= letters) {
+			return s.code
+		}
+	}
+
+	scores := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		for code, stopwords := range languageStopwords {
+			for _, w := range stopwords {
+				if w == word {
+					scores[code]++
+					break
+				}
+			}
+		}
+	}
+
+	best, bestScore := "", minLanguageWords-1
+	for code, score := range scores {
+		if score > bestScore || score == bestScore && best != "" && code < best {
+			best, bestScore = code, score
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,263 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"strings"
+)
+
+const (
+	// layoutStep is the pixel stride used when building the ink map.
+	layoutStep = 2
+
+	// minBandGap is the minimum height of a horizontal whitespace gap, as a
+	// fraction of the page height, separating two bands.
+	minBandGap = 0.01
+
+	// minColumnGap is the minimum width of a vertical whitespace gap, as a
+	// fraction of the page width, separating two columns.
+	minColumnGap = 0.025
+
+	// minColumnWidth is the minimum width of a column as a fraction of the
+	// page width; narrower splits are treated as noise (e.g. list bullets).
+	minColumnWidth = 0.15
+
+	// columnTolerance is how far apart, as a fraction of the page width,
+	// column gaps of consecutive bands may be to belong to the same block.
+	columnTolerance = 0.05
+)
+
+// Layout modes of [SegmentLayout] consumers.
+const (
+	LayoutOff     = "off"
+	LayoutHints   = "hints"
+	LayoutRegions = "regions"
+)
+
+// inkMap is a downsampled boolean map of inked pixels.
+type inkMap struct {
+	w, h   int
+	ink    []bool
+	bounds image.Rectangle
+}
+
+func newInkMap(img image.Image) *inkMap {
+	bounds := img.Bounds()
+	m := &inkMap{
+		w:      (bounds.Dx() + layoutStep - 1) / layoutStep,
+		h:      (bounds.Dy() + layoutStep - 1) / layoutStep,
+		bounds: bounds,
+	}
+	m.ink = make([]bool, m.w*m.h)
+	for y := 0; y < m.h; y++ {
+		for x := 0; x < m.w; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x*layoutStep, bounds.Min.Y+y*layoutStep).RGBA()
+			lum := (299*r + 587*g + 114*b) / 1000 >> 8
+			m.ink[y*m.w+x] = lum < inkLuminance
+		}
+	}
+
+	return m
+}
+
+// runs returns the [start, end) ranges where inked is true, merging ranges
+// separated by fewer than minGap cells.
+func runs(inked []bool, minGap int) [][2]int {
+	var out [][2]int
+	start, gap := -1, 0
+	for i, v := range inked {
+		switch {
+		case v && start < 0:
+			start, gap = i, 0
+		case v:
+			gap = 0
+		case start >= 0:
+			gap++
+			if gap >= minGap {
+				out = append(out, [2]int{start, i - gap + 1})
+				start, gap = -1, 0
+			}
+		}
+	}
+	if start >= 0 {
+		out = append(out, [2]int{start, len(inked) - gap})
+	}
+
+	return out
+}
+
+// columns returns the column ranges of the rows [y0, y1).
+func (m *inkMap) columns(y0, y1 int) [][2]int {
+	inked := make([]bool, m.w)
+	for y := y0; y < y1; y++ {
+		for x := 0; x < m.w; x++ {
+			if m.ink[y*m.w+x] {
+				inked[x] = true
+			}
+		}
+	}
+
+	cols := runs(inked, max(1, int(minColumnGap*float64(m.w))))
+	// Merge columns too narrow to be real text columns into their neighbour.
+	minWidth := int(minColumnWidth * float64(m.w))
+	for i := 0; i < len(cols) && len(cols) > 1; {
+		if cols[i][1]-cols[i][0] >= minWidth {
+			i++
+			continue
+		}
+		if i == len(cols)-1 {
+			cols[i-1][1] = cols[i][1]
+		} else {
+			cols[i+1][0] = cols[i][0]
+		}
+		cols = append(cols[:i], cols[i+1:]...)
+	}
+
+	return cols
+}
+
+func sameColumns(a, b [][2]int, tolerance int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 1; i < len(a); i++ {
+		// Compare the gaps between columns, which define the layout.
+		if abs(a[i][0]-b[i][0]) > tolerance || abs(a[i-1][1]-b[i-1][1]) > tolerance {
+			return false
+		}
+	}
+
+	return true
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// SegmentLayout splits a rendered page into text regions in reading order:
+// the page is cut into horizontal bands, consecutive bands sharing the same
+// column structure are grouped into blocks, and each block yields one region
+// per column, left to right. A single-column page yields one region.
+func SegmentLayout(img image.Image) []image.Rectangle {
+	m := newInkMap(img)
+
+	rows := make([]bool, m.h)
+	for y := 0; y < m.h; y++ {
+		for x := 0; x < m.w; x++ {
+			if m.ink[y*m.w+x] {
+				rows[y] = true
+				break
+			}
+		}
+	}
+	bands := runs(rows, max(1, int(minBandGap*float64(m.h))))
+	if len(bands) == 0 {
+		return nil
+	}
+
+	type block struct {
+		y0, y1 int
+		cols   [][2]int
+	}
+	tolerance := int(columnTolerance * float64(m.w))
+	var blocks []block
+	for _, band := range bands {
+		cols := m.columns(band[0], band[1])
+		if n := len(blocks); n > 0 && sameColumns(blocks[n-1].cols, cols, tolerance) {
+			last := &blocks[n-1]
+			last.y1 = band[1]
+			for i := range last.cols {
+				last.cols[i][0] = min(last.cols[i][0], cols[i][0])
+				last.cols[i][1] = max(last.cols[i][1], cols[i][1])
+			}
+			continue
+		}
+		blocks = append(blocks, block{y0: band[0], y1: band[1], cols: cols})
+	}
+
+	var regions []image.Rectangle
+	for _, b := range blocks {
+		for _, c := range b.cols {
+			r := image.Rect(c[0]*layoutStep, b.y0*layoutStep, c[1]*layoutStep, b.y1*layoutStep).Add(m.bounds.Min)
+			regions = append(regions, r.Intersect(m.bounds))
+		}
+	}
+
+	return regions
+}
+
+// LayoutHint describes the regions of a page for the prompt, so the model
+// reads multi-column pages in the right order. An empty string is returned
+// for single-region pages.
+func LayoutHint(bounds image.Rectangle, regions []image.Rectangle) string {
+	if len(regions) < 2 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\nThe page layout has the following text regions, listed in reading order " +
+		"(x, y, width, height as percent of the page from the top-left corner). Read them in this order:\n")
+	for i, r := range regions {
+		fmt.Fprintf(&sb, "%d. %.0f%%, %.0f%%, %.0f%%, %.0f%%\n", i+1,
+			percent(r.Min.X-bounds.Min.X, bounds.Dx()),
+			percent(r.Min.Y-bounds.Min.Y, bounds.Dy()),
+			percent(r.Dx(), bounds.Dx()),
+			percent(r.Dy(), bounds.Dy()))
+	}
+
+	return sb.String()
+}
+
+func percent(v, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(v) / float64(total) * 100
+}
+
+// RegionsPrompt tells the model that the images are the regions of a page in
+// reading order.
+func RegionsPrompt(n int) string {
+	return fmt.Sprintf("\n\nThe %d images are the text regions of a single page, in reading order.", n)
+}
+
+// ApplyLayout analyses an encoded page image and adapts it to the layout
+// mode: [LayoutHints] returns a prompt hint describing the regions and keeps
+// the page image, [LayoutRegions] returns one image per region with a prompt
+// hint. Single-region pages are returned unchanged.
+func ApplyLayout(data []byte, mode string, quality int) (string, [][]byte, error) {
+	if mode == "" || mode == LayoutOff {
+		return "", [][]byte{data}, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to decode page image: %w", err)
+	}
+
+	regions := SegmentLayout(img)
+	if len(regions) < 2 {
+		return "", [][]byte{data}, nil
+	}
+
+	switch mode {
+	case LayoutHints:
+		return LayoutHint(img.Bounds(), regions), [][]byte{data}, nil
+	case LayoutRegions:
+		images := make([][]byte, 0, len(regions))
+		for _, r := range regions {
+			region, err := EncodeJpeg(CropImage(img, r), quality)
+			if err != nil {
+				return "", nil, err
+			}
+			images = append(images, region)
+		}
+		return RegionsPrompt(len(regions)), images, nil
+	default:
+		return "", nil, fmt.Errorf("unknown layout mode %q", mode)
+	}
+}
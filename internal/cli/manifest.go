@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Artifact kinds recorded in a manifest.
+const (
+	ArtifactPageImage      = "page_image"
+	ArtifactEmbeddedImage  = "embedded_image"
+	ArtifactResponse       = "response"
+	ArtifactLocalOCR       = "local_ocr" // text of the local OCR instead of a response
+	ArtifactAnnotatedPdf   = "annotated_pdf"
+	ArtifactSearchablePdf  = "searchable_pdf"
+	ArtifactMarkdown       = "markdown"
+	ArtifactJSONDocument   = "json_document"
+	ArtifactResponsesJSONL = "responses_jsonl"
+	ArtifactComparison     = "comparison"
+	ArtifactCrossCheck     = "cross_check"
+	ArtifactCheckpoint     = "checkpoint"
+	ArtifactDataset        = "dataset"
+	ArtifactPIIReport      = "pii_report"
+)
+
+// ManifestFile is the name of the manifest written after each run.
+const ManifestFile = "manifest.json"
+
+// Artifact is a file produced by a run.
+type Artifact struct {
+	Path       string `json:"path"` // relative to the manifest
+	Kind       string `json:"kind"`
+	SHA256     string `json:"sha256"`
+	Size       int64  `json:"size"`
+	Pages      []int  `json:"pages,omitempty"`       // source pages the artifact was produced from
+	PromptHash string `json:"prompt_hash,omitempty"` // SHA-256 of the request prompt of a response
+	// Model is the model of a response produced by another model than the
+	// one of the manifest, such as a fallback model.
+	Model string `json:"model,omitempty"`
+}
+
+// Manifest lists the artifacts of a run with their checksums, so they can be
+// verified downstream and a run can be resumed. It is safe for concurrent use.
+type Manifest struct {
+	SchemaVersion int        `json:"schema_version"`
+	Source        string     `json:"source"`
+	SourceSHA256  string     `json:"source_sha256"`
+	Model         string     `json:"model"`
+	PromptHash    string     `json:"prompt_hash"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Artifacts     []Artifact `json:"artifacts"`
+
+	dir string
+	mu  sync.Mutex
+}
+
+// NewManifest returns an empty manifest of a run on source, stored in dir.
+func NewManifest(dir, source, sourceHash, model, prompt string) *Manifest {
+	return &Manifest{
+		SchemaVersion: SchemaVersion,
+		Source:        source,
+		SourceSHA256:  sourceHash,
+		Model:         model,
+		PromptHash:    HashBytes([]byte(prompt)),
+		CreatedAt:     time.Now().UTC(),
+		dir:           dir,
+	}
+}
+
+// Add checksums the file at path and records it. A nil manifest ignores the
+// call. Recording a path again replaces its previous entry.
+func (m *Manifest) Add(kind, path string, pages []int, promptHash string) error {
+	if m == nil {
+		return nil
+	}
+
+	sum, err := HashFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to checksum %s: %w", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(m.dir, path)
+	if err != nil {
+		rel = path
+	}
+	a := Artifact{
+		Path:       filepath.ToSlash(rel),
+		Kind:       kind,
+		SHA256:     sum,
+		Size:       info.Size(),
+		Pages:      pages,
+		PromptHash: promptHash,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.Artifacts {
+		if m.Artifacts[i].Path == a.Path {
+			m.Artifacts[i] = a
+			return nil
+		}
+	}
+	m.Artifacts = append(m.Artifacts, a)
+
+	return nil
+}
+
+// SetModel records that the artifact at path was produced by model. A nil
+// manifest, or a path not recorded, ignores the call.
+func (m *Manifest) SetModel(path, model string) {
+	if m == nil {
+		return
+	}
+	rel, err := filepath.Rel(m.dir, path)
+	if err != nil {
+		rel = path
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.Artifacts {
+		if m.Artifacts[i].Path == filepath.ToSlash(rel) {
+			m.Artifacts[i].Model = model
+		}
+	}
+}
+
+// Paths returns the paths, relative to the manifest, of the artifacts of kind
+// produced from page, in the order they were recorded.
+func (m *Manifest) Paths(kind string, page int) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var paths []string
+	for _, a := range m.Artifacts {
+		if a.Kind == kind && slices.Contains(a.Pages, page) {
+			paths = append(paths, a.Path)
+		}
+	}
+
+	return paths
+}
+
+// Write stores the manifest at path, with the artifacts sorted by path.
+func (m *Manifest) Write(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sort.Slice(m.Artifacts, func(i, j int) bool {
+		return m.Artifacts[i].Path < m.Artifacts[j].Path
+	})
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// LoadManifest reads the manifest at path. Artifact paths stay relative to
+// the directory of the manifest.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{dir: filepath.Dir(path)}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+
+	return m, nil
+}
+
+// Dir returns the directory the artifact paths are relative to.
+func (m *Manifest) Dir() string {
+	return m.dir
+}
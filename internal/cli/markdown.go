@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// MarkdownFile is the name of the stitched Markdown rendition of a document.
+const MarkdownFile = "document.md"
+
+// MarkdownPrompt asks the model to reproduce the page as Markdown.
+const MarkdownPrompt = `Reproduce the content of the page as GitHub-flavored Markdown, preserving the layout:
+- use #, ## and ### headings for titles and section headings, following their visual hierarchy
+- keep bulleted and numbered lists as Markdown lists, with nesting
+- reproduce tables as Markdown pipe tables with a header row
+- keep paragraphs in reading order and do not summarize or omit text
+- leave out running headers, footers and page numbers
+Answer with the Markdown only, without commentary or code fences.`
+
+// CleanMarkdown post-processes a Markdown answer: it removes a surrounding
+// code fence, normalizes line endings, adds missing header separators to pipe
+// tables and separates headings, lists and tables from adjacent paragraphs
+// with blank lines.
+func CleanMarkdown(answer string) string {
+	text := uniai.TrimCodeFences(strings.ReplaceAll(answer, "\r\n", "\n"))
+
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	var out []string
+	inFence := false
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if inFence {
+			out = append(out, line)
+			continue
+		}
+
+		kind := markdownBlockKind(line)
+		prevKind := ""
+		if len(out) > 0 {
+			prevKind = markdownBlockKind(out[len(out)-1])
+		}
+		if len(out) > 0 && out[len(out)-1] != "" && kind != prevKind && (kind == "heading" || prevKind == "heading" ||
+			kind == "table" || prevKind == "table" || (kind == "list" && prevKind == "text")) {
+			out = append(out, "")
+		}
+		out = append(out, line)
+
+		// A table header must be followed by a separator row.
+		if kind == "table" && prevKind != "table" && !isTableSeparator(line) {
+			if i+1 >= len(lines) || !isTableSeparator(lines[i+1]) {
+				cells := strings.Count(strings.Trim(strings.TrimSpace(line), "|"), "|") + 1
+				out = append(out, "|"+strings.Repeat(" --- |", cells))
+			}
+		}
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// markdownBlockKind classifies a line as heading, table, list, text or blank.
+func markdownBlockKind(line string) string {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case trimmed == "":
+		return ""
+	case strings.HasPrefix(trimmed, "#"):
+		return "heading"
+	case strings.HasPrefix(trimmed, "|"):
+		return "table"
+	case strings.HasPrefix(trimmed, "- "), strings.HasPrefix(trimmed, "* "), strings.HasPrefix(trimmed, "+ "):
+		return "list"
+	}
+	if i := strings.IndexAny(trimmed, ".)"); i > 0 && i < len(trimmed)-1 && trimmed[i+1] == ' ' && strings.Trim(trimmed[:i], "0123456789") == "" {
+		return "list"
+	}
+
+	return "text"
+}
+
+func isTableSeparator(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "|") && strings.Trim(trimmed, "|-: ") == "" && strings.Contains(trimmed, "-")
+}
+
+// StitchMarkdown joins the cleaned Markdown of every page, in page order,
+// into a single document. Each page starts with an HTML comment naming it, so
+// the source of every part can be traced.
+func StitchMarkdown(pages map[int]string) string {
+	pageNums := make([]int, 0, len(pages))
+	for pageNum := range pages {
+		pageNums = append(pageNums, pageNum)
+	}
+	sort.Ints(pageNums)
+
+	var sb strings.Builder
+	for i, pageNum := range pageNums {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		fmt.Fprintf(&sb, "<!-- page %d -->\n\n", pageNum)
+		sb.WriteString(CleanMarkdown(pages[pageNum]))
+	}
+	sb.WriteString("\n")
+
+	return sb.String()
+}
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Stages of job processing whose latencies are measured.
+const (
+	StageLoad     = "load"     // downloading, converting and rendering a document
+	StageGenerate = "generate" // the response of a page
+	StageJob      = "job"      // a whole job
+)
+
+// LatencyBuckets are the upper bounds, in seconds, of the latency histograms.
+var LatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// Metrics collects stage latencies and finished job counts, and writes them
+// in the Prometheus text format. It is safe for concurrent use; a nil
+// *Metrics ignores observations.
+type Metrics struct {
+	mu       sync.Mutex
+	stages   map[string]*histogram
+	finished map[string]uint64 // by final job status
+}
+
+// NewMetrics returns empty metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{stages: make(map[string]*histogram), finished: make(map[string]uint64)}
+}
+
+// Observe records the latency of a stage.
+func (m *Metrics) Observe(stage string, d time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.stages[stage]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(LatencyBuckets))}
+		m.stages[stage] = h
+	}
+	seconds := d.Seconds()
+	if i, _ := slices.BinarySearch(LatencyBuckets, seconds); i < len(LatencyBuckets) {
+		h.counts[i]++
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// JobFinished counts a job that ended with status.
+func (m *Metrics) JobFinished(status string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	m.finished[status]++
+	m.mu.Unlock()
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+
+	fmt.Fprintln(cw, "# HELP uniai_jobs_finished_total Jobs processed, by final status.")
+	fmt.Fprintln(cw, "# TYPE uniai_jobs_finished_total counter")
+	for _, status := range sortedKeys(m.finished) {
+		fmt.Fprintf(cw, "uniai_jobs_finished_total{status=%q} %d\n", status, m.finished[status])
+	}
+
+	fmt.Fprintln(cw, "# HELP uniai_stage_duration_seconds Latency of the processing stages.")
+	fmt.Fprintln(cw, "# TYPE uniai_stage_duration_seconds histogram")
+	for _, stage := range sortedKeys(m.stages) {
+		h := m.stages[stage]
+		var cumulative uint64
+		for i, le := range LatencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(cw, "uniai_stage_duration_seconds_bucket{stage=%q,le=%q} %d\n", stage, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(cw, "uniai_stage_duration_seconds_bucket{stage=%q,le=\"+Inf\"} %d\n", stage, h.count)
+		fmt.Fprintf(cw, "uniai_stage_duration_seconds_sum{stage=%q} %g\n", stage, h.sum)
+		fmt.Fprintf(cw, "uniai_stage_duration_seconds_count{stage=%q} %d\n", stage, h.count)
+	}
+
+	return cw.n, cw.err
+}
+
+// countingWriter counts the bytes written and keeps the first error.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+
+	return n, err
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// ModelsFile is the name of the model configuration in the user config
+// directory.
+const ModelsFile = "models.yaml"
+
+// Models is the model configuration, such as:
+//
+//	profiles:
+//	  uniai01:7b:
+//	    temperature: 0.1
+//	    num_predict: 2048
+//	aliases:
+//	  fast: uniai01:3b
+//	  accurate: uniai01:34b
+//	auto:
+//	  small: fast
+//	  large: accurate
+type Models struct {
+	// Profiles are the default options of models, merged into the options
+	// of every request for them, see uniai.ModelProfiles.
+	Profiles uniai.ModelProfiles `yaml:"profiles"`
+	// Aliases are task-oriented names of models, resolved before every
+	// request, see uniai.ModelAliases.
+	Aliases uniai.ModelAliases `yaml:"aliases"`
+	// Auto are the models selected by --model auto.
+	Auto *AutoSelect `yaml:"auto"`
+}
+
+// DefaultModelsPath returns the model configuration in the user config
+// directory.
+func DefaultModelsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "uniai", ModelsFile), nil
+}
+
+// LoadModels reads a model configuration. A missing file is not an error: it
+// returns no profiles and no aliases.
+func LoadModels(path string) (*Models, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Models{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var models Models
+	if err := yaml.Unmarshal(data, &models); err != nil {
+		return nil, fmt.Errorf("failed to parse models %s: %w", path, err)
+	}
+	for name, options := range models.Profiles {
+		if len(options) == 0 {
+			return nil, fmt.Errorf("models %s: profile %s has no options", path, name)
+		}
+	}
+	for alias, model := range models.Aliases {
+		if model == "" {
+			return nil, fmt.Errorf("models %s: alias %s has no model", path, alias)
+		}
+		if _, ok := models.Aliases[model]; ok {
+			return nil, fmt.Errorf("models %s: alias %s refers to alias %s", path, alias, model)
+		}
+	}
+	if models.Auto != nil {
+		if err := models.Auto.Validate(); err != nil {
+			return nil, fmt.Errorf("models %s: %w", path, err)
+		}
+	}
+
+	return &models, nil
+}
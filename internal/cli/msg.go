@@ -0,0 +1,339 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Outlook .msg files are OLE compound files (MS-CFB) with one stream per MAPI
+// property (MS-OXMSG). Only the properties needed to show a message are read.
+
+var cfbSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// Special sector numbers of the compound file allocation table.
+const (
+	cfbFreeSect   = 0xFFFFFFFF
+	cfbEndOfChain = 0xFFFFFFFE
+	cfbNoStream   = 0xFFFFFFFF
+)
+
+// Directory entry types.
+const (
+	cfbStorage = 1
+	cfbStream  = 2
+	cfbRoot    = 5
+)
+
+type cfbEntry struct {
+	name               string
+	typ                byte
+	left, right, child uint32
+	start              uint32
+	size               uint64
+}
+
+// cfbFile is a compound file read fully into memory.
+type cfbFile struct {
+	data       []byte
+	sectorSize int
+	cutoff     uint64
+	fat        []uint32
+	miniFat    []uint32
+	entries    []cfbEntry
+	miniStream []byte
+}
+
+func readCFB(data []byte) (*cfbFile, error) {
+	if len(data) < 512 || !bytes.Equal(data[:8], cfbSignature) {
+		return nil, errors.New("not a compound file")
+	}
+
+	le := binary.LittleEndian
+	f := &cfbFile{
+		data:       data,
+		sectorSize: 1 << le.Uint16(data[0x1E:]),
+		cutoff:     uint64(le.Uint32(data[0x38:])),
+	}
+	if f.sectorSize != 512 && f.sectorSize != 4096 {
+		return nil, fmt.Errorf("invalid sector size %d", f.sectorSize)
+	}
+
+	// The FAT sectors are listed in the header and the DIFAT chain.
+	var fatSectors []uint32
+	for i := 0; i < 109; i++ {
+		if s := le.Uint32(data[0x4C+4*i:]); s != cfbFreeSect {
+			fatSectors = append(fatSectors, s)
+		}
+	}
+	difat := le.Uint32(data[0x44:])
+	for n := le.Uint32(data[0x48:]); n > 0 && difat < cfbEndOfChain; n-- {
+		sector, err := f.sector(difat)
+		if err != nil {
+			return nil, err
+		}
+		last := f.sectorSize/4 - 1
+		for i := 0; i < last; i++ {
+			if s := le.Uint32(sector[4*i:]); s != cfbFreeSect {
+				fatSectors = append(fatSectors, s)
+			}
+		}
+		difat = le.Uint32(sector[4*last:])
+	}
+	for _, s := range fatSectors {
+		sector, err := f.sector(s)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < f.sectorSize/4; i++ {
+			f.fat = append(f.fat, le.Uint32(sector[4*i:]))
+		}
+	}
+
+	dir, err := f.read(le.Uint32(data[0x30:]), f.fat, f.sectorSize, f.data, 0, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	for off := 0; off+128 <= len(dir); off += 128 {
+		e := dir[off : off+128]
+		nameLen := int(le.Uint16(e[0x40:]))
+		if nameLen > 64 {
+			nameLen = 64
+		}
+		f.entries = append(f.entries, cfbEntry{
+			name:  decodeUTF16(e[:max(0, nameLen-2)]),
+			typ:   e[0x42],
+			left:  le.Uint32(e[0x44:]),
+			right: le.Uint32(e[0x48:]),
+			child: le.Uint32(e[0x4C:]),
+			start: le.Uint32(e[0x74:]),
+			size:  le.Uint64(e[0x78:]),
+		})
+	}
+	if len(f.entries) == 0 || f.entries[0].typ != cfbRoot {
+		return nil, errors.New("missing root entry")
+	}
+	if f.sectorSize == 512 {
+		// Version 3 files may have garbage in the high bits of the size.
+		for i := range f.entries {
+			f.entries[i].size &= 0xFFFFFFFF
+		}
+	}
+
+	if miniFat, err := f.read(le.Uint32(data[0x3C:]), f.fat, f.sectorSize, f.data, 0, true); err == nil {
+		for i := 0; i+4 <= len(miniFat); i += 4 {
+			f.miniFat = append(f.miniFat, le.Uint32(miniFat[i:]))
+		}
+	}
+	root := f.entries[0]
+	f.miniStream, err = f.read(root.start, f.fat, f.sectorSize, f.data, root.size, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mini stream: %w", err)
+	}
+
+	return f, nil
+}
+
+// sector returns the content of a regular sector.
+func (f *cfbFile) sector(n uint32) ([]byte, error) {
+	off := (int(n) + 1) * f.sectorSize
+	if n >= cfbEndOfChain || off+f.sectorSize > len(f.data) {
+		return nil, fmt.Errorf("sector %d out of range", n)
+	}
+
+	return f.data[off : off+f.sectorSize], nil
+}
+
+// read follows a sector chain in table and returns size bytes, or the whole
+// chain if size is 0. Regular sectors are read when regular is set, mini
+// sectors of the mini stream otherwise.
+func (f *cfbFile) read(start uint32, table []uint32, sectorSize int, data []byte, size uint64, regular bool) ([]byte, error) {
+	var out []byte
+	for n, steps := start, 0; n != cfbEndOfChain && n != cfbFreeSect; steps++ {
+		if steps > len(table) {
+			return nil, errors.New("sector chain loop")
+		}
+
+		var chunk []byte
+		if regular {
+			s, err := f.sector(n)
+			if err != nil {
+				return nil, err
+			}
+			chunk = s
+		} else {
+			off := int(n) * sectorSize
+			if off+sectorSize > len(data) {
+				return nil, fmt.Errorf("mini sector %d out of range", n)
+			}
+			chunk = data[off : off+sectorSize]
+		}
+		out = append(out, chunk...)
+
+		if int(n) >= len(table) {
+			return nil, fmt.Errorf("sector %d missing from allocation table", n)
+		}
+		n = table[n]
+		if size > 0 && uint64(len(out)) >= size {
+			break
+		}
+	}
+	if size > 0 {
+		if uint64(len(out)) < size {
+			return nil, errors.New("stream truncated")
+		}
+		out = out[:size]
+	}
+
+	return out, nil
+}
+
+// stream returns the content of a stream entry.
+func (f *cfbFile) stream(e cfbEntry) ([]byte, error) {
+	if e.size == 0 {
+		return nil, nil
+	}
+	if e.size < f.cutoff {
+		return f.read(e.start, f.miniFat, 64, f.miniStream, e.size, false)
+	}
+
+	return f.read(e.start, f.fat, f.sectorSize, f.data, e.size, true)
+}
+
+// children returns the entry indexes of a storage by name.
+func (f *cfbFile) children(storage int) map[string]int {
+	children := make(map[string]int)
+	seen := make(map[uint32]bool)
+
+	var visit func(id uint32)
+	visit = func(id uint32) {
+		if id == cfbNoStream || int(id) >= len(f.entries) || seen[id] {
+			return
+		}
+		seen[id] = true
+		e := f.entries[id]
+		children[e.name] = int(id)
+		visit(e.left)
+		visit(e.right)
+	}
+	visit(f.entries[storage].child)
+
+	return children
+}
+
+func decodeUTF16(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+
+	return strings.TrimRight(string(utf16.Decode(u)), "\x00")
+}
+
+// MAPI property ids read from messages and attachments.
+const (
+	propSubject        = "0037"
+	propSenderName     = "0C1A"
+	propSenderEmail    = "0C1F"
+	propDisplayTo      = "0E04"
+	propDisplayCc      = "0E03"
+	propBody           = "1000"
+	propHtml           = "1013"
+	propAttachData     = "3701"
+	propAttachFilename = "3704"
+	propAttachLongName = "3707"
+)
+
+// msgProps gives access to the property streams of a storage.
+type msgProps struct {
+	file    *cfbFile
+	streams map[string]int
+}
+
+// bytes returns the raw value of a property of any type.
+func (p msgProps) bytes(id string) ([]byte, string) {
+	for _, typ := range []string{"001F", "001E", "0102"} {
+		if i, ok := p.streams["__substg1.0_"+id+typ]; ok && p.file.entries[i].typ == cfbStream {
+			data, err := p.file.stream(p.file.entries[i])
+			if err == nil {
+				return data, typ
+			}
+		}
+	}
+
+	return nil, ""
+}
+
+// string returns a string property, decoding Unicode values.
+func (p msgProps) string(id string) string {
+	data, typ := p.bytes(id)
+	if typ == "001F" {
+		return decodeUTF16(data)
+	}
+
+	return strings.TrimRight(string(data), "\x00")
+}
+
+// ConvertMsg converts an Outlook .msg message into a document, like
+// ConvertEmail.
+func ConvertMsg(content []byte) (*Document, error) {
+	f, err := readCFB(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse msg file: %w", err)
+	}
+
+	root := f.children(0)
+	props := msgProps{file: f, streams: root}
+
+	from := props.string(propSenderName)
+	if email := props.string(propSenderEmail); email != "" && email != from {
+		from = strings.TrimSpace(from + " <" + email + ">")
+	}
+	header := emailHeader{
+		from:    from,
+		to:      props.string(propDisplayTo),
+		cc:      props.string(propDisplayCc),
+		subject: props.string(propSubject),
+	}
+
+	body := props.string(propBody)
+	if strings.TrimSpace(body) == "" {
+		if data, _ := props.bytes(propHtml); len(data) > 0 {
+			body = HtmlToText(data)
+		}
+	}
+
+	// Attachment storages are numbered, so sorting their names keeps the
+	// order of the message.
+	var storages []string
+	for name, i := range root {
+		if f.entries[i].typ == cfbStorage && strings.HasPrefix(name, "__attach_version1.0_") {
+			storages = append(storages, name)
+		}
+	}
+	sort.Strings(storages)
+
+	var attachments []emailAttachmentPart
+	for _, name := range storages {
+		att := msgProps{file: f, streams: f.children(root[name])}
+		filename := att.string(propAttachLongName)
+		if filename == "" {
+			filename = att.string(propAttachFilename)
+		}
+		if filename == "" {
+			filename = name
+		}
+		data, typ := att.bytes(propAttachData)
+		if typ != "0102" {
+			// Embedded messages are stored as sub-storages and not supported.
+			continue
+		}
+		attachments = append(attachments, emailAttachmentPart{filename, data})
+	}
+
+	return emailDocument(header, body, attachments), nil
+}
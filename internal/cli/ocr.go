@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// Local OCR modes.
+const (
+	LocalOCROff      = "off"      // every request is sent to the API
+	LocalOCRFallback = "fallback" // pages are OCRed locally when the API is unreachable
+	LocalOCROnly     = "only"     // nothing is uploaded, every page is OCRed locally
+)
+
+// EngineTesseract identifies responses produced by the local Tesseract OCR
+// instead of a model.
+const EngineTesseract = "tesseract"
+
+// DefaultOCRLanguage is the Tesseract language of local OCR.
+const DefaultOCRLanguage = "eng"
+
+// LocalOCR extracts the plain text of img with the tesseract tool, which
+// must be available in PATH. lang is a Tesseract language such as "eng" or
+// "deu+fra".
+func LocalOCR(ctx context.Context, img []byte, lang string) (string, error) {
+	tesseract, err := exec.LookPath("tesseract")
+	if err != nil {
+		return "", errors.New("local OCR requires the tesseract tool in PATH")
+	}
+	if lang == "" {
+		lang = DefaultOCRLanguage
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, tesseract, "stdin", "stdout", "-l", lang)
+	cmd.Stdin = bytes.NewReader(img)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract failed: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Unreachable reports whether err is a failure to reach the API, such as a
+// refused connection or an unknown host, rather than an error response.
+func Unreachable(err error) bool {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+
+	return errors.As(err, &opErr) || errors.As(err, &dnsErr)
+}
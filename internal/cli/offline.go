@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// OfflineModel is the model name of the offline provider.
+const OfflineModel = "offline"
+
+// offlineEmbeddingSize is the length of the vectors of the offline provider.
+const offlineEmbeddingSize = 16
+
+//go:embed offline.yaml
+var offlineFixtures []byte
+
+type offlineFile struct {
+	Responses []struct {
+		Match    []string `yaml:"match"`
+		Response string   `yaml:"response"`
+	} `yaml:"responses"`
+	Default string `yaml:"default"`
+}
+
+// OfflineProvider answers every request with canned responses of the bundled
+// fixture set, deterministically and without network, so the CLI can be
+// tried out without credentials.
+type OfflineProvider struct {
+	fixtures offlineFile
+}
+
+var _ uniai.Provider = (*OfflineProvider)(nil)
+
+// NewOfflineProvider returns the provider of --offline.
+func NewOfflineProvider() (*OfflineProvider, error) {
+	p := &OfflineProvider{}
+	if err := yaml.Unmarshal(offlineFixtures, &p.fixtures); err != nil {
+		return nil, fmt.Errorf("invalid offline fixtures: %w", err)
+	}
+
+	return p, nil
+}
+
+// respond returns the canned response to prompt, or a sample of format if it
+// is a JSON schema.
+func (p *OfflineProvider) respond(prompt string, format json.RawMessage) string {
+	if len(format) > 0 {
+		var schema any
+		if json.Unmarshal(format, &schema) == nil {
+			if schema, ok := schema.(map[string]any); ok {
+				data, _ := json.Marshal(SampleJSON(schema))
+				return string(data)
+			}
+		}
+		// "json" requests any JSON value.
+		return "{}"
+	}
+
+	prompt = strings.ToLower(prompt)
+	for _, r := range p.fixtures.Responses {
+		matches := true
+		for _, word := range r.Match {
+			if !strings.Contains(prompt, strings.ToLower(word)) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return r.Response
+		}
+	}
+
+	return p.fixtures.Default
+}
+
+func (p *OfflineProvider) Generate(ctx context.Context, req *uniai.GenerateRequest, fn uniai.GenerateResponseFunc) error {
+	model := req.Model
+	if model == "" {
+		model = OfflineModel
+	}
+
+	return offlineStream(req.Prompt+req.System, p.respond(req.Prompt, req.Format), func(chunk string, done bool, m uniai.Metrics) error {
+		return fn(uniai.GenerateResponse{Model: model, CreatedAt: time.Now().UTC(), Response: chunk, Done: done, Metrics: m})
+	})
+}
+
+func (p *OfflineProvider) Chat(ctx context.Context, req *uniai.ChatRequest, fn uniai.ChatResponseFunc) error {
+	model := req.Model
+	if model == "" {
+		model = OfflineModel
+	}
+
+	var prompt, last string
+	for _, m := range req.Messages {
+		prompt += m.Content
+		if m.Role == "user" {
+			last = m.Content
+		}
+	}
+
+	return offlineStream(prompt, p.respond(last, req.Format), func(chunk string, done bool, m uniai.Metrics) error {
+		return fn(uniai.ChatResponse{
+			Model:     model,
+			CreatedAt: time.Now().UTC(),
+			Message:   uniai.Message{Role: "assistant", Content: chunk},
+			Done:      done,
+			Metrics:   m,
+		})
+	})
+}
+
+func (p *OfflineProvider) Embed(ctx context.Context, req *uniai.EmbedRequest) (*uniai.EmbedResponse, error) {
+	resp := &uniai.EmbedResponse{Model: req.Model}
+	for _, input := range req.Input {
+		sum := sha256.Sum256([]byte(input))
+		vec := make([]float32, offlineEmbeddingSize)
+		for i := range vec {
+			vec[i] = float32(sum[i])/127.5 - 1
+		}
+		resp.Embeddings = append(resp.Embeddings, vec)
+		resp.PromptEvalCount += uniai.EstimateTokens(input)
+	}
+
+	return resp, nil
+}
+
+func (p *OfflineProvider) ListModels(ctx context.Context) ([]uniai.ModelInfo, error) {
+	return []uniai.ModelInfo{{Name: OfflineModel, Model: OfflineModel}}, nil
+}
+
+// offlineStream sends response word by word to fn, with token counts
+// estimated from prompt and response in the last chunk.
+func offlineStream(prompt, response string, fn func(chunk string, done bool, m uniai.Metrics) error) error {
+	chunks := strings.SplitAfter(response, " ")
+	for i, chunk := range chunks {
+		var m uniai.Metrics
+		done := i == len(chunks)-1
+		if done {
+			m = uniai.Metrics{
+				TotalDuration:   time.Millisecond,
+				PromptEvalCount: uniai.EstimateTokens(prompt),
+				EvalCount:       uniai.EstimateTokens(response),
+			}
+		}
+		if err := fn(chunk, done, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SampleJSON returns a value of the JSON schema: the first enum value, or
+// empty values of its type with every property and one item of arrays.
+func SampleJSON(schema map[string]any) any {
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		return enum[0]
+	}
+
+	typ, _ := schema["type"].(string)
+	if types, ok := schema["type"].([]any); ok && len(types) > 0 {
+		typ, _ = types[0].(string)
+	}
+	switch typ {
+	case "object":
+		obj := make(map[string]any)
+		props, _ := schema["properties"].(map[string]any)
+		for name, prop := range props {
+			if prop, ok := prop.(map[string]any); ok {
+				obj[name] = SampleJSON(prop)
+			}
+		}
+		return obj
+	case "array":
+		if items, ok := schema["items"].(map[string]any); ok {
+			return []any{SampleJSON(items)}
+		}
+		return []any{}
+	case "string":
+		return OfflineModel
+	case "number", "integer":
+		return 0
+	case "boolean":
+		return false
+	}
+
+	return nil
+}
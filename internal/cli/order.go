@@ -0,0 +1,40 @@
+package cli
+
+import "sync"
+
+// Reorderer restores the order of results completed out of order. Results
+// are added with their 0-based sequence index and passed to the emit
+// function strictly in index order; early results are buffered until their
+// predecessors arrive. It is safe for concurrent use.
+type Reorderer[T any] struct {
+	mu      sync.Mutex
+	next    int
+	pending map[int]T
+	emit    func(T)
+}
+
+// NewReorderer returns a Reorderer calling emit in index order.
+func NewReorderer[T any](emit func(T)) *Reorderer[T] {
+	return &Reorderer[T]{
+		pending: make(map[int]T),
+		emit:    emit,
+	}
+}
+
+// Add records the result with the given index and emits every result that is
+// now in order.
+func (r *Reorderer[T]) Add(index int, v T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending[index] = v
+	for {
+		v, ok := r.pending[r.next]
+		if !ok {
+			return
+		}
+		delete(r.pending, r.next)
+		r.next++
+		r.emit(v)
+	}
+}
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Output directory layouts.
+const (
+	// OutputFlat writes the artifacts of all documents into the output
+	// directory, prefixed with the document name.
+	OutputFlat = "flat"
+	// OutputPerDoc writes the artifacts of every document into its own
+	// subdirectory.
+	OutputPerDoc = "per-doc"
+	// OutputPerRun writes the artifacts of every run into a timestamped
+	// directory below the document subdirectory.
+	OutputPerRun = "per-run"
+)
+
+// sourcesFile records which document content owns which name in an output
+// directory.
+const sourcesFile = ".uniai-sources.json"
+
+// OutputDir is where the artifacts of a document are written.
+type OutputDir struct {
+	Dir  string // directory of the artifacts
+	Doc  string // directory shared by the runs of the document
+	Name string // document name, unique within the output directory
+	Flat bool   // whether file names are prefixed with the document name
+}
+
+// Path returns the path of the named artifact.
+func (o OutputDir) Path(name string) string {
+	if o.Flat {
+		name = o.Name + "_" + name
+	}
+
+	return filepath.Join(o.Dir, name)
+}
+
+// ValidOutputLayout reports whether layout is a known output layout.
+func ValidOutputLayout(layout string) bool {
+	switch layout {
+	case OutputFlat, OutputPerDoc, OutputPerRun:
+		return true
+	}
+
+	return false
+}
+
+// NewOutputDir resolves and creates the output directory of the document at
+// source with the given content hash. The document name is the file name
+// without extension; if a document with different content already wrote
+// under that name, a hash suffix is added instead of overwriting its
+// artifacts, unless revise is set: the document is then a new revision of
+// the document that wrote under that name, and takes it over.
+func NewOutputDir(root, layout, source, fileHash string, revise bool, now time.Time) (OutputDir, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return OutputDir{}, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	base := filepath.Base(source)
+	name, err := claimName(root, strings.TrimSuffix(base, filepath.Ext(base)), fileHash, revise)
+	if err != nil {
+		return OutputDir{}, err
+	}
+
+	out := OutputDir{Dir: root, Doc: root, Name: name}
+	switch layout {
+	case OutputFlat:
+		out.Flat = true
+	case OutputPerDoc:
+		out.Dir = filepath.Join(root, name)
+		out.Doc = out.Dir
+	case OutputPerRun:
+		out.Doc = filepath.Join(root, name)
+		out.Dir = filepath.Join(out.Doc, now.Format("20060102-150405"))
+	default:
+		return OutputDir{}, fmt.Errorf("unknown output layout %q", layout)
+	}
+
+	if err := os.MkdirAll(out.Dir, 0755); err != nil {
+		return OutputDir{}, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	return out, nil
+}
+
+// claimName returns the name under which the document with fileHash is
+// written in root, recording new claims in the sources file. With revise,
+// the document takes over the name of a document with other content.
+func claimName(root, name, fileHash string, revise bool) (string, error) {
+	path := filepath.Join(root, sourcesFile)
+
+	sources := make(map[string]string)
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &sources); err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	case !os.IsNotExist(err):
+		return "", err
+	}
+
+	if owner, ok := sources[name]; ok && owner != fileHash && !revise {
+		name += "-" + fileHash[:min(8, len(fileHash))]
+		if owner, ok := sources[name]; ok && owner != fileHash {
+			return "", fmt.Errorf("output name %q is already used by another document", name)
+		}
+	}
+	if owner, ok := sources[name]; ok && owner == fileHash {
+		return name, nil
+	}
+
+	sources[name] = fileHash
+	data, err = json.MarshalIndent(sources, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return name, nil
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// minPaceInterval is the interval between requests after the first
+	// rate limit of a run.
+	minPaceInterval = time.Second
+
+	// maxPaceInterval bounds the interval between requests however often
+	// the API rate limits them.
+	maxPaceInterval = time.Minute
+)
+
+// Pacer spaces out the requests of a run once the API rate limited one. Each
+// rate limit doubles the interval between requests, for the rest of the run,
+// and holds every request until the wait the API asked for has passed. A
+// pacer with a rate, see [Pacer.SetRate], also never starts requests faster
+// than it. It is safe for concurrent use; a nil *Pacer never waits.
+type Pacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	floor    time.Duration // interval of the rate, if any
+	next     time.Time     // earliest start of the next request
+}
+
+// NewPacer returns a pacer that does not wait until the first rate limit.
+func NewPacer() *Pacer {
+	return &Pacer{}
+}
+
+// SetRate bounds the requests started per second to rps; 0 removes the
+// bound.
+func (p *Pacer) SetRate(rps float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.floor = 0
+	if rps > 0 {
+		p.floor = time.Duration(float64(time.Second) / rps)
+	}
+	p.interval = max(p.interval, p.floor)
+}
+
+// Wait blocks until the next request may be sent, or ctx is done.
+func (p *Pacer) Wait(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	start := p.next
+	if start.Before(now) {
+		start = now
+	}
+	p.next = start.Add(p.interval)
+	p.mu.Unlock()
+
+	if wait := start.Sub(now); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Throttled slows down the requests after a rate limit: it doubles the
+// interval between requests and holds them for retryAfter, if longer. It
+// returns the new interval.
+func (p *Pacer) Throttled(retryAfter time.Duration) time.Duration {
+	if p == nil {
+		return 0
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.interval = max(min(max(2*p.interval, minPaceInterval), maxPaceInterval), p.floor)
+	if next := time.Now().Add(max(retryAfter, p.interval)); next.After(p.next) {
+		p.next = next
+	}
+
+	return p.interval
+}
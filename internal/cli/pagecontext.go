@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Modes of --page-context.
+const (
+	PageContextOff      = "off"
+	PageContextPrevious = "previous"
+	PageContextSummary  = "summary"
+)
+
+// MaxPageContextChars bounds the characters of the context added to a
+// prompt. A longer previous answer keeps its end, which the next page most
+// likely continues.
+const MaxPageContextChars = 4000
+
+// PageContext carries what the earlier pages of a document established,
+// such as entities and table headers, into the prompts of the later pages:
+// the answer to the previous request, or a rolling summary of all answers so
+// far updated after every request. It is safe for concurrent use; a nil
+// *PageContext adds nothing.
+type PageContext struct {
+	mode string
+
+	mu    sync.Mutex
+	pages []int  // pages of the context
+	text  string // previous answer or summary
+}
+
+// NewPageContext returns the context of mode, or nil for PageContextOff.
+func NewPageContext(mode string) (*PageContext, error) {
+	switch mode {
+	case PageContextOff, "":
+		return nil, nil
+	case PageContextPrevious, PageContextSummary:
+		return &PageContext{mode: mode}, nil
+	}
+
+	return nil, fmt.Errorf("invalid page context: %s", mode)
+}
+
+// Summarizes reports whether the context is a rolling summary, updated with
+// [PageContext.SummaryPrompt].
+func (c *PageContext) Summarizes() bool {
+	return c != nil && c.mode == PageContextSummary
+}
+
+// Prompt returns prompt with the context added after it, if any.
+func (c *PageContext) Prompt(prompt string) string {
+	if c == nil {
+		return prompt
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.text == "" {
+		return prompt
+	}
+
+	if c.mode == PageContextSummary {
+		return fmt.Sprintf("%s\n\nFor context, a summary of the answers to the previous pages %s of the document, to resolve entities, abbreviations and table headers they define:\n\n%s",
+			prompt, FormatPageRange(c.pages), c.text)
+	}
+
+	return fmt.Sprintf("%s\n\nFor context, the answer to the previous page %s of the document, to resolve entities, abbreviations and table headers it defines (do not repeat it):\n\n%s",
+		prompt, FormatPageRange(c.pages), c.text)
+}
+
+// SummaryPrompt returns the prompt asking to update the rolling summary with
+// the answer to pages.
+func (c *PageContext) SummaryPrompt(pages []int, answer string) string {
+	c.mu.Lock()
+	summary := c.text
+	c.mu.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString("Keep a running summary of a document processed page by page. ")
+	sb.WriteString("List the entities, abbreviations, table headers and open items later pages may refer to, in at most 200 words. ")
+	sb.WriteString("Answer only with the updated summary.\n\n")
+	if summary != "" {
+		fmt.Fprintf(&sb, "Summary so far:\n\n%s\n\n", summary)
+	}
+	fmt.Fprintf(&sb, "Answer to page %s:\n\n%s", FormatPageRange(pages), strings.TrimSpace(answer))
+
+	return sb.String()
+}
+
+// Update makes text, the answer to pages or the summary updated with it,
+// the context of the next request.
+func (c *PageContext) Update(pages []int, text string) {
+	if c == nil {
+		return
+	}
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) > MaxPageContextChars {
+		runes := []rune(text)
+		text = "…" + string(runes[len(runes)-MaxPageContextChars:])
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mode == PageContextSummary {
+		c.pages = append(c.pages, pages...)
+	} else {
+		c.pages = append([]int(nil), pages...)
+	}
+	c.text = text
+}
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register the JPEG decoder
+	_ "image/png"  // register the PNG decoder
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// ErrRequestTooLarge is returned when a request exceeds the size limit even
+// with its images downscaled.
+var ErrRequestTooLarge = errors.New("request exceeds the size limit")
+
+// RequestSize returns the size of req serialized as sent to the API.
+func RequestSize(req *uniai.GenerateRequest) (int, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
+// FitRequest re-encodes the images of req so that the serialized request is
+// no larger than limit, and returns its size before and after. Every image
+// gets a share of the bytes left by the rest of the request in proportion to
+// its size, and is re-encoded as JPEG within it, at lower quality first, then
+// downscaled. The error wraps ErrRequestTooLarge when the request cannot fit.
+func FitRequest(req *uniai.GenerateRequest, limit, quality int) (before, after int, err error) {
+	before, err = RequestSize(req)
+	if err != nil || limit <= 0 || before <= limit {
+		return before, before, err
+	}
+
+	encoded := 0
+	for _, img := range req.Images {
+		encoded += base64.StdEncoding.EncodedLen(len(img))
+	}
+	// Images are sent base64 encoded: 4 bytes for every 3.
+	available := (limit - (before - encoded)) / 4 * 3
+	if len(req.Images) == 0 || available <= 0 {
+		return before, before, fmt.Errorf("%w: %d bytes of %d allowed, without images", ErrRequestTooLarge, before-encoded, limit)
+	}
+
+	total := 0
+	for _, img := range req.Images {
+		total += len(img)
+	}
+	images := make([]uniai.ImageData, len(req.Images))
+	for i, data := range req.Images {
+		budget := int(int64(available) * int64(len(data)) / int64(total))
+		if len(data) <= budget {
+			images[i] = data
+			continue
+		}
+
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return before, before, fmt.Errorf("failed to decode image %d: %w", i+1, err)
+		}
+		images[i], err = EncodeJpegBudget(img, budget, quality)
+		if errors.Is(err, ErrBudgetExceeded) {
+			return before, before, fmt.Errorf("%w: image %d does not fit in %d bytes", ErrRequestTooLarge, i+1, budget)
+		}
+		if err != nil {
+			return before, before, fmt.Errorf("failed to encode image %d: %w", i+1, err)
+		}
+	}
+	req.Images = images
+
+	after, err = RequestSize(req)
+	if err != nil {
+		return before, after, err
+	}
+	if after > limit {
+		return before, after, fmt.Errorf("%w: %d bytes of %d allowed", ErrRequestTooLarge, after, limit)
+	}
+
+	return before, after, nil
+}
@@ -0,0 +1,348 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/big"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// PIIMapFile is the name of the mapping of masked values written next to the
+// outputs.
+const PIIMapFile = "pii_map.json"
+
+// PII categories, used in the placeholders of masked values.
+const (
+	PIIEmail      = "EMAIL"
+	PIIPhone      = "PHONE"
+	PIIIBAN       = "IBAN"
+	PIINationalID = "NATIONAL_ID"
+)
+
+// piiPattern detects a category of personal data. valid, if set, rejects
+// matches that only look like it.
+type piiPattern struct {
+	category string
+	re       *regexp.Regexp
+	valid    func(match string) bool
+}
+
+// piiPatterns are applied in order: earlier categories win overlapping
+// matches.
+var piiPatterns = []piiPattern{
+	{category: PIIEmail, re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)},
+	{category: PIIIBAN, re: regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}\b`), valid: validIBAN},
+	// US social security and UK national insurance numbers.
+	{category: PIINationalID, re: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b|\b[A-CEGHJ-PR-TW-Z]{2} ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`)},
+	{category: PIIPhone, re: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{1,4}\)[ .-]?)?\d{2,4}(?:[ .-]\d{2,4}){1,4}\b`), valid: validPhone},
+}
+
+var placeholderPattern = regexp.MustCompile(`\[(?:EMAIL|PHONE|IBAN|NATIONAL_ID)_\d+\]`)
+
+// PIIMap masks the personal data of texts before they are sent, and keeps
+// the mapping of every placeholder to its value so responses can be
+// restored locally. The same value always gets the same placeholder. It is
+// safe for concurrent use.
+type PIIMap struct {
+	mu     sync.Mutex
+	values map[string]string // by placeholder
+	masks  map[string]string // placeholders by value
+	counts map[string]int    // by category
+}
+
+// NewPIIMap returns an empty mapping.
+func NewPIIMap() *PIIMap {
+	return &PIIMap{values: make(map[string]string), masks: make(map[string]string), counts: make(map[string]int)}
+}
+
+// Scrub returns text with its email addresses, phone numbers, IBANs and
+// national IDs replaced by placeholders such as [EMAIL_1].
+func (m *PIIMap) Scrub(text string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, p := range piiPatterns {
+		text = p.re.ReplaceAllStringFunc(text, func(match string) string {
+			if p.valid != nil && !p.valid(match) {
+				return match
+			}
+			if mask, ok := m.masks[match]; ok {
+				return mask
+			}
+			m.counts[p.category]++
+			mask := fmt.Sprintf("[%s_%d]", p.category, m.counts[p.category])
+			m.masks[match], m.values[mask] = mask, match
+			return mask
+		})
+	}
+
+	return text
+}
+
+// Restore returns text with the placeholders of the mapping replaced by
+// their values.
+func (m *PIIMap) Restore(text string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return placeholderPattern.ReplaceAllStringFunc(text, func(mask string) string {
+		if value, ok := m.values[mask]; ok {
+			return value
+		}
+		return mask
+	})
+}
+
+// Len returns the number of masked values.
+func (m *PIIMap) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.values)
+}
+
+// Write stores the mapping at path as JSON, readable by the owner only.
+func (m *PIIMap) Write(path string) error {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(m.values, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// LoadPIIMap reads a mapping written by [PIIMap.Write], so the placeholders
+// of a previous run can be restored.
+func LoadPIIMap(path string) (*PIIMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewPIIMap()
+	if err := json.Unmarshal(data, &m.values); err != nil {
+		return nil, fmt.Errorf("failed to parse PII map %s: %w", path, err)
+	}
+	for mask, value := range m.values {
+		m.masks[value] = mask
+		category := mask[1:strings.LastIndexByte(mask, '_')]
+		var n int
+		fmt.Sscanf(mask[strings.LastIndexByte(mask, '_')+1:], "%d", &n)
+		m.counts[category] = max(m.counts[category], n)
+	}
+
+	return m, nil
+}
+
+// validIBAN checks the ISO 13616 checksum of an IBAN candidate.
+func validIBAN(match string) bool {
+	iban := strings.ReplaceAll(match, " ", "")
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+
+	var digits strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			fmt.Fprintf(&digits, "%d", r-'A'+10)
+		default:
+			return false
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// validPhone rejects numbers too short or too long to be phone numbers, such
+// as dates and amounts.
+func validPhone(match string) bool {
+	n := 0
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+
+	return n >= 9 && n <= 15
+}
+
+// piiRects returns the regions, in pixels of an image of the page rendered
+// width pixels wide, of the personal data of the text layer of the page.
+func piiRects(page *model.PdfPage, width int) ([]image.Rectangle, error) {
+	ex, err := extractor.New(page)
+	if err != nil {
+		return nil, err
+	}
+	pageText, _, _, err := ex.ExtractPageText()
+	if err != nil {
+		return nil, err
+	}
+	box, err := page.GetMediaBox()
+	if err != nil {
+		return nil, err
+	}
+	if box.Width() <= 0 {
+		return nil, nil
+	}
+	scale := float64(width) / box.Width()
+
+	text, marks := pageText.Text(), pageText.Marks()
+	var rects []image.Rectangle
+	for _, p := range piiPatterns {
+		for _, loc := range p.re.FindAllStringIndex(text, -1) {
+			if p.valid != nil && !p.valid(text[loc[0]:loc[1]]) {
+				continue
+			}
+			matched, err := marks.RangeOffset(loc[0], loc[1])
+			if err != nil {
+				continue
+			}
+			bbox, ok := matched.BBox()
+			if !ok {
+				continue
+			}
+			rects = append(rects, image.Rect(
+				int((bbox.Llx-box.Llx)*scale), int((box.Ury-bbox.Ury)*scale),
+				int((bbox.Urx-box.Llx)*scale)+1, int((box.Ury-bbox.Lly)*scale)+1,
+			))
+		}
+	}
+	sort.Slice(rects, func(i, j int) bool { return rects[i].Min.Y < rects[j].Min.Y })
+
+	return rects, nil
+}
+
+// pixelate replaces the regions of img by coarse blocks of their average
+// color, so their text cannot be read back.
+func pixelate(img image.Image, rects []image.Rectangle) image.Image {
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	for _, r := range rects {
+		r = r.Inset(-2).Intersect(dst.Bounds())
+		block := max(8, r.Dy()/2)
+		for y := r.Min.Y; y < r.Max.Y; y += block {
+			for x := r.Min.X; x < r.Max.X; x += block {
+				cell := image.Rect(x, y, x+block, y+block).Intersect(r)
+				draw.Draw(dst, cell, image.NewUniform(averageColor(dst, cell)), image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	return dst
+}
+
+func averageColor(img *image.RGBA, r image.Rectangle) color.Color {
+	var sr, sg, sb, n uint64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			sr, sg, sb, n = sr+uint64(c.R), sg+uint64(c.G), sb+uint64(c.B), n+1
+		}
+	}
+	if n == 0 {
+		return color.White
+	}
+
+	return color.RGBA{R: uint8(sr / n), G: uint8(sg / n), B: uint8(sb / n), A: 255}
+}
+
+// PIIReportFile is the name of the report of the personal data found in the
+// responses, written next to the outputs.
+const PIIReportFile = "pii_report.json"
+
+// PIIFinding locates personal data in a response. The value itself is left
+// out of the report.
+type PIIFinding struct {
+	Category string `json:"category"`
+	Request  string `json:"request"`
+	Pages    []int  `json:"pages"`
+	Line     int    `json:"line"`   // 1-based line in the response
+	Column   int    `json:"column"` // 1-based column, in characters
+}
+
+// PIIReport lists the personal data found in the responses of a run, so
+// compliance reviews know what the outputs contain.
+type PIIReport struct {
+	SchemaVersion int            `json:"schema_version"`
+	Source        string         `json:"source"`
+	Counts        map[string]int `json:"counts"` // by category
+	Findings      []PIIFinding   `json:"findings"`
+}
+
+// NewPIIReport scans the responses of a run on source for personal data.
+func NewPIIReport(source string, responses []CheckpointEntry) *PIIReport {
+	r := &PIIReport{SchemaVersion: SchemaVersion, Source: source, Counts: make(map[string]int), Findings: []PIIFinding{}}
+	for _, response := range responses {
+		for _, f := range ScanPII(response.Response) {
+			f.Request, f.Pages = response.Request, response.Pages
+			r.Findings = append(r.Findings, f)
+			r.Counts[f.Category]++
+		}
+	}
+
+	return r
+}
+
+// Write stores the report at path as indented JSON.
+func (r *PIIReport) Write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ScanPII returns the email addresses, phone numbers, IBANs and national IDs
+// of text, in the order they appear. As in [PIIMap.Scrub], earlier
+// categories win overlapping matches.
+func ScanPII(text string) []PIIFinding {
+	type match struct {
+		category   string
+		start, end int
+	}
+	var matches []match
+	for _, p := range piiPatterns {
+	next:
+		for _, loc := range p.re.FindAllStringIndex(text, -1) {
+			if p.valid != nil && !p.valid(text[loc[0]:loc[1]]) {
+				continue
+			}
+			for _, m := range matches {
+				if loc[0] < m.end && m.start < loc[1] {
+					continue next
+				}
+			}
+			matches = append(matches, match{p.category, loc[0], loc[1]})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+
+	findings := make([]PIIFinding, len(matches))
+	for i, m := range matches {
+		before := text[:m.start]
+		line := strings.Count(before, "\n") + 1
+		column := utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+		findings[i] = PIIFinding{Category: m.category, Line: line, Column: column}
+	}
+
+	return findings
+}
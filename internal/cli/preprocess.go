@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+)
+
+const (
+	// trimPadding is the whitespace, in pixels, TrimMargins keeps around
+	// the inked area of a page.
+	trimPadding = 16
+
+	// trimSampleStep is the pixel stride used when looking for ink.
+	trimSampleStep = 2
+
+	// tallPageRatio is the height to width ratio above which SplitTall
+	// splits a page, e.g. a receipt or a captured web page.
+	tallPageRatio = 2.0
+
+	// tileRatio is the height to width ratio of the tiles of SplitTall,
+	// that of an A4 page.
+	tileRatio = 1.414
+
+	// tileOverlap is the fraction of a tile shared with the next one, so
+	// that a line cut by a tile boundary is whole in one of them.
+	tileOverlap = 0.05
+
+	// maxDPI bounds the resolution of rendered pages, whose images
+	// otherwise take too much memory.
+	maxDPI = 600
+)
+
+// ValidDPI reports whether dpi is a supported render resolution, zero
+// rendering at the fixed width of the settings.
+func ValidDPI(dpi int) bool {
+	return dpi >= 0 && dpi <= maxDPI
+}
+
+// Preprocess applies the image preprocessing of the settings to a rendered
+// page: its whitespace margins are trimmed, then it is converted to
+// grayscale. Images are returned unchanged when neither is set.
+func Preprocess(img image.Image, settings RenderSettings) image.Image {
+	if settings.TrimMargins {
+		img = TrimMargins(img)
+	}
+	if settings.Grayscale {
+		img = Grayscale(img)
+	}
+
+	return img
+}
+
+// PreprocessEncoded applies the image preprocessing of the settings to an
+// encoded image, such as an image attached to an email, and encodes it again
+// in the format and within the byte budget of the settings. Images are
+// returned unchanged when the settings have no preprocessing or budget.
+func PreprocessEncoded(data []byte, settings RenderSettings) ([]byte, error) {
+	if !settings.TrimMargins && !settings.Grayscale && settings.MaxBytes <= 0 {
+		return data, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	return EncodeImage(Preprocess(img, settings), settings)
+}
+
+// TrimMargins crops the whitespace margins of img, keeping trimPadding
+// pixels around its inked area. Blank images are returned unchanged.
+func TrimMargins(img image.Image) image.Image {
+	bounds := img.Bounds()
+	inked := image.Rectangle{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += trimSampleStep {
+		for x := bounds.Min.X; x < bounds.Max.X; x += trimSampleStep {
+			r, g, b, _ := img.At(x, y).RGBA()
+			if lum := (299*r + 587*g + 114*b) / 1000 >> 8; lum < inkLuminance {
+				inked = inked.Union(image.Rect(x, y, x+trimSampleStep, y+trimSampleStep))
+			}
+		}
+	}
+	if inked.Empty() {
+		return img
+	}
+
+	r := inked.Inset(-trimPadding).Intersect(bounds)
+	if r == bounds {
+		return img
+	}
+
+	return CropImage(img, r)
+}
+
+// Grayscale returns img converted to grayscale, which encodes smaller and
+// costs the model nothing on black and white documents.
+func Grayscale(img image.Image) image.Image {
+	if gray, ok := img.(*image.Gray); ok {
+		return gray
+	}
+	bounds := img.Bounds()
+	dst := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+
+	return dst
+}
+
+// SplitTall splits an encoded page image more than tallPageRatio times as
+// tall as it is wide into overlapping tiles, from top to bottom, encoded as
+// JPEG at quality, and returns a prompt hint describing them. Other images
+// are returned unchanged.
+func SplitTall(data []byte, quality int) (string, [][]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to decode page image: %w", err)
+	}
+	if cfg.Width == 0 || float64(cfg.Height)/float64(cfg.Width) <= tallPageRatio {
+		return "", [][]byte{data}, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to decode page image: %w", err)
+	}
+
+	var tiles [][]byte
+	for _, r := range tileRects(img.Bounds()) {
+		tile, err := EncodeJpeg(CropImage(img, r), quality)
+		if err != nil {
+			return "", nil, err
+		}
+		tiles = append(tiles, tile)
+	}
+
+	return TilesPrompt(len(tiles)), tiles, nil
+}
+
+// tileRects returns the overlapping tiles, from top to bottom, covering
+// bounds.
+func tileRects(bounds image.Rectangle) []image.Rectangle {
+	height := int(math.Round(float64(bounds.Dx()) * tileRatio))
+	step := height - int(float64(height)*tileOverlap)
+
+	var rects []image.Rectangle
+	for y := bounds.Min.Y; ; y += step {
+		if y+height >= bounds.Max.Y {
+			// The last tile ends with the page rather than being a sliver.
+			rects = append(rects, image.Rect(bounds.Min.X, max(bounds.Max.Y-height, bounds.Min.Y), bounds.Max.X, bounds.Max.Y))
+			return rects
+		}
+		rects = append(rects, image.Rect(bounds.Min.X, y, bounds.Max.X, y+height))
+	}
+}
+
+// TilesPrompt tells the model that the images are the tiles of a single tall
+// page.
+func TilesPrompt(n int) string {
+	return fmt.Sprintf("\n\nThe %d images are consecutive parts of a single tall page, from top to bottom. Consecutive parts overlap slightly: do not repeat the content they share.", n)
+}
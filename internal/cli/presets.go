@@ -0,0 +1,166 @@
+package cli
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed presets.yaml
+var builtinPresets []byte
+
+// Preset bundles what a document task needs: the prompt, or the template
+// rendering it, an optional JSON schema constraining the answer, model
+// options and default values of command line flags.
+type Preset struct {
+	Description string            `yaml:"description"`
+	Prompt      string            `yaml:"prompt"`   // text/template executed with the variables
+	Template    string            `yaml:"template"` // name of a prompt template, used instead of Prompt
+	Vars        map[string]string `yaml:"vars"`     // default template variables
+	System      string            `yaml:"system"`
+	Schema      map[string]any    `yaml:"schema"`
+	Options     map[string]any    `yaml:"options"`
+	Flags       map[string]string `yaml:"flags"` // flag defaults, overridden by flags set explicitly
+
+	// Source is where the preset was loaded from.
+	Source string `yaml:"-"`
+}
+
+// Format returns the schema as the JSON format of a request, or nil if the
+// preset is nil or has no schema.
+func (p *Preset) Format() (json.RawMessage, error) {
+	if p == nil || len(p.Schema) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(p.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	return data, nil
+}
+
+type presetFile struct {
+	Tasks map[string]*Preset `yaml:"tasks"`
+}
+
+// DefaultPresetFile returns the path of the user presets file.
+func DefaultPresetFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "uniai", "presets.yaml"), nil
+}
+
+// LoadPresets returns the built-in presets and those of the YAML file at
+// path, which override built-ins of the same name. A missing file is not an
+// error.
+func LoadPresets(path string) (map[string]*Preset, error) {
+	presets := make(map[string]*Preset)
+	if err := parsePresets(builtinPresets, "built-in", presets); err != nil {
+		return nil, err
+	}
+
+	if path == "" {
+		return presets, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return presets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := parsePresets(data, path, presets); err != nil {
+		return nil, err
+	}
+
+	return presets, nil
+}
+
+func parsePresets(data []byte, source string, presets map[string]*Preset) error {
+	var f presetFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("failed to parse presets %s: %w", source, err)
+	}
+
+	for name, p := range f.Tasks {
+		if p == nil {
+			continue
+		}
+		if p.Prompt == "" && p.Template == "" {
+			return fmt.Errorf("task %s in %s has neither a prompt nor a template", name, source)
+		}
+		p.Source = source
+		presets[name] = p
+	}
+
+	return nil
+}
+
+// PresetNames returns the sorted names of presets.
+func PresetNames(presets map[string]*Preset) []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// MergeOptions returns base with the values of override added or replaced.
+func MergeOptions(base, override map[string]any) map[string]any {
+	merged := make(map[string]any, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// ContextWindow returns the num_ctx option of options, the context window
+// the model is loaded with, or 0 if it is not set.
+func ContextWindow(options map[string]any) int {
+	switch n := options["num_ctx"].(type) {
+	case int:
+		return n
+	case float64:
+		return int(n)
+	}
+
+	return 0
+}
+
+// ParseOptions parses "key=value" model options, such as temperature=0.2.
+// Values are JSON numbers, booleans, arrays or objects, and strings
+// otherwise.
+func ParseOptions(pairs []string) (map[string]any, error) {
+	options := make(map[string]any, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid option %q, expected key=value", pair)
+		}
+		var v any
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			v = value
+		}
+		options[key] = v
+	}
+
+	return options, nil
+}
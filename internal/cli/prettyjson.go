@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// ANSI colors of highlighted JSON.
+const (
+	ansiBlue   = "\x1b[34m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+)
+
+// ErrInvalidJSON is returned by [JSONWriter.Flush] when the response is not
+// valid JSON; it was printed as is.
+var ErrInvalidJSON = errors.New("response is not valid JSON")
+
+// IsTerminal reports whether f is a terminal rather than a file or a pipe.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// JSONWriter buffers a JSON response written to it, such as a streamed
+// structured output, and prints it re-indented and highlighted for a terminal
+// once complete.
+type JSONWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+// NewJSONWriter returns a writer printing highlighted JSON to w.
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{w: w}
+}
+
+// Write buffers p.
+func (j *JSONWriter) Write(p []byte) (int, error) {
+	return j.buf.Write(p)
+}
+
+// Flush prints the buffered response highlighted, or as is with
+// ErrInvalidJSON if it is not valid JSON. A Markdown code fence around it is
+// ignored.
+func (j *JSONWriter) Flush() error {
+	data := bytes.Clone(j.buf.Bytes())
+	j.buf.Reset()
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	highlighted, err := HighlightJSON(unfenceJSON(data))
+	if err != nil {
+		if _, err := j.w.Write(data); err != nil {
+			return err
+		}
+		return ErrInvalidJSON
+	}
+	_, err = io.WriteString(j.w, highlighted+"\n")
+
+	return err
+}
+
+// unfenceJSON returns data without a surrounding ```json code fence.
+func unfenceJSON(data []byte) []byte {
+	s := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(s, "```") || !strings.HasSuffix(s, "```") || len(s) < 6 {
+		return data
+	}
+	s = strings.TrimSuffix(s, "```")
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+
+	return []byte(s)
+}
+
+// HighlightJSON re-indents the JSON document data with ANSI colors: keys in
+// blue, strings in green, numbers in yellow and literals in magenta. It
+// returns an error if data is not valid JSON.
+func HighlightJSON(data []byte) (string, error) {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, bytes.TrimSpace(data), "", "  "); err != nil {
+		return "", err
+	}
+
+	src := indented.String()
+	var sb strings.Builder
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end++
+			color := ansiGreen
+			if rest := strings.TrimLeft(src[end:], " "); strings.HasPrefix(rest, ":") {
+				color = ansiBlue
+			}
+			sb.WriteString(color + src[i:end] + ansiReset)
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(src) && strings.IndexByte("0123456789.eE+-", src[end]) >= 0 {
+				end++
+			}
+			sb.WriteString(ansiYellow + src[i:end] + ansiReset)
+			i = end
+		case c == 't' || c == 'f' || c == 'n':
+			end := i + 1
+			for end < len(src) && src[end] >= 'a' && src[end] <= 'z' {
+				end++
+			}
+			sb.WriteString(ansiMagenta + src[i:end] + ansiReset)
+			i = end
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+
+	return sb.String(), nil
+}
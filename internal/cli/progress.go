@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"sync"
+	"time"
+)
+
+// Progress counts the pages of a run as they complete and estimates the time
+// left from the pace so far. It is safe for concurrent use; a nil *Progress
+// counts nothing.
+type Progress struct {
+	total int
+	start time.Time
+
+	mu   sync.Mutex
+	done int
+}
+
+// NewProgress returns the progress of a run of total pages, starting now.
+func NewProgress(total int) *Progress {
+	return &Progress{total: total, start: time.Now()}
+}
+
+// Done records n more completed pages, answered or not, and returns the
+// pages completed so far, the total and the estimated time left.
+func (p *Progress) Done(n int) (done, total int, left time.Duration) {
+	if p == nil {
+		return 0, 0, 0
+	}
+
+	p.mu.Lock()
+	p.done += n
+	done = p.done
+	p.mu.Unlock()
+
+	if done > 0 && done < p.total {
+		elapsed := time.Since(p.start)
+		left = elapsed * time.Duration(p.total-done) / time.Duration(done)
+	}
+
+	return done, p.total, left
+}
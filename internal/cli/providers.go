@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProviderUniAI is the type of providers using the UniAI HTTP API.
+const ProviderUniAI = "uniai"
+
+// ProvidersFile is the name of the provider configuration in the user config
+// directory.
+const ProvidersFile = "providers.yaml"
+
+// ProviderConfig is an AI backend. Its base URL and auths may reference
+// environment variables as ${NAME}.
+type ProviderConfig struct {
+	Type    string `yaml:"type"`
+	BaseURL string `yaml:"base_url"`
+	Auth    string `yaml:"auth"`
+	// Keys spread the requests across several credentials instead of Auth,
+	// each with its own rate limit.
+	Keys []KeyConfig `yaml:"keys"`
+	// SigningKey, if set, signs every request with HMAC-SHA256 in the
+	// SignatureHeader, see uniai.Signer.
+	SigningKey      string `yaml:"signing_key"`
+	SignatureHeader string `yaml:"signature_header"`
+}
+
+// KeyConfig is an API credential of a provider and its requests per minute,
+// 0 for no limit.
+type KeyConfig struct {
+	Auth string `yaml:"auth"`
+	RPM  int    `yaml:"rpm"`
+}
+
+// Route input kinds.
+const (
+	InputImage = "image" // rendered page images, e.g. scans to OCR
+	InputText  = "text"  // text extracted from the document
+)
+
+// Route sends the page requests matching all of its set conditions to a
+// provider and model. An empty provider keeps the provider of the task and an
+// empty model keeps the requested one.
+type Route struct {
+	Task     string `yaml:"task"`
+	Input    string `yaml:"input"`
+	MinPages int    `yaml:"min_pages"`
+	MaxPages int    `yaml:"max_pages"`
+	Language string `yaml:"language"` // ISO 639-1 code, see DetectLanguage
+	Provider string `yaml:"provider"`
+	Model    string `yaml:"model"`
+}
+
+// RouteInput describes a page request to route.
+type RouteInput struct {
+	Task     string
+	Input    string // InputImage or InputText
+	Pages    int    // pages of the document processed
+	Language string
+}
+
+// Matches reports whether the request in meets the conditions of the route.
+func (r Route) Matches(in RouteInput) bool {
+	switch {
+	case r.Task != "" && r.Task != in.Task:
+		return false
+	case r.Input != "" && r.Input != in.Input:
+		return false
+	case r.MinPages > 0 && in.Pages < r.MinPages:
+		return false
+	case r.MaxPages > 0 && in.Pages > r.MaxPages:
+		return false
+	case r.Language != "" && r.Language != in.Language:
+		return false
+	}
+
+	return true
+}
+
+// Providers are the configured backends, the provider used for each task
+// (command name) and the routes of page requests, read from a YAML file:
+//
+//	default: local
+//	providers:
+//	  local: {type: uniai}
+//	  eu: {type: uniai, base_url: https://eu.example.com, auth: "${EU_AUTH}"}
+//	  gateway: {type: uniai, base_url: https://gw.example.com, signing_key: "${GW_KEY}"}
+//	  shared:
+//	    type: uniai
+//	    keys:
+//	      - {auth: "${TEAM_A_AUTH}", rpm: 60}
+//	      - {auth: "${TEAM_B_AUTH}", rpm: 30}
+//	tasks:
+//	  classify: eu
+//	routes:
+//	  - {task: uniai, input: image, model: uniai01-vision:11b}
+//	  - {input: text, max_pages: 20, language: en, provider: eu, model: uniai01:1b}
+//
+// Tasks without a provider use the default one. The first matching route
+// picks the provider and model of a page request.
+type Providers struct {
+	Default   string                    `yaml:"default"`
+	Providers map[string]ProviderConfig `yaml:"providers"`
+	Tasks     map[string]string         `yaml:"tasks"`
+	Routes    []Route                   `yaml:"routes"`
+}
+
+// DefaultProvidersPath returns the provider configuration in the user config
+// directory.
+func DefaultProvidersPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "uniai", ProvidersFile), nil
+}
+
+// LoadProviders reads a provider configuration. A missing file is not an
+// error: it returns no providers.
+func LoadProviders(path string) (*Providers, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Providers{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var providers Providers
+	if err := yaml.Unmarshal(data, &providers); err != nil {
+		return nil, fmt.Errorf("failed to parse providers %s: %w", path, err)
+	}
+	for name, p := range providers.Providers {
+		if p.Type == "" {
+			p.Type = ProviderUniAI
+		}
+		p.BaseURL = os.ExpandEnv(p.BaseURL)
+		p.Auth = os.ExpandEnv(p.Auth)
+		p.SigningKey = os.ExpandEnv(p.SigningKey)
+		for i := range p.Keys {
+			p.Keys[i].Auth = os.ExpandEnv(p.Keys[i].Auth)
+		}
+		providers.Providers[name] = p
+	}
+	for i, r := range providers.Routes {
+		if r.Input != "" && r.Input != InputImage && r.Input != InputText {
+			return nil, fmt.Errorf("route %d of %s has an invalid input %q", i+1, path, r.Input)
+		}
+		if r.Provider != "" {
+			if _, ok := providers.Providers[r.Provider]; !ok {
+				return nil, fmt.Errorf("route %d of %s has an unknown provider %q", i+1, path, r.Provider)
+			}
+		}
+	}
+
+	return &providers, nil
+}
+
+// For returns the name and configuration of the provider of a task, or of
+// the named provider if name is set. ok is false if no provider is
+// configured for the task.
+func (p *Providers) For(task, name string) (string, ProviderConfig, bool, error) {
+	if name == "" {
+		name = p.Tasks[task]
+	}
+	if name == "" {
+		name = p.Default
+	}
+	if name == "" {
+		return "", ProviderConfig{}, false, nil
+	}
+
+	config, ok := p.Providers[name]
+	if !ok {
+		return "", ProviderConfig{}, false, fmt.Errorf("unknown provider %q", name)
+	}
+
+	return name, config, true, nil
+}
+
+// Route returns the first route matching in. ok is false if none does.
+func (p *Providers) Route(in RouteInput) (Route, bool) {
+	for _, r := range p.Routes {
+		if r.Matches(in) {
+			return r, true
+		}
+	}
+
+	return Route{}, false
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// ReaderPool hands out PDF readers of a single file to concurrent workers.
+// A PdfReader is not safe for concurrent use, but it can be reused across
+// pages, so a pool of a few readers avoids parsing the document once per page.
+// Readers are opened lazily, up to the pool size.
+type ReaderPool struct {
+	path string
+
+	mu      sync.Mutex
+	free    []*pooledReader
+	all     []*pooledReader
+	size    int
+	waiters *sync.Cond
+}
+
+type pooledReader struct {
+	file   *os.File
+	reader *model.PdfReader
+}
+
+// NewReaderPool returns a pool of at most size readers of the PDF at path.
+func NewReaderPool(path string, size int) *ReaderPool {
+	p := &ReaderPool{
+		path: path,
+		size: max(1, size),
+	}
+	p.waiters = sync.NewCond(&p.mu)
+
+	return p
+}
+
+// Do runs fn with a reader from the pool, opening one if none is free and the
+// pool is not full, or waiting for one to be released otherwise.
+func (p *ReaderPool) Do(fn func(reader *model.PdfReader) error) error {
+	r, err := p.acquire()
+	if err != nil {
+		return err
+	}
+	defer p.release(r)
+
+	return fn(r.reader)
+}
+
+func (p *ReaderPool) acquire() (*pooledReader, error) {
+	p.mu.Lock()
+	for len(p.free) == 0 && len(p.all) >= p.size {
+		p.waiters.Wait()
+	}
+
+	if n := len(p.free); n > 0 {
+		r := p.free[n-1]
+		p.free = p.free[:n-1]
+		p.mu.Unlock()
+		return r, nil
+	}
+
+	// Reserve the slot before opening, so parsing happens outside the lock.
+	r := &pooledReader{}
+	p.all = append(p.all, r)
+	p.mu.Unlock()
+
+	if err := r.open(p.path); err != nil {
+		p.mu.Lock()
+		for i, other := range p.all {
+			if other == r {
+				p.all = append(p.all[:i], p.all[i+1:]...)
+				break
+			}
+		}
+		p.waiters.Signal()
+		p.mu.Unlock()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (p *ReaderPool) release(r *pooledReader) {
+	p.mu.Lock()
+	p.free = append(p.free, r)
+	p.waiters.Signal()
+	p.mu.Unlock()
+}
+
+func (r *pooledReader) open(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	reader, err := model.NewPdfReader(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open PDF file: %w", err)
+	}
+
+	r.file = f
+	r.reader = reader
+	return nil
+}
+
+// Close closes all the files of the pool. It must only be called once no
+// reader is in use anymore.
+func (p *ReaderPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var firstErr error
+	for _, r := range p.all {
+		if r.file != nil {
+			if err := r.file.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	p.all, p.free = nil, nil
+
+	return firstErr
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// benchmarkPDF is a large test document, which takes several times longer
+// to parse than one of its pages to render.
+const benchmarkPDF = "../../testdata/BUDGET-2025-BUD.pdf"
+
+// benchmarkPages is the number of pages of benchmarkPDF rendered per
+// iteration.
+const benchmarkPages = 8
+
+// benchmarkSettings render small images, so that the benchmarks measure the
+// parsing of the document more than the rasterization of its pages.
+var benchmarkSettings = RenderSettings{Width: 200, Quality: 50}
+
+// BenchmarkRenderPages renders the first pages of benchmarkPDF with readers
+// shared through a ReaderPool, as the render stage does, and with a reader
+// opened per page, as it did before.
+func BenchmarkRenderPages(b *testing.B) {
+	pages := min(benchmarkPages, pageCount(b, benchmarkPDF))
+
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("pooled/workers=%d", workers), func(b *testing.B) {
+			for b.Loop() {
+				pool := NewReaderPool(benchmarkPDF, workers)
+				renderAll(b, pages, workers, func(page int) error {
+					return pool.Do(func(reader *model.PdfReader) error {
+						return renderPage(reader, page)
+					})
+				})
+				pool.Close()
+			}
+		})
+
+		b.Run(fmt.Sprintf("per-page/workers=%d", workers), func(b *testing.B) {
+			for b.Loop() {
+				renderAll(b, pages, workers, func(page int) error {
+					f, err := os.Open(benchmarkPDF)
+					if err != nil {
+						return err
+					}
+					defer f.Close()
+
+					reader, err := model.NewPdfReader(f)
+					if err != nil {
+						return err
+					}
+					return renderPage(reader, page)
+				})
+			}
+		})
+	}
+}
+
+// renderAll calls render for pages 1 to pages, from at most workers
+// goroutines.
+func renderAll(b *testing.B, pages, workers int, render func(page int) error) {
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, workers)
+	)
+	for page := 1; page <= pages; page++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := render(page); err != nil {
+				b.Errorf("page %d: %v", page, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func renderPage(reader *model.PdfReader, pageNumber int) error {
+	page, err := reader.GetPage(pageNumber)
+	if err != nil {
+		return err
+	}
+	_, err = RenderPdfPageImage(page, benchmarkSettings)
+
+	return err
+}
+
+func pageCount(tb testing.TB, path string) int {
+	tb.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+
+	reader, err := model.NewPdfReader(f)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	pages, err := reader.GetNumPages()
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return pages
+}
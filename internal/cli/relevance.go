@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"strings"
+)
+
+// DefaultThumbnailSettings are the render settings of the cheap relevance
+// pass.
+var DefaultThumbnailSettings = RenderSettings{
+	Width:   400,
+	Quality: 60,
+}
+
+// EncodeJpeg encodes img as JPEG in memory.
+func EncodeJpeg(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// RelevancePrompt returns the prompt asking the model whether a page
+// thumbnail is relevant to query.
+func RelevancePrompt(query string) string {
+	return fmt.Sprintf("This image is a low-resolution thumbnail of a single document page. "+
+		"Is this page relevant to the following request? Answer only YES or NO.\n\nRequest: %s", query)
+}
+
+// ParseRelevance interprets the model answer to [RelevancePrompt]. Anything
+// that is not a clear "no" is treated as relevant, so uncertain pages still get
+// the full-resolution pass.
+func ParseRelevance(answer string) bool {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	answer = strings.TrimLeft(answer, "*\"'`")
+
+	return !strings.HasPrefix(answer, "no")
+}
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"math/bits"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/unidoc/unipdf/v4/model"
+	"golang.org/x/image/draw"
+)
+
+var update = flag.Bool("update", false, "rewrite the reference renders of TestRenderGolden from the current render stage")
+
+// goldenDir holds goldenFile, the reference renders of TestRenderGolden.
+const (
+	goldenDir  = "../../testdata/golden"
+	goldenFile = "renders.json"
+)
+
+// goldenTolerance is the number of bits the perceptual hash of a render may
+// differ from its reference, absorbing encoder noise.
+const goldenTolerance = 4
+
+// goldenRender is a reference render of a page of a test PDF.
+type goldenRender struct {
+	File   string `json:"file"` // relative to goldenDir
+	Page   int    `json:"page"`
+	Width  int    `json:"width"`
+	Format string `json:"format"`
+	Size   string `json:"size"` // dimensions of the decoded output, as WxH
+	Hash   string `json:"hash"` // perceptual hash of the decoded output
+}
+
+func (r goldenRender) String() string {
+	return fmt.Sprintf("%s/page=%d/width=%d/%s", filepath.Base(r.File), r.Page, r.Width, r.Format)
+}
+
+// TestRenderGolden renders the pages listed in goldenFile and compares the
+// size and perceptual hash of every output with its reference, so that
+// changes of the render stage cannot silently change what is sent to the
+// model. Run it with -update after an intended change to rewrite the
+// references.
+func TestRenderGolden(t *testing.T) {
+	path := filepath.Join(goldenDir, goldenFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set struct {
+		Renders []goldenRender `json:"renders"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		t.Fatalf("failed to parse %s: %v", path, err)
+	}
+
+	_, cwebpErr := exec.LookPath("cwebp")
+	for i, want := range set.Renders {
+		t.Run(want.String(), func(t *testing.T) {
+			if want.Format == FormatWebp && cwebpErr != nil {
+				t.Skip("cwebp is not in PATH")
+			}
+			if want.Hash == "" && !*update {
+				t.Fatal("no reference recorded, run with -update")
+			}
+
+			got, err := renderGolden(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *update {
+				set.Renders[i] = got
+				return
+			}
+
+			if got.Size != want.Size {
+				t.Errorf("size %s, want %s", got.Size, want.Size)
+			}
+			if d := hashDistance(t, want.Hash, got.Hash); d > goldenTolerance {
+				t.Errorf("looks different from the reference: %d bits of the perceptual hash differ, at most %d allowed", d, goldenTolerance)
+			}
+		})
+	}
+
+	if *update {
+		data, err := json.MarshalIndent(set, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// renderGolden renders the page of r as the render stage does, decodes the
+// encoded output and returns r with its size and perceptual hash.
+func renderGolden(r goldenRender) (goldenRender, error) {
+	f, err := os.Open(filepath.Join(goldenDir, r.File))
+	if err != nil {
+		return r, err
+	}
+	defer f.Close()
+
+	reader, err := model.NewPdfReader(f)
+	if err != nil {
+		return r, fmt.Errorf("failed to open PDF file: %w", err)
+	}
+	page, err := reader.GetPage(r.Page)
+	if err != nil {
+		return r, err
+	}
+
+	settings := DefaultRenderSettings
+	settings.Width, settings.Format = r.Width, r.Format
+	img, err := RenderPdfPageImage(page, settings)
+	if err != nil {
+		return r, err
+	}
+	data, err := EncodeImage(img, settings)
+	if err != nil {
+		return r, err
+	}
+	out, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return r, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	r.Size = fmt.Sprintf("%dx%d", out.Bounds().Dx(), out.Bounds().Dy())
+	r.Hash = fmt.Sprintf("%016x", perceptualHash(out))
+
+	return r, nil
+}
+
+// perceptualHash returns the difference hash of img: 64 bits telling, for
+// every cell of a 9x8 grayscale thumbnail, whether it is brighter than its
+// right neighbour. Images that look alike get hashes a few bits apart, even
+// at other sizes or encodings.
+func perceptualHash(img image.Image) uint64 {
+	thumb := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.BiLinear.Scale(thumb, thumb.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if thumb.GrayAt(x, y).Y > thumb.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// hashDistance returns the number of bits the hexadecimal perceptual hashes
+// want and got differ in.
+func hashDistance(t *testing.T, want, got string) int {
+	t.Helper()
+
+	a, err := strconv.ParseUint(want, 16, 64)
+	if err != nil {
+		t.Fatalf("invalid reference hash %q", want)
+	}
+	b, err := strconv.ParseUint(got, 16, 64)
+	if err != nil {
+		t.Fatalf("invalid hash %q", got)
+	}
+
+	return bits.OnesCount64(a ^ b)
+}
@@ -3,38 +3,236 @@ package cli
 import (
 	"errors"
 	"fmt"
-	"image/jpeg"
+	"image"
+	"math"
 	"os"
 
 	"github.com/unidoc/unipdf/v4/model"
 	"github.com/unidoc/unipdf/v4/render"
 )
 
+// RenderSettings controls how pages are rendered and encoded.
+type RenderSettings struct {
+	// Width is the output image width in pixels.
+	Width int
+
+	// Quality is the encoding quality, 1-100.
+	Quality int
+
+	// Format is the output image format, one of FormatJpeg (the default),
+	// FormatWebp or FormatAuto.
+	Format string
+
+	// Crop restricts the output to a region of the page when set.
+	Crop *CropBox
+
+	// MaxBytes is the byte budget of the encoded image. Quality, then size,
+	// is reduced until the image fits. Zero disables the budget.
+	MaxBytes int
+
+	// BlurPII pixelates the personal data found in the text layer of the
+	// page, as masked by PIIMap.
+	BlurPII bool
+
+	// DPI renders the page at this resolution instead of at Width when set.
+	DPI int
+
+	// Grayscale converts the image to grayscale.
+	Grayscale bool
+
+	// TrimMargins crops the whitespace around the content of the page.
+	TrimMargins bool
+}
+
+// DefaultRenderSettings are the settings used when none are specified.
+var DefaultRenderSettings = RenderSettings{
+	Width:   1400,
+	Quality: 90,
+}
+
+// String returns a stable textual form of the settings, suitable for cache
+// keys.
+func (s RenderSettings) String() string {
+	key := fmt.Sprintf("w=%d;q=%d", s.Width, s.Quality)
+	if s.Crop != nil {
+		key += ";crop=" + s.Crop.String()
+	}
+	if s.Format != "" && s.Format != FormatJpeg {
+		key += ";f=" + s.Format
+	}
+	if s.MaxBytes > 0 {
+		key += fmt.Sprintf(";max=%d", s.MaxBytes)
+	}
+	if s.BlurPII {
+		key += ";blur-pii"
+	}
+	if s.DPI > 0 {
+		key += fmt.Sprintf(";dpi=%d", s.DPI)
+	}
+	if s.Grayscale {
+		key += ";gray"
+	}
+	if s.TrimMargins {
+		key += ";trim"
+	}
+
+	return key
+}
+
 func RenderPdfPage(pageNumber int, page *model.PdfPage, outputDir string) (string, error) {
+	img, err := RenderPdfPageImage(page, DefaultRenderSettings)
+	if err != nil {
+		return "", err
+	}
+
+	return SavePageImage(pageNumber, img, OutputDir{Dir: outputDir}, DefaultRenderSettings)
+}
+
+// RenderPdfPageImage renders the page into an in-memory image without writing
+// it to disk, so callers can inspect it before deciding to keep it.
+func RenderPdfPageImage(page *model.PdfPage, settings RenderSettings) (image.Image, error) {
 	if page == nil {
-		return "", errors.New("page is nil")
+		return nil, errors.New("page is nil")
 	}
 
+	width, err := settings.outputWidth(page)
+	if err != nil {
+		return nil, err
+	}
 	device := render.NewImageDevice()
-	device.OutputWidth = 1400
+	device.OutputWidth = width
 
 	img, err := device.Render(page)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if settings.BlurPII {
+		// A page whose personal data cannot be located is not sent.
+		rects, err := piiRects(page, img.Bounds().Dx())
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate personal data: %w", err)
+		}
+		if len(rects) > 0 {
+			img = pixelate(img, rects)
+		}
 	}
+	if settings.Crop != nil {
+		box, err := page.GetMediaBox()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get page size: %w", err)
+		}
 
-	outputFilePath := outputDir + fmt.Sprintf("/page_%d.jpg", pageNumber)
+		r := settings.Crop.Rect(img.Bounds(), box.Width(), box.Height())
+		if r.Empty() {
+			return nil, fmt.Errorf("crop box %s is outside the page", settings.Crop)
+		}
+		img = CropImage(img, r)
+	}
 
-	f, err := os.Create(outputFilePath)
+	return Preprocess(img, settings), nil
+}
+
+// outputWidth returns the width in pixels page is rendered at: Width, or the
+// width of the page at DPI.
+func (s RenderSettings) outputWidth(page *model.PdfPage) (int, error) {
+	if s.DPI <= 0 {
+		return s.Width, nil
+	}
+	width, _, err := pageSize(page)
 	if err != nil {
-		return "", fmt.Errorf("failed to create output file: %w", err)
+		return 0, err
 	}
-	defer f.Close()
 
-	err = jpeg.Encode(f, img, &jpeg.Options{Quality: 90})
+	return int(math.Round(width * float64(s.DPI) / 72)), nil
+}
+
+// pageSize returns the size in points of page as displayed, rotation
+// included.
+func pageSize(page *model.PdfPage) (width, height float64, err error) {
+	box, err := page.GetMediaBox()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get page size: %w", err)
+	}
+	width, height = box.Width(), box.Height()
+	if page.Rotate != nil && *page.Rotate%180 != 0 {
+		width, height = height, width
+	}
+	if width <= 0 || height <= 0 {
+		return 0, 0, errors.New("page is empty")
+	}
+
+	return width, height, nil
+}
+
+// RenderedSize returns the size in pixels of the image RenderPdfPageImage
+// renders of page with settings, without rendering it. Images shrunk to fit
+// the MaxBytes budget of the settings, or with their margins trimmed, end up
+// smaller.
+func RenderedSize(page *model.PdfPage, settings RenderSettings) (width, height int, err error) {
+	pageWidth, pageHeight, err := pageSize(page)
+	if err != nil {
+		return 0, 0, err
+	}
+	box, err := page.GetMediaBox()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get page size: %w", err)
+	}
+
+	if width, err = settings.outputWidth(page); err != nil {
+		return 0, 0, err
+	}
+	height = int(math.Round(float64(width) * pageHeight / pageWidth))
+	if settings.Crop == nil {
+		return width, height, nil
+	}
+
+	r := settings.Crop.Rect(image.Rect(0, 0, width, height), box.Width(), box.Height())
+	if r.Empty() {
+		return 0, 0, fmt.Errorf("crop box %s is outside the page", settings.Crop)
+	}
+
+	return r.Dx(), r.Dy(), nil
+}
+
+// SavePageImage encodes img in the format of the settings and within their
+// byte budget into out, and returns the file path.
+func SavePageImage(pageNumber int, img image.Image, out OutputDir, settings RenderSettings) (string, error) {
+	data, err := EncodeImage(img, settings)
 	if err != nil {
 		return "", fmt.Errorf("failed to encode image: %w", err)
 	}
 
+	return WritePageImage(pageNumber, data, out)
+}
+
+// WritePageImage writes encoded page image data into out and returns the file
+// path. The extension follows the image format.
+func WritePageImage(pageNumber int, data []byte, out OutputDir) (string, error) {
+	outputFilePath := PageImagePath(out, pageNumber, ImageExt(data))
+	if err := os.WriteFile(outputFilePath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to create output file: %w", err)
+	}
+
 	return outputFilePath, nil
 }
+
+// PageImagePath returns the path of the rendered image of a page in out.
+func PageImagePath(out OutputDir, pageNumber int, ext string) string {
+	return out.Path(fmt.Sprintf("page_%d%s", pageNumber, ext))
+}
+
+// LoadPageImage decodes a previously rendered page image.
+func LoadPageImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	return img, nil
+}
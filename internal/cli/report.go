@@ -0,0 +1,129 @@
+package cli
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Report formats.
+const (
+	ReportHTML = "html"
+	ReportPDF  = "pdf"
+)
+
+// ReportThumbnailWidth is the width in pixels of the page thumbnails of a
+// report.
+const ReportThumbnailWidth = 320
+
+// reportThumbnailQuality is the JPEG quality of the thumbnails of a report.
+const reportThumbnailQuality = 70
+
+// ValidReportFormat reports whether format is a known report format.
+func ValidReportFormat(format string) bool {
+	return format == ReportHTML || format == ReportPDF
+}
+
+// ReportFile returns the name of the report in format.
+func ReportFile(format string) string {
+	return "report." + format
+}
+
+//go:embed report.html.tmpl
+var reportHTML string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"thumbnail": reportThumbnail,
+	"duration":  func(d time.Duration) string { return d.Round(time.Millisecond).String() },
+	"pages":     FormatPageRange,
+}).Parse(reportHTML))
+
+// ReportPage is a page of a report: its outcome, image and responses. A
+// response about several pages is shown with its first page.
+type ReportPage struct {
+	*PageSummary
+	Image     string // path of the page image, empty if it was not kept
+	Responses []CheckpointEntry
+}
+
+// Report gathers the outcome of a run for a review by people rather than
+// programs: the run metadata, every page with its image next to its
+// responses, and the failures.
+type Report struct {
+	Summary   *RunSummary
+	Run       *RunInfo
+	Pages     []*ReportPage
+	Generated time.Time
+}
+
+// NewReport builds the report of a run from its summary, its metadata, its
+// responses and the artifacts of its manifest, stored in dir.
+func NewReport(summary *RunSummary, run *RunInfo, responses []CheckpointEntry, manifest *Manifest, dir string) (*Report, error) {
+	r := &Report{Summary: summary, Run: run, Generated: time.Now().UTC()}
+	pages := make(map[int]*ReportPage, len(summary.Pages))
+	for _, p := range summary.Pages {
+		page := &ReportPage{PageSummary: p}
+		pages[p.Page] = page
+		r.Pages = append(r.Pages, page)
+	}
+
+	for _, response := range responses {
+		if page := pages[response.Pages[0]]; page != nil {
+			page.Responses = append(page.Responses, response)
+		}
+	}
+	for _, a := range manifest.Artifacts {
+		if a.Kind != ArtifactPageImage || len(a.Pages) == 0 || pages[a.Pages[0]] == nil {
+			continue
+		}
+		pages[a.Pages[0]].Image = filepath.Join(dir, filepath.FromSlash(a.Path))
+	}
+
+	return r, nil
+}
+
+// Write writes the report to path in format.
+func (r *Report) Write(path, format string) error {
+	if format == ReportPDF {
+		return r.WritePDF(path)
+	}
+
+	return r.WriteHTML(path)
+}
+
+// WriteHTML writes the report to path as a single HTML file, with its
+// styles and page thumbnails inline so it can be shared as is.
+func (r *Report) WriteHTML(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := reportTemplate.Execute(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to render report: %w", err)
+	}
+
+	return f.Close()
+}
+
+// reportThumbnail returns the image at path, scaled down to
+// ReportThumbnailWidth, as a JPEG data URL.
+func reportThumbnail(path string) (template.URL, error) {
+	img, err := LoadPageImage(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	if img.Bounds().Dx() > ReportThumbnailWidth {
+		img = ScaleToWidth(img, ReportThumbnailWidth)
+	}
+	data, err := EncodeJpeg(img, reportThumbnailQuality)
+	if err != nil {
+		return "", err
+	}
+
+	return template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(data)), nil
+}
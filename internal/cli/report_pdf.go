@@ -0,0 +1,295 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/creator"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// Layout of the PDF report.
+const (
+	reportImageWidth   = 1200 // width in pixels the page images are scaled down to
+	reportImageQuality = 80   // JPEG quality of the page images
+	reportFontSize     = 10
+	reportHeadingSize  = 16
+)
+
+var (
+	reportMuted = creator.ColorRGBFrom8bit(101, 109, 118)
+	reportError = creator.ColorRGBFrom8bit(207, 34, 46)
+)
+
+// reportPDF composes a PDF report with the creator of unipdf.
+type reportPDF struct {
+	c          *creator.Creator
+	font, bold *model.PdfFont
+}
+
+// WritePDF writes the report to path as a PDF: a summary page with the run
+// metadata and the failures, then every page image followed by its
+// responses, for archival and distribution by email.
+func (r *Report) WritePDF(path string) error {
+	font, err := model.NewStandard14Font(model.HelveticaName)
+	if err != nil {
+		return err
+	}
+	bold, err := model.NewStandard14Font(model.HelveticaBoldName)
+	if err != nil {
+		return err
+	}
+	pdf := &reportPDF{c: creator.New(), font: font, bold: bold}
+	pdf.c.SetPageSize(creator.PageSizeA4)
+	pdf.c.SetPageMargins(50, 50, 50, 50)
+	pdf.c.DrawFooter(pdf.footer)
+
+	if err := pdf.summary(r); err != nil {
+		return fmt.Errorf("failed to write report summary: %w", err)
+	}
+	for _, page := range r.Pages {
+		if err := pdf.page(page); err != nil {
+			return fmt.Errorf("failed to write report page %d: %w", page.Page, err)
+		}
+	}
+
+	// The report is composed in memory, so a failure leaves no truncated
+	// file behind.
+	var buf bytes.Buffer
+	if err := pdf.c.Write(&buf); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// summary draws the first page: the run metadata and the failures.
+func (p *reportPDF) summary(r *Report) error {
+	if err := p.heading(r.Summary.Source); err != nil {
+		return err
+	}
+	generated := fmt.Sprintf("Generated %s by uniai %s", r.Generated.Format("2006-01-02 15:04:05 MST"), r.Run.ClientVersion)
+	if err := p.text(generated, p.font, reportMuted); err != nil {
+		return err
+	}
+
+	rows := [][]string{
+		{"Status", r.Summary.Status},
+		{"Model", r.Run.Model},
+		{"Prompt", r.Run.Prompt},
+		{"Started", r.Run.StartedAt.Format("2006-01-02 15:04:05 MST")},
+		{"Duration", r.Run.Duration.Round(time.Millisecond).String()},
+		{"Pages", fmt.Sprint(len(r.Pages))},
+		{"Tokens", fmt.Sprintf("%d prompt, %d completion", r.Summary.PromptTokens, r.Summary.CompletionTokens)},
+	}
+	if r.Run.ServerVersion != "" {
+		rows = append(rows, []string{"Server version", r.Run.ServerVersion})
+	}
+	if r.Summary.StopReason != "" {
+		rows = append(rows, []string{"Stopped", r.Summary.StopReason})
+	}
+	if r.Summary.Error != "" {
+		rows = append(rows, []string{"Error", r.Summary.Error})
+	}
+	if err := p.table([]float64{0.25, 0.75}, nil, rows); err != nil {
+		return err
+	}
+
+	if len(r.Summary.Failures) > 0 {
+		if err := p.subheading("Failures"); err != nil {
+			return err
+		}
+		failures := make([][]string, len(r.Summary.Failures))
+		for i, e := range r.Summary.Failures {
+			failures[i] = []string{fmt.Sprint(e.Page), e.Stage, fmt.Sprint(e.Err)}
+		}
+		if err := p.table([]float64{0.1, 0.15, 0.75}, []string{"Page", "Stage", "Error"}, failures); err != nil {
+			return err
+		}
+	}
+
+	if len(r.Pages) > 0 {
+		if err := p.subheading("Pages"); err != nil {
+			return err
+		}
+		pages := make([][]string, len(r.Pages))
+		for i, page := range r.Pages {
+			note := page.Reason
+			if page.Error != "" {
+				note = page.Error
+			}
+			pages[i] = []string{fmt.Sprint(page.Page), page.Status, page.Duration.Round(time.Millisecond).String(),
+				fmt.Sprintf("%d + %d", page.PromptTokens, page.CompletionTokens), note}
+		}
+		return p.table([]float64{0.08, 0.12, 0.12, 0.13, 0.55}, []string{"Page", "Status", "Duration", "Tokens", "Note"}, pages)
+	}
+
+	return nil
+}
+
+// page draws a page of the document on a new page of the report, its image
+// scaled to the width of the page, then its responses.
+func (p *reportPDF) page(page *ReportPage) error {
+	p.c.NewPage()
+	if err := p.heading(fmt.Sprintf("Page %d (%s)", page.Page, page.Status)); err != nil {
+		return err
+	}
+	info := fmt.Sprintf("%s, %d prompt + %d completion tokens", page.Duration.Round(time.Millisecond), page.PromptTokens, page.CompletionTokens)
+	if err := p.text(info, p.font, reportMuted); err != nil {
+		return err
+	}
+	if page.Reason != "" {
+		if err := p.text("Skipped: "+page.Reason, p.font, reportMuted); err != nil {
+			return err
+		}
+	}
+	if page.Error != "" {
+		if err := p.text(page.Error, p.font, reportError); err != nil {
+			return err
+		}
+	}
+
+	if page.Image != "" {
+		if err := p.image(page.Image); err != nil {
+			return err
+		}
+	}
+
+	for _, response := range page.Responses {
+		title := response.Request
+		if len(response.Pages) > 1 {
+			title += " (pages " + FormatPageRange(response.Pages) + ")"
+		}
+		if err := p.text(title, p.bold, reportMuted); err != nil {
+			return err
+		}
+		if err := p.text(strings.TrimSpace(response.Response), p.font, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// image draws the image at path, as a JPEG scaled down to reportImageWidth,
+// fitting the rest of the page.
+func (p *reportPDF) image(path string) error {
+	goImg, err := LoadPageImage(path)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	if goImg.Bounds().Dx() > reportImageWidth {
+		goImg = ScaleToWidth(goImg, reportImageWidth)
+	}
+	img, err := p.c.NewImageFromGoImage(goImg)
+	if err != nil {
+		return err
+	}
+	encoder := core.NewDCTEncoder()
+	encoder.Quality = reportImageQuality
+	encoder.Width, encoder.Height = int(img.Width()), int(img.Height())
+	img.SetEncoder(encoder)
+
+	ctx := p.c.Context()
+	width := ctx.Width - ctx.Margins.Left - ctx.Margins.Right
+	img.ScaleToWidth(width)
+	// Leave room below the image for the start of the responses.
+	if maxHeight := ctx.Height - ctx.Y - ctx.Margins.Bottom - 60; img.Height() > maxHeight && maxHeight > 0 {
+		img.ScaleToHeight(maxHeight)
+	}
+	img.SetHorizontalAlignment(creator.HorizontalAlignmentCenter)
+	img.SetMargins(0, 0, 8, 12)
+
+	return p.c.Draw(img)
+}
+
+func (p *reportPDF) heading(text string) error {
+	para := p.c.NewParagraph(text)
+	para.SetFont(p.bold)
+	para.SetFontSize(reportHeadingSize)
+	para.SetMargins(0, 0, 0, 4)
+
+	return p.c.Draw(para)
+}
+
+func (p *reportPDF) subheading(text string) error {
+	para := p.c.NewParagraph(text)
+	para.SetFont(p.bold)
+	para.SetFontSize(reportHeadingSize - 4)
+	para.SetMargins(0, 0, 16, 6)
+
+	return p.c.Draw(para)
+}
+
+// text draws a wrapped paragraph in font, and in color if not nil.
+func (p *reportPDF) text(text string, font *model.PdfFont, color creator.Color) error {
+	para := p.c.NewParagraph(text)
+	para.SetFont(font)
+	para.SetFontSize(reportFontSize)
+	para.SetLineHeight(1.2)
+	para.SetMargins(0, 0, 0, 6)
+	if color != nil {
+		para.SetColor(color)
+	}
+
+	return p.c.Draw(para)
+}
+
+// table draws rows, with a bold header if not nil, in columns of relative
+// widths.
+func (p *reportPDF) table(widths []float64, header []string, rows [][]string) error {
+	table := p.c.NewTable(len(widths))
+	if err := table.SetColumnWidths(widths...); err != nil {
+		return err
+	}
+	table.SetMargins(0, 0, 8, 0)
+	table.EnableRowWrap(true)
+
+	cell := func(text string, font *model.PdfFont) error {
+		para := p.c.NewParagraph(text)
+		para.SetFont(font)
+		para.SetFontSize(reportFontSize - 1)
+		c := table.NewCell()
+		c.SetBorder(creator.CellBorderSideBottom, creator.CellBorderStyleSingle, 0.5)
+		c.SetBorderColor(creator.ColorRGBFrom8bit(208, 215, 222))
+		c.SetIndent(4)
+		return c.SetContent(para)
+	}
+	if header != nil {
+		for _, text := range header {
+			if err := cell(text, p.bold); err != nil {
+				return err
+			}
+		}
+		if err := table.SetHeaderRows(1, 1); err != nil {
+			return err
+		}
+	}
+	for _, row := range rows {
+		for i, text := range row {
+			font := p.font
+			if i == 0 && header == nil {
+				font = p.bold
+			}
+			if err := cell(text, font); err != nil {
+				return err
+			}
+		}
+	}
+
+	return p.c.Draw(table)
+}
+
+// footer numbers the pages of the report.
+func (p *reportPDF) footer(block *creator.Block, args creator.FooterFunctionArgs) {
+	para := p.c.NewParagraph(fmt.Sprintf("%d / %d", args.PageNum, args.TotalPages))
+	para.SetFont(p.font)
+	para.SetFontSize(reportFontSize - 2)
+	para.SetColor(reportMuted)
+	para.SetPos(block.Width()/2-10, 20)
+	block.Draw(para)
+}
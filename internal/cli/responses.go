@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// ResponseCache stores the responses of generate requests, keyed by
+// everything that determines them: the model, the prompts, the format, the
+// options and the content of the images. Re-running unchanged documents with
+// the same settings then costs no requests.
+type ResponseCache struct {
+	dir string
+}
+
+// DefaultResponseCacheDir returns the default location of the response cache
+// inside the user cache directory.
+func DefaultResponseCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "uniai", "responses"), nil
+}
+
+// NewResponseCache creates the cache directory if needed and returns the
+// cache.
+func NewResponseCache(dir string) (*ResponseCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create response cache directory: %w", err)
+	}
+
+	return &ResponseCache{dir: dir}, nil
+}
+
+// Key returns the cache key of a request. Page text is part of the prompt,
+// so the key covers the content of the page whether it is sent as text or as
+// images.
+func (c *ResponseCache) Key(req *uniai.GenerateRequest) string {
+	h := sha256.New()
+	// Options are a map, which encoding/json writes with sorted keys.
+	options, _ := json.Marshal(req.Options)
+	fmt.Fprintf(h, "%q|%q|%q|%s|%s", req.Model, req.System, req.Prompt, req.Format, options)
+	for _, img := range req.Images {
+		fmt.Fprintf(h, "|%s", HashBytes(img))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".txt")
+}
+
+// Get returns the cached response of key, if present. A nil cache has no
+// entries.
+func (c *ResponseCache) Get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return "", false
+	}
+
+	return string(data), true
+}
+
+// Put stores the response of key. A nil cache ignores the call.
+func (c *ResponseCache) Put(key, response string) error {
+	if c == nil {
+		return nil
+	}
+
+	dst := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	// Each writer has a temporary file of its own, as runs sending the same
+	// request may store its response at the same time.
+	tmp, err := createTemp(dst)
+	if err != nil {
+		return err
+	}
+	_, err = tmp.WriteString(response)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes the cached response of key, if present. A nil cache ignores
+// the call.
+func (c *ResponseCache) Delete(key string) error {
+	if c == nil {
+		return nil
+	}
+
+	if err := os.Remove(c.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
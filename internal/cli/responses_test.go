@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// TestResponseCacheConcurrentPut checks that writers of the same response
+// leave a whole response of one of them and no temporary file.
+func TestResponseCacheConcurrentPut(t *testing.T) {
+	cache, err := NewResponseCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := cache.Key(&uniai.GenerateRequest{Model: uniai.ModelDefault, Prompt: "page"})
+
+	const writers = 8
+	responses := make([]string, writers)
+	var wg sync.WaitGroup
+	for i := range writers {
+		responses[i] = strings.Repeat(string(rune('a'+i)), 256<<10)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				if err := cache.Put(key, responses[i]); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, ok := cache.Get(key)
+	if !ok {
+		t.Fatal("no entry")
+	}
+	if !slices.Contains(responses, got) {
+		t.Errorf("entry of %d bytes is not the response of a single writer", len(got))
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(cache.path(key)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("%d files in the entry directory, want only the entry", len(entries))
+	}
+}
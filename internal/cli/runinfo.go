@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RunInfoFile is the name of the run metadata written next to the outputs.
+const RunInfoFile = "run.json"
+
+// RequestInfo is the metadata of a single request of a run.
+type RequestInfo struct {
+	Name             string        `json:"name"`
+	Pages            []int         `json:"pages,omitempty"`
+	Model            string        `json:"model,omitempty"`
+	PromptHash       string        `json:"prompt_hash"`
+	StartedAt        time.Time     `json:"started_at"`
+	Duration         time.Duration `json:"duration"`
+	PromptTokens     int           `json:"prompt_tokens,omitempty"`
+	CompletionTokens int           `json:"completion_tokens,omitempty"`
+	Error            string        `json:"error,omitempty"`
+	// Cached is set when the response of a previous run was reused.
+	Cached bool `json:"cached,omitempty"`
+	// Engine is EngineTesseract when the text was extracted by the local
+	// OCR instead of a model.
+	Engine string `json:"engine,omitempty"`
+	// Retries counts the attempts of the request the API rate limited.
+	Retries int `json:"retries,omitempty"`
+	// Fallback is set when the request was answered by the fallback model,
+	// in Model, because the primary model was overloaded.
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// RenderInfo is the preparation of a page of a run: its rendering, or the
+// extraction of its text layer.
+type RenderInfo struct {
+	Page     int           `json:"page"`
+	Duration time.Duration `json:"duration"`
+}
+
+// RunInfo describes how the outputs of a run were produced: the request
+// settings, the client and server versions and the timing of every request,
+// so results can be reproduced and audited later. It is safe for concurrent
+// use.
+type RunInfo struct {
+	SchemaVersion int             `json:"schema_version"`
+	Source        string          `json:"source"`
+	Args          []string        `json:"args"`
+	Model         string          `json:"model"`
+	System        string          `json:"system,omitempty"`
+	Prompt        string          `json:"prompt"`
+	Format        json.RawMessage `json:"format,omitempty"`
+	Options       map[string]any  `json:"options,omitempty"`
+	ClientVersion string          `json:"client_version"`
+	ServerVersion string          `json:"server_version,omitempty"`
+	GoVersion     string          `json:"go_version"`
+	StartedAt     time.Time       `json:"started_at"`
+	FinishedAt    time.Time       `json:"finished_at"`
+	Duration      time.Duration   `json:"duration"`
+	Requests      []RequestInfo   `json:"requests"`
+	Renders       []RenderInfo    `json:"renders,omitempty"`
+	// Unprocessed lists the pages not sent because the run was stopped, for
+	// instance by its budget, with the reason in StopReason.
+	Unprocessed []int  `json:"unprocessed_pages,omitempty"`
+	StopReason  string `json:"stop_reason,omitempty"`
+	// Failures are the pages that failed, with their stage and error.
+	Failures []*PageError `json:"failures,omitempty"`
+	// ModelSelection is why the model was picked, if it was selected
+	// automatically.
+	ModelSelection *ModelSelection `json:"model_selection,omitempty"`
+
+	mu sync.Mutex
+}
+
+// NewRunInfo returns the metadata of a run starting now.
+func NewRunInfo(source, model, system, prompt string, format json.RawMessage, options map[string]any) *RunInfo {
+	return &RunInfo{
+		SchemaVersion: SchemaVersion,
+		Source:        source,
+		Args:          os.Args[1:],
+		Model:         model,
+		System:        system,
+		Prompt:        prompt,
+		Format:        format,
+		Options:       options,
+		ClientVersion: ClientVersion(),
+		GoVersion:     runtime.Version(),
+		StartedAt:     time.Now().UTC(),
+	}
+}
+
+// Add records a request. A nil run ignores the call.
+func (r *RunInfo) Add(req RequestInfo) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Requests = append(r.Requests, req)
+}
+
+// Rendered records the preparation of a page. A nil run ignores the call.
+func (r *RunInfo) Rendered(page int, d time.Duration) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Renders = append(r.Renders, RenderInfo{Page: page, Duration: d})
+}
+
+// Stop records pages left unprocessed for reason. A nil run ignores the
+// call.
+func (r *RunInfo) Stop(reason string, pages []int) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.StopReason = reason
+	r.Unprocessed = append(r.Unprocessed, pages...)
+}
+
+// Fail records the failed pages of the run. A nil run ignores the call.
+func (r *RunInfo) Fail(errs []*PageError) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Failures = errs
+}
+
+// Write stores the metadata at path, with the run finishing now and the
+// requests in start order.
+func (r *RunInfo) Write(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.FinishedAt = time.Now().UTC()
+	r.Duration = r.FinishedAt.Sub(r.StartedAt)
+	sort.SliceStable(r.Requests, func(i, j int) bool {
+		return r.Requests[i].StartedAt.Before(r.Requests[j].StartedAt)
+	})
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ClientVersion returns the module version of the binary, or the VCS
+// revision it was built from for development builds.
+func ClientVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	version := info.Main.Version
+	if version != "" && version != "(devel)" {
+		return version
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return "devel+" + s.Value
+		}
+	}
+
+	return "devel"
+}
+
+// LoadRunInfo reads the run metadata at path.
+func LoadRunInfo(path string) (*RunInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r RunInfo
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse run metadata %s: %w", path, err)
+	}
+
+	return &r, nil
+}
@@ -0,0 +1,365 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SchedulesFile is the name of the schedules of the daemon in its job
+// directory.
+const SchedulesFile = "schedules.json"
+
+// maxScheduleRuns is the number of runs kept in the history of a schedule.
+const maxScheduleRuns = 20
+
+var (
+	// ErrScheduleNotFound is returned for unknown schedule names.
+	ErrScheduleNotFound = errors.New("schedule not found")
+	// ErrScheduleExists is returned when adding a schedule under a name in
+	// use.
+	ErrScheduleExists = errors.New("schedule already exists")
+)
+
+// Schedule submits a job for every file of a directory at the times of a
+// cron expression, such as every night at 2am with a task preset.
+type Schedule struct {
+	Name string `json:"name"`
+	Cron string `json:"cron"` // see ParseCron
+	// Dir is the directory whose files are processed, and Pattern the
+	// glob their names match, all files if empty.
+	Dir     string `json:"dir"`
+	Pattern string `json:"pattern,omitempty"`
+	// NewOnly skips the files not modified since the previous run.
+	NewOnly bool `json:"new_only,omitempty"`
+	// Task is the task preset of the jobs. Its prompt is rendered with the
+	// prompt of Job as the 'prompt' variable, and its system prompt and
+	// options apply unless Job sets them.
+	Task string `json:"task,omitempty"`
+	// Job is the template of the jobs; its file is set for every file.
+	Job       JobSpec       `json:"job"`
+	CreatedAt time.Time     `json:"created_at"`
+	Runs      []ScheduleRun `json:"runs,omitempty"` // most recent last
+}
+
+// ScheduleRun is a run of a schedule: the jobs it submitted, or why it was
+// skipped.
+type ScheduleRun struct {
+	StartedAt time.Time `json:"started_at"`
+	Jobs      []string  `json:"jobs,omitempty"`
+	Skipped   string    `json:"skipped,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// lastRun returns the time of the last run of s that submitted jobs, or the
+// zero time.
+func (s *Schedule) lastRun() time.Time {
+	for i := len(s.Runs) - 1; i >= 0; i-- {
+		if s.Runs[i].Skipped == "" && s.Runs[i].Error == "" {
+			return s.Runs[i].StartedAt
+		}
+	}
+
+	return time.Time{}
+}
+
+// ScheduleExpander returns the jobs of a run of a schedule, given the time of
+// its previous run, zero if none.
+type ScheduleExpander func(s Schedule, since time.Time) ([]JobSpec, error)
+
+// Scheduler submits the jobs of schedules to a job pool when they are due.
+// A schedule is skipped while jobs of its previous run are unfinished, so
+// that slow runs do not pile up. Schedules and their history are persisted
+// to a file. It is safe for concurrent use.
+type Scheduler struct {
+	path   string
+	jobs   *Jobs
+	expand ScheduleExpander
+
+	mu        sync.Mutex
+	schedules map[string]*Schedule
+	crons     map[string]*Cron
+	changed   chan struct{} // wakes up Run when the schedules change
+}
+
+// NewScheduler returns the scheduler of the schedules persisted at path,
+// submitting their jobs, expanded by expand, to jobs.
+func NewScheduler(path string, jobs *Jobs, expand ScheduleExpander) (*Scheduler, error) {
+	s := &Scheduler{
+		path:      path,
+		jobs:      jobs,
+		expand:    expand,
+		schedules: make(map[string]*Schedule),
+		crons:     make(map[string]*Cron),
+		changed:   make(chan struct{}, 1),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var schedules []*Schedule
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return nil, fmt.Errorf("failed to parse schedules %s: %w", path, err)
+	}
+	for _, sched := range schedules {
+		cron, err := ParseCron(sched.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: %w", sched.Name, err)
+		}
+		s.schedules[sched.Name], s.crons[sched.Name] = sched, cron
+	}
+
+	return s, nil
+}
+
+// Add adds a schedule, whose first run is at the next time of its cron
+// expression.
+func (s *Scheduler) Add(sched Schedule) error {
+	if !validSessionName.MatchString(sched.Name) {
+		return fmt.Errorf("invalid schedule name %q: use letters, digits, '.', '_' and '-'", sched.Name)
+	}
+	if sched.Dir == "" {
+		return fmt.Errorf("schedule %s has no directory", sched.Name)
+	}
+	if _, err := filepath.Match(sched.Pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", sched.Pattern, err)
+	}
+	cron, err := ParseCron(sched.Cron)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.schedules[sched.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrScheduleExists, sched.Name)
+	}
+	sched.CreatedAt, sched.Runs = time.Now().UTC(), nil
+	s.schedules[sched.Name], s.crons[sched.Name] = &sched, cron
+	s.notify()
+
+	return s.saveLocked()
+}
+
+// Delete removes the named schedule. The jobs it submitted are kept.
+func (s *Scheduler) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.schedules[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrScheduleNotFound, name)
+	}
+	delete(s.schedules, name)
+	delete(s.crons, name)
+	s.notify()
+
+	return s.saveLocked()
+}
+
+// Get returns a copy of the named schedule.
+func (s *Scheduler) Get(name string) (Schedule, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sched, ok := s.schedules[name]
+	if !ok {
+		return Schedule{}, false
+	}
+	copied := *sched
+	copied.Runs = slices.Clone(sched.Runs)
+
+	return copied, true
+}
+
+// List returns copies of the schedules, by name, without their history.
+func (s *Scheduler) List() []Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules := make([]Schedule, 0, len(s.schedules))
+	for _, sched := range s.schedules {
+		copied := *sched
+		copied.Runs = nil
+		schedules = append(schedules, copied)
+	}
+	slices.SortFunc(schedules, func(a, b Schedule) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return schedules
+}
+
+// Next returns the time of the next run of the named schedule after t.
+func (s *Scheduler) Next(name string, t time.Time) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cron, ok := s.crons[name]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return cron.Next(t), true
+}
+
+// Run runs the due schedules until ctx is done. Runs missed while the
+// scheduler was not running are not caught up.
+func (s *Scheduler) Run(ctx context.Context) error {
+	last := time.Now()
+	for {
+		next := s.next(last)
+		var wait <-chan time.Time
+		timer := time.NewTimer(time.Until(next))
+		if !next.IsZero() {
+			wait = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-s.changed:
+			// New schedules run from now on.
+			timer.Stop()
+			last = time.Now()
+		case now := <-wait:
+			for _, name := range s.due(last, now) {
+				if _, err := s.RunNow(name); err != nil && !errors.Is(err, ErrScheduleNotFound) {
+					println("Failed to run schedule", name+":", err.Error())
+				}
+			}
+			last = now
+		}
+	}
+}
+
+// next returns the earliest run of the schedules after t.
+func (s *Scheduler) next(t time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next time.Time
+	for _, cron := range s.crons {
+		if n := cron.Next(t); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+
+	return next
+}
+
+// due returns the schedules with a run after from and until to.
+func (s *Scheduler) due(from, to time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var names []string
+	for name, cron := range s.crons {
+		if n := cron.Next(from); !n.IsZero() && !n.After(to) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// RunNow runs the named schedule at once and returns the run, recorded in
+// its history. The run is skipped if jobs of the previous run are
+// unfinished.
+func (s *Scheduler) RunNow(name string) (ScheduleRun, error) {
+	s.mu.Lock()
+	sched, ok := s.schedules[name]
+	if !ok {
+		s.mu.Unlock()
+		return ScheduleRun{}, fmt.Errorf("%w: %s", ErrScheduleNotFound, name)
+	}
+	run := ScheduleRun{StartedAt: time.Now().UTC()}
+	if n := len(sched.Runs); n > 0 && s.running(sched.Runs[n-1]) {
+		run.Skipped = "the jobs of the previous run are unfinished"
+		defer s.mu.Unlock()
+		return run, s.recordLocked(sched, run)
+	}
+	spec, since := *sched, sched.lastRun()
+	s.mu.Unlock()
+
+	jobs, err := s.expand(spec, since)
+	if err != nil {
+		run.Error = err.Error()
+	}
+	for _, job := range jobs {
+		run.Jobs = append(run.Jobs, s.jobs.Submit(job).State().ID)
+	}
+	if err == nil && len(jobs) == 0 {
+		run.Skipped = "no files to process"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sched, ok = s.schedules[name]; !ok {
+		return run, nil
+	}
+
+	return run, s.recordLocked(sched, run)
+}
+
+// running reports whether a job of run is unfinished.
+func (s *Scheduler) running(run ScheduleRun) bool {
+	for _, id := range run.Jobs {
+		if job, ok := s.jobs.Get(id); ok && !job.State().Finished() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recordLocked adds run to the history of sched and saves the schedules.
+func (s *Scheduler) recordLocked(sched *Schedule, run ScheduleRun) error {
+	sched.Runs = append(sched.Runs, run)
+	if n := len(sched.Runs); n > maxScheduleRuns {
+		sched.Runs = slices.Clone(sched.Runs[n-maxScheduleRuns:])
+	}
+
+	return s.saveLocked()
+}
+
+// notify wakes up Run to reconsider the schedules.
+func (s *Scheduler) notify() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// saveLocked writes the schedules to their file.
+func (s *Scheduler) saveLocked() error {
+	schedules := make([]*Schedule, 0, len(s.schedules))
+	for _, sched := range s.schedules {
+		schedules = append(schedules, sched)
+	}
+	slices.SortFunc(schedules, func(a, b *Schedule) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	data, err := json.MarshalIndent(schedules, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// SchemaVersion is the version of the JSON files of an output directory: the
+// manifest, the run metadata, the model comparison and the cross-validation.
+// Files without a schema_version are version 1.
+//
+// Version 2 records the model of every request in the run metadata.
+const SchemaVersion = 2
+
+// schemaFiles are the versioned files of an output directory, the manifest
+// last since it checksums the others.
+var schemaFiles = []struct {
+	name string
+	new  func() any
+}{
+	{RunInfoFile, func() any { return &RunInfo{} }},
+	{ComparisonJsonFile, func() any { return &Comparison{} }},
+	{CrossCheckFile, func() any { return &CrossCheck{} }},
+	{ManifestFile, func() any { return &Manifest{} }},
+}
+
+// schemaMigrations upgrade a versioned file, decoded as a JSON object, from
+// version i+1 to i+2.
+var schemaMigrations = []func(file string, doc map[string]any){
+	func(file string, doc map[string]any) {
+		if file != RunInfoFile {
+			return
+		}
+		requests, _ := doc["requests"].([]any)
+		for _, r := range requests {
+			req, ok := r.(map[string]any)
+			if ok && req["model"] == nil && req["engine"] == nil {
+				req["model"] = doc["model"]
+			}
+		}
+	},
+}
+
+// MigrateOutputs upgrades the versioned files of all output directories
+// below root, in any layout, and returns the paths of the upgraded files.
+func MigrateOutputs(root string) ([]string, error) {
+	type run struct{ dir, prefix string }
+	var runs []run
+	seen := make(map[run]bool)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		for _, f := range schemaFiles {
+			// Files of the flat layout are prefixed with the document name.
+			if prefix, ok := strings.CutSuffix(d.Name(), f.name); ok && (prefix == "" || strings.HasSuffix(prefix, "_")) {
+				r := run{filepath.Dir(path), prefix}
+				if !seen[r] {
+					seen[r] = true
+					runs = append(runs, r)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var migrated []string
+	for _, r := range runs {
+		paths, err := MigrateOutput(r.dir, r.prefix)
+		migrated = append(migrated, paths...)
+		if err != nil {
+			return migrated, err
+		}
+	}
+
+	return migrated, nil
+}
+
+// MigrateOutput upgrades the versioned files of a run in the output
+// directory dir, named with prefix, to SchemaVersion, updating their
+// checksums in the manifest, and returns the paths of the upgraded files.
+func MigrateOutput(dir, prefix string) ([]string, error) {
+	var migrated []string
+	for _, f := range schemaFiles {
+		path := filepath.Join(dir, prefix+f.name)
+		changed, err := migrateFile(path, f.name, f.new())
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return migrated, err
+		}
+		if changed {
+			migrated = append(migrated, path)
+		}
+	}
+	if len(migrated) == 0 {
+		return nil, nil
+	}
+
+	manifestPath := filepath.Join(dir, prefix+ManifestFile)
+	m, err := LoadManifest(manifestPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return migrated, nil
+	}
+	if err != nil {
+		return migrated, err
+	}
+	for _, a := range m.Artifacts {
+		path := filepath.Join(dir, filepath.FromSlash(a.Path))
+		if slices.Contains(migrated, path) {
+			if err := m.Add(a.Kind, path, a.Pages, a.PromptHash); err != nil {
+				return migrated, err
+			}
+		}
+	}
+
+	return migrated, m.Write(manifestPath)
+}
+
+// migrateFile upgrades the versioned file at path, named file in its output
+// directory, to SchemaVersion. v is the type it is written as, so fields
+// keep their order. It reports whether the file changed.
+func migrateFile(path, file string, v any) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return false, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	version := 1
+	if n, ok := doc["schema_version"].(json.Number); ok {
+		v, err := n.Int64()
+		if err != nil {
+			return false, fmt.Errorf("invalid schema version of %s: %w", path, err)
+		}
+		version = int(v)
+	}
+	if version > SchemaVersion {
+		return false, fmt.Errorf("%s has schema version %d, newer than the supported version %d", path, version, SchemaVersion)
+	}
+	if version == SchemaVersion {
+		return false, nil
+	}
+
+	for ; version < SchemaVersion; version++ {
+		schemaMigrations[version-1](file, doc)
+	}
+	doc["schema_version"] = SchemaVersion
+
+	if data, err = json.Marshal(doc); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to upgrade %s: %w", path, err)
+	}
+	if data, err = json.MarshalIndent(v, "", "  "); err != nil {
+		return false, err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return false, err
+	}
+
+	return true, os.Rename(tmp, path)
+}
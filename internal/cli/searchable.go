@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/unidoc/unipdf/v4/contentstream"
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/model"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+	"github.com/sampila/uniai-client/pkg/uniai/jsonrepair"
+)
+
+const (
+	// ocrFontName is the resource name of the font used by the text layer.
+	ocrFontName = core.PdfObjectName("UniAIOcr")
+
+	// ocrMargin is the page margin, in points, of text lines without a
+	// bounding box.
+	ocrMargin = 36
+
+	// ocrMaxFontSize caps the font size of text lines without a bounding box.
+	ocrMaxFontSize = 12
+
+	// ocrCharWidth is the approximate average glyph width of Helvetica, as a
+	// fraction of the font size.
+	ocrCharWidth = 0.5
+
+	// textRenderInvisible is the "neither fill nor stroke" text render mode.
+	textRenderInvisible = 3
+)
+
+// OcrPositionsPrompt can be appended to a prompt to ask the model for
+// transcribed lines with positions, in the format understood by
+// [ParseOcrLines].
+const OcrPositionsPrompt = `Return the transcription as a JSON array of objects {"text": "...", "bbox": [x0, y0, x1, y1]}, ` +
+	`one per text line in reading order, where bbox is the line bounding box as fractions (0-1) of the page width and height, measured from the top-left corner.`
+
+// OcrLine is a transcribed line of text. BBox is optional and holds
+// [x0, y0, x1, y1] as fractions of the page size, with a top-left origin.
+type OcrLine struct {
+	Text string    `json:"text"`
+	BBox []float64 `json:"bbox,omitempty"`
+}
+
+// ParseOcrLines parses a model transcription. Responses in the JSON format of
+// [OcrPositionsPrompt] (optionally inside a code fence) keep their positions;
+// anything else is split into plain lines.
+func ParseOcrLines(response string) []OcrLine {
+	trimmed := uniai.TrimCodeFences(response)
+
+	var lines []OcrLine
+	if strings.HasPrefix(strings.TrimSpace(trimmed), "[") && jsonrepair.Unmarshal([]byte(trimmed), &lines) == nil {
+		return lines
+	}
+
+	lines = lines[:0]
+	for _, l := range strings.Split(response, "\n") {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		lines = append(lines, OcrLine{Text: l})
+	}
+
+	return lines
+}
+
+// WriteSearchablePdf writes a copy of the PDF at src to dst where every page
+// present in texts gets an invisible text layer on top of its content, making
+// scanned pages searchable and copyable. Texts are keyed by 1-based page
+// number.
+func WriteSearchablePdf(src, dst string, texts map[int][]OcrLine) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader, err := model.NewPdfReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to open PDF file: %w", err)
+	}
+
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		return err
+	}
+
+	font, err := model.NewStandard14Font(model.HelveticaName)
+	if err != nil {
+		return err
+	}
+
+	writer := model.NewPdfWriter()
+	for i := 1; i <= numPages; i++ {
+		page, err := reader.GetPage(i)
+		if err != nil {
+			return fmt.Errorf("failed to get page %d: %w", i, err)
+		}
+
+		if lines, ok := texts[i]; ok && len(lines) > 0 {
+			if err := addTextLayer(page, font, lines); err != nil {
+				return fmt.Errorf("failed to add text layer to page %d: %w", i, err)
+			}
+		}
+
+		if err := writer.AddPage(page); err != nil {
+			return fmt.Errorf("failed to add page %d: %w", i, err)
+		}
+	}
+
+	return writePdf(&writer, dst)
+}
+
+func addTextLayer(page *model.PdfPage, font *model.PdfFont, lines []OcrLine) error {
+	box, err := page.GetMediaBox()
+	if err != nil {
+		return err
+	}
+	width, height := box.Width(), box.Height()
+
+	if page.Resources == nil {
+		page.Resources = model.NewPdfPageResources()
+	}
+	if err := page.Resources.SetFontByName(ocrFontName, font.ToPdfObject()); err != nil {
+		return err
+	}
+
+	lineHeight := (height - 2*ocrMargin) / float64(len(lines))
+	fallbackSize := min(lineHeight*0.8, ocrMaxFontSize)
+
+	cc := contentstream.NewContentCreator()
+	cc.Add_BT().Add_Tr(textRenderInvisible)
+	for i, line := range lines {
+		text := strings.TrimSpace(line.Text)
+		if text == "" {
+			continue
+		}
+
+		var x, y, size, scale float64
+		if len(line.BBox) == 4 {
+			x0, y0, x1, y1 := line.BBox[0], line.BBox[1], line.BBox[2], line.BBox[3]
+			size = max((y1-y0)*height*0.8, 1)
+			x = box.Llx + x0*width
+			y = box.Ury - y1*height
+			// Stretch the text horizontally so selections match the line.
+			scale = (x1 - x0) * width / (ocrCharWidth * size * float64(len([]rune(text)))) * 100
+		} else {
+			size = fallbackSize
+			x = box.Llx + ocrMargin
+			y = box.Ury - ocrMargin - float64(i+1)*lineHeight
+			scale = 100
+		}
+
+		encoded := font.Encoder().Encode(text)
+		cc.Add_Tf(ocrFontName, size).
+			Add_Tz(scale).
+			Add_Tm(1, 0, 0, 1, x, y).
+			Add_Tj(*core.MakeStringFromBytes(encoded))
+	}
+	cc.Add_ET()
+
+	return page.AppendContentBytes(cc.Bytes(), true)
+}
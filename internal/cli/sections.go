@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// SectionPlaceholder is replaced with the section title in prompts of
+// section-based processing.
+const SectionPlaceholder = "{{section}}"
+
+// Section is a range of pages belonging to one outline (bookmark) entry.
+type Section struct {
+	Title string
+	Level int
+
+	// StartPage and EndPage are 1-based and inclusive.
+	StartPage int
+	EndPage   int
+}
+
+// Pages returns the page numbers of the section.
+func (s Section) Pages() []int {
+	pages := make([]int, 0, s.EndPage-s.StartPage+1)
+	for i := s.StartPage; i <= s.EndPage; i++ {
+		pages = append(pages, i)
+	}
+
+	return pages
+}
+
+// ReadSections derives document sections from the outline, down to maxDepth
+// levels (1 for top-level entries only). A section ends right before the next
+// one starts; pages before the first entry form a "Front matter" section. Nil
+// is returned when the document has no outline.
+func ReadSections(reader *model.PdfReader, maxDepth int) ([]Section, error) {
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+
+	outline, err := reader.GetOutlines()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outline: %w", err)
+	}
+	if outline == nil || len(outline.Entries) == 0 {
+		return nil, nil
+	}
+
+	var sections []Section
+	var walk func(items []*model.OutlineItem, level int)
+	walk = func(items []*model.OutlineItem, level int) {
+		for _, item := range items {
+			// Outline destinations are 0-based.
+			page := int(item.Dest.Page) + 1
+			if page >= 1 && page <= numPages {
+				sections = append(sections, Section{
+					Title:     strings.TrimSpace(item.Title),
+					Level:     level,
+					StartPage: page,
+				})
+			}
+			if maxDepth <= 0 || level < maxDepth {
+				walk(item.Entries, level+1)
+			}
+		}
+	}
+	walk(outline.Entries, 1)
+
+	if len(sections) == 0 {
+		return nil, nil
+	}
+
+	sort.SliceStable(sections, func(i, j int) bool {
+		return sections[i].StartPage < sections[j].StartPage
+	})
+
+	if sections[0].StartPage > 1 {
+		sections = append([]Section{{Title: "Front matter", Level: 1, StartPage: 1}}, sections...)
+	}
+
+	for i := range sections {
+		if i == len(sections)-1 {
+			sections[i].EndPage = numPages
+			continue
+		}
+		// Entries starting on the same page share it.
+		sections[i].EndPage = max(sections[i].StartPage, sections[i+1].StartPage-1)
+	}
+
+	return sections, nil
+}
+
+// SectionPrompt adapts prompt for a section: the [SectionPlaceholder] is
+// replaced by the section title, or the section is described after the prompt
+// when the placeholder is absent.
+func SectionPrompt(prompt string, s Section) string {
+	if strings.Contains(prompt, SectionPlaceholder) {
+		return strings.ReplaceAll(prompt, SectionPlaceholder, s.Title)
+	}
+
+	return fmt.Sprintf("%s\n\nThe provided pages are the section %q (pages %d-%d) of the document.", prompt, s.Title, s.StartPage, s.EndPage)
+}
@@ -0,0 +1,313 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// validSessionName restricts session names to characters safe in file names.
+var validSessionName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ErrInvalidTurn is returned when editing or regenerating a turn a session
+// does not have.
+var ErrInvalidTurn = errors.New("invalid turn")
+
+// Session is a persisted chat history.
+type Session struct {
+	Name      string          `json:"name"`
+	Model     string          `json:"model"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Messages  []uniai.Message `json:"messages"`
+
+	// Document is the document the conversation is about, given as context
+	// by its system message.
+	Document string `json:"document,omitempty"`
+
+	// Parent is the session this branch was forked from, after its first
+	// ForkedAt messages.
+	Parent   string `json:"parent,omitempty"`
+	ForkedAt int    `json:"forked_at,omitempty"`
+}
+
+// Fork returns a new session named name continuing the first at messages of
+// s, e.g. to try another extraction strategy from the same context.
+func (s *Session) Fork(name string, at int) (*Session, error) {
+	if at < 0 || at > len(s.Messages) {
+		return nil, fmt.Errorf("invalid fork index %d: session %s has %d message(s)", at, s.Name, len(s.Messages))
+	}
+	if !validSessionName.MatchString(name) {
+		return nil, fmt.Errorf("invalid session name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+
+	return &Session{
+		Name:     name,
+		Model:    s.Model,
+		Messages: slices.Clone(s.Messages[:at]),
+		Parent:   s.Name,
+		ForkedAt: at,
+	}, nil
+}
+
+// userMessage returns the index in Messages of the n-th user message,
+// starting at 1.
+func (s *Session) userMessage(n int) (int, error) {
+	count := 0
+	for i, m := range s.Messages {
+		if m.Role != "user" {
+			continue
+		}
+		if count++; count == n {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w %d: session %s has %d user message(s)", ErrInvalidTurn, n, s.Name, count)
+}
+
+// UserMessages returns the user messages of the session, the n-th one at
+// n-1.
+func (s *Session) UserMessages() []string {
+	var messages []string
+	for _, m := range s.Messages {
+		if m.Role == "user" {
+			messages = append(messages, m.Content)
+		}
+	}
+
+	return messages
+}
+
+// EditTurn replaces the content of the n-th user message, starting at 1, and
+// drops the messages after it, which answered the previous content. The
+// session then ends with the edited message, awaiting a new reply.
+func (s *Session) EditTurn(n int, content string) error {
+	i, err := s.userMessage(n)
+	if err != nil {
+		return err
+	}
+	s.Messages = slices.Clone(s.Messages[:i+1])
+	s.Messages[i].Content = content
+
+	return nil
+}
+
+// DropReply drops the last reply of the assistant so that it can be
+// regenerated. The session then ends with the user message it answered.
+func (s *Session) DropReply() error {
+	n := len(s.Messages)
+	if n < 2 || s.Messages[n-1].Role != "assistant" || s.Messages[n-2].Role != "user" {
+		return fmt.Errorf("%w: session %s does not end with a reply to regenerate", ErrInvalidTurn, s.Name)
+	}
+	s.Messages = slices.Clip(s.Messages[:n-1])
+
+	return nil
+}
+
+// CommonHistory returns the number of leading messages a and b share, such
+// as the history of a branch and of the session it was forked from.
+func CommonHistory(a, b *Session) int {
+	n := 0
+	for n < len(a.Messages) && n < len(b.Messages) {
+		ma, mb := a.Messages[n], b.Messages[n]
+		if ma.Role != mb.Role || ma.Content != mb.Content || len(ma.Images) != len(mb.Images) {
+			break
+		}
+		n++
+	}
+
+	return n
+}
+
+// Markdown renders the session as a readable transcript.
+func (s *Session) Markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Chat session %s\n\n", s.Name)
+	fmt.Fprintf(&sb, "Model: %s, started %s\n", s.Model, s.CreatedAt.Format(time.RFC3339))
+	if s.Parent != "" {
+		fmt.Fprintf(&sb, "Forked from %s after message %d\n", s.Parent, s.ForkedAt)
+	}
+	sb.WriteString(MessagesMarkdown(s.Messages))
+
+	return sb.String()
+}
+
+// MessagesMarkdown renders chat messages as a transcript, a section per
+// message.
+func MessagesMarkdown(messages []uniai.Message) string {
+	var sb strings.Builder
+	for _, m := range messages {
+		role := m.Role
+		if role != "" {
+			role = strings.ToUpper(role[:1]) + role[1:]
+		}
+		fmt.Fprintf(&sb, "\n## %s\n\n%s\n", role, strings.TrimSpace(m.Content))
+		if len(m.Images) > 0 {
+			fmt.Fprintf(&sb, "\n[%d image(s)]\n", len(m.Images))
+		}
+	}
+
+	return sb.String()
+}
+
+// SessionStore keeps chat sessions as JSON files in a directory.
+type SessionStore struct {
+	dir string
+}
+
+// DefaultSessionDir returns the per-user directory of chat sessions.
+func DefaultSessionDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "uniai", "sessions"), nil
+}
+
+// NewSessionStore creates the session directory if needed and returns the
+// store.
+func NewSessionStore(dir string) (*SessionStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	return &SessionStore{dir: dir}, nil
+}
+
+func (s *SessionStore) path(name string) (string, error) {
+	if !validSessionName.MatchString(name) {
+		return "", fmt.Errorf("invalid session name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+
+	return filepath.Join(s.dir, name+".json"), nil
+}
+
+// Load returns the named session. The error wraps os.ErrNotExist if it does
+// not exist.
+func (s *SessionStore) Load(name string) (*Session, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadSessionFile(path)
+}
+
+// Save writes a session, updating its modification time.
+func (s *SessionStore) Save(sess *Session) error {
+	path, err := s.path(sess.Name)
+	if err != nil {
+		return err
+	}
+
+	return SaveSessionFile(path, sess)
+}
+
+// LoadSessionFile reads the session at path, such as a chat history file
+// outside of the session store. The error wraps os.ErrNotExist if it does
+// not exist.
+func LoadSessionFile(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", path, err)
+	}
+
+	return &sess, nil
+}
+
+// SaveSessionFile writes a session to path, updating its modification time.
+func SaveSessionFile(path string, sess *Session) error {
+	sess.UpdatedAt = time.Now().UTC()
+	if sess.CreatedAt.IsZero() {
+		sess.CreatedAt = sess.UpdatedAt
+	}
+
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// List returns all sessions, most recently used first.
+func (s *SessionStore) List() ([]*Session, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*Session
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		sess, err := s.Load(name)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+
+	return sessions, nil
+}
+
+// Branches returns the sessions forked from the named session, most
+// recently used first.
+func (s *SessionStore) Branches(name string) ([]*Session, error) {
+	sessions, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var branches []*Session
+	for _, sess := range sessions {
+		if sess.Parent == name {
+			branches = append(branches, sess)
+		}
+	}
+
+	return branches, nil
+}
+
+// Delete removes the named session.
+func (s *SessionStore) Delete(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrSessionNotFound, name)
+		}
+		return err
+	}
+
+	return nil
+}
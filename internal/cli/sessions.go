@@ -0,0 +1,321 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// ErrSessionNotFound is returned for a session neither open nor stored.
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrSessionExists is returned when forking into an existing session.
+var ErrSessionExists = errors.New("session already exists")
+
+// SessionRateError is returned when a session sends more turns than its rate
+// limit allows.
+type SessionRateError struct {
+	Session    string
+	RetryAfter time.Duration
+}
+
+func (e *SessionRateError) Error() string {
+	return fmt.Sprintf("session %s is rate limited, retry in %s", e.Session, e.RetryAfter.Round(time.Second))
+}
+
+// SessionTurn is a user message sent to a session.
+type SessionTurn struct {
+	Content string            `json:"content"`
+	Images  []uniai.ImageData `json:"images,omitempty"`
+	// Model is the model of a new session, or switches the model of an
+	// existing one.
+	Model string `json:"model,omitempty"`
+	// System is the system prompt of a new session.
+	System string `json:"system,omitempty"`
+	// Options of the request, [uniai.DefaultOptions] if nil.
+	Options map[string]any `json:"options,omitempty"`
+}
+
+// SessionManager runs many independent chat sessions concurrently over one
+// client. The turns of a session are sent one at a time, in order, while
+// sessions do not wait for one another. Every session is limited to a
+// number of turns per minute, and saved to the store, if any, after every
+// reply. It is safe for concurrent use.
+type SessionManager struct {
+	client         *uniai.Client
+	store          *SessionStore // nil keeps the sessions in memory only
+	turnsPerMinute int           // 0 for no limit
+
+	mu       sync.Mutex
+	sessions map[string]*managedSession
+}
+
+// managedSession is an open session with its turn lock and the start of its
+// recent turns.
+type managedSession struct {
+	turn  chan struct{} // held while a turn is sent
+	sess  *Session
+	turns []time.Time // turns started in the last minute
+}
+
+// NewSessionManager returns a manager of the sessions of store sending at most
+// turnsPerMinute turns per session, any number if 0. Sessions are kept in
+// memory only if store is nil.
+func NewSessionManager(client *uniai.Client, store *SessionStore, turnsPerMinute int) *SessionManager {
+	return &SessionManager{
+		client:         client,
+		store:          store,
+		turnsPerMinute: turnsPerMinute,
+		sessions:       make(map[string]*managedSession),
+	}
+}
+
+// open returns the named session, loading it from the store, or creating it
+// if create is set.
+func (m *SessionManager) open(name string, create bool) (*managedSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.sessions[name]; ok {
+		return s, nil
+	}
+	if !validSessionName.MatchString(name) {
+		return nil, fmt.Errorf("invalid session name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+
+	sess := &Session{Name: name, Model: uniai.ModelDefault}
+	if m.store != nil {
+		loaded, err := m.store.Load(name)
+		switch {
+		case err == nil:
+			sess = loaded
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, err
+		case !create:
+			return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, name)
+		}
+	} else if !create {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, name)
+	}
+
+	s := &managedSession{turn: make(chan struct{}, 1), sess: sess}
+	m.sessions[name] = s
+
+	return s, nil
+}
+
+// lock waits for the turn of session s, or until ctx is done.
+func (s *managedSession) lock(ctx context.Context) error {
+	select {
+	case s.turn <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *managedSession) unlock() {
+	<-s.turn
+}
+
+// allow records a turn started at now, unless the session already started
+// limit turns in the last minute.
+func (s *managedSession) allow(now time.Time, limit int) error {
+	if limit <= 0 {
+		return nil
+	}
+
+	recent := s.turns[:0]
+	for _, t := range s.turns {
+		if now.Sub(t) < time.Minute {
+			recent = append(recent, t)
+		}
+	}
+	s.turns = recent
+	if len(s.turns) >= limit {
+		return &SessionRateError{Session: s.sess.Name, RetryAfter: s.turns[0].Add(time.Minute).Sub(now)}
+	}
+	s.turns = append(s.turns, now)
+
+	return nil
+}
+
+// Send sends turn to the named session, created if it does not exist, and
+// returns the reply once complete. fn, if not nil, is called with every
+// chunk of the reply as it is streamed. A turn waits for the previous turn
+// of the session to complete; a turn that fails leaves the history
+// unchanged.
+func (m *SessionManager) Send(ctx context.Context, name string, turn SessionTurn, fn func(chunk string)) (uniai.Message, error) {
+	return m.reply(ctx, name, true, turn, fn, func(sess *Session) error {
+		if len(sess.Messages) == 0 && turn.System != "" {
+			sess.Messages = append(sess.Messages, uniai.Message{Role: "system", Content: turn.System})
+		}
+		sess.Messages = append(sess.Messages, uniai.Message{Role: "user", Content: turn.Content, Images: turn.Images})
+		return nil
+	})
+}
+
+// Regenerate replaces the last reply of the named session with a new one,
+// with the model and options of turn if set, and returns it as
+// [SessionManager.Send] does.
+func (m *SessionManager) Regenerate(ctx context.Context, name string, turn SessionTurn, fn func(chunk string)) (uniai.Message, error) {
+	return m.reply(ctx, name, false, turn, fn, (*Session).DropReply)
+}
+
+// Edit replaces the content of the n-th user message of the named session,
+// starting at 1, with the content of turn, drops the messages after it and
+// returns the new reply as [SessionManager.Send] does.
+func (m *SessionManager) Edit(ctx context.Context, name string, n int, turn SessionTurn, fn func(chunk string)) (uniai.Message, error) {
+	return m.reply(ctx, name, false, turn, fn, func(sess *Session) error {
+		return sess.EditTurn(n, turn.Content)
+	})
+}
+
+// reply prepares a copy of the history of the named session to end with a
+// user message, and sends it with the model and options of turn. The
+// session is only updated once the reply is complete.
+func (m *SessionManager) reply(ctx context.Context, name string, create bool, turn SessionTurn, fn func(chunk string), prepare func(sess *Session) error) (uniai.Message, error) {
+	s, err := m.open(name, create)
+	if err != nil {
+		return uniai.Message{}, err
+	}
+	if err := s.lock(ctx); err != nil {
+		return uniai.Message{}, err
+	}
+	defer s.unlock()
+
+	draft := *s.sess
+	draft.Messages = slices.Clip(draft.Messages)
+	if err := prepare(&draft); err != nil {
+		return uniai.Message{}, err
+	}
+	if err := s.allow(time.Now(), m.turnsPerMinute); err != nil {
+		return uniai.Message{}, err
+	}
+
+	model := cmp.Or(turn.Model, draft.Model)
+	options := turn.Options
+	if options == nil {
+		options = uniai.DefaultOptions
+	}
+	var reply strings.Builder
+	err = m.client.Chat(ctx, &uniai.ChatRequest{Model: model, Messages: draft.Messages, Options: options}, func(resp uniai.ChatResponse) error {
+		reply.WriteString(resp.Message.Content)
+		if fn != nil {
+			fn(resp.Message.Content)
+		}
+		return nil
+	})
+	if err != nil {
+		return uniai.Message{}, err
+	}
+
+	answer := uniai.Message{Role: "assistant", Content: reply.String()}
+	sess := s.sess
+	sess.Model, sess.Messages = model, append(draft.Messages, answer)
+	if m.store != nil {
+		if err := m.store.Save(sess); err != nil {
+			return answer, fmt.Errorf("failed to save session: %w", err)
+		}
+	}
+
+	return answer, nil
+}
+
+// Session returns a copy of the named session, waiting for its current turn
+// to complete.
+func (m *SessionManager) Session(ctx context.Context, name string) (*Session, error) {
+	s, err := m.open(name, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
+
+	sess := *s.sess
+	sess.Messages = slices.Clone(s.sess.Messages)
+
+	return &sess, nil
+}
+
+// Fork forks the first at messages of the named session into a new session
+// named branch, saved to the store, and returns a copy of it.
+func (m *SessionManager) Fork(ctx context.Context, name, branch string, at int) (*Session, error) {
+	sess, err := m.Session(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	fork, err := sess.Fork(branch, at)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sessions[branch]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrSessionExists, branch)
+	}
+	if m.store != nil {
+		if _, err := m.store.Load(branch); err == nil {
+			return nil, fmt.Errorf("%w: %s", ErrSessionExists, branch)
+		}
+		if err := m.store.Save(fork); err != nil {
+			return nil, fmt.Errorf("failed to save session: %w", err)
+		}
+	}
+	m.sessions[branch] = &managedSession{turn: make(chan struct{}, 1), sess: fork}
+
+	copied := *fork
+	copied.Messages = slices.Clone(fork.Messages)
+
+	return &copied, nil
+}
+
+// Sessions returns the names of the open sessions.
+func (m *SessionManager) Sessions() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.sessions))
+	for name := range m.sessions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// Close releases the named session from memory. It stays in the store.
+func (m *SessionManager) Close(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, name)
+}
+
+// Delete closes the named session and removes it from the store.
+func (m *SessionManager) Delete(name string) error {
+	m.mu.Lock()
+	_, open := m.sessions[name]
+	delete(m.sessions, name)
+	m.mu.Unlock()
+
+	if m.store == nil {
+		if !open {
+			return fmt.Errorf("%w: %s", ErrSessionNotFound, name)
+		}
+		return nil
+	}
+
+	return m.store.Delete(name)
+}
@@ -0,0 +1,271 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Step types of a pipeline spec.
+const (
+	// StepRender loads the pages of the input: rendered images, or text for
+	// pages with a text layer when text_first is set and for converted
+	// documents.
+	StepRender = "render"
+	// StepExtract sends every page of its input with the prompt and keeps
+	// the answer of each page as text.
+	StepExtract = "extract"
+	// StepStructure sends all pages of its input in a single request and
+	// parses the answer as JSON.
+	StepStructure = "structure"
+	// StepValidate checks the JSON value of its input against a schema.
+	StepValidate = "validate"
+	// StepExport writes its input to a file.
+	StepExport = "export"
+)
+
+// Export formats of a pipeline spec.
+const (
+	ExportJson     = "json"
+	ExportCsv      = "csv"
+	ExportText     = "text"
+	ExportMarkdown = "markdown"
+)
+
+// Spec describes a multi-step document job, run by 'uniai pipeline run'.
+type Spec struct {
+	Name   string     `yaml:"name"`
+	Input  string     `yaml:"input"`  // input file, relative to the spec
+	Pages  string     `yaml:"pages"`  // page range, all pages if empty
+	Output string     `yaml:"output"` // output directory, relative to the spec
+	Model  string     `yaml:"model"`  // default model of the steps
+	Steps  []SpecStep `yaml:"steps"`
+}
+
+// SpecStep is a step of a pipeline spec. Which fields apply depends on the
+// type.
+type SpecStep struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	// Input is the name of the step whose result this step consumes; the
+	// previous step by default.
+	Input string `yaml:"input"`
+
+	// render
+	TextFirst bool `yaml:"text_first"`
+
+	// extract and structure
+	Prompt  string         `yaml:"prompt"`
+	Model   string         `yaml:"model"`
+	System  string         `yaml:"system"`
+	Options map[string]any `yaml:"options"`
+
+	// structure and validate
+	Schema map[string]any `yaml:"schema"`
+
+	// validate: continue with the invalid value instead of failing
+	ContinueOnError bool `yaml:"continue_on_error"`
+
+	// export
+	Format string `yaml:"format"`
+	File   string `yaml:"file"` // relative to the output directory
+}
+
+// LoadSpec reads and checks a YAML pipeline spec. Relative input and output
+// paths are resolved against the directory of the spec.
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec Spec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse spec %s: %w", path, err)
+	}
+	if spec.Name == "" {
+		spec.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	dir := filepath.Dir(path)
+	if spec.Input != "" && !filepath.IsAbs(spec.Input) && !strings.Contains(spec.Input, "://") {
+		spec.Input = filepath.Join(dir, spec.Input)
+	}
+	if spec.Output != "" && !filepath.IsAbs(spec.Output) {
+		spec.Output = filepath.Join(dir, spec.Output)
+	}
+
+	if err := spec.check(); err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+}
+
+// check validates the steps and fills in their default names and inputs.
+func (s *Spec) check() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("spec %s has no steps", s.Name)
+	}
+
+	names := make(map[string]bool)
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("%s-%d", step.Type, i+1)
+		}
+		if names[step.Name] {
+			return fmt.Errorf("duplicate step name %q", step.Name)
+		}
+
+		switch step.Type {
+		case StepRender:
+		case StepExtract, StepStructure:
+			if step.Prompt == "" {
+				return fmt.Errorf("step %s has no prompt", step.Name)
+			}
+		case StepValidate:
+			if len(step.Schema) == 0 {
+				return fmt.Errorf("step %s has no schema", step.Name)
+			}
+		case StepExport:
+			if step.File == "" {
+				return fmt.Errorf("step %s has no file", step.Name)
+			}
+			if step.Format == "" {
+				step.Format = exportFormatOf(step.File)
+			}
+			switch step.Format {
+			case ExportJson, ExportCsv, ExportText, ExportMarkdown:
+			default:
+				return fmt.Errorf("step %s has an invalid format %q", step.Name, step.Format)
+			}
+		default:
+			return fmt.Errorf("step %s has an invalid type %q", step.Name, step.Type)
+		}
+
+		if step.Type != StepRender {
+			if step.Input == "" {
+				if i == 0 {
+					return fmt.Errorf("step %s has no input", step.Name)
+				}
+				step.Input = s.Steps[i-1].Name
+			}
+			if !names[step.Input] {
+				return fmt.Errorf("step %s uses %q, which is not an earlier step", step.Name, step.Input)
+			}
+		}
+		names[step.Name] = true
+	}
+
+	return nil
+}
+
+func exportFormatOf(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		return ExportCsv
+	case ".md":
+		return ExportMarkdown
+	case ".txt":
+		return ExportText
+	}
+
+	return ExportJson
+}
+
+// ExportValue formats the result of a step. JSON values are written as
+// indented JSON, or as CSV when they are a list of objects (one column per
+// key) or an object (one row); texts are written as they are.
+func ExportValue(format string, value any, texts []string) ([]byte, error) {
+	switch format {
+	case ExportText, ExportMarkdown:
+		if value != nil {
+			data, err := json.MarshalIndent(value, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			if format == ExportMarkdown {
+				return []byte("```json\n" + string(data) + "\n```\n"), nil
+			}
+			return append(data, '\n'), nil
+		}
+		sep := "\n\n"
+		if format == ExportMarkdown {
+			sep = "\n\n---\n\n"
+		}
+		return []byte(strings.Join(texts, sep) + "\n"), nil
+	case ExportCsv:
+		return exportCsv(value)
+	}
+
+	if value == nil {
+		value = texts
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+func exportCsv(value any) ([]byte, error) {
+	var rows []map[string]any
+	switch v := value.(type) {
+	case map[string]any:
+		rows = []map[string]any{v}
+	case []any:
+		for _, item := range v {
+			row, ok := item.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("cannot export %T list items as CSV", item)
+			}
+			rows = append(rows, row)
+		}
+	default:
+		return nil, fmt.Errorf("cannot export %T as CSV", value)
+	}
+
+	seen := make(map[string]bool)
+	var columns []string
+	for _, row := range rows {
+		for k := range row {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+	}
+	sort.Strings(columns)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(columns)
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for i, c := range columns {
+			switch v := row[c].(type) {
+			case nil:
+			case string:
+				record[i] = v
+			case map[string]any, []any:
+				data, _ := json.Marshal(v)
+				record[i] = string(data)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+
+	return buf.Bytes(), w.Error()
+}
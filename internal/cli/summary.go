@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// PageMetricsFile is the name of the per-page metrics written next to the
+// outputs.
+const PageMetricsFile = "metrics.csv"
+
+// Statuses of a run and of its pages in a summary.
+const (
+	StatusOK          = "ok"
+	StatusPartial     = "partial" // some pages failed or were not processed
+	StatusFailed      = "failed"
+	StatusCached      = "cached" // answered from the response cache
+	StatusSkipped     = "skipped"
+	StatusUnprocessed = "unprocessed" // not sent because the run was stopped
+)
+
+// PageSummary is the outcome of a page of a run. A request about several
+// pages, such as a section, counts for each of them.
+type PageSummary struct {
+	Page             int           `json:"page"`
+	Status           string        `json:"status"`
+	RenderDuration   time.Duration `json:"render_duration"`
+	Duration         time.Duration `json:"duration"` // of its requests
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	Retries          int           `json:"retries"`
+	Outputs          []string      `json:"outputs,omitempty"`
+	Reason           string        `json:"reason,omitempty"` // why the page was skipped
+	Error            string        `json:"error,omitempty"`
+}
+
+// RunSummary is the machine-readable outcome of a run, for orchestration
+// systems to branch on without scraping the log. Output paths are relative
+// to OutputDir.
+type RunSummary struct {
+	SchemaVersion    int            `json:"schema_version"`
+	Source           string         `json:"source"`
+	Status           string         `json:"status"`
+	OutputDir        string         `json:"output_dir"`
+	Manifest         string         `json:"manifest"`
+	RunInfo          string         `json:"run_info"`
+	Duration         time.Duration  `json:"duration"`
+	PromptTokens     int            `json:"prompt_tokens"`
+	CompletionTokens int            `json:"completion_tokens"`
+	Pages            []*PageSummary `json:"pages"`
+	Outputs          []string       `json:"outputs,omitempty"` // outputs of the whole document
+	Failures         []*PageError   `json:"failures,omitempty"`
+	StopReason       string         `json:"stop_reason,omitempty"`
+	Error            string         `json:"error,omitempty"`
+}
+
+// NewRunSummary summarizes the run, whose manifest and metadata are written
+// at manifestPath and runInfoPath relative to outputDir, with the pages
+// skipped by reason and the error of the run.
+func NewRunSummary(run *RunInfo, manifest *Manifest, outputDir, manifestPath, runInfoPath string, skipped map[int]string, err error) *RunSummary {
+	s := &RunSummary{
+		SchemaVersion: SchemaVersion,
+		Source:        run.Source,
+		OutputDir:     outputDir,
+		Manifest:      manifestPath,
+		RunInfo:       runInfoPath,
+		Duration:      run.Duration,
+		Failures:      run.Failures,
+		StopReason:    run.StopReason,
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+
+	pages := make(map[int]*PageSummary)
+	page := func(n int) *PageSummary {
+		if pages[n] == nil {
+			pages[n] = &PageSummary{Page: n, Status: StatusOK}
+		}
+		return pages[n]
+	}
+
+	for _, req := range run.Requests {
+		s.PromptTokens += req.PromptTokens
+		s.CompletionTokens += req.CompletionTokens
+		for _, n := range req.Pages {
+			p := page(n)
+			p.Duration += req.Duration
+			p.PromptTokens += req.PromptTokens
+			p.CompletionTokens += req.CompletionTokens
+			p.Retries += req.Retries
+			if req.Cached && p.Status == StatusOK {
+				p.Status = StatusCached
+			}
+		}
+	}
+	for _, render := range run.Renders {
+		page(render.Page).RenderDuration += render.Duration
+	}
+	for _, a := range manifest.Artifacts {
+		output := path.Join(path.Dir(manifestPath), a.Path)
+		if len(a.Pages) == 0 {
+			s.Outputs = append(s.Outputs, output)
+			continue
+		}
+		for _, n := range a.Pages {
+			page(n).Outputs = append(page(n).Outputs, output)
+		}
+	}
+	for n, reason := range skipped {
+		page(n).Status, page(n).Reason = StatusSkipped, reason
+	}
+	for _, n := range run.Unprocessed {
+		page(n).Status = StatusUnprocessed
+	}
+	for _, e := range run.Failures {
+		p := page(e.Page)
+		p.Status = StatusFailed
+		if e.Err != nil {
+			p.Error = e.Err.Error()
+		}
+	}
+
+	answered, failed := 0, 0
+	for _, p := range pages {
+		s.Pages = append(s.Pages, p)
+		switch p.Status {
+		case StatusOK, StatusCached:
+			answered++
+		case StatusFailed, StatusUnprocessed:
+			failed++
+		}
+	}
+	slices.SortFunc(s.Pages, func(a, b *PageSummary) int { return a.Page - b.Page })
+
+	switch {
+	case err == nil && failed == 0:
+		s.Status = StatusOK
+	case answered > 0:
+		s.Status = StatusPartial
+	default:
+		s.Status = StatusFailed
+	}
+
+	return s
+}
+
+// WriteCSV writes the metrics of every page to w as CSV, with a header row,
+// for analysis in a spreadsheet. Durations are in milliseconds.
+func (s *RunSummary) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"page", "render_ms", "request_ms", "prompt_tokens", "completion_tokens", "retries", "status"})
+	for _, p := range s.Pages {
+		cw.Write([]string{
+			strconv.Itoa(p.Page),
+			strconv.FormatInt(p.RenderDuration.Milliseconds(), 10),
+			strconv.FormatInt(p.Duration.Milliseconds(), 10),
+			strconv.Itoa(p.PromptTokens),
+			strconv.Itoa(p.CompletionTokens),
+			strconv.Itoa(p.Retries),
+			p.Status,
+		})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// Write writes the summary to w as indented JSON.
+func (s *RunSummary) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
+
+// LoadRunSummary reads the JSON summary of a run written by
+// [RunSummary.Write].
+func LoadRunSummary(path string) (*RunSummary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s RunSummary
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse run summary %s: %w", path, err)
+	}
+
+	return &s, nil
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//go:embed system/*.md
+var builtinSystemPrompts embed.FS
+
+// systemPromptExt is the file extension of system prompt presets.
+const systemPromptExt = ".md"
+
+// DefaultSystemPreset is the system prompt preset of page requests, unless
+// --system-preset or the task preset sets another.
+const DefaultSystemPreset = "default"
+
+// SystemPrompts holds the named system prompts: the built-in ones and the *.md
+// files of the user directory, which override built-ins of the same name, so
+// that an organization can share its prompt standards as files.
+type SystemPrompts struct {
+	texts   map[string]string
+	sources map[string]string
+}
+
+// DefaultSystemDir returns the per-user directory of system prompts.
+func DefaultSystemDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "uniai", "system"), nil
+}
+
+// LoadSystemPrompts returns the built-in system prompts and the *.md files of
+// userDir. A missing userDir is not an error.
+func LoadSystemPrompts(userDir string) (*SystemPrompts, error) {
+	p := &SystemPrompts{texts: make(map[string]string), sources: make(map[string]string)}
+
+	entries, err := fs.ReadDir(builtinSystemPrompts, "system")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		data, err := builtinSystemPrompts.ReadFile("system/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		p.add(strings.TrimSuffix(e.Name(), systemPromptExt), string(data), TemplateSourceBuiltin)
+	}
+
+	if userDir == "" {
+		return p, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(userDir, "*"+systemPromptExt))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		p.add(strings.TrimSuffix(filepath.Base(path), systemPromptExt), string(data), path)
+	}
+
+	return p, nil
+}
+
+func (p *SystemPrompts) add(name, text, source string) {
+	p.texts[name] = strings.TrimSpace(text)
+	p.sources[name] = source
+}
+
+// Get returns the named system prompt.
+func (p *SystemPrompts) Get(name string) (string, error) {
+	text, ok := p.texts[name]
+	if !ok {
+		return "", fmt.Errorf("unknown system prompt %q, available: %s", name, strings.Join(p.Names(), ", "))
+	}
+
+	return text, nil
+}
+
+// Names returns the sorted names of the system prompts.
+func (p *SystemPrompts) Names() []string {
+	names := make([]string, 0, len(p.texts))
+	for name := range p.texts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Source returns where a system prompt was loaded from, and its text.
+func (p *SystemPrompts) Source(name string) (source, text string, ok bool) {
+	text, ok = p.texts[name]
+	return p.sources[name], text, ok
+}
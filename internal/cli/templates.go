@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// templateExt is the file extension of prompt templates.
+const templateExt = ".tmpl"
+
+// TemplateSourceBuiltin is the source of templates shipped with the CLI.
+const TemplateSourceBuiltin = "built-in"
+
+// TemplateRegistry holds the named prompt templates: the built-in ones and
+// the user templates, which override built-ins of the same name. Templates
+// are text/template files; templates whose name starts with an underscore are
+// partials, meant to be included by others with {{template "_name" .}}.
+// Variables are available as {{.name}}.
+type TemplateRegistry struct {
+	set     *template.Template
+	texts   map[string]string
+	sources map[string]string
+}
+
+// DefaultTemplateDir returns the per-user directory of prompt templates.
+func DefaultTemplateDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "uniai", "templates"), nil
+}
+
+// LoadTemplates returns the built-in templates and the *.tmpl files of
+// userDir. A missing userDir is not an error.
+func LoadTemplates(userDir string) (*TemplateRegistry, error) {
+	r := &TemplateRegistry{
+		set:     template.New("").Option("missingkey=zero"),
+		texts:   make(map[string]string),
+		sources: make(map[string]string),
+	}
+
+	entries, err := fs.ReadDir(builtinTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		data, err := builtinTemplates.ReadFile("templates/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		if err := r.add(strings.TrimSuffix(e.Name(), templateExt), string(data), TemplateSourceBuiltin); err != nil {
+			return nil, err
+		}
+	}
+
+	if userDir == "" {
+		return r, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(userDir, "*"+templateExt))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.add(strings.TrimSuffix(filepath.Base(path), templateExt), string(data), path); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+func (r *TemplateRegistry) add(name, text, source string) error {
+	// The final newline of a file would otherwise end up in every template
+	// including it.
+	text = strings.TrimRight(text, "\r\n")
+	if _, err := r.set.New(name).Parse(text); err != nil {
+		return fmt.Errorf("failed to parse template %s: %w", source, err)
+	}
+	r.texts[name] = text
+	r.sources[name] = source
+
+	return nil
+}
+
+// Names returns the names of the templates that can be selected, without the
+// partials.
+func (r *TemplateRegistry) Names() []string {
+	var names []string
+	for name := range r.texts {
+		if !strings.HasPrefix(name, "_") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Source returns where a template was loaded from, and its text.
+func (r *TemplateRegistry) Source(name string) (source, text string, ok bool) {
+	text, ok = r.texts[name]
+	return r.sources[name], text, ok
+}
+
+// Description returns the leading {{/* comment */}} of a template.
+func (r *TemplateRegistry) Description(name string) string {
+	text := strings.TrimPrefix(strings.TrimSpace(r.texts[name]), "{{")
+	text = strings.TrimPrefix(text, "- ")
+	if !strings.HasPrefix(text, "/*") {
+		return ""
+	}
+	end := strings.Index(text, "*/")
+	if end < 0 {
+		return ""
+	}
+
+	return strings.TrimSpace(text[len("/*"):end])
+}
+
+// Render executes the named template with the given variables.
+func (r *TemplateRegistry) Render(name string, vars map[string]string) (string, error) {
+	if _, ok := r.texts[name]; !ok {
+		return "", fmt.Errorf("unknown template %q", name)
+	}
+
+	var sb strings.Builder
+	if err := r.set.ExecuteTemplate(&sb, name, vars); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// RenderText executes text as a template with the given variables. The text
+// may include the templates of the registry.
+func (r *TemplateRegistry) RenderText(text string, vars map[string]string) (string, error) {
+	set, err := r.set.Clone()
+	if err != nil {
+		return "", err
+	}
+	t, err := set.New("").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse prompt: %w", err)
+	}
+
+	var sb strings.Builder
+	if err := t.Execute(&sb, vars); err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// ParseVars parses "key=value" template variables.
+func ParseVars(pairs []string) (map[string]string, error) {
+	vars := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid variable %q, expected key=value", pair)
+		}
+		vars[key] = value
+	}
+
+	return vars, nil
+}
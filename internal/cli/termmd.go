@@ -0,0 +1,233 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI styles of the terminal Markdown rendering.
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiDim       = "\x1b[2m"
+	ansiItalic    = "\x1b[3m"
+	ansiUnderline = "\x1b[4m"
+	ansiCyan      = "\x1b[36m"
+	ansiMagenta   = "\x1b[35m"
+)
+
+var (
+	mdHeading  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdBullet   = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdRule     = regexp.MustCompile(`^\s*(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	mdTableSep = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+
+	mdCode   = regexp.MustCompile("`([^`]+)`")
+	mdBold   = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdItalic = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+	mdLink   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+)
+
+// MarkdownWriter renders Markdown written to it, such as a streamed model
+// response, with ANSI styles for a terminal: headings, emphasis, inline code,
+// links, lists, quotes, rules, fenced code blocks and aligned tables. Lines
+// are rendered once complete, and tables once their last row is; Flush
+// renders what is left at the end of the response.
+type MarkdownWriter struct {
+	w      io.Writer
+	line   []byte     // incomplete line
+	code   bool       // inside a fenced code block
+	table  [][]string // rows of the table being read
+	header bool       // whether the table has a header row
+}
+
+// NewMarkdownWriter returns a writer rendering Markdown to w.
+func NewMarkdownWriter(w io.Writer) *MarkdownWriter {
+	return &MarkdownWriter{w: w}
+}
+
+// Write renders the complete lines of p and buffers the rest.
+func (m *MarkdownWriter) Write(p []byte) (int, error) {
+	m.line = append(m.line, p...)
+	for {
+		i := bytes.IndexByte(m.line, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(m.line[:i])
+		m.line = m.line[i+1:]
+		if err := m.render(strings.TrimSuffix(line, "\r")); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// Flush renders the buffered line and table, ending a response.
+func (m *MarkdownWriter) Flush() error {
+	if len(m.line) > 0 {
+		line := string(m.line)
+		m.line = m.line[:0]
+		if err := m.render(line); err != nil {
+			return err
+		}
+	}
+	if err := m.flushTable(); err != nil {
+		return err
+	}
+	m.code = false
+
+	return nil
+}
+
+// RenderMarkdown renders a complete Markdown text for a terminal.
+func RenderMarkdown(text string) string {
+	var sb strings.Builder
+	m := NewMarkdownWriter(&sb)
+	m.Write([]byte(text))
+	m.Flush()
+
+	return sb.String()
+}
+
+func (m *MarkdownWriter) render(line string) error {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+		if err := m.flushTable(); err != nil {
+			return err
+		}
+		m.code = !m.code
+		if lang := strings.Trim(trimmed, "`~ "); m.code && lang != "" {
+			return m.print(ansiDim + "  " + lang + ansiReset)
+		}
+		return nil
+	}
+	if m.code {
+		return m.print(ansiCyan + "  │ " + line + ansiReset)
+	}
+
+	if strings.HasPrefix(trimmed, "|") {
+		if mdTableSep.MatchString(trimmed) && len(m.table) == 1 {
+			m.header = true
+		} else {
+			m.table = append(m.table, tableCells(trimmed))
+		}
+		return nil
+	}
+	if err := m.flushTable(); err != nil {
+		return err
+	}
+
+	switch {
+	case mdHeading.MatchString(line):
+		match := mdHeading.FindStringSubmatch(line)
+		style := ansiBold
+		if len(match[1]) <= 2 {
+			style += ansiMagenta
+		}
+		if len(match[1]) == 1 {
+			style += ansiUnderline
+		}
+		return m.print(style + inlineMarkdown(match[2], style) + ansiReset)
+	case mdRule.MatchString(line):
+		return m.print(ansiDim + strings.Repeat("─", 40) + ansiReset)
+	case mdBullet.MatchString(line):
+		match := mdBullet.FindStringSubmatch(line)
+		return m.print(match[1] + "  • " + inlineMarkdown(match[2], ""))
+	case strings.HasPrefix(trimmed, ">"):
+		quote := strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))
+		style := ansiDim + ansiItalic
+		return m.print(style + "│ " + inlineMarkdown(quote, style) + ansiReset)
+	}
+
+	return m.print(inlineMarkdown(line, ""))
+}
+
+// flushTable renders the buffered table with its columns aligned.
+func (m *MarkdownWriter) flushTable() error {
+	if len(m.table) == 0 {
+		return nil
+	}
+	rows, header := m.table, m.header
+	m.table, m.header = nil, false
+
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(plainMarkdown(cell)))
+		}
+	}
+
+	for r, row := range rows {
+		var sb strings.Builder
+		for i, width := range widths {
+			var cell string
+			if i < len(row) {
+				cell = row[i]
+			}
+			style := ""
+			if header && r == 0 {
+				style = ansiBold
+			}
+			pad := strings.Repeat(" ", width-utf8.RuneCountInString(plainMarkdown(cell)))
+			sb.WriteString(ansiDim + "│ " + ansiReset + style + inlineMarkdown(cell, style) + ansiReset + pad + " ")
+		}
+		sb.WriteString(ansiDim + "│" + ansiReset)
+		if err := m.print(sb.String()); err != nil {
+			return err
+		}
+		if header && r == 0 {
+			var sep []string
+			for _, width := range widths {
+				sep = append(sep, strings.Repeat("─", width+2))
+			}
+			if err := m.print(ansiDim + "├" + strings.Join(sep, "┼") + "┤" + ansiReset); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *MarkdownWriter) print(s string) error {
+	_, err := io.WriteString(m.w, s+"\n")
+	return err
+}
+
+// tableCells splits a table row into its trimmed cells.
+func tableCells(row string) []string {
+	row = strings.TrimSuffix(strings.TrimPrefix(row, "|"), "|")
+	cells := strings.Split(row, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+
+	return cells
+}
+
+// inlineMarkdown styles the inline code, emphasis and links of s, restoring
+// style, the style of the enclosing block, after each of them.
+func inlineMarkdown(s, style string) string {
+	restore := ansiReset + style
+	// Links go first, as the styles contain brackets.
+	s = mdLink.ReplaceAllString(s, ansiUnderline+"$1"+restore+ansiDim+" ($2)"+restore)
+	s = mdCode.ReplaceAllString(s, ansiCyan+"$1"+restore)
+	s = mdBold.ReplaceAllString(s, ansiBold+"$1$2"+restore)
+
+	return mdItalic.ReplaceAllString(s, ansiItalic+"$1"+restore)
+}
+
+// plainMarkdown returns s as inlineMarkdown displays it, without the styles.
+func plainMarkdown(s string) string {
+	s = mdLink.ReplaceAllString(s, "$1 ($2)")
+	s = mdCode.ReplaceAllString(s, "$1")
+	s = mdBold.ReplaceAllString(s, "$1$2")
+
+	return mdItalic.ReplaceAllString(s, "$1")
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transcript formats.
+const (
+	TranscriptMarkdown = "markdown"
+	TranscriptJSONL    = "jsonl"
+)
+
+// Turn is a finalized exchange of a chat: a user message and the complete
+// reply of the model.
+type Turn struct {
+	Time             time.Time     `json:"time"`
+	Model            string        `json:"model"`
+	User             string        `json:"user"`
+	Assistant        string        `json:"assistant"`
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	Duration         time.Duration `json:"duration"`
+}
+
+// Transcript appends the turns of a chat to a file as they complete, as
+// Markdown or JSON lines, so an interactive session leaves a reusable
+// artifact. Turns of earlier chats in the same file are kept. It is safe for
+// concurrent use.
+type Transcript struct {
+	format string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenTranscript opens the transcript at path for appending, creating it if
+// needed. An empty format is chosen from the extension: JSON lines for .jsonl
+// and .json, Markdown otherwise.
+func OpenTranscript(path, format string) (*Transcript, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".jsonl", ".json":
+			format = TranscriptJSONL
+		default:
+			format = TranscriptMarkdown
+		}
+	}
+	if format != TranscriptMarkdown && format != TranscriptJSONL {
+		return nil, fmt.Errorf("invalid transcript format: %s", format)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transcript{format: format, f: f}, nil
+}
+
+// Add appends turn and syncs it to disk.
+func (t *Transcript) Add(turn Turn) error {
+	var data []byte
+	if t.format == TranscriptJSONL {
+		var err error
+		if data, err = json.Marshal(turn); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	} else {
+		data = []byte(turnMarkdown(turn))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, err := t.f.Write(data); err != nil {
+		return err
+	}
+
+	return t.f.Sync()
+}
+
+// Close closes the transcript file.
+func (t *Transcript) Close() error {
+	return t.f.Close()
+}
+
+// turnMarkdown renders a turn in the layout of Session.Markdown, followed by
+// its model, token counts and duration.
+func turnMarkdown(turn Turn) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## User\n\n%s\n\n", strings.TrimSpace(turn.User))
+	fmt.Fprintf(&sb, "## Assistant\n\n%s\n\n", strings.TrimSpace(turn.Assistant))
+	fmt.Fprintf(&sb, "_%s, %s, %d prompt + %d completion tokens, %s_\n\n",
+		turn.Time.Format(time.RFC3339), turn.Model, turn.PromptTokens, turn.CompletionTokens, turn.Duration.Round(time.Millisecond))
+
+	return sb.String()
+}
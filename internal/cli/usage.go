@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Groupings of a usage report.
+const (
+	UsageByDay      = "day"
+	UsageByModel    = "model"
+	UsageByDocument = "document"
+	UsageByRun      = "run"
+)
+
+// UsageRecord is the usage of a single request.
+type UsageRecord struct {
+	Time             time.Time     `json:"time"`
+	Run              string        `json:"run"`
+	Command          string        `json:"command"`
+	Document         string        `json:"document,omitempty"`
+	Model            string        `json:"model"`
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	Duration         time.Duration `json:"duration"`
+	Cost             float64       `json:"cost,omitempty"`
+}
+
+// Price is the price of a model per million tokens.
+type Price struct {
+	Prompt     float64 `yaml:"prompt"`
+	Completion float64 `yaml:"completion"`
+}
+
+// Cost returns the cost of a request.
+func (p Price) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.Prompt + float64(completionTokens)*p.Completion) / 1e6
+}
+
+// Prices are the prices of models, read from a YAML file:
+//
+//	uniai01:7b: {prompt: 0.10, completion: 0.40}
+//	default: {prompt: 0.50, completion: 1.50}
+//
+// The default entry applies to models without a price.
+type Prices map[string]Price
+
+// For returns the price of a model.
+func (p Prices) For(model string) Price {
+	if price, ok := p[model]; ok {
+		return price
+	}
+
+	return p["default"]
+}
+
+// LoadPrices reads a prices file. A missing file is not an error: usage is
+// then recorded without cost.
+func LoadPrices(path string) (Prices, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Prices{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var prices Prices
+	if err := yaml.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("failed to parse prices %s: %w", path, err)
+	}
+
+	return prices, nil
+}
+
+// DefaultUsageDir returns the directory of the usage ledger and the prices
+// file in the user config directory.
+func DefaultUsageDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "uniai"), nil
+}
+
+// Ledger is an append-only file of usage records, one JSON object per line,
+// so runs of several processes can share it. It is safe for concurrent use.
+type Ledger struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewLedger returns the ledger stored at path.
+func NewLedger(path string) *Ledger {
+	return &Ledger{path: path}
+}
+
+// Add appends a record. A nil ledger ignores the call.
+func (l *Ledger) Add(rec UsageRecord) error {
+	if l == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// ReadLedger returns the records of the ledger at path. A missing ledger has
+// no records; malformed lines, such as one cut short by a crash, are skipped.
+func ReadLedger(path string) ([]UsageRecord, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []UsageRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		records = append(records, rec)
+	}
+
+	return records, scanner.Err()
+}
+
+// UsageTotal is the usage of a group of requests.
+type UsageTotal struct {
+	Key              string        `json:"key"`
+	Requests         int           `json:"requests"`
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	Duration         time.Duration `json:"duration"`
+	Cost             float64       `json:"cost"`
+}
+
+// Add counts a request in the total.
+func (t *UsageTotal) Add(rec UsageRecord) {
+	t.Requests++
+	t.PromptTokens += rec.PromptTokens
+	t.CompletionTokens += rec.CompletionTokens
+	t.Duration += rec.Duration
+	t.Cost += rec.Cost
+}
+
+// SummarizeUsage totals the records by day (in local time), model, document
+// or run, sorted by key.
+func SummarizeUsage(records []UsageRecord, by string) ([]UsageTotal, error) {
+	key := func(rec UsageRecord) string { return rec.Time.Local().Format(time.DateOnly) }
+	switch by {
+	case UsageByDay:
+	case UsageByModel:
+		key = func(rec UsageRecord) string { return rec.Model }
+	case UsageByDocument:
+		key = func(rec UsageRecord) string {
+			if rec.Document == "" {
+				return "(" + rec.Command + ")"
+			}
+			return rec.Document
+		}
+	case UsageByRun:
+		key = func(rec UsageRecord) string { return rec.Run }
+	default:
+		return nil, fmt.Errorf("invalid grouping %q, use day, model, document or run", by)
+	}
+
+	index := make(map[string]int)
+	var totals []UsageTotal
+	for _, rec := range records {
+		k := key(rec)
+		i, ok := index[k]
+		if !ok {
+			i = len(totals)
+			index[k] = i
+			totals = append(totals, UsageTotal{Key: k})
+		}
+		totals[i].Add(rec)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Key < totals[j].Key })
+
+	return totals, nil
+}
@@ -6,46 +6,123 @@ import (
 	"strings"
 )
 
-func ParsePageRange(pageRange string) (pageNumbers []int, err error) {
-	if pageRange == "" {
+// MaxRangePages is the largest number of pages a page range may select, so
+// ranges such as "1-999999999" are rejected instead of exhausting memory.
+const MaxRangePages = 100000
+
+// ParsePageRange parses comma-separated pages and ranges of pages, e.g.
+// "1-3,5,8-9", into page numbers in the given order. Spaces around the items
+// are ignored, pages selected twice are only returned once and an empty
+// range selects no page.
+func ParsePageRange(pageRange string) ([]int, error) {
+	if strings.TrimSpace(pageRange) == "" {
 		return nil, nil
 	}
 
-	if strings.Contains(pageRange, "-") {
-		parts := strings.Split(pageRange, "-")
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid page range format: %s", pageRange)
+	var pageNumbers []int
+	seen := make(map[int]bool)
+	for _, item := range strings.Split(pageRange, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, fmt.Errorf("invalid page range format: %q", pageRange)
 		}
 
-		// Parse the start page
-		start, err := strconv.Atoi(parts[0])
+		startText, endText, isRange := strings.Cut(item, "-")
+		start, err := parsePageNumber(startText)
 		if err != nil {
-			return nil, fmt.Errorf("invalid start page: %s", parts[0])
+			return nil, fmt.Errorf("invalid start page: %w", err)
+		}
+		end := start
+		if isRange {
+			if end, err = parsePageNumber(endText); err != nil {
+				return nil, fmt.Errorf("invalid end page: %w", err)
+			}
+			if end < start {
+				return nil, fmt.Errorf("invalid page range %s: the end is before the start", item)
+			}
+		}
+		if end-start >= MaxRangePages-len(pageNumbers) {
+			return nil, fmt.Errorf("page range %s selects more than %d pages", pageRange, MaxRangePages)
 		}
 
-		// Parse the end page if provided
-		end, err := strconv.Atoi(parts[1])
-		if err != nil {
-			return nil, fmt.Errorf("invalid end page: %s", parts[1])
+		// Counting from start rather than up to end, which would overflow
+		// at the largest int.
+		for i := range end - start + 1 {
+			if page := start + i; !seen[page] {
+				seen[page] = true
+				pageNumbers = append(pageNumbers, page)
+			}
 		}
+	}
+
+	return pageNumbers, nil
+}
+
+// parsePageNumber parses a page number, which starts at 1.
+func parsePageNumber(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || strings.HasPrefix(s, "+") {
+		return 0, fmt.Errorf("%q is not a page number", s)
+	}
 
-		for i := start; i <= end; i++ {
-			pageNumbers = append(pageNumbers, i)
+	return n, nil
+}
+
+// FormatPageRange formats page numbers in the syntax of ParsePageRange,
+// joining runs of consecutive pages into ranges, e.g. "1-3,5". Parsing the
+// result returns pages again if they are positive and distinct.
+func FormatPageRange(pages []int) string {
+	var items []string
+	for i := 0; i < len(pages); {
+		j := i
+		for j+1 < len(pages) && pages[j+1] == pages[j]+1 {
+			j++
+		}
+		if j > i {
+			items = append(items, fmt.Sprintf("%d-%d", pages[i], pages[j]))
+		} else {
+			items = append(items, strconv.Itoa(pages[i]))
 		}
+		i = j + 1
 	}
 
-	// Split the range by ',' to handle multiple ranges
-	if strings.Contains(pageRange, ",") {
-		ranges := strings.Split(pageRange, ",")
+	return strings.Join(items, ",")
+}
 
-		pageNumbers = make([]int, 0, len(ranges))
-		for i, r := range ranges {
-			pageNumbers[i], err = strconv.Atoi(r)
-			if err != nil {
-				return nil, fmt.Errorf("invalid page number: %s", r)
-			}
+// ParseByteSize parses sizes such as "1500000", "800KB", "1.5MB" or "2MiB".
+// Decimal units (KB, MB, GB) are powers of 1000, binary units (KiB, MiB, GiB)
+// powers of 1024.
+func ParseByteSize(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	units := []struct {
+		suffix string
+		factor float64
+	}{
+		{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+		{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9},
+		{"K", 1e3}, {"M", 1e6}, {"G", 1e9},
+		{"B", 1},
+	}
+
+	upper := strings.ToUpper(s)
+	factor := 1.0
+	for _, u := range units {
+		if strings.HasSuffix(upper, u.suffix) {
+			upper = strings.TrimSpace(strings.TrimSuffix(upper, u.suffix))
+			factor = u.factor
+			break
 		}
 	}
 
-	return pageNumbers, nil
+	v, err := strconv.ParseFloat(upper, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+
+	return int(v * factor), nil
 }
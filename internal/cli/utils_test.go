@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestParsePageRange(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []int
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "  ", want: nil},
+		{in: "3", want: []int{3}},
+		{in: "1,2,4", want: []int{1, 2, 4}},
+		{in: "1-3,5,8-9", want: []int{1, 2, 3, 5, 8, 9}},
+		{in: " 4 - 5 , 1 ", want: []int{4, 5, 1}},
+		{in: "2-4,3-6", want: []int{2, 3, 4, 5, 6}},
+		{in: "7-7", want: []int{7}},
+		{in: "9223372036854775806-9223372036854775807", want: []int{math.MaxInt - 1, math.MaxInt}},
+		{in: "1,", wantErr: true},
+		{in: ",1", wantErr: true},
+		{in: "0", wantErr: true},
+		{in: "-1", wantErr: true},
+		{in: "+1", wantErr: true},
+		{in: "a", wantErr: true},
+		{in: "1-", wantErr: true},
+		{in: "3-1", wantErr: true},
+		{in: "1-2-3", wantErr: true},
+		{in: "1-999999999", wantErr: true},
+		{in: "1-99999999999999999999", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePageRange(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePageRange(%q) error = %v, want error %t", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ParsePageRange(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatPageRange(t *testing.T) {
+	tests := []struct {
+		in   []int
+		want string
+	}{
+		{in: nil, want: ""},
+		{in: []int{1, 2, 4}, want: "1-2,4"},
+		{in: []int{1, 2, 3, 5, 8, 9}, want: "1-3,5,8-9"},
+		{in: []int{5, 4, 3}, want: "5,4,3"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatPageRange(tt.in); got != tt.want {
+			t.Errorf("FormatPageRange(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestPageRangeRoundTrip checks that formatting random distinct pages, in a
+// random order, and parsing the result returns the same pages.
+func TestPageRangeRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 1000 {
+		pages := randomPages(r)
+
+		formatted := FormatPageRange(pages)
+		got, err := ParsePageRange(formatted)
+		if err != nil {
+			t.Fatalf("ParsePageRange(FormatPageRange(%v)) = %q: %v", pages, formatted, err)
+		}
+		if !slices.Equal(got, pages) {
+			t.Fatalf("ParsePageRange(FormatPageRange(%v)) = %v, formatted as %q", pages, got, formatted)
+		}
+	}
+}
+
+// randomPages returns up to 50 distinct pages, mostly in runs of consecutive
+// pages, the runs in a random order.
+func randomPages(r *rand.Rand) []int {
+	var pages []int
+	seen := make(map[int]bool)
+	for range r.IntN(10) {
+		start, n := 1+r.IntN(200), 1+r.IntN(5)
+		for page := start; page < start+n; page++ {
+			if !seen[page] {
+				seen[page] = true
+				pages = append(pages, page)
+			}
+		}
+	}
+
+	return pages
+}
+
+// FuzzParsePageRange checks that no input makes ParsePageRange panic, and
+// that the pages of a valid range are positive, distinct, within
+// MaxRangePages and formatted back into an equivalent range.
+func FuzzParsePageRange(f *testing.F) {
+	for _, seed := range []string{"", "1", "1,2,4", "1-3,5,8-9", " 4 - 5 , 1 ", "3-1", "1-", "-", ",", "0", "+1", "1-999999999", "9223372036854775807"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, in string) {
+		pages, err := ParsePageRange(in)
+		if err != nil {
+			return
+		}
+		if len(pages) > MaxRangePages {
+			t.Fatalf("ParsePageRange(%q) selects %d pages, more than %d", in, len(pages), MaxRangePages)
+		}
+		seen := make(map[int]bool, len(pages))
+		for _, page := range pages {
+			if page < 1 || seen[page] {
+				t.Fatalf("ParsePageRange(%q) = %v, pages must be positive and distinct", in, pages)
+			}
+			seen[page] = true
+		}
+
+		formatted := FormatPageRange(pages)
+		again, err := ParsePageRange(formatted)
+		if err != nil {
+			t.Fatalf("ParsePageRange(%q) = %v, formatted as %q which fails to parse: %v", in, pages, formatted, err)
+		}
+		if !slices.Equal(again, pages) {
+			t.Fatalf("ParsePageRange(%q) = %v, formatted as %q which parses as %v", in, pages, formatted, again)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+// Package queue distributes document jobs between uniai instances through
+// lists of a Redis server (redis://[user:password@]host:port[/db], or
+// rediss:// over TLS).
+//
+// Producers push jobs onto <name>:jobs. Each worker atomically moves the job
+// it takes to its own <name>:processing:<worker> list and removes it from
+// there once its result is pushed onto <name>:results, so the jobs of a
+// worker that crashed are taken again when it restarts.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/sampila/uniai-client/internal/cli"
+)
+
+// Supported URL schemes.
+const (
+	SchemeRedis    = "redis"
+	SchemeRedisTLS = "rediss"
+)
+
+// DefaultName is the name of the queue if the URL has no ?name= parameter.
+const DefaultName = "uniai"
+
+// popTimeout bounds blocking pops, so canceled workers stop in time.
+const popTimeout = 5 * time.Second
+
+// Message is a job in the queue.
+type Message struct {
+	ID          string      `json:"id"`
+	Spec        cli.JobSpec `json:"spec"`
+	SubmittedAt time.Time   `json:"submitted_at"`
+
+	raw string // message as stored in the list, to remove it once done
+}
+
+// Result is the outcome of a job, pushed onto the results list.
+type Result struct {
+	cli.JobState
+	Worker string `json:"worker"`
+}
+
+// Queue is a connection to a job queue.
+type Queue struct {
+	conn *redisConn
+	name string
+}
+
+// Open connects to the queue at a redis:// or rediss:// URL.
+func Open(ctx context.Context, location string) (*Queue, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid queue URL %q: %w", location, err)
+	}
+	if u.Scheme != SchemeRedis && u.Scheme != SchemeRedisTLS {
+		return nil, fmt.Errorf("unsupported queue URL scheme %q", u.Scheme)
+	}
+
+	conn, err := dialRedis(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	name := u.Query().Get("name")
+	if name == "" {
+		name = DefaultName
+	}
+
+	return &Queue{conn: conn, name: name}, nil
+}
+
+// Close closes the connection.
+func (q *Queue) Close() error {
+	return q.conn.Close()
+}
+
+// Push adds a job to the queue and returns its message.
+func (q *Queue) Push(ctx context.Context, spec cli.JobSpec) (*Message, error) {
+	msg := &Message{ID: cli.NewJobID(), Spec: spec, SubmittedAt: time.Now().UTC()}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := q.conn.do(ctx, "LPUSH", q.name+":jobs", string(data)); err != nil {
+		return nil, fmt.Errorf("failed to push job: %w", err)
+	}
+
+	return msg, nil
+}
+
+// Pop waits for the next job and hands it to worker. It returns nil once ctx
+// is canceled.
+func (q *Queue) Pop(ctx context.Context, worker string) (*Message, error) {
+	for ctx.Err() == nil {
+		reply, err := q.conn.do(ctx, "BRPOPLPUSH", q.name+":jobs", q.processing(worker), fmt.Sprint(popTimeout.Seconds()))
+		if err == errNil {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to pop job: %w", err)
+		}
+
+		raw, _ := reply.(string)
+		var msg Message
+		if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+			// Drop malformed messages rather than retrying them forever.
+			q.conn.do(ctx, "LREM", q.processing(worker), "1", raw)
+			return nil, fmt.Errorf("invalid job %q: %w", raw, err)
+		}
+		msg.raw = raw
+
+		return &msg, nil
+	}
+
+	return nil, nil
+}
+
+// Complete pushes the result of a job and removes it from the jobs of worker.
+func (q *Queue) Complete(ctx context.Context, worker string, msg *Message, state cli.JobState) error {
+	data, err := json.Marshal(Result{JobState: state, Worker: worker})
+	if err != nil {
+		return err
+	}
+
+	if _, err := q.conn.do(ctx, "LPUSH", q.name+":results", string(data)); err != nil {
+		return fmt.Errorf("failed to push result of job %s: %w", msg.ID, err)
+	}
+	if _, err := q.conn.do(ctx, "LREM", q.processing(worker), "1", msg.raw); err != nil {
+		return fmt.Errorf("failed to remove job %s: %w", msg.ID, err)
+	}
+
+	return nil
+}
+
+// Requeue moves the jobs a previous run of worker left unfinished back to the
+// queue and returns their number.
+func (q *Queue) Requeue(ctx context.Context, worker string) (int, error) {
+	n := 0
+	for {
+		_, err := q.conn.do(ctx, "RPOPLPUSH", q.processing(worker), q.name+":jobs")
+		if err == errNil {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("failed to requeue jobs: %w", err)
+		}
+		n++
+	}
+}
+
+// Results returns the results pushed so far, most recent first, without
+// removing them.
+func (q *Queue) Results(ctx context.Context) ([]Result, error) {
+	reply, err := q.conn.do(ctx, "LRANGE", q.name+":results", "0", "-1")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results: %w", err)
+	}
+
+	items, _ := reply.([]any)
+	results := make([]Result, 0, len(items))
+	for _, item := range items {
+		var r Result
+		if s, ok := item.(string); ok && json.Unmarshal([]byte(s), &r) == nil {
+			results = append(results, r)
+		}
+	}
+
+	return results, nil
+}
+
+func (q *Queue) processing(worker string) string {
+	return q.name + ":processing:" + worker
+}
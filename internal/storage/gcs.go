@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// gcsBaseURL is the endpoint of the Cloud Storage JSON API. It can be
+// overridden with STORAGE_EMULATOR_HOST, as with the official clients.
+func gcsBaseURL() string {
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		return strings.TrimSuffix(host, "/")
+	}
+
+	return "https://storage.googleapis.com"
+}
+
+// gcsToken resolves an OAuth access token for Cloud Storage, in order: the
+// GOOGLE_OAUTH_ACCESS_TOKEN variable, the metadata server of the instance
+// the CLI runs on, and the gcloud CLI. An empty token is returned when none
+// is available, so public objects can still be read.
+func gcsToken(ctx context.Context) string {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token
+	}
+	if token, err := metadataToken(ctx); err == nil {
+		return token
+	}
+	if out, err := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token").Output(); err == nil {
+		return strings.TrimSpace(string(out))
+	}
+
+	return ""
+}
+
+// metadataToken fetches the token of the default service account from the
+// GCE metadata server.
+func metadataToken(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return "", err
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode metadata token: %w", err)
+	}
+
+	return token.AccessToken, nil
+}
+
+// newGCSDownloadRequest returns an authorized request for the content of an
+// object.
+func newGCSDownloadRequest(ctx context.Context, bucket, object string) (*http.Request, error) {
+	target := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media",
+		gcsBaseURL(), url.PathEscape(bucket), url.PathEscape(object))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := gcsToken(ctx); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return req, nil
+}
+
+// newGCSUploadRequest returns an authorized simple upload request storing
+// body as an object.
+func newGCSUploadRequest(ctx context.Context, bucket, object string, body io.Reader, size int64) (*http.Request, error) {
+	target := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s",
+		gcsBaseURL(), url.PathEscape(bucket), url.QueryEscape(object))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType(object))
+	if token := gcsToken(ctx); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return req, nil
+}
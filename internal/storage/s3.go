@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// unsignedPayload lets S3 requests stream bodies without hashing them first.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// s3Credentials are the AWS credentials and region used to sign requests.
+type s3Credentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	region       string
+	endpoint     string // custom endpoint of S3-compatible storage, addressed path-style
+}
+
+// resolveS3Credentials reads the credentials from the standard AWS
+// environment variables, falling back to the profile (AWS_PROFILE or
+// "default") of the shared credentials file.
+func resolveS3Credentials() (s3Credentials, error) {
+	creds := s3Credentials{
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		region:       os.Getenv("AWS_REGION"),
+		endpoint:     strings.TrimSuffix(os.Getenv("AWS_ENDPOINT_URL"), "/"),
+	}
+	if creds.region == "" {
+		creds.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if creds.region == "" {
+		creds.region = "us-east-1"
+	}
+
+	if creds.accessKey == "" || creds.secretKey == "" {
+		profile, err := readSharedCredentials()
+		if err != nil {
+			return s3Credentials{}, err
+		}
+		creds.accessKey = profile["aws_access_key_id"]
+		creds.secretKey = profile["aws_secret_access_key"]
+		creds.sessionToken = profile["aws_session_token"]
+	}
+	if creds.accessKey == "" || creds.secretKey == "" {
+		return s3Credentials{}, errors.New("no AWS credentials found: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY or configure ~/.aws/credentials")
+	}
+
+	return creds, nil
+}
+
+// readSharedCredentials returns the keys of the selected profile of the AWS
+// shared credentials file, or nil if the file does not exist.
+func readSharedCredentials() (map[string]string, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		keys    = make(map[string]string)
+		current string
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			current = strings.TrimSpace(line[1 : len(line)-1])
+		case current == profile:
+			if k, v, ok := strings.Cut(line, "="); ok {
+				keys[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+		}
+	}
+
+	return keys, scanner.Err()
+}
+
+// newS3Request returns a signed request for an object. Bodies are sent
+// unsigned, so size must be given for uploads.
+func newS3Request(ctx context.Context, method, bucket, key, mimeType string, body io.Reader, size int64) (*http.Request, error) {
+	creds, err := resolveS3Credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	var target string
+	if creds.endpoint != "" {
+		target = creds.endpoint + "/" + bucket + "/" + escapePath(key)
+	} else {
+		target = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, creds.region, escapePath(key))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if mimeType != "" {
+		req.Header.Set("Content-Type", mimeType)
+	}
+
+	signS3(req, creds, time.Now().UTC())
+
+	return req, nil
+}
+
+// signS3 adds an AWS Signature Version 4 authorization header to req.
+func signS3(req *http.Request, creds s3Credentials, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	scope := date + "/" + creds.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hashHex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretKey), date)
+	key = hmacSHA256(key, creds.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hashHex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// escapePath percent-encodes an object key as required by SigV4, keeping the
+// slashes between segments.
+func escapePath(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
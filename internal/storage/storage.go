@@ -0,0 +1,215 @@
+// Package storage reads and writes documents at remote locations: HTTP(S)
+// URLs and objects in Amazon S3 (s3://bucket/key) or Google Cloud Storage
+// (gs://bucket/object).
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Supported URL schemes.
+const (
+	SchemeHTTP  = "http"
+	SchemeHTTPS = "https"
+	SchemeS3    = "s3"
+	SchemeGCS   = "gs"
+)
+
+// httpClient is used for all remote requests. It has no overall timeout, as
+// documents may be large; requests are bounded by their context instead.
+var httpClient = &http.Client{}
+
+// IsRemote reports whether location is a URL of a supported remote source
+// rather than a local path.
+func IsRemote(location string) bool {
+	u, err := url.Parse(location)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case SchemeHTTP, SchemeHTTPS, SchemeS3, SchemeGCS:
+		return u.Host != ""
+	}
+
+	return false
+}
+
+// BaseName returns the file name of the object at a remote location.
+func BaseName(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return path.Base(location)
+	}
+
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		return u.Host
+	}
+
+	return name
+}
+
+// Open starts reading the object at a remote location. The caller must close
+// the returned reader.
+func Open(ctx context.Context, location string) (io.ReadCloser, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", location, err)
+	}
+
+	var req *http.Request
+	switch u.Scheme {
+	case SchemeHTTP, SchemeHTTPS:
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	case SchemeS3:
+		req, err = newS3Request(ctx, http.MethodGet, u.Host, strings.TrimPrefix(u.Path, "/"), "", nil, 0)
+	case SchemeGCS:
+		req, err = newGCSDownloadRequest(ctx, u.Host, strings.TrimPrefix(u.Path, "/"))
+	default:
+		return nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", location, err)
+	}
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download %s: %w", location, err)
+	}
+
+	return resp.Body, nil
+}
+
+// Download streams the object at a remote location into a new temporary
+// directory, keeping its file name, and returns the local path. The caller
+// should remove the directory once done.
+func Download(ctx context.Context, location string) (string, error) {
+	body, err := Open(ctx, location)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	dir, err := os.MkdirTemp("", "uniai-input-*")
+	if err != nil {
+		return "", err
+	}
+
+	local := filepath.Join(dir, BaseName(location))
+	f, err := os.Create(local)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to download %s: %w", location, err)
+	}
+	if err := f.Close(); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	return local, nil
+}
+
+// checkResponse returns an error with the start of the body for non-2xx
+// responses.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if len(msg) == 0 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// CanUpload reports whether location is an object storage URL results can be
+// uploaded to.
+func CanUpload(location string) bool {
+	u, err := url.Parse(location)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	return u.Scheme == SchemeS3 || u.Scheme == SchemeGCS
+}
+
+// Join appends a slash-separated relative path to a remote location.
+func Join(location, rel string) string {
+	return strings.TrimSuffix(location, "/") + "/" + strings.TrimPrefix(filepath.ToSlash(rel), "/")
+}
+
+// Upload stores the local file at path as the object at a remote location.
+func Upload(ctx context.Context, location, path string) error {
+	u, err := url.Parse(location)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", location, err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	var req *http.Request
+	object := strings.TrimPrefix(u.Path, "/")
+	switch u.Scheme {
+	case SchemeS3:
+		req, err = newS3Request(ctx, http.MethodPut, u.Host, object, contentType(object), f, info.Size())
+	case SchemeGCS:
+		req, err = newGCSUploadRequest(ctx, u.Host, object, f, info.Size())
+	default:
+		return fmt.Errorf("cannot upload to %q URLs", u.Scheme)
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", location, err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", location, err)
+	}
+
+	return nil
+}
+
+// contentType returns the MIME type of a file name, defaulting to binary
+// data.
+func contentType(name string) string {
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+
+	return "application/octet-stream"
+}
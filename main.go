@@ -1,18 +1,13 @@
 package main
 
 import (
-	"github.com/sampila/uniai-client/cmd"
-	"github.com/unidoc/unipdf/v4/common/license"
 	"os"
-)
 
-func init() {
-	err := license.SetMeteredKey(os.Getenv("UNIDOC_LICENSE_API_KEY_DEV"))
-	if err != nil {
-		panic(err)
-	}
-}
+	"github.com/sampila/uniai-client/cmd"
+)
 
 func main() {
-	cmd.Execute()
+	if err := cmd.Execute(); err != nil {
+		os.Exit(cmd.ExitCode(err))
+	}
 }
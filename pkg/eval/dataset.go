@@ -0,0 +1,130 @@
+// Package eval scores model outputs against expected results, so that prompt
+// and model changes can be compared on a fixed dataset of documents.
+package eval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Dataset is a set of documents with their expected outputs. Settings of the
+// dataset apply to every case that does not set its own.
+type Dataset struct {
+	Name   string `yaml:"name"`
+	Prompt string `yaml:"prompt"`
+	Task   string `yaml:"task"` // task preset used instead of the prompt
+	Model  string `yaml:"model"`
+	Cases  []Case `yaml:"cases"`
+
+	// TextFirst sends the text layer of pages that have one instead of
+	// rendering them.
+	TextFirst bool `yaml:"text_first"`
+}
+
+// Case is a document, the pages to process and the expected output: a string
+// compared as text, or a mapping or list compared field by field with the
+// JSON answer of the model.
+type Case struct {
+	Name     string `yaml:"name"`
+	File     string `yaml:"file"`
+	Pages    string `yaml:"pages"`
+	Prompt   string `yaml:"prompt"`
+	Task     string `yaml:"task"`
+	Expected any    `yaml:"expected"`
+
+	// ExpectedFile is read into Expected; .json files are parsed.
+	ExpectedFile string `yaml:"expected_file"`
+}
+
+// Structured reports whether the expected output is compared field by field.
+func (c *Case) Structured() bool {
+	switch c.Expected.(type) {
+	case map[string]any, []any:
+		return true
+	}
+
+	return false
+}
+
+// LoadDataset reads a YAML (or JSON) dataset. Files are relative to the
+// directory of the dataset.
+func LoadDataset(path string) (*Dataset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ds Dataset
+	if err := yaml.Unmarshal(data, &ds); err != nil {
+		return nil, fmt.Errorf("failed to parse dataset %s: %w", path, err)
+	}
+	if len(ds.Cases) == 0 {
+		return nil, errors.New("dataset has no cases")
+	}
+	if ds.Name == "" {
+		ds.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	dir := filepath.Dir(path)
+	for i := range ds.Cases {
+		c := &ds.Cases[i]
+		if c.File == "" {
+			return nil, fmt.Errorf("case %d has no file", i+1)
+		}
+		if c.Name == "" {
+			c.Name = filepath.Base(c.File)
+		}
+		c.File = resolve(dir, c.File)
+		if c.Prompt == "" {
+			c.Prompt = ds.Prompt
+		}
+		if c.Task == "" {
+			c.Task = ds.Task
+		}
+		if c.Prompt == "" && c.Task == "" {
+			return nil, fmt.Errorf("case %s has neither a prompt nor a task", c.Name)
+		}
+
+		if c.ExpectedFile != "" {
+			if c.Expected, err = readExpected(resolve(dir, c.ExpectedFile)); err != nil {
+				return nil, fmt.Errorf("case %s: %w", c.Name, err)
+			}
+		}
+		if c.Expected == nil {
+			return nil, fmt.Errorf("case %s has no expected output", c.Name)
+		}
+	}
+
+	return &ds, nil
+}
+
+func resolve(dir, file string) string {
+	if filepath.IsAbs(file) || strings.Contains(file, "://") {
+		return file
+	}
+
+	return filepath.Join(dir, file)
+}
+
+func readExpected(path string) (any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".json") {
+		return string(data), nil
+	}
+
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return v, nil
+}
@@ -0,0 +1,88 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// Result is the outcome of one case.
+type Result struct {
+	Case     string        `json:"case"`
+	File     string        `json:"file"`
+	Output   string        `json:"output,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Score    Score         `json:"score"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Report collects the results of a dataset run.
+type Report struct {
+	Dataset   string    `json:"dataset"`
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	Results   []Result  `json:"results"`
+
+	// Mean is the average score over all cases; failed cases score 0.
+	Mean Score `json:"mean"`
+}
+
+// Add appends a result and updates the mean score.
+func (r *Report) Add(res Result) {
+	r.Results = append(r.Results, res)
+
+	n := float64(len(r.Results))
+	var sum Score
+	for _, res := range r.Results {
+		sum.Exact += res.Score.Exact
+		sum.F1 += res.Score.F1
+		sum.Precision += res.Score.Precision
+		sum.Recall += res.Score.Recall
+		sum.Similarity += res.Score.Similarity
+	}
+	r.Mean = Score{
+		Exact:      sum.Exact / n,
+		F1:         sum.F1 / n,
+		Precision:  sum.Precision / n,
+		Recall:     sum.Recall / n,
+		Similarity: sum.Similarity / n,
+	}
+}
+
+// WriteText writes the report as a table, one case per row.
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "CASE\tEXACT\tF1\tPRECISION\tRECALL\tSIMILARITY\tTIME\n")
+	for _, res := range r.Results {
+		fmt.Fprintf(tw, "%s\t%s\n", res.Case, formatScore(res.Score, res.Duration, res.Error))
+	}
+	fmt.Fprintf(tw, "MEAN (%d cases)\t%s\n", len(r.Results), formatScore(r.Mean, 0, ""))
+
+	return tw.Flush()
+}
+
+func formatScore(s Score, d time.Duration, errMsg string) string {
+	if errMsg != "" {
+		return "error: " + errMsg
+	}
+
+	elapsed := ""
+	if d > 0 {
+		elapsed = d.Round(time.Millisecond).String()
+	}
+
+	return fmt.Sprintf("%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%s", s.Exact, s.F1, s.Precision, s.Recall, s.Similarity, elapsed)
+}
+
+// WriteJSON writes the report as indented JSON to path.
+func (r *Report) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,257 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+	"github.com/sampila/uniai-client/pkg/uniai/jsonrepair"
+)
+
+// maxEditCells bounds the size of the edit distance matrix; longer texts are
+// compared word by word instead of character by character.
+const maxEditCells = 10_000_000
+
+// Score holds the metrics of one output, each between 0 and 1.
+type Score struct {
+	// Exact is 1 when the normalized output equals the expected one.
+	Exact float64 `json:"exact"`
+	// F1 is the field-level F1 of structured outputs, or the word-level F1
+	// of text outputs.
+	F1        float64 `json:"f1"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	// Similarity is one minus the normalized edit distance.
+	Similarity float64 `json:"similarity"`
+}
+
+// ScoreText compares a text output with the expected text. Whitespace and
+// case are ignored.
+func ScoreText(expected, actual string) Score {
+	expected, actual = normalize(expected), normalize(actual)
+
+	var s Score
+	if expected == actual {
+		s.Exact = 1
+	}
+	s.Precision, s.Recall, s.F1 = f1(wordCounts(expected), wordCounts(actual))
+	s.Similarity = Similarity(expected, actual)
+
+	return s
+}
+
+// ScoreJSON compares the JSON answer of the model with the expected value.
+// Fields are compared by path, so {"a": {"b": 1}} has the single field
+// "a.b"; list items are compared by position. An answer that is not valid
+// JSON scores 0 on exact match and F1.
+func ScoreJSON(expected any, actual string) Score {
+	want := flatten(expected)
+	wantText := canonical(expected)
+
+	value, err := ParseJSON(actual)
+	if err != nil {
+		return Score{Similarity: Similarity(wantText, normalize(actual))}
+	}
+	got := flatten(value)
+	gotText := canonical(value)
+
+	var s Score
+	if wantText == gotText {
+		s.Exact = 1
+	}
+	matched := 0
+	for path, v := range got {
+		if want[path] == v {
+			matched++
+		}
+	}
+	s.Precision, s.Recall, s.F1 = ratios(matched, len(got), len(want))
+	s.Similarity = Similarity(wantText, gotText)
+
+	return s
+}
+
+// ParseJSON parses a JSON answer, optionally wrapped in a Markdown code fence
+// or surrounded by text. Common defects such as trailing commas or unquoted
+// keys are repaired.
+func ParseJSON(answer string) (any, error) {
+	text := strings.TrimSpace(uniai.ApplyFilters(answer, uniai.DefaultFilters...))
+
+	var v any
+	if err := jsonrepair.Unmarshal([]byte(text), &v); err != nil {
+		return nil, fmt.Errorf("answer is not valid JSON: %w", err)
+	}
+
+	return v, nil
+}
+
+// Similarity returns one minus the edit distance of a and b divided by the
+// length of the longer one.
+func Similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+
+	var dist, length int
+	if len(ra)*len(rb) <= maxEditCells {
+		dist, length = editDistance(ra, rb), max(len(ra), len(rb))
+	} else {
+		wa, wb := strings.Fields(a), strings.Fields(b)
+		dist, length = editDistance(wa, wb), max(len(wa), len(wb))
+	}
+
+	return 1 - float64(dist)/float64(length)
+}
+
+func editDistance[T comparable](a, b []T) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+// normalize lowercases s and collapses whitespace.
+func normalize(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+func wordCounts(s string) map[string]int {
+	counts := make(map[string]int)
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		counts[w]++
+	}
+
+	return counts
+}
+
+func f1(want, got map[string]int) (precision, recall, f float64) {
+	matched, nWant, nGot := 0, 0, 0
+	for w, n := range want {
+		matched += min(n, got[w])
+		nWant += n
+	}
+	for _, n := range got {
+		nGot += n
+	}
+
+	return ratios(matched, nGot, nWant)
+}
+
+func ratios(matched, got, want int) (precision, recall, f float64) {
+	if got == 0 && want == 0 {
+		return 1, 1, 1
+	}
+	if got > 0 {
+		precision = float64(matched) / float64(got)
+	}
+	if want > 0 {
+		recall = float64(matched) / float64(want)
+	}
+	if precision+recall > 0 {
+		f = 2 * precision * recall / (precision + recall)
+	}
+
+	return precision, recall, f
+}
+
+// flatten maps the path of every leaf of v to its normalized value.
+func flatten(v any) map[string]string {
+	fields := make(map[string]string)
+	var walk func(path string, v any)
+	walk = func(path string, v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, child := range v {
+				walk(joinPath(path, normalize(k)), child)
+			}
+		case []any:
+			for i, child := range v {
+				walk(joinPath(path, strconv.Itoa(i)), child)
+			}
+		default:
+			fields[path] = leaf(v)
+		}
+	}
+	walk("", v)
+
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// leaf formats a scalar so that equal values from YAML and JSON compare
+// equal, e.g. the integer 3 and the float 3.0.
+func leaf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return normalize(v)
+	case int:
+		return strconv.FormatFloat(float64(v), 'g', -1, 64)
+	case int64:
+		return strconv.FormatFloat(float64(v), 'g', -1, 64)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+
+	return normalize(fmt.Sprint(v))
+}
+
+// canonical formats v as sorted path=value lines, the form compared for exact
+// match and similarity.
+func canonical(v any) string {
+	fields := flatten(v)
+	lines := make([]string, 0, len(fields))
+	for path, value := range fields {
+		lines = append(lines, path+"="+value)
+	}
+	sort.Strings(lines)
+
+	return strings.Join(lines, "\n")
+}
+
+// JoinJSON combines the JSON answers of several pages into a list, the form
+// expected of multi-page cases. Answers that are not valid JSON are kept as
+// strings.
+func JoinJSON(answers []string) string {
+	values := make([]any, len(answers))
+	for i, answer := range answers {
+		v, err := ParseJSON(answer)
+		if err != nil {
+			v = answer
+		}
+		values[i] = v
+	}
+
+	data, _ := json.Marshal(values)
+	return string(data)
+}
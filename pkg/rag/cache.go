@@ -0,0 +1,112 @@
+package rag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// cacheFile is the name of the embedding cache in a store directory.
+const cacheFile = "embeddings.json"
+
+// EmbeddingCache keeps the embeddings of chunk texts by embedding model and
+// SHA-256 of the text, so re-indexing a mostly unchanged corpus only embeds
+// the new and changed chunks. It is kept next to the store index.
+type EmbeddingCache struct {
+	dir string
+
+	// Vectors holds the normalized embeddings by model, then text hash.
+	Vectors map[string]map[string][]float32 `json:"vectors"`
+
+	hits, misses int
+}
+
+// OpenEmbeddingCache loads the embedding cache of the store in dir, or
+// returns an empty one if it does not exist yet.
+func OpenEmbeddingCache(dir string) (*EmbeddingCache, error) {
+	c := &EmbeddingCache{dir: dir}
+
+	data, err := os.ReadFile(filepath.Join(dir, cacheFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("failed to parse embedding cache: %w", err)
+		}
+	}
+	if c.Vectors == nil {
+		c.Vectors = make(map[string]map[string][]float32)
+	}
+
+	return c, nil
+}
+
+// Get returns the cached embedding of text by model.
+func (c *EmbeddingCache) Get(model, text string) ([]float32, bool) {
+	v, ok := c.Vectors[model][hashText(text)]
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+
+	return v, ok
+}
+
+// Put caches the embedding of text by model.
+func (c *EmbeddingCache) Put(model, text string, vector []float32) {
+	if c.Vectors[model] == nil {
+		c.Vectors[model] = make(map[string][]float32)
+	}
+	c.Vectors[model][hashText(text)] = vector
+}
+
+// Stats returns the number of embeddings found in and missing from the cache
+// so far.
+func (c *EmbeddingCache) Stats() (hits, misses int) {
+	return c.hits, c.misses
+}
+
+// Prune removes the embeddings of texts no longer in store, and returns their
+// number.
+func (c *EmbeddingCache) Prune(store *Store) int {
+	used := make(map[string]bool, len(store.Chunks))
+	for _, chunk := range store.Chunks {
+		used[hashText(chunk.Text)] = true
+	}
+
+	removed := 0
+	for model, vectors := range c.Vectors {
+		for hash := range vectors {
+			if model != store.Model || !used[hash] {
+				delete(vectors, hash)
+				removed++
+			}
+		}
+		if len(vectors) == 0 {
+			delete(c.Vectors, model)
+		}
+	}
+
+	return removed
+}
+
+// Save writes the cache to disk, replacing the previous file atomically.
+func (c *EmbeddingCache) Save() error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(c.dir, cacheFile, data)
+}
+
+func hashText(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
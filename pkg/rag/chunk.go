@@ -0,0 +1,73 @@
+package rag
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Page is the text of a single page of a document to index.
+type Page struct {
+	Number int
+	Text   string
+}
+
+// Chunk is a passage of a document stored with its embedding.
+type Chunk struct {
+	ID     string    `json:"id"`
+	Source string    `json:"source"`
+	Page   int       `json:"page"`
+	Index  int       `json:"index"` // position of the chunk within the page
+	Text   string    `json:"text"`
+	Vector []float32 `json:"vector"`
+}
+
+// SplitText splits text into passages of at most size characters, breaking
+// between words. Consecutive passages share about overlap characters so that
+// sentences cut at a boundary are still retrievable from one of them.
+func SplitText(text string, size, overlap int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil
+	}
+	if size <= 0 {
+		return []string{strings.Join(words, " ")}
+	}
+	overlap = min(max(overlap, 0), size/2)
+
+	var (
+		chunks []string
+		start  int
+	)
+	for start < len(words) {
+		end, length := start, 0
+		for end < len(words) {
+			n := utf8.RuneCountInString(words[end])
+			if length > 0 && length+1+n > size {
+				break
+			}
+			if length > 0 {
+				length++
+			}
+			length += n
+			end++
+		}
+		chunks = append(chunks, strings.Join(words[start:end], " "))
+		if end == len(words) {
+			break
+		}
+
+		// Step back over the words of the overlap, always making progress.
+		next, back := end, 0
+		for next > start+1 {
+			n := utf8.RuneCountInString(words[next-1]) + 1
+			if back+n > overlap {
+				break
+			}
+			back += n
+			next--
+		}
+		start = next
+	}
+
+	return chunks
+}
@@ -0,0 +1,254 @@
+// Package rag implements retrieval-augmented generation on top of the UniAI
+// client: documents are split into chunks, embedded and stored, and questions
+// are answered from the most similar chunks with citations.
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// Options configures indexing and querying.
+type Options struct {
+	// EmbedModel is the model used for embeddings.
+	EmbedModel string
+	// Model is the model generating answers.
+	Model string
+
+	ChunkSize    int // maximum chunk length in characters
+	ChunkOverlap int // characters shared by consecutive chunks
+	BatchSize    int // chunks embedded per request
+	TopK         int // chunks retrieved per question
+}
+
+// DefaultOptions are reasonable options for most documents.
+var DefaultOptions = Options{
+	EmbedModel:   uniai.ModelDefault,
+	Model:        uniai.ModelDefault,
+	ChunkSize:    1000,
+	ChunkOverlap: 200,
+	BatchSize:    16,
+	TopK:         5,
+}
+
+// RAG indexes documents into a store and answers questions from it.
+type RAG struct {
+	client *uniai.Client
+	store  *Store
+	cache  *EmbeddingCache
+	opts   Options
+}
+
+// Citation is a retrieved chunk referenced by an answer as [Number].
+type Citation struct {
+	Number int
+	Result
+}
+
+// Answer is the answer to a question with the chunks it was based on.
+type Answer struct {
+	Text      string
+	Citations []Citation
+}
+
+// New returns a RAG using client and store. Zero options are replaced by
+// their defaults.
+func New(client *uniai.Client, store *Store, opts Options) *RAG {
+	if opts.EmbedModel == "" {
+		opts.EmbedModel = DefaultOptions.EmbedModel
+	}
+	if opts.Model == "" {
+		opts.Model = DefaultOptions.Model
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultOptions.ChunkSize
+	}
+	if opts.ChunkOverlap < 0 {
+		opts.ChunkOverlap = DefaultOptions.ChunkOverlap
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultOptions.BatchSize
+	}
+	if opts.TopK <= 0 {
+		opts.TopK = DefaultOptions.TopK
+	}
+
+	return &RAG{client: client, store: store, opts: opts}
+}
+
+// UseCache makes indexing reuse the embeddings of cache and add the new ones
+// to it. The cache is not saved.
+func (r *RAG) UseCache(cache *EmbeddingCache) {
+	r.cache = cache
+}
+
+// Index chunks and embeds the pages of source and replaces its previous
+// chunks in the store. The store is not saved. It returns the number of
+// chunks indexed.
+func (r *RAG) Index(ctx context.Context, source string, pages []Page) (int, error) {
+	if r.store.Model != "" && r.store.Model != r.opts.EmbedModel && len(r.store.Chunks) > 0 {
+		return 0, fmt.Errorf("store was indexed with embedding model %q, not %q", r.store.Model, r.opts.EmbedModel)
+	}
+
+	var chunks []Chunk
+	for _, page := range pages {
+		for i, text := range SplitText(page.Text, r.opts.ChunkSize, r.opts.ChunkOverlap) {
+			chunks = append(chunks, Chunk{
+				ID:     fmt.Sprintf("%s#p%d-%d", source, page.Number, i),
+				Source: source,
+				Page:   page.Number,
+				Index:  i,
+				Text:   text,
+			})
+		}
+	}
+
+	// Only chunks missing from the cache are embedded.
+	var missing []*Chunk
+	for i := range chunks {
+		if r.cache != nil {
+			if v, ok := r.cache.Get(r.opts.EmbedModel, chunks[i].Text); ok {
+				chunks[i].Vector = v
+				continue
+			}
+		}
+		missing = append(missing, &chunks[i])
+	}
+
+	for start := 0; start < len(missing); start += r.opts.BatchSize {
+		batch := missing[start:min(start+r.opts.BatchSize, len(missing))]
+		input := make([]string, len(batch))
+		for i, c := range batch {
+			input[i] = c.Text
+		}
+
+		vectors, err := r.embed(ctx, input)
+		if err != nil {
+			return 0, err
+		}
+		for i, c := range batch {
+			c.Vector = normalize(vectors[i])
+			if r.cache != nil {
+				r.cache.Put(r.opts.EmbedModel, c.Text, c.Vector)
+			}
+		}
+	}
+
+	r.store.Model = r.opts.EmbedModel
+	r.store.Replace(source, chunks)
+
+	return len(chunks), nil
+}
+
+// embedEach embeds every input with its own request to the older endpoint
+// of the API.
+func (r *RAG) embedEach(ctx context.Context, input []string) ([][]float32, error) {
+	vectors := make([][]float32, len(input))
+	for i, text := range input {
+		resp, err := r.client.Embeddings(ctx, &uniai.EmbeddingRequest{Model: r.opts.EmbedModel, Prompt: text})
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed: %w", err)
+		}
+		vectors[i] = make([]float32, len(resp.Embedding))
+		for j, v := range resp.Embedding {
+			vectors[i][j] = float32(v)
+		}
+	}
+
+	return vectors, nil
+}
+
+// Retrieve returns the chunks most relevant to question.
+func (r *RAG) Retrieve(ctx context.Context, question string) ([]Result, error) {
+	if len(r.store.Chunks) == 0 {
+		return nil, fmt.Errorf("store is empty, index documents first")
+	}
+
+	vectors, err := r.embed(ctx, []string{question})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.store.Search(vectors[0], r.opts.TopK), nil
+}
+
+// Query answers question from the retrieved chunks, sent to the chat
+// endpoint as context messages. If fn is not nil, it is called with every
+// streamed part of the answer.
+func (r *RAG) Query(ctx context.Context, question string, fn func(text string)) (*Answer, error) {
+	results, err := r.Retrieve(ctx, question)
+	if err != nil {
+		return nil, err
+	}
+
+	answer := &Answer{}
+	for i, res := range results {
+		answer.Citations = append(answer.Citations, Citation{Number: i + 1, Result: res})
+	}
+
+	var sb strings.Builder
+	err = r.client.Chat(ctx, &uniai.ChatRequest{
+		Model:    r.opts.Model,
+		Messages: QueryMessages(question, answer.Citations),
+		Options:  uniai.DefaultOptions,
+	}, func(resp uniai.ChatResponse) error {
+		sb.WriteString(resp.Message.Content)
+		if fn != nil {
+			fn(resp.Message.Content)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate answer: %w", err)
+	}
+	answer.Text = sb.String()
+
+	return answer, nil
+}
+
+// queryInstructions is the system message of the chat answering a question
+// from numbered context passages.
+const queryInstructions = "Answer the question of the user using only the numbered context passages that follow. " +
+	"Cite the passages you use by their number in square brackets, e.g. [2]. " +
+	"If the passages do not contain the answer, say that you do not know."
+
+// QueryMessages builds the chat answering question from numbered context
+// passages: the instructions, a context message per passage, then the
+// question.
+func QueryMessages(question string, citations []Citation) []uniai.Message {
+	messages := []uniai.Message{{Role: "system", Content: queryInstructions}}
+	for _, c := range citations {
+		messages = append(messages, uniai.Message{
+			Role:    "system",
+			Content: fmt.Sprintf("[%d] (%s, page %d)\n%s", c.Number, c.Source, c.Page, c.Text),
+		})
+	}
+
+	return append(messages, uniai.Message{Role: "user", Content: question})
+}
+
+// embed returns one embedding per input.
+func (r *RAG) embed(ctx context.Context, input []string) ([][]float32, error) {
+	resp, err := r.client.Embed(ctx, &uniai.EmbedRequest{
+		Model: r.opts.EmbedModel,
+		Input: input,
+	})
+	var status uniai.StatusError
+	if errors.As(err, &status) && status.StatusCode == http.StatusNotFound {
+		// Older servers only embed one prompt at a time.
+		return r.embedEach(ctx, input)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed: %w", err)
+	}
+	if len(resp.Embeddings) != len(input) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(input), len(resp.Embeddings))
+	}
+
+	return resp.Embeddings, nil
+}
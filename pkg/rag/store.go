@@ -0,0 +1,163 @@
+package rag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// storeFile is the name of the index file in a store directory.
+const storeFile = "index.json"
+
+// Store is an embedded vector store kept in a single JSON file. It loads all
+// chunks into memory and searches them exhaustively, which is fast enough for
+// the document collections of a single user.
+type Store struct {
+	dir string
+
+	// Model is the embedding model of the stored vectors. Queries must be
+	// embedded with the same model.
+	Model  string  `json:"model"`
+	Chunks []Chunk `json:"chunks"`
+}
+
+// Result is a chunk found by [Store.Search] with its cosine similarity to the
+// query.
+type Result struct {
+	Chunk
+	Score float64
+}
+
+// OpenStore loads the store in dir, or returns an empty one if it does not
+// exist yet.
+func OpenStore(dir string) (*Store, error) {
+	s := &Store{dir: dir}
+
+	data, err := os.ReadFile(filepath.Join(dir, storeFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse store: %w", err)
+	}
+
+	return s, nil
+}
+
+// Save writes the store to disk, replacing the previous file atomically.
+func (s *Store) Save() error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(s.dir, storeFile, data)
+}
+
+// writeFile writes data to the file name in dir, creating dir if needed and
+// replacing the previous file atomically.
+func writeFile(dir, name string, data []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, name+".*")
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
+
+// Replace removes the chunks of source and adds the given ones, so
+// re-indexing a document does not duplicate it.
+func (s *Store) Replace(source string, chunks []Chunk) {
+	kept := s.Chunks[:0]
+	for _, c := range s.Chunks {
+		if c.Source != source {
+			kept = append(kept, c)
+		}
+	}
+	s.Chunks = append(kept, chunks...)
+}
+
+// Sources returns the indexed sources with their number of chunks.
+func (s *Store) Sources() map[string]int {
+	sources := make(map[string]int)
+	for _, c := range s.Chunks {
+		sources[c.Source]++
+	}
+
+	return sources
+}
+
+// Search returns the k chunks most similar to vector, best first.
+func (s *Store) Search(vector []float32, k int) []Result {
+	query := normalize(vector)
+
+	results := make([]Result, 0, len(s.Chunks))
+	for _, c := range s.Chunks {
+		if len(c.Vector) != len(query) {
+			continue
+		}
+		results = append(results, Result{Chunk: c, Score: dot(query, c.Vector)})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+
+	return results
+}
+
+// normalize returns v scaled to unit length, so the dot product of two
+// normalized vectors is their cosine similarity.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+
+	norm := float32(math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x / norm
+	}
+
+	return out
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+
+	return sum
+}
@@ -0,0 +1,23 @@
+package uniai
+
+// ModelAliases map task-oriented model names to the models serving them,
+// such as {"fast": "uniai01:3b", "accurate": "uniai01:34b"}, so that scripts
+// keep referencing the aliases while the models behind them change.
+type ModelAliases map[string]string
+
+// UseModelAliases sets the aliases resolved to their models before every
+// generate, chat and embed request. Requests for other models are sent
+// unchanged.
+func (c *Client) UseModelAliases(aliases ModelAliases) {
+	c.aliases = aliases
+}
+
+// ResolveModel returns the model an alias stands for, or model itself if it
+// is not an alias of the client.
+func (c *Client) ResolveModel(model string) string {
+	if resolved, ok := c.aliases[model]; ok {
+		return resolved
+	}
+
+	return model
+}
@@ -0,0 +1,207 @@
+// Package cassette records the exchanges of a uniai client with the API to a
+// file and replays them, so the whole pipeline can run deterministically in
+// tests and offline without calling the paid API.
+//
+// Cassettes are sanitized: they hold the method, path and SHA-256 of every
+// request body, and the status, content type and body of its response. Hosts,
+// credentials and the prompts and images sent are never written.
+package cassette
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Modes of a Transport.
+const (
+	ModeRecord = "record" // send requests to the API and record them
+	ModeReplay = "replay" // answer requests from the cassette only
+)
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	BodyHash    string `json:"body_sha256"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Response    string `json:"response"`
+}
+
+func (in Interaction) key() string {
+	return in.Method + " " + in.Path + " " + in.BodyHash
+}
+
+// Cassette is the file of recorded interactions.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Transport is an http.RoundTripper recording or replaying the requests of a
+// client. It is safe for concurrent use.
+type Transport struct {
+	path string
+	mode string
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     map[int]bool // replayed interactions
+}
+
+// Open returns the transport of the cassette at path in mode. In record
+// mode, requests are sent with next, or http.DefaultTransport if nil, and
+// the cassette is written after every interaction; recording again replaces
+// it. In replay mode the cassette must exist.
+func Open(path, mode string, next http.RoundTripper) (*Transport, error) {
+	t := &Transport{path: path, mode: mode, next: next, used: make(map[int]bool)}
+	if next == nil {
+		t.next = http.DefaultTransport
+	}
+
+	switch mode {
+	case ModeRecord:
+		return t, nil
+	case ModeReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &t.cassette); err != nil {
+			return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+		}
+		return t, nil
+	}
+
+	return nil, fmt.Errorf("invalid cassette mode: %s", mode)
+}
+
+// Client returns an HTTP client using the transport.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// RoundTrip records or replays a request.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	in := Interaction{Method: req.Method, Path: req.URL.Path, BodyHash: hex.EncodeToString(sum[:])}
+
+	if t.mode == ModeReplay {
+		recorded, err := t.replay(in)
+		if err != nil {
+			return nil, err
+		}
+		return response(req, recorded), nil
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	in.Status, in.ContentType, in.Response = resp.StatusCode, resp.Header.Get("Content-Type"), string(data)
+	if err := t.record(in); err != nil {
+		return nil, fmt.Errorf("failed to record cassette: %w", err)
+	}
+
+	return resp, nil
+}
+
+// replay returns the first interaction matching in that was not replayed
+// yet, or the last matching one if all were, so repeated identical requests
+// keep working.
+func (t *Transport) replay(in Interaction) (Interaction, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last := -1
+	for i, recorded := range t.cassette.Interactions {
+		if recorded.key() != in.key() {
+			continue
+		}
+		if !t.used[i] {
+			t.used[i] = true
+			return recorded, nil
+		}
+		last = i
+	}
+	if last < 0 {
+		return Interaction{}, fmt.Errorf("no interaction recorded in %s for %s %s with this body", t.path, in.Method, in.Path)
+	}
+
+	return t.cassette.Interactions[last], nil
+}
+
+// record appends in to the cassette and writes it.
+func (t *Transport) record(in Interaction) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.cassette.Interactions = append(t.cassette.Interactions, in)
+	data, err := json.MarshalIndent(t.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, t.path)
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	defer req.Body.Close()
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	return body, nil
+}
+
+func response(req *http.Request, in Interaction) *http.Response {
+	header := make(http.Header)
+	if in.ContentType != "" {
+		header.Set("Content-Type", in.ContentType)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+		StatusCode:    in.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(in.Response))),
+		ContentLength: int64(len(in.Response)),
+		Request:       req,
+	}
+}
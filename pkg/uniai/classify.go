@@ -0,0 +1,136 @@
+package uniai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultAbstainThreshold is the confidence below which a [Classifier]
+// abstains when no threshold is set.
+const DefaultAbstainThreshold = 0.5
+
+// Label is a class a document can be assigned to.
+type Label struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description"`
+}
+
+// Example is a labeled text shown to the model as a few-shot example.
+type Example struct {
+	Text  string `json:"text" yaml:"text"`
+	Label string `json:"label" yaml:"label"`
+}
+
+// Classification is the result of [Classifier.Classify].
+type Classification struct {
+	Label      string  `json:"label"`
+	Confidence float64 `json:"confidence"`
+	Reason     string  `json:"reason,omitempty"`
+
+	// Abstained is set when the confidence is below the threshold of the
+	// classifier; Label is then the best guess of the model.
+	Abstained bool `json:"abstained"`
+}
+
+// Classifier assigns one label of a fixed set to documents.
+type Classifier struct {
+	Client   *Client
+	Model    string
+	Labels   []Label
+	Examples []Example
+
+	// Threshold is the minimum confidence (0-1) of a classification;
+	// [DefaultAbstainThreshold] if zero, and never abstaining if negative.
+	Threshold float64
+
+	// Options are the model options, [DefaultOptions] if nil.
+	Options map[string]any
+}
+
+// ClassifyInput is the content of a document: text, images or both.
+type ClassifyInput struct {
+	Text   string
+	Images []ImageData
+}
+
+type classifierAnswer struct {
+	Label      string  `json:"label"`
+	Confidence float64 `json:"confidence" description:"confidence between 0 and 1"`
+	Reason     string  `json:"reason,omitempty" description:"one sentence"`
+}
+
+// Classify asks the model for the label of a document, with its confidence
+// and reason.
+func (c *Classifier) Classify(ctx context.Context, in ClassifyInput) (*Classification, error) {
+	if len(c.Labels) == 0 {
+		return nil, errors.New("classifier has no labels")
+	}
+	if in.Text == "" && len(in.Images) == 0 {
+		return nil, errors.New("nothing to classify")
+	}
+
+	names := make([]any, len(c.Labels))
+	for i, l := range c.Labels {
+		names[i] = l.Name
+	}
+	schema := SchemaFor[classifierAnswer]()
+	schema["properties"].(map[string]any)["label"] = map[string]any{"type": "string", "enum": names}
+
+	req := &GenerateRequest{
+		Model:   c.Model,
+		Prompt:  c.prompt(in.Text),
+		Images:  in.Images,
+		Options: c.Options,
+	}
+	if req.Model == "" {
+		req.Model = ModelDefault
+	}
+	if req.Options == nil {
+		req.Options = DefaultOptions
+	}
+
+	var answer classifierAnswer
+	if err := ExtractInto(ctx, c.Client, req, schema, &answer); err != nil {
+		return nil, err
+	}
+
+	threshold := c.Threshold
+	if threshold == 0 {
+		threshold = DefaultAbstainThreshold
+	}
+
+	return &Classification{
+		Label:      answer.Label,
+		Confidence: answer.Confidence,
+		Reason:     answer.Reason,
+		Abstained:  answer.Confidence < threshold,
+	}, nil
+}
+
+func (c *Classifier) prompt(text string) string {
+	var sb strings.Builder
+	sb.WriteString("Classify the document into exactly one of the following labels:\n")
+	for _, l := range c.Labels {
+		fmt.Fprintf(&sb, "- %s", l.Name)
+		if l.Description != "" {
+			fmt.Fprintf(&sb, ": %s", l.Description)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("Give your confidence between 0 and 1 that the label is correct, and the reason in one sentence.\n")
+
+	if len(c.Examples) > 0 {
+		sb.WriteString("\nExamples:\n")
+		for _, ex := range c.Examples {
+			fmt.Fprintf(&sb, "\nDocument:\n%s\nLabel: %s\n", strings.TrimSpace(ex.Text), ex.Label)
+		}
+	}
+
+	if text != "" {
+		fmt.Fprintf(&sb, "\nDocument to classify:\n%s\n", strings.TrimSpace(text))
+	}
+
+	return sb.String()
+}
@@ -3,6 +3,7 @@ package uniai
 import (
 	"bufio"
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -12,12 +13,43 @@ import (
 	"net/http"
 	"net/url"
 	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sampila/uniai-client/pkg/uniai/jsonrepair"
 )
 
 type Client struct {
 	client    *http.Client
 	baseURL   *url.URL
 	authBasic string
+	filters   []ResponseFilter
+	usage     UsageFunc
+	// keys authenticate the requests instead of authBasic, if set.
+	keys     *KeyPool
+	exchange ExchangeFunc
+	// provider answers the requests instead of the UniAI API, if set.
+	provider Provider
+	// signer signs every request, if set.
+	signer *Signer
+	// passImageURLs sends image URLs to the API instead of inlining them.
+	passImageURLs bool
+	// redactor masks the credentials of the client in logs and dumps.
+	redactor *Redactor
+	// resolveOptions returns the default options of a model, if set.
+	resolveOptions OptionsResolver
+	// aliases are resolved to their models before every request.
+	aliases ModelAliases
+	// queue bounds the requests in flight, if set.
+	queue *RequestQueue
+	// retry sends the requests failing with transient errors again.
+	retry RetryPolicy
+	// timeout bounds every API request, if set.
+	timeout time.Duration
+	// header is set on every request.
+	header http.Header
+	// userAgent replaces the default User-Agent, if set.
+	userAgent string
 }
 
 func checkError(resp *http.Response, body []byte) error {
@@ -25,7 +57,7 @@ func checkError(resp *http.Response, body []byte) error {
 		return nil
 	}
 
-	apiError := StatusError{StatusCode: resp.StatusCode}
+	apiError := StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 
 	err := json.Unmarshal(body, &apiError)
 	if err != nil {
@@ -33,10 +65,17 @@ func checkError(resp *http.Response, body []byte) error {
 		apiError.ErrorMessage = string(body)
 	}
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return &RateLimitError{StatusError: apiError, RetryAfter: retryAfter(resp)}
+	}
+
 	return apiError
 }
 
-func NewClient(baseURL string, httpClient *http.Client, authBasic string) (*Client, error) {
+// NewClient returns a client of the UniAI API at baseURL, or at the default
+// base URL if empty, authenticating with authBasic, "user:password". A nil
+// httpClient uses [http.DefaultClient]. opts are applied in order.
+func NewClient(baseURL string, httpClient *http.Client, authBasic string, opts ...ClientOption) (*Client, error) {
 	if authBasic == "" {
 		return nil, errors.New("authBasic cannot be empty")
 	}
@@ -57,19 +96,98 @@ func NewClient(baseURL string, httpClient *http.Client, authBasic string) (*Clie
 	}
 
 	nc.authBasic = base64.StdEncoding.EncodeToString([]byte(authBasic))
+	nc.redactor = NewRedactor(authBasic, nc.authBasic)
+	for _, opt := range opts {
+		opt(nc)
+	}
 
 	return nc, nil
 }
 
+// UseFilters sets the filters applied to the complete response of every
+// generate request, before the filters of the request itself.
+func (c *Client) UseFilters(filters ...ResponseFilter) {
+	c.filters = filters
+}
+
+// FilterResponse applies the filters of the client and of req to a complete
+// response, for callers collecting streamed chunks themselves.
+func (c *Client) FilterResponse(req *GenerateRequest, response string) string {
+	response = ApplyFilters(response, c.filters...)
+	return ApplyFilters(response, req.Filters...)
+}
+
+// UsageFunc is a function that the client invokes with the token counts and
+// durations of every completed request, for accounting.
+type UsageFunc func(model string, m Metrics)
+
+// OnUsage sets the function invoked with the metrics of every completed
+// generate, chat and embed request.
+func (c *Client) OnUsage(fn UsageFunc) {
+	c.usage = fn
+}
+
+// Kinds of exchanges.
+const (
+	ExchangeGenerate = "generate"
+	ExchangeChat     = "chat"
+	ExchangeEmbed    = "embed"
+)
+
+// Exchange is a completed generate, chat or embed request and its response.
+type Exchange struct {
+	Kind   string
+	Model  string // the requested model
+	System string
+	// Prompt is the prompt, the chat messages as "role: content" lines or
+	// the embedded texts separated by blank lines.
+	Prompt   string
+	Images   []ImageData
+	Response string // the complete response text, empty for embeddings
+	Err      error
+	Started  time.Time
+	Duration time.Duration
+}
+
+// ExchangeFunc is a function that the client invokes with every completed
+// request, e.g. for auditing.
+type ExchangeFunc func(Exchange)
+
+// OnExchange sets the function invoked with every completed generate, chat
+// and embed request, failed ones included.
+func (c *Client) OnExchange(fn ExchangeFunc) {
+	c.exchange = fn
+}
+
+// reportUsage passes the metrics of a completed request to the usage function.
+func (c *Client) reportUsage(model, fallback string, m Metrics) {
+	if c.usage == nil {
+		return
+	}
+	if model == "" {
+		model = fallback
+	}
+	c.usage(model, m)
+}
+
 func (c *Client) do(ctx context.Context, method, path string, reqData, respData any) error {
-	var reqBody io.Reader
+	if c.baseURL == nil {
+		return errNoAPI
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
 	var data []byte
 	var err error
 
 	switch reqData := reqData.(type) {
 	case io.Reader:
 		// reqData is already an io.Reader
-		reqBody = reqData
+		data, err = io.ReadAll(reqData)
+		if err != nil {
+			return err
+		}
 	case nil:
 		// noop
 	default:
@@ -77,25 +195,9 @@ func (c *Client) do(ctx context.Context, method, path string, reqData, respData
 		if err != nil {
 			return err
 		}
-
-		reqBody = bytes.NewReader(data)
 	}
 
-	requestURL := c.baseURL.JoinPath(path)
-
-	request, err := http.NewRequestWithContext(ctx, method, requestURL.String(), reqBody)
-	if err != nil {
-		return err
-	}
-
-	request.Header.Set("Content-Type", "application/json")
-	request.Header.Set("Accept", "application/json")
-	request.Header.Set("User-Agent", fmt.Sprintf("unicloud/1 (%s %s) Go/%s", runtime.GOARCH, runtime.GOOS, runtime.Version()))
-	if c.authBasic != "" {
-		request.Header.Set("Authorization", "Basic "+c.authBasic)
-	}
-
-	respObj, err := c.client.Do(request)
+	respObj, err := c.send(ctx, method, path, data, http.Header{"Accept": {"application/json"}})
 	if err != nil {
 		return err
 	}
@@ -118,39 +220,127 @@ func (c *Client) do(ctx context.Context, method, path string, reqData, respData
 	return nil
 }
 
-const maxBufferSize = 512 * KiloByte
+// send sends a request with body, if not nil, and header, and returns the
+// response for the caller to close. With a key pool, requests the API
+// throttles are sent again with another key; with a retry policy, requests
+// failing with a transient error are sent again after a backoff.
+func (c *Client) send(ctx context.Context, method, path string, body []byte, header http.Header) (*http.Response, error) {
+	requestURL := c.baseURL.JoinPath(path)
 
-func (c *Client) stream(ctx context.Context, method, path string, data any, fn func([]byte) error) error {
-	var buf io.Reader
-	if data != nil {
-		bts, err := json.Marshal(data)
+	retries := 0
+	for attempt := 1; ; attempt++ {
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(body)
+		}
+
+		request, err := http.NewRequestWithContext(ctx, method, requestURL.String(), reqBody)
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		for key, values := range c.header {
+			request.Header[key] = values
+		}
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("User-Agent", cmp.Or(c.userAgent, fmt.Sprintf("unicloud/1 (%s %s) Go/%s", runtime.GOARCH, runtime.GOOS, runtime.Version())))
+		for key, values := range header {
+			request.Header[http.CanonicalHeaderKey(key)] = values
+		}
+
+		auth := c.authBasic
+		var key *poolKey
+		if c.keys != nil {
+			if key, err = c.keys.acquire(ctx); err != nil {
+				return nil, err
+			}
+			auth = key.auth
+		}
+		if auth != "" {
+			request.Header.Set("Authorization", "Basic "+auth)
+		}
+		// Every attempt is signed anew, so retries carry a fresh timestamp.
+		if c.signer != nil {
+			c.signer.Sign(request, body, time.Now())
 		}
 
-		buf = bytes.NewBuffer(bts)
+		response, err := c.client.Do(request)
+		if err != nil {
+			if retries++; !transientError(err) || retries >= c.retry.Attempts {
+				return nil, err
+			}
+			if err := sleep(ctx, c.retry.Backoff(retries)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if transientStatus(response.StatusCode) && retries+1 < c.retry.Attempts {
+			retries++
+			wait := c.retry.Backoff(retries)
+			if response.StatusCode == http.StatusServiceUnavailable {
+				wait = max(wait, retryAfter(response))
+			}
+			io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+			if err := sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if key == nil || response.StatusCode != http.StatusTooManyRequests || attempt == maxThrottledAttempts {
+			return response, nil
+		}
+
+		c.keys.throttled(key, response)
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
 	}
+}
 
-	requestURL := c.baseURL.JoinPath(path)
+const maxBufferSize = 512 * KiloByte
 
-	request, err := http.NewRequestWithContext(ctx, method, requestURL.String(), buf)
-	if err != nil {
-		return err
+// Stream sends a request with body, marshaled as JSON if not nil, to path of
+// the API and calls fn with every line of the NDJSON response, for endpoints
+// without a typed method yet. Lines up to 512 KiB are supported. Error
+// responses and lines with an "error" field are returned as errors. The bytes
+// passed to fn are only valid until it returns. Clients of other providers
+// have no API to stream from.
+func (c *Client) Stream(ctx context.Context, method, path string, body any, fn func([]byte) error) error {
+	return c.stream(ctx, method, path, body, fn)
+}
+
+func (c *Client) stream(ctx context.Context, method, path string, data any, fn func([]byte) error) error {
+	if c.baseURL == nil {
+		return errNoAPI
 	}
 
-	request.Header.Set("Content-Type", "application/json")
-	request.Header.Set("Accept", "application/x-ndjson")
-	request.Header.Set("User-Agent", fmt.Sprintf("unicloud/1 (%s %s) Go/%s", runtime.GOARCH, runtime.GOOS, runtime.Version()))
-	if c.authBasic != "" {
-		request.Header.Set("Authorization", "Basic "+c.authBasic)
+	var bts []byte
+	if data != nil {
+		var err error
+		if bts, err = json.Marshal(data); err != nil {
+			return err
+		}
 	}
 
-	response, err := c.client.Do(request)
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	response, err := c.send(ctx, method, path, bts, http.Header{"Accept": {"application/x-ndjson"}})
 	if err != nil {
 		return err
 	}
 	defer response.Body.Close()
 
+	// Error responses, e.g. 429 Too Many Requests from a gateway, are not
+	// necessarily NDJSON.
+	if response.StatusCode >= http.StatusBadRequest {
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return err
+		}
+		return checkError(response, body)
+	}
+
 	scanner := bufio.NewScanner(response.Body)
 	// increase the buffer size to avoid running out of space
 	scanBuf := make([]byte, 0, maxBufferSize)
@@ -181,24 +371,86 @@ func (c *Client) stream(ctx context.Context, method, path string, data any, fn f
 			return err
 		}
 	}
+	// A response cut short, e.g. by the deadline of ctx, is an error rather
+	// than the end of the stream.
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
 
 	return nil
 }
 
 // GenerateResponseFunc is a function that [Client.Generate] invokes every time
 // a response is received from the service. If this function returns an error,
-// [Client.Generate] will stop generating and return this error.
+// [Client.Generate] will stop generating and return this error, or nil if it
+// is [ErrStop].
 type GenerateResponseFunc func(GenerateResponse) error
 
 // Generate generates a response for a given prompt. The req parameter should
 // be populated with prompt details. fn is called for each response (there may
-// be multiple responses, e.g. in case streaming is enabled).
+// be multiple responses, e.g. in case streaming is enabled). The images of
+// req.ImagePaths and req.ImageURLs are read when the request is sent.
 func (c *Client) Generate(ctx context.Context, req *GenerateRequest, fn GenerateResponseFunc) error {
+	req, err := req.withImagePaths()
+	if err != nil {
+		return err
+	}
+	if req, err = c.withImageURLs(ctx, req); err != nil {
+		return err
+	}
+	if c.aliases != nil || c.resolveOptions != nil {
+		resolved := *req
+		resolved.Model = c.ResolveModel(req.Model)
+		resolved.Options = c.ResolveOptions(req.Model, req.Options)
+		req = &resolved
+	}
+
+	if c.exchange == nil {
+		return stopped(c.generate(ctx, req, fn))
+	}
+
+	ex := Exchange{Kind: ExchangeGenerate, Model: req.Model, System: req.System, Prompt: req.Prompt, Images: req.Images, Started: time.Now()}
+	var response strings.Builder
+	ex.Err = stopped(c.generate(ctx, req, func(resp GenerateResponse) error {
+		response.WriteString(resp.Response)
+		return fn(resp)
+	}))
+	ex.Response, ex.Duration = response.String(), time.Since(ex.Started)
+	c.exchange(ex)
+
+	return ex.Err
+}
+
+func (c *Client) generate(ctx context.Context, req *GenerateRequest, fn GenerateResponseFunc) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	release, err := c.queue.Acquire(ctx, PriorityFrom(ctx))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if c.provider != nil {
+		return c.provider.Generate(ctx, req, func(resp GenerateResponse) error {
+			if resp.Done {
+				c.reportUsage(resp.Model, req.Model, resp.Metrics)
+			}
+			return fn(resp)
+		})
+	}
+
 	return c.stream(ctx, http.MethodPost, "/api/generate", req, func(bts []byte) error {
 		var resp GenerateResponse
 		if err := json.Unmarshal(bts, &resp); err != nil {
 			return err
 		}
+		if resp.Done {
+			c.reportUsage(resp.Model, req.Model, resp.Metrics)
+		}
 
 		return fn(resp)
 	})
@@ -206,7 +458,8 @@ func (c *Client) Generate(ctx context.Context, req *GenerateRequest, fn Generate
 
 // ChatResponseFunc is a function that [Client.Chat] invokes every time
 // a response is received from the service. If this function returns an error,
-// [Client.Chat] will stop generating and return this error.
+// [Client.Chat] will stop generating and return this error, or nil if it is
+// [ErrStop].
 type ChatResponseFunc func(ChatResponse) error
 
 // Chat generates the next message in a chat. [ChatRequest] may contain a
@@ -214,11 +467,217 @@ type ChatResponseFunc func(ChatResponse) error
 // fn is called for each response (there may be multiple responses, e.g. if case
 // streaming is enabled).
 func (c *Client) Chat(ctx context.Context, req *ChatRequest, fn ChatResponseFunc) error {
+	if c.aliases != nil || c.resolveOptions != nil {
+		resolved := *req
+		resolved.Model = c.ResolveModel(req.Model)
+		resolved.Options = c.ResolveOptions(req.Model, req.Options)
+		req = &resolved
+	}
+	if c.exchange == nil {
+		return stopped(c.chat(ctx, req, fn))
+	}
+
+	ex := Exchange{Kind: ExchangeChat, Model: req.Model, Started: time.Now()}
+	var prompt []string
+	for _, m := range req.Messages {
+		prompt = append(prompt, m.Role+": "+m.Content)
+		ex.Images = append(ex.Images, m.Images...)
+	}
+	ex.Prompt = strings.Join(prompt, "\n")
+	var response strings.Builder
+	ex.Err = stopped(c.chat(ctx, req, func(resp ChatResponse) error {
+		response.WriteString(resp.Message.Content)
+		return fn(resp)
+	}))
+	ex.Response, ex.Duration = response.String(), time.Since(ex.Started)
+	c.exchange(ex)
+
+	return ex.Err
+}
+
+func (c *Client) chat(ctx context.Context, req *ChatRequest, fn ChatResponseFunc) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	release, err := c.queue.Acquire(ctx, PriorityFrom(ctx))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if c.provider != nil {
+		return c.provider.Chat(ctx, req, func(resp ChatResponse) error {
+			if resp.Done {
+				c.reportUsage(resp.Model, req.Model, resp.Metrics)
+			}
+			return fn(resp)
+		})
+	}
+
 	return c.stream(ctx, http.MethodPost, "/api/chat", req, func(bts []byte) error {
 		var resp ChatResponse
 		if err := json.Unmarshal(bts, &resp); err != nil {
 			return err
 		}
+		if resp.Done {
+			c.reportUsage(resp.Model, req.Model, resp.Metrics)
+		}
+
+		return fn(resp)
+	})
+}
+
+// Embed generates embeddings from a model.
+func (c *Client) Embed(ctx context.Context, req *EmbedRequest) (*EmbedResponse, error) {
+	if c.aliases != nil || c.resolveOptions != nil {
+		resolved := *req
+		resolved.Model = c.ResolveModel(req.Model)
+		resolved.Options = c.ResolveOptions(req.Model, req.Options)
+		req = &resolved
+	}
+	if c.exchange == nil {
+		return c.embed(ctx, req)
+	}
+
+	ex := Exchange{Kind: ExchangeEmbed, Model: req.Model, Prompt: strings.Join(req.Input, "\n\n"), Started: time.Now()}
+	resp, err := c.embed(ctx, req)
+	ex.Err, ex.Duration = err, time.Since(ex.Started)
+	c.exchange(ex)
+
+	return resp, err
+}
+
+func (c *Client) embed(ctx context.Context, req *EmbedRequest) (*EmbedResponse, error) {
+	release, err := c.queue.Acquire(ctx, PriorityFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var resp *EmbedResponse
+	if c.provider != nil {
+		if resp, err = c.provider.Embed(ctx, req); err != nil {
+			return nil, err
+		}
+	} else {
+		resp = new(EmbedResponse)
+		if err := c.do(ctx, http.MethodPost, "/api/embed", req, resp); err != nil {
+			return nil, err
+		}
+	}
+	c.reportUsage(resp.Model, req.Model, Metrics{
+		TotalDuration:   resp.TotalDuration,
+		LoadDuration:    resp.LoadDuration,
+		PromptEvalCount: resp.PromptEvalCount,
+	})
+
+	return resp, nil
+}
+
+// Embeddings embeds a single prompt with the older /api/embeddings endpoint,
+// for servers without /api/embed. Prefer [Client.Embed], which embeds several
+// inputs at once. Clients of other providers embed the prompt with the Embed
+// of the provider.
+func (c *Client) Embeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	if c.aliases != nil || c.resolveOptions != nil {
+		resolved := *req
+		resolved.Model = c.ResolveModel(req.Model)
+		resolved.Options = c.ResolveOptions(req.Model, req.Options)
+		req = &resolved
+	}
+	if c.exchange == nil {
+		return c.embeddings(ctx, req)
+	}
+
+	ex := Exchange{Kind: ExchangeEmbed, Model: req.Model, Prompt: req.Prompt, Started: time.Now()}
+	resp, err := c.embeddings(ctx, req)
+	ex.Err, ex.Duration = err, time.Since(ex.Started)
+	c.exchange(ex)
+
+	return resp, err
+}
+
+func (c *Client) embeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	if c.provider != nil {
+		resp, err := c.embed(ctx, &EmbedRequest{Model: req.Model, Input: []string{req.Prompt}, KeepAlive: req.KeepAlive, Options: req.Options})
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Embeddings) != 1 {
+			return nil, fmt.Errorf("expected 1 embedding, got %d", len(resp.Embeddings))
+		}
+		embedding := make([]float64, len(resp.Embeddings[0]))
+		for i, v := range resp.Embeddings[0] {
+			embedding[i] = float64(v)
+		}
+		return &EmbeddingResponse{Embedding: embedding}, nil
+	}
+
+	release, err := c.queue.Acquire(ctx, PriorityFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	start := time.Now()
+	resp := new(EmbeddingResponse)
+	if err := c.do(ctx, http.MethodPost, "/api/embeddings", req, resp); err != nil {
+		return nil, err
+	}
+	// The endpoint reports no token counts, only the request is accounted.
+	c.reportUsage("", req.Model, Metrics{TotalDuration: time.Since(start)})
+
+	return resp, nil
+}
+
+// ListModels returns the models available on the server.
+func (c *Client) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	if c.provider != nil {
+		return c.provider.ListModels(ctx)
+	}
+
+	var resp struct {
+		Models []ModelInfo `json:"models"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/tags", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Models, nil
+}
+
+// ShowModel returns the details of a model served by the API, such as its
+// parameters, template and capabilities. Clients of other providers have no
+// such endpoint.
+func (c *Client) ShowModel(ctx context.Context, req *ShowRequest) (*ShowResponse, error) {
+	resolved := *req
+	resolved.Model = c.ResolveModel(req.Model)
+
+	resp := new(ShowResponse)
+	if err := c.do(ctx, http.MethodPost, "/api/show", &resolved, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// PullProgressFunc is a function that [Client.PullModel] invokes with the
+// progress of the pull. If it returns an error, [Client.PullModel] stops
+// and returns this error.
+type PullProgressFunc func(ProgressResponse) error
+
+// PullModel downloads a model to the server from its registry, calling fn
+// as the download progresses. Clients of other providers have no such
+// endpoint.
+func (c *Client) PullModel(ctx context.Context, req *PullRequest, fn PullProgressFunc) error {
+	resolved := *req
+	resolved.Model = c.ResolveModel(req.Model)
+
+	return c.stream(ctx, http.MethodPost, "/api/pull", &resolved, func(bts []byte) error {
+		var resp ProgressResponse
+		if err := json.Unmarshal(bts, &resp); err != nil {
+			return err
+		}
 
 		return fn(resp)
 	})
@@ -245,3 +704,41 @@ func (c *Client) Version(ctx context.Context) (string, error) {
 
 	return version.Version, nil
 }
+
+// GenerateText is a convenience wrapper around [Client.Generate] that
+// collects the streamed chunks and returns the complete response text, with
+// the response filters applied.
+func (c *Client) GenerateText(ctx context.Context, req *GenerateRequest) (string, error) {
+	var sb strings.Builder
+	err := c.Generate(ctx, req, func(resp GenerateResponse) error {
+		sb.WriteString(resp.Response)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return c.FilterResponse(req, sb.String()), nil
+}
+
+// GenerateJSON generates a JSON response and unmarshals it into v. The JSON
+// format is requested unless req already sets a format (such as a schema),
+// and the response is repaired with [jsonrepair] if it is not valid JSON.
+func (c *Client) GenerateJSON(ctx context.Context, req *GenerateRequest, v any) error {
+	if len(req.Format) == 0 {
+		jsonReq := *req
+		jsonReq.Format = json.RawMessage(`"json"`)
+		req = &jsonReq
+	}
+
+	text, err := c.GenerateText(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if err := jsonrepair.Unmarshal([]byte(ApplyFilters(text, DefaultFilters...)), v); err != nil {
+		return fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+
+	return nil
+}
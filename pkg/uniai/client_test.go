@@ -0,0 +1,383 @@
+package uniai_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+	"github.com/sampila/uniai-client/pkg/uniai/uniaitest"
+)
+
+func newTestClient(t *testing.T, opts ...uniai.ClientOption) (*uniaitest.Server, *uniai.Client) {
+	t.Helper()
+
+	srv := uniaitest.NewServer()
+	t.Cleanup(srv.Close)
+
+	client, err := uniai.NewClient(srv.URL, srv.Client(), uniaitest.Auth, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return srv, client
+}
+
+func TestGenerate(t *testing.T) {
+	srv, client := newTestClient(t)
+	srv.Reply(uniaitest.Stream("Hello", ", ", "world"))
+
+	var (
+		chunks []string
+		last   uniai.GenerateResponse
+	)
+	err := client.Generate(context.Background(), &uniai.GenerateRequest{Model: uniai.ModelDefault, Prompt: "hi"}, func(resp uniai.GenerateResponse) error {
+		chunks = append(chunks, resp.Response)
+		last = resp
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"Hello", ", ", "world"}; !slices.Equal(chunks, want) {
+		t.Errorf("chunks = %q, want %q", chunks, want)
+	}
+	if !last.Done || last.PromptEvalCount != 10 || last.EvalCount != 3 {
+		t.Errorf("last chunk = done %t, %d prompt and %d completion tokens, want done, 10 and 3", last.Done, last.PromptEvalCount, last.EvalCount)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("%d requests sent, want 1", len(reqs))
+	}
+	if reqs[0].Path != "/api/generate" || reqs[0].Auth != uniaitest.Auth {
+		t.Errorf("request to %s with auth %q, want /api/generate with %q", reqs[0].Path, reqs[0].Auth, uniaitest.Auth)
+	}
+	var sent uniai.GenerateRequest
+	if err := reqs[0].Decode(&sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Prompt != "hi" {
+		t.Errorf("prompt sent = %q, want %q", sent.Prompt, "hi")
+	}
+}
+
+func TestGenerateText(t *testing.T) {
+	_, client := newTestClient(t)
+
+	text, err := client.GenerateText(context.Background(), &uniai.GenerateRequest{Model: uniai.ModelDefault, Prompt: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Hello world" {
+		t.Errorf("text = %q, want %q", text, "Hello world")
+	}
+}
+
+func TestChat(t *testing.T) {
+	srv, client := newTestClient(t)
+	srv.Reply(uniaitest.Text("Paris is the capital"))
+
+	var content strings.Builder
+	req := &uniai.ChatRequest{
+		Model: uniai.ModelDefault,
+		Messages: []uniai.Message{
+			{Role: "system", Content: "Answer briefly."},
+			{Role: "user", Content: "What is the capital of France?"},
+		},
+	}
+	err := client.Chat(context.Background(), req, func(resp uniai.ChatResponse) error {
+		if resp.Message.Role != "assistant" {
+			t.Errorf("role = %q, want assistant", resp.Message.Role)
+		}
+		content.WriteString(resp.Message.Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content.String() != "Paris is the capital" {
+		t.Errorf("content = %q, want %q", content.String(), "Paris is the capital")
+	}
+
+	var sent uniai.ChatRequest
+	if err := srv.Requests()[0].Decode(&sent); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent.Messages) != 2 {
+		t.Errorf("%d messages sent, want 2", len(sent.Messages))
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	_, client := newTestClient(t)
+
+	input := []string{"first", "second", "first"}
+	resp, err := client.Embed(context.Background(), &uniai.EmbedRequest{Model: uniai.ModelDefault, Input: input})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Embeddings) != len(input) {
+		t.Fatalf("%d embeddings, want %d", len(resp.Embeddings), len(input))
+	}
+	for i, text := range input {
+		if !slices.Equal(resp.Embeddings[i], uniaitest.Embedding(text)) {
+			t.Errorf("embedding %d = %v, want %v", i, resp.Embeddings[i], uniaitest.Embedding(text))
+		}
+	}
+	if slices.Equal(resp.Embeddings[0], resp.Embeddings[1]) {
+		t.Error("different inputs have the same embedding")
+	}
+}
+
+func TestEmbeddings(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider bool   // embed through a provider client wrapping the API client
+		path     string // endpoint the request is sent to
+	}{
+		{name: "API", path: "/api/embeddings"},
+		{name: "provider", provider: true, path: "/api/embed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, client := newTestClient(t)
+			if tt.provider {
+				client = uniai.NewProviderClient(client)
+			}
+
+			var (
+				usage     int
+				exchanges []uniai.Exchange
+			)
+			client.OnUsage(func(string, uniai.Metrics) { usage++ })
+			client.OnExchange(func(ex uniai.Exchange) { exchanges = append(exchanges, ex) })
+
+			resp, err := client.Embeddings(context.Background(), &uniai.EmbeddingRequest{Model: uniai.ModelDefault, Prompt: "text"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := uniaitest.Embedding("text")
+			if len(resp.Embedding) != len(want) {
+				t.Fatalf("embedding of length %d, want %d", len(resp.Embedding), len(want))
+			}
+			for i, v := range want {
+				if resp.Embedding[i] != float64(v) {
+					t.Fatalf("embedding = %v, want %v", resp.Embedding, want)
+				}
+			}
+			if usage != 1 {
+				t.Errorf("usage reported %d times, want 1", usage)
+			}
+			if len(exchanges) != 1 || exchanges[0].Kind != uniai.ExchangeEmbed || exchanges[0].Prompt != "text" {
+				t.Errorf("exchanges = %+v, want an embed exchange of %q", exchanges, "text")
+			}
+			if reqs := srv.Requests(); len(reqs) != 1 || reqs[0].Path != tt.path {
+				t.Errorf("requests = %+v, want one to %s", reqs, tt.path)
+			}
+		})
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply uniaitest.Reply
+		// chunks is the number of chunks passed to the response function
+		// before the error.
+		chunks int
+		check  func(t *testing.T, err error)
+	}{
+		{
+			name:  "JSON error body",
+			reply: uniaitest.Fail(http.StatusNotFound, "model not found"),
+			check: wantStatus(http.StatusNotFound, "model not found"),
+		},
+		{
+			name:  "plain text error body",
+			reply: uniaitest.Raw(http.StatusBadGateway, "<html>bad gateway</html>"),
+			check: wantStatus(http.StatusBadGateway, "<html>bad gateway</html>"),
+		},
+		{
+			name:  "status without message",
+			reply: uniaitest.Fail(http.StatusInternalServerError, ""),
+			check: wantStatus(http.StatusInternalServerError, "500 Internal Server Error"),
+		},
+		{
+			name:  "rate limited",
+			reply: uniaitest.Fail(http.StatusTooManyRequests, "slow down"),
+			check: func(t *testing.T, err error) {
+				var rateErr *uniai.RateLimitError
+				if !errors.As(err, &rateErr) {
+					t.Fatalf("error = %v, want a RateLimitError", err)
+				}
+				wantStatus(http.StatusTooManyRequests, "slow down")(t, err)
+			},
+		},
+		{
+			name:   "error mid-stream",
+			reply:  uniaitest.FailMidStream("out of memory", "Hello", " world"),
+			chunks: 2,
+			check:  wantMessage("out of memory"),
+		},
+		{
+			name:  "malformed line",
+			reply: uniaitest.Malformed(),
+			check: wantMessage("unmarshal: "),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, client := newTestClient(t)
+			srv.Reply(tt.reply)
+
+			chunks := 0
+			err := client.Generate(context.Background(), &uniai.GenerateRequest{Model: uniai.ModelDefault, Prompt: "hi"}, func(uniai.GenerateResponse) error {
+				chunks++
+				return nil
+			})
+			if err == nil {
+				t.Fatal("no error")
+			}
+			tt.check(t, err)
+			if chunks != tt.chunks {
+				t.Errorf("%d chunks received before the error, want %d", chunks, tt.chunks)
+			}
+		})
+	}
+}
+
+func wantStatus(code int, message string) func(*testing.T, error) {
+	return func(t *testing.T, err error) {
+		t.Helper()
+
+		var statusErr uniai.StatusError
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("error = %v, want a StatusError", err)
+		}
+		if statusErr.StatusCode != code || statusErr.ErrorMessage != message {
+			t.Errorf("status error = %d %q, want %d %q", statusErr.StatusCode, statusErr.ErrorMessage, code, message)
+		}
+	}
+}
+
+func wantMessage(message string) func(*testing.T, error) {
+	return func(t *testing.T, err error) {
+		t.Helper()
+
+		if !strings.Contains(err.Error(), message) {
+			t.Errorf("error = %q, want it to contain %q", err, message)
+		}
+	}
+}
+
+func TestStreamStop(t *testing.T) {
+	srv, client := newTestClient(t)
+	srv.Reply(uniaitest.Stream("a", "b", "c"))
+
+	chunks := 0
+	err := client.Generate(context.Background(), &uniai.GenerateRequest{Model: uniai.ModelDefault, Prompt: "hi"}, func(uniai.GenerateResponse) error {
+		chunks++
+		return uniai.ErrStop
+	})
+	if err != nil {
+		t.Fatalf("error = %v, want nil when stopped", err)
+	}
+	if chunks != 1 {
+		t.Errorf("%d chunks received, want 1", chunks)
+	}
+}
+
+func TestStreamTimeout(t *testing.T) {
+	srv, client := newTestClient(t, uniai.WithTimeout(50*time.Millisecond))
+	reply := uniaitest.Stream("a", "b", "c")
+	reply.Delay = 40 * time.Millisecond
+	srv.Reply(reply)
+
+	err := client.Generate(context.Background(), &uniai.GenerateRequest{Model: uniai.ModelDefault, Prompt: "hi"}, func(uniai.GenerateResponse) error {
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestUsageAndExchange(t *testing.T) {
+	srv, client := newTestClient(t)
+	srv.Reply(uniaitest.Stream("a", "b"), uniaitest.Fail(http.StatusBadRequest, "bad request"))
+
+	var (
+		usage     []uniai.Metrics
+		exchanges []uniai.Exchange
+	)
+	client.OnUsage(func(model string, m uniai.Metrics) {
+		usage = append(usage, m)
+	})
+	client.OnExchange(func(ex uniai.Exchange) {
+		exchanges = append(exchanges, ex)
+	})
+
+	ctx := context.Background()
+	req := &uniai.GenerateRequest{Model: uniai.ModelDefault, Prompt: "hi"}
+	if _, err := client.GenerateText(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GenerateText(ctx, req); err == nil {
+		t.Fatal("no error")
+	}
+	if _, err := client.Embed(ctx, &uniai.EmbedRequest{Model: uniai.ModelDefault, Input: []string{"text"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(usage) != 2 {
+		t.Fatalf("usage reported %d times, want 2", len(usage))
+	}
+	if usage[0].PromptEvalCount != 10 || usage[0].EvalCount != 2 {
+		t.Errorf("usage = %d prompt and %d completion tokens, want 10 and 2", usage[0].PromptEvalCount, usage[0].EvalCount)
+	}
+
+	kinds := make([]string, len(exchanges))
+	for i, ex := range exchanges {
+		kinds[i] = ex.Kind
+	}
+	if want := []string{uniai.ExchangeGenerate, uniai.ExchangeGenerate, uniai.ExchangeEmbed}; !slices.Equal(kinds, want) {
+		t.Fatalf("exchanges = %q, want %q", kinds, want)
+	}
+	if exchanges[0].Response != "ab" || exchanges[0].Err != nil {
+		t.Errorf("exchange = %q, %v, want %q without error", exchanges[0].Response, exchanges[0].Err, "ab")
+	}
+	if exchanges[1].Err == nil {
+		t.Error("failed exchange has no error")
+	}
+}
+
+func TestListModelsAndVersion(t *testing.T) {
+	srv, client := newTestClient(t)
+	srv.SetModels(uniai.ModelInfo{Name: "a"}, uniai.ModelInfo{Name: "b"})
+
+	ctx := context.Background()
+	models, err := client.ListModels(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 || models[0].Name != "a" || models[1].Name != "b" {
+		t.Errorf("models = %v, want a and b", models)
+	}
+
+	version, err := client.Version(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != uniaitest.Version {
+		t.Errorf("version = %q, want %q", version, uniaitest.Version)
+	}
+}
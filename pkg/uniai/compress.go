@@ -0,0 +1,216 @@
+package uniai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Modes of a [Compressor].
+const (
+	CompressOff       = "off"
+	CompressTrim      = "trim"
+	CompressSummarize = "summarize"
+)
+
+// ErrContextWindow is returned when a request does not fit in the context
+// window of the model even once its prompt is compressed, such as when its
+// images alone exceed it.
+var ErrContextWindow = errors.New("request exceeds the context window")
+
+// Compressor shrinks the prompt of a request exceeding the context window of
+// the model, instead of letting the request fail or be truncated by the
+// server. The instruction at the start of the prompt is kept as is; the
+// context after it, such as extracted text, previous answers and
+// attachments, is either trimmed, keeping its beginning, or condensed by the
+// model and then trimmed if still too long.
+type Compressor struct {
+	// Client sends the summarization requests of [CompressSummarize].
+	Client *Client
+	// Mode is [CompressTrim], [CompressSummarize] or [CompressOff].
+	Mode string
+	// ContextTokens is the context window of the model. Prompts are not
+	// compressed if it is 0.
+	ContextTokens int
+	// AnswerTokens are kept free for the answer, a quarter of the window if
+	// 0.
+	AnswerTokens int
+	// Options are the options of the summarization requests,
+	// [DefaultOptions] if nil.
+	Options map[string]any
+}
+
+// Compression describes how the prompt of a request was compressed.
+type Compression struct {
+	// Before and After are the estimated prompt tokens of the request.
+	Before int `json:"before"`
+	After  int `json:"after"`
+	// Summarized is set when the context was condensed by the model.
+	Summarized bool `json:"summarized,omitempty"`
+	// Dropped are the paragraphs of the context left out of the prompt, the
+	// last one possibly only in part.
+	Dropped []string `json:"dropped,omitempty"`
+}
+
+// DroppedChars returns the number of characters left out of the prompt.
+func (c *Compression) DroppedChars() int {
+	n := 0
+	for _, d := range c.Dropped {
+		n += utf8.RuneCountInString(d)
+	}
+
+	return n
+}
+
+// Compress shrinks the prompt of req to fit in the context window, keeping
+// instruction, its prefix, as is; if the prompt does not start with
+// instruction, its first paragraph is kept instead. It returns nil if the
+// request fits, or if the compressor is disabled.
+func (c *Compressor) Compress(ctx context.Context, req *GenerateRequest, instruction string) (*Compression, error) {
+	if c == nil || c.Mode == CompressOff || c.ContextTokens <= 0 {
+		return nil, nil
+	}
+
+	before := EstimateRequestTokens(req)
+	limit := c.ContextTokens - c.answerTokens()
+	if before <= limit {
+		return nil, nil
+	}
+
+	if instruction == "" || !strings.HasPrefix(req.Prompt, instruction) {
+		instruction, _, _ = strings.Cut(req.Prompt, "\n\n")
+	}
+	rest := strings.TrimSpace(strings.TrimPrefix(req.Prompt, instruction))
+	budget := limit - (before - EstimateTokens(req.Prompt)) - EstimateTokens(instruction)
+	if budget <= 0 || rest == "" {
+		return nil, fmt.Errorf("%w: %d estimated tokens of %d", ErrContextWindow, before, limit)
+	}
+
+	compression := &Compression{Before: before}
+	if c.Mode == CompressSummarize {
+		summary, err := c.summarize(ctx, req.Model, rest, budget)
+		if err != nil {
+			return nil, fmt.Errorf("failed to condense the prompt: %w", err)
+		}
+		rest, compression.Summarized = summary, true
+	}
+
+	rest, compression.Dropped = trimParagraphs(rest, budget)
+	req.Prompt = instruction + "\n\n" + rest
+	compression.After = EstimateRequestTokens(req)
+
+	return compression, nil
+}
+
+func (c *Compressor) answerTokens() int {
+	if c.AnswerTokens > 0 {
+		return c.AnswerTokens
+	}
+
+	return c.ContextTokens / 4
+}
+
+// summarize condenses text to about budget tokens, in chunks fitting in the
+// context window when it is longer than the window itself.
+func (c *Compressor) summarize(ctx context.Context, model, text string, budget int) (string, error) {
+	chunkTokens := max(c.ContextTokens-c.answerTokens()-200, 1)
+	chunks := chunkParagraphs(text, chunkTokens)
+	// About three words per four tokens.
+	words := max(budget*3/4/len(chunks), 20)
+
+	summaries := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		req := &GenerateRequest{
+			Model: model,
+			Prompt: fmt.Sprintf("Condense the following content in at most %d words. Keep every name, number, date, heading and table header a later question may need. "+
+				"Answer only with the condensed content.\n\n%s", words, chunk),
+			Options: c.Options,
+		}
+		if req.Options == nil {
+			req.Options = DefaultOptions
+		}
+		summary, err := c.Client.GenerateText(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		summaries[i] = strings.TrimSpace(summary)
+	}
+
+	return strings.Join(summaries, "\n\n"), nil
+}
+
+// trimParagraphs keeps the paragraphs of text fitting in budget tokens, in
+// order, cutting the first one that does not fit, and returns the dropped
+// ones.
+func trimParagraphs(text string, budget int) (string, []string) {
+	paragraphs := strings.Split(text, "\n\n")
+	var kept []string
+	used := 0
+	for i, paragraph := range paragraphs {
+		tokens := EstimateTokens(paragraph) + 1
+		if used+tokens <= budget {
+			kept = append(kept, paragraph)
+			used += tokens
+			continue
+		}
+
+		dropped := paragraphs[i:]
+		if head, tail := cutTokens(paragraph, budget-used-1); head != "" {
+			kept = append(kept, head+" […]")
+			dropped = append([]string{tail}, paragraphs[i+1:]...)
+		}
+		return strings.Join(kept, "\n\n"), dropped
+	}
+
+	return text, nil
+}
+
+// chunkParagraphs groups the paragraphs of text in chunks of at most
+// maxTokens, cutting the paragraphs longer than that.
+func chunkParagraphs(text string, maxTokens int) []string {
+	var (
+		chunks []string
+		chunk  []string
+		used   int
+	)
+	flush := func() {
+		if len(chunk) > 0 {
+			chunks = append(chunks, strings.Join(chunk, "\n\n"))
+			chunk, used = nil, 0
+		}
+	}
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		for EstimateTokens(paragraph) > maxTokens {
+			flush()
+			var head string
+			head, paragraph = cutTokens(paragraph, maxTokens)
+			chunks = append(chunks, head)
+		}
+		tokens := EstimateTokens(paragraph) + 1
+		if used+tokens > maxTokens {
+			flush()
+		}
+		chunk = append(chunk, paragraph)
+		used += tokens
+	}
+	flush()
+
+	return chunks
+}
+
+// cutTokens splits text after about tokens tokens, at a space if possible.
+func cutTokens(text string, tokens int) (string, string) {
+	if tokens <= 0 {
+		return "", text
+	}
+	runes := []rune(text)
+	n := min(tokens*4, len(runes))
+	if i := strings.LastIndexByte(string(runes[:n]), ' '); i > 0 && n < len(runes) {
+		head := string(runes[:n])[:i]
+		return head, strings.TrimLeft(text[len(head):], " ")
+	}
+
+	return string(runes[:n]), string(runes[n:])
+}
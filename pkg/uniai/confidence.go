@@ -0,0 +1,226 @@
+package uniai
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// FieldConfidence is the confidence in an extracted field, from the signals
+// available for it.
+type FieldConfidence struct {
+	// Path locates the field, as in $.lines[0].total.
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+	// Confidence is between 0 and 1: the mean of the consistency and the
+	// probability, those known, or 1 if neither is; fields violating the
+	// schema have confidence 0.
+	Confidence float64 `json:"confidence"`
+	// Consistency is the share of the answers giving the value, when there
+	// were several answers.
+	Consistency *float64 `json:"consistency,omitempty"`
+	// Probability is the geometric mean of the probabilities of the tokens
+	// of the value, when log probabilities were returned.
+	Probability *float64 `json:"probability,omitempty"`
+	// Violations are the schema violations of the field.
+	Violations []string `json:"violations,omitempty"`
+}
+
+// ConfidenceSignals are the inputs of [ScoreConfidence]; every signal is
+// optional.
+type ConfidenceSignals struct {
+	// Schema is validated against the value.
+	Schema map[string]any
+	// Agreement is the agreement of several answers, as computed by
+	// [Consensus].
+	Agreement []FieldAgreement
+	// Probabilities are the probabilities of the fields, by path, as
+	// computed by [FieldProbabilities].
+	Probabilities map[string]float64
+}
+
+// ScoreConfidence returns the confidence of every field of an extracted
+// value, sorted by path. Required fields missing from the value are included
+// with confidence 0 so they are reviewed too.
+func ScoreConfidence(value any, signals ConfidenceSignals) []FieldConfidence {
+	agreement := make(map[string]float64, len(signals.Agreement))
+	for _, f := range signals.Agreement {
+		agreement[f.Path] = f.Agreement
+	}
+
+	byPath := make(map[string]*FieldConfidence)
+	var fields []*FieldConfidence
+	for path, v := range leafValues("$", value, agreement) {
+		f := &FieldConfidence{Path: path, Value: v}
+		if a, ok := agreement[path]; ok {
+			f.Consistency = &a
+		}
+		if p, ok := signals.Probabilities[path]; ok {
+			f.Probability = &p
+		}
+		byPath[path] = f
+		fields = append(fields, f)
+	}
+
+	if signals.Schema != nil {
+		for _, violation := range ValidateSchema(value, signals.Schema) {
+			path, msg, _ := strings.Cut(violation, ": ")
+			if name, ok := strings.CutPrefix(msg, "missing "); ok {
+				path += "." + name
+			}
+			f, ok := byPath[path]
+			if !ok {
+				f = &FieldConfidence{Path: path}
+				byPath[path] = f
+				fields = append(fields, f)
+			}
+			f.Violations = append(f.Violations, msg)
+		}
+	}
+
+	scored := make([]FieldConfidence, 0, len(fields))
+	for _, f := range fields {
+		f.Confidence = 1
+		if len(f.Violations) > 0 {
+			f.Confidence = 0
+		} else if f.Consistency != nil || f.Probability != nil {
+			sum, n := 0.0, 0
+			for _, s := range []*float64{f.Consistency, f.Probability} {
+				if s != nil {
+					sum += *s
+					n++
+				}
+			}
+			f.Confidence = sum / float64(n)
+		}
+		scored = append(scored, *f)
+	}
+	sort.Slice(scored, func(i, j int) bool { return scored[i].Path < scored[j].Path })
+
+	return scored
+}
+
+// leafValues returns the values of the fields of v by path. Objects and lists
+// are descended into, except for paths with a known agreement, which were
+// compared as a whole.
+func leafValues(path string, v any, known map[string]float64) map[string]any {
+	leaves := make(map[string]any)
+	var walk func(path string, v any)
+	walk = func(path string, v any) {
+		if _, ok := known[path]; ok {
+			leaves[path] = v
+			return
+		}
+		switch v := v.(type) {
+		case map[string]any:
+			for k, child := range v {
+				walk(path+"."+k, child)
+			}
+		case []any:
+			if len(v) == 0 {
+				leaves[path] = v
+			}
+			for i, child := range v {
+				walk(fmt.Sprintf("%s[%d]", path, i), child)
+			}
+		default:
+			leaves[path] = v
+		}
+	}
+	walk(path, v)
+
+	return leaves
+}
+
+// FieldProbabilities returns the probability of every value of a JSON
+// answer, by path: the geometric mean of the probabilities of the tokens
+// spanning the value. text is the answer as generated, possibly surrounded by
+// prose, and logprobs are the log probabilities of its tokens, in order. It
+// returns nil if there are no log probabilities or the answer is not valid
+// JSON.
+func FieldProbabilities(text string, logprobs []Logprob) map[string]float64 {
+	if len(logprobs) == 0 {
+		return nil
+	}
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return nil
+	}
+
+	spans := make(map[string][2]int)
+	dec := json.NewDecoder(strings.NewReader(text[start:]))
+	if err := jsonSpans(dec, text[start:], "$", spans); err != nil {
+		return nil
+	}
+
+	// Offsets of the tokens in the answer.
+	offsets := make([]int, len(logprobs)+1)
+	for i, lp := range logprobs {
+		offsets[i+1] = offsets[i] + len(lp.Token)
+	}
+
+	probabilities := make(map[string]float64, len(spans))
+	for path, span := range spans {
+		from, to := span[0]+start, span[1]+start
+		sum, n := 0.0, 0
+		for i, lp := range logprobs {
+			if offsets[i] < to && offsets[i+1] > from {
+				sum += lp.Logprob
+				n++
+			}
+		}
+		if n > 0 {
+			probabilities[path] = math.Exp(sum / float64(n))
+		}
+	}
+
+	return probabilities
+}
+
+// jsonSpans records the byte span of the value read next from dec, and of
+// the values it contains, by path.
+func jsonSpans(dec *json.Decoder, text, path string, spans map[string][2]int) error {
+	from := int(dec.InputOffset())
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	// The offset before a token includes the separators preceding it.
+	for from < len(text) && strings.ContainsRune(" \t\r\n:,", rune(text[from])) {
+		from++
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return fmt.Errorf("invalid object key %v", key)
+			}
+			if err := jsonSpans(dec, text, path+"."+name, spans); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if err := jsonSpans(dec, text, fmt.Sprintf("%s[%d]", path, i), spans); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+	spans[path] = [2]int{from, int(dec.InputOffset())}
+
+	return nil
+}
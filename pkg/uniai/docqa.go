@@ -0,0 +1,206 @@
+package uniai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Defaults of a [DocumentQA].
+const (
+	DefaultDigestWords = 60
+	DefaultTargetPages = 4
+)
+
+// PageDigest is a compact description of a page of a document.
+type PageDigest struct {
+	Page int    `json:"page"`
+	Text string `json:"text"`
+}
+
+// DocumentAnswer is the answer to a question about a whole document.
+type DocumentAnswer struct {
+	Text string `json:"text"`
+	// Pages are the pages sent in full with the question, as picked by the
+	// model from the digest.
+	Pages []int `json:"pages"`
+}
+
+// DocumentQA answers questions whose answers span many pages of a document
+// without sending every page at full resolution. A compact digest of every
+// page is built first, once per document; a question is then answered with
+// the digest of the whole document plus the few pages the model picks from
+// it as needed in full.
+type DocumentQA struct {
+	Client *Client
+	Model  string
+	// DigestWords bounds the length of a page digest,
+	// [DefaultDigestWords] if 0.
+	DigestWords int
+	// TargetPages bounds the pages sent in full with a question,
+	// [DefaultTargetPages] if 0.
+	TargetPages int
+	// Options are the model options, [DefaultOptions] if nil.
+	Options map[string]any
+	// Progress, if set, is called after every page digest.
+	Progress func(digest PageDigest)
+}
+
+// Digest returns the digest of every page, from its text or its images,
+// which may be of low resolution.
+func (q *DocumentQA) Digest(ctx context.Context, pages []PageInput) ([]PageDigest, error) {
+	words := q.DigestWords
+	if words <= 0 {
+		words = DefaultDigestWords
+	}
+	instruction := fmt.Sprintf("Describe this page of a longer document in at most %d words, for an index used to find the pages answering questions about the document: "+
+		"its kind of content, the topics, entities, dates, amounts and table headers it contains. Answer with the description only.", words)
+
+	digests := make([]PageDigest, 0, len(pages))
+	for _, page := range pages {
+		req := q.request(instruction)
+		req.Images = page.Images
+		if text := strings.TrimSpace(page.Text); text != "" {
+			req.Prompt += "\n\nContent:\n" + text
+		}
+		text, err := q.Client.GenerateText(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", page.Number, err)
+		}
+
+		digest := PageDigest{Page: page.Number, Text: strings.TrimSpace(text)}
+		digests = append(digests, digest)
+		if q.Progress != nil {
+			q.Progress(digest)
+		}
+	}
+
+	return digests, nil
+}
+
+// pageNumberPattern matches the page numbers of the answer to the page
+// selection prompt.
+var pageNumberPattern = regexp.MustCompile(`\d+`)
+
+// SelectPages returns the pages of the digests the model needs in full to
+// answer question, at most TargetPages, possibly none.
+func (q *DocumentQA) SelectPages(ctx context.Context, question string, digests []PageDigest) ([]int, error) {
+	target := q.TargetPages
+	if target <= 0 {
+		target = DefaultTargetPages
+	}
+
+	req := q.request(fmt.Sprintf("Below is a digest of every page of a document, then a question about it. "+
+		"Which pages must be read in full to answer the question? Answer only with at most %d page numbers separated by commas, most useful first, or NONE if the digest is enough.\n\n%s\n\nQuestion: %s",
+		target, digestText(digests), question))
+	answer, err := q.Client.GenerateText(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]bool, len(digests))
+	for _, d := range digests {
+		known[d.Page] = true
+	}
+	pages := []int{}
+	for _, s := range pageNumberPattern.FindAllString(answer, -1) {
+		n, err := strconv.Atoi(s)
+		if err != nil || !known[n] || slices.Contains(pages, n) {
+			continue
+		}
+		if pages = append(pages, n); len(pages) == target {
+			break
+		}
+	}
+	slices.Sort(pages)
+
+	return pages, nil
+}
+
+// Answer answers question from the digests of the document and the pages
+// picked by [DocumentQA.SelectPages], whose full inputs are returned by
+// load. fn, if not nil, is called with the chunks of the answer as they are
+// streamed.
+func (q *DocumentQA) Answer(ctx context.Context, question string, digests []PageDigest, load func(ctx context.Context, pages []int) ([]PageInput, error), fn func(text string)) (*DocumentAnswer, error) {
+	if len(digests) == 0 {
+		return nil, errors.New("no page digests to answer from")
+	}
+
+	pages, err := q.SelectPages(ctx, question, digests)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select pages: %w", err)
+	}
+	var inputs []PageInput
+	if len(pages) > 0 {
+		if inputs, err = load(ctx, pages); err != nil {
+			return nil, fmt.Errorf("failed to load pages %v: %w", pages, err)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Answer the question about a document below. You are given a digest of every page")
+	if len(inputs) > 0 {
+		numbers := make([]string, len(pages))
+		for i, n := range pages {
+			numbers[i] = strconv.Itoa(n)
+		}
+		fmt.Fprintf(&sb, " and the full content of pages %s", strings.Join(numbers, ", "))
+	}
+	sb.WriteString(". Cite the pages your answer relies on as (page N).\n\nDigest:\n")
+	sb.WriteString(digestText(digests))
+
+	req := q.request("")
+	var texts []string
+	for _, page := range inputs {
+		req.Images = append(req.Images, page.Images...)
+		if text := strings.TrimSpace(page.Text); text != "" {
+			texts = append(texts, fmt.Sprintf("Page %d:\n%s", page.Number, text))
+		}
+	}
+	if len(texts) > 0 {
+		sb.WriteString("\n\nFull content:\n" + strings.Join(texts, "\n\n"))
+	}
+	fmt.Fprintf(&sb, "\n\nQuestion: %s", question)
+	req.Prompt = sb.String()
+
+	var answer strings.Builder
+	err = q.Client.Generate(ctx, req, func(resp GenerateResponse) error {
+		answer.WriteString(resp.Response)
+		if fn != nil {
+			fn(resp.Response)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DocumentAnswer{Text: strings.TrimSpace(q.Client.FilterResponse(req, answer.String())), Pages: pages}, nil
+}
+
+// request returns a request with prompt and the model and options of q.
+func (q *DocumentQA) request(prompt string) *GenerateRequest {
+	req := &GenerateRequest{Model: q.Model, Prompt: prompt, Options: q.Options}
+	if req.Model == "" {
+		req.Model = ModelDefault
+	}
+	if req.Options == nil {
+		req.Options = DefaultOptions
+	}
+
+	return req
+}
+
+// digestText lists the digests one page per line.
+func digestText(digests []PageDigest) string {
+	lines := make([]string, len(digests))
+	for i, d := range digests {
+		lines[i] = fmt.Sprintf("Page %d: %s", d.Page, strings.Join(strings.Fields(d.Text), " "))
+	}
+
+	return strings.Join(lines, "\n")
+}
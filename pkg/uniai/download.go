@@ -0,0 +1,241 @@
+package uniai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDownloadAttempts is the number of requests of a download, unless
+// [DownloadOptions] sets another one.
+const DefaultDownloadAttempts = 5
+
+// ErrChecksumMismatch is wrapped by the error of a download whose content
+// does not match its checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// DownloadOptions are the options of [Client.Download].
+type DownloadOptions struct {
+	// SHA256 is the expected hex SHA-256 of the artifact. If empty, the
+	// checksum announced by the API in the X-Checksum-SHA256, Repr-Digest or
+	// Digest header is verified, if any.
+	SHA256 string
+
+	// Attempts bounds the requests of the download, each resuming where the
+	// previous one stopped.
+	Attempts int
+}
+
+// Download saves the artifact at path of the API, such as an export, to
+// dest. The artifact is written to dest.part first: a transfer interrupted
+// by a flaky connection is resumed with a Range request, in the same call or
+// a later one, instead of starting over. Once complete, the content is
+// verified against its checksum and renamed to dest; a mismatching download
+// is removed, so the next call starts afresh.
+func (c *Client) Download(ctx context.Context, path, dest string, opts *DownloadOptions) error {
+	if c.baseURL == nil {
+		return errNoAPI
+	}
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+	attempts := opts.Attempts
+	if attempts <= 0 {
+		attempts = DefaultDownloadAttempts
+	}
+
+	part := dest + ".part"
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	expected := strings.ToLower(opts.SHA256)
+	var etag string
+	for attempt := 1; ; attempt++ {
+		var done bool
+		done, err = c.downloadPart(ctx, path, f, &etag, &expected)
+		if done || ctx.Err() != nil || attempt == attempts || !resumable(err) {
+			break
+		}
+
+		timer := time.NewTimer(time.Duration(attempt) * time.Second)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if expected != "" {
+		sum, err := fileSHA256(part)
+		if err != nil {
+			return err
+		}
+		if sum != expected {
+			os.Remove(part)
+			return fmt.Errorf("failed to download %s: %w: got sha256 %s, expected %s", path, ErrChecksumMismatch, sum, expected)
+		}
+	}
+
+	return os.Rename(part, dest)
+}
+
+// downloadPart requests the artifact from the end of f on, and appends it to
+// f. It reports whether the artifact is complete. The ETag of the artifact,
+// and its checksum if not known, are taken from the first response.
+func (c *Client) downloadPart(ctx context.Context, path string, f *os.File, etag, checksum *string) (bool, error) {
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, err
+	}
+
+	header := http.Header{"Accept": {"*/*"}}
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		// The partial content is only valid for the same artifact.
+		if *etag != "" {
+			header.Set("If-Range", *etag)
+		}
+	}
+	resp, err := c.send(ctx, http.MethodGet, path, nil, header)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The whole artifact, because it changed or the API ignores ranges.
+		if err := f.Truncate(0); err != nil {
+			return false, err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+	case http.StatusPartialContent:
+		if start := rangeStart(resp.Header.Get("Content-Range")); start != offset {
+			// Start over with the whole artifact next time.
+			if err := f.Truncate(0); err != nil {
+				return false, err
+			}
+			return false, fmt.Errorf("unexpected range %q resuming at %d", resp.Header.Get("Content-Range"), offset)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Nothing is left after offset: the artifact is complete.
+		if offset > 0 {
+			return true, nil
+		}
+		fallthrough
+	default:
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return false, err
+		}
+		if err := checkError(resp, body); err != nil {
+			return false, err
+		}
+		return false, fmt.Errorf("unexpected response %s", resp.Status)
+	}
+
+	if *etag == "" {
+		*etag = resp.Header.Get("ETag")
+	}
+	if *checksum == "" {
+		*checksum = headerSHA256(resp.Header)
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// resumable reports whether a download failing with err may succeed when
+// resumed: on network errors, rate limits and server errors.
+func resumable(err error) bool {
+	var status StatusError
+	var limited *RateLimitError
+	switch {
+	case err == nil:
+		return false
+	case errors.As(err, &limited):
+		return true
+	case errors.As(err, &status):
+		return status.StatusCode >= http.StatusInternalServerError
+	}
+
+	return true
+}
+
+// rangeStart returns the first byte of a Content-Range header such as
+// "bytes 100-199/200", or -1 if invalid.
+func rangeStart(contentRange string) int64 {
+	spec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return -1
+	}
+	start, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return -1
+	}
+
+	return n
+}
+
+// headerSHA256 returns the hex SHA-256 announced in X-Checksum-SHA256, in
+// Repr-Digest as sha-256=:base64:, or in Digest as SHA-256=base64, if any.
+func headerSHA256(h http.Header) string {
+	if sum := h.Get("X-Checksum-Sha256"); sum != "" {
+		return strings.ToLower(sum)
+	}
+	for _, name := range []string{"Repr-Digest", "Digest"} {
+		for _, digest := range strings.Split(h.Get(name), ",") {
+			alg, value, ok := strings.Cut(strings.TrimSpace(digest), "=")
+			if !ok || !strings.EqualFold(alg, "sha-256") {
+				continue
+			}
+			sum, err := base64.StdEncoding.DecodeString(strings.Trim(value, ":"))
+			if err == nil && len(sum) == sha256.Size {
+				return hex.EncodeToString(sum)
+			}
+		}
+	}
+
+	return ""
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
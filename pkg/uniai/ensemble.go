@@ -0,0 +1,334 @@
+package uniai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sampila/uniai-client/pkg/uniai/jsonrepair"
+)
+
+// Ensemble extracts structured data with several models, or several samples
+// of a model, and merges their answers field by field into a consensus.
+type Ensemble struct {
+	Client *Client
+	// Models answer every request, [ModelDefault] if empty.
+	Models []string
+	// Samples is the number of answers per model; samples use different
+	// seeds, so they only differ with a temperature above 0.
+	Samples int
+	// Logprobs asks for token log probabilities, which add the probability
+	// of the fields to their confidence.
+	Logprobs bool
+}
+
+// EnsembleAnswer is the answer of one model or sample.
+type EnsembleAnswer struct {
+	Source string `json:"source"`
+	Value  any    `json:"value,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	// Probabilities are the probabilities of the fields of the answer, by
+	// path, when log probabilities were returned.
+	Probabilities map[string]float64 `json:"-"`
+}
+
+// FieldValue is a value given for a field, with the answers giving it.
+type FieldValue struct {
+	Value   any      `json:"value"`
+	Sources []string `json:"sources"`
+}
+
+// FieldAgreement is how much the answers agree on a field.
+type FieldAgreement struct {
+	// Path locates the field, as in $.lines[0].total.
+	Path string `json:"path"`
+	// Agreement is the share of the answers giving the consensus value.
+	Agreement float64 `json:"agreement"`
+	// Values are the distinct values given, the most frequent first; it is
+	// only set when the answers disagree.
+	Values []FieldValue `json:"values,omitempty"`
+}
+
+// EnsembleResult is the consensus of the answers of an [Ensemble].
+type EnsembleResult struct {
+	Value any `json:"value"`
+	// Agreement is the mean agreement of the fields.
+	Agreement float64          `json:"agreement"`
+	Fields    []FieldAgreement `json:"fields"`
+	// Confidence scores every field of the value, from the agreement of the
+	// answers, the token probabilities and the schema.
+	Confidence []FieldConfidence `json:"confidence"`
+	Answers    []EnsembleAnswer  `json:"answers"`
+}
+
+// Disagreements returns the fields the answers disagree on.
+func (r *EnsembleResult) Disagreements() []FieldAgreement {
+	var fields []FieldAgreement
+	for _, f := range r.Fields {
+		if len(f.Values) > 1 {
+			fields = append(fields, f)
+		}
+	}
+
+	return fields
+}
+
+// Extract sends the request to every model, Samples times each, and merges
+// the JSON answers with [Consensus]. Answers are validated against schema
+// with [ExtractInto]; without a schema any JSON value is accepted. Answers
+// that fail are reported in the result; an error is only returned when they
+// all fail.
+func (e *Ensemble) Extract(ctx context.Context, req *GenerateRequest, schema map[string]any) (*EnsembleResult, error) {
+	models := e.Models
+	if len(models) == 0 {
+		models = []string{ModelDefault}
+	}
+	samples := max(e.Samples, 1)
+
+	var answers []EnsembleAnswer
+	for _, model := range models {
+		for i := 0; i < samples; i++ {
+			r := *req
+			r.Model = model
+			source := model
+			if samples > 1 {
+				r.Options = maps.Clone(req.Options)
+				if r.Options == nil {
+					r.Options = maps.Clone(DefaultOptions)
+				}
+				r.Options["seed"] = i + 1
+				source += "#" + strconv.Itoa(i+1)
+			}
+
+			r.Logprobs = r.Logprobs || e.Logprobs
+
+			var value any
+			raw, err := e.answer(ctx, &r, schema, &value)
+			answer := EnsembleAnswer{Source: source, Value: value}
+			if err != nil {
+				answer = EnsembleAnswer{Source: source, Error: err.Error()}
+			} else {
+				answer.Probabilities = FieldProbabilities(raw.text, raw.logprobs)
+			}
+			answers = append(answers, answer)
+		}
+	}
+
+	value, fields := Consensus(answers)
+	if fields == nil {
+		errs := make([]error, 0, len(answers))
+		for _, a := range answers {
+			errs = append(errs, fmt.Errorf("%s: %s", a.Source, a.Error))
+		}
+		return nil, fmt.Errorf("no valid answer: %w", errors.Join(errs...))
+	}
+
+	result := &EnsembleResult{Value: value, Fields: fields, Answers: answers}
+	for _, f := range fields {
+		result.Agreement += f.Agreement
+	}
+	result.Agreement /= float64(len(fields))
+
+	signals := ConfidenceSignals{Schema: schema, Probabilities: consensusProbabilities(fields, answers)}
+	valid := 0
+	for _, a := range answers {
+		if a.Error == "" {
+			valid++
+		}
+	}
+	if valid > 1 {
+		signals.Agreement = fields
+	}
+	result.Confidence = ScoreConfidence(value, signals)
+
+	return result, nil
+}
+
+// answer generates an answer into v, validated against schema if set, and
+// returns the raw answer.
+func (e *Ensemble) answer(ctx context.Context, req *GenerateRequest, schema map[string]any, v any) (rawAnswer, error) {
+	if schema != nil {
+		return extractInto(ctx, e.Client, req, schema, v)
+	}
+
+	r := *req
+	r.Format = json.RawMessage(`"json"`)
+	raw, err := generateRaw(ctx, e.Client, &r)
+	if err != nil {
+		return rawAnswer{}, err
+	}
+	text := ApplyFilters(e.Client.FilterResponse(&r, raw.text), DefaultFilters...)
+	if err := jsonrepair.Unmarshal([]byte(text), v); err != nil {
+		return rawAnswer{}, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+
+	return raw, nil
+}
+
+// consensusProbabilities returns the probability of every field of the
+// consensus: the mean probability in the answers giving the consensus value.
+func consensusProbabilities(fields []FieldAgreement, answers []EnsembleAnswer) map[string]float64 {
+	probabilities := make(map[string]float64)
+	for _, f := range fields {
+		var sources []string
+		if len(f.Values) > 0 {
+			sources = f.Values[0].Sources
+		}
+
+		sum, n := 0.0, 0
+		for _, a := range answers {
+			p, ok := a.Probabilities[f.Path]
+			if !ok || (sources != nil && !slices.Contains(sources, a.Source)) {
+				continue
+			}
+			sum += p
+			n++
+		}
+		if n > 0 {
+			probabilities[f.Path] = sum / float64(n)
+		}
+	}
+
+	return probabilities
+}
+
+// vote is the value an answer gives for a field.
+type vote struct {
+	source string
+	value  any
+}
+
+// Consensus merges JSON answers field by field: objects are merged key by
+// key, lists of objects or lists element by element when the answers have
+// the same length, and every other value is decided by majority. Values are
+// compared ignoring case and spacing; ties go to the earliest answer. A field
+// missing from an answer counts as a null vote. Failed answers are ignored;
+// nil fields are returned when no answer succeeded.
+func Consensus(answers []EnsembleAnswer) (any, []FieldAgreement) {
+	var votes []vote
+	for _, a := range answers {
+		if a.Error == "" {
+			votes = append(votes, vote{a.Source, a.Value})
+		}
+	}
+	if len(votes) == 0 {
+		return nil, nil
+	}
+
+	var fields []FieldAgreement
+	value := mergeVotes("$", votes, &fields)
+
+	return value, fields
+}
+
+func mergeVotes(path string, votes []vote, fields *[]FieldAgreement) any {
+	if objects, ok := allObjects(votes); ok {
+		keys := make(map[string]bool)
+		for _, o := range objects {
+			for k := range o {
+				keys[k] = true
+			}
+		}
+
+		merged := make(map[string]any, len(keys))
+		for _, k := range slices.Sorted(maps.Keys(keys)) {
+			sub := make([]vote, len(votes))
+			for i, v := range votes {
+				sub[i] = vote{v.source, objects[i][k]}
+			}
+			if value := mergeVotes(path+"."+k, sub, fields); value != nil {
+				merged[k] = value
+			}
+		}
+		return merged
+	}
+
+	if lists, ok := sameLengthLists(votes); ok {
+		merged := make([]any, len(lists[0]))
+		for j := range merged {
+			sub := make([]vote, len(votes))
+			for i, v := range votes {
+				sub[i] = vote{v.source, lists[i][j]}
+			}
+			merged[j] = mergeVotes(fmt.Sprintf("%s[%d]", path, j), sub, fields)
+		}
+		return merged
+	}
+
+	// Leaf values, and lists of different lengths, are decided by majority.
+	var groups []FieldValue
+	index := make(map[string]int)
+	for _, v := range votes {
+		key := canonicalValue(v.value)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, FieldValue{Value: v.value})
+		}
+		groups[i].Sources = append(groups[i].Sources, v.source)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].Sources) > len(groups[j].Sources)
+	})
+
+	field := FieldAgreement{Path: path, Agreement: float64(len(groups[0].Sources)) / float64(len(votes))}
+	if len(groups) > 1 {
+		field.Values = groups
+	}
+	*fields = append(*fields, field)
+
+	return groups[0].Value
+}
+
+// allObjects returns the votes as objects if they all are; missing (nil)
+// votes count as empty objects as long as one vote is an object.
+func allObjects(votes []vote) ([]map[string]any, bool) {
+	objects := make([]map[string]any, len(votes))
+	found := false
+	for i, v := range votes {
+		switch o := v.value.(type) {
+		case map[string]any:
+			objects[i] = o
+			found = true
+		case nil:
+		default:
+			return nil, false
+		}
+	}
+
+	return objects, found
+}
+
+// sameLengthLists returns the votes as lists if they all are lists of the
+// same, non-zero length.
+func sameLengthLists(votes []vote) ([][]any, bool) {
+	lists := make([][]any, len(votes))
+	for i, v := range votes {
+		l, ok := v.value.([]any)
+		if !ok || len(l) == 0 || (i > 0 && len(l) != len(lists[0])) {
+			return nil, false
+		}
+		lists[i] = l
+	}
+
+	return lists, true
+}
+
+// canonicalValue returns the JSON of a value with strings lowercased and
+// their spacing collapsed, for comparing answers.
+func canonicalValue(v any) string {
+	if s, ok := v.(string); ok {
+		v = strings.ToLower(strings.Join(strings.Fields(s), " "))
+	}
+
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,198 @@
+package uniai
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Entity types extracted by default by an [EntityExtractor].
+const (
+	EntityPerson       = "person"
+	EntityOrganization = "organization"
+	EntityDate         = "date"
+	EntityAmount       = "amount"
+)
+
+// DefaultEntityTypes are the entity types extracted when none are set.
+var DefaultEntityTypes = []string{EntityPerson, EntityOrganization, EntityDate, EntityAmount}
+
+// PageInput is the content of a document page: text, images or both.
+type PageInput struct {
+	Number int
+	Text   string
+	Images []ImageData
+}
+
+// Entity is a named entity found in a document.
+type Entity struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+	// Normalized is the canonical form of the entity: YYYY-MM-DD for dates,
+	// the number and ISO currency code for amounts (e.g. "1250.00 EUR").
+	Normalized string `json:"normalized,omitempty"`
+	// Pages are the pages the entity appears on.
+	Pages []int `json:"pages"`
+}
+
+// EntityExtractor finds named entities in document pages.
+type EntityExtractor struct {
+	Client *Client
+	Model  string
+	// Types are the entity types to extract, [DefaultEntityTypes] if empty.
+	Types []string
+	// Options are the model options, [DefaultOptions] if nil.
+	Options map[string]any
+}
+
+type entityAnswer struct {
+	Entities []struct {
+		Type       string `json:"type"`
+		Text       string `json:"text" description:"the entity as written in the document"`
+		Normalized string `json:"normalized,omitempty" description:"dates as YYYY-MM-DD, amounts as the number and ISO currency code"`
+	} `json:"entities"`
+}
+
+// Extract returns the entities of the pages, deduplicated across pages with
+// [MergeEntities].
+func (e *EntityExtractor) Extract(ctx context.Context, pages []PageInput) ([]Entity, error) {
+	var all []Entity
+	for _, page := range pages {
+		entities, err := e.ExtractPage(ctx, page)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", page.Number, err)
+		}
+		all = append(all, entities...)
+	}
+
+	return MergeEntities(all), nil
+}
+
+// ExtractPage returns the entities of a single page.
+func (e *EntityExtractor) ExtractPage(ctx context.Context, page PageInput) ([]Entity, error) {
+	types := e.Types
+	if len(types) == 0 {
+		types = DefaultEntityTypes
+	}
+	enum := make([]any, len(types))
+	for i, t := range types {
+		enum[i] = t
+	}
+
+	schema := SchemaFor[entityAnswer]()
+	item := schema["properties"].(map[string]any)["entities"].(map[string]any)["items"].(map[string]any)
+	item["properties"].(map[string]any)["type"] = map[string]any{"type": "string", "enum": enum}
+
+	prompt := "List every named entity of the following types on this page: " + strings.Join(types, ", ") +
+		". Give each entity once, as written, with its normalized form."
+	if page.Text != "" {
+		prompt += "\n\nPage content:\n" + strings.TrimSpace(page.Text)
+	}
+
+	req := &GenerateRequest{
+		Model:   e.Model,
+		Prompt:  prompt,
+		Images:  page.Images,
+		Options: e.Options,
+	}
+	if req.Model == "" {
+		req.Model = ModelDefault
+	}
+	if req.Options == nil {
+		req.Options = DefaultOptions
+	}
+
+	var answer entityAnswer
+	if err := ExtractInto(ctx, e.Client, req, schema, &answer); err != nil {
+		return nil, err
+	}
+
+	entities := make([]Entity, 0, len(answer.Entities))
+	for _, a := range answer.Entities {
+		if strings.TrimSpace(a.Text) == "" {
+			continue
+		}
+		entities = append(entities, Entity{
+			Type:       a.Type,
+			Text:       strings.Join(strings.Fields(a.Text), " "),
+			Normalized: strings.TrimSpace(a.Normalized),
+			Pages:      []int{page.Number},
+		})
+	}
+
+	return entities, nil
+}
+
+// MergeEntities deduplicates entities of the same type whose normalized form
+// (or text) matches ignoring case, spacing and punctuation, merging their
+// pages. The first occurrence is kept. Entities are sorted by type, then by
+// first page.
+func MergeEntities(entities []Entity) []Entity {
+	index := make(map[string]int)
+	var merged []Entity
+	for _, e := range entities {
+		key := e.Type + "\x00" + entityKey(e)
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			e.Pages = append([]int(nil), e.Pages...)
+			merged = append(merged, e)
+			continue
+		}
+
+		m := &merged[i]
+		if m.Normalized == "" {
+			m.Normalized = e.Normalized
+		}
+		for _, p := range e.Pages {
+			if !slices.Contains(m.Pages, p) {
+				m.Pages = append(m.Pages, p)
+			}
+		}
+	}
+
+	for i := range merged {
+		sort.Ints(merged[i].Pages)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].Type != merged[j].Type {
+			return merged[i].Type < merged[j].Type
+		}
+		return firstPage(merged[i]) < firstPage(merged[j])
+	})
+
+	return merged
+}
+
+func entityKey(e Entity) string {
+	s := e.Normalized
+	if s == "" {
+		s = e.Text
+	}
+
+	return foldKey(s)
+}
+
+// foldKey returns the lowercase letters and digits of s, for comparing text
+// as written in different places.
+func foldKey(s string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
+
+func firstPage(e Entity) int {
+	if len(e.Pages) == 0 {
+		return 0
+	}
+
+	return e.Pages[0]
+}
@@ -0,0 +1,158 @@
+package uniai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sampila/uniai-client/pkg/uniai/jsonrepair"
+)
+
+// ValidationError is returned by [Extract] and [ParseJSON] when the answer
+// does not match the schema of the target type.
+type ValidationError struct {
+	Response   string   // the (filtered) answer of the model
+	Violations []string // one message per schema violation
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("response does not match the schema: %s", strings.Join(e.Violations, "; "))
+}
+
+// Extract asks the model for a value of type T and returns it. The JSON
+// schema of T (see [SchemaFor]) is sent as the response format and appended
+// to the prompt as formatting instructions. The answer is repaired if it is
+// not valid JSON and validated against the schema; when it does not match,
+// the request is retried once with the violations, after which a
+// *[ValidationError] is returned.
+//
+//	type Invoice struct {
+//		Number string  `json:"number" description:"invoice number"`
+//		Total  float64 `json:"total"`
+//		Lines  []Line  `json:"lines,omitempty"`
+//	}
+//	invoice, err := uniai.Extract[Invoice](ctx, client, &uniai.GenerateRequest{
+//		Model:  uniai.ModelDefault,
+//		Prompt: "Extract the invoice.",
+//		Images: []uniai.ImageData{page},
+//	})
+func Extract[T any](ctx context.Context, client *Client, req *GenerateRequest) (T, error) {
+	var out T
+	if err := ExtractInto(ctx, client, req, SchemaFor[T](), &out); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return out, nil
+}
+
+// ExtractInto is [Extract] with an explicit schema, for targets whose schema
+// is only known at run time. The validated answer is unmarshaled into v.
+func ExtractInto(ctx context.Context, client *Client, req *GenerateRequest, schema map[string]any, v any) error {
+	_, err := extractInto(ctx, client, req, schema, v)
+	return err
+}
+
+// rawAnswer is a complete response as generated, before filters, with the
+// log probabilities of its tokens when they were requested.
+type rawAnswer struct {
+	text     string
+	logprobs []Logprob
+}
+
+// generateRaw generates a complete response without applying filters.
+func generateRaw(ctx context.Context, client *Client, req *GenerateRequest) (rawAnswer, error) {
+	var sb strings.Builder
+	var logprobs []Logprob
+	err := client.Generate(ctx, req, func(resp GenerateResponse) error {
+		sb.WriteString(resp.Response)
+		logprobs = append(logprobs, resp.Logprobs...)
+		return nil
+	})
+
+	return rawAnswer{text: sb.String(), logprobs: logprobs}, err
+}
+
+// extractInto is [ExtractInto], returning the raw answer that was accepted.
+func extractInto(ctx context.Context, client *Client, req *GenerateRequest, schema map[string]any, v any) (rawAnswer, error) {
+	format, err := json.Marshal(schema)
+	if err != nil {
+		return rawAnswer{}, err
+	}
+	instructions, err := JSONInstructions(schema)
+	if err != nil {
+		return rawAnswer{}, err
+	}
+
+	r := *req
+	r.Format = format
+	r.Prompt = req.Prompt + "\n\n" + instructions
+
+	var verr *ValidationError
+	for attempt := 0; attempt < 2; attempt++ {
+		if verr != nil {
+			r.Prompt += "\n\nYour previous answer was invalid: " + strings.Join(verr.Violations, "; ") + ". Correct it."
+		}
+
+		raw, err := generateRaw(ctx, client, &r)
+		if err != nil {
+			return rawAnswer{}, err
+		}
+		text := ApplyFilters(client.FilterResponse(&r, raw.text), DefaultFilters...)
+
+		repaired, err := ParseJSON(text, schema)
+		if errors.As(err, &verr) {
+			continue
+		}
+		if err != nil {
+			return rawAnswer{}, err
+		}
+
+		if err := json.Unmarshal(repaired, v); err != nil {
+			return rawAnswer{}, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		return raw, nil
+	}
+
+	return rawAnswer{}, verr
+}
+
+// JSONInstructions returns the instructions appended to a prompt asking for
+// a JSON answer matching schema, or any JSON value if schema is nil.
+func JSONInstructions(schema map[string]any) (string, error) {
+	if schema == nil {
+		return "Answer only with a JSON value, without any other text.", nil
+	}
+	indented, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return "Answer only with a JSON value matching this JSON schema, without any other text:\n" + string(indented), nil
+}
+
+// ParseJSON returns the JSON value of an answer, repaired if it is not valid
+// JSON, such as an answer in a code fence or with a trailing comma. If the
+// answer is not JSON or does not match schema, when not nil, it returns a
+// *[ValidationError].
+func ParseJSON(answer string, schema map[string]any) (json.RawMessage, error) {
+	text := strings.TrimSpace(answer)
+	repaired, err := jsonrepair.Repair(text)
+	if err != nil {
+		return nil, &ValidationError{Response: text, Violations: []string{err.Error()}}
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(repaired), &value); err != nil {
+		return nil, &ValidationError{Response: text, Violations: []string{err.Error()}}
+	}
+	if schema != nil {
+		if violations := ValidateSchema(value, schema); len(violations) > 0 {
+			return nil, &ValidationError{Response: text, Violations: violations}
+		}
+	}
+
+	return json.RawMessage(repaired), nil
+}
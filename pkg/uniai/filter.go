@@ -0,0 +1,113 @@
+package uniai
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ResponseFilter transforms the complete text of a response. Filters are
+// plain functions, so any func(string) string can be used as one.
+type ResponseFilter func(response string) string
+
+// DefaultFilters removes what models commonly wrap around an answer:
+// reasoning blocks, a conversational lead-in and a code fence.
+var DefaultFilters = []ResponseFilter{StripThinking, StripPreamble, TrimCodeFences}
+
+var (
+	thinkingBlock = regexp.MustCompile(`(?is)<(think|thinking|reasoning)>.*?</(think|thinking|reasoning)>`)
+	preambleLine  = regexp.MustCompile(`(?i)^(sure|certainly|of course|okay|ok|here is|here's|here are|below is|the following is)\b[^\n]*:\s*\n`)
+)
+
+// ApplyFilters runs the filters on a response in order.
+func ApplyFilters(response string, filters ...ResponseFilter) string {
+	for _, f := range filters {
+		response = f(response)
+	}
+
+	return response
+}
+
+// TrimCodeFences removes a Markdown code fence (with an optional language,
+// as in ```json) wrapping the whole response.
+func TrimCodeFences(response string) string {
+	text := strings.TrimSpace(response)
+	if !strings.HasPrefix(text, "```") {
+		return response
+	}
+
+	nl := strings.IndexByte(text, '\n')
+	if nl < 0 {
+		return strings.TrimSpace(strings.Trim(text, "`"))
+	}
+	text = strings.TrimSpace(text[nl+1:])
+
+	return strings.TrimSpace(strings.TrimSuffix(text, "```"))
+}
+
+// StripThinking removes <think>, <thinking> and <reasoning> blocks in which
+// reasoning models write their chain of thought.
+func StripThinking(response string) string {
+	if !strings.Contains(response, "<") {
+		return response
+	}
+
+	return strings.TrimSpace(thinkingBlock.ReplaceAllString(response, ""))
+}
+
+// StripPreamble removes a conversational lead-in line such as "Sure, here is
+// the extracted data:" preceding the answer.
+func StripPreamble(response string) string {
+	text := strings.TrimLeft(response, " \t\r\n")
+	loc := preambleLine.FindStringIndex(text)
+	if loc == nil {
+		return response
+	}
+
+	return strings.TrimSpace(text[loc[1]:])
+}
+
+// TrimSpace removes leading and trailing white space.
+func TrimSpace(response string) string {
+	return strings.TrimSpace(response)
+}
+
+// Replace returns a filter replacing the matches of re with repl, which may
+// refer to submatches as in [regexp.Regexp.ReplaceAllString].
+func Replace(re *regexp.Regexp, repl string) ResponseFilter {
+	return func(response string) string {
+		return re.ReplaceAllString(response, repl)
+	}
+}
+
+// ParseFilter returns the filter named by spec: "fences", "thinking",
+// "preamble", "trim", "default" (all of [DefaultFilters]) or a substitution
+// "s/regex/replacement/" (any delimiter may replace the slash).
+func ParseFilter(spec string) ([]ResponseFilter, error) {
+	switch spec {
+	case "fences":
+		return []ResponseFilter{TrimCodeFences}, nil
+	case "thinking":
+		return []ResponseFilter{StripThinking}, nil
+	case "preamble":
+		return []ResponseFilter{StripPreamble}, nil
+	case "trim":
+		return []ResponseFilter{TrimSpace}, nil
+	case "default":
+		return DefaultFilters, nil
+	}
+
+	if len(spec) < 4 || spec[0] != 's' {
+		return nil, fmt.Errorf("unknown response filter %q", spec)
+	}
+	parts := strings.Split(spec[2:], spec[1:2])
+	if len(parts) != 3 || parts[2] != "" {
+		return nil, fmt.Errorf("invalid substitution %q, expected s/regex/replacement/", spec)
+	}
+	re, err := regexp.Compile(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid substitution %q: %w", spec, err)
+	}
+
+	return []ResponseFilter{Replace(re, parts[1])}, nil
+}
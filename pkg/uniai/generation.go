@@ -0,0 +1,93 @@
+package uniai
+
+import (
+	"context"
+	"io"
+)
+
+// generationBuffer is the number of responses a [Generation] receives ahead
+// of its reader.
+const generationBuffer = 16
+
+// Generation is a generation started by [Client.StartGenerate], whose
+// responses are pulled with [Generation.Recv] or received from
+// [Generation.Responses], instead of being pushed to a callback.
+type Generation struct {
+	responses chan GenerateResponse
+	cancel    context.CancelFunc
+	done      chan struct{}
+	err       error // set before done is closed
+}
+
+// StartGenerate starts generating a response to req in the background and
+// returns its handle. The generation runs until the last response is
+// received, an error occurs, ctx is done or [Generation.Cancel] is called;
+// the handle must be read to the end or cancelled to release the
+// connection.
+func (c *Client) StartGenerate(ctx context.Context, req *GenerateRequest) *Generation {
+	ctx, cancel := context.WithCancel(ctx)
+	g := &Generation{
+		responses: make(chan GenerateResponse, generationBuffer),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+
+	go func() {
+		g.err = c.Generate(ctx, req, func(resp GenerateResponse) error {
+			select {
+			case g.responses <- resp:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		cancel()
+		// done is closed first, so that Err sees the error once responses
+		// is closed.
+		close(g.done)
+		close(g.responses)
+	}()
+
+	return g
+}
+
+// Recv returns the next response, blocking until it is received. After the
+// last response it returns [io.EOF], or the error of the generation if it
+// failed, [context.Canceled] once cancelled.
+func (g *Generation) Recv() (GenerateResponse, error) {
+	resp, ok := <-g.responses
+	if !ok {
+		return GenerateResponse{}, g.Err()
+	}
+
+	return resp, nil
+}
+
+// Responses returns the channel of the responses, closed after the last one,
+// for select loops. [Generation.Err] then returns how the generation ended.
+func (g *Generation) Responses() <-chan GenerateResponse {
+	return g.responses
+}
+
+// Err returns the error of a finished generation, [io.EOF] if it completed.
+// It returns nil while the generation is running.
+func (g *Generation) Err() error {
+	select {
+	case <-g.done:
+	default:
+		return nil
+	}
+	if g.err != nil {
+		return g.err
+	}
+
+	return io.EOF
+}
+
+// Cancel stops the generation and waits until its connection is closed. The
+// responses already received can still be read. It may be called more than
+// once, and after the generation finished.
+func (g *Generation) Cancel() {
+	g.cancel()
+	<-g.done
+}
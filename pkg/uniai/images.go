@@ -0,0 +1,165 @@
+package uniai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register the WebP decoder
+)
+
+// resizeQuality is the JPEG quality of images downscaled to
+// MaxImageDimension.
+const resizeQuality = 90
+
+// withImagePaths returns the request with the images of ImagePaths read and
+// appended to Images, downscaled to MaxImageDimension if set. The request is
+// returned unchanged without image paths; otherwise it is a copy, so the
+// images are only held while the request is sent.
+func (r *GenerateRequest) withImagePaths() (*GenerateRequest, error) {
+	if len(r.ImagePaths) == 0 {
+		return r, nil
+	}
+
+	req := *r
+	req.Images = append(make([]ImageData, 0, len(r.Images)+len(r.ImagePaths)), r.Images...)
+	req.ImagePaths = nil
+	for _, path := range r.ImagePaths {
+		img, err := ReadImage(path, r.MaxImageDimension)
+		if err != nil {
+			return nil, err
+		}
+		req.Images = append(req.Images, img)
+	}
+
+	return &req, nil
+}
+
+// ReadImage reads the image file at path for a request. With maxDimension
+// above 0, larger JPEG, PNG or WebP images are downscaled to fit it and
+// re-encoded, PNG images as PNG and the others as JPEG.
+func ReadImage(path string, maxDimension int) (ImageData, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if maxDimension <= 0 {
+		return data, nil
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %s: %w", path, err)
+	}
+	if cfg.Width <= maxDimension && cfg.Height <= maxDimension {
+		return data, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %s: %w", path, err)
+	}
+	scale := float64(maxDimension) / float64(max(cfg.Width, cfg.Height))
+	width, height := max(1, int(float64(cfg.Width)*scale)), max(1, int(float64(cfg.Height)*scale))
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+
+	var buf bytes.Buffer
+	if format == "png" {
+		err = png.Encode(&buf, dst)
+	} else {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: resizeQuality})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image %s: %w", path, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// PassImageURLs makes the client send the ImageURLs of requests to the API,
+// for servers that fetch images by URL, instead of downloading and inlining
+// them. Clients of other providers always inline them.
+func (c *Client) PassImageURLs(pass bool) {
+	c.passImageURLs = pass
+}
+
+// withImageURLs returns the request with the images of ImageURLs downloaded
+// and appended to Images, unless the client passes them to the API. As with
+// withImagePaths, the request is copied if it changes.
+func (c *Client) withImageURLs(ctx context.Context, r *GenerateRequest) (*GenerateRequest, error) {
+	if len(r.ImageURLs) == 0 || (c.passImageURLs && c.provider == nil) {
+		return r, nil
+	}
+	if err := validateImageURLs(r.ImageURLs); err != nil {
+		return nil, err
+	}
+
+	req := *r
+	req.Images = append(make([]ImageData, 0, len(r.Images)+len(r.ImageURLs)), r.Images...)
+	req.ImageURLs = nil
+	for _, rawURL := range r.ImageURLs {
+		img, err := c.fetchImage(ctx, rawURL)
+		if err != nil {
+			return nil, err
+		}
+		req.Images = append(req.Images, img)
+	}
+
+	return &req, nil
+}
+
+// fetchImage downloads the image at rawURL, failing without reading it all
+// when it is larger than MaxImageSize.
+func (c *Client) fetchImage(ctx context.Context, rawURL string) (ImageData, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := c.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	// Presigned URLs carry their credentials in the query, kept out of
+	// errors.
+	name := redactURL(httpReq.URL)
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("failed to fetch image %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch image %s: %s", name, resp.Status)
+	}
+	if resp.ContentLength > MaxImageSize {
+		return nil, invalid("image %s is %d bytes, more than the %d allowed", name, resp.ContentLength, MaxImageSize)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxImageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch image %s: %w", name, err)
+	}
+	if len(data) > MaxImageSize {
+		return nil, invalid("image %s is more than the %d bytes allowed", name, MaxImageSize)
+	}
+
+	return data, nil
+}
+
+// redactURL returns u without its query, user info and fragment.
+func redactURL(u *url.URL) string {
+	return u.Scheme + "://" + u.Host + u.Path
+}
@@ -0,0 +1,143 @@
+package uniai
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"  // register the GIF decoder
+	_ "image/jpeg" // register the JPEG decoder
+	_ "image/png"  // register the PNG decoder
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// ImageTokenScheme is how the models of a family turn an image into context
+// tokens, to estimate the cost of a request before sending it. Images are
+// either a fixed number of tokens, or scaled to fit MaxDimension and cut
+// into Tile×Tile tiles of TileTokens each, plus BaseTokens.
+type ImageTokenScheme struct {
+	// Family is the prefix of the model names of the family, "" for the
+	// default scheme.
+	Family string
+
+	// Fixed is the tokens of any image, for models resizing every image to
+	// the same size.
+	Fixed int
+
+	MaxDimension int // 0 for no limit
+	Tile         int
+	TileTokens   int
+	MaxTiles     int // 0 for no limit
+	BaseTokens   int
+}
+
+// ImageTokenSchemes are the schemes of known model families, most specific
+// prefix first. Models of other families use DefaultImageTokenScheme.
+var ImageTokenSchemes = []ImageTokenScheme{
+	{Family: "llava", Fixed: 576},
+	{Family: "bakllava", Fixed: 576},
+	{Family: "gemma3", Fixed: 256},
+	{Family: "llama3.2-vision", Tile: 560, TileTokens: 1601, MaxTiles: 4},
+	{Family: "minicpm-v", Tile: 448, TileTokens: 64, MaxTiles: 9, BaseTokens: 64},
+	// 14 px patches merged 2×2 into a token.
+	{Family: "qwen2.5vl", Tile: 28, TileTokens: 1, BaseTokens: 2},
+	{Family: "qwen2.5-vl", Tile: 28, TileTokens: 1, BaseTokens: 2},
+	{Family: "qwen2-vl", Tile: 28, TileTokens: 1, BaseTokens: 2},
+	{Family: "gpt-4", MaxDimension: 2048, Tile: 512, TileTokens: 170, BaseTokens: 85},
+	{Family: "claude", MaxDimension: 1568, Tile: 28, TileTokens: 1},
+}
+
+// DefaultImageTokenScheme is the scheme of the UniAI models and of unknown
+// families: 512 px tiles, about ImageTokens for a 1024 px square image.
+var DefaultImageTokenScheme = ImageTokenScheme{MaxDimension: 2048, Tile: 512, TileTokens: 170, BaseTokens: 85}
+
+// ImageTokenSchemeFor returns the scheme of model, matched by the prefix of
+// its name without a namespace such as "library/".
+func ImageTokenSchemeFor(model string) ImageTokenScheme {
+	name := strings.ToLower(model)
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, s := range ImageTokenSchemes {
+		if strings.HasPrefix(name, s.Family) {
+			return s
+		}
+	}
+
+	return DefaultImageTokenScheme
+}
+
+// Tokens returns the estimated tokens of an image of width×height pixels.
+func (s ImageTokenScheme) Tokens(width, height int) int {
+	if width <= 0 || height <= 0 {
+		return 0
+	}
+	if s.Fixed > 0 {
+		return s.Fixed
+	}
+
+	w, h := float64(width), float64(height)
+	if s.MaxDimension > 0 && max(w, h) > float64(s.MaxDimension) {
+		scale := float64(s.MaxDimension) / max(w, h)
+		w, h = w*scale, h*scale
+	}
+	tile := float64(max(s.Tile, 1))
+	tiles := int(math.Ceil(w/tile) * math.Ceil(h/tile))
+	if s.MaxTiles > 0 {
+		tiles = min(tiles, s.MaxTiles)
+	}
+
+	return s.BaseTokens + tiles*s.TileTokens
+}
+
+// EstimateImageTokens returns the estimated context tokens of img sent to
+// model, from the dimensions in the header of the JPEG, PNG, GIF or WebP
+// image. With maxDimension above 0, as in [GenerateRequest], the image is
+// counted downscaled to fit it.
+func EstimateImageTokens(model string, img ImageData, maxDimension int) (int, error) {
+	return estimateImageTokens(model, bytes.NewReader(img), maxDimension)
+}
+
+func estimateImageTokens(model string, r io.Reader, maxDimension int) (int, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+	width, height := cfg.Width, cfg.Height
+	if maxDimension > 0 && max(width, height) > maxDimension {
+		scale := float64(maxDimension) / float64(max(width, height))
+		width, height = max(1, int(float64(width)*scale)), max(1, int(float64(height)*scale))
+	}
+
+	return ImageTokenSchemeFor(model).Tokens(width, height), nil
+}
+
+// EstimateRequestTokens returns the estimated prompt tokens of req: the
+// tokens of its system prompt and prompt, and of its inline images and image
+// files. Images that cannot be decoded are counted as ImageTokens; image
+// URLs, not known before they are fetched, too.
+func EstimateRequestTokens(req *GenerateRequest) int {
+	tokens := EstimateTokens(req.System) + EstimateTokens(req.Prompt)
+	for _, img := range req.Images {
+		n, err := EstimateImageTokens(req.Model, img, req.MaxImageDimension)
+		if err != nil {
+			n = ImageTokens
+		}
+		tokens += n
+	}
+	for _, path := range req.ImagePaths {
+		n := ImageTokens
+		// Only the header of the file is read.
+		if f, err := os.Open(path); err == nil {
+			if estimate, err := estimateImageTokens(req.Model, f, req.MaxImageDimension); err == nil {
+				n = estimate
+			}
+			f.Close()
+		}
+		tokens += n
+	}
+
+	return tokens + len(req.ImageURLs)*ImageTokens
+}
@@ -0,0 +1,376 @@
+// Package jsonrepair fixes the defects commonly found in JSON written by
+// language models, so that almost-valid answers can still be unmarshaled:
+// Markdown fences and surrounding prose, trailing or missing commas, unquoted
+// or single-quoted keys and strings, comments, Python literals, unescaped
+// quotes and control characters in strings, and objects or lists cut off by
+// the end of the output.
+package jsonrepair
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrNoJSON is returned when the text contains no JSON object or list.
+var ErrNoJSON = errors.New("no JSON object or list found")
+
+// Unmarshal parses data into v like [json.Unmarshal], repairing it first if
+// it is not valid JSON.
+func Unmarshal(data []byte, v any) error {
+	err := json.Unmarshal(data, v)
+	if err == nil {
+		return nil
+	}
+
+	repaired, repairErr := Repair(string(data))
+	if repairErr != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(repaired), v)
+}
+
+// Repair returns the first JSON object or list of s as valid JSON. Text
+// before and after it is dropped. Valid JSON is returned unchanged apart from
+// insignificant white space.
+func Repair(s string) (string, error) {
+	start := strings.IndexAny(s, "{[")
+	if start < 0 {
+		return "", ErrNoJSON
+	}
+
+	r := &repairer{in: []rune(s[start:])}
+	r.run()
+
+	return r.out.String(), nil
+}
+
+// Kinds of the last token written.
+const (
+	tokNone  = iota
+	tokOpen  // { or [
+	tokComma // ,
+	tokColon // :
+	tokKey   // object key
+	tokValue // string, number, literal or closed container
+)
+
+type repairer struct {
+	in    []rune
+	pos   int
+	out   strings.Builder
+	stack []rune // open containers, '{' or '['
+	last  int
+}
+
+func (r *repairer) run() {
+	for r.pos < len(r.in) {
+		c := r.in[r.pos]
+		switch {
+		case unicode.IsSpace(c):
+			r.pos++
+		case c == '/' && r.comment():
+		case c == '{' || c == '[':
+			r.beginValue()
+			r.out.WriteRune(c)
+			r.stack = append(r.stack, c)
+			r.last = tokOpen
+			r.pos++
+		case c == '}' || c == ']':
+			r.pos++
+			if r.close() {
+				return
+			}
+		case c == ',':
+			r.pos++
+			if r.last == tokValue && !r.closingNext() {
+				r.out.WriteByte(',')
+				r.last = tokComma
+			}
+		case c == ':':
+			r.pos++
+			if r.last == tokKey {
+				r.out.WriteByte(':')
+				r.last = tokColon
+			}
+		case isQuote(c):
+			r.beginValue()
+			r.str(c)
+		case c == '-' || c == '+' || c == '.' || unicode.IsDigit(c):
+			r.beginValue()
+			r.number()
+		default:
+			r.beginValue()
+			r.word()
+		}
+	}
+
+	// The output was cut off: close what is still open.
+	for len(r.stack) > 0 {
+		r.close()
+	}
+}
+
+// expectKey reports whether the next token is an object key.
+func (r *repairer) expectKey() bool {
+	return len(r.stack) > 0 && r.stack[len(r.stack)-1] == '{' && (r.last == tokOpen || r.last == tokComma)
+}
+
+// beginValue writes the comma or colon missing before a new token.
+func (r *repairer) beginValue() {
+	switch r.last {
+	case tokValue:
+		if len(r.stack) > 0 {
+			r.out.WriteByte(',')
+			r.last = tokComma
+		}
+	case tokKey:
+		r.out.WriteByte(':')
+		r.last = tokColon
+	}
+}
+
+// close closes the innermost container and reports whether it was the
+// outermost one.
+func (r *repairer) close() bool {
+	if len(r.stack) == 0 {
+		return true
+	}
+
+	switch r.last {
+	case tokKey:
+		r.out.WriteString(":null")
+	case tokColon:
+		r.out.WriteString("null")
+	}
+
+	// A mismatched bracket closes the innermost container anyway.
+	if r.stack[len(r.stack)-1] == '{' {
+		r.out.WriteByte('}')
+	} else {
+		r.out.WriteByte(']')
+	}
+	r.stack = r.stack[:len(r.stack)-1]
+	r.last = tokValue
+
+	return len(r.stack) == 0
+}
+
+// closingNext reports whether the next significant character closes a
+// container or the input ends, making a preceding comma a trailing one.
+func (r *repairer) closingNext() bool {
+	for i := r.pos; i < len(r.in); i++ {
+		c := r.in[i]
+		if unicode.IsSpace(c) || c == ',' {
+			continue
+		}
+		return c == '}' || c == ']'
+	}
+
+	return true
+}
+
+// comment skips a // or /* */ comment and reports whether there was one.
+func (r *repairer) comment() bool {
+	if r.pos+1 >= len(r.in) {
+		return false
+	}
+
+	switch r.in[r.pos+1] {
+	case '/':
+		for r.pos < len(r.in) && r.in[r.pos] != '\n' {
+			r.pos++
+		}
+		return true
+	case '*':
+		r.pos += 2
+		for r.pos < len(r.in) && !(r.in[r.pos] == '*' && r.pos+1 < len(r.in) && r.in[r.pos+1] == '/') {
+			r.pos++
+		}
+		r.pos = min(r.pos+2, len(r.in))
+		return true
+	}
+
+	return false
+}
+
+func isQuote(c rune) bool {
+	switch c {
+	case '"', '\'', '`', '“', '”', '‘', '’':
+		return true
+	}
+
+	return false
+}
+
+// closingQuote returns the quote ending a string opened with c.
+func closingQuote(c rune) rune {
+	switch c {
+	case '“':
+		return '”'
+	case '‘':
+		return '’'
+	}
+
+	return c
+}
+
+// str copies a string opened with quote, re-quoting and escaping it as
+// needed. A quote only ends the string when it is followed by a delimiter, so
+// unescaped quotes inside the text are kept.
+func (r *repairer) str(quote rune) {
+	key := r.expectKey()
+	end := closingQuote(quote)
+	r.pos++
+
+	var sb strings.Builder
+	for r.pos < len(r.in) {
+		c := r.in[r.pos]
+		r.pos++
+
+		if c == '\\' && r.pos < len(r.in) {
+			next := r.in[r.pos]
+			r.pos++
+			switch next {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't', 'u':
+				sb.WriteRune('\\')
+				sb.WriteRune(next)
+			default:
+				// \' and invalid escapes such as \d
+				if next != '\'' {
+					sb.WriteString(`\\`)
+				}
+				sb.WriteRune(next)
+			}
+			continue
+		}
+
+		if c == end && r.endsString() {
+			break
+		}
+
+		switch c {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if c < 0x20 {
+				sb.WriteString(`\u00`)
+				sb.WriteString(strconv.FormatInt(int64(c)>>4, 16))
+				sb.WriteString(strconv.FormatInt(int64(c)&0xf, 16))
+			} else {
+				sb.WriteRune(c)
+			}
+		}
+	}
+
+	r.out.WriteByte('"')
+	r.out.WriteString(sb.String())
+	r.out.WriteByte('"')
+	if key {
+		r.last = tokKey
+	} else {
+		r.last = tokValue
+	}
+}
+
+// endsString reports whether the quote just read is followed by a delimiter.
+func (r *repairer) endsString() bool {
+	for i := r.pos; i < len(r.in); i++ {
+		c := r.in[i]
+		if c == ' ' || c == '\t' || c == '\r' {
+			continue
+		}
+		switch c {
+		case ',', ':', '}', ']', '\n':
+			return true
+		}
+		return false
+	}
+
+	return true
+}
+
+// number copies a number, quoting it if it cannot be parsed.
+func (r *repairer) number() {
+	start := r.pos
+	for r.pos < len(r.in) && strings.ContainsRune("0123456789+-.eE", r.in[r.pos]) {
+		r.pos++
+	}
+	text := string(r.in[start:r.pos])
+	text = strings.TrimPrefix(text, "+")
+	if strings.HasPrefix(text, ".") {
+		text = "0" + text
+	} else if strings.HasPrefix(text, "-.") {
+		text = "-0" + text[1:]
+	}
+	text = strings.TrimSuffix(text, ".")
+
+	if r.expectKey() {
+		r.out.WriteString(quote(text))
+		r.last = tokKey
+		return
+	}
+
+	// Text starting with digits, such as "12px" or "10:30".
+	if r.pos < len(r.in) && !unicode.IsSpace(r.in[r.pos]) && !strings.ContainsRune(",}]", r.in[r.pos]) {
+		r.pos = start
+		r.word()
+		return
+	}
+
+	if !json.Valid([]byte(text)) {
+		text = quote(text)
+	}
+	r.out.WriteString(text)
+	r.last = tokValue
+}
+
+// word handles unquoted text: keys, literals and bare strings.
+func (r *repairer) word() {
+	if r.expectKey() {
+		start := r.pos
+		for r.pos < len(r.in) && !strings.ContainsRune(":,{}[]\n", r.in[r.pos]) {
+			r.pos++
+		}
+		r.out.WriteString(quote(strings.TrimSpace(string(r.in[start:r.pos]))))
+		r.last = tokKey
+		return
+	}
+
+	start := r.pos
+	for r.pos < len(r.in) && !strings.ContainsRune(",{}[]\n", r.in[r.pos]) {
+		r.pos++
+	}
+	text := strings.TrimSpace(string(r.in[start:r.pos]))
+
+	switch text {
+	case "true", "True", "TRUE":
+		r.out.WriteString("true")
+	case "false", "False", "FALSE":
+		r.out.WriteString("false")
+	case "null", "None", "none", "NULL", "nil", "undefined", "NaN":
+		r.out.WriteString("null")
+	default:
+		r.out.WriteString(quote(text))
+	}
+	r.last = tokValue
+}
+
+// quote returns s as a JSON string.
+func quote(s string) string {
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,154 @@
+package uniai
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultKeyCooldown is how long a key is not used after the API answered
+// 429 Too Many Requests without a Retry-After header.
+const DefaultKeyCooldown = 30 * time.Second
+
+// maxThrottledAttempts bounds the attempts of a request answered with 429
+// Too Many Requests when the client spreads requests across keys.
+const maxThrottledAttempts = 5
+
+// APIKey is a credential of the UniAI API with its rate limit.
+type APIKey struct {
+	Auth string // basic auth credentials, as user:password
+	RPM  int    // requests per minute, 0 for no limit
+}
+
+type poolKey struct {
+	auth      string // base64 encoded credentials
+	interval  time.Duration
+	nextAt    time.Time // earliest start of the next request by the rate limit
+	coolUntil time.Time // end of the cooldown after a 429 answer
+}
+
+func (k *poolKey) readyAt() time.Time {
+	if k.coolUntil.After(k.nextAt) {
+		return k.coolUntil
+	}
+
+	return k.nextAt
+}
+
+// KeyPool spreads requests across several API keys in turn, waiting for the
+// rate limit of each key and cooling down keys the API throttled. It is safe
+// for concurrent use.
+type KeyPool struct {
+	cooldown time.Duration
+
+	mu   sync.Mutex
+	keys []*poolKey
+	next int // index of the key tried first
+}
+
+// NewKeyPool returns a pool of keys. Throttled keys are not used for
+// cooldown, or DefaultKeyCooldown if it is zero, unless the API said when
+// to retry.
+func NewKeyPool(keys []APIKey, cooldown time.Duration) (*KeyPool, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no API keys")
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultKeyCooldown
+	}
+
+	p := &KeyPool{cooldown: cooldown}
+	for _, key := range keys {
+		if key.Auth == "" {
+			return nil, errors.New("API key auth cannot be empty")
+		}
+		k := &poolKey{auth: base64.StdEncoding.EncodeToString([]byte(key.Auth))}
+		if key.RPM > 0 {
+			k.interval = time.Minute / time.Duration(key.RPM)
+		}
+		p.keys = append(p.keys, k)
+	}
+
+	return p, nil
+}
+
+// acquire waits until a key may send a request and returns it.
+func (p *KeyPool) acquire(ctx context.Context) (*poolKey, error) {
+	for {
+		p.mu.Lock()
+		now := time.Now()
+		var (
+			best   *poolKey
+			bestAt time.Time
+			index  int
+		)
+		for i := range p.keys {
+			j := (p.next + i) % len(p.keys)
+			if at := p.keys[j].readyAt(); best == nil || at.Before(bestAt) {
+				best, bestAt, index = p.keys[j], at, j
+			}
+		}
+		if !bestAt.After(now) {
+			best.nextAt = now.Add(best.interval)
+			p.next = index + 1
+			p.mu.Unlock()
+			return best, nil
+		}
+		p.mu.Unlock()
+
+		timer := time.NewTimer(bestAt.Sub(now))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// throttled cools down a key the API answered 429 Too Many Requests, until
+// the time given by its Retry-After header if any.
+func (p *KeyPool) throttled(k *poolKey, resp *http.Response) {
+	cooldown := p.cooldown
+	if d := retryAfter(resp); d > 0 {
+		cooldown = d
+	}
+
+	p.mu.Lock()
+	k.coolUntil = time.Now().Add(cooldown)
+	p.mu.Unlock()
+}
+
+// retryAfter returns the wait given by the Retry-After header of resp, in
+// seconds or as an HTTP date, or 0 if there is none.
+func retryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0)
+	}
+
+	return 0
+}
+
+// UseKeys makes the client authenticate every request with a key of pool
+// instead of its own credentials, and retry the requests the API throttled
+// with another key.
+func (c *Client) UseKeys(pool *KeyPool) {
+	c.keys = pool
+	pool.mu.Lock()
+	for _, k := range pool.keys {
+		plain, _ := base64.StdEncoding.DecodeString(k.auth)
+		c.Redactor().Add(k.auth, string(plain))
+	}
+	pool.mu.Unlock()
+}
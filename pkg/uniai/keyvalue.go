@@ -0,0 +1,196 @@
+package uniai
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Region is the approximate area of a page an item was found in, as
+// fractions of the page width and height from its top left corner.
+type Region struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Valid reports whether the region lies within the page and is not empty.
+func (r Region) Valid() bool {
+	return r.X >= 0 && r.Y >= 0 && r.Width > 0 && r.Height > 0 && r.X+r.Width <= 1.01 && r.Y+r.Height <= 1.01
+}
+
+// String describes the region by the ninth of the page its center is in,
+// such as "top left" or "middle".
+func (r Region) String() string {
+	third := func(v float64, names ...string) string {
+		return names[min(int(v*3), 2)]
+	}
+	vertical := third(r.Y+r.Height/2, "top", "middle", "bottom")
+	horizontal := third(r.X+r.Width/2, "left", "center", "right")
+
+	switch {
+	case vertical == "middle" && horizontal == "center":
+		return "middle"
+	case horizontal == "center":
+		return vertical
+	}
+
+	return vertical + " " + horizontal
+}
+
+// Anchor is a place in a document a value was read from.
+type Anchor struct {
+	Page   int     `json:"page"`
+	Region *Region `json:"region,omitempty"`
+}
+
+// KeyValue is a field of a form or document, such as "Invoice number" and
+// its value, with the places it was found.
+type KeyValue struct {
+	Key     string   `json:"key"`
+	Value   string   `json:"value"`
+	Sources []Anchor `json:"sources"`
+}
+
+// Page returns the first page the value was found on.
+func (kv KeyValue) Page() int {
+	if len(kv.Sources) == 0 {
+		return 0
+	}
+
+	return kv.Sources[0].Page
+}
+
+// KeyValueExtractor reads the key-value pairs of document pages.
+type KeyValueExtractor struct {
+	Client *Client
+	Model  string
+	// Keys are the keys to look for; all the pairs of the pages are
+	// extracted if empty.
+	Keys []string
+	// Options are the model options, [DefaultOptions] if nil.
+	Options map[string]any
+}
+
+type keyValueAnswer struct {
+	Fields []struct {
+		Key    string    `json:"key" description:"the label of the field as written"`
+		Value  string    `json:"value" description:"the value as written, empty if the field is blank"`
+		Region []float64 `json:"region,omitempty" description:"approximate bounding box of the value as [x, y, width, height], fractions of the page size from the top left corner"`
+	} `json:"fields"`
+}
+
+// Extract returns the key-value pairs of the pages, merged across pages with
+// [MergeKeyValues].
+func (e *KeyValueExtractor) Extract(ctx context.Context, pages []PageInput) ([]KeyValue, error) {
+	var all []KeyValue
+	for _, page := range pages {
+		kvs, err := e.ExtractPage(ctx, page)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", page.Number, err)
+		}
+		all = append(all, kvs...)
+	}
+
+	return MergeKeyValues(all), nil
+}
+
+// ExtractPage returns the key-value pairs of a single page. Regions are only
+// requested for pages with images.
+func (e *KeyValueExtractor) ExtractPage(ctx context.Context, page PageInput) ([]KeyValue, error) {
+	prompt := "List the fields of this page as key-value pairs, such as form fields and labelled values."
+	if len(e.Keys) > 0 {
+		prompt = "Find the values of the following fields on this page: " + strings.Join(e.Keys, ", ") +
+			". Use these names as keys and leave out the fields that are not on the page."
+	}
+	if len(page.Images) > 0 {
+		prompt += " Give the approximate region of each value on the page."
+	}
+	if page.Text != "" {
+		prompt += "\n\nPage content:\n" + strings.TrimSpace(page.Text)
+	}
+
+	req := &GenerateRequest{
+		Model:   e.Model,
+		Prompt:  prompt,
+		Images:  page.Images,
+		Options: e.Options,
+	}
+	if req.Model == "" {
+		req.Model = ModelDefault
+	}
+	if req.Options == nil {
+		req.Options = DefaultOptions
+	}
+
+	var answer keyValueAnswer
+	if err := ExtractInto(ctx, e.Client, req, SchemaFor[keyValueAnswer](), &answer); err != nil {
+		return nil, err
+	}
+
+	kvs := make([]KeyValue, 0, len(answer.Fields))
+	for _, f := range answer.Fields {
+		key := strings.Join(strings.Fields(f.Key), " ")
+		if key == "" {
+			continue
+		}
+
+		anchor := Anchor{Page: page.Number}
+		if len(f.Region) == 4 && len(page.Images) > 0 {
+			r := Region{X: f.Region[0], Y: f.Region[1], Width: f.Region[2], Height: f.Region[3]}
+			if r.Valid() {
+				anchor.Region = &r
+			}
+		}
+		kvs = append(kvs, KeyValue{
+			Key:     strings.TrimRight(key, ":"),
+			Value:   strings.TrimSpace(f.Value),
+			Sources: []Anchor{anchor},
+		})
+	}
+
+	return kvs, nil
+}
+
+// MergeKeyValues merges the pairs found on several pages: pairs with the
+// same key and value (ignoring case, spacing and punctuation) are merged with
+// their sources, and blank values are dropped when the key has a value
+// elsewhere. Different values of a key, as in repeated forms, are kept in
+// document order.
+func MergeKeyValues(kvs []KeyValue) []KeyValue {
+	filled := make(map[string]bool)
+	for _, kv := range kvs {
+		if kv.Value != "" {
+			filled[foldKey(kv.Key)] = true
+		}
+	}
+
+	index := make(map[string]int)
+	var merged []KeyValue
+	for _, kv := range kvs {
+		key := foldKey(kv.Key)
+		if kv.Value == "" && filled[key] {
+			continue
+		}
+
+		id := key + "\x00" + foldKey(kv.Value)
+		i, ok := index[id]
+		if !ok {
+			index[id] = len(merged)
+			kv.Sources = append([]Anchor(nil), kv.Sources...)
+			merged = append(merged, kv)
+			continue
+		}
+
+		m := &merged[i]
+		for _, src := range kv.Sources {
+			if !slices.ContainsFunc(m.Sources, func(a Anchor) bool { return a.Page == src.Page }) {
+				m.Sources = append(m.Sources, src)
+			}
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,60 @@
+package uniai
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// ClientOption configures a [Client] created by [NewClient].
+type ClientOption func(*Client)
+
+// WithTimeout bounds every API request of the client, a streamed response
+// included, to d: a request still running after d fails with
+// [context.DeadlineExceeded]. Artifact downloads are not bounded. A deadline
+// of the context of the request applies too.
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithHeader sets the header key to value on every request of the client,
+// such as the tenant header of an API gateway. Headers set by the client
+// itself, like Authorization, take precedence.
+func WithHeader(key, value string) ClientOption {
+	return func(c *Client) {
+		if c.header == nil {
+			c.header = make(http.Header)
+		}
+		c.header.Set(key, value)
+	}
+}
+
+// WithTransport sends the requests of the client with rt, e.g. to set TLS
+// settings or a proxy. The HTTP client given to [NewClient] is copied rather
+// than modified.
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		client := *c.client
+		client.Transport = rt
+		c.client = &client
+	}
+}
+
+// WithUserAgent replaces the User-Agent header of the requests of the
+// client.
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// withTimeout returns ctx bounded by the timeout of the client, if set.
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, c.timeout)
+}
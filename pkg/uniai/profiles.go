@@ -0,0 +1,69 @@
+package uniai
+
+import (
+	"maps"
+	"reflect"
+	"strings"
+)
+
+// OptionsResolver returns the default options of model, or nil if it has
+// none, such as a lower temperature for a model prone to drift.
+type OptionsResolver func(model string) map[string]any
+
+// UseOptionsResolver sets the function resolving the default options of the
+// model of every generate, chat and embed request. They replace the options
+// of the request left unset or at their [DefaultOptions] value, while the
+// options the request sets to another value are kept.
+func (c *Client) UseOptionsResolver(fn OptionsResolver) {
+	c.resolveOptions = fn
+}
+
+// ResolveOptions returns the options sent with a request for model with
+// options, once merged with the default options of the model resolved by
+// the client, e.g. to record them. model may be an alias, see
+// [Client.UseModelAliases]. options is not modified.
+func (c *Client) ResolveOptions(model string, options map[string]any) map[string]any {
+	if c.resolveOptions == nil {
+		return options
+	}
+	profile := c.resolveOptions(c.ResolveModel(model))
+	if len(profile) == 0 {
+		return options
+	}
+
+	resolved := maps.Clone(options)
+	if resolved == nil {
+		resolved = make(map[string]any, len(profile))
+	}
+	for key, value := range profile {
+		current, ok := options[key]
+		if !ok || reflect.DeepEqual(current, DefaultOptions[key]) {
+			resolved[key] = value
+		}
+	}
+
+	return resolved
+}
+
+// ModelProfiles are the default options of models, by model name, such as
+// {"uniai01:7b": {"temperature": 0.1, "num_predict": 2048}}. A profile named
+// after a model family without a tag, such as "uniai01", applies to all of
+// its models, under the profile of the model itself.
+type ModelProfiles map[string]map[string]any
+
+// Options returns the options of model, nil if no profile applies. It is an
+// [OptionsResolver].
+func (p ModelProfiles) Options(model string) map[string]any {
+	family, _, tagged := strings.Cut(model, ":")
+	if !tagged {
+		return p[model]
+	}
+
+	options := p[model]
+	if base := p[family]; len(base) > 0 {
+		options = maps.Clone(base)
+		maps.Copy(options, p[model])
+	}
+
+	return options
+}
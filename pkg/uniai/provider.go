@@ -0,0 +1,39 @@
+package uniai
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errNoAPI is returned by requests that only the UniAI API serves, such as
+// [Client.Version], on clients of another provider.
+var errNoAPI = errors.New("not supported by this provider")
+
+// Provider is an AI backend answering generate, chat and embed requests.
+// [Client] implements it over the UniAI HTTP API. Adapters to other backends
+// are wrapped with [NewProviderClient] to be used with the helpers of this
+// package, which take a [Client].
+type Provider interface {
+	Generate(ctx context.Context, req *GenerateRequest, fn GenerateResponseFunc) error
+	Chat(ctx context.Context, req *ChatRequest, fn ChatResponseFunc) error
+	Embed(ctx context.Context, req *EmbedRequest) (*EmbedResponse, error)
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+}
+
+var _ Provider = (*Client)(nil)
+
+// ModelInfo describes a model available from a provider.
+type ModelInfo struct {
+	Name       string    `json:"name"`
+	Model      string    `json:"model,omitempty"`
+	ModifiedAt time.Time `json:"modified_at,omitzero"`
+	Size       int64     `json:"size,omitempty"`
+	Digest     string    `json:"digest,omitempty"`
+}
+
+// NewProviderClient returns a client sending its requests to p, with the
+// response filters and usage reporting of a [Client].
+func NewProviderClient(p Provider) *Client {
+	return &Client{provider: p}
+}
@@ -0,0 +1,140 @@
+package uniai
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Priority is the priority of a request in a [RequestQueue].
+type Priority int
+
+// Priorities of requests, from the lowest.
+const (
+	// PriorityBatch is for background work, such as the pages of a large
+	// document.
+	PriorityBatch Priority = iota
+	// PriorityNormal is the priority of requests without one.
+	PriorityNormal
+	// PriorityInteractive is for requests a user waits for, such as the
+	// replies of a chat.
+	PriorityInteractive
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx in which the requests have priority p.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFrom returns the priority of the requests of ctx,
+// [PriorityNormal] if it has none.
+func PriorityFrom(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+
+	return PriorityNormal
+}
+
+// RequestQueue bounds the requests in flight across the clients using it,
+// see [Client.UseQueue]. Waiting requests are sent by priority, then in the
+// order they arrived, so that interactive requests stay responsive while a
+// large batch runs through the same queue. It is safe for concurrent use; a
+// nil *RequestQueue does not bound the requests.
+type RequestQueue struct {
+	slots int
+
+	mu      sync.Mutex
+	inUse   int
+	waiting [PriorityInteractive + 1][]chan struct{} // by priority
+}
+
+// NewRequestQueue returns a queue sending at most slots requests at a time.
+func NewRequestQueue(slots int) *RequestQueue {
+	return &RequestQueue{slots: max(slots, 1)}
+}
+
+// Acquire waits until a request of priority p can be sent, or until ctx is
+// done, and returns the function to call once the request completed.
+func (q *RequestQueue) Acquire(ctx context.Context, p Priority) (release func(), err error) {
+	if q == nil {
+		return func() {}, nil
+	}
+	p = min(max(p, PriorityBatch), PriorityInteractive)
+
+	q.mu.Lock()
+	if q.inUse < q.slots && q.waitingLocked() == 0 {
+		q.inUse++
+		q.mu.Unlock()
+		return q.release, nil
+	}
+	ready := make(chan struct{})
+	q.waiting[p] = append(q.waiting[p], ready)
+	q.mu.Unlock()
+
+	select {
+	case <-ready:
+		return q.release, nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if i := slices.Index(q.waiting[p], ready); i >= 0 {
+		q.waiting[p] = slices.Delete(q.waiting[p], i, i+1)
+		return nil, ctx.Err()
+	}
+	// The slot was handed over meanwhile: pass it on.
+	q.releaseLocked()
+
+	return nil, ctx.Err()
+}
+
+// Waiting returns the number of requests waiting for a slot.
+func (q *RequestQueue) Waiting() int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.waitingLocked()
+}
+
+func (q *RequestQueue) waitingLocked() int {
+	n := 0
+	for _, waiting := range q.waiting {
+		n += len(waiting)
+	}
+
+	return n
+}
+
+func (q *RequestQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.releaseLocked()
+}
+
+// releaseLocked hands the slot of a completed request over to the first
+// waiting request of the highest priority, if any.
+func (q *RequestQueue) releaseLocked() {
+	for p := PriorityInteractive; p >= PriorityBatch; p-- {
+		if len(q.waiting[p]) > 0 {
+			close(q.waiting[p][0])
+			q.waiting[p] = q.waiting[p][1:]
+			return
+		}
+	}
+	q.inUse--
+}
+
+// UseQueue makes the generate, chat and embed requests of the client wait
+// for a slot of q, with the priority of their context, see [WithPriority].
+// Clients sharing q share its slots.
+func (c *Client) UseQueue(q *RequestQueue) {
+	c.queue = q
+}
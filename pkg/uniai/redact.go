@@ -0,0 +1,233 @@
+package uniai
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Redacted replaces the secrets and payloads masked by a [Redactor].
+const Redacted = "[REDACTED]"
+
+// minSecretLength is the length under which registered secrets are not
+// masked, as they would mask common words instead.
+const minSecretLength = 6
+
+var (
+	// authorizationPattern matches an Authorization header written out, as
+	// in a dump or an error, up to the end of the line or of a quoted value.
+	authorizationPattern = regexp.MustCompile(`(?i)((?:proxy-)?authorization"?\s*[:=]\s*"?)[^"\r\n]+`)
+	// credentialsPattern matches the credentials of an authorization scheme.
+	credentialsPattern = regexp.MustCompile(`(?i)\b(basic|bearer)\s+[A-Za-z0-9+/=._~-]{8,}`)
+	// queryPattern matches the credentials of presigned and keyed URLs.
+	queryPattern = regexp.MustCompile(`(?i)([?&](?:x-amz-(?:signature|credential|security-token)|x-goog-(?:signature|credential)|signature|sig|token|access_token|api_key|key)=)[^&#\s"']+`)
+	// dataURLPattern matches the payload of a base64 data URL.
+	dataURLPattern = regexp.MustCompile(`(data:[a-z]+/[a-z0-9.+-]+;base64,)([A-Za-z0-9+/]+=*)`)
+	// payloadPattern matches base64 runs too long to be anything but a
+	// binary payload, such as the images of a request.
+	payloadPattern = regexp.MustCompile(`[A-Za-z0-9+/]{256,}={0,2}`)
+	// secretKeyPattern matches the log attribute keys whose values are
+	// secrets whatever they look like.
+	secretKeyPattern = regexp.MustCompile(`(?i)(authorization|password|passwd|secret|token|api_?key|license_?key|signing_?key|credentials?)$`)
+)
+
+// sensitiveHeaders are the headers masked by [Redactor.Header], besides the
+// ones added with [Redactor.AddHeader].
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Amz-Security-Token",
+	DefaultSignatureHeader,
+}
+
+// Redactor masks the secrets of the client and the CLI, such as API
+// credentials, signing keys and license keys, as well as authorization
+// headers and raw image payloads, in the text written to logs and debug
+// dumps. Secrets are registered with [Redactor.Add] as they are read; the
+// rest is recognized by its shape. It is safe for concurrent use.
+type Redactor struct {
+	mu      sync.RWMutex
+	secrets []string // longest first
+	headers map[string]bool
+}
+
+// NewRedactor returns a redactor of secrets.
+func NewRedactor(secrets ...string) *Redactor {
+	r := &Redactor{headers: make(map[string]bool)}
+	for _, name := range sensitiveHeaders {
+		r.headers[http.CanonicalHeaderKey(name)] = true
+	}
+	r.Add(secrets...)
+
+	return r
+}
+
+// Add registers secrets to mask wherever they appear. Secrets shorter than
+// 6 characters are ignored.
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, secret := range secrets {
+		secret = strings.TrimSpace(secret)
+		if len(secret) < minSecretLength || slices.Contains(r.secrets, secret) {
+			continue
+		}
+		r.secrets = append(r.secrets, secret)
+	}
+	sort.SliceStable(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+}
+
+// AddHeader registers headers to mask in [Redactor.Header], such as a custom
+// signature header.
+func (r *Redactor) AddHeader(names ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, name := range names {
+		r.headers[http.CanonicalHeaderKey(name)] = true
+	}
+}
+
+// merge registers the secrets and headers of other.
+func (r *Redactor) merge(other *Redactor) {
+	if other == nil || other == r {
+		return
+	}
+	other.mu.RLock()
+	secrets := append([]string(nil), other.secrets...)
+	var headers []string
+	for name := range other.headers {
+		headers = append(headers, name)
+	}
+	other.mu.RUnlock()
+
+	r.Add(secrets...)
+	r.AddHeader(headers...)
+}
+
+// String returns s with the registered secrets, authorization headers and
+// credentials, URL credentials and base64 payloads masked. Payloads are
+// replaced by their decoded size, which is usually what a debugging reader
+// needs to know about them.
+func (r *Redactor) String(s string) string {
+	if s == "" {
+		return s
+	}
+
+	r.mu.RLock()
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	r.mu.RUnlock()
+
+	s = authorizationPattern.ReplaceAllString(s, "${1}"+Redacted)
+	s = credentialsPattern.ReplaceAllString(s, "$1 "+Redacted)
+	s = queryPattern.ReplaceAllString(s, "${1}"+Redacted)
+	s = dataURLPattern.ReplaceAllStringFunc(s, func(m string) string {
+		match := dataURLPattern.FindStringSubmatch(m)
+		return match[1] + payloadSize(match[2])
+	})
+
+	return payloadPattern.ReplaceAllStringFunc(s, payloadSize)
+}
+
+// payloadSize returns the replacement of the base64 payload p.
+func payloadSize(p string) string {
+	return fmt.Sprintf("[REDACTED %d bytes]", base64.StdEncoding.DecodedLen(len(p))-strings.Count(p, "="))
+}
+
+// Header returns a copy of h with the values of the authorization, cookie,
+// API key and signature headers masked. The scheme of an Authorization
+// header is kept, as it tells which credentials were sent.
+func (r *Redactor) Header(h http.Header) http.Header {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	redacted := make(http.Header, len(h))
+	for name, values := range h {
+		if !r.headers[http.CanonicalHeaderKey(name)] {
+			redacted[name] = append([]string(nil), values...)
+			continue
+		}
+		masked := make([]string, len(values))
+		for i, value := range values {
+			masked[i] = Redacted
+			if scheme, _, ok := strings.Cut(value, " "); ok && strings.HasSuffix(strings.ToLower(name), "authorization") {
+				masked[i] = scheme + " " + Redacted
+			}
+		}
+		redacted[name] = masked
+	}
+
+	return redacted
+}
+
+// ReplaceAttr masks the secrets in a log attribute, for the ReplaceAttr of
+// [slog.HandlerOptions]. The message is an attribute too. Attributes named
+// after a secret, such as "token" or "authorization", are masked whatever
+// their value; errors and other values are masked through their text.
+func (r *Redactor) ReplaceAttr(_ []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+	if secretKeyPattern.MatchString(a.Key) && !a.Value.Equal(slog.StringValue("")) {
+		a.Value = slog.StringValue(Redacted)
+		return a
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(r.String(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case http.Header:
+			a.Value = slog.AnyValue(r.Header(v))
+		case ImageData:
+			a.Value = slog.StringValue(fmt.Sprintf("[REDACTED %d bytes]", len(v)))
+		case []byte:
+			if utf8.Valid(v) {
+				a.Value = slog.StringValue(r.String(string(v)))
+			} else {
+				a.Value = slog.StringValue(fmt.Sprintf("[REDACTED %d bytes]", len(v)))
+			}
+		case error:
+			if s := v.Error(); r.String(s) != s {
+				a.Value = slog.StringValue(r.String(s))
+			}
+		default:
+			if s := fmt.Sprint(v); r.String(s) != s {
+				a.Value = slog.StringValue(r.String(s))
+			}
+		}
+	}
+
+	return a
+}
+
+// Redactor returns the redactor masking the credentials of the client, for
+// the logs and dumps of its requests.
+func (c *Client) Redactor() *Redactor {
+	if c.redactor == nil {
+		c.redactor = NewRedactor()
+	}
+
+	return c.redactor
+}
+
+// UseRedactor makes the client register its credentials with r, and mask
+// them with it, so a redactor shared with the application covers them too.
+func (c *Client) UseRedactor(r *Redactor) {
+	r.merge(c.redactor)
+	c.redactor = r
+}
@@ -0,0 +1,82 @@
+package uniai
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy tells a [Client] how to send again the requests that failed
+// with a transient error: a 500, 502, 503 or 504 response, or a network
+// error such as a reset connection. The wait before attempt n+1 is drawn at
+// random up to Base * 2^(n-1), bounded by Max, so that the clients of an
+// overloaded API do not retry in step. A 503 with a Retry-After header waits
+// at least as long as it asks.
+type RetryPolicy struct {
+	Attempts int           // attempts of a request, the first included; 1 or less disables retries
+	Base     time.Duration // bound of the first wait
+	Max      time.Duration // bound of every wait
+}
+
+// DefaultRetryPolicy sends a request up to 4 times, over about 4 seconds.
+var DefaultRetryPolicy = RetryPolicy{Attempts: 4, Base: 500 * time.Millisecond, Max: 30 * time.Second}
+
+// UseRetry makes the client send the requests failing with a transient error
+// again following policy. Streamed responses are only retried until their
+// first line, so a response is never received twice. Clients do not retry by
+// default.
+func (c *Client) UseRetry(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// Backoff returns the wait before the attempt following attempt, starting
+// at 1.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	limit := p.Base << min(attempt-1, 30)
+	if limit <= 0 || (p.Max > 0 && limit > p.Max) {
+		limit = p.Max
+	}
+	if limit <= 0 {
+		return 0
+	}
+
+	return rand.N(limit) + 1
+}
+
+// transientStatus reports whether a response with status code is worth
+// sending again.
+func transientStatus(code int) bool {
+	switch code {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// transientError reports whether a failure to get a response is worth
+// sending the request again: a network error, but not a canceled or expired
+// context.
+func transientError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+
+	return errors.As(err, &netErr) || errors.Is(err, net.ErrClosed)
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,233 @@
+package uniai
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// SchemaFor returns the JSON schema of T, derived from its Go type and struct
+// tags:
+//   - the json tag names a field; fields tagged "-" are left out, and fields
+//     without omitempty are required
+//   - a description tag documents a field for the model
+//   - an enum tag lists the allowed values, separated by commas
+//
+// Strings, numbers, booleans, time.Time (as an RFC 3339 string), slices,
+// arrays, maps with string keys, pointers and nested structs are supported;
+// interfaces accept any value.
+func SchemaFor[T any]() map[string]any {
+	return schemaOf(reflect.TypeFor[T](), make(map[reflect.Type]bool))
+}
+
+func schemaOf(t reflect.Type, seen map[reflect.Type]bool) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			// Recursive types are not expanded again.
+			return map[string]any{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		return structSchema(t, seen)
+	}
+
+	return map[string]any{}
+}
+
+func structSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]any {
+	props := make(map[string]any)
+	required := []any{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := schemaOf(f.Type, seen)
+		if desc := f.Tag.Get("description"); desc != "" {
+			prop["description"] = desc
+		}
+		if enum := f.Tag.Get("enum"); enum != "" {
+			var values []any
+			for _, v := range strings.Split(enum, ",") {
+				values = append(values, strings.TrimSpace(v))
+			}
+			prop["enum"] = values
+		}
+		props[name] = prop
+
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+
+	return map[string]any{
+		"type":       "object",
+		"properties": props,
+		"required":   required,
+	}
+}
+
+// ValidateSchema checks value against a JSON schema and returns the
+// violations. The subset understood is type, properties, required,
+// additionalProperties, items and enum, which covers the schemas used to
+// constrain model answers.
+func ValidateSchema(value any, schema map[string]any) []string {
+	var errs []string
+	validateSchema("$", value, schema, &errs)
+
+	return errs
+}
+
+func validateSchema(path string, value any, schema map[string]any, errs *[]string) {
+	if t, ok := schema["type"].(string); ok && !hasType(value, t) {
+		*errs = append(*errs, fmt.Sprintf("%s: expected %s", path, t))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*errs = append(*errs, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if required, ok := schema["required"].([]any); ok {
+			for _, r := range required {
+				if name, ok := r.(string); ok {
+					if _, present := v[name]; !present {
+						*errs = append(*errs, fmt.Sprintf("%s: missing %s", path, name))
+					}
+				}
+			}
+		}
+		if props, ok := schema["properties"].(map[string]any); ok {
+			keys := make([]string, 0, len(props))
+			for k := range props {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				child, present := v[k]
+				sub, ok := props[k].(map[string]any)
+				// Optional properties may be null.
+				if child == nil && !isRequired(schema, k) {
+					continue
+				}
+				if present && ok {
+					validateSchema(path+"."+k, child, sub, errs)
+				}
+			}
+		}
+		if extra, ok := schema["additionalProperties"].(map[string]any); ok {
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if _, declared := schemaProperties(schema)[k]; !declared {
+					validateSchema(path+"."+k, v[k], extra, errs)
+				}
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, child := range v {
+				validateSchema(fmt.Sprintf("%s[%d]", path, i), child, items, errs)
+			}
+		}
+	}
+}
+
+func isRequired(schema map[string]any, name string) bool {
+	required, _ := schema["required"].([]any)
+	for _, r := range required {
+		if r == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func schemaProperties(schema map[string]any) map[string]any {
+	p, _ := schema["properties"].(map[string]any)
+	return p
+}
+
+func hasType(value any, t string) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == float64(int64(f))
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+
+	return true
+}
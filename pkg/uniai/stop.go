@@ -0,0 +1,65 @@
+package uniai
+
+import (
+	"errors"
+	"regexp"
+)
+
+// ErrStop, returned by a [GenerateResponseFunc] or a [ChatResponseFunc],
+// stops streaming the response without [Client.Generate] or [Client.Chat]
+// reporting an error. The connection is closed, so the server stops
+// generating too. The final response, which is marked Done and carries the
+// metrics, is never received: the usage of a stopped request is not
+// reported.
+var ErrStop = errors.New("stop streaming")
+
+// stopped returns err, or nil if it is [ErrStop].
+func stopped(err error) error {
+	if errors.Is(err, ErrStop) {
+		return nil
+	}
+
+	return err
+}
+
+// StopAfterTokens returns a [GenerateResponseFunc] calling fn with the
+// streamed responses until about n tokens are received, then stopping the
+// stream. Every chunk counts as at least one token.
+func StopAfterTokens(n int, fn GenerateResponseFunc) GenerateResponseFunc {
+	tokens := 0
+	return func(resp GenerateResponse) error {
+		if err := fn(resp); err != nil {
+			return err
+		}
+		if resp.Response != "" {
+			tokens += max(EstimateTokens(resp.Response), 1)
+		}
+		if tokens >= n && !resp.Done {
+			return ErrStop
+		}
+		return nil
+	}
+}
+
+// StopAt returns a [GenerateResponseFunc] calling fn with the streamed
+// responses until the text received so far matches re, then stopping the
+// stream. The chunk completing the match is cut after it, so that fn
+// receives the text up to the end of the match, such as the first JSON
+// object of a response with StopAt(regexp.MustCompile(`(?s)\{.*?\}`), fn).
+func StopAt(re *regexp.Regexp, fn GenerateResponseFunc) GenerateResponseFunc {
+	var text []byte
+	return func(resp GenerateResponse) error {
+		before := len(text)
+		text = append(text, resp.Response...)
+		loc := re.FindIndex(text)
+		if loc == nil || resp.Done {
+			return fn(resp)
+		}
+
+		resp.Response = resp.Response[:max(loc[1]-before, 0)]
+		if err := fn(resp); err != nil {
+			return err
+		}
+		return ErrStop
+	}
+}
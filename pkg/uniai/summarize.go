@@ -0,0 +1,230 @@
+package uniai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Defaults of a [Summarizer].
+const (
+	DefaultFanIn         = 5
+	DefaultContextTokens = 8192
+	// ImageTokens is the estimated number of context tokens of an image
+	// whose size is not known, see [EstimateImageTokens].
+	ImageTokens = 768
+)
+
+// Summary levels.
+const (
+	SummaryPage     = "page"
+	SummarySection  = "section"
+	SummaryDocument = "document"
+)
+
+// SummaryPart is an intermediate summary of a map-reduce summarization.
+type SummaryPart struct {
+	Level string `json:"level"`
+	Pages []int  `json:"pages"`
+	Text  string `json:"text"`
+}
+
+// Summary is the summary of a document.
+type Summary struct {
+	Text string `json:"text"`
+	// MapReduce is set when the document did not fit in the context of the
+	// model and was summarized page by page, then section by section.
+	MapReduce bool          `json:"map_reduce"`
+	Parts     []SummaryPart `json:"parts,omitempty"`
+}
+
+// Summarizer summarizes documents of any length: a document fitting in the
+// context of the model is summarized in a single request; a longer one is
+// summarized hierarchically, every page first, then groups of FanIn
+// summaries into section summaries until a single document summary is left.
+type Summarizer struct {
+	Client *Client
+	Model  string
+	// Instructions are added to every request, such as the length or focus
+	// of the summary.
+	Instructions string
+	// FanIn is the number of summaries merged per request,
+	// [DefaultFanIn] if 0.
+	FanIn int
+	// ContextTokens is the context size of the model,
+	// [DefaultContextTokens] if 0.
+	ContextTokens int
+	// MapReduce forces the hierarchical summarization.
+	MapReduce bool
+	// Options are the model options, [DefaultOptions] if nil.
+	Options map[string]any
+	// Progress, if set, is called after every request.
+	Progress func(part SummaryPart)
+}
+
+// EstimateTokens returns a rough estimate of the number of tokens of text,
+// about four characters per token.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// fits reports whether the pages fit in the context of the model, keeping a
+// quarter of it for the prompt and the answer.
+func (s *Summarizer) fits(pages []PageInput) bool {
+	budget := s.ContextTokens
+	if budget <= 0 {
+		budget = DefaultContextTokens
+	}
+
+	tokens := 0
+	for _, page := range pages {
+		tokens += EstimateTokens(page.Text)
+		for _, img := range page.Images {
+			n, err := EstimateImageTokens(s.Model, img, 0)
+			if err != nil {
+				n = ImageTokens
+			}
+			tokens += n
+		}
+	}
+
+	return tokens <= budget*3/4
+}
+
+// Summarize returns the summary of the pages of a document.
+func (s *Summarizer) Summarize(ctx context.Context, pages []PageInput) (*Summary, error) {
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no pages to summarize")
+	}
+
+	if !s.MapReduce && s.fits(pages) {
+		text, err := s.summarize(ctx, "Summarize this document.", pages)
+		if err != nil {
+			return nil, err
+		}
+		return &Summary{Text: text}, nil
+	}
+
+	summary := &Summary{MapReduce: true}
+	var level []SummaryPart
+	for _, page := range pages {
+		text, err := s.summarize(ctx, "Summarize this page of a longer document.", []PageInput{page})
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", page.Number, err)
+		}
+		level = append(level, s.add(summary, SummaryPart{Level: SummaryPage, Pages: []int{page.Number}, Text: text}))
+	}
+
+	fanIn := s.FanIn
+	if fanIn == 0 {
+		fanIn = DefaultFanIn
+	}
+	fanIn = max(fanIn, 2)
+	for len(level) > 1 {
+		var next []SummaryPart
+		for i := 0; i < len(level); i += fanIn {
+			group := level[i:min(i+fanIn, len(level))]
+			if len(group) == 1 {
+				next = append(next, group[0])
+				continue
+			}
+
+			kind, instruction := SummarySection, "Merge these summaries of consecutive parts of a longer document into one summary of the section."
+			if len(level) <= fanIn {
+				kind, instruction = SummaryDocument, "Merge these summaries of consecutive parts of a document into one summary of the whole document."
+			}
+			part, err := s.merge(ctx, kind, instruction, group)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, s.add(summary, part))
+		}
+		level = next
+	}
+	summary.Text = level[0].Text
+
+	return summary, nil
+}
+
+// add records an intermediate summary and reports the progress.
+func (s *Summarizer) add(summary *Summary, part SummaryPart) SummaryPart {
+	summary.Parts = append(summary.Parts, part)
+	if s.Progress != nil {
+		s.Progress(part)
+	}
+
+	return part
+}
+
+// merge summarizes a group of summaries.
+func (s *Summarizer) merge(ctx context.Context, kind, instruction string, group []SummaryPart) (SummaryPart, error) {
+	part := SummaryPart{Level: kind}
+	var sb strings.Builder
+	for _, g := range group {
+		part.Pages = append(part.Pages, g.Pages...)
+		fmt.Fprintf(&sb, "Summary of %s:\n%s\n\n", pagesLabel(g.Pages), g.Text)
+	}
+
+	text, err := s.summarize(ctx, instruction, []PageInput{{Text: sb.String()}})
+	if err != nil {
+		return SummaryPart{}, fmt.Errorf("%s: %w", pagesLabel(part.Pages), err)
+	}
+	part.Text = text
+
+	return part, nil
+}
+
+// summarize sends one summarization request with the text and images of the
+// pages.
+func (s *Summarizer) summarize(ctx context.Context, instruction string, pages []PageInput) (string, error) {
+	prompt := instruction
+	if s.Instructions != "" {
+		prompt += " " + s.Instructions
+	}
+
+	req := &GenerateRequest{
+		Model:   s.Model,
+		Options: s.Options,
+	}
+	if req.Model == "" {
+		req.Model = ModelDefault
+	}
+	if req.Options == nil {
+		req.Options = DefaultOptions
+	}
+
+	var texts []string
+	for _, page := range pages {
+		req.Images = append(req.Images, page.Images...)
+		if text := strings.TrimSpace(page.Text); text != "" {
+			if page.Number > 0 && len(pages) > 1 {
+				text = fmt.Sprintf("Page %d:\n%s", page.Number, text)
+			}
+			texts = append(texts, text)
+		}
+	}
+	if len(texts) > 0 {
+		prompt += "\n\nContent:\n" + strings.Join(texts, "\n\n")
+	}
+	req.Prompt = prompt
+
+	text, err := s.Client.GenerateText(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(text), nil
+}
+
+// pagesLabel describes a range of pages, such as "pages 3-7".
+func pagesLabel(pages []int) string {
+	switch {
+	case len(pages) == 0:
+		return "the document"
+	case len(pages) == 1:
+		return fmt.Sprintf("page %d", pages[0])
+	}
+
+	return fmt.Sprintf("pages %d-%d", pages[0], pages[len(pages)-1])
+}
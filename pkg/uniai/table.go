@@ -0,0 +1,370 @@
+package uniai
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/sampila/uniai-client/pkg/uniai/jsonrepair"
+)
+
+// Table is a table found in a document.
+type Table struct {
+	Caption string     `json:"caption,omitempty"`
+	Headers []string   `json:"headers"`
+	Rows    [][]string `json:"rows"`
+
+	// Page is the page the table starts on; LastPage is set when it
+	// continues on the following pages.
+	Page     int `json:"page"`
+	LastPage int `json:"last_page,omitempty"`
+}
+
+// End returns the last page of the table.
+func (t *Table) End() int {
+	return max(t.Page, t.LastPage)
+}
+
+// Columns returns the number of columns.
+func (t *Table) Columns() int {
+	n := len(t.Headers)
+	for _, row := range t.Rows {
+		n = max(n, len(row))
+	}
+
+	return n
+}
+
+// WriteCSV writes the headers and rows as CSV. Short rows are padded.
+func (t *Table) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	n := t.Columns()
+	pad := func(row []string) []string {
+		for len(row) < n {
+			row = append(row, "")
+		}
+		return row
+	}
+
+	if len(t.Headers) > 0 {
+		if err := cw.Write(pad(append([]string(nil), t.Headers...))); err != nil {
+			return err
+		}
+	}
+	for _, row := range t.Rows {
+		if err := cw.Write(pad(append([]string(nil), row...))); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// MergeContinuedTables joins tables continued across a page break: a table
+// starting on the page after the end of the previous table, with the same
+// number of columns and either the same headers (repeated on the new page)
+// or no caption and "headers" that are actually data. Tables must be in
+// document order.
+func MergeContinuedTables(tables []Table) []Table {
+	var merged []Table
+	for _, t := range tables {
+		if len(merged) == 0 {
+			merged = append(merged, t)
+			continue
+		}
+
+		prev := &merged[len(merged)-1]
+		if t.Page != prev.End()+1 || t.Columns() != prev.Columns() {
+			merged = append(merged, t)
+			continue
+		}
+
+		switch {
+		case sameCells(t.Headers, prev.Headers):
+			prev.Rows = append(prev.Rows, t.Rows...)
+		case t.Caption == "" && len(t.Headers) == 0:
+			prev.Rows = append(prev.Rows, t.Rows...)
+		case t.Caption == "" && looksLikeData(t.Headers):
+			prev.Rows = append(prev.Rows, t.Headers)
+			prev.Rows = append(prev.Rows, t.Rows...)
+		default:
+			merged = append(merged, t)
+			continue
+		}
+		prev.LastPage = t.End()
+	}
+
+	return merged
+}
+
+func sameCells(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(strings.Join(strings.Fields(a[i]), " "), strings.Join(strings.Fields(b[i]), " ")) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// looksLikeData reports whether a header row holds numbers, which headers
+// rarely do.
+func looksLikeData(cells []string) bool {
+	for _, c := range cells {
+		if _, _, ok := ParseAmount(c); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// currencySymbols maps currency symbols and prefixes to ISO 4217 codes.
+var currencySymbols = map[string]string{
+	"$": "USD", "US$": "USD", "€": "EUR", "£": "GBP", "¥": "JPY", "₹": "INR",
+	"Rp": "IDR", "RM": "MYR", "S$": "SGD", "A$": "AUD", "C$": "CAD", "CHF": "CHF", "₩": "KRW", "₱": "PHP", "฿": "THB",
+}
+
+var isoCurrency = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ParseAmount parses numbers as written in documents: with thousands
+// separators ("1,234.56", "1.234,56", "1 234,56", "1'234.56"), currency
+// symbols or codes ("€12", "USD 1,000", "12.50 EUR"), accounting negatives
+// ("(123.45)") and percentages ("12%", returned as 12). The ISO currency code
+// is returned when present.
+func ParseAmount(s string) (value float64, currency string, ok bool) {
+	// Split the currency and signs from the number, on either side.
+	start := strings.IndexFunc(s, unicode.IsDigit)
+	if start < 0 {
+		return 0, "", false
+	}
+	if start > 0 && (s[start-1] == '.' || s[start-1] == ',') {
+		start--
+	}
+	end := strings.LastIndexFunc(s, unicode.IsDigit)
+	prefix, number, suffix := s[:start], s[start:end+1], s[end+1:]
+
+	negative := false
+	prefix = strings.TrimSpace(prefix)
+	suffix = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(suffix), "%"))
+	if strings.Count(prefix, "(") == 1 && strings.Count(suffix, ")") == 1 {
+		negative = true
+		prefix = strings.TrimSpace(strings.Replace(prefix, "(", "", 1))
+		suffix = strings.TrimSpace(strings.Replace(suffix, ")", "", 1))
+	}
+	for _, sign := range []string{"-", "+"} {
+		if strings.HasPrefix(prefix, sign) || strings.HasSuffix(prefix, sign) {
+			negative = negative != (sign == "-")
+			prefix = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(prefix, sign), sign))
+		}
+	}
+	for _, affix := range []string{prefix, suffix} {
+		if affix == "" {
+			continue
+		}
+		code, found := currencySymbols[affix]
+		if !found && !isoCurrency.MatchString(affix) {
+			return 0, "", false
+		}
+		if !found {
+			code = affix
+		}
+		currency = code
+	}
+
+	number = strings.NewReplacer(" ", "", "\u00a0", "", "'", "", "’", "").Replace(number)
+	if strings.ContainsFunc(number, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' && r != ',' }) {
+		return 0, "", false
+	}
+
+	// The last separator is the decimal one, unless only one kind of
+	// separator is used and it is repeated or followed by exactly three
+	// digits, as in "1,000" or "15.000".
+	lastDot, lastComma := strings.LastIndex(number, "."), strings.LastIndex(number, ",")
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		if lastComma > lastDot {
+			number = strings.ReplaceAll(number, ".", "")
+			number = strings.Replace(number, ",", ".", 1)
+		} else {
+			number = strings.ReplaceAll(number, ",", "")
+		}
+	case lastComma >= 0:
+		number = singleSeparator(number, ",", lastComma)
+	case lastDot >= 0:
+		number = singleSeparator(number, ".", lastDot)
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	if negative {
+		value = -value
+	}
+
+	return value, currency, true
+}
+
+func singleSeparator(number, sep string, last int) string {
+	if strings.Count(number, sep) > 1 || (len(number)-last-1 == 3 && last > 0 && number[:last] != "0") {
+		return strings.ReplaceAll(number, sep, "")
+	}
+
+	return strings.Replace(number, sep, ".", 1)
+}
+
+// NormalizeNumbers rewrites the cells of numeric columns (columns where every
+// non-empty cell is a number) as plain numbers, such as "-1234.5". When all
+// amounts of a column are in the same currency, its code is added to the
+// header, as in "Total (EUR)".
+func (t *Table) NormalizeNumbers() {
+	for col := 0; col < t.Columns(); col++ {
+		values := make(map[int]float64)
+		currencies := make(map[string]bool)
+		numeric := true
+		for i, row := range t.Rows {
+			if col >= len(row) || strings.TrimSpace(row[col]) == "" {
+				continue
+			}
+			v, cur, ok := ParseAmount(row[col])
+			if !ok {
+				numeric = false
+				break
+			}
+			values[i] = v
+			if cur != "" {
+				currencies[cur] = true
+			}
+		}
+		if !numeric || len(values) == 0 {
+			continue
+		}
+
+		for i, v := range values {
+			t.Rows[i][col] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		if len(currencies) == 1 && col < len(t.Headers) {
+			for cur := range currencies {
+				if !strings.Contains(t.Headers[col], cur) {
+					t.Headers[col] = strings.TrimSpace(t.Headers[col] + " (" + cur + ")")
+				}
+			}
+		}
+	}
+}
+
+// tableAnswer is the JSON format tables are requested in. Title and columns
+// are accepted as synonyms of caption and headers.
+type tableAnswer struct {
+	Tables []struct {
+		Caption string   `json:"caption,omitempty"`
+		Title   string   `json:"title,omitempty"`
+		Headers []string `json:"headers,omitempty"`
+		Columns []string `json:"columns,omitempty"`
+		Rows    [][]any  `json:"rows"`
+	} `json:"tables"`
+}
+
+// ParseTables parses the tables of an answer: JSON in the form
+// {"tables": [{"caption": ..., "headers": [...], "rows": [[...]]}]}, or
+// Markdown pipe tables.
+func ParseTables(answer string, page int) []Table {
+	text := strings.TrimSpace(ApplyFilters(answer, DefaultFilters...))
+
+	var parsed tableAnswer
+	if strings.HasPrefix(text, "{") && jsonrepair.Unmarshal([]byte(text), &parsed) == nil && parsed.Tables != nil {
+		tables := make([]Table, 0, len(parsed.Tables))
+		for _, pt := range parsed.Tables {
+			t := Table{Caption: pt.Caption, Headers: pt.Headers, Page: page}
+			if t.Caption == "" {
+				t.Caption = pt.Title
+			}
+			if t.Headers == nil {
+				t.Headers = pt.Columns
+			}
+			for _, row := range pt.Rows {
+				cells := make([]string, len(row))
+				for i, c := range row {
+					cells[i] = cellString(c)
+				}
+				t.Rows = append(t.Rows, cells)
+			}
+			tables = append(tables, t)
+		}
+		return tables
+	}
+
+	return parseMarkdownTables(text, page)
+}
+
+func cellString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+var markdownSeparator = regexp.MustCompile(`^\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)*\|?$`)
+
+func parseMarkdownTables(text string, page int) []Table {
+	var (
+		tables  []Table
+		current *Table
+		caption string
+	)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "|") {
+			current = nil
+			if line != "" {
+				caption = strings.Trim(line, "#*: ")
+			}
+			continue
+		}
+		if markdownSeparator.MatchString(line) {
+			continue
+		}
+
+		cells := splitMarkdownRow(line)
+		if current == nil {
+			isHeader := i+1 < len(lines) && markdownSeparator.MatchString(strings.TrimSpace(lines[i+1]))
+			tables = append(tables, Table{Caption: caption, Page: page})
+			current = &tables[len(tables)-1]
+			caption = ""
+			if isHeader {
+				current.Headers = cells
+				continue
+			}
+		}
+		current.Rows = append(current.Rows, cells)
+	}
+
+	return tables
+}
+
+func splitMarkdownRow(line string) []string {
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+	cells := strings.Split(line, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+
+	return cells
+}
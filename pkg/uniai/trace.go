@@ -0,0 +1,208 @@
+package uniai
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultTraceBodyLimit is the default number of bytes of the bodies written
+// by a [Tracer].
+const DefaultTraceBodyLimit = 4 * KiloByte
+
+// Tracer is an http.RoundTripper writing a wire-level trace of the requests
+// of a client: their headers and bodies, the headers and body of their
+// responses and the timings of the connection, for diagnosing unexpected
+// answers of the API. Everything is masked by a [Redactor]: credentials are
+// masked and images are replaced by their size, and bodies are truncated.
+// The entry of a request is written once its response body is closed, so that
+// streamed responses pass through unchanged. It is safe for concurrent use.
+type Tracer struct {
+	w         io.Writer
+	redactor  *Redactor
+	next      http.RoundTripper
+	bodyLimit int
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewTracer returns a tracer sending requests with next, or
+// http.DefaultTransport if nil, and writing their trace to w, masked with
+// redactor.
+func NewTracer(w io.Writer, redactor *Redactor, next http.RoundTripper) *Tracer {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if redactor == nil {
+		redactor = NewRedactor()
+	}
+
+	return &Tracer{w: w, redactor: redactor, next: next, bodyLimit: DefaultTraceBodyLimit}
+}
+
+// SetBodyLimit sets the number of bytes of the bodies written, after
+// masking, DefaultTraceBodyLimit by default.
+func (t *Tracer) SetBodyLimit(n int) {
+	t.bodyLimit = n
+}
+
+// traceEntry is the trace of a request, written when it completes.
+type traceEntry struct {
+	seq     int
+	start   time.Time
+	request *http.Request
+	body    []byte
+
+	dns, connect, tls, firstByte time.Duration
+	reused                       bool
+}
+
+// RoundTrip sends req and traces it.
+func (t *Tracer) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.seq++
+	e := &traceEntry{seq: t.seq, start: time.Now(), request: req}
+	t.mu.Unlock()
+
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		e.body = body
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	var dnsStart, connectStart, tlsStart time.Time
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { e.dns = time.Since(dnsStart) },
+		ConnectStart:         func(string, string) { connectStart = time.Now() },
+		ConnectDone:          func(string, string, error) { e.connect = time.Since(connectStart) },
+		TLSHandshakeStart:    func() { tlsStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { e.tls = time.Since(tlsStart) },
+		GotConn:              func(info httptrace.GotConnInfo) { e.reused = info.Reused },
+		GotFirstResponseByte: func() { e.firstByte = time.Since(e.start) },
+	}))
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.write(e, nil, nil, 0, err)
+		return nil, err
+	}
+	resp.Body = &tracedBody{ReadCloser: resp.Body, tracer: t, entry: e, resp: resp}
+
+	return resp, nil
+}
+
+// tracedBody keeps the beginning of a response body and writes the trace of
+// its request when it is closed.
+type tracedBody struct {
+	io.ReadCloser
+	tracer *Tracer
+	entry  *traceEntry
+	resp   *http.Response
+
+	head []byte
+	size int64
+	once sync.Once
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	// Payloads are masked before truncation, so keep enough to recognize
+	// them.
+	if keep := 4*b.tracer.bodyLimit - len(b.head); keep > 0 {
+		b.head = append(b.head, p[:min(n, keep)]...)
+	}
+	if err != nil && err != io.EOF {
+		b.once.Do(func() { b.tracer.write(b.entry, b.resp, b.head, b.size, err) })
+	}
+
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.tracer.write(b.entry, b.resp, b.head, b.size, nil) })
+
+	return err
+}
+
+// write writes the trace of a request, with its response if not nil and the
+// error it failed with, if any.
+func (t *Tracer) write(e *traceEntry, resp *http.Response, body []byte, size int64, err error) {
+	var sb strings.Builder
+	req := e.request
+	fmt.Fprintf(&sb, "=== #%d %s %s %s\n", e.seq, e.start.UTC().Format(time.RFC3339Nano), req.Method, t.redactor.String(req.URL.String()))
+	t.writeHeader(&sb, ">", req.Header)
+	t.writeBody(&sb, e.body, int64(len(e.body)))
+
+	if resp != nil {
+		fmt.Fprintf(&sb, "< %s %s\n", resp.Proto, resp.Status)
+		t.writeHeader(&sb, "<", resp.Header)
+		t.writeBody(&sb, body, size)
+	}
+	if err != nil {
+		fmt.Fprintf(&sb, "! error: %s\n", t.redactor.String(err.Error()))
+	}
+	fmt.Fprintf(&sb, "--- dns %s, connect %s, tls %s, reused %t, first byte %s, total %s\n\n",
+		e.dns, e.connect, e.tls, e.reused, e.firstByte, time.Since(e.start))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	io.WriteString(t.w, sb.String())
+}
+
+func (t *Tracer) writeHeader(sb *strings.Builder, prefix string, h http.Header) {
+	h = t.redactor.Header(h)
+	for _, name := range slices.Sorted(maps.Keys(h)) {
+		for _, value := range h[name] {
+			fmt.Fprintf(sb, "%s %s: %s\n", prefix, name, t.redactor.String(value))
+		}
+	}
+}
+
+// writeBody writes the masked body, truncated to the body limit, of a
+// message of size bytes.
+func (t *Tracer) writeBody(sb *strings.Builder, body []byte, size int64) {
+	if size == 0 {
+		return
+	}
+	sb.WriteString("\n")
+	if !utf8.Valid(body) && utf8.Valid(body[:max(len(body)-utf8.UTFMax, 0)]) {
+		// The kept head ends in the middle of a character.
+		body = bytes.ToValidUTF8(body, nil)
+	}
+	if !utf8.Valid(body) {
+		fmt.Fprintf(sb, "[binary body, %d bytes]\n\n", size)
+		return
+	}
+
+	text := t.redactor.String(string(body))
+	truncated := int64(len(body)) < size
+	if len(text) > t.bodyLimit {
+		text, truncated = strings.ToValidUTF8(text[:t.bodyLimit], ""), true
+	}
+	sb.WriteString(text)
+	if !strings.HasSuffix(text, "\n") {
+		sb.WriteString("\n")
+	}
+	if truncated {
+		fmt.Fprintf(sb, "[truncated, %d bytes in total]\n", size)
+	}
+	sb.WriteString("\n")
+}
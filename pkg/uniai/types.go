@@ -2,6 +2,7 @@ package uniai
 
 import (
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"reflect"
@@ -33,6 +34,26 @@ func (e StatusError) Error() string {
 	}
 }
 
+// RateLimitError is returned when the API answered 429 Too Many Requests.
+type RateLimitError struct {
+	StatusError
+	// RetryAfter is how long the API asked to wait before the next request,
+	// from its Retry-After header, or 0 if it did not say.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rate limited, retry after %s: %s", e.RetryAfter, e.StatusError.Error())
+	}
+
+	return "rate limited: " + e.StatusError.Error()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.StatusError
+}
+
 // GenerateRequest describes a request sent by [Client.Generate]. While you
 // have to specify the Model and Prompt fields, all the other fields have
 // reasonable defaults for basic uses.
@@ -74,6 +95,21 @@ type GenerateRequest struct {
 	// request, for multimodal models.
 	Images []ImageData `json:"images,omitempty"`
 
+	// ImagePaths are image files sent after Images. The client reads them
+	// when the request is sent, so batched requests do not hold every image
+	// in memory until then.
+	ImagePaths []string `json:"-"`
+
+	// MaxImageDimension, if set, downscales the images of ImagePaths whose
+	// width or height exceeds it, in pixels.
+	MaxImageDimension int `json:"-"`
+
+	// ImageURLs are http or https URLs of images, e.g. presigned object
+	// storage URLs. The client downloads and inlines them after Images and
+	// ImagePaths, or passes them to the API if it fetches images itself, see
+	// [Client.PassImageURLs].
+	ImageURLs []string `json:"image_urls,omitempty"`
+
 	// Options lists model-specific options. For example, temperature can be
 	// set through this field, if the model supports it.
 	Options map[string]any `json:"options"`
@@ -83,6 +119,15 @@ type GenerateRequest struct {
 	// (request that thinking _not_ be used) and unset (use the old behavior
 	// before this option was introduced)
 	Think *bool `json:"think,omitempty"`
+
+	// Logprobs asks for the log probability of every generated token, for
+	// models and servers that support it.
+	Logprobs bool `json:"logprobs,omitempty"`
+
+	// Filters clean up the complete response, after the filters of the
+	// client. They are applied by [Client.GenerateText] and
+	// [Client.FilterResponse]; streamed chunks are passed through unchanged.
+	Filters []ResponseFilter `json:"-"`
 }
 
 // GenerateResponse is the response passed into [GenerateResponseFunc].
@@ -110,9 +155,19 @@ type GenerateResponse struct {
 	// can be sent in the next request to keep a conversational memory.
 	Context []int `json:"context,omitempty"`
 
+	// Logprobs are the log probabilities of the tokens of this chunk, when
+	// requested with GenerateRequest.Logprobs.
+	Logprobs []Logprob `json:"logprobs,omitempty"`
+
 	Metrics
 }
 
+// Logprob is the log probability of a generated token.
+type Logprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+}
+
 // ChatRequest describes a request sent by [Client.Chat].
 type ChatRequest struct {
 	// Model is the model name, as in [GenerateRequest].
@@ -279,6 +334,111 @@ type ChatResponse struct {
 	Metrics
 }
 
+// EmbedRequest is the request passed to [Client.Embed].
+type EmbedRequest struct {
+	// Model is the model name.
+	Model string `json:"model"`
+
+	// Input is the list of texts to embed.
+	Input []string `json:"input"`
+
+	// KeepAlive controls how long the model will stay loaded in memory following
+	// this request.
+	KeepAlive *Duration `json:"keep_alive,omitempty"`
+
+	// Truncate truncates inputs that exceed the context length of the model
+	// instead of returning an error. It is true by default.
+	Truncate *bool `json:"truncate,omitempty"`
+
+	// Options lists model-specific options.
+	Options map[string]interface{} `json:"options"`
+}
+
+// EmbedResponse is the response from [Client.Embed], with one embedding per
+// input, in the same order.
+type EmbedResponse struct {
+	Model      string      `json:"model"`
+	Embeddings [][]float32 `json:"embeddings"`
+
+	TotalDuration   time.Duration `json:"total_duration,omitempty"`
+	LoadDuration    time.Duration `json:"load_duration,omitempty"`
+	PromptEvalCount int           `json:"prompt_eval_count,omitempty"`
+}
+
+// EmbeddingRequest is the request of [Client.Embeddings], embedding a single
+// prompt with the older endpoint of the API.
+type EmbeddingRequest struct {
+	// Model is the model name.
+	Model string `json:"model"`
+
+	// Prompt is the text to embed.
+	Prompt string `json:"prompt"`
+
+	// KeepAlive controls how long the model will stay loaded in memory following
+	// this request.
+	KeepAlive *Duration `json:"keep_alive,omitempty"`
+
+	// Options lists model-specific options.
+	Options map[string]interface{} `json:"options"`
+}
+
+// EmbeddingResponse is the response from [Client.Embeddings].
+type EmbeddingResponse struct {
+	Embedding []float64 `json:"embedding"`
+}
+
+// ShowRequest is the request passed to [Client.ShowModel].
+type ShowRequest struct {
+	// Model is the model name.
+	Model string `json:"model"`
+
+	// Verbose asks for the full model information, such as large token
+	// lists.
+	Verbose bool `json:"verbose,omitempty"`
+}
+
+// ShowResponse is the response from [Client.ShowModel].
+type ShowResponse struct {
+	License      string         `json:"license,omitempty"`
+	Modelfile    string         `json:"modelfile,omitempty"`
+	Parameters   string         `json:"parameters,omitempty"`
+	Template     string         `json:"template,omitempty"`
+	System       string         `json:"system,omitempty"`
+	Details      ModelDetails   `json:"details,omitempty"`
+	ModelInfo    map[string]any `json:"model_info,omitempty"`
+	Capabilities []string       `json:"capabilities,omitempty"`
+	ModifiedAt   time.Time      `json:"modified_at,omitzero"`
+}
+
+// ModelDetails describes the format and size of a model.
+type ModelDetails struct {
+	ParentModel       string   `json:"parent_model,omitempty"`
+	Format            string   `json:"format,omitempty"`
+	Family            string   `json:"family,omitempty"`
+	Families          []string `json:"families,omitempty"`
+	ParameterSize     string   `json:"parameter_size,omitempty"`
+	QuantizationLevel string   `json:"quantization_level,omitempty"`
+}
+
+// PullRequest is the request passed to [Client.PullModel].
+type PullRequest struct {
+	// Model is the model name.
+	Model string `json:"model"`
+
+	// Insecure allows pulling from a registry without TLS.
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// ProgressResponse is the progress of a pull passed into
+// [PullProgressFunc]. Total and Completed are in bytes, for the layer of
+// Digest being downloaded.
+type ProgressResponse struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
 type Metrics struct {
 	TotalDuration      time.Duration `json:"total_duration,omitempty"`
 	LoadDuration       time.Duration `json:"load_duration,omitempty"`
@@ -329,31 +489,37 @@ type Tensor struct {
 	Shape []uint64 `json:"shape"`
 }
 
+// Summary prints the metrics to stderr.
 func (m *Metrics) Summary() {
+	m.WriteSummary(os.Stderr)
+}
+
+// WriteSummary writes the metrics to w, one per line.
+func (m *Metrics) WriteSummary(w io.Writer) {
 	if m.TotalDuration > 0 {
-		fmt.Fprintf(os.Stderr, "total duration:       %v\n", m.TotalDuration)
+		fmt.Fprintf(w, "total duration:       %v\n", m.TotalDuration)
 	}
 
 	if m.LoadDuration > 0 {
-		fmt.Fprintf(os.Stderr, "load duration:        %v\n", m.LoadDuration)
+		fmt.Fprintf(w, "load duration:        %v\n", m.LoadDuration)
 	}
 
 	if m.PromptEvalCount > 0 {
-		fmt.Fprintf(os.Stderr, "prompt eval count:    %d token(s)\n", m.PromptEvalCount)
+		fmt.Fprintf(w, "prompt eval count:    %d token(s)\n", m.PromptEvalCount)
 	}
 
 	if m.PromptEvalDuration > 0 {
-		fmt.Fprintf(os.Stderr, "prompt eval duration: %s\n", m.PromptEvalDuration)
-		fmt.Fprintf(os.Stderr, "prompt eval rate:     %.2f tokens/s\n", float64(m.PromptEvalCount)/m.PromptEvalDuration.Seconds())
+		fmt.Fprintf(w, "prompt eval duration: %s\n", m.PromptEvalDuration)
+		fmt.Fprintf(w, "prompt eval rate:     %.2f tokens/s\n", float64(m.PromptEvalCount)/m.PromptEvalDuration.Seconds())
 	}
 
 	if m.EvalCount > 0 {
-		fmt.Fprintf(os.Stderr, "eval count:           %d token(s)\n", m.EvalCount)
+		fmt.Fprintf(w, "eval count:           %d token(s)\n", m.EvalCount)
 	}
 
 	if m.EvalDuration > 0 {
-		fmt.Fprintf(os.Stderr, "eval duration:        %s\n", m.EvalDuration)
-		fmt.Fprintf(os.Stderr, "eval rate:            %.2f tokens/s\n", float64(m.EvalCount)/m.EvalDuration.Seconds())
+		fmt.Fprintf(w, "eval duration:        %s\n", m.EvalDuration)
+		fmt.Fprintf(w, "eval rate:            %.2f tokens/s\n", float64(m.EvalCount)/m.EvalDuration.Seconds())
 	}
 }
 
@@ -402,16 +568,7 @@ func (d *Duration) UnmarshalJSON(b []byte) (err error) {
 func FormatParams(params map[string][]string) (map[string]any, error) {
 	opts := Options{}
 	valueOpts := reflect.ValueOf(&opts).Elem() // names of the fields in the options struct
-	typeOpts := reflect.TypeOf(opts)           // types of the fields in the options struct
-
-	// build map of json struct tags to their types
-	jsonOpts := make(map[string]reflect.StructField)
-	for _, field := range reflect.VisibleFields(typeOpts) {
-		jsonTag := strings.Split(field.Tag.Get("json"), ",")[0]
-		if jsonTag != "" {
-			jsonOpts[jsonTag] = field
-		}
-	}
+	jsonOpts := optionFields()                 // map of json struct tags to their types
 
 	out := make(map[string]any)
 	// iterate params and set values based on json struct tags
@@ -0,0 +1,336 @@
+// Package uniaitest provides a simulator of the UniAI API for tests of code
+// using the uniai client, in this module and downstream.
+//
+// A Server answers generate and chat requests with scripted replies: streamed
+// NDJSON chunks, error bodies, malformed lines or HTTP error codes. Requests
+// without a scripted reply get DefaultReply. Embeddings are derived from the
+// input text, so equal inputs always get equal vectors.
+package uniaitest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sampila/uniai-client/pkg/uniai"
+)
+
+// Auth is the basic auth credentials of clients returned by
+// [Server.NewClient].
+const Auth = "uniaitest:secret"
+
+// Version is the server version reported by the simulator.
+const Version = "0.0.0-uniaitest"
+
+// EmbeddingSize is the length of the vectors returned by /api/embed and
+// /api/embeddings.
+const EmbeddingSize = 8
+
+// Reply is the scripted answer to a generate or chat request.
+type Reply struct {
+	// Chunks are streamed in order, one NDJSON line each, the last one
+	// marked done with the token counts.
+	Chunks []string
+
+	// PromptTokens and CompletionTokens are reported with the last chunk.
+	PromptTokens     int
+	CompletionTokens int
+
+	// Status, if set, is the HTTP status code of the response. With a
+	// status of 400 or more and no Body, the body is {"error": Error}.
+	Status int
+	// Error, if set, is sent as an {"error": ...} line after the chunks,
+	// as the API does when generation fails mid-stream.
+	Error string
+	// Body, if set, is sent as is instead of the chunks, e.g. a plain text
+	// error page or a malformed line.
+	Body string
+
+	// Delay is waited before every chunk.
+	Delay time.Duration
+}
+
+// Text returns a reply streaming text in chunks of words.
+func Text(text string) Reply {
+	return Stream(strings.SplitAfter(text, " ")...)
+}
+
+// Stream returns a reply streaming chunks.
+func Stream(chunks ...string) Reply {
+	return Reply{Chunks: chunks, PromptTokens: 10, CompletionTokens: len(chunks)}
+}
+
+// Fail returns a reply with the HTTP status and a JSON error body.
+func Fail(status int, message string) Reply {
+	return Reply{Status: status, Error: message}
+}
+
+// FailMidStream returns a reply streaming chunks before an error line.
+func FailMidStream(message string, chunks ...string) Reply {
+	return Reply{Chunks: chunks, Error: message}
+}
+
+// Raw returns a reply with the HTTP status and body sent as is.
+func Raw(status int, body string) Reply {
+	return Reply{Status: status, Body: body}
+}
+
+// Malformed returns a reply whose stream is not valid NDJSON.
+func Malformed() Reply {
+	return Reply{Body: "{\"response\": \"truncated\n"}
+}
+
+// DefaultReply answers the requests without a scripted reply.
+var DefaultReply = Text("Hello world")
+
+// Request is a request received by the simulator.
+type Request struct {
+	Method string
+	Path   string
+	Auth   string // basic auth credentials, as user:password
+	Body   []byte
+}
+
+// Decode unmarshals the JSON body of the request into v.
+func (r Request) Decode(v any) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Server is a UniAI API simulator listening on a local port.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	replies  []Reply
+	requests []Request
+	models   []uniai.ModelInfo
+}
+
+// NewServer starts a simulator. It must be closed with Close.
+func NewServer() *Server {
+	s := &Server{
+		models: []uniai.ModelInfo{{Name: uniai.ModelDefault, Model: uniai.ModelDefault}},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.heartbeat)
+	mux.HandleFunc("POST /api/generate", s.generate)
+	mux.HandleFunc("POST /api/chat", s.chat)
+	mux.HandleFunc("POST /api/embed", s.embed)
+	mux.HandleFunc("POST /api/embeddings", s.embeddings)
+	mux.HandleFunc("GET /api/tags", s.tags)
+	mux.HandleFunc("GET /api/version", s.version)
+	s.Server = httptest.NewServer(s.record(mux))
+
+	return s
+}
+
+// NewClient returns a client of the simulator.
+func (s *Server) NewClient() (*uniai.Client, error) {
+	return uniai.NewClient(s.URL, s.Client(), Auth)
+}
+
+// Reply queues replies to the next generate or chat requests, in order.
+func (s *Server) Reply(replies ...Reply) {
+	s.mu.Lock()
+	s.replies = append(s.replies, replies...)
+	s.mu.Unlock()
+}
+
+// SetModels sets the models listed by /api/tags.
+func (s *Server) SetModels(models ...uniai.ModelInfo) {
+	s.mu.Lock()
+	s.models = models
+	s.mu.Unlock()
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req := Request{Method: r.Method, Path: r.URL.Path, Body: body}
+		if user, password, ok := r.BasicAuth(); ok {
+			req.Auth = user + ":" + password
+		}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		s.mu.Unlock()
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// next returns the next scripted reply, or DefaultReply.
+func (s *Server) next() Reply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.replies) == 0 {
+		return DefaultReply
+	}
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+
+	return reply
+}
+
+func (s *Server) generate(w http.ResponseWriter, r *http.Request) {
+	var req uniai.GenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.stream(w, s.next(), func(chunk string, done bool, m uniai.Metrics) any {
+		return uniai.GenerateResponse{Model: req.Model, CreatedAt: time.Now().UTC(), Response: chunk, Done: done, Metrics: m}
+	})
+}
+
+func (s *Server) chat(w http.ResponseWriter, r *http.Request) {
+	var req uniai.ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.stream(w, s.next(), func(chunk string, done bool, m uniai.Metrics) any {
+		return uniai.ChatResponse{
+			Model:     req.Model,
+			CreatedAt: time.Now().UTC(),
+			Message:   uniai.Message{Role: "assistant", Content: chunk},
+			Done:      done,
+			Metrics:   m,
+		}
+	})
+}
+
+// stream writes reply as NDJSON, with line building the line of a chunk.
+func (s *Server) stream(w http.ResponseWriter, reply Reply, line func(chunk string, done bool, m uniai.Metrics) any) {
+	if reply.Body != "" {
+		w.WriteHeader(max(reply.Status, http.StatusOK))
+		io.WriteString(w, reply.Body)
+		return
+	}
+	if reply.Status >= http.StatusBadRequest {
+		writeError(w, reply.Status, reply.Error)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(max(reply.Status, http.StatusOK))
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	for i, chunk := range reply.Chunks {
+		if reply.Delay > 0 {
+			time.Sleep(reply.Delay)
+		}
+
+		done := i == len(reply.Chunks)-1 && reply.Error == ""
+		var m uniai.Metrics
+		if done {
+			m = uniai.Metrics{
+				TotalDuration:   time.Millisecond,
+				PromptEvalCount: reply.PromptTokens,
+				EvalCount:       reply.CompletionTokens,
+			}
+		}
+		enc.Encode(line(chunk, done, m))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if reply.Error != "" {
+		enc.Encode(map[string]string{"error": reply.Error})
+	}
+}
+
+func (s *Server) embed(w http.ResponseWriter, r *http.Request) {
+	var req uniai.EmbedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp := uniai.EmbedResponse{Model: req.Model}
+	for _, input := range req.Input {
+		resp.Embeddings = append(resp.Embeddings, Embedding(input))
+		resp.PromptEvalCount += len(input)/4 + 1
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) embeddings(w http.ResponseWriter, r *http.Request) {
+	var req uniai.EmbeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var resp uniai.EmbeddingResponse
+	for _, v := range Embedding(req.Prompt) {
+		resp.Embedding = append(resp.Embedding, float64(v))
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) tags(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	models := s.models
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]any{"models": models})
+}
+
+func (s *Server) heartbeat(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, "UniAI is running")
+}
+
+func (s *Server) version(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{"version": Version})
+}
+
+// Embedding returns the vector the simulator embeds text as, derived from
+// its SHA-256.
+func Embedding(text string) []float32 {
+	sum := sha256.Sum256([]byte(text))
+	vec := make([]float32, EmbeddingSize)
+	for i := range vec {
+		vec[i] = float32(sum[i])/127.5 - 1
+	}
+
+	return vec
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	if message == "" {
+		message = fmt.Sprintf("%d %s", status, http.StatusText(status))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
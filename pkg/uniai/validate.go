@@ -0,0 +1,187 @@
+package uniai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// MaxImageSize is the largest image accepted in a request.
+const MaxImageSize = 20 * MegaByte
+
+// ImageFormats are the content types of the images accepted in a request.
+var ImageFormats = []string{"image/jpeg", "image/png", "image/webp"}
+
+// ErrInvalidRequest is wrapped by the errors of [GenerateRequest.Validate]
+// and [ChatRequest.Validate].
+var ErrInvalidRequest = errors.New("invalid request")
+
+// chatRoles are the roles of the messages of a chat.
+var chatRoles = []string{"system", "user", "assistant", "tool"}
+
+// Validate checks the request before it is sent, so mistakes are reported
+// with a descriptive error instead of a 400 from the API.
+func (r *GenerateRequest) Validate() error {
+	if r.Model == "" {
+		return invalid("model is empty")
+	}
+	if strings.TrimSpace(r.Prompt) == "" {
+		return invalid("prompt is empty")
+	}
+	if err := validateImages(r.Images); err != nil {
+		return err
+	}
+	if err := validateImageURLs(r.ImageURLs); err != nil {
+		return err
+	}
+	if err := validateFormat(r.Format); err != nil {
+		return err
+	}
+
+	return validateOptions(r.Options)
+}
+
+// Validate checks the request before it is sent, as
+// [GenerateRequest.Validate] does.
+func (r *ChatRequest) Validate() error {
+	if r.Model == "" {
+		return invalid("model is empty")
+	}
+	if len(r.Messages) == 0 {
+		return invalid("no messages")
+	}
+	for i, m := range r.Messages {
+		if !slices.Contains(chatRoles, strings.ToLower(m.Role)) {
+			return invalid("message %d: unknown role %q", i+1, m.Role)
+		}
+		if err := validateImages(m.Images); err != nil {
+			return fmt.Errorf("message %d: %w", i+1, err)
+		}
+	}
+	last := r.Messages[len(r.Messages)-1]
+	if strings.TrimSpace(last.Content) == "" && len(last.Images) == 0 {
+		return invalid("prompt is empty")
+	}
+	if err := validateFormat(r.Format); err != nil {
+		return err
+	}
+
+	return validateOptions(r.Options)
+}
+
+func invalid(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidRequest, fmt.Sprintf(format, args...))
+}
+
+// validateImages checks that every image is of a known format and not larger
+// than MaxImageSize.
+func validateImages(images []ImageData) error {
+	for i, img := range images {
+		if len(img) == 0 {
+			return invalid("image %d is empty", i+1)
+		}
+		if len(img) > MaxImageSize {
+			return invalid("image %d is %d bytes, more than the %d allowed", i+1, len(img), MaxImageSize)
+		}
+		if format := http.DetectContentType(img); !slices.Contains(ImageFormats, format) {
+			return invalid("image %d is %s, not one of %s", i+1, format, strings.Join(ImageFormats, ", "))
+		}
+	}
+
+	return nil
+}
+
+// validateImageURLs checks that every image URL is an absolute http or
+// https URL.
+func validateImageURLs(urls []string) error {
+	for i, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return invalid("image URL %d is not a valid URL", i+1)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return invalid("image URL %d %s is not an http or https URL", i+1, redactURL(u))
+		}
+	}
+
+	return nil
+}
+
+// validateFormat checks that a response format is "json" or a JSON schema.
+func validateFormat(format json.RawMessage) error {
+	if len(format) == 0 {
+		return nil
+	}
+	if !json.Valid(format) {
+		return invalid("format is not valid JSON")
+	}
+
+	var s string
+	if json.Unmarshal(format, &s) == nil {
+		if s != "json" && s != "" {
+			return invalid("format %q is neither \"json\" nor a JSON schema", s)
+		}
+		return nil
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(format, &schema); err != nil {
+		return invalid("format is neither \"json\" nor a JSON schema")
+	}
+
+	return nil
+}
+
+// validateOptions checks that every option is a field of [Options] with a
+// value of a matching kind.
+func validateOptions(opts map[string]any) error {
+	fields := optionFields()
+	for key, value := range opts {
+		field, ok := fields[key]
+		if !ok {
+			return invalid("unknown option %q", key)
+		}
+		if value == nil {
+			continue
+		}
+
+		kind := field.Type.Kind()
+		if kind == reflect.Pointer {
+			kind = field.Type.Elem().Kind()
+		}
+		v := reflect.ValueOf(value)
+		var valid bool
+		switch kind {
+		case reflect.Int, reflect.Float32:
+			valid = v.CanInt() || v.CanUint() || v.CanFloat()
+		case reflect.Bool:
+			valid = v.Kind() == reflect.Bool
+		case reflect.Slice:
+			valid = v.Kind() == reflect.Slice || v.Kind() == reflect.String
+		default:
+			valid = true
+		}
+		if !valid {
+			return invalid("option %q has a value of type %T", key, value)
+		}
+	}
+
+	return nil
+}
+
+// optionFields returns the fields of [Options] by their JSON names.
+func optionFields() map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for _, field := range reflect.VisibleFields(reflect.TypeOf(Options{})) {
+		jsonTag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if jsonTag != "" {
+			fields[jsonTag] = field
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,5 @@
+// Package uniaipb holds the gRPC API of the document processing server,
+// generated from uniai.proto.
+package uniaipb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative uniai.proto
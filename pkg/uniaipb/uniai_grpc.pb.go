@@ -0,0 +1,212 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: uniai.proto
+
+// The document processing API of `uniai serve --grpc-addr`, mirroring the
+// jobs of the WebSocket endpoint.
+
+package uniaipb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Processing_SubmitDocument_FullMethodName = "/uniai.v1.Processing/SubmitDocument"
+	Processing_StreamResults_FullMethodName  = "/uniai.v1.Processing/StreamResults"
+	Processing_GetJob_FullMethodName         = "/uniai.v1.Processing/GetJob"
+)
+
+// ProcessingClient is the client API for Processing service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ProcessingClient interface {
+	// SubmitDocument queues a document for processing and returns the job.
+	SubmitDocument(ctx context.Context, in *SubmitDocumentRequest, opts ...grpc.CallOption) (*Job, error)
+	// StreamResults streams the events of a job, from its first one, until
+	// the job is done or failed.
+	StreamResults(ctx context.Context, in *StreamResultsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+	// GetJob returns the status and the page results of a job.
+	GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error)
+}
+
+type processingClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewProcessingClient(cc grpc.ClientConnInterface) ProcessingClient {
+	return &processingClient{cc}
+}
+
+func (c *processingClient) SubmitDocument(ctx context.Context, in *SubmitDocumentRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Processing_SubmitDocument_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *processingClient) StreamResults(ctx context.Context, in *StreamResultsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Processing_ServiceDesc.Streams[0], Processing_StreamResults_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamResultsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Processing_StreamResultsClient = grpc.ServerStreamingClient[Event]
+
+func (c *processingClient) GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Processing_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ProcessingServer is the server API for Processing service.
+// All implementations must embed UnimplementedProcessingServer
+// for forward compatibility.
+type ProcessingServer interface {
+	// SubmitDocument queues a document for processing and returns the job.
+	SubmitDocument(context.Context, *SubmitDocumentRequest) (*Job, error)
+	// StreamResults streams the events of a job, from its first one, until
+	// the job is done or failed.
+	StreamResults(*StreamResultsRequest, grpc.ServerStreamingServer[Event]) error
+	// GetJob returns the status and the page results of a job.
+	GetJob(context.Context, *GetJobRequest) (*Job, error)
+	mustEmbedUnimplementedProcessingServer()
+}
+
+// UnimplementedProcessingServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedProcessingServer struct{}
+
+func (UnimplementedProcessingServer) SubmitDocument(context.Context, *SubmitDocumentRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitDocument not implemented")
+}
+func (UnimplementedProcessingServer) StreamResults(*StreamResultsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamResults not implemented")
+}
+func (UnimplementedProcessingServer) GetJob(context.Context, *GetJobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedProcessingServer) mustEmbedUnimplementedProcessingServer() {}
+func (UnimplementedProcessingServer) testEmbeddedByValue()                    {}
+
+// UnsafeProcessingServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ProcessingServer will
+// result in compilation errors.
+type UnsafeProcessingServer interface {
+	mustEmbedUnimplementedProcessingServer()
+}
+
+func RegisterProcessingServer(s grpc.ServiceRegistrar, srv ProcessingServer) {
+	// If the following call pancis, it indicates UnimplementedProcessingServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Processing_ServiceDesc, srv)
+}
+
+func _Processing_SubmitDocument_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitDocumentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessingServer).SubmitDocument(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Processing_SubmitDocument_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessingServer).SubmitDocument(ctx, req.(*SubmitDocumentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Processing_StreamResults_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamResultsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ProcessingServer).StreamResults(m, &grpc.GenericServerStream[StreamResultsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Processing_StreamResultsServer = grpc.ServerStreamingServer[Event]
+
+func _Processing_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessingServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Processing_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessingServer).GetJob(ctx, req.(*GetJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Processing_ServiceDesc is the grpc.ServiceDesc for Processing service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Processing_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "uniai.v1.Processing",
+	HandlerType: (*ProcessingServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitDocument",
+			Handler:    _Processing_SubmitDocument_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _Processing_GetJob_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamResults",
+			Handler:       _Processing_StreamResults_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "uniai.proto",
+}